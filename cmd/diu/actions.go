@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// actionPeriodTitles names the --daily and --weekly periods in the diu
+// stats --actions title
+var actionPeriodTitles = map[string]string{
+	"24h": "Actions per Tool (Last 24 Hours)",
+	"7d":  "Actions per Tool (Last 7 Days)",
+}
+
+// toolActions is how often one tool's executions did each action
+type toolActions struct {
+	Tool       string         `json:"tool"`
+	Executions int            `json:"executions"`
+	Actions    []actionBucket `json:"actions"`
+}
+
+// actionBucket is the executions of one action and their share of the
+// tool's executions
+type actionBucket struct {
+	Action  string  `json:"action"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// actionReport is the --json form of diu stats --actions
+type actionReport struct {
+	Period string        `json:"period"`
+	Tools  []toolActions `json:"tools"`
+}
+
+// executionAction names what an execution did: the action its monitor
+// recorded, otherwise the subcommand or first argument it ran with
+func executionAction(exec *core.ExecutionRecord) string {
+	if action, _ := exec.Metadata["action"].(string); action != "" {
+		return action
+	}
+	if subcommand, _ := exec.Metadata["subcommand"].(string); subcommand != "" {
+		return subcommand
+	}
+	if len(exec.Args) > 0 {
+		return exec.Args[0]
+	}
+	return statsUnknownKey
+}
+
+// countActions counts each tool's executions per action. Tools are ordered
+// by descending executions and each tool's actions by descending count.
+func countActions(executions []*core.ExecutionRecord) []toolActions {
+	counts := make(map[string]map[string]int)
+	totals := make(map[string]int)
+	for _, exec := range executions {
+		if counts[exec.Tool] == nil {
+			counts[exec.Tool] = make(map[string]int)
+		}
+		counts[exec.Tool][executionAction(exec)]++
+		totals[exec.Tool]++
+	}
+
+	tools := make([]toolActions, 0, len(counts))
+	for tool, actions := range counts {
+		entry := toolActions{Tool: tool, Executions: totals[tool]}
+		for action, count := range actions {
+			entry.Actions = append(entry.Actions, actionBucket{
+				Action:  action,
+				Count:   count,
+				Percent: float64(count) * 100 / float64(entry.Executions),
+			})
+		}
+		sort.Slice(entry.Actions, func(i, j int) bool {
+			if entry.Actions[i].Count != entry.Actions[j].Count {
+				return entry.Actions[i].Count > entry.Actions[j].Count
+			}
+			return entry.Actions[i].Action < entry.Actions[j].Action
+		})
+		tools = append(tools, entry)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		if tools[i].Executions != tools[j].Executions {
+			return tools[i].Executions > tools[j].Executions
+		}
+		return tools[i].Tool < tools[j].Tool
+	})
+	return tools
+}
+
+// showActionStats prints the share of each tool's executions that went to
+// each action, such as installs, runs, and audits
+func showActionStats(cmd *command, period string, executions []*core.ExecutionRecord) error {
+	report := actionReport{Period: period, Tools: countActions(executions)}
+	if jsonOutput(cmd) {
+		return printJSON(report)
+	}
+
+	title, ok := actionPeriodTitles[period]
+	if !ok {
+		title = "Actions per Tool"
+	}
+	fmt.Println(titleStyle.Render(title))
+	if len(report.Tools) == 0 {
+		fmt.Println()
+		fmt.Println(infoStyle.Render("No executions found"))
+		return nil
+	}
+
+	top := flagInt(cmd, "top")
+	for _, tool := range report.Tools {
+		output := newTable([]tableColumn{
+			{Header: "ACTION", MaxWidth: packageNameColumnWidth},
+			{Header: "RUNS", AlignRight: true},
+			{Header: "SHARE", AlignRight: true},
+		}, false)
+		for i, action := range tool.Actions {
+			if top > 0 && i >= top {
+				break
+			}
+			output.AddRow(action.Action, strconv.Itoa(action.Count), fmt.Sprintf("%.1f%%", action.Percent))
+		}
+
+		fmt.Println()
+		toolStyle := newStyle().Foreground(getToolColor(tool.Tool))
+		fmt.Printf("%s %d executions\n", toolStyle.Render(tool.Tool+":"), tool.Executions)
+		_ = output.Render(os.Stdout)
+		if top > 0 && len(tool.Actions) > top {
+			fmt.Println(subtitleStyle.Render(fmt.Sprintf("  ... %d more (raise --top to show)", len(tool.Actions)-top)))
+		}
+	}
+	return nil
+}
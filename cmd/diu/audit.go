@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/osv"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// auditFinding is a tracked package with known vulnerabilities
+type auditFinding struct {
+	Package         *core.PackageInfo   `json:"package"`
+	Vulnerabilities []osv.Vulnerability `json:"vulnerabilities"`
+}
+
+// auditReport is what diu audit found
+type auditReport struct {
+	Checked  int            `json:"checked"`
+	Skipped  int            `json:"skipped"`
+	Findings []auditFinding `json:"findings"`
+}
+
+// auditPackages looks up known vulnerabilities of tracked packages in OSV,
+// listing the most recently used packages first
+func auditPackages(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	packages, err := store.GetPackages(core.NormalizeToolName(flagString(cmd, "tool")))
+	closeStore(store)
+	if err != nil {
+		return fmt.Errorf("failed to get packages: %w", err)
+	}
+
+	report := auditReport{Findings: []auditFinding{}}
+	lookups := make(map[*core.PackageInfo]osv.Package, len(packages))
+	queries := make([]osv.Package, 0, len(packages))
+	for _, pkg := range packages {
+		lookup, ok := osv.PackageFor(pkg)
+		if !ok {
+			report.Skipped++
+			continue
+		}
+		lookups[pkg] = lookup
+		queries = append(queries, lookup)
+	}
+	report.Checked = len(queries)
+
+	if len(queries) > 0 {
+		if !jsonOutput(cmd) {
+			fmt.Println(infoStyle.Render(fmt.Sprintf("Checking %d packages against OSV...", len(queries))))
+		}
+		results, err := osv.NewClient(config).Query(context.Background(), queries, flagBool(cmd, "refresh"))
+		if err != nil {
+			return err
+		}
+		for pkg, lookup := range lookups {
+			if vulns := results[lookup]; len(vulns) > 0 {
+				report.Findings = append(report.Findings, auditFinding{Package: pkg, Vulnerabilities: vulns})
+			}
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i].Package, report.Findings[j].Package
+		if !a.LastUsed.Equal(b.LastUsed) {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Name < b.Name
+	})
+
+	if jsonOutput(cmd) {
+		return printJSON(report)
+	}
+	printAuditReport(report)
+	return nil
+}
+
+// printAuditReport prints each vulnerable package with its vulnerabilities
+func printAuditReport(report auditReport) {
+	if report.Skipped > 0 {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Skipped %d packages without a version or an OSV ecosystem", report.Skipped)))
+	}
+	if len(report.Findings) == 0 {
+		fmt.Println(successStyle.Render(fmt.Sprintf("No known vulnerabilities in %d packages", report.Checked)))
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(titleStyle.Render("Vulnerable Packages"))
+	count := 0
+	for _, finding := range report.Findings {
+		pkg := finding.Package
+		fmt.Println()
+		fmt.Printf("%s %s@%s  %s\n", subtitleStyle.Render(pkg.Tool), pkg.Name, pkg.Version,
+			infoStyle.Render("last used: "+formatLastUsed(pkg.LastUsed)))
+		for _, vuln := range finding.Vulnerabilities {
+			count++
+			severity := vuln.Severity
+			if severity == "" {
+				severity = "-"
+			}
+			line := fmt.Sprintf("  %-20s %-9s %s", vuln.ID, severity, truncate(vuln.Summary, 60))
+			if len(vuln.Fixed) > 0 {
+				line += " (fixed in " + strings.Join(vuln.Fixed, ", ") + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+	fmt.Println()
+	fmt.Println(subtitleStyle.Render(fmt.Sprintf("%d vulnerabilities in %d of %d packages checked",
+		count, len(report.Findings), report.Checked)))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/remote"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// backup creates a manual backup, uploading it when a remote target is
+// given with --to or configured as storage.backup_remote
+func backup(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	backupPath, err := store.Backup()
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	fmt.Println(successStyle.Render("Backup created"))
+
+	remoteURL := flagString(cmd, "to")
+	if remoteURL == "" {
+		remoteURL = config.Storage.BackupRemote
+	}
+	if remoteURL == "" {
+		return nil
+	}
+	return uploadBackup(context.Background(), config, remoteURL, backupPath)
+}
+
+// uploadBackup copies a local backup file to remoteURL
+func uploadBackup(ctx context.Context, config *core.Config, remoteURL, backupPath string) error {
+	target, object, err := remote.Open(remoteURL, remoteOptions(config))
+	if err != nil {
+		return err
+	}
+	if object == "" {
+		object = filepath.Base(backupPath)
+	}
+
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if err := target.Put(ctx, object, data); err != nil {
+		return fmt.Errorf("failed to upload backup to %s: %w", target, err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Uploaded %s to %s", object, target)))
+	return nil
+}
+
+// restoreBackup restores storage from a local backup file or, with --from or
+// storage.backup_remote, from a remote target. Without an object in the
+// remote URL, the newest backup under the prefix is used. The current data is
+// backed up before it is replaced.
+func restoreBackup(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var data []byte
+	var source string
+	if len(args) > 0 {
+		data, source, err = readLocalBackup(config, args[0])
+	} else {
+		data, source, err = downloadBackup(context.Background(), config, flagString(cmd, "from"))
+	}
+	if err != nil {
+		return err
+	}
+
+	var incoming core.StorageData
+	if err := json.Unmarshal(data, &incoming); err != nil {
+		return fmt.Errorf("%s is not a valid backup: %w", source, err)
+	}
+	current, err := readCurrentStorage(config.Storage.JSONFile)
+	if err != nil {
+		return err
+	}
+
+	printRestoreSummary(source, &incoming, current)
+	if flagBool(cmd, "dry-run") {
+		fmt.Println(infoStyle.Render("Dry run: no changes made"))
+		return nil
+	}
+	if !flagBool(cmd, "yes") {
+		answer, err := readPrompt(bufio.NewReader(os.Stdin), "Replace current data with this backup? [y/N] ")
+		if err != nil || !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			return fmt.Errorf("restore cancelled")
+		}
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	safetyPath, err := store.Backup()
+	if err != nil {
+		return fmt.Errorf("failed to back up current data: %w", err)
+	}
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Current data backed up to %s", safetyPath)))
+
+	// Restore from a fresh copy: writing the safety backup may have pruned
+	// the file being restored.
+	restorePath, err := store.ImportBackup(data)
+	if err != nil {
+		return fmt.Errorf("failed to stage backup: %w", err)
+	}
+	if err := store.Restore(restorePath); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Restored %s", source)))
+	return nil
+}
+
+// readLocalBackup reads a backup file given as a path or as a file name in
+// the storage directory
+func readLocalBackup(config *core.Config, name string) ([]byte, string, error) {
+	path := name
+	if _, err := safefs.Stat(path); os.IsNotExist(err) && filepath.Base(name) == name {
+		path = filepath.Join(filepath.Dir(config.Storage.JSONFile), name)
+	}
+
+	data, err := safefs.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read backup: %w", err)
+	}
+	return data, path, nil
+}
+
+// downloadBackup fetches a backup from remoteURL, falling back to
+// storage.backup_remote
+func downloadBackup(ctx context.Context, config *core.Config, remoteURL string) ([]byte, string, error) {
+	if remoteURL == "" {
+		remoteURL = config.Storage.BackupRemote
+	}
+	if remoteURL == "" {
+		return nil, "", fmt.Errorf("backup file required: pass a backup path, --from, or set storage.backup_remote")
+	}
+
+	target, object, err := remote.Open(remoteURL, remoteOptions(config))
+	if err != nil {
+		return nil, "", err
+	}
+	if object == "" {
+		object, err = remote.LatestBackup(ctx, target)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	data, err := target.Get(ctx, object)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s from %s: %w", object, target, err)
+	}
+	return data, fmt.Sprintf("%s from %s", object, target), nil
+}
+
+// readCurrentStorage loads the storage file as it is on disk, returning
+// empty data when it does not exist yet
+func readCurrentStorage(path string) (*core.StorageData, error) {
+	current := &core.StorageData{}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return current, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage: %w", err)
+	}
+	if err := json.Unmarshal(data, current); err != nil {
+		return nil, fmt.Errorf("failed to parse storage: %w", err)
+	}
+	sharded, err := storage.ReadShards(path, current.Metadata.Shards)
+	if err != nil {
+		return nil, err
+	}
+	current.Executions = append(current.Executions, sharded...)
+	return current, nil
+}
+
+// printRestoreSummary compares record counts in the backup and current data
+func printRestoreSummary(source string, incoming, current *core.StorageData) {
+	fmt.Println(titleStyle.Render("Restore"))
+	fmt.Println(subtitleStyle.Render(source))
+	fmt.Println()
+	fmt.Printf("  %-12s %10s %10s\n", "", "Backup", "Current")
+	fmt.Printf("  %-12s %10d %10d\n", "Executions", len(incoming.Executions), len(current.Executions))
+	fmt.Printf("  %-12s %10d %10d\n", "Packages", countStoredPackages(incoming), countStoredPackages(current))
+	fmt.Println()
+}
+
+func countStoredPackages(data *core.StorageData) int {
+	count := 0
+	for _, packages := range data.Packages {
+		count += len(packages)
+	}
+	return count
+}
+
+func remoteOptions(config *core.Config) remote.Options {
+	return remote.Options{S3Endpoint: config.Storage.BackupS3Endpoint}
+}
+
+// listBackups prints the available storage backups, newest first
+func listBackups(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	backups, err := storage.ListBackups(config.Storage.JSONFile)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Println(infoStyle.Render("No backups found"))
+		return nil
+	}
+
+	fmt.Println(titleStyle.Render("Backups"))
+	fmt.Println()
+
+	var total int64
+	for _, backup := range backups {
+		total += backup.Size
+		fmt.Printf("  %s  %9s  %s\n",
+			backup.CreatedAt.Format("2006-01-02 15:04:05"),
+			formatBytes(backup.Size),
+			backup.Path,
+		)
+	}
+	fmt.Println()
+	fmt.Println(subtitleStyle.Render(fmt.Sprintf("%d backups, %s total", len(backups), formatBytes(total))))
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// defaultTimelineDays is the timeline span when stats has no time window.
+const defaultTimelineDays = 30
+
+var (
+	sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+	heatmapLevels   = []rune(" ░▒▓█")
+	// heatmapColors shade heatmap cells from no activity to the busiest hour.
+	heatmapColors = []color{"238", "22", "28", "34", "46"}
+	heatmapDays   = []time.Weekday{
+		time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+		time.Friday, time.Saturday, time.Sunday,
+	}
+)
+
+// renderHeatmap draws executions as a weekday by hour-of-day grid in local
+// time, shading each cell relative to the busiest hour
+func renderHeatmap(executions []*core.ExecutionRecord) string {
+	var counts [7][24]int
+	peak := 0
+	for _, exec := range executions {
+		local := exec.Timestamp.Local()
+		day := (int(local.Weekday()) + 6) % 7
+		counts[day][local.Hour()]++
+		if counts[day][local.Hour()] > peak {
+			peak = counts[day][local.Hour()]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("     ")
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&b, "%-6d", hour)
+	}
+	b.WriteString("\n")
+
+	for row, weekday := range heatmapDays {
+		fmt.Fprintf(&b, "%-4s ", weekday.String()[:3])
+		for hour := 0; hour < 24; hour++ {
+			level := scaleLevel(counts[row][hour], peak, len(heatmapLevels))
+			cell := strings.Repeat(string(heatmapLevels[level]), 2)
+			b.WriteString(newStyle().Foreground(heatmapColors[level]).Render(cell))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "     less %s more (peak %d per hour)\n", string(heatmapLevels), peak)
+	return b.String()
+}
+
+// renderTimeline draws daily execution counts from start through end as a
+// sparkline, one character per local day
+func renderTimeline(executions []*core.ExecutionRecord, start, end time.Time) string {
+	first := startOfDay(start.Local())
+	last := startOfDay(end.Local())
+	if last.Before(first) {
+		first = last
+	}
+
+	days := int(last.Sub(first).Hours()/24+0.5) + 1
+	counts := make([]int, days)
+	peak, total := 0, 0
+	for _, exec := range executions {
+		index := int(startOfDay(exec.Timestamp.Local()).Sub(first).Hours()/24 + 0.5)
+		if index < 0 || index >= days {
+			continue
+		}
+		counts[index]++
+		total++
+		if counts[index] > peak {
+			peak = counts[index]
+		}
+	}
+
+	var spark strings.Builder
+	for _, count := range counts {
+		if count == 0 {
+			spark.WriteRune(' ')
+			continue
+		}
+		// Any activity gets at least the lowest bar so quiet days stay visible.
+		level := (count*len(sparklineLevels)+peak-1)/peak - 1
+		spark.WriteRune(sparklineLevels[level])
+	}
+
+	return fmt.Sprintf("%s %s %s\n%d executions over %d days, peak %d per day\n",
+		first.Format(time.DateOnly),
+		successStyle.Render(spark.String()),
+		last.Format(time.DateOnly),
+		total, days, peak)
+}
+
+// scaleLevel maps count onto 0..levels-1, where only a zero count maps to 0
+func scaleLevel(count, peak, levels int) int {
+	if count <= 0 || peak <= 0 {
+		return 0
+	}
+	level := (count*(levels-1) + peak - 1) / peak
+	if level < 1 {
+		level = 1
+	}
+	if level > levels-1 {
+		level = levels - 1
+	}
+	return level
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -16,10 +16,14 @@ type command struct {
 	Long   string
 	Hidden bool
 	RunE   func(*command, []string) error
+	// ValidArgsFunction completes positional arguments in the shell.
+	ValidArgsFunction completionFunc
 
-	parent   *command
-	flags    *flagSet
-	commands []*command
+	parent          *command
+	flags           *flagSet
+	persistentFlags *flagSet
+	flagCompletions map[string]completionFunc
+	commands        []*command
 }
 
 func (c *command) AddCommand(commands ...*command) {
@@ -34,6 +38,17 @@ func (c *command) Execute(args []string) error {
 }
 
 func (c *command) execute(args []string) error {
+	c.inheritFlags()
+
+	// Persistent flags may come before the subcommand, e.g. diu --json stats.
+	if len(args) > 0 && len(c.commands) > 0 && isLeadingFlag(args[0]) {
+		next, err := c.Flags().parseFlagAt(args, 0)
+		if err != nil {
+			return err
+		}
+		return c.execute(args[next+1:])
+	}
+
 	if len(args) > 0 {
 		switch args[0] {
 		case "help":
@@ -82,6 +97,56 @@ func (c *command) Flags() *flagSet {
 	return c.flags
 }
 
+// PersistentFlags are flags accepted by c and every command below it
+func (c *command) PersistentFlags() *flagSet {
+	if c.persistentFlags == nil {
+		c.persistentFlags = newFlagSet()
+	}
+	return c.persistentFlags
+}
+
+// inheritFlags makes c's flags fall back to the persistent flags of c and
+// its ancestors, nearest first
+func (c *command) inheritFlags() {
+	tail := c.Flags()
+	for current := c; current != nil; current = current.parent {
+		if current.persistentFlags != nil {
+			tail.inherited = current.persistentFlags
+			tail = current.persistentFlags
+		}
+	}
+	tail.inherited = nil
+}
+
+// isLeadingFlag reports whether arg is a flag rather than a subcommand or one
+// of the help and version arguments execute handles itself
+func isLeadingFlag(arg string) bool {
+	switch arg {
+	case "-", "--", "-h", "--help", "-v", "--version":
+		return false
+	}
+	return strings.HasPrefix(arg, "-")
+}
+
+// RegisterFlagCompletionFunc completes the values of a flag in the shell
+func (c *command) RegisterFlagCompletionFunc(name string, fn completionFunc) {
+	if c.flagCompletions == nil {
+		c.flagCompletions = make(map[string]completionFunc)
+	}
+	c.flagCompletions[name] = fn
+}
+
+// flagCompletion finds the completion for a flag on c or, for persistent
+// flags, on an ancestor
+func (c *command) flagCompletion(name string) completionFunc {
+	for current := c; current != nil; current = current.parent {
+		if fn, ok := current.flagCompletions[name]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
 func (c *command) Flag(name string) *flag {
 	return c.Flags().lookupLong(name)
 }
@@ -131,16 +196,31 @@ func (c *command) printUsageTo(w io.Writer) {
 		}
 	}
 
-	if c.flags != nil && len(c.flags.order) > 0 {
-		_, _ = fmt.Fprintln(w)
-		_, _ = fmt.Fprintln(w, "Flags:")
-		for _, flag := range c.flags.order {
-			short := ""
-			if flag.short != "" {
-				short = "-" + flag.short + ", "
-			}
-			_, _ = fmt.Fprintf(w, "  %s--%-16s %s\n", short, flag.name, flag.usage)
+	if c.flags != nil {
+		printFlagsTo(w, "Flags:", c.flags.order)
+	}
+
+	var global []*flag
+	for current := c; current != nil; current = current.parent {
+		if current.persistentFlags != nil {
+			global = append(global, current.persistentFlags.order...)
+		}
+	}
+	printFlagsTo(w, "Global Flags:", global)
+}
+
+func printFlagsTo(w io.Writer, heading string, flags []*flag) {
+	if len(flags) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, heading)
+	for _, flag := range flags {
+		short := ""
+		if flag.short != "" {
+			short = "-" + flag.short + ", "
 		}
+		_, _ = fmt.Fprintf(w, "  %s--%-16s %s\n", short, flag.name, flag.usage)
 	}
 }
 
@@ -175,6 +255,8 @@ type flagSet struct {
 	byName  map[string]*flag
 	byShort map[string]*flag
 	order   []*flag
+	// inherited is consulted for flags this set does not define.
+	inherited *flagSet
 }
 
 func newFlagSet() *flagSet {
@@ -257,14 +339,20 @@ func (s *flagSet) lookupLong(name string) *flag {
 	if s == nil {
 		return nil
 	}
-	return s.byName[name]
+	if flag, ok := s.byName[name]; ok {
+		return flag
+	}
+	return s.inherited.lookupLong(name)
 }
 
 func (s *flagSet) lookupShort(name string) *flag {
 	if s == nil {
 		return nil
 	}
-	return s.byShort[name]
+	if flag, ok := s.byShort[name]; ok {
+		return flag
+	}
+	return s.inherited.lookupShort(name)
 }
 
 func (s *flagSet) parse(args []string) ([]string, error) {
@@ -279,54 +367,12 @@ func (s *flagSet) parse(args []string) ([]string, error) {
 			remaining = append(remaining, arg)
 			continue
 		}
-		if strings.HasPrefix(arg, "--") && len(arg) > 2 {
-			name, value, hasValue := strings.Cut(arg[2:], "=")
-			flag := s.lookupLong(name)
-			if flag == nil {
-				return nil, fmt.Errorf("unknown flag: --%s", name)
-			}
-			if !hasValue && flag.kind != flagKindBool {
-				if i+1 >= len(args) {
-					return nil, fmt.Errorf("flag needs a value: --%s", name)
-				}
-				i++
-				value = args[i]
-			}
-			if err := flag.set(value, hasValue); err != nil {
-				return nil, fmt.Errorf("invalid value for --%s: %w", name, err)
-			}
-			continue
-		}
 		if strings.HasPrefix(arg, "-") && len(arg) > 1 {
-			short := arg[1:]
-			flag := s.lookupShort(short)
-			attachedValue := ""
-			attachedHasValue := false
-			if flag == nil && len(short) > 1 {
-				if attachedFlag := s.lookupShort(short[:1]); attachedFlag != nil && attachedFlag.kind != flagKindBool {
-					flag = attachedFlag
-					attachedValue = short[1:]
-					attachedHasValue = true
-				}
-			}
-			if flag == nil {
-				return nil, fmt.Errorf("unknown flag: -%s", short)
-			}
-			value := attachedValue
-			hasValue := attachedHasValue
-			if flag.kind != flagKindBool {
-				if !hasValue && i+1 >= len(args) {
-					return nil, fmt.Errorf("flag needs a value: -%s", short)
-				}
-				if !hasValue {
-					i++
-					value = args[i]
-					hasValue = true
-				}
-			}
-			if err := flag.set(value, hasValue); err != nil {
-				return nil, fmt.Errorf("invalid value for -%s: %w", short, err)
+			next, err := s.parseFlagAt(args, i)
+			if err != nil {
+				return nil, err
 			}
+			i = next
 			continue
 		}
 		remaining = append(remaining, arg)
@@ -334,6 +380,61 @@ func (s *flagSet) parse(args []string) ([]string, error) {
 	return remaining, nil
 }
 
+// parseFlagAt sets the flag at args[i], consuming the following argument as
+// its value when needed, and returns the index of the last argument used
+func (s *flagSet) parseFlagAt(args []string, i int) (int, error) {
+	arg := args[i]
+	if strings.HasPrefix(arg, "--") && len(arg) > 2 {
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		flag := s.lookupLong(name)
+		if flag == nil {
+			return i, fmt.Errorf("unknown flag: --%s", name)
+		}
+		if !hasValue && flag.kind != flagKindBool {
+			if i+1 >= len(args) {
+				return i, fmt.Errorf("flag needs a value: --%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		if err := flag.set(value, hasValue); err != nil {
+			return i, fmt.Errorf("invalid value for --%s: %w", name, err)
+		}
+		return i, nil
+	}
+
+	short := arg[1:]
+	flag := s.lookupShort(short)
+	attachedValue := ""
+	attachedHasValue := false
+	if flag == nil && len(short) > 1 {
+		if attachedFlag := s.lookupShort(short[:1]); attachedFlag != nil && attachedFlag.kind != flagKindBool {
+			flag = attachedFlag
+			attachedValue = short[1:]
+			attachedHasValue = true
+		}
+	}
+	if flag == nil {
+		return i, fmt.Errorf("unknown flag: -%s", short)
+	}
+	value := attachedValue
+	hasValue := attachedHasValue
+	if flag.kind != flagKindBool {
+		if !hasValue && i+1 >= len(args) {
+			return i, fmt.Errorf("flag needs a value: -%s", short)
+		}
+		if !hasValue {
+			i++
+			value = args[i]
+			hasValue = true
+		}
+	}
+	if err := flag.set(value, hasValue); err != nil {
+		return i, fmt.Errorf("invalid value for -%s: %w", short, err)
+	}
+	return i, nil
+}
+
 type flagKind string
 
 const (
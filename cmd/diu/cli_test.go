@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
 
 func TestFlagSetParsesLongAndShortFlags(t *testing.T) {
 	flags := newFlagSet()
@@ -88,6 +95,43 @@ func TestCommandDispatchesToSubcommand(t *testing.T) {
 	}
 }
 
+func TestPersistentFlagsReachSubcommands(t *testing.T) {
+	for _, args := range [][]string{{"--json", "query"}, {"query", "--json"}} {
+		var enabled, got bool
+		root := &command{Use: "diu"}
+		root.PersistentFlags().BoolVar(&enabled, "json", false, "json output")
+		child := &command{
+			Use: "query",
+			RunE: func(cmd *command, args []string) error {
+				got = flagBool(cmd, "json")
+				return nil
+			},
+		}
+		root.AddCommand(child)
+
+		if err := root.Execute(args); err != nil {
+			t.Fatalf("%v: execute failed: %v", args, err)
+		}
+		if !got {
+			t.Errorf("%v: expected subcommand to see --json", args)
+		}
+	}
+}
+
+func TestUsageListsGlobalFlags(t *testing.T) {
+	var enabled bool
+	root := &command{Use: "diu"}
+	root.PersistentFlags().BoolVar(&enabled, "json", false, "json output")
+	child := &command{Use: "stats", Short: "Show stats"}
+	root.AddCommand(child)
+
+	var buf bytes.Buffer
+	child.printUsageTo(&buf)
+	if !strings.Contains(buf.String(), "Global Flags:") || !strings.Contains(buf.String(), "--json") {
+		t.Errorf("Expected global flags in usage, got %q", buf.String())
+	}
+}
+
 func TestFlagSetVisitOnlyChangedFlags(t *testing.T) {
 	flags := newFlagSet()
 	var tool string
@@ -107,3 +151,110 @@ func TestFlagSetVisitOnlyChangedFlags(t *testing.T) {
 		t.Fatalf("visited = %#v, want [tool]", visited)
 	}
 }
+
+func TestTableAlignsAndTruncates(t *testing.T) {
+	output := newTable([]tableColumn{
+		{Header: "TOOL"},
+		{Header: "COUNT", AlignRight: true},
+		{Header: "COMMAND", MaxWidth: 10},
+	}, false)
+	output.AddRow("npm", "12", "npm install -g typescript")
+	output.AddRow("homebrew", "3", "brew up")
+
+	var buf bytes.Buffer
+	if err := output.Render(&buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := "TOOL      COUNT  COMMAND\n" +
+		"npm          12  npm insta…\n" +
+		"homebrew      3  brew up\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	wide := newTable([]tableColumn{{Header: "COMMAND", MaxWidth: 10}}, true)
+	wide.AddRow("npm install -g typescript")
+	buf.Reset()
+	if err := wide.Render(&buf); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "npm install -g typescript") {
+		t.Errorf("Expected wide table to keep full cell, got %q", buf.String())
+	}
+}
+
+func TestRenderMonitorHealth(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	output := captureStdout(t, func() {
+		err := renderMonitorHealth([]core.MonitorHealth{
+			{Name: "cargo", Status: core.HealthStatusError, Errors: 1, LastError: "plugin cargo not found"},
+			{Name: "npm", Status: core.HealthStatusDegraded, Initialized: true, WrapperPath: "/tmp/npm", LastEvent: now.Add(-2 * time.Hour)},
+		}, now)
+		if err != nil {
+			t.Errorf("renderMonitorHealth failed: %v", err)
+		}
+	})
+	for _, want := range []string{"LAST EVENT", "cargo    error     -        never", "plugin cargo not found", "npm      degraded  missing  2h ago"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in monitor table, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		10 * time.Second:     "just now",
+		5 * time.Minute:      "5m ago",
+		3 * time.Hour:        "3h ago",
+		2 * 24 * time.Hour:   "2d ago",
+		60 * 24 * time.Hour:  "2mo ago",
+		800 * 24 * time.Hour: "2y ago",
+	}
+	for elapsed, want := range tests {
+		if got := formatRelativeTime(now.Add(-elapsed), now); got != want {
+			t.Errorf("formatRelativeTime(-%s) = %q, want %q", elapsed, got, want)
+		}
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	var tool, format string
+	var verbose bool
+	root := &command{Use: "diu"}
+	root.PersistentFlags().BoolVar(&verbose, "json", false, "json")
+	query := &command{Use: "query"}
+	query.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	query.Flags().StringVar(&format, "format", "", "format")
+	query.RegisterFlagCompletionFunc("tool", func(cmd *command, args []string, toComplete string) []string {
+		return []string{"npm", "pip", "pnpm"}
+	})
+	stats := &command{
+		Use: "stats",
+		ValidArgsFunction: func(cmd *command, args []string, toComplete string) []string {
+			return []string{"daily", "weekly"}
+		},
+	}
+	root.AddCommand(query, stats, &command{Use: "record", Hidden: true})
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{""}, []string{"query", "stats"}},
+		{[]string{"q"}, []string{"query"}},
+		{[]string{"--json", "s"}, []string{"stats"}},
+		{[]string{"query", "--t"}, []string{"--tool"}},
+		{[]string{"query", "--j"}, []string{"--json"}},
+		{[]string{"query", "--tool", "p"}, []string{"pip", "pnpm"}},
+		{[]string{"query", "-t", ""}, []string{"npm", "pip", "pnpm"}},
+		{[]string{"query", "--format", ""}, nil},
+		{[]string{"stats", "w"}, []string{"weekly"}},
+	}
+	for _, tt := range tests {
+		got := completeWords(root, tt.words)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("completeWords(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
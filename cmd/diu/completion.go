@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// completeCommandName is the hidden entry point shell completion scripts
+// call with the words typed so far, the last one being completed
+const completeCommandName = "__complete"
+
+// completionFunc returns candidate values for a flag or positional argument.
+// Candidates are filtered by the typed prefix afterwards.
+type completionFunc func(cmd *command, args []string, toComplete string) []string
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for diu
+_diu_completions() {
+    local IFS=$'\n'
+    COMPREPLY=($(diu ` + completeCommandName + ` "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _diu_completions diu
+`,
+	"zsh": `#compdef diu
+# zsh completion for diu
+_diu() {
+    local -a completions
+    completions=("${(@f)$(diu ` + completeCommandName + ` "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a completions
+}
+compdef _diu diu
+`,
+	"fish": `# fish completion for diu
+complete -c diu -f -a '(diu ` + completeCommandName + ` (commandline -opc)[2..-1] (commandline -ct))'
+`,
+}
+
+// printCompletionScript writes the completion script for a shell
+func printCompletionScript(cmd *command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: diu completion <bash|zsh|fish>")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s (must be bash, zsh, or fish)", args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+func completeShells(cmd *command, args []string, toComplete string) []string {
+	shells := make([]string, 0, len(completionScripts))
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	return shells
+}
+
+// printCompletions writes one candidate per line for the last of words
+func printCompletions(w io.Writer, root *command, words []string) {
+	for _, candidate := range completeWords(root, words) {
+		_, _ = fmt.Fprintln(w, candidate)
+	}
+}
+
+// completeWords resolves the subcommand named by words, applies the flags
+// typed so far, and returns sorted candidates for the final word
+func completeWords(root *command, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	toComplete := words[len(words)-1]
+	typed := words[:len(words)-1]
+
+	cmd := root
+	cmd.inheritFlags()
+	var args []string
+	for i := 0; i < len(typed); i++ {
+		word := typed[i]
+		if isLeadingFlag(word) {
+			// Errors are ignored: a half-typed command line still completes.
+			if next, err := cmd.Flags().parseFlagAt(typed, i); err == nil {
+				i = next
+			}
+			continue
+		}
+		if child := cmd.findCommand(word); child != nil && len(args) == 0 {
+			cmd = child
+			cmd.inheritFlags()
+			continue
+		}
+		args = append(args, word)
+	}
+
+	var candidates []string
+	switch {
+	case len(typed) > 0 && flagWantsValue(cmd, typed[len(typed)-1]):
+		name := strings.TrimLeft(typed[len(typed)-1], "-")
+		flag := cmd.Flags().lookupLong(name)
+		if flag == nil {
+			flag = cmd.Flags().lookupShort(name)
+		}
+		if fn := cmd.flagCompletion(flag.name); fn != nil {
+			candidates = fn(cmd, args, toComplete)
+		}
+	case strings.HasPrefix(toComplete, "-"):
+		candidates = flagNames(cmd)
+	case len(args) == 0 && len(cmd.commands) > 0:
+		for _, child := range cmd.commands {
+			if !child.Hidden {
+				candidates = append(candidates, commandName(child.Use))
+			}
+		}
+	case cmd.ValidArgsFunction != nil:
+		candidates = cmd.ValidArgsFunction(cmd, args, toComplete)
+	}
+
+	var matches []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, toComplete) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// flagWantsValue reports whether word is a flag, without an attached value,
+// that takes the next word as its value
+func flagWantsValue(cmd *command, word string) bool {
+	if !isLeadingFlag(word) || strings.Contains(word, "=") {
+		return false
+	}
+	var flag *flag
+	if strings.HasPrefix(word, "--") {
+		flag = cmd.Flags().lookupLong(word[2:])
+	} else {
+		flag = cmd.Flags().lookupShort(word[1:])
+	}
+	return flag != nil && flag.kind != flagKindBool
+}
+
+// flagNames lists the long flags cmd accepts, including inherited ones
+func flagNames(cmd *command) []string {
+	var names []string
+	for set := cmd.Flags(); set != nil; set = set.inherited {
+		for _, flag := range set.order {
+			names = append(names, "--"+flag.name)
+		}
+	}
+	return names
+}
+
+// completeTools offers tools that have recorded executions or tracked
+// packages
+func completeTools(cmd *command, args []string, toComplete string) []string {
+	store, ok := openCompletionStore()
+	if !ok {
+		return nil
+	}
+	defer closeStore(store)
+
+	var tools []string
+	if stats, err := store.GetStatistics(); err == nil {
+		tools = append(tools, stats.ToolsUsed...)
+	}
+	if packages, err := store.GetAllPackages(); err == nil {
+		for tool := range packages {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// completePackages offers tracked package names, narrowed by --tool when it
+// has been typed
+func completePackages(cmd *command, args []string, toComplete string) []string {
+	store, ok := openCompletionStore()
+	if !ok {
+		return nil
+	}
+	defer closeStore(store)
+
+	packages, err := store.GetPackages(core.NormalizeToolName(flagString(cmd, "tool")))
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+// completeSnapshots offers saved snapshot names and "current"
+func completeSnapshots(cmd *command, args []string, toComplete string) []string {
+	config, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	snapshots, err := loadSnapshots(config)
+	if err != nil {
+		return nil
+	}
+	names := []string{snapshotCurrent}
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+	return names
+}
+
+// openCompletionStore opens storage for completion; failures yield no
+// candidates rather than an error in the user's shell
+func openCompletionStore() (storage.Storage, bool) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, false
+	}
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return nil, false
+	}
+	return store, true
+}
+
+// runCompletion handles the hidden completion entry point before normal
+// command dispatch so partially typed flags are not rejected
+func runCompletion(root *command, args []string) bool {
+	if len(args) == 0 || args[0] != completeCommandName {
+		return false
+	}
+	printCompletions(os.Stdout, root, args[1:])
+	return true
+}
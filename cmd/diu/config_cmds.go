@@ -1,128 +1,62 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/remote"
 )
 
-// getConfig gets a configuration value
+// getConfig prints the value of a dotted config key
 func getConfig(cmd *command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("config key required")
 	}
 
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	key := args[0]
-	switch key {
-	case "storage.json_file":
-		fmt.Println(config.Storage.JSONFile)
-	case "storage.retention_days":
-		fmt.Println(config.Storage.RetentionDays)
-	case "storage.max_executions":
-		fmt.Println(config.Storage.MaxExecutions)
-	case "storage.max_storage_bytes":
-		fmt.Println(config.Storage.MaxStorageBytes)
-	case "storage.max_backups":
-		fmt.Println(config.Storage.MaxBackups)
-	case "daemon.pid_file":
-		fmt.Println(config.Daemon.PIDFile)
-	case "daemon.socket_path":
-		fmt.Println(config.Daemon.SocketPath)
-	case "api.enabled":
-		fmt.Println(config.API.Enabled)
-	case "api.port":
-		fmt.Println(config.API.Port)
-	case "monitoring.enabled_tools":
-		fmt.Println(strings.Join(config.Monitoring.EnabledTools, ", "))
-	default:
-		return fmt.Errorf("unknown config key: %s", key)
+	value, err := config.GetValue(key)
+	if err != nil {
+		return err
 	}
-
+	text, err := core.FormatConfigValue(value)
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", key, err)
+	}
+	fmt.Println(text)
 	return nil
 }
 
-// setConfig sets a configuration value
+// setConfig parses and stores the value of a dotted config key, refusing
+// values that fail validation
 func setConfig(cmd *command, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("config key and value required")
 	}
 
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	key := args[0]
 	value := args[1]
-
-	switch key {
-	case "storage.json_file":
-		config.Storage.JSONFile = value
-	case "storage.retention_days":
-		days, err := strconv.Atoi(value)
-		if err != nil {
-			return fmt.Errorf("invalid retention_days value: %w", err)
-		}
-		if days < 0 {
-			return fmt.Errorf("retention_days must be non-negative")
-		}
-		config.Storage.RetentionDays = days
-	case "storage.max_executions":
-		maxExecutions, err := strconv.Atoi(value)
-		if err != nil {
-			return fmt.Errorf("invalid max_executions value: %w", err)
-		}
-		if maxExecutions < 0 {
-			return fmt.Errorf("max_executions must be non-negative")
-		}
-		config.Storage.MaxExecutions = maxExecutions
-	case "storage.max_storage_bytes":
-		maxStorageBytes, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return fmt.Errorf("invalid max_storage_bytes value: %w", err)
-		}
-		if maxStorageBytes < 0 {
-			return fmt.Errorf("max_storage_bytes must be non-negative")
-		}
-		config.Storage.MaxStorageBytes = maxStorageBytes
-	case "storage.max_backups":
-		maxBackups, err := strconv.Atoi(value)
-		if err != nil {
-			return fmt.Errorf("invalid max_backups value: %w", err)
+	if key == "storage.backup_remote" && value != "" {
+		if _, _, err := remote.Open(value, remote.Options{}); err != nil {
+			return err
 		}
-		if maxBackups < 0 {
-			return fmt.Errorf("max_backups must be non-negative")
-		}
-		config.Storage.MaxBackups = maxBackups
-	case "daemon.pid_file":
-		config.Daemon.PIDFile = value
-	case "daemon.socket_path":
-		config.Daemon.SocketPath = value
-	case "api.enabled":
-		enabled, err := strconv.ParseBool(value)
-		if err != nil {
-			return fmt.Errorf("invalid boolean value: %w", err)
-		}
-		config.API.Enabled = enabled
-	case "api.port":
-		port, err := strconv.Atoi(value)
-		if err != nil {
-			return fmt.Errorf("invalid port value: %w", err)
-		}
-		config.API.Port = port
-	case "monitoring.enabled_tools":
-		config.Monitoring.EnabledTools = strings.Split(value, ",")
-	default:
-		return fmt.Errorf("unknown config key: %s", key)
+	}
+
+	if err := config.SetValue(key, value); err != nil {
+		return err
+	}
+	if err := keyValidationError(config.Validate(), key); err != nil {
+		return err
 	}
 
 	if err := config.Save(); err != nil {
@@ -133,14 +67,73 @@ func setConfig(cmd *command, args []string) error {
 	return nil
 }
 
+// keyValidationError reports the validation errors for key and the settings
+// nested under it, ignoring problems elsewhere in the config
+func keyValidationError(issues []core.ConfigIssue, key string) error {
+	var matched []core.ConfigIssue
+	for _, issue := range issues {
+		if issue.Key == key || strings.HasPrefix(issue.Key, key+".") || strings.HasPrefix(issue.Key, key+"[") {
+			matched = append(matched, issue)
+		}
+	}
+	return core.ValidationError(matched)
+}
+
 // listConfig lists all configuration
 func listConfig(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(config)
+	return printJSON(config)
+}
+
+// configValidationReport is the --json output of config validate
+type configValidationReport struct {
+	Path   string             `json:"path"`
+	Valid  bool               `json:"valid"`
+	Issues []core.ConfigIssue `json:"issues"`
+}
+
+// validateConfig checks the config and lists every problem, failing when any
+// of them is an error rather than a warning
+func validateConfig(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	issues := config.Validate()
+	validationErr := core.ValidationError(issues)
+	if jsonOutput(cmd) {
+		if issues == nil {
+			issues = []core.ConfigIssue{}
+		}
+		if err := printJSON(configValidationReport{Path: config.Path(), Valid: validationErr == nil, Issues: issues}); err != nil {
+			return err
+		}
+		return validationErr
+	}
+
+	printConfigWarnings(issues)
+	for _, issue := range issues {
+		if !issue.Warning {
+			fmt.Println(errorStyle.Render("error: " + issue.String()))
+		}
+	}
+	if validationErr != nil {
+		return fmt.Errorf("%s is invalid", config.Path())
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("%s is valid", config.Path())))
+	return nil
+}
+
+// printConfigWarnings prints the warnings among issues
+func printConfigWarnings(issues []core.ConfigIssue) {
+	for _, issue := range issues {
+		if issue.Warning {
+			fmt.Println(infoStyle.Render("warning: " + issue.String()))
+		}
+	}
 }
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
 	"github.com/yowainwright/diu/internal/daemon"
+	"github.com/yowainwright/diu/internal/safefs"
 )
 
 // DaemonChecker is an interface for checking daemon status
@@ -20,19 +24,25 @@ type DaemonChecker interface {
 // RealDaemonChecker uses the actual daemon package
 type RealDaemonChecker struct{}
 
+// IsRunning prefers the service manager's view when the daemon is installed
+// as a systemd unit or Windows service, and falls back to the PID file
+// otherwise.
 func (RealDaemonChecker) IsRunning(config *core.Config) bool {
+	if manager := installedServiceManager(); manager != nil && manager.Name() != serviceManagerLaunchd {
+		return manager.Running()
+	}
 	return daemon.IsRunning(config)
 }
 
 // defaultDaemonChecker is used by default
 var defaultDaemonChecker DaemonChecker = RealDaemonChecker{}
 
-var daemonProcessStarter = func(execPath string, args []string, procAttr *syscall.ProcAttr) error {
-	// #nosec G204 -- execPath is the current executable path and is validated before forking.
-	if _, err := syscall.ForkExec(execPath, args, procAttr); err != nil {
+// daemonProcessStarter launches the detached daemon child and releases it
+var daemonProcessStarter = func(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
 		return err
 	}
-	return nil
+	return cmd.Process.Release()
 }
 
 // SetDaemonChecker sets a custom checker (for testing)
@@ -46,26 +56,74 @@ func SetDaemonChecker(checker DaemonChecker) func() {
 
 // startDaemon starts the DIU daemon
 func startDaemon(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	if flagBool(cmd, "foreground") && flagBool(cmd, "detach") {
+		return fmt.Errorf("--foreground and --detach cannot be used together")
+	}
+
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	issues := config.Validate()
+	if err := core.ValidationError(issues); err != nil {
+		return err
+	}
+	printConfigWarnings(issues)
+	if flagBool(cmd, "foreground") {
+		return startDaemonForeground(config)
+	}
 	return startDaemonWithConfig(config)
 }
 
-// startDaemonWithConfig starts the DIU daemon with the given config
+// startDaemonWithConfig starts the DIU daemon in the background with the given config
 func startDaemonWithConfig(config *core.Config) error {
 	if defaultDaemonChecker.IsRunning(config) {
-		fmt.Println(infoStyle.Render("DIU daemon is already running"))
+		printDaemonAlreadyRunning(config)
 		return nil
 	}
 
-	if os.Getenv("DIU_DAEMON_FOREGROUND") == "" {
-		return forkDaemonBackground(config)
+	if manager := installedServiceManager(); manager != nil {
+		return startDaemonService(manager, config)
+	}
+	return forkDaemonBackground(config)
+}
+
+// startDaemonForeground runs the daemon in the current process. Only the PID
+// file is consulted: a service manager launching this process already
+// reports the service as active.
+func startDaemonForeground(config *core.Config) error {
+	if daemon.IsRunning(config) {
+		printDaemonAlreadyRunning(config)
+		return nil
 	}
 	return runDaemonForeground(config)
 }
 
+// printDaemonAlreadyRunning reports a running daemon, naming the PID that
+// holds the PID lock when it is known
+func printDaemonAlreadyRunning(config *core.Config) {
+	if pid, held := daemon.LockHolder(config); held && pid > 0 {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("DIU daemon is already running (PID %d)", pid)))
+		return
+	}
+	fmt.Println(infoStyle.Render("DIU daemon is already running"))
+}
+
+// startDaemonService starts the daemon through its installed service manager
+func startDaemonService(manager serviceManager, config *core.Config) error {
+	fmt.Println(successStyle.Render(fmt.Sprintf("Starting DIU daemon via %s...", manager.Name())))
+
+	if err := manager.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon service: %w", err)
+	}
+	if err := waitForDaemonStarted(config, daemonStartTimeout); err != nil {
+		return err
+	}
+
+	fmt.Println(successStyle.Render("DIU daemon started"))
+	return nil
+}
+
 func forkDaemonBackground(config *core.Config) error {
 	fmt.Println(successStyle.Render("Starting DIU daemon..."))
 
@@ -78,23 +136,26 @@ func forkDaemonBackground(config *core.Config) error {
 		return fmt.Errorf("invalid daemon executable path: %w", err)
 	}
 
-	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	stdin, err := os.Open(os.DevNull)
 	if err != nil {
 		return fmt.Errorf("failed to open %s: %w", os.DevNull, err)
 	}
-	defer func() {
-		if err := devNull.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to close %s: %v\n", os.DevNull, err)
-		}
-	}()
+	defer closeDaemonStdio(stdin)
 
-	procAttr := &syscall.ProcAttr{
-		Env:   append(os.Environ(), "DIU_DAEMON_FOREGROUND=1"),
-		Files: []uintptr{devNull.Fd(), devNull.Fd(), devNull.Fd()},
-		Sys:   &syscall.SysProcAttr{Setsid: true},
+	output, err := openDaemonOutput(config)
+	if err != nil {
+		return err
 	}
+	defer closeDaemonStdio(output)
+
+	// #nosec G204 -- execPath is the current executable path and is validated before starting.
+	child := exec.Command(execPath, daemonStartArgs(config)...)
+	child.Stdin = stdin
+	child.Stdout = output
+	child.Stderr = output
+	child.SysProcAttr = detachedSysProcAttr()
 
-	if err := daemonProcessStarter(execPath, []string{execPath, "daemon", "start"}, procAttr); err != nil {
+	if err := daemonProcessStarter(child); err != nil {
 		return fmt.Errorf("failed to fork daemon: %w", err)
 	}
 
@@ -106,11 +167,43 @@ func forkDaemonBackground(config *core.Config) error {
 	return nil
 }
 
+// openDaemonOutput opens the file the detached daemon's stdout and stderr go
+// to, so panics and early startup errors end up beside the daemon log
+// rather than in the launching terminal.
+func openDaemonOutput(config *core.Config) (*os.File, error) {
+	path := config.Daemon.StdoutLogFile()
+	if path == "" {
+		file, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", os.DevNull, err)
+		}
+		return file, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := safefs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, core.PrivateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon output file: %w", err)
+	}
+	return file, nil
+}
+
+func closeDaemonStdio(file *os.File) {
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close %s: %v\n", file.Name(), err)
+	}
+}
+
 func runDaemonForeground(config *core.Config) error {
 	d, err := daemon.NewDaemon(config)
 	if err != nil {
 		return fmt.Errorf("failed to create daemon: %w", err)
 	}
+	if runningAsWindowsService() {
+		return runWindowsService(d)
+	}
 	if err := d.Start(); err != nil {
 		return err
 	}
@@ -120,7 +213,7 @@ func runDaemonForeground(config *core.Config) error {
 
 // stopDaemon stops the DIU daemon
 func stopDaemon(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -146,23 +239,29 @@ func stopDaemonWithConfig(config *core.Config) error {
 		return nil
 	}
 
-	pidBytes, err := os.ReadFile(config.Daemon.PIDFile)
-	if err != nil {
-		return fmt.Errorf("failed to read PID file: %w", err)
-	}
+	if manager := installedServiceManager(); manager != nil {
+		if err := manager.Stop(); err != nil {
+			return fmt.Errorf("failed to stop daemon service: %w", err)
+		}
+	} else {
+		pidBytes, err := os.ReadFile(config.Daemon.PIDFile)
+		if err != nil {
+			return fmt.Errorf("failed to read PID file: %w", err)
+		}
 
-	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
-	if err != nil {
-		return fmt.Errorf("invalid PID: %w", err)
-	}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+		if err != nil {
+			return fmt.Errorf("invalid PID: %w", err)
+		}
 
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return fmt.Errorf("process not found: %w", err)
-	}
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return fmt.Errorf("process not found: %w", err)
+		}
 
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		return fmt.Errorf("failed to stop daemon: %w", err)
+		if err := terminateDaemonProcess(process); err != nil {
+			return fmt.Errorf("failed to stop daemon: %w", err)
+		}
 	}
 
 	if err := waitForDaemonStopped(config, daemonStopTimeout); err != nil {
@@ -199,7 +298,7 @@ func waitForDaemonStopped(config *core.Config, timeout time.Duration) error {
 
 // restartDaemon restarts the DIU daemon
 func restartDaemon(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -210,14 +309,217 @@ func restartDaemon(cmd *command, args []string) error {
 	return startDaemonWithConfig(config)
 }
 
+// reloadDaemon asks a running daemon to reload its configuration
+func reloadDaemon(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !defaultDaemonChecker.IsRunning(config) {
+		fmt.Println(infoStyle.Render("DIU daemon is not running"))
+		return nil
+	}
+
+	pidBytes, err := os.ReadFile(config.Daemon.PIDFile)
+	if err != nil {
+		return fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	if err != nil {
+		return fmt.Errorf("invalid PID: %w", err)
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("process not found: %w", err)
+	}
+
+	if err := signalDaemonReload(process); err != nil {
+		return fmt.Errorf("failed to reload daemon: %w", err)
+	}
+
+	fmt.Println(successStyle.Render("DIU daemon reload requested"))
+	return nil
+}
+
+// daemonLogs prints the tail of the daemon log file, optionally following it
+func daemonLogs(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if config.Daemon.LogFile == "" {
+		return fmt.Errorf("daemon.log_file is not configured; the daemon logs to stderr")
+	}
+
+	lines := flagInt(cmd, "lines")
+	if lines < 0 {
+		return fmt.Errorf("lines must be non-negative")
+	}
+
+	offset, err := printLogTail(os.Stdout, config.Daemon.LogFile, lines)
+	if err != nil {
+		return err
+	}
+	if !flagBool(cmd, "follow") {
+		return nil
+	}
+	return followLogFile(os.Stdout, config.Daemon.LogFile, offset, daemonLogPollInterval, nil)
+}
+
+// daemonLogPollInterval is the interval between log file checks while following.
+const daemonLogPollInterval = 500 * time.Millisecond
+
+// printLogTail writes the last n lines of the log file and returns the offset read up to.
+func printLogTail(w io.Writer, path string, n int) (int64, error) {
+	data, err := safefs.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	if _, err := io.WriteString(w, strings.Join(lines, "")); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// followLogFile streams data appended to the log file. When the file shrinks
+// or is replaced by rotation, it starts again from the beginning of the new file.
+func followLogFile(w io.Writer, path string, offset int64, interval time.Duration, stop <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if info.Size() < offset {
+				offset = 0
+			}
+			if info.Size() > offset {
+				read, err := copyLogRange(w, path, offset)
+				if err != nil {
+					return err
+				}
+				offset += read
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stat log file: %w", err)
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func copyLogRange(w io.Writer, path string, offset int64) (read int64, err error) {
+	file, err := safefs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close log file: %w", closeErr)
+		}
+	}()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek log file: %w", err)
+	}
+	return io.Copy(w, file)
+}
+
+// daemonStatusReport is the --json form of diu daemon status
+type daemonStatusReport struct {
+	Running  bool                 `json:"running"`
+	PID      int                  `json:"pid,omitempty"`
+	Service  *serviceStatusEntry  `json:"service,omitempty"`
+	Monitors []core.MonitorHealth `json:"monitors,omitempty"`
+	Pause    *core.PauseState     `json:"pause,omitempty"`
+}
+
+type serviceStatusEntry struct {
+	Manager string `json:"manager"`
+	Loaded  bool   `json:"loaded"`
+}
+
+// daemonMonitorHealth asks the running daemon how its monitors are doing,
+// returning nil when its API cannot be reached
+func daemonMonitorHealth(config *core.Config) []core.MonitorHealth {
+	api := monitorsDaemon(config)
+	if api == nil {
+		return nil
+	}
+	health, err := api.Health(context.Background())
+	if err != nil {
+		return nil
+	}
+	return health.Monitors
+}
+
+// renderMonitorHealth prints a table of the daemon's monitors
+func renderMonitorHealth(health []core.MonitorHealth, now time.Time) error {
+	output := newTable([]tableColumn{
+		{Header: "MONITOR"},
+		{Header: "STATUS"},
+		{Header: "WRAPPER"},
+		{Header: "LAST EVENT"},
+		{Header: "ERRORS", AlignRight: true},
+		{Header: "LAST ERROR", MaxWidth: 48},
+	}, false)
+	for _, monitor := range health {
+		wrapper := "-"
+		if monitor.WrapperPath != "" {
+			wrapper = "missing"
+			if monitor.WrapperInstalled {
+				wrapper = "installed"
+			}
+		}
+		output.AddRow(monitor.Name, monitor.Status, wrapper, formatRelativeTime(monitor.LastEvent, now),
+			strconv.FormatInt(monitor.Errors, 10), monitor.LastError)
+	}
+	return output.Render(os.Stdout)
+}
+
 // daemonStatus checks and displays daemon status
 func daemonStatus(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if defaultDaemonChecker.IsRunning(config) {
+	if jsonOutput(cmd) {
+		report := daemonStatusReport{Running: defaultDaemonChecker.IsRunning(config)}
+		if report.Running {
+			pidBytes, _ := os.ReadFile(config.Daemon.PIDFile)
+			report.PID, _ = strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+			report.Monitors = daemonMonitorHealth(config)
+		}
+		if manager := installedServiceManager(); manager != nil {
+			report.Service = &serviceStatusEntry{Manager: manager.Name(), Loaded: manager.Running()}
+		}
+		if pause, _ := core.LoadPauseState(config.Daemon.DataDir, time.Now()); pause.Paused {
+			report.Pause = &pause
+		}
+		return printJSON(report)
+	}
+
+	running := defaultDaemonChecker.IsRunning(config)
+	if running {
 		fmt.Println(successStyle.Render("DIU daemon is running"))
 
 		pidBytes, _ := os.ReadFile(config.Daemon.PIDFile)
@@ -227,5 +529,22 @@ func daemonStatus(cmd *command, args []string) error {
 		fmt.Println(errorStyle.Render("DIU daemon is not running"))
 	}
 
+	if manager := installedServiceManager(); manager != nil {
+		state := "not loaded"
+		if manager.Running() {
+			state = "loaded"
+		}
+		fmt.Println(subtitleStyle.Render("  Service:"), manager.Name(), "("+state+")")
+	}
+	if pause, _ := core.LoadPauseState(config.Daemon.DataDir, time.Now()); pause.Paused {
+		fmt.Println(subtitleStyle.Render("  Recording:"), "paused "+pausedUntil(pause))
+	}
+
+	if running {
+		if health := daemonMonitorHealth(config); len(health) > 0 {
+			fmt.Println()
+			return renderMonitorHealth(health, time.Now())
+		}
+	}
 	return nil
 }
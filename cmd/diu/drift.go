@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// removalActions are the actions that remove the packages they affect, so
+// a package whose latest execution is one of them was meant to be gone
+var removalActions = []string{"uninstall", "remove", "pip_uninstall", "tool_uninstall", "self_remove"}
+
+// driftPackage is one package of a diu sync-check bucket
+type driftPackage struct {
+	Tool          string    `json:"tool"`
+	Name          string    `json:"name"`
+	Version       string    `json:"version,omitempty"`
+	UsageCount    int       `json:"usage_count"`
+	EphemeralRuns int       `json:"ephemeral_runs,omitempty"`
+	LastUsed      time.Time `json:"last_used"`
+}
+
+// driftReport is what diu sync-check found: installed packages that were
+// used and never used, and used packages that are not installed
+type driftReport struct {
+	UsedWithin       string         `json:"used_within,omitempty"`
+	Scanned          []string       `json:"scanned"`
+	InstalledUsed    []driftPackage `json:"installed_used"`
+	InstalledUnused  []driftPackage `json:"installed_unused"`
+	UsedNotInstalled []driftPackage `json:"used_not_installed"`
+}
+
+// driftTool is the tracked tool whose scan lists the packages of tool.
+// brew install --cask is recorded under homebrew while the scan lists the
+// cask under homebrew-cask, so casks belong to homebrew.
+func driftTool(tool string) string {
+	if tool == homebrewCaskTool {
+		return core.ToolHomebrew
+	}
+	return tool
+}
+
+// driftKey keys a package for matching installed packages against usage
+func driftKey(tool, name string) string {
+	return driftTool(tool) + "/" + name
+}
+
+// checkDrift compares what each tracked tool has installed with what diu
+// recorded being used
+func checkDrift(cmd *command, args []string) error {
+	var cutoff time.Time
+	usedWithin := flagString(cmd, "used-within")
+	if usedWithin != "" {
+		duration, err := parseDuration(usedWithin)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid --used-within duration %q", usedWithin)
+		}
+		cutoff = time.Now().Add(-duration)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	tools := config.TrackedTools()
+	if tool := core.NormalizeToolName(flagString(cmd, "tool")); tool != "" {
+		tools = []string{tool}
+	}
+	scanConfig := *config
+	scanConfig.Monitoring.Process.AutoInstallWrappers = false
+
+	var installed []*core.PackageInfo
+	var scanned []string
+	for _, tool := range tools {
+		monitor, err := newMonitor(config, tool)
+		if err != nil {
+			continue
+		}
+		if err := monitor.Initialize(&scanConfig); err != nil {
+			continue
+		}
+		packages, err := monitor.GetInstalledPackages()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to scan %s packages: %v\n", tool, err)
+			continue
+		}
+		scanned = append(scanned, tool)
+		installed = append(installed, packages...)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	stored, err := store.GetPackages("")
+	if err != nil {
+		return fmt.Errorf("failed to get packages: %w", err)
+	}
+	opts := storage.QueryOptions{}
+	if !cutoff.IsZero() {
+		opts.Since = &cutoff
+	}
+	executions, err := store.GetExecutions(opts)
+	if err != nil {
+		return fmt.Errorf("failed to get executions: %w", err)
+	}
+
+	report := compareInstalled(installed, scanned, stored, executions, cutoff)
+	report.UsedWithin = usedWithin
+	if jsonOutput(cmd) {
+		return printJSON(report)
+	}
+	return printDriftReport(report)
+}
+
+// compareInstalled sorts the installed packages of the scanned tools into
+// used and never used since cutoff, by the usage stored for them, and
+// finds the packages executions used that are not installed. An execution
+// changing a project's dependencies rather than global packages does not
+// count, and neither does a package whose latest execution removed it.
+func compareInstalled(installed []*core.PackageInfo, scanned []string, stored []*core.PackageInfo, executions []*core.ExecutionRecord, cutoff time.Time) driftReport {
+	report := driftReport{
+		Scanned:          scanned,
+		InstalledUsed:    []driftPackage{},
+		InstalledUnused:  []driftPackage{},
+		UsedNotInstalled: []driftPackage{},
+	}
+
+	usage := make(map[string]*driftPackage)
+	for _, pkg := range stored {
+		if pkg.UsageCount == 0 || packageUnusedSince(pkg, cutoff) {
+			continue
+		}
+		key := driftKey(pkg.Tool, pkg.Name)
+		entry := usage[key]
+		if entry == nil {
+			entry = &driftPackage{}
+			usage[key] = entry
+		}
+		entry.UsageCount += pkg.UsageCount
+		if pkg.LastUsed.After(entry.LastUsed) {
+			entry.LastUsed = pkg.LastUsed
+		}
+	}
+
+	isInstalled := make(map[string]bool)
+	for _, pkg := range installed {
+		key := driftKey(pkg.Tool, pkg.Name)
+		if isInstalled[key] {
+			continue
+		}
+		isInstalled[key] = true
+		entry := driftPackage{Tool: pkg.Tool, Name: pkg.Name, Version: pkg.Version}
+		if used := usage[key]; used != nil {
+			entry.UsageCount, entry.LastUsed = used.UsageCount, used.LastUsed
+			report.InstalledUsed = append(report.InstalledUsed, entry)
+		} else {
+			report.InstalledUnused = append(report.InstalledUnused, entry)
+		}
+	}
+
+	ordered := slices.Clone(executions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+	missing := make(map[string]*driftPackage)
+	removed := make(map[string]bool)
+	for _, exec := range ordered {
+		if !slices.Contains(scanned, driftTool(exec.Tool)) || exec.Timestamp.Before(cutoff) {
+			continue
+		}
+		action, _ := exec.Metadata["action"].(string)
+		ephemeral := exec.Metadata["ephemeral"] == true
+		if global, ok := exec.Metadata["global"].(bool); ok && !global && !ephemeral {
+			continue
+		}
+		for _, name := range exec.PackagesAffected {
+			key := driftKey(exec.Tool, name)
+			if name == "" || isInstalled[key] {
+				continue
+			}
+			if slices.Contains(removalActions, action) {
+				removed[key] = true
+				continue
+			}
+			removed[key] = false
+			entry := missing[key]
+			if entry == nil {
+				entry = &driftPackage{Tool: exec.Tool, Name: name}
+				missing[key] = entry
+			}
+			entry.UsageCount++
+			if ephemeral {
+				entry.EphemeralRuns++
+			}
+			entry.LastUsed = exec.Timestamp
+		}
+	}
+	for key, entry := range missing {
+		if !removed[key] {
+			report.UsedNotInstalled = append(report.UsedNotInstalled, *entry)
+		}
+	}
+
+	sortDriftPackages(report.InstalledUsed)
+	sortDriftPackages(report.InstalledUnused)
+	sortDriftPackages(report.UsedNotInstalled)
+	return report
+}
+
+// sortDriftPackages orders packages by how often, then how recently, they
+// were used
+func sortDriftPackages(packages []driftPackage) {
+	sort.Slice(packages, func(i, j int) bool {
+		a, b := packages[i], packages[j]
+		if a.UsageCount != b.UsageCount {
+			return a.UsageCount > b.UsageCount
+		}
+		if !a.LastUsed.Equal(b.LastUsed) {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Name < b.Name
+	})
+}
+
+func printDriftReport(report driftReport) error {
+	fmt.Println(titleStyle.Render("Installed vs Used"))
+	if len(report.Scanned) == 0 {
+		fmt.Println()
+		fmt.Println(infoStyle.Render("No tracked tool could list its installed packages"))
+		return nil
+	}
+	if report.UsedWithin != "" {
+		fmt.Println(subtitleStyle.Render("Usage within " + report.UsedWithin))
+	}
+
+	for _, bucket := range []struct {
+		title    string
+		empty    string
+		packages []driftPackage
+	}{
+		{"Installed and used", "No installed package has been used", report.InstalledUsed},
+		{"Installed, never used", "Every installed package has been used", report.InstalledUnused},
+		{"Used, not installed", "Everything used is installed", report.UsedNotInstalled},
+	} {
+		fmt.Println()
+		fmt.Println(infoStyle.Render(fmt.Sprintf("%s (%d)", bucket.title, len(bucket.packages))))
+		if len(bucket.packages) == 0 {
+			fmt.Println(subtitleStyle.Render("  " + bucket.empty))
+			continue
+		}
+		output := newTable([]tableColumn{
+			{Header: "TOOL", MaxWidth: packageToolColumnWidth, Color: getToolColor},
+			{Header: "PACKAGE", MaxWidth: packageNameColumnWidth},
+			{Header: "VERSION", MaxWidth: 16},
+			{Header: "USED", AlignRight: true},
+			{Header: "EPHEMERAL", AlignRight: true},
+			{Header: "LAST USED"},
+		}, false)
+		for _, pkg := range bucket.packages {
+			output.AddRow(pkg.Tool, pkg.Name, pkg.Version, strconv.Itoa(pkg.UsageCount), strconv.Itoa(pkg.EphemeralRuns), formatLastUsed(pkg.LastUsed))
+		}
+		if err := output.Render(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
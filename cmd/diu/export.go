@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/anonymize"
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	formatJSONL  = "jsonl"
+	formatSQLite = "sqlite"
+
+	exportDataAll        = "all"
+	exportDataExecutions = "executions"
+	exportDataPackages   = "packages"
+
+	sqliteCommandName = "sqlite3"
+	sqlTypeText       = "TEXT"
+	sqlTypeInteger    = "INTEGER"
+)
+
+// exportData holds the records selected for export
+type exportData struct {
+	Executions []*core.ExecutionRecord `json:"executions,omitempty"`
+	Packages   []*core.PackageInfo     `json:"packages,omitempty"`
+}
+
+// exportColumn describes one exported field
+type exportColumn struct {
+	Name    string
+	SQLType string
+}
+
+var executionExportColumns = []exportColumn{
+	{"id", sqlTypeText},
+	{"tool", sqlTypeText},
+	{"command", sqlTypeText},
+	{"args", sqlTypeText},
+	{"timestamp", sqlTypeText},
+	{"duration_ms", sqlTypeInteger},
+	{"exit_code", sqlTypeInteger},
+	{"working_dir", sqlTypeText},
+	{"user", sqlTypeText},
+	{"environment", sqlTypeText},
+	{"packages_affected", sqlTypeText},
+	{"metadata", sqlTypeText},
+	{"project", sqlTypeText},
+	{"session_id", sqlTypeText},
+	{"host", sqlTypeText},
+	{"machine_id", sqlTypeText},
+}
+
+var packageExportColumns = []exportColumn{
+	{"tool", sqlTypeText},
+	{"name", sqlTypeText},
+	{"version", sqlTypeText},
+	{"install_date", sqlTypeText},
+	{"last_used", sqlTypeText},
+	{"usage_count", sqlTypeInteger},
+	{"path", sqlTypeText},
+	{"dependencies", sqlTypeText},
+}
+
+// exportHistory writes executions and packages in a machine-readable format
+func exportHistory(cmd *command, args []string) error {
+	format := flagString(cmd, "format")
+	dataset := flagString(cmd, "data")
+	out := flagString(cmd, "out")
+
+	switch format {
+	case formatCSV, formatJSON, formatJSONL, formatSQLite:
+	default:
+		return fmt.Errorf("unsupported export format: %s (use csv, json, jsonl, or sqlite)", format)
+	}
+	switch dataset {
+	case exportDataAll, exportDataExecutions, exportDataPackages:
+	default:
+		return fmt.Errorf("unsupported export data: %s (use all, executions, or packages)", dataset)
+	}
+	if flagString(cmd, "salt") != "" && !flagBool(cmd, "anonymize") {
+		return fmt.Errorf("--salt requires --anonymize")
+	}
+	if out == "" && format == formatSQLite {
+		return fmt.Errorf("--out is required for sqlite exports")
+	}
+	if out == "" && format == formatCSV && dataset == exportDataAll {
+		return fmt.Errorf("csv exports to stdout need --data executions or --data packages")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	data, err := loadExportData(cmd, store, dataset)
+	if err != nil {
+		return err
+	}
+	if flagBool(cmd, "anonymize") {
+		if err := anonymizeExportData(data, flagString(cmd, "salt")); err != nil {
+			return err
+		}
+	}
+
+	if out == "" {
+		return writeExport(os.Stdout, format, dataset, data)
+	}
+	if err := writeExportFiles(out, format, dataset, data); err != nil {
+		return err
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Exported %d executions and %d packages to %s",
+		len(data.Executions), len(data.Packages), out)))
+	return nil
+}
+
+// loadExportData applies the query filters and loads the selected records.
+// --last and --limit only apply to executions.
+func loadExportData(cmd *command, store storage.Storage, dataset string) (*exportData, error) {
+	tool := core.NormalizeToolName(flagString(cmd, "tool"))
+	pkgName := flagString(cmd, "package")
+	data := &exportData{}
+
+	if dataset != exportDataPackages {
+		opts := storage.QueryOptions{
+			Tool:    tool,
+			Package: pkgName,
+			Limit:   flagInt(cmd, "limit"),
+		}
+		if last := flagString(cmd, "last"); last != "" {
+			duration, err := parseDuration(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration: %w", err)
+			}
+			since := time.Now().Add(-duration)
+			opts.Since = &since
+		}
+
+		executions, err := store.GetExecutions(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query executions: %w", err)
+		}
+		data.Executions = executions
+	}
+
+	if dataset != exportDataExecutions {
+		packages, err := store.GetPackages(tool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get packages: %w", err)
+		}
+		for _, pkg := range packages {
+			if pkgName == "" || pkg.Name == pkgName {
+				data.Packages = append(data.Packages, pkg)
+			}
+		}
+		sort.Slice(data.Packages, func(i, j int) bool {
+			if data.Packages[i].Tool == data.Packages[j].Tool {
+				return data.Packages[i].Name < data.Packages[j].Name
+			}
+			return data.Packages[i].Tool < data.Packages[j].Tool
+		})
+	}
+
+	return data, nil
+}
+
+// anonymizeExportData replaces user names, hosts, and directories in data
+// with pseudonyms
+func anonymizeExportData(data *exportData, salt string) error {
+	anonymizer, err := anonymize.New(salt)
+	if err != nil {
+		return err
+	}
+	for _, record := range data.Executions {
+		anonymizer.Record(record)
+	}
+	for _, pkg := range data.Packages {
+		anonymizer.Package(pkg)
+	}
+	return nil
+}
+
+// writeExport writes data to w in a streaming format
+func writeExport(w io.Writer, format, dataset string, data *exportData) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case formatJSONL:
+		return writeExportJSONL(w, data)
+	case formatCSV:
+		if dataset == exportDataPackages {
+			return writeExportCSV(w, packageExportColumns, packageExportRows(data.Packages))
+		}
+		return writeExportCSV(w, executionExportColumns, executionExportRows(data.Executions))
+	}
+	return fmt.Errorf("unsupported export format: %s", format)
+}
+
+// writeExportFiles writes data to out. CSV exports of both datasets write
+// packages next to out with a -packages suffix.
+func writeExportFiles(out, format, dataset string, data *exportData) error {
+	if format == formatSQLite {
+		return writeExportSQLite(out, data)
+	}
+
+	if format == formatCSV && dataset == exportDataAll {
+		if err := writeExportFile(out, func(w io.Writer) error {
+			return writeExportCSV(w, executionExportColumns, executionExportRows(data.Executions))
+		}); err != nil {
+			return err
+		}
+		packagesOut := packagesExportPath(out)
+		if err := writeExportFile(packagesOut, func(w io.Writer) error {
+			return writeExportCSV(w, packageExportColumns, packageExportRows(data.Packages))
+		}); err != nil {
+			return err
+		}
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Packages written to %s", packagesOut)))
+		return nil
+	}
+
+	return writeExportFile(out, func(w io.Writer) error {
+		return writeExport(w, format, dataset, data)
+	})
+}
+
+// writeExportFile creates path and passes it to write
+func writeExportFile(path string, write func(io.Writer) error) (err error) {
+	file, err := safefs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, core.PrivateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close export file: %w", closeErr)
+		}
+	}()
+
+	if err := write(file); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
+
+// packagesExportPath returns the companion CSV path for packages
+func packagesExportPath(out string) string {
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "-packages" + ext
+}
+
+// exportLine is one JSONL record, tagged with its kind
+type exportLine struct {
+	Type   string      `json:"type"`
+	Record interface{} `json:"record"`
+}
+
+func writeExportJSONL(w io.Writer, data *exportData) error {
+	enc := json.NewEncoder(w)
+	for _, record := range data.Executions {
+		if err := enc.Encode(exportLine{Type: "execution", Record: record}); err != nil {
+			return err
+		}
+	}
+	for _, pkg := range data.Packages {
+		if err := enc.Encode(exportLine{Type: "package", Record: pkg}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportCSV(w io.Writer, columns []exportColumn, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// writeExportSQLite creates a fresh SQLite database at out using the
+// sqlite3 command line tool
+func writeExportSQLite(out string, data *exportData) error {
+	if _, err := exec.LookPath(sqliteCommandName); err != nil {
+		return fmt.Errorf("sqlite exports require %s on PATH", sqliteCommandName)
+	}
+	if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", out, err)
+	}
+
+	command := exec.Command(sqliteCommandName, out)
+	command.Stdin = strings.NewReader(sqliteExportScript(data))
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", sqliteCommandName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// sqliteExportScript renders data as SQL creating executions and packages tables
+func sqliteExportScript(data *exportData) string {
+	var script strings.Builder
+	script.WriteString("BEGIN;\n")
+	writeSQLiteTable(&script, exportDataExecutions, executionExportColumns, executionExportRows(data.Executions))
+	writeSQLiteTable(&script, exportDataPackages, packageExportColumns, packageExportRows(data.Packages))
+	script.WriteString("COMMIT;\n")
+	return script.String()
+}
+
+func writeSQLiteTable(script *strings.Builder, table string, columns []exportColumn, rows [][]string) {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = fmt.Sprintf("%q %s", column.Name, column.SQLType)
+	}
+	fmt.Fprintf(script, "CREATE TABLE %s (%s);\n", table, strings.Join(definitions, ", "))
+
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i, value := range row {
+			values[i] = sqliteValue(columns[i].SQLType, value)
+		}
+		fmt.Fprintf(script, "INSERT INTO %s VALUES (%s);\n", table, strings.Join(values, ", "))
+	}
+}
+
+func sqliteValue(sqlType, value string) string {
+	if value == "" {
+		return "NULL"
+	}
+	if sqlType == sqlTypeInteger {
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return value
+		}
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func executionExportRows(executions []*core.ExecutionRecord) [][]string {
+	rows := make([][]string, 0, len(executions))
+	for _, record := range executions {
+		rows = append(rows, []string{
+			record.ID,
+			record.Tool,
+			record.Command,
+			exportJSONValue(record.Args),
+			formatExportTime(record.Timestamp),
+			strconv.FormatInt(record.Duration.Milliseconds(), 10),
+			strconv.Itoa(record.ExitCode),
+			record.WorkingDir,
+			record.User,
+			exportJSONValue(record.Environment),
+			exportJSONValue(record.PackagesAffected),
+			exportJSONValue(record.Metadata),
+			record.ProjectName,
+			record.SessionID,
+			record.Host,
+			record.MachineID,
+		})
+	}
+	return rows
+}
+
+func packageExportRows(packages []*core.PackageInfo) [][]string {
+	rows := make([][]string, 0, len(packages))
+	for _, pkg := range packages {
+		rows = append(rows, []string{
+			pkg.Tool,
+			pkg.Name,
+			pkg.Version,
+			formatExportTime(pkg.InstallDate),
+			formatExportTime(pkg.LastUsed),
+			strconv.Itoa(pkg.UsageCount),
+			pkg.Path,
+			exportJSONValue(pkg.Dependencies),
+		})
+	}
+	return rows
+}
+
+// exportJSONValue encodes list and map fields for flat formats, leaving
+// empty values blank
+func exportJSONValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	switch string(data) {
+	case "null", "[]", "{}":
+		return ""
+	}
+	return string(data)
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
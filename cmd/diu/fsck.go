@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// fsckReport is the --json form of diu fsck
+type fsckReport struct {
+	*storage.CheckReport
+	// Repaired lists the issues --repair fixed; Issues lists those left.
+	Repaired []storage.CheckIssue `json:"repaired,omitempty"`
+}
+
+// checkStorage checks the storage file and its shards for problems and,
+// with --repair, fixes them by recomputing what is derived from the
+// executions
+func checkStorage(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	report, err := storage.Check(config.Storage.JSONFile)
+	if err != nil {
+		return fmt.Errorf("failed to check storage: %w", err)
+	}
+	result := fsckReport{CheckReport: report}
+
+	if flagBool(cmd, "repair") && len(report.Issues) > 0 {
+		if !report.Repairable() {
+			printFsckReport(result)
+			return fmt.Errorf("storage cannot be repaired; restore it from a backup with diu restore")
+		}
+		if err := storage.Repair(config); err != nil {
+			return fmt.Errorf("repair failed: %w", err)
+		}
+		after, err := storage.Check(config.Storage.JSONFile)
+		if err != nil {
+			return fmt.Errorf("failed to check repaired storage: %w", err)
+		}
+		result = fsckReport{CheckReport: after}
+		left := make(map[string]bool, len(after.Issues))
+		for _, issue := range after.Issues {
+			left[issue.Message] = true
+		}
+		for _, issue := range report.Issues {
+			if !left[issue.Message] {
+				result.Repaired = append(result.Repaired, issue)
+			}
+		}
+	}
+
+	if jsonOutput(cmd) {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		printFsckReport(result)
+	}
+	if len(result.Issues) > 0 {
+		if !flagBool(cmd, "repair") && result.Repairable() {
+			return fmt.Errorf("%d problems found; run diu fsck --repair to fix them", len(result.Issues))
+		}
+		return fmt.Errorf("%d problems found", len(result.Issues))
+	}
+	return nil
+}
+
+func printFsckReport(result fsckReport) {
+	fmt.Println(titleStyle.Render("Storage check"))
+	fmt.Println(subtitleStyle.Render(fmt.Sprintf("%s: %d executions, %d packages",
+		result.Path, result.Executions, result.Packages)))
+	fmt.Println()
+
+	for _, issue := range result.Repaired {
+		fmt.Println(successStyle.Render("repaired: " + issue.Message))
+	}
+	for _, issue := range result.Issues {
+		fmt.Println(errorStyle.Render(issue.Message))
+	}
+	if len(result.Issues) == 0 {
+		fmt.Println(successStyle.Render("No problems found"))
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+	"github.com/yowainwright/diu/pkg/client"
+)
+
+// defaultGenerateUsedWithin is how recently a package must have been used
+// for diu generate to include it
+const defaultGenerateUsedWithin = "180d"
+
+// The --format values of diu generate npm-globals and python-tools
+const (
+	generateFormatScript = "script"
+	generateFormatList   = "list"
+)
+
+// The --installer values of diu generate python-tools
+const (
+	pythonInstallerUV   = "uv"
+	pythonInstallerPipx = "pipx"
+)
+
+// pythonInstallCommands are the commands that install one Python CLI tool
+// into its own environment with each --installer
+var pythonInstallCommands = map[string]string{
+	pythonInstallerUV:   "uv tool install",
+	pythonInstallerPipx: "pipx install",
+}
+
+// xbarPluginName is the file name to save the xbar plugin as: xbar and
+// SwiftBar read the refresh interval, one minute, from it
+const xbarPluginName = "diu.1m.sh"
+
+// xbarPluginBody is the part of the xbar plugin after the variables
+// naming the diu binary and the summary URL. It reads the summary with
+// plutil, which parses JSON on macOS 12 and later, so it needs no jq.
+const xbarPluginBody = `summary=$(curl -fsS --max-time 2 "$URL" 2>/dev/null)
+if [ -z "$summary" ]; then
+	echo "diu ✕"
+	echo "---"
+	echo "The diu daemon is not reachable at $URL"
+	echo "Start the daemon | bash=\"$DIU\" param1=daemon param2=start terminal=false refresh=true"
+	exit 0
+fi
+
+field() {
+	printf '%s' "$summary" | plutil -extract "$1" raw -o - - 2>/dev/null
+}
+
+today=$(field today_executions)
+failures=$(field today_failures)
+unused=$(field unused_packages)
+days=$(field unused_days)
+status=$(field status)
+paused=$(field paused)
+last_command=$(field last_execution.command | tr '|' '/')
+
+case "$paused" in
+true | 1) echo "diu ⏸" ;;
+*) echo "diu $today" ;;
+esac
+echo "---"
+echo "$today commands today, $failures failed"
+echo "$unused packages unused for $days days"
+if [ -n "$last_command" ]; then
+	echo "Last: $last_command | length=60"
+fi
+echo "Daemon: $status"
+echo "---"
+case "$paused" in
+true | 1) echo "Resume recording | bash=\"$DIU\" param1=resume terminal=false refresh=true" ;;
+*) echo "Pause recording for 30m | bash=\"$DIU\" param1=pause param2=30m terminal=false refresh=true" ;;
+esac
+echo "Refresh | refresh=true"
+`
+
+// npmBundledPackages ship with Node.js, so a new machine already has them
+var npmBundledPackages = []string{"npm", "corepack"}
+
+// usedPackages returns the packages of tools used within the --used-within
+// duration of cmd, sorted by tool then name
+func usedPackages(cmd *command, tools ...string) ([]*core.PackageInfo, error) {
+	usedWithin := flagString(cmd, "used-within")
+	if usedWithin == "" {
+		usedWithin = defaultGenerateUsedWithin
+	}
+	duration, err := parseDuration(usedWithin)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("invalid --used-within duration %q", usedWithin)
+	}
+	cutoff := time.Now().Add(-duration)
+
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	packages, err := store.GetPackages("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+	var used []*core.PackageInfo
+	for _, pkg := range packages {
+		if slices.Contains(tools, pkg.Tool) && !packageUnusedSince(pkg, cutoff) {
+			used = append(used, pkg)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		if used[i].Tool != used[j].Tool {
+			return used[i].Tool < used[j].Tool
+		}
+		return used[i].Name < used[j].Name
+	})
+	return used, nil
+}
+
+// writeGenerated writes what write produces to --out, or to stdout without
+// it, reporting the file written
+func writeGenerated(cmd *command, what string, count int, write func(io.Writer) error) error {
+	out := flagString(cmd, "out")
+	if out == "" {
+		return write(os.Stdout)
+	}
+	if err := writeExportFile(out, write); err != nil {
+		return err
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Wrote %s of %d packages to %s", what, count, out)))
+	return nil
+}
+
+// generateFormat returns the --format of cmd, which is script or list
+func generateFormat(cmd *command) (string, error) {
+	format := flagString(cmd, "format")
+	if format != generateFormatScript && format != generateFormatList {
+		return "", fmt.Errorf("invalid format %q: must be %s or %s", format, generateFormatScript, generateFormatList)
+	}
+	return format, nil
+}
+
+// generateBrewfile prints a Brewfile of the Homebrew formulae and casks
+// used recently, to bootstrap a new machine with `brew bundle`
+func generateBrewfile(cmd *command, args []string) error {
+	packages, err := usedPackages(cmd, core.ToolHomebrew, homebrewCaskTool)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(cmd, "Brewfile", len(packages), func(w io.Writer) error {
+		return writeBrewfile(w, packages, flagString(cmd, "used-within"), time.Now())
+	})
+}
+
+// writeBrewfile writes packages as a Brewfile: the taps of tapped
+// formulae, then the formulae, then the casks
+func writeBrewfile(w io.Writer, packages []*core.PackageInfo, usedWithin string, now time.Time) error {
+	if usedWithin == "" {
+		usedWithin = defaultGenerateUsedWithin
+	}
+	var taps, formulae, casks []string
+	for _, pkg := range packages {
+		if pkg.Tool == homebrewCaskTool {
+			casks = append(casks, pkg.Name)
+			continue
+		}
+		// A tapped formula is named user/repo/formula.
+		if parts := strings.Split(pkg.Name, "/"); len(parts) == 3 {
+			if tap := parts[0] + "/" + parts[1]; !slices.Contains(taps, tap) {
+				taps = append(taps, tap)
+			}
+		}
+		formulae = append(formulae, pkg.Name)
+	}
+	sort.Strings(taps)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by diu on %s from Homebrew packages used within %s\n", now.Format("2006-01-02"), usedWithin)
+	for _, section := range []struct {
+		keyword string
+		names   []string
+	}{{"tap", taps}, {"brew", formulae}, {"cask", casks}} {
+		if len(section.names) == 0 {
+			continue
+		}
+		b.WriteString("\n")
+		for _, name := range section.names {
+			fmt.Fprintf(&b, "%s %q\n", section.keyword, name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// generateNPMGlobals prints an install script, or a plain list, of the
+// global npm packages used recently
+func generateNPMGlobals(cmd *command, args []string) error {
+	format, err := generateFormat(cmd)
+	if err != nil {
+		return err
+	}
+	packages, err := usedPackages(cmd, core.ToolNPM)
+	if err != nil {
+		return err
+	}
+	packages = slices.DeleteFunc(packages, func(pkg *core.PackageInfo) bool {
+		return slices.Contains(npmBundledPackages, pkg.Name)
+	})
+
+	return writeGenerated(cmd, "npm global "+format, len(packages), func(w io.Writer) error {
+		return writeNPMGlobals(w, packages, format, flagBool(cmd, "pin"), flagString(cmd, "used-within"), time.Now())
+	})
+}
+
+// writeNPMGlobals writes packages as a shell script that installs them
+// globally, or as a list with one package per line for xargs npm install
+// -g. With pin, each package keeps its recorded version.
+func writeNPMGlobals(w io.Writer, packages []*core.PackageInfo, format string, pin bool, usedWithin string, now time.Time) error {
+	if usedWithin == "" {
+		usedWithin = defaultGenerateUsedWithin
+	}
+	specs := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		spec := pkg.Name
+		if pin && pkg.Version != "" {
+			spec += "@" + pkg.Version
+		}
+		specs = append(specs, spec)
+	}
+
+	var b strings.Builder
+	if format == generateFormatList {
+		for _, spec := range specs {
+			b.WriteString(spec + "\n")
+		}
+	} else {
+		b.WriteString("#!/bin/sh\n")
+		fmt.Fprintf(&b, "# Generated by diu on %s from global npm packages used within %s\n", now.Format("2006-01-02"), usedWithin)
+		if len(specs) > 0 {
+			b.WriteString("set -e\n")
+			fmt.Fprintf(&b, "%s install %s %s\n", npmCommandName, npmGlobalFlag, strings.Join(specs, " "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// generatePythonTools prints an install script, or a plain list, of the
+// Python CLI tools used recently. They are the tools uv tool install put in
+// their own environments, which pipx can install the same way.
+func generatePythonTools(cmd *command, args []string) error {
+	format, err := generateFormat(cmd)
+	if err != nil {
+		return err
+	}
+	installer := flagString(cmd, "installer")
+	if _, ok := pythonInstallCommands[installer]; !ok {
+		return fmt.Errorf("invalid installer %q: must be %s or %s", installer, pythonInstallerUV, pythonInstallerPipx)
+	}
+	packages, err := usedPackages(cmd, core.ToolUV)
+	if err != nil {
+		return err
+	}
+
+	return writeGenerated(cmd, "Python tool "+format, len(packages), func(w io.Writer) error {
+		return writePythonTools(w, packages, format, installer, flagBool(cmd, "pin"), flagString(cmd, "used-within"), time.Now())
+	})
+}
+
+// writePythonTools writes packages as a shell script installing each with
+// installer, or as a list with one package per line. Both installers take
+// one tool per environment, so the script has a line per tool. With pin,
+// each package keeps its recorded version.
+func writePythonTools(w io.Writer, packages []*core.PackageInfo, format, installer string, pin bool, usedWithin string, now time.Time) error {
+	if usedWithin == "" {
+		usedWithin = defaultGenerateUsedWithin
+	}
+	var b strings.Builder
+	if format == generateFormatScript {
+		b.WriteString("#!/bin/sh\n")
+		fmt.Fprintf(&b, "# Generated by diu on %s from Python tools used within %s\n", now.Format("2006-01-02"), usedWithin)
+		if len(packages) > 0 {
+			b.WriteString("set -e\n")
+		}
+	}
+	for _, pkg := range packages {
+		spec := pkg.Name
+		if pin && pkg.Version != "" {
+			spec += "==" + pkg.Version
+		}
+		if format == generateFormatScript {
+			spec = pythonInstallCommands[installer] + " " + spec
+		}
+		b.WriteString(spec + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// generateXbar prints an xbar or SwiftBar plugin showing the daemon's
+// /api/v1/summary in the menu bar. With --out the plugin is written
+// executable, as xbar requires.
+func generateXbar(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	daemon, err := client.NewFromConfig(config)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "diu"
+	}
+
+	var plugin bytes.Buffer
+	if err := writeXbarPlugin(&plugin, daemon.BaseURL()+client.SummaryPath, executable, time.Now()); err != nil {
+		return err
+	}
+	out := flagString(cmd, "out")
+	if out == "" {
+		_, err := os.Stdout.Write(plugin.Bytes())
+		return err
+	}
+	if err := writeOwnerExecutableFile(out, plugin.Bytes()); err != nil {
+		return err
+	}
+	fmt.Println(successStyle.Render("Wrote xbar plugin to " + out))
+	return nil
+}
+
+// writeXbarPlugin writes a shell script that polls summaryURL and prints
+// the menu bar item xbar and SwiftBar show, with menu items running diu
+// from executable
+func writeXbarPlugin(w io.Writer, summaryURL, executable string, now time.Time) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# <xbar.title>diu</xbar.title>\n")
+	fmt.Fprintf(&b, "# <xbar.version>%s</xbar.version>\n", core.Version)
+	b.WriteString("# <xbar.desc>Today's package manager activity from the diu daemon</xbar.desc>\n")
+	b.WriteString("# <xbar.dependencies>diu</xbar.dependencies>\n")
+	fmt.Fprintf(&b, "# Generated by diu on %s. Save as %s in the xbar or SwiftBar plugin folder.\n", now.Format("2006-01-02"), xbarPluginName)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "DIU=%s\n", shellSingleQuote(executable))
+	fmt.Fprintf(&b, "URL=%s\n", shellSingleQuote(summaryURL))
+	b.WriteString("\n")
+	b.WriteString(xbarPluginBody)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// shellSingleQuote quotes s for a POSIX shell
+func shellSingleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
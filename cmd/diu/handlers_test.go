@@ -2,18 +2,30 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/gitinfo"
+	"github.com/yowainwright/diu/internal/project"
 	"github.com/yowainwright/diu/internal/storage"
+	"github.com/yowainwright/diu/pkg/client"
 )
 
 // =============================================================================
@@ -202,6 +214,364 @@ func TestQueryExecutionsWithTimeFilter(t *testing.T) {
 	}
 }
 
+// =============================================================================
+// Export Handler Tests
+// =============================================================================
+
+func exportCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var format, out, data, tool, pkg, last, salt string
+	var limit int
+	var anonymize bool
+	cmd.Flags().StringVarP(&format, "format", "f", formatJSON, "format")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "out")
+	cmd.Flags().StringVar(&data, "data", exportDataAll, "data")
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	cmd.Flags().StringVarP(&pkg, "package", "p", "", "package")
+	cmd.Flags().StringVarP(&last, "last", "l", "", "last")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "limit")
+	cmd.Flags().BoolVar(&anonymize, "anonymize", false, "anonymize")
+	cmd.Flags().StringVar(&salt, "salt", "", "salt")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func seedExportTestData(t *testing.T, config *core.Config) {
+	t.Helper()
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool:             core.ToolNPM,
+		Command:          "npm install -g typescript,eslint",
+		Args:             []string{"install", "-g", "typescript", "eslint"},
+		Timestamp:        time.Now().Add(-time.Hour),
+		Duration:         1500 * time.Millisecond,
+		ExitCode:         1,
+		WorkingDir:       "/tmp/it's here",
+		User:             "dev",
+		PackagesAffected: []string{"typescript", "eslint"},
+	})
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool:      core.ToolHomebrew,
+		Command:   "brew install jq",
+		Timestamp: time.Now().Add(-72 * time.Hour),
+	})
+	updateTestPackage(t, store, &core.PackageInfo{Name: "typescript", Tool: core.ToolNPM, Version: "5.4.0", UsageCount: 3})
+	updateTestPackage(t, store, &core.PackageInfo{Name: "jq", Tool: core.ToolHomebrew, Version: "1.7"})
+	closeTestStore(t, store)
+}
+
+func TestExportHistoryCSVIncludesAllFields(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+
+	output := captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--format", "csv", "--data", "executions", "--tool", "npm"), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+
+	rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v\n%s", err, output)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected header and one npm row, got %d rows", len(rows))
+	}
+	if len(rows[0]) != len(executionExportColumns) || rows[0][0] != "id" {
+		t.Fatalf("Unexpected header: %v", rows[0])
+	}
+	row := rows[1]
+	if row[2] != "npm install -g typescript,eslint" {
+		t.Fatalf("Expected command with comma intact, got %q", row[2])
+	}
+	if row[5] != "1500" || row[6] != "1" || row[7] != "/tmp/it's here" || row[8] != "dev" {
+		t.Fatalf("Unexpected field values: %v", row)
+	}
+	if row[10] != `["typescript","eslint"]` {
+		t.Fatalf("Expected packages_affected as JSON, got %q", row[10])
+	}
+}
+
+func TestExportHistoryCSVToFileWritesPackagesCompanion(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+	out := filepath.Join(t.TempDir(), "diu.csv")
+
+	captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--format", "csv", "--out", out), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+
+	packages, err := os.ReadFile(filepath.Join(filepath.Dir(out), "diu-packages.csv"))
+	if err != nil {
+		t.Fatalf("Expected packages CSV: %v", err)
+	}
+	if !strings.HasPrefix(string(packages), "tool,name,version,install_date,last_used,usage_count,path,dependencies\n") {
+		t.Fatalf("Unexpected packages CSV: %q", packages)
+	}
+	if !strings.Contains(string(packages), "npm,typescript,5.4.0") {
+		t.Fatalf("Expected typescript package row, got %q", packages)
+	}
+}
+
+func TestExportHistoryCSVStdoutRequiresDataset(t *testing.T) {
+	setupTestHomeConfig(t)
+	err := exportHistory(exportCommandForTest(t, "--format", "csv"), nil)
+	if err == nil || !strings.Contains(err.Error(), "--data") {
+		t.Fatalf("Expected --data error, got %v", err)
+	}
+}
+
+func TestExportHistoryJSONLAppliesFilters(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+
+	output := captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--format", "jsonl", "--last", "24h"), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 1 recent execution and 3 packages, got %d lines: %q", len(lines), output)
+	}
+	var first struct {
+		Type   string               `json:"type"`
+		Record core.ExecutionRecord `json:"record"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Invalid JSONL line: %v", err)
+	}
+	if first.Type != "execution" || first.Record.Tool != core.ToolNPM {
+		t.Fatalf("Expected recent npm execution first, got %+v", first)
+	}
+}
+
+func TestExportHistoryAnonymize(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+
+	export := func(args ...string) []*core.ExecutionRecord {
+		t.Helper()
+		var data exportData
+		output := captureStdout(t, func() {
+			if err := exportHistory(exportCommandForTest(t, append([]string{"--data", "executions", "--anonymize"}, args...)...), nil); err != nil {
+				t.Fatalf("exportHistory failed: %v", err)
+			}
+		})
+		if err := json.Unmarshal([]byte(output), &data); err != nil {
+			t.Fatalf("Invalid JSON export: %v", err)
+		}
+		return data.Executions
+	}
+
+	first := export("--salt", "team")
+	npm := first[0]
+	if npm.User == "dev" || npm.WorkingDir == "/tmp/it's here" || !strings.HasPrefix(npm.User, "user-") {
+		t.Errorf("Expected user and working dir to be pseudonyms, got %q and %q", npm.User, npm.WorkingDir)
+	}
+	if npm.Tool != core.ToolNPM || npm.Duration != 1500*time.Millisecond || len(npm.PackagesAffected) != 2 {
+		t.Errorf("Expected tool, timing, and packages to be kept, got %+v", npm)
+	}
+	if again := export("--salt", "team"); again[0].User != npm.User {
+		t.Errorf("Expected the same salt to give the same pseudonyms, got %q and %q", again[0].User, npm.User)
+	}
+
+	if err := exportHistory(exportCommandForTest(t, "--salt", "team"), nil); err == nil || !strings.Contains(err.Error(), "--anonymize") {
+		t.Errorf("Expected --salt without --anonymize to fail, got %v", err)
+	}
+}
+
+func TestExportHistoryJSONToFile(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+	out := filepath.Join(t.TempDir(), "diu.json")
+
+	output := captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--out", out, "--package", "typescript"), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Exported 1 executions and 1 packages") {
+		t.Fatalf("Expected export summary, got %q", output)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	var exported exportData
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Invalid JSON export: %v", err)
+	}
+	if len(exported.Executions) != 1 || len(exported.Packages) != 1 || exported.Packages[0].Name != "typescript" {
+		t.Fatalf("Unexpected export: %+v", exported)
+	}
+}
+
+func TestExportHistorySQLite(t *testing.T) {
+	if _, err := exec.LookPath(sqliteCommandName); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+	out := filepath.Join(t.TempDir(), "diu.db")
+
+	captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--format", "sqlite", "--out", out), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+
+	output, err := exec.Command(sqliteCommandName, out,
+		"SELECT count(*) FROM executions; SELECT working_dir FROM executions WHERE tool = 'npm'; SELECT sum(usage_count) FROM packages;").Output()
+	if err != nil {
+		t.Fatalf("Failed to query export: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "2\n/tmp/it's here\n4" {
+		t.Fatalf("Unexpected sqlite contents: %q", got)
+	}
+}
+
+func TestSQLiteExportScriptQuotesValues(t *testing.T) {
+	script := sqliteExportScript(&exportData{
+		Executions: []*core.ExecutionRecord{{ID: "a", Tool: "npm", Command: "echo 'hi'"}},
+	})
+	if !strings.Contains(script, "'echo ''hi'''") {
+		t.Fatalf("Expected escaped quotes, got %q", script)
+	}
+	if !strings.Contains(script, "CREATE TABLE packages") {
+		t.Fatalf("Expected packages table, got %q", script)
+	}
+}
+
+// =============================================================================
+// Report Handler Tests
+// =============================================================================
+
+func TestShowReportPrintsSummary(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+
+	cmd := &command{}
+	var weekly, email bool
+	cmd.Flags().BoolVar(&weekly, "weekly", true, "")
+	cmd.Flags().BoolVar(&email, "email", false, "")
+	output := captureStdout(t, func() {
+		if err := showReport(cmd, nil); err != nil {
+			t.Fatalf("showReport failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "diu weekly summary") || !strings.Contains(output, "Executions: 2 (1 failed)") {
+		t.Fatalf("Unexpected report output: %q", output)
+	}
+}
+
+func TestShowReportEmailRequiresSMTP(t *testing.T) {
+	setupTestHomeConfig(t)
+
+	cmd := &command{}
+	var email bool
+	cmd.Flags().BoolVar(&email, "email", true, "")
+	err := showReport(cmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "reporting.smtp.host") {
+		t.Fatalf("Expected missing SMTP host error, got %v", err)
+	}
+}
+
+// =============================================================================
+// Import Handler Tests
+// =============================================================================
+
+func TestImportHistoryFromJSONLExportSkipsDuplicates(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	seedExportTestData(t, config)
+	out := filepath.Join(t.TempDir(), "diu.jsonl")
+	captureStdout(t, func() {
+		if err := exportHistory(exportCommandForTest(t, "--format", "jsonl", "--out", out), nil); err != nil {
+			t.Fatalf("exportHistory failed: %v", err)
+		}
+	})
+
+	output := captureStdout(t, func() {
+		if err := importHistory(&command{}, []string{out}); err != nil {
+			t.Fatalf("importHistory failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "0 inserted, 2 skipped") || !strings.Contains(output, "0 inserted, 3 skipped") {
+		t.Fatalf("Expected all records skipped, got %q", output)
+	}
+	if got := countStoredExecutions(t, config); got != 2 {
+		t.Fatalf("Expected 2 executions after re-import, got %d", got)
+	}
+}
+
+func TestImportHistoryFromStorageFile(t *testing.T) {
+	source := setupTestHomeConfig(t)
+	seedExportTestData(t, source)
+	dir := t.TempDir()
+	storageFile := filepath.Join(dir, "executions.json")
+	shards, _ := filepath.Glob(filepath.Join(filepath.Dir(source.Storage.JSONFile), "executions-*.json"))
+	if len(shards) == 0 {
+		t.Fatal("Expected the executions in shards")
+	}
+	for _, path := range append(shards, source.Storage.JSONFile) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read storage: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(path)), data, 0600); err != nil {
+			t.Fatalf("Failed to copy storage: %v", err)
+		}
+	}
+
+	config := setupTestHomeConfig(t)
+	output := captureStdout(t, func() {
+		if err := importHistory(&command{}, []string{storageFile}); err != nil {
+			t.Fatalf("importHistory failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "2 inserted, 0 skipped") || !strings.Contains(output, "3 inserted, 0 skipped") {
+		t.Fatalf("Expected all records inserted, got %q", output)
+	}
+
+	store := openTestStore(t, config)
+	defer closeTestStore(t, store)
+	pkg, err := store.GetPackage(core.ToolNPM, "typescript")
+	if err != nil {
+		t.Fatalf("Expected imported typescript package: %v", err)
+	}
+	if pkg.Version != "5.4.0" || pkg.UsageCount != 3 {
+		t.Fatalf("Expected package fields preserved, got %+v", pkg)
+	}
+}
+
+func TestParseImportDataFillsHostFromStorageMetadata(t *testing.T) {
+	records, err := parseImportData("", []byte(`{
+		"metadata": {"hostname": "old-laptop"},
+		"executions": [{"id": "a", "tool": "npm"}, {"id": "b", "tool": "npm", "host": "desktop"}]
+	}`))
+	if err != nil {
+		t.Fatalf("parseImportData failed: %v", err)
+	}
+	if records.Executions[0].Host != "old-laptop" || records.Executions[1].Host != "desktop" {
+		t.Errorf("Unexpected hosts: %q, %q", records.Executions[0].Host, records.Executions[1].Host)
+	}
+}
+
+func TestParseImportDataRejectsUnknownRecordType(t *testing.T) {
+	_, err := parseImportData("", []byte(`{"type":"execution","record":{"id":"a"}}`+"\n"+`{"type":"widget","record":{}}`+"\n"))
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("Expected record 2 error, got %v", err)
+	}
+}
+
 // =============================================================================
 // Stats Handler Tests
 // =============================================================================
@@ -448,6 +818,87 @@ func TestListPackagesUnusedFilterNoMatches(t *testing.T) {
 	}
 }
 
+func TestListPackagesUnusedReportsReclaimableSpace(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	root := t.TempDir()
+	writeSized := func(path string, size int) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o755); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	cellarBinary := filepath.Join(root, "Cellar", "wget", "1.21", "bin", "wget")
+	writeSized(cellarBinary, 2048)
+	writeSized(filepath.Join(root, "Cellar", "wget", "1.21", "share", "wget.info"), 1024)
+	brewLink := filepath.Join(root, "bin", "wget")
+	if err := os.MkdirAll(filepath.Dir(brewLink), 0o755); err != nil {
+		t.Fatalf("Failed to create bin directory: %v", err)
+	}
+	if err := os.Symlink(cellarBinary, brewLink); err != nil {
+		t.Fatalf("Failed to link wget: %v", err)
+	}
+	goBinary := filepath.Join(root, "go", "bin", "stringer")
+	writeSized(goBinary, 1024)
+
+	store := openTestStore(t, config)
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	updateTestPackage(t, store, &core.PackageInfo{Name: "wget", Tool: core.ToolHomebrew, LastUsed: old, Path: brewLink})
+	updateTestPackage(t, store, &core.PackageInfo{Name: "stringer", Tool: core.ToolGoBinary, LastUsed: old, Path: goBinary})
+	updateTestPackage(t, store, &core.PackageInfo{Name: "black", Tool: core.ToolPip, LastUsed: old})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := listPackages(packagesCommandForTest(t, "--unused", "30d"), nil); err != nil {
+			t.Fatalf("listPackages with unused filter failed: %v", err)
+		}
+	})
+	for _, want := range []string{"3.0 KiB", "You could reclaim 4.0 KiB by removing 3 unused packages", "Disk usage unknown for 1 of them"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got: %q", want, output)
+		}
+	}
+}
+
+func TestListPackagesWithLicenses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readline-tool/1.0.0" {
+			_, _ = w.Write([]byte(`{"license": "GPL-3.0-or-later"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	t.Setenv("npm_config_registry", server.URL)
+
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "readline-tool", Version: "1.0.0", UsageCount: 3})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", License: "MIT"})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolPip, Name: "black"})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := listPackages(packagesCommandForTest(t, "--license"), nil); err != nil {
+			t.Fatalf("listPackages --license failed: %v", err)
+		}
+	})
+	for _, want := range []string{"license: MIT", "license: unknown", "Copyleft Licenses", "GPL-3.0-or-later"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+
+	store = openTestStore(t, config)
+	pkg, err := store.GetPackage(core.ToolNPM, "readline-tool")
+	closeTestStore(t, store)
+	if err != nil || pkg.License != "GPL-3.0-or-later" {
+		t.Errorf("Expected the looked up license to be stored, got %+v, %v", pkg, err)
+	}
+}
+
 func TestCheckPackagesWithSearch(t *testing.T) {
 	config := setupTestHomeConfig(t)
 	store := openTestStore(t, config)
@@ -779,11 +1230,17 @@ func TestGetConfigAllKeys(t *testing.T) {
 		"storage.max_executions",
 		"storage.max_storage_bytes",
 		"storage.max_backups",
+		"storage.auto_cleanup",
+		"daemon.log_level",
+		"daemon.log_format",
 		"daemon.pid_file",
 		"daemon.socket_path",
 		"api.enabled",
 		"api.port",
 		"monitoring.enabled_tools",
+		"reporting.daily_summary",
+		"reporting.email_reports",
+		"reporting.smtp.port",
 	}
 
 	for _, key := range validKeys {
@@ -817,11 +1274,30 @@ func TestSetConfigAllKeys(t *testing.T) {
 		{"storage.max_executions", "500"},
 		{"storage.max_storage_bytes", "1073741824"},
 		{"storage.max_backups", "5"},
+		{"storage.backup_keep", "30d"},
+		{"storage.backup_remote", "s3://bucket/diu"},
+		{"storage.backup_s3_endpoint", "http://127.0.0.1:9000"},
+		{"storage.auto_cleanup", "false"},
+		{"tools.go.retention_days", "30"},
+		{"daemon.log_level", "debug"},
+		{"daemon.log_format", "json"},
+		{"daemon.log_file", "/tmp/diu.log"},
 		{"daemon.pid_file", "/tmp/diu.pid"},
 		{"daemon.socket_path", "/tmp/diu.sock"},
 		{"api.enabled", "false"},
 		{"api.port", "9090"},
 		{"monitoring.enabled_tools", "homebrew,npm"},
+		{"reporting.email_reports", "true"},
+		{"reporting.weekly_summary", "false"},
+		{"reporting.smtp.host", "smtp.example.com"},
+		{"reporting.smtp.port", "2525"},
+		{"reporting.smtp.username", "diu"},
+		{"reporting.smtp.from", "diu@example.com"},
+		{"reporting.smtp.to", "me@example.com,team@example.com"},
+		{"tools.homebrew.track_casks", "false"},
+		{"monitoring.process.wrapper_dir", "/tmp/diu-wrappers"},
+		{"storage.cleanup_interval", "12h0m0s"},
+		{"prune.ignore", "git,npm/typescript"},
 	}
 
 	for _, tt := range tests {
@@ -844,7 +1320,7 @@ func TestSetConfigAllKeys(t *testing.T) {
 			})
 			// For monitoring.enabled_tools, the output has comma-space separator
 			var expectedOutput = tt.value
-			if tt.key == "monitoring.enabled_tools" {
+			if tt.key == "monitoring.enabled_tools" || tt.key == "reporting.smtp.to" || tt.key == "prune.ignore" {
 				expectedOutput = strings.ReplaceAll(tt.value, ",", ", ")
 			}
 			if strings.TrimSpace(getOutput) != expectedOutput {
@@ -1333,6 +1809,23 @@ func TestStopDaemonNotRunning(t *testing.T) {
 	}
 }
 
+func TestReloadDaemonNotRunning(t *testing.T) {
+	setupTestHomeConfig(t)
+
+	restore := SetDaemonChecker(MockDaemonChecker{isRunning: false})
+	defer restore()
+
+	output := captureStdout(t, func() {
+		if err := reloadDaemon(&command{}, nil); err != nil {
+			t.Fatalf("reloadDaemon failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "DIU daemon is not running") {
+		t.Fatalf("Expected 'not running' message, got: %q", output)
+	}
+}
+
 func TestDaemonStatusWithMockRunning(t *testing.T) {
 	setupTestHomeConfig(t)
 
@@ -1366,6 +1859,19 @@ func TestDaemonStatusWithMockRunning(t *testing.T) {
 	if !strings.Contains(output, "DIU daemon is running") {
 		t.Fatalf("Expected 'is running' message, got: %q", output)
 	}
+
+	output = captureStdout(t, func() {
+		if err := daemonStatus(withGlobalJSON(&command{}), nil); err != nil {
+			t.Fatalf("daemonStatus --json failed: %v", err)
+		}
+	})
+	var report map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid status JSON %q: %v", output, err)
+	}
+	if report["pid"] != float64(12345) {
+		t.Errorf("Expected a numeric pid, got %v", report["pid"])
+	}
 }
 
 // =============================================================================
@@ -1829,7 +2335,7 @@ func TestRestartDaemonStopsThenStarts(t *testing.T) {
 	defer restore()
 
 	oldStarter := daemonProcessStarter
-	daemonProcessStarter = func(string, []string, *syscall.ProcAttr) error {
+	daemonProcessStarter = func(*exec.Cmd) error {
 		return nil
 	}
 	defer func() {
@@ -1855,7 +2361,7 @@ func TestRestartDaemonStopsThenStarts(t *testing.T) {
 
 func TestForkDaemonBackgroundStarterError(t *testing.T) {
 	oldStarter := daemonProcessStarter
-	daemonProcessStarter = func(string, []string, *syscall.ProcAttr) error {
+	daemonProcessStarter = func(*exec.Cmd) error {
 		return errors.New("fork failed")
 	}
 	defer func() {
@@ -1874,6 +2380,58 @@ func TestForkDaemonBackgroundStarterError(t *testing.T) {
 	}
 }
 
+func TestForkDaemonBackgroundRedirectsToStdoutLogFile(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+	config := &core.Config{Daemon: core.DaemonConfig{LogFile: filepath.Join(logDir, "diu.log")}}
+	// diu.log is rotated by renaming, which the child's descriptors would
+	// not follow
+	logFile := filepath.Join(logDir, "diu.stdout.log")
+
+	var started *exec.Cmd
+	oldStarter := daemonProcessStarter
+	daemonProcessStarter = func(cmd *exec.Cmd) error {
+		started = cmd
+		return errors.New("stop before waiting")
+	}
+	defer func() {
+		daemonProcessStarter = oldStarter
+	}()
+
+	captureStdout(t, func() {
+		_ = forkDaemonBackground(config)
+	})
+	if started == nil {
+		t.Fatal("expected daemon process to be started")
+	}
+	if got := strings.Join(started.Args[1:], " "); got != "daemon start --foreground" {
+		t.Fatalf("expected child to run 'daemon start --foreground', got %q", got)
+	}
+	stdout, ok := started.Stdout.(*os.File)
+	if !ok || stdout.Name() != logFile {
+		t.Fatalf("expected stdout to be redirected to %s, got %v", logFile, started.Stdout)
+	}
+	if started.Stderr != started.Stdout {
+		t.Fatal("expected stderr to share the log file with stdout")
+	}
+	if started.SysProcAttr == nil {
+		t.Fatal("expected detached process attributes")
+	}
+	if _, err := os.Stat(logFile); err != nil {
+		t.Fatalf("expected log file to be created: %v", err)
+	}
+}
+
+func TestStartDaemonRejectsForegroundAndDetach(t *testing.T) {
+	cmd := &command{}
+	var foreground, detach bool
+	cmd.Flags().BoolVar(&foreground, "foreground", true, "")
+	cmd.Flags().BoolVar(&detach, "detach", true, "")
+
+	if err := startDaemon(cmd, nil); err == nil {
+		t.Fatal("expected --foreground and --detach to be rejected together")
+	}
+}
+
 func TestStopDaemonWithConfigMissingPIDFile(t *testing.T) {
 	config := setupTestHomeConfig(t)
 	restore := SetDaemonChecker(MockDaemonChecker{isRunning: true})
@@ -2136,3 +2694,1736 @@ func TestInstallWrappersInitializesKnownTool(t *testing.T) {
 		t.Fatalf("installWrappers failed: %v", err)
 	}
 }
+
+func TestPrintLogTail(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "diu.log")
+	if err := os.WriteFile(logPath, []byte("one\ntwo\nthree\n"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	var buf bytes.Buffer
+	offset, err := printLogTail(&buf, logPath, 2)
+	if err != nil {
+		t.Fatalf("printLogTail failed: %v", err)
+	}
+	if buf.String() != "two\nthree\n" {
+		t.Errorf("Unexpected tail: %q", buf.String())
+	}
+	if offset != int64(len("one\ntwo\nthree\n")) {
+		t.Errorf("Unexpected offset: %d", offset)
+	}
+}
+
+func TestPrintLogTailMissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	offset, err := printLogTail(&buf, filepath.Join(t.TempDir(), "missing.log"), 10)
+	if err != nil || offset != 0 || buf.Len() != 0 {
+		t.Fatalf("Expected empty tail for missing log, got %q, %d, %v", buf.String(), offset, err)
+	}
+}
+
+func TestFollowLogFileStreamsAppendsAndRotation(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "diu.log")
+	if err := os.WriteFile(logPath, []byte("first\n"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	writer := writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	})
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- followLogFile(writer, logPath, int64(len("first\n")), 10*time.Millisecond, stop)
+	}()
+
+	appendFile := func(text string) {
+		file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, core.PrivateFileMode)
+		if err != nil {
+			t.Fatalf("Failed to open log: %v", err)
+		}
+		if _, err := file.WriteString(text); err != nil {
+			t.Fatalf("Failed to append log: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("Failed to close log: %v", err)
+		}
+	}
+	waitFor := func(want string) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			got := buf.String()
+			mu.Unlock()
+			if strings.Contains(got, want) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %q", want)
+	}
+
+	appendFile("second line\n")
+	waitFor("second line\n")
+
+	if err := os.WriteFile(logPath, []byte("new\n"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to rotate log: %v", err)
+	}
+	waitFor("new\n")
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("followLogFile failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Contains(buf.String(), "first") {
+		t.Errorf("Expected follow to start at offset, got %q", buf.String())
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestListBackups(t *testing.T) {
+	config := setupTestHomeConfig(t)
+
+	output := captureStdout(t, func() {
+		if err := listBackups(&command{}, nil); err != nil {
+			t.Fatalf("listBackups failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "No backups found") {
+		t.Fatalf("Expected empty message, got %q", output)
+	}
+
+	backupPath := config.Storage.JSONFile + ".backup.20260101_000000_000000000"
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0o700); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	if err := os.WriteFile(backupPath, make([]byte, 2048), 0o600); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+
+	output = captureStdout(t, func() {
+		if err := listBackups(&command{}, nil); err != nil {
+			t.Fatalf("listBackups failed: %v", err)
+		}
+	})
+	for _, want := range []string{backupPath, "2.0 KiB", "1 backups"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1536:        "1.5 KiB",
+		5 << 20:     "5.0 MiB",
+		3 << 30 / 2: "1.5 GiB",
+	}
+	for size, want := range tests {
+		if got := formatBytes(size); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestBackupToAndRestoreFromRemote(t *testing.T) {
+	config := setupTestHomeConfig(t)
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	if err := store.AddExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm install -g typescript", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to add execution: %v", err)
+	}
+	closeStore(store)
+
+	backupCmd := &command{}
+	var to string
+	backupCmd.Flags().StringVar(&to, "to", server.URL+"/diu", "")
+	output := captureStdout(t, func() {
+		if err := backup(backupCmd, nil); err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Uploaded") {
+		t.Fatalf("Expected upload message, got %q", output)
+	}
+
+	var remoteObject string
+	for path := range objects {
+		remoteObject = path
+	}
+	if !strings.HasPrefix(remoteObject, "/diu/executions.json.backup.") {
+		t.Fatalf("Expected backup uploaded under /diu, got %v", objects)
+	}
+
+	if err := os.Remove(config.Storage.JSONFile); err != nil {
+		t.Fatalf("Failed to remove storage: %v", err)
+	}
+
+	restoreCmd := &command{}
+	var from string
+	var yes bool
+	restoreCmd.Flags().StringVar(&from, "from", server.URL+remoteObject, "")
+	restoreCmd.Flags().BoolVar(&yes, "yes", true, "")
+	captureStdout(t, func() {
+		if err := restoreBackup(restoreCmd, nil); err != nil {
+			t.Fatalf("restoreBackup failed: %v", err)
+		}
+	})
+
+	store, err = storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer closeStore(store)
+	executions, err := store.GetExecutions(storage.QueryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get executions: %v", err)
+	}
+	if len(executions) != 1 || executions[0].Tool != "npm" {
+		t.Fatalf("Expected restored npm execution, got %+v", executions)
+	}
+}
+
+func TestRestoreBackupRequiresSource(t *testing.T) {
+	setupTestHomeConfig(t)
+	if err := restoreBackup(&command{}, nil); err == nil || !strings.Contains(err.Error(), "--from") {
+		t.Fatalf("Expected missing source error, got %v", err)
+	}
+}
+
+func restoreCommandForTest(dryRun, yes bool) *command {
+	cmd := &command{}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", dryRun, "")
+	cmd.Flags().BoolVar(&yes, "yes", yes, "")
+	return cmd
+}
+
+func createBackupForRestoreTest(t *testing.T, config *core.Config) string {
+	t.Helper()
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeStore(store)
+	if err := store.AddExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm install -g typescript", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to add execution: %v", err)
+	}
+	backupPath, err := store.Backup()
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	for _, command := range []string{"npm install -g eslint", "npm install -g prettier"} {
+		if err := store.AddExecution(&core.ExecutionRecord{Tool: "npm", Command: command, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Failed to add execution: %v", err)
+		}
+	}
+	return backupPath
+}
+
+func countStoredExecutions(t *testing.T, config *core.Config) int {
+	t.Helper()
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeStore(store)
+	executions, err := store.GetExecutions(storage.QueryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get executions: %v", err)
+	}
+	return len(executions)
+}
+
+func TestRestoreBackupDryRunShowsCounts(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	backupPath := createBackupForRestoreTest(t, config)
+
+	output := captureStdout(t, func() {
+		if err := restoreBackup(restoreCommandForTest(true, false), []string{backupPath}); err != nil {
+			t.Fatalf("restoreBackup failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "Dry run") {
+		t.Fatalf("Expected dry run notice, got %q", output)
+	}
+	if !regexp.MustCompile(`Executions\s+1\s+3`).MatchString(output) {
+		t.Fatalf("Expected backup and current execution counts, got %q", output)
+	}
+	if got := countStoredExecutions(t, config); got != 3 {
+		t.Fatalf("Expected dry run to keep 3 executions, got %d", got)
+	}
+}
+
+func TestRestoreBackupCancelledWithoutConfirmation(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	backupPath := createBackupForRestoreTest(t, config)
+
+	var err error
+	withStdin(t, "n\n", func() {
+		captureStdout(t, func() {
+			err = restoreBackup(restoreCommandForTest(false, false), []string{backupPath})
+		})
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected cancelled restore, got %v", err)
+	}
+	if got := countStoredExecutions(t, config); got != 3 {
+		t.Fatalf("Expected cancelled restore to keep 3 executions, got %d", got)
+	}
+}
+
+func TestRestoreBackupFromLocalFileTakesSafetyBackup(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	backupPath := createBackupForRestoreTest(t, config)
+
+	var output string
+	withStdin(t, "y\n", func() {
+		output = captureStdout(t, func() {
+			if err := restoreBackup(restoreCommandForTest(false, false), []string{filepath.Base(backupPath)}); err != nil {
+				t.Fatalf("restoreBackup failed: %v", err)
+			}
+		})
+	})
+
+	if !strings.Contains(output, "Current data backed up to") {
+		t.Fatalf("Expected safety backup message, got %q", output)
+	}
+	if got := countStoredExecutions(t, config); got != 1 {
+		t.Fatalf("Expected restored data to have 1 execution, got %d", got)
+	}
+
+	backups, err := storage.ListBackups(config.Storage.JSONFile)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	foundSafety := false
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			t.Fatalf("Failed to read backup: %v", err)
+		}
+		if strings.Contains(string(data), "npm install -g prettier") {
+			foundSafety = true
+		}
+	}
+	if !foundSafety {
+		t.Fatal("Expected a safety backup of the pre-restore data")
+	}
+}
+
+func TestStreamExecutionsReadsDaemonStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != client.ExecutionStreamPath {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.URL.Query().Get("tool"); got != core.ToolNPM {
+			t.Errorf("Expected tool filter npm, got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, `{"tool":"npm","command":"npm install -g typescript","packages_affected":["typescript"]}`+"\n")
+		_, _ = io.WriteString(w, `{"tool":"npm","command":"npm ls -g","exit_code":1}`+"\n")
+	}))
+	defer server.Close()
+
+	config := apiConfigForServer(t, server)
+
+	var output bytes.Buffer
+	err := streamExecutions(t.Context(), config, core.ToolNPM, func(record *core.ExecutionRecord) error {
+		return writeWatchedExecution(&output, record, formatTable)
+	})
+	if err == nil || !strings.Contains(err.Error(), "closed the execution stream") {
+		t.Fatalf("Expected closed stream error, got %v", err)
+	}
+
+	text := output.String()
+	for _, want := range []string{"[npm] npm install -g typescript (typescript)", "npm ls -g exit 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, text)
+		}
+	}
+}
+
+func TestStreamExecutionsRequiresAPI(t *testing.T) {
+	config := core.DefaultConfig()
+	config.API.Enabled = false
+
+	err := streamExecutions(t.Context(), config, "", func(*core.ExecutionRecord) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "api.enabled") {
+		t.Fatalf("Expected disabled API error, got %v", err)
+	}
+}
+
+func apiConfigForServer(t *testing.T, server *httptest.Server) *core.Config {
+	t.Helper()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	config := core.DefaultConfig()
+	config.API.Host = host
+	config.API.Port, err = strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("Failed to parse server port: %v", err)
+	}
+	return config
+}
+
+func topCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var tool, last, sortBy, by, interval string
+	var limit int
+	var once bool
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	cmd.Flags().StringVarP(&last, "last", "l", "7d", "last")
+	cmd.Flags().StringVarP(&sortBy, "sort", "s", topSortCount, "sort")
+	cmd.Flags().StringVar(&by, "by", topByPackage, "by")
+	cmd.Flags().IntVarP(&limit, "limit", "n", defaultListLimit, "limit")
+	cmd.Flags().StringVar(&interval, "interval", "2s", "interval")
+	cmd.Flags().BoolVar(&once, "once", false, "once")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestShowTopRanksRecentPackages(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: core.ToolHomebrew, Command: "rg foo", Timestamp: now.Add(-time.Hour), Duration: time.Second, PackagesAffected: []string{"ripgrep"}},
+		{Tool: core.ToolHomebrew, Command: "rg bar", Timestamp: now.Add(-2 * time.Hour), Duration: time.Second, PackagesAffected: []string{"ripgrep"}},
+		{Tool: core.ToolNPM, Command: "tsc", Timestamp: now.Add(-30 * time.Minute), Duration: time.Minute, PackagesAffected: []string{"typescript"}},
+		{Tool: core.ToolNPM, Command: "old", Timestamp: now.Add(-30 * 24 * time.Hour), PackagesAffected: []string{"stale"}},
+	} {
+		addTestExecution(t, store, record)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showTop(topCommandForTest(t, "--once"), nil); err != nil {
+			t.Fatalf("showTop failed: %v", err)
+		}
+	})
+	if strings.Contains(output, "stale") {
+		t.Errorf("Expected executions outside the window to be excluded, got %q", output)
+	}
+	if strings.Index(output, "ripgrep") > strings.Index(output, "typescript") {
+		t.Errorf("Expected ripgrep ranked first by count, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := showTop(topCommandForTest(t, "--once", "--sort", "duration"), nil); err != nil {
+			t.Fatalf("showTop failed: %v", err)
+		}
+	})
+	if strings.Index(output, "typescript") > strings.Index(output, "ripgrep") {
+		t.Errorf("Expected typescript ranked first by duration, got %q", output)
+	}
+}
+
+func TestShowTopRejectsInvalidSort(t *testing.T) {
+	setupTestHomeConfig(t)
+	err := showTop(topCommandForTest(t, "--once", "--sort", "size"), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid sort") {
+		t.Fatalf("Expected invalid sort error, got %v", err)
+	}
+}
+
+func TestBuildTopRowsByTool(t *testing.T) {
+	now := time.Now()
+	rows := buildTopRows([]*core.ExecutionRecord{
+		{Tool: core.ToolNPM, Timestamp: now.Add(-time.Hour), Duration: time.Second},
+		{Tool: core.ToolNPM, Timestamp: now, Duration: 2 * time.Second},
+		{Tool: core.ToolGo, Timestamp: now.Add(-time.Minute)},
+	}, topByTool)
+	sortTopRows(rows, topSortLastUsed)
+
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Name != core.ToolNPM || rows[0].Count != 2 || rows[0].TotalDuration != 3*time.Second {
+		t.Errorf("Unexpected npm row: %+v", rows[0])
+	}
+	if !rows[0].LastUsed.Equal(now) {
+		t.Errorf("Expected npm last used %v, got %v", now, rows[0].LastUsed)
+	}
+}
+
+func pruneCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var tool, unused string
+	var apply, yes bool
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	cmd.Flags().StringVarP(&unused, "unused", "u", "90d", "unused")
+	cmd.Flags().BoolVar(&apply, "apply", false, "apply")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "yes")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestPrunePackagesPrintsUninstallCommands(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	config.Prune.Ignore = []string{"homebrew/git"}
+	if err := config.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	store := openTestStore(t, config)
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	for _, pkg := range []*core.PackageInfo{
+		{Tool: core.ToolHomebrew, Name: "wget", LastUsed: old},
+		{Tool: core.ToolHomebrew, Name: "git", LastUsed: old},
+		{Tool: core.ToolNPM, Name: "typescript", LastUsed: old},
+		{Tool: core.ToolNPM, Name: "eslint", LastUsed: time.Now()},
+		{Tool: core.ToolGem, Name: "rails", LastUsed: old},
+	} {
+		updateTestPackage(t, store, pkg)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := prunePackages(pruneCommandForTest(t, "--unused", "90d"), nil); err != nil {
+			t.Fatalf("prunePackages failed: %v", err)
+		}
+	})
+
+	for _, want := range []string{"brew uninstall wget", "npm uninstall -g typescript", "# rails (gem)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+	for _, unwanted := range []string{"uninstall git", "eslint"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("Did not expect %q in output, got %q", unwanted, output)
+		}
+	}
+}
+
+func TestPrunePackagesApplyCancelled(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "wget", LastUsed: time.Now().Add(-200 * 24 * time.Hour)})
+	closeTestStore(t, store)
+
+	withStdin(t, "n\n", func() {
+		_ = captureStdout(t, func() {
+			err := prunePackages(pruneCommandForTest(t, "--apply"), nil)
+			if err == nil || !strings.Contains(err.Error(), "prune cancelled") {
+				t.Errorf("Expected prune cancelled error, got %v", err)
+			}
+		})
+	})
+}
+
+func TestSnapshotAndDiff(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.6"})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "wget", Version: "1.21"})
+	closeTestStore(t, store)
+
+	_ = captureStdout(t, func() {
+		if err := createSnapshot(&command{}, []string{"before"}); err != nil {
+			t.Fatalf("createSnapshot failed: %v", err)
+		}
+	})
+	if err := createSnapshot(&command{}, []string{"before"}); err == nil {
+		t.Fatal("Expected duplicate snapshot name to fail")
+	}
+
+	store = openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.7"})
+	if err := store.DeletePackage(core.ToolHomebrew, "wget"); err != nil {
+		t.Fatalf("DeletePackage failed: %v", err)
+	}
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "typescript", Version: "5.4.0"})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := diffSnapshots(&command{}, []string{"before"}); err != nil {
+			t.Fatalf("diffSnapshots failed: %v", err)
+		}
+	})
+	for _, want := range []string{"+ npm/typescript 5.4.0", "- homebrew/wget 1.21", "~ homebrew/jq 1.6 -> 1.7"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in diff output, got %q", want, output)
+		}
+	}
+
+	output = captureStdout(t, func() {
+		if err := listSnapshots(&command{}, nil); err != nil {
+			t.Fatalf("listSnapshots failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "before") || !strings.Contains(output, "2 packages") {
+		t.Errorf("Expected snapshot listing, got %q", output)
+	}
+}
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, name := range []string{"pre-upgrade", "2026.10.01", "before_cleanup"} {
+		if err := validateSnapshotName(name); err != nil {
+			t.Errorf("Expected %q to be valid: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "current", "../escape", ".hidden", "a/b"} {
+		if err := validateSnapshotName(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestShowStatsHeatmapAndTimeline(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: now})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: now.AddDate(0, 0, -3)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--weekly", "--heatmap", "--timeline"), nil); err != nil {
+			t.Fatalf("showStats failed: %v", err)
+		}
+	})
+
+	for _, want := range []string{"Daily executions:", "2 executions over 8 days", "Activity by weekday and hour:", "Mon ", "Sun "} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+	if strings.Contains(output, "Tool usage:") {
+		t.Errorf("Expected chart views to replace the tool summary, got %q", output)
+	}
+}
+
+func TestRenderTimelineScalesBars(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	var executions []*core.ExecutionRecord
+	for i := 0; i < 8; i++ {
+		executions = append(executions, &core.ExecutionRecord{Timestamp: start})
+	}
+	executions = append(executions, &core.ExecutionRecord{Timestamp: start.AddDate(0, 0, 2)})
+
+	output := renderTimeline(executions, start, start.AddDate(0, 0, 2))
+	if !strings.Contains(output, "2026-03-01 █ ▁ 2026-03-03") {
+		t.Errorf("Unexpected sparkline: %q", output)
+	}
+	if !strings.Contains(output, "9 executions over 3 days, peak 8 per day") {
+		t.Errorf("Unexpected timeline summary: %q", output)
+	}
+}
+
+func TestShowStatsByDimension(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: core.ToolNPM, Command: "npm i", Timestamp: now.Add(-time.Hour), WorkingDir: "/src/web", User: "ana"},
+		{Tool: core.ToolNPM, Command: "npm i", Timestamp: now, WorkingDir: "/src/web", User: "ana"},
+		{Tool: core.ToolGo, Command: "go build", Timestamp: now, WorkingDir: "/src/api", User: "ben"},
+	} {
+		addTestExecution(t, store, record)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--by", "project"), nil); err != nil {
+			t.Fatalf("showStats failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Executions by project:") {
+		t.Fatalf("Expected project heading, got %q", output)
+	}
+	if !regexp.MustCompile(`web\s+2\s+66\.7%`).MatchString(output) || !regexp.MustCompile(`api\s+1\s+33\.3%`).MatchString(output) {
+		t.Errorf("Unexpected project counts: %q", output)
+	}
+
+	_ = captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--by", "color"), nil); err == nil {
+			t.Error("Expected invalid --by value to fail")
+		}
+	})
+}
+
+func TestShowStatsFiltersByProject(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: core.ToolNPM, Command: "npm i react", Timestamp: now, WorkingDir: "/src/web", ProjectName: "@acme/web", PackagesAffected: []string{"react"}},
+		{Tool: core.ToolNPM, Command: "npm i react", Timestamp: now, WorkingDir: "/src/web/app", ProjectName: "@acme/web", PackagesAffected: []string{"react"}},
+		{Tool: core.ToolGo, Command: "go get cobra", Timestamp: now, WorkingDir: "/src/api", ProjectName: "example.com/api", PackagesAffected: []string{"cobra"}},
+	} {
+		addTestExecution(t, store, record)
+	}
+	closeTestStore(t, store)
+
+	var report statsReport
+	output := captureStdout(t, func() {
+		if err := showStats(withGlobalJSON(statsCommandForTest(t, "--project", "@acme/web")), nil); err != nil {
+			t.Fatalf("showStats failed: %v", err)
+		}
+	})
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Failed to parse stats JSON %q: %v", output, err)
+	}
+	if report.TotalExecutions != 2 || report.Tools[core.ToolNPM] != 2 || report.Tools[core.ToolGo] != 0 {
+		t.Errorf("Unexpected project stats: %+v", report)
+	}
+	if len(report.TopPackages) != 1 || report.TopPackages[0].Name != "react" || report.TopPackages[0].UsageCount != 2 {
+		t.Errorf("Expected top packages from the project's executions, got %+v", report.TopPackages)
+	}
+
+	output = captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--project", "example.com/api", "--columns", "tool,project"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`go\s+example\.com/api`).MatchString(output) || strings.Contains(output, "@acme/web") {
+		t.Errorf("Unexpected project query: %q", output)
+	}
+}
+
+func TestQueryExecutionsBySessionOldestFirst(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: core.ToolNPM, Command: "npm test", Timestamp: now.Add(-time.Minute), SessionID: "100-1"},
+		{Tool: core.ToolNPM, Command: "npm install", Timestamp: now.Add(-2 * time.Minute), SessionID: "100-1"},
+		{Tool: core.ToolGo, Command: "go build", Timestamp: now, SessionID: "200-1"},
+	} {
+		addTestExecution(t, store, record)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--session", "100-1", "--columns", "session,command"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`(?s)100-1\s+npm install.*100-1\s+npm test`).MatchString(output) || strings.Contains(output, "go build") {
+		t.Errorf("Expected the session's commands oldest first, got %q", output)
+	}
+}
+
+func TestGroupExecutionsByWeekday(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	buckets, err := groupExecutionsBy([]*core.ExecutionRecord{
+		{Timestamp: monday},
+		{Timestamp: monday.AddDate(0, 0, 6)},
+		{Timestamp: monday.AddDate(0, 0, 7)},
+	}, statsByWeekday)
+	if err != nil {
+		t.Fatalf("groupExecutionsBy failed: %v", err)
+	}
+	if len(buckets) != 7 || buckets[0].Key != "Monday" || buckets[0].Count != 2 || buckets[6].Key != "Sunday" || buckets[6].Count != 1 {
+		t.Errorf("Unexpected weekday buckets: %+v", buckets)
+	}
+}
+
+func TestGroupExecutionsByHour(t *testing.T) {
+	morning := time.Date(2026, 3, 2, 9, 15, 0, 0, time.Local)
+	buckets, err := groupExecutionsBy([]*core.ExecutionRecord{
+		{Timestamp: morning},
+		{Timestamp: morning.Add(30 * time.Minute)},
+		{Timestamp: morning.Add(14 * time.Hour)},
+	}, statsByHour)
+	if err != nil {
+		t.Fatalf("groupExecutionsBy failed: %v", err)
+	}
+	if len(buckets) != 24 || buckets[0].Key != "00" || buckets[9].Count != 2 || buckets[23].Key != "23" || buckets[23].Count != 1 {
+		t.Errorf("Unexpected hour buckets: %+v", buckets)
+	}
+}
+
+func TestGroupExecutionsByRepo(t *testing.T) {
+	buckets, err := groupExecutionsBy([]*core.ExecutionRecord{
+		{Metadata: map[string]interface{}{gitinfo.MetadataRoot: "/src/diu", gitinfo.MetadataRemote: "yowainwright/diu"}},
+		{Metadata: map[string]interface{}{gitinfo.MetadataRoot: "/src/diu", gitinfo.MetadataRemote: "yowainwright/diu"}},
+		{Metadata: map[string]interface{}{gitinfo.MetadataRoot: "/src/scratch"}},
+		{},
+	}, statsByRepo)
+	if err != nil {
+		t.Fatalf("groupExecutionsBy failed: %v", err)
+	}
+	want := []statsBucket{{Key: "yowainwright/diu", Count: 2}, {Key: statsUnknownKey, Count: 1}, {Key: "scratch", Count: 1}}
+	if !slices.Equal(buckets, want) {
+		t.Errorf("Unexpected repo buckets: %+v", buckets)
+	}
+}
+
+func TestQueryExecutionsColumns(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool:      core.ToolNPM,
+		Command:   "npm install -g " + strings.Repeat("x", 80),
+		Timestamp: time.Now().Add(-2 * time.Hour),
+		Duration:  1500 * time.Millisecond,
+		ExitCode:  1,
+	})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--columns", "tool,ago,duration,exit,command"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`npm\s+2h ago\s+1\.5s\s+1\s+npm install -g x+…`).MatchString(output) {
+		t.Errorf("Unexpected query table: %q", output)
+	}
+	if strings.Contains(output, "TIME") {
+		t.Errorf("Expected unselected columns to be hidden, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--wide"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, strings.Repeat("x", 80)) {
+		t.Errorf("Expected --wide to keep the full command, got %q", output)
+	}
+
+	if err := queryExecutions(queryCommandForTest(t, "--columns", "tool,size"), nil); err == nil {
+		t.Error("Expected unknown column to fail")
+	}
+}
+
+func TestQueryExecutionsFailedOnly(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install ok", Timestamp: time.Now()})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install broken", Timestamp: time.Now(), ExitCode: 1,
+		Output: &core.Output{Stderr: "npm ERR! code E404\nnpm ERR! 404 Not Found - broken"}})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--failed"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "npm install broken") || strings.Contains(output, "npm install ok") {
+		t.Errorf("Expected only the failed execution, got %q", output)
+	}
+	if !strings.Contains(output, "stderr | npm ERR! 404 Not Found - broken") {
+		t.Errorf("Expected the failed execution's captured output, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--exit-code", "0"), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "npm install ok") || strings.Contains(output, "npm install broken") {
+		t.Errorf("Expected only the successful execution, got %q", output)
+	}
+}
+
+func TestQueryExecutionsByDirAndProject(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	repo := filepath.Join(t.TempDir(), "app")
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install inside", WorkingDir: repo, Timestamp: time.Now()})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install outside", WorkingDir: filepath.Dir(repo), Timestamp: time.Now()})
+	closeTestStore(t, store)
+
+	for _, args := range [][]string{{"--dir", repo}, {"--project", "app"}} {
+		output := captureStdout(t, func() {
+			if err := queryExecutions(queryCommandForTest(t, args...), nil); err != nil {
+				t.Fatalf("queryExecutions failed: %v", err)
+			}
+		})
+		if !strings.Contains(output, "npm install inside") || strings.Contains(output, "npm install outside") {
+			t.Errorf("%v: expected only the execution inside the repo, got %q", args, output)
+		}
+	}
+}
+
+func TestQueryExecutionsCommandMatch(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install typescript", Timestamp: time.Now()})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm run lint", Timestamp: time.Now()})
+	closeTestStore(t, store)
+
+	for _, args := range [][]string{{"--match", "install .*script"}, {"--glob", "npm install *"}} {
+		output := captureStdout(t, func() {
+			if err := queryExecutions(queryCommandForTest(t, args...), nil); err != nil {
+				t.Fatalf("queryExecutions failed: %v", err)
+			}
+		})
+		if !strings.Contains(output, "npm install typescript") || strings.Contains(output, "npm run lint") {
+			t.Errorf("%v: expected only the install, got %q", args, output)
+		}
+	}
+
+	if err := queryExecutions(queryCommandForTest(t, "--match", "("), nil); err == nil {
+		t.Error("Expected an invalid --match pattern to fail")
+	}
+	if err := queryExecutions(queryCommandForTest(t, "--match", "a", "--glob", "b"), nil); err == nil {
+		t.Error("Expected --match with --glob to fail")
+	}
+}
+
+func TestQueryExecutionsNDJSON(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install a", Timestamp: time.Now().Add(-time.Minute)})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install b", Timestamp: time.Now()})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := queryExecutions(queryCommandForTest(t, "--format", formatNDJSON), nil); err != nil {
+			t.Fatalf("queryExecutions failed: %v", err)
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d: %q", len(lines), output)
+	}
+	var record core.ExecutionRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Invalid NDJSON line %q: %v", lines[0], err)
+	}
+	if record.Command != "npm install b" {
+		t.Errorf("Expected newest execution first, got %q", record.Command)
+	}
+}
+
+func TestGlobalJSONOutput(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install eslint", PackagesAffected: []string{"eslint"}, Timestamp: time.Now()})
+	closeTestStore(t, store)
+
+	tests := []struct {
+		name   string
+		run    func() error
+		target interface{}
+	}{
+		{"daemon status", func() error { return daemonStatus(withGlobalJSON(&command{}), nil) }, &daemonStatusReport{}},
+		{"stats", func() error { return showStats(withGlobalJSON(statsCommandForTest(t)), nil) }, &statsReport{}},
+		{"stats by", func() error { return showStats(withGlobalJSON(statsCommandForTest(t, "--by", "tool")), nil) }, &statsReport{}},
+		{"packages", func() error { return listPackages(withGlobalJSON(packagesCommandForTest(t)), nil) }, &[]*core.PackageInfo{}},
+		{"query", func() error { return queryExecutions(withGlobalJSON(queryCommandForTest(t)), nil) }, &[]*core.ExecutionRecord{}},
+		{"cleanup", func() error { return cleanup(withGlobalJSON(&command{}), nil) }, &cleanupReport{}},
+	}
+	for _, tt := range tests {
+		output := captureStdout(t, func() {
+			if err := tt.run(); err != nil {
+				t.Fatalf("%s failed: %v", tt.name, err)
+			}
+		})
+		if err := json.Unmarshal([]byte(output), tt.target); err != nil {
+			t.Errorf("%s: expected JSON output, got %q: %v", tt.name, output, err)
+		}
+	}
+
+	output := captureStdout(t, func() {
+		if err := showStats(withGlobalJSON(statsCommandForTest(t)), nil); err != nil {
+			t.Fatalf("showStats failed: %v", err)
+		}
+	})
+	var report statsReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid stats JSON: %v", err)
+	}
+	if report.TotalExecutions != 1 || report.Tools[core.ToolNPM] != 1 || len(report.TopPackages) != 1 {
+		t.Errorf("unexpected stats report: %+v", report)
+	}
+}
+
+func TestValidateConfigCommand(t *testing.T) {
+	config := setupTestHomeConfig(t)
+
+	output := captureStdout(t, func() {
+		if err := validateConfig(&command{}, nil); err != nil {
+			t.Fatalf("validateConfig failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "is valid") {
+		t.Errorf("Expected valid config, got %q", output)
+	}
+
+	config.Storage.Backend = "sqlite"
+	if err := config.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	output = captureStdout(t, func() {
+		if err := validateConfig(withGlobalJSON(&command{}), nil); err == nil {
+			t.Fatal("Expected an invalid backend to fail validation")
+		}
+	})
+	var report configValidationReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", output, err)
+	}
+	if report.Valid || len(report.Issues) != 1 || report.Issues[0].Key != "storage.backend" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestCompleteTrackedToolsAndPackages(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install -g eslint", PackagesAffected: []string{"eslint"}, Timestamp: time.Now()})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolPip, Command: "pip install black", PackagesAffected: []string{"black"}, Timestamp: time.Now()})
+	closeTestStore(t, store)
+
+	tools := completeTools(&command{}, nil, "")
+	if !slices.Contains(tools, core.ToolNPM) || !slices.Contains(tools, core.ToolPip) {
+		t.Errorf("Expected tracked tools, got %v", tools)
+	}
+
+	packages := completePackages(packagesCommandForTest(t, "--tool", core.ToolPip), nil, "")
+	if len(packages) != 1 || packages[0] != "black" {
+		t.Errorf("Expected only pip packages, got %v", packages)
+	}
+}
+
+func whyCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var tool string
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestExplainPackageShowsVersionHistory(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.6"})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "wget", Version: "1.21"})
+	now := time.Now()
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool: core.ToolHomebrew, Command: "brew upgrade jq", Timestamp: now.Add(-time.Hour),
+		Metadata: map[string]interface{}{core.MetadataVersionChanges: []core.VersionChange{{Package: "jq", From: "1.6", To: "1.7"}}},
+	})
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool: core.ToolHomebrew, Command: "brew upgrade", Timestamp: now,
+		Metadata: map[string]interface{}{core.MetadataVersionChanges: []core.VersionChange{{Package: "jq", From: "1.7", To: "1.7.1"}}},
+	})
+	pkg, err := store.GetPackage(core.ToolHomebrew, "jq")
+	closeTestStore(t, store)
+	if err != nil {
+		t.Fatalf("GetPackage failed: %v", err)
+	}
+	if pkg.Version != "1.7.1" {
+		t.Errorf("Expected the package version to follow the last upgrade, got %q", pkg.Version)
+	}
+
+	output := captureStdout(t, func() {
+		if err := explainPackage(whyCommandForTest(t), []string{"jq"}); err != nil {
+			t.Fatalf("explainPackage failed: %v", err)
+		}
+	})
+	first, second := strings.Index(output, "1.6 -> 1.7 "), strings.Index(output, "1.7 -> 1.7.1")
+	if !strings.Contains(output, "Version history") || first < 0 || second < first {
+		t.Errorf("Expected both upgrades oldest first, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := explainPackage(whyCommandForTest(t), []string{"wget"}); err != nil {
+			t.Fatalf("explainPackage failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "No recorded installs or upgrades") {
+		t.Errorf("Expected no history for wget, got %q", output)
+	}
+
+	if err := explainPackage(whyCommandForTest(t), []string{"fd"}); err == nil || !strings.Contains(err.Error(), "not tracked") {
+		t.Errorf("Expected an untracked package error, got %v", err)
+	}
+}
+
+func sbomCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var format, out, tool string
+	cmd.Flags().StringVarP(&format, "format", "f", formatCycloneDX, "format")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "out")
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestGenerateSBOM(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.7.1", License: "MIT"})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "@scope/tool", Version: "2.0.0+build"})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolPip, Name: "Django_Rest", Version: "3.15"})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := generateSBOM(sbomCommandForTest(t), nil); err != nil {
+			t.Fatalf("generateSBOM failed: %v", err)
+		}
+	})
+	var bom cycloneDXBOM
+	if err := json.Unmarshal([]byte(output), &bom); err != nil {
+		t.Fatalf("Invalid CycloneDX output: %v\n%s", err, output)
+	}
+	if bom.BOMFormat != "CycloneDX" || !strings.HasPrefix(bom.SerialNumber, "urn:uuid:") || len(bom.Components) != 3 {
+		t.Fatalf("Unexpected CycloneDX document: %+v", bom)
+	}
+	wantPURLs := []string{"pkg:generic/jq@1.7.1", "pkg:npm/%40scope/tool@2.0.0%2Bbuild", "pkg:pypi/django-rest@3.15"}
+	for i, want := range wantPURLs {
+		if bom.Components[i].PURL != want {
+			t.Errorf("Component %d purl = %q, want %q", i, bom.Components[i].PURL, want)
+		}
+	}
+	if licenses := bom.Components[0].Licenses; len(licenses) != 1 || licenses[0].Expression != "MIT" {
+		t.Errorf("Expected jq's license in the BOM, got %+v", licenses)
+	}
+
+	out := filepath.Join(t.TempDir(), "sbom.spdx.json")
+	_ = captureStdout(t, func() {
+		if err := generateSBOM(sbomCommandForTest(t, "--format", "spdx", "--tool", "npm", "--out", out), nil); err != nil {
+			t.Fatalf("generateSBOM spdx failed: %v", err)
+		}
+	})
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read SBOM: %v", err)
+	}
+	var document spdxDocument
+	if err := json.Unmarshal(raw, &document); err != nil {
+		t.Fatalf("Invalid SPDX output: %v", err)
+	}
+	if document.SPDXVersion != spdxVersion || len(document.Packages) != 1 || len(document.Relationships) != 1 {
+		t.Fatalf("Unexpected SPDX document: %+v", document)
+	}
+	if pkg := document.Packages[0]; pkg.Name != "@scope/tool" || pkg.VersionInfo != "2.0.0+build" || pkg.LicenseDeclared != spdxNoAssertion || pkg.ExternalRefs[0].ReferenceLocator != wantPURLs[1] {
+		t.Errorf("Unexpected SPDX package: %+v", pkg)
+	}
+
+	if err := generateSBOM(sbomCommandForTest(t, "--format", "xml"), nil); err == nil {
+		t.Error("Expected an unsupported format error")
+	}
+}
+
+func auditCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var tool string
+	var refresh, asJSON bool
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "refresh")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "json")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestAuditPackagesListsRecentlyUsedFirst(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/querybatch" {
+			var request struct {
+				Queries []struct {
+					Package struct{ Name string } `json:"package"`
+				} `json:"queries"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			results := make([]map[string]interface{}, len(request.Queries))
+			for i, q := range request.Queries {
+				results[i] = map[string]interface{}{"vulns": []map[string]string{{"id": "OSV-" + q.Package.Name}}}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/v1/vulns/")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "summary": "Problem in " + strings.TrimPrefix(id, "OSV-")})
+	}))
+	defer server.Close()
+
+	config := setupTestHomeConfig(t)
+	config.Audit.OSVURL = server.URL
+	if err := config.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "lodash", Version: "4.17.20", LastUsed: time.Now().Add(-48 * time.Hour)})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolPip, Name: "requests", Version: "2.19.0", LastUsed: time.Now()})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.7"})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := auditPackages(auditCommandForTest(t), nil); err != nil {
+			t.Fatalf("auditPackages failed: %v", err)
+		}
+	})
+	requests, lodash := strings.Index(output, "OSV-requests"), strings.Index(output, "OSV-lodash")
+	if requests < 0 || lodash < requests {
+		t.Errorf("Expected the most recently used package first, got %q", output)
+	}
+	for _, want := range []string{"Skipped 1 packages", "2 vulnerabilities in 2 of 2 packages checked"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+
+	output = captureStdout(t, func() {
+		if err := auditPackages(auditCommandForTest(t, "--json", "--tool", "npm"), nil); err != nil {
+			t.Fatalf("auditPackages --json failed: %v", err)
+		}
+	})
+	var report auditReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+	if report.Checked != 1 || len(report.Findings) != 1 || report.Findings[0].Vulnerabilities[0].Summary != "Problem in lodash" {
+		t.Errorf("Unexpected audit report: %+v", report)
+	}
+}
+
+func outdatedCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var tool string
+	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func TestListOutdatedMostUsedFirst(t *testing.T) {
+	prependFakeCommand(t, "npm", `#!/bin/sh
+if [ "$1" = "outdated" ]; then
+  printf '%s\n' '{"eslint":{"current":"8.0.0","latest":"9.1.0"},"typescript":{"current":"5.3.3","latest":"5.4.2"},"rimraf":{"current":"3.0.2","latest":"5.0.5"}}'
+  exit 1
+fi
+printf '/usr/local\n'
+`)
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "typescript", UsageCount: 40, LastUsed: time.Now()})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolNPM, Name: "eslint", UsageCount: 2, LastUsed: time.Now().Add(-time.Hour)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := listOutdated(outdatedCommandForTest(t, "--tool", "npm"), nil); err != nil {
+			t.Fatalf("listOutdated failed: %v", err)
+		}
+	})
+	typescript, eslint, rimraf := strings.Index(output, "typescript"), strings.Index(output, "eslint"), strings.Index(output, "rimraf")
+	if typescript < 0 || eslint < typescript || rimraf < eslint {
+		t.Errorf("Expected outdated packages ordered by use, got %q", output)
+	}
+	if !strings.Contains(output, "5.4.2") || !strings.Contains(output, "never") {
+		t.Errorf("Expected latest versions and unused packages in output, got %q", output)
+	}
+}
+
+func TestCompareInstalled(t *testing.T) {
+	now := time.Now()
+	installed := []*core.PackageInfo{
+		{Tool: core.ToolNPM, Name: "typescript", Version: "5.5.4"},
+		{Tool: core.ToolNPM, Name: "yo", Version: "4.3.1"},
+		{Tool: homebrewCaskTool, Name: "vlc", Version: "3.0.21"},
+	}
+	stored := []*core.PackageInfo{
+		{Tool: core.ToolNPM, Name: "typescript", UsageCount: 12, LastUsed: now.Add(-time.Hour)},
+		{Tool: core.ToolHomebrew, Name: "vlc", UsageCount: 1, LastUsed: now.Add(-2 * time.Hour)},
+		{Tool: core.ToolNPM, Name: "yo", UsageCount: 3, LastUsed: now.Add(-200 * 24 * time.Hour)},
+	}
+	executions := []*core.ExecutionRecord{
+		{Tool: core.ToolNPM, PackagesAffected: []string{"create-vite"}, Timestamp: now.Add(-3 * time.Hour), Metadata: map[string]interface{}{"action": "exec", "ephemeral": true}},
+		{Tool: core.ToolNPM, PackagesAffected: []string{"create-vite"}, Timestamp: now.Add(-time.Hour), Metadata: map[string]interface{}{"action": "exec", "ephemeral": true}},
+		{Tool: core.ToolNPM, PackagesAffected: []string{"lodash"}, Timestamp: now, Metadata: map[string]interface{}{"action": "install", "global": false}},
+		{Tool: core.ToolNPM, PackagesAffected: []string{"rimraf"}, Timestamp: now.Add(-2 * time.Hour), Metadata: map[string]interface{}{"action": "install", "global": true}},
+		{Tool: core.ToolNPM, PackagesAffected: []string{"rimraf"}, Timestamp: now.Add(-time.Hour), Metadata: map[string]interface{}{"action": "uninstall", "global": true}},
+		{Tool: core.ToolHomebrew, PackagesAffected: []string{"jq"}, Timestamp: now, Metadata: map[string]interface{}{}},
+		{Tool: core.ToolPip, PackagesAffected: []string{"requests"}, Timestamp: now, Metadata: map[string]interface{}{"action": "install"}},
+	}
+
+	report := compareInstalled(installed, []string{core.ToolNPM, core.ToolHomebrew}, stored, executions, now.Add(-90*24*time.Hour))
+	names := func(packages []driftPackage) []string {
+		var got []string
+		for _, pkg := range packages {
+			got = append(got, pkg.Tool+"/"+pkg.Name)
+		}
+		return got
+	}
+	if got := names(report.InstalledUsed); !slices.Equal(got, []string{"npm/typescript", "homebrew-cask/vlc"}) {
+		t.Errorf("InstalledUsed = %v", got)
+	}
+	if got := names(report.InstalledUnused); !slices.Equal(got, []string{"npm/yo"}) {
+		t.Errorf("InstalledUnused = %v", got)
+	}
+	if got := names(report.UsedNotInstalled); !slices.Equal(got, []string{"npm/create-vite", "homebrew/jq"}) {
+		t.Errorf("UsedNotInstalled = %v", got)
+	}
+	if len(report.UsedNotInstalled) > 0 && report.UsedNotInstalled[0].EphemeralRuns != 2 {
+		t.Errorf("Expected two ephemeral runs of create-vite, got %+v", report.UsedNotInstalled[0])
+	}
+}
+
+func TestShowStatsUpgrades(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	day := 24 * time.Hour
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", InstallDate: now.Add(-730 * day)})
+	updateTestPackage(t, store, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "ripgrep", InstallDate: now.Add(-730 * day)})
+	for i := 0; i < 20; i++ {
+		addTestExecution(t, store, &core.ExecutionRecord{
+			Tool: core.ToolHomebrew, Command: "jq .", Timestamp: now.Add(-time.Duration(i) * day), PackagesAffected: []string{"jq"},
+		})
+	}
+	for _, daysAgo := range []int{90, 60, 30} {
+		addTestExecution(t, store, &core.ExecutionRecord{
+			Tool: core.ToolHomebrew, Command: "brew upgrade ripgrep", Timestamp: now.Add(-time.Duration(daysAgo) * day),
+			PackagesAffected: []string{"ripgrep"}, Metadata: map[string]interface{}{"subcommand": "upgrade"},
+		})
+	}
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool: core.ToolHomebrew, Command: "rg TODO", Timestamp: now.Add(-2 * day), PackagesAffected: []string{"ripgrep"},
+	})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--upgrades"), nil); err != nil {
+			t.Fatalf("showStats --upgrades failed: %v", err)
+		}
+	})
+	for _, want := range []string{"Upgrade Cadence", "1mo", "Used daily but not upgraded in a year:", "jq (homebrew) - used 20 of the last 30 days, installed 2.0y ago"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+	if strings.Contains(output, "ripgrep (homebrew)") {
+		t.Errorf("Did not expect recently upgraded ripgrep to be flagged, got %q", output)
+	}
+}
+
+func TestShowStatsTime(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for i, seconds := range []int{1, 2, 3, 60} {
+		addTestExecution(t, store, &core.ExecutionRecord{
+			Tool: core.ToolNPM, Command: "npm install", Timestamp: now.Add(-time.Duration(i+1) * time.Hour),
+			Duration: time.Duration(seconds) * time.Second, PackagesAffected: []string{"typescript"},
+			Metadata: map[string]interface{}{"subcommand": "install"},
+		})
+	}
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool: core.ToolHomebrew, Command: "brew upgrade", Timestamp: now.Add(-10 * time.Hour),
+		Duration: 2 * time.Hour, Metadata: map[string]interface{}{"subcommand": "upgrade"},
+	})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: now.Add(-11 * time.Hour)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--time", "--daily"), nil); err != nil {
+			t.Fatalf("showStats --time failed: %v", err)
+		}
+	})
+	for _, want := range []string{
+		"Time Spent Waiting (Last 24 Hours)", "Total time waiting: 2.0h across 5 executions",
+		"1 executions have no recorded duration", "homebrew upgrade", "npm/typescript",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+	if !regexp.MustCompile(`npm install\s+4\s+1m6s\s+2s\s+1m0s`).MatchString(output) {
+		t.Errorf("Expected npm install p50 of 2s and p95 of 1m0s, got %q", output)
+	}
+}
+
+func TestShowStatsActions(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for i, subcommand := range []string{"install", "i", "install", "run", "audit"} {
+		metadata := map[string]interface{}{"subcommand": subcommand}
+		switch subcommand {
+		case "install", "i":
+			metadata["action"] = "install"
+		case "audit":
+			metadata["action"] = "audit"
+		}
+		addTestExecution(t, store, &core.ExecutionRecord{
+			Tool: core.ToolNPM, Command: "npm " + subcommand, Timestamp: now.Add(-time.Duration(i+1) * time.Hour), Metadata: metadata,
+		})
+	}
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolGo, Command: "go build", Args: []string{"build"}, Timestamp: now.Add(-time.Hour)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--actions", "--tool", "npm"), nil); err != nil {
+			t.Fatalf("showStats --actions failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`(?s)npm: 5 executions.*install\s+3\s+60\.0%.*audit\s+1\s+20\.0%.*run\s+1\s+20\.0%`).MatchString(output) {
+		t.Errorf("Expected npm's actions by share, got %q", output)
+	}
+	if strings.Contains(output, "go:") {
+		t.Errorf("Expected only npm's actions, got %q", output)
+	}
+
+	tools := countActions([]*core.ExecutionRecord{{Tool: core.ToolGo, Args: []string{"build"}}, {Tool: core.ToolGo}})
+	want := []actionBucket{{Action: statsUnknownKey, Count: 1, Percent: 50}, {Action: "build", Count: 1, Percent: 50}}
+	if len(tools) != 1 || !slices.Equal(tools[0].Actions, want) {
+		t.Errorf("Expected actions from arguments when metadata has none, got %+v", tools)
+	}
+}
+
+func TestShowStatsScripts(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	web := filepath.Join(t.TempDir(), "web")
+	if err := os.MkdirAll(web, core.OwnerDirectoryMode); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	manifest := `{"name": "web", "scripts": {"build": "vite build", "dev": "vite", "lint": "eslint ."}}`
+	if err := os.WriteFile(filepath.Join(web, "package.json"), []byte(manifest), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	store := openTestStore(t, config)
+	now := time.Now()
+	for i, script := range []string{"dev", "dev", "build"} {
+		addTestExecution(t, store, &core.ExecutionRecord{
+			Tool: core.ToolPNPM, Command: "pnpm " + script, Args: []string{script}, ProjectName: "web", WorkingDir: web,
+			Timestamp: now.Add(-time.Duration(i+1) * time.Hour),
+			Metadata:  map[string]interface{}{"action": "run", "script": script, project.MetadataScriptRoot: web},
+		})
+	}
+	addTestExecution(t, store, &core.ExecutionRecord{
+		Tool: core.ToolNPM, Command: "npm run test", Args: []string{"run", "test"}, ProjectName: "api", Timestamp: now.Add(-time.Hour),
+		Metadata: map[string]interface{}{"script": "test"},
+	})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install", Args: []string{"install"}, Timestamp: now.Add(-time.Hour)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--scripts"), nil); err != nil {
+			t.Fatalf("showStats --scripts failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`(?s)web \(.*\): 3 runs.*dev\s+2\s+1h ago.*build\s+1.*Never run: lint.*api: 1 runs.*test\s+1`).MatchString(output) {
+		t.Errorf("Expected script runs per project with the never-run scripts, got %q", output)
+	}
+
+	projects := countScripts([]*core.ExecutionRecord{{Tool: core.ToolNPM, Metadata: map[string]interface{}{"action": "install"}}})
+	if len(projects) != 0 {
+		t.Errorf("Expected executions without a script to be skipped, got %+v", projects)
+	}
+}
+
+func TestGenerateBrewfile(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, pkg := range []*core.PackageInfo{
+		{Name: "ripgrep", Tool: core.ToolHomebrew, LastUsed: now.Add(-24 * time.Hour)},
+		{Name: "hashicorp/tap/terraform", Tool: core.ToolHomebrew, LastUsed: now.Add(-30 * 24 * time.Hour)},
+		{Name: "imagemagick", Tool: core.ToolHomebrew, LastUsed: now.Add(-400 * 24 * time.Hour)},
+		{Name: "wget", Tool: core.ToolHomebrew},
+		{Name: "visual-studio-code", Tool: homebrewCaskTool, LastUsed: now.Add(-2 * time.Hour)},
+		{Name: "typescript", Tool: core.ToolNPM, LastUsed: now},
+	} {
+		updateTestPackage(t, store, pkg)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := generateBrewfile(generateCommandForTest(t), nil); err != nil {
+			t.Fatalf("generateBrewfile failed: %v", err)
+		}
+	})
+	body := output[strings.Index(output, "\n")+1:]
+	want := "\ntap \"hashicorp/tap\"\n\nbrew \"hashicorp/tap/terraform\"\nbrew \"ripgrep\"\n\ncask \"visual-studio-code\"\n"
+	if !strings.HasPrefix(output, "# Generated by diu") || body != want {
+		t.Errorf("Expected a Brewfile of the recently used formulae and casks, got %q", output)
+	}
+
+	out := filepath.Join(t.TempDir(), "Brewfile")
+	captureStdout(t, func() {
+		if err := generateBrewfile(generateCommandForTest(t, "--used-within", "7d", "--out", out), nil); err != nil {
+			t.Fatalf("generateBrewfile --out failed: %v", err)
+		}
+	})
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read Brewfile: %v", err)
+	}
+	if strings.Contains(string(data), "terraform") || !strings.Contains(string(data), `brew "ripgrep"`) {
+		t.Errorf("Expected only packages used within 7d, got %q", data)
+	}
+
+	if err := generateBrewfile(generateCommandForTest(t, "--used-within", "soon"), nil); err == nil {
+		t.Error("Expected an invalid --used-within to fail")
+	}
+}
+
+func TestGenerateNPMGlobals(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, pkg := range []*core.PackageInfo{
+		{Name: "typescript", Version: "5.5.4", Tool: core.ToolNPM, LastUsed: now.Add(-time.Hour)},
+		{Name: "@antfu/ni", Version: "0.22.0", Tool: core.ToolNPM, LastUsed: now.Add(-10 * 24 * time.Hour)},
+		{Name: "npm", Version: "10.8.2", Tool: core.ToolNPM, LastUsed: now},
+		{Name: "yo", Version: "4.3.1", Tool: core.ToolNPM, LastUsed: now.Add(-300 * 24 * time.Hour)},
+		{Name: "tsx", Tool: core.ToolPNPM, LastUsed: now},
+	} {
+		updateTestPackage(t, store, pkg)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := generateNPMGlobals(generateCommandForTest(t), nil); err != nil {
+			t.Fatalf("generateNPMGlobals failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(output, "#!/bin/sh\n# Generated by diu") || !strings.HasSuffix(output, "\nnpm install -g @antfu/ni typescript\n") {
+		t.Errorf("Expected a script installing the recently used globals, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := generateNPMGlobals(generateCommandForTest(t, "--format", "list", "--pin"), nil); err != nil {
+			t.Fatalf("generateNPMGlobals --format list failed: %v", err)
+		}
+	})
+	if output != "@antfu/ni@0.22.0\ntypescript@5.5.4\n" {
+		t.Errorf("Expected a pinned list, got %q", output)
+	}
+
+	if err := generateNPMGlobals(generateCommandForTest(t, "--format", "json"), nil); err == nil {
+		t.Error("Expected an unknown format to fail")
+	}
+}
+
+func TestGeneratePythonTools(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	for _, pkg := range []*core.PackageInfo{
+		{Name: "ruff", Version: "0.6.9", Tool: core.ToolUV, LastUsed: now.Add(-time.Hour)},
+		{Name: "black", Version: "24.8.0", Tool: core.ToolUV, LastUsed: now.Add(-20 * 24 * time.Hour)},
+		{Name: "httpie", Version: "3.2.3", Tool: core.ToolUV, LastUsed: now.Add(-400 * 24 * time.Hour)},
+		{Name: "requests", Tool: core.ToolPip, LastUsed: now},
+	} {
+		updateTestPackage(t, store, pkg)
+	}
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := generatePythonTools(generateCommandForTest(t), nil); err != nil {
+			t.Fatalf("generatePythonTools failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(output, "#!/bin/sh\n# Generated by diu") || !strings.HasSuffix(output, "\nset -e\nuv tool install black\nuv tool install ruff\n") {
+		t.Errorf("Expected uv tool install lines for the recently used tools, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := generatePythonTools(generateCommandForTest(t, "--installer", "pipx", "--pin"), nil); err != nil {
+			t.Fatalf("generatePythonTools --installer pipx failed: %v", err)
+		}
+	})
+	if !strings.HasSuffix(output, "\npipx install black==24.8.0\npipx install ruff==0.6.9\n") {
+		t.Errorf("Expected pinned pipx install lines, got %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := generatePythonTools(generateCommandForTest(t, "--format", "list", "--used-within", "7d"), nil); err != nil {
+			t.Fatalf("generatePythonTools --format list failed: %v", err)
+		}
+	})
+	if output != "ruff\n" {
+		t.Errorf("Expected a list of the tools used this week, got %q", output)
+	}
+
+	if err := generatePythonTools(generateCommandForTest(t, "--installer", "pip"), nil); err == nil {
+		t.Error("Expected an unknown installer to fail")
+	}
+}
+
+func TestGenerateXbar(t *testing.T) {
+	setupTestHomeConfig(t)
+	out := filepath.Join(t.TempDir(), xbarPluginName)
+
+	captureStdout(t, func() {
+		if err := generateXbar(generateCommandForTest(t, "--out", out), nil); err != nil {
+			t.Fatalf("generateXbar failed: %v", err)
+		}
+	})
+	info, err := os.Stat(out)
+	if err != nil {
+		t.Fatalf("Expected the plugin written: %v", err)
+	}
+	if info.Mode().Perm()&core.ExecutableModeMask == 0 {
+		t.Errorf("Expected the plugin executable, got mode %v", info.Mode())
+	}
+	plugin, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(string(plugin), "URL='http://127.0.0.1:8081/api/v1/summary'\n") || !strings.Contains(string(plugin), "<xbar.title>diu</xbar.title>") {
+		t.Errorf("Expected the plugin to poll the summary endpoint, got %s", plugin)
+	}
+	if output, err := exec.Command("sh", "-n", out).CombinedOutput(); err != nil {
+		t.Errorf("Expected a valid shell script: %v: %s", err, output)
+	}
+
+	var b strings.Builder
+	if err := writeXbarPlugin(&b, "http://127.0.0.1:8081/api/v1/summary", "/Users/o'neil/bin/diu", time.Now()); err != nil {
+		t.Fatalf("writeXbarPlugin failed: %v", err)
+	}
+	if !strings.Contains(b.String(), `DIU='/Users/o'\''neil/bin/diu'`) {
+		t.Errorf("Expected the diu path quoted, got %s", b.String())
+	}
+}
+
+func TestShowStatsFromAggregates(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	store := openTestStore(t, config)
+	now := time.Now()
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm install", Timestamp: now.Add(-time.Hour)})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm update", Timestamp: now.Add(-2 * time.Hour)})
+	addTestExecution(t, store, &core.ExecutionRecord{Tool: core.ToolGo, Command: "go get", Timestamp: now.Add(-3 * time.Hour)})
+	closeTestStore(t, store)
+
+	output := captureStdout(t, func() {
+		if err := showStats(statsCommandForTest(t, "--tool", "npm", "--rebuild"), nil); err != nil {
+			t.Fatalf("showStats --rebuild failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Total executions: 2") || strings.Contains(output, "go:") {
+		t.Errorf("Expected only the npm executions counted, got %q", output)
+	}
+}
+
+func TestMonitorsEnableAndDisable(t *testing.T) {
+	setupTestHomeConfig(t)
+	restore := SetDaemonChecker(MockDaemonChecker{isRunning: false})
+	defer restore()
+
+	output := captureStdout(t, func() {
+		if err := enableMonitor(&command{}, []string{"brew"}); err != nil {
+			t.Fatalf("enableMonitor failed: %v", err)
+		}
+		if err := disableMonitor(&command{}, []string{"npm"}); err != nil {
+			t.Fatalf("disableMonitor failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "homebrew monitor enabled") || !strings.Contains(output, "npm monitor disabled") {
+		t.Errorf("Expected both changes reported, got %q", output)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if !config.ToolEnabled(core.ToolHomebrew) || !config.ToolDisabled(core.ToolNPM) {
+		t.Errorf("Expected homebrew enabled and npm disabled in the config file, got %+v", config.Tools)
+	}
+
+	output = captureStdout(t, func() {
+		if err := listMonitors(&command{}, nil); err != nil {
+			t.Fatalf("listMonitors failed: %v", err)
+		}
+	})
+	if !regexp.MustCompile(`homebrew\s+yes\s+no`).MatchString(output) || !regexp.MustCompile(`npm\s+no\s+no`).MatchString(output) {
+		t.Errorf("Expected homebrew enabled and npm disabled, got %q", output)
+	}
+
+	if err := enableMonitor(&command{}, []string{"apt"}); err == nil {
+		t.Error("Expected a tool without a monitor rejected")
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	setupTestHomeConfig(t)
+	restore := SetDaemonChecker(MockDaemonChecker{isRunning: false})
+	defer restore()
+
+	if err := pauseRecording(&command{}, []string{"soon"}); err == nil {
+		t.Error("Expected an invalid duration rejected")
+	}
+	output := captureStdout(t, func() {
+		if err := pauseRecording(&command{}, []string{"2h"}); err != nil {
+			t.Fatalf("pauseRecording failed: %v", err)
+		}
+		if err := daemonStatus(&command{}, nil); err != nil {
+			t.Fatalf("daemonStatus failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Recording paused until") || !strings.Contains(output, "Recording:") {
+		t.Errorf("Expected the pause reported, got %q", output)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if state, err := core.LoadPauseState(config.Daemon.DataDir, time.Now()); err != nil || !state.Paused {
+		t.Fatalf("Expected recording paused, got %+v, %v", state, err)
+	}
+
+	output = captureStdout(t, func() {
+		if err := resumeRecording(&command{}, nil); err != nil {
+			t.Fatalf("resumeRecording failed: %v", err)
+		}
+		if err := resumeRecording(&command{}, nil); err != nil {
+			t.Fatalf("resumeRecording failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Recording resumed") || !strings.Contains(output, "Recording was not paused") {
+		t.Errorf("Expected the resume reported, got %q", output)
+	}
+}
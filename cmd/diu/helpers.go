@@ -2,17 +2,19 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/gitinfo"
 	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/project"
 	"github.com/yowainwright/diu/internal/safefs"
 	"github.com/yowainwright/diu/internal/storage"
 )
@@ -41,9 +43,10 @@ const (
 	defaultListLimit = 20
 	defaultPageSize  = 12
 
-	formatTable = "table"
-	formatJSON  = "json"
-	formatCSV   = "csv"
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
 
 	homebrewCommandName = "brew"
 	npmCommandName      = "npm"
@@ -70,12 +73,11 @@ const (
 	actionSearch    = "/"
 	actionUninstall = "u"
 
-	removeFilePlan               = "remove-file"
-	packageNameAllowedCharacters = "@._+-/"
-	packageIndexColumnWidth      = 3
-	packageToolColumnWidth       = 14
-	packageNameColumnWidth       = 34
-	packageUsageColumnWidth      = 4
+	removeFilePlan          = "remove-file"
+	packageIndexColumnWidth = 3
+	packageToolColumnWidth  = 14
+	packageNameColumnWidth  = 34
+	packageUsageColumnWidth = 4
 )
 
 type executablePathDeps struct {
@@ -129,33 +131,41 @@ func flagBool(cmd *command, name string) bool {
 	return value
 }
 
-// parseDuration parses duration strings like "24h", "7d", "30d", "1w", "1mo"
-func parseDuration(s string) (time.Duration, error) {
-	if strings.HasSuffix(s, "d") {
-		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
-		if err != nil {
-			return 0, err
-		}
-		return time.Duration(days) * 24 * time.Hour, nil
-	}
+// configFlagPath is the file named by the global --config flag; empty means
+// the default config location
+var configFlagPath string
 
-	if strings.HasSuffix(s, "w") {
-		weeks, err := strconv.Atoi(strings.TrimSuffix(s, "w"))
-		if err != nil {
-			return 0, err
-		}
-		return time.Duration(weeks) * 7 * 24 * time.Hour, nil
-	}
+// loadConfig loads the config named by --config, or the default config
+func loadConfig() (*core.Config, error) {
+	return core.LoadConfig(configFlagPath)
+}
 
-	if strings.HasSuffix(s, "mo") {
-		months, err := strconv.Atoi(strings.TrimSuffix(s, "mo"))
-		if err != nil {
-			return 0, err
-		}
-		return time.Duration(months) * 30 * 24 * time.Hour, nil
+// daemonStartArgs returns the arguments that run the daemon in the
+// foreground, passing --config on so the daemon reads the same file
+func daemonStartArgs(config *core.Config) []string {
+	args := []string{"daemon", "start", "--foreground"}
+	if configFlagPath != "" && config.Path() != "" {
+		args = append(args, "--config", config.Path())
 	}
+	return args
+}
+
+// jsonOutput reports whether the global --json flag asks for machine-readable
+// output instead of styled text
+func jsonOutput(cmd *command) bool {
+	return flagBool(cmd, "json")
+}
 
-	return time.ParseDuration(s)
+// printJSON writes value to stdout as indented JSON
+func printJSON(value interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+// parseDuration parses duration strings like "24h", "7d", "30d", "1w", "1mo"
+func parseDuration(s string) (time.Duration, error) {
+	return core.ParseDuration(s)
 }
 
 // getToolColor returns the ANSI color code for a tool
@@ -169,6 +179,8 @@ func getToolColor(tool string) color {
 		return color("208") // Orange
 	case "bun":
 		return color("230") // Cream
+	case "yarn":
+		return color("39") // Blue
 	case "go":
 		return color("86") // Cyan
 	case "pip", "python", "uv", "poetry":
@@ -177,6 +189,8 @@ func getToolColor(tool string) color {
 		return color("160") // Red
 	case "cargo", "rust":
 		return color("208") // Orange
+	case "make", "just", "task":
+		return color("141") // Purple
 	default:
 		return color("250") // Gray
 	}
@@ -190,6 +204,20 @@ func formatLastUsed(lastUsed time.Time) string {
 	return lastUsed.Format("2006-01-02")
 }
 
+// formatBytes formats a byte count using binary units
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // truncate truncates a string to maxLength, adding ellipsis if truncated
 func truncate(value string, maxLength int) string {
 	if len(value) <= maxLength {
@@ -204,7 +232,7 @@ func truncate(value string, maxLength int) string {
 // shouldSkipExecutableWrapper returns true if the executable should not be wrapped
 func shouldSkipExecutableWrapper(name string) bool {
 	switch name {
-	case "", ".", "..", "diu", "brew", core.ToolNPM, core.ToolPNPM, core.ToolBun, core.ToolGo, core.ToolPip, "pip3", core.ToolUV, core.ToolPoetry:
+	case "", ".", "..", "diu", "brew", core.ToolNPM, core.ToolPNPM, core.ToolBun, core.ToolYarn, core.ToolGo, core.ToolPip, "pip3", core.ToolUV, core.ToolPoetry, core.ToolMake, core.ToolJust, core.ToolTask:
 		return true
 	default:
 		return strings.HasPrefix(name, ".")
@@ -224,7 +252,7 @@ func packageNameForExecutable(tool, path, name string) string {
 		if pkg := pathSegmentAfter(slashPath, "/Cellar/"); pkg != "" {
 			return pkg
 		}
-	case core.ToolNPM, core.ToolPNPM, core.ToolBun:
+	case core.ToolNPM, core.ToolPNPM, core.ToolBun, core.ToolYarn:
 		if pkg := npmPackageFromPath(slashPath); pkg != "" {
 			return pkg
 		}
@@ -403,45 +431,7 @@ func goBinaryDirWithDeps(config *core.Config, deps executablePathDeps) string {
 
 // validatePackageManagerName validates a package manager package name
 func validatePackageManagerName(name string) error {
-	if strings.TrimSpace(name) == "" {
-		return fmt.Errorf("package name cannot be empty")
-	}
-	if strings.TrimSpace(name) != name {
-		return fmt.Errorf("package name cannot contain leading or trailing whitespace")
-	}
-	if strings.HasPrefix(name, "-") {
-		return fmt.Errorf("package name cannot start with a flag prefix: %s", name)
-	}
-	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
-		return fmt.Errorf("package name cannot be an absolute or incomplete path: %s", name)
-	}
-	if strings.Contains(name, "..") || strings.Contains(name, "//") {
-		return fmt.Errorf("package name contains an unsafe path segment: %s", name)
-	}
-
-	hasAlnum := false
-	for _, char := range name {
-		if char >= 'a' && char <= 'z' {
-			hasAlnum = true
-			continue
-		}
-		if char >= 'A' && char <= 'Z' {
-			hasAlnum = true
-			continue
-		}
-		if char >= '0' && char <= '9' {
-			hasAlnum = true
-			continue
-		}
-		if strings.ContainsRune(packageNameAllowedCharacters, char) {
-			continue
-		}
-		return fmt.Errorf("package name contains unsupported character %q", char)
-	}
-	if !hasAlnum {
-		return fmt.Errorf("package name must contain a letter or number")
-	}
-	return nil
+	return core.ValidatePackageName(name)
 }
 
 // validateRemovableExecutablePath validates a path for removal as an executable
@@ -560,49 +550,35 @@ func readPrompt(reader *bufio.Reader, prompt string) (string, error) {
 	return strings.TrimSpace(input), nil
 }
 
-// newMonitor creates a monitor for the given tool
-func newMonitor(tool string) (monitors.Monitor, error) {
-	switch core.NormalizeToolName(tool) {
-	case core.ToolHomebrew:
-		return monitors.NewHomebrewMonitor(), nil
-	case core.ToolNPM:
-		return monitors.NewNPMMonitor(), nil
-	case core.ToolPNPM:
-		return monitors.NewPNPMMonitor(), nil
-	case core.ToolBun:
-		return monitors.NewBunMonitor(), nil
-	case core.ToolGo:
-		return monitors.NewGoMonitor(), nil
-	case core.ToolPip:
-		return monitors.NewPipMonitor(), nil
-	case core.ToolUV:
-		return monitors.NewUVMonitor(), nil
-	case core.ToolPoetry:
-		return monitors.NewPoetryMonitor(), nil
-	default:
-		return nil, fmt.Errorf("unsupported tool: %s", tool)
-	}
+// newMonitor creates a monitor for the given tool, or its plugin when
+// config has one
+func newMonitor(config *core.Config, tool string) (monitors.Monitor, error) {
+	return monitors.ForConfig(config, tool)
 }
 
 // enrichExecutionRecord enriches an execution record with parsed metadata
-func enrichExecutionRecord(config *core.Config, record *core.ExecutionRecord) {
+// and returns the monitor for its tool, or nil when there is none
+func enrichExecutionRecord(config *core.Config, record *core.ExecutionRecord) monitors.Monitor {
 	record.Tool = core.NormalizeToolName(record.Tool)
 	if record.Timestamp.IsZero() {
 		record.Timestamp = time.Now()
 	}
+	gitinfo.Annotate(record)
+	project.Annotate(record)
 
-	monitor, err := newMonitor(record.Tool)
+	monitor, err := newMonitor(config, record.Tool)
 	if err != nil {
-		return
+		return nil
 	}
 
 	parseConfig := *config
 	parseConfig.Monitoring.Process.AutoInstallWrappers = false
 	if err := monitor.Initialize(&parseConfig); err != nil {
-		return
+		return nil
 	}
 
 	monitors.EnrichExecutionRecord(monitor, record)
+	return monitor
 }
 
 // supportsUninstall returns true if the package tool supports uninstall
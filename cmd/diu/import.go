@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// importDocument matches both diu export JSON, where packages is a list, and
+// storage files, where packages is keyed by tool and name
+type importDocument struct {
+	Type       string                  `json:"type"`
+	Executions []*core.ExecutionRecord `json:"executions"`
+	Packages   json.RawMessage         `json:"packages"`
+	// Metadata is set for storage files, whose older executions may not
+	// name their host.
+	Metadata core.StorageMetadata `json:"metadata"`
+}
+
+// importHistory merges executions and packages from an export or another
+// machine's storage file
+func importHistory(cmd *command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("import file required")
+	}
+
+	data, err := safefs.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read import file: %w", err)
+	}
+	records, err := parseImportData(args[0], data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	result, err := store.ImportRecords(records.Executions, records.Packages)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Imported %s", args[0])))
+	fmt.Printf("  %-12s %d inserted, %d skipped\n", "Executions:", result.ExecutionsInserted, result.ExecutionsSkipped)
+	fmt.Printf("  %-12s %d inserted, %d skipped\n", "Packages:", result.PackagesInserted, result.PackagesSkipped)
+	return nil
+}
+
+// parseImportData accepts diu JSON exports, JSONL exports, and storage files,
+// reading a storage file's executions from the shards beside path
+func parseImportData(path string, data []byte) (*exportData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var first importDocument
+	if err := decoder.Decode(&first); err != nil {
+		return nil, err
+	}
+	var next json.RawMessage
+	if err := decoder.Decode(&next); errors.Is(err, io.EOF) && first.Type == "" {
+		packages, err := parseImportPackages(first.Packages)
+		if err != nil {
+			return nil, err
+		}
+		sharded, err := storage.ReadShards(path, first.Metadata.Shards)
+		if err != nil {
+			return nil, err
+		}
+		for i := range sharded {
+			first.Executions = append(first.Executions, &sharded[i])
+		}
+		for _, record := range first.Executions {
+			if record.Host == "" {
+				record.Host = first.Metadata.Hostname
+			}
+		}
+		return &exportData{Executions: first.Executions, Packages: packages}, nil
+	}
+
+	return parseImportJSONL(data)
+}
+
+func parseImportPackages(raw json.RawMessage) ([]*core.PackageInfo, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	if raw[0] == '[' {
+		var packages []*core.PackageInfo
+		if err := json.Unmarshal(raw, &packages); err != nil {
+			return nil, fmt.Errorf("invalid packages: %w", err)
+		}
+		return packages, nil
+	}
+
+	var byTool map[string]map[string]*core.PackageInfo
+	if err := json.Unmarshal(raw, &byTool); err != nil {
+		return nil, fmt.Errorf("invalid packages: %w", err)
+	}
+	var packages []*core.PackageInfo
+	for _, byName := range byTool {
+		for _, pkg := range byName {
+			packages = append(packages, pkg)
+		}
+	}
+	return packages, nil
+}
+
+func parseImportJSONL(data []byte) (*exportData, error) {
+	records := &exportData{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for line := 1; ; line++ {
+		var entry struct {
+			Type   string          `json:"type"`
+			Record json.RawMessage `json:"record"`
+		}
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+
+		switch entry.Type {
+		case "execution":
+			var record core.ExecutionRecord
+			if err := json.Unmarshal(entry.Record, &record); err != nil {
+				return nil, fmt.Errorf("record %d: %w", line, err)
+			}
+			records.Executions = append(records.Executions, &record)
+		case "package":
+			var pkg core.PackageInfo
+			if err := json.Unmarshal(entry.Record, &pkg); err != nil {
+				return nil, fmt.Errorf("record %d: %w", line, err)
+			}
+			records.Packages = append(records.Packages, &pkg)
+		default:
+			return nil, fmt.Errorf("record %d: unknown type %q", line, entry.Type)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/license"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// resolveLicenses looks up the license of each package that has none yet
+// and stores the ones found. Lookup failures leave the package unknown and
+// are returned joined, so one unreachable registry does not stop the rest.
+func resolveLicenses(store storage.Storage, packages []*core.PackageInfo) error {
+	resolver := license.NewResolver()
+	var failures []error
+	for _, pkg := range packages {
+		if pkg.License != "" {
+			continue
+		}
+		found, err := resolver.License(context.Background(), pkg)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s/%s: %w", pkg.Tool, pkg.Name, err))
+			continue
+		}
+		if found == "" {
+			continue
+		}
+		pkg.License = found
+		if err := store.UpdatePackage(pkg); err != nil {
+			return fmt.Errorf("failed to update package %s/%s: %w", pkg.Tool, pkg.Name, err)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to look up %d licenses, first: %w", len(failures), failures[0])
+	}
+	return nil
+}
+
+// printCopyleftReport lists the packages under copyleft licenses, most used
+// first
+func printCopyleftReport(packages []*core.PackageInfo) {
+	var copyleft []*core.PackageInfo
+	for _, pkg := range packages {
+		if license.Copyleft(pkg.License) != "" {
+			copyleft = append(copyleft, pkg)
+		}
+	}
+	fmt.Println()
+	if len(copyleft) == 0 {
+		fmt.Println(successStyle.Render("No copyleft-licensed packages"))
+		return
+	}
+	sort.SliceStable(copyleft, func(i, j int) bool {
+		return copyleft[i].UsageCount > copyleft[j].UsageCount
+	})
+
+	fmt.Println(titleStyle.Render("Copyleft Licenses"))
+	for _, pkg := range copyleft {
+		fmt.Printf("  %-10s %-24s %-20s %-6s used %d times, last: %s\n",
+			pkg.Tool, truncate(pkg.Name, 24), truncate(pkg.License, 20), license.Copyleft(pkg.License),
+			pkg.UsageCount, formatLastUsed(pkg.LastUsed))
+	}
+}
@@ -17,6 +17,9 @@ func main() {
 		Short: "Do I Use - Package Manager Execution Tracker",
 		Long:  `DIU tracks when package managers and global development tools are executed, storing execution data for analysis and auditing.`,
 	}
+	var jsonOutputFlag bool
+	rootCmd.PersistentFlags().BoolVar(&jsonOutputFlag, "json", false, "Print machine-readable JSON output")
+	rootCmd.PersistentFlags().StringVar(&configFlagPath, "config", "", "Config file to use instead of the default")
 
 	// Daemon commands
 	daemonCmd := &command{
@@ -29,6 +32,9 @@ func main() {
 		Short: "Start the DIU daemon",
 		RunE:  startDaemon,
 	}
+	var startForeground, startDetach bool
+	daemonStartCmd.Flags().BoolVar(&startForeground, "foreground", false, "Run the daemon in the current process")
+	daemonStartCmd.Flags().BoolVar(&startDetach, "detach", false, "Run the daemon in the background (default)")
 
 	daemonStopCmd := &command{
 		Use:   "stop",
@@ -48,7 +54,49 @@ func main() {
 		RunE:  daemonStatus,
 	}
 
-	daemonCmd.AddCommand(daemonStartCmd, daemonStopCmd, daemonRestartCmd, daemonStatusCmd)
+	daemonReloadCmd := &command{
+		Use:   "reload",
+		Short: "Reload daemon configuration",
+		RunE:  reloadDaemon,
+	}
+
+	var (
+		installLaunchd bool
+		installSystemd bool
+		installSocket  bool
+		installWindows bool
+	)
+
+	daemonInstallCmd := &command{
+		Use:   "install",
+		Short: "Install the daemon as a user service",
+		RunE:  installDaemonService,
+	}
+	daemonInstallCmd.Flags().BoolVar(&installLaunchd, "launchd", false, "Install as a macOS LaunchAgent")
+	daemonInstallCmd.Flags().BoolVar(&installSystemd, "systemd", false, "Install as a systemd user service")
+	daemonInstallCmd.Flags().BoolVar(&installWindows, "windows", false, "Install as a Windows service")
+	daemonInstallCmd.Flags().BoolVar(&installSocket, "socket", false, "Also install a systemd socket unit for activation")
+
+	daemonUninstallCmd := &command{
+		Use:   "uninstall",
+		Short: "Remove the daemon user service",
+		RunE:  uninstallDaemonService,
+	}
+
+	var (
+		logsFollow bool
+		logsLines  int
+	)
+
+	daemonLogsCmd := &command{
+		Use:   "logs",
+		Short: "Show daemon log output",
+		RunE:  daemonLogs,
+	}
+	daemonLogsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow log output")
+	daemonLogsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "Number of lines to show")
+
+	daemonCmd.AddCommand(daemonStartCmd, daemonStopCmd, daemonRestartCmd, daemonReloadCmd, daemonStatusCmd, daemonLogsCmd, daemonInstallCmd, daemonUninstallCmd)
 
 	// Query command
 	var (
@@ -57,6 +105,16 @@ func main() {
 		queryLast    string
 		queryLimit   int
 		queryFormat  string
+		queryColumns string
+		queryWide    bool
+		queryFailed  bool
+		queryExit    string
+		queryDir     string
+		queryProject string
+		querySession string
+		queryHost    string
+		queryMatch   string
+		queryGlob    string
 	)
 
 	queryCmd := &command{
@@ -68,14 +126,41 @@ func main() {
 	queryCmd.Flags().StringVarP(&queryPackage, "package", "p", "", "Filter by package name")
 	queryCmd.Flags().StringVarP(&queryLast, "last", "l", "", "Show executions in last duration (e.g., 24h, 7d)")
 	queryCmd.Flags().IntVarP(&queryLimit, "limit", "n", 20, "Limit number of results")
-	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "table", "Output format (table, json, csv)")
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "table", "Output format (table, json, ndjson, csv)")
+	queryCmd.Flags().StringVar(&queryColumns, "columns", defaultQueryColumns, "Table columns (time, ago, tool, duration, exit, command, packages, project, dir, user, host, session, id)")
+	queryCmd.Flags().BoolVar(&queryWide, "wide", false, "Do not truncate table cells")
+	queryCmd.Flags().BoolVar(&queryFailed, "failed", false, "Only show executions with a non-zero exit code")
+	queryCmd.Flags().StringVar(&queryExit, "exit-code", "", "Only show executions that exited with this code")
+	queryCmd.Flags().StringVar(&queryDir, "dir", "", "Only show executions run in this directory or below it")
+	queryCmd.Flags().StringVar(&queryProject, "project", "", "Only show executions in this project (see diu stats --by project)")
+	queryCmd.Flags().StringVar(&querySession, "session", "", "Only show executions from this shell session, oldest first")
+	queryCmd.Flags().StringVar(&queryHost, "host", "", "Only show executions recorded on this hostname or machine ID")
+	queryCmd.Flags().StringVar(&queryMatch, "match", "", "Only show executions whose command matches this regular expression")
+	queryCmd.Flags().StringVar(&queryGlob, "glob", "", "Only show executions whose whole command matches this glob (* and ?)")
+
+	var (
+		watchTool   string
+		watchFormat string
+	)
+
+	watchCmd := &command{
+		Use:   "watch",
+		Short: "Stream executions live as the daemon records them",
+		RunE:  watchExecutions,
+	}
+	watchCmd.Flags().StringVarP(&watchTool, "tool", "t", "", "Only show executions of this tool")
+	watchCmd.Flags().StringVarP(&watchFormat, "format", "f", formatTable, "Output format (table, json)")
 
 	// Stats command
 	var (
-		statsDaily  bool
-		statsWeekly bool
-		statsTool   string
-		statsTop    int
+		statsDaily   bool
+		statsWeekly  bool
+		statsTool    string
+		statsTop     int
+		statsHeat    bool
+		statsLine    bool
+		statsBy      string
+		statsProject string
 	)
 
 	statsCmd := &command{
@@ -87,6 +172,42 @@ func main() {
 	statsCmd.Flags().BoolVarP(&statsWeekly, "weekly", "w", false, "Show weekly statistics")
 	statsCmd.Flags().StringVarP(&statsTool, "tool", "t", "", "Statistics for specific tool")
 	statsCmd.Flags().IntVar(&statsTop, "top", 10, "Show top N most used packages")
+	statsCmd.Flags().BoolVar(&statsHeat, "heatmap", false, "Show activity as a weekday by hour heatmap")
+	statsCmd.Flags().BoolVar(&statsLine, "timeline", false, "Show daily execution counts as a sparkline")
+	statsCmd.Flags().StringVar(&statsProject, "project", "", "Statistics for a specific project")
+	statsCmd.Flags().StringVar(&statsBy, "by", "", "Count executions by tool, package, project, repo, dir, user, host, weekday, or hour")
+	var statsUpgrades bool
+	statsCmd.Flags().BoolVar(&statsUpgrades, "upgrades", false, "Show how often packages are upgraded and flag stale ones used daily")
+	var statsTime, statsRebuild bool
+	statsCmd.Flags().BoolVar(&statsTime, "time", false, "Show time spent waiting per command, tool, package, and day")
+	statsCmd.Flags().BoolVar(&statsRebuild, "rebuild", false, "Recount the stored statistics from the executions first")
+	var statsActions bool
+	statsCmd.Flags().BoolVar(&statsActions, "actions", false, "Show the share of each tool's executions per action, such as install, run, or audit")
+	var statsScripts bool
+	statsCmd.Flags().BoolVar(&statsScripts, "scripts", false, "Show which package.json scripts run most and which are never run")
+
+	var (
+		topTool     string
+		topLast     string
+		topSort     string
+		topBy       string
+		topLimit    int
+		topInterval string
+		topOnce     bool
+	)
+
+	topCmd := &command{
+		Use:   "top",
+		Short: "Live view of packages and tools ranked by recent usage",
+		RunE:  showTop,
+	}
+	topCmd.Flags().StringVarP(&topTool, "tool", "t", "", "Only rank executions of this tool")
+	topCmd.Flags().StringVarP(&topLast, "last", "l", "7d", "Usage window (e.g., 24h, 7d)")
+	topCmd.Flags().StringVarP(&topSort, "sort", "s", topSortCount, "Sort by count, duration, or last")
+	topCmd.Flags().StringVar(&topBy, "by", topByPackage, "Rank packages or tools (package, tool)")
+	topCmd.Flags().IntVarP(&topLimit, "limit", "n", defaultListLimit, "Number of rows to show")
+	topCmd.Flags().StringVar(&topInterval, "interval", topDefaultInterval.String(), "Refresh interval")
+	topCmd.Flags().BoolVar(&topOnce, "once", false, "Print the ranking once and exit")
 
 	// Packages command
 	var (
@@ -101,6 +222,8 @@ func main() {
 	}
 	packagesCmd.Flags().StringVarP(&packagesTool, "tool", "t", "", "Filter by tool")
 	packagesCmd.Flags().StringVarP(&packagesUnused, "unused", "u", "", "Show packages not used in duration")
+	var packagesLicense bool
+	packagesCmd.Flags().BoolVar(&packagesLicense, "license", false, "Look up and show licenses, then list copyleft-licensed packages")
 
 	var (
 		checkTool   string
@@ -121,6 +244,15 @@ func main() {
 	checkCmd.Flags().IntVarP(&checkLimit, "limit", "n", defaultListLimit, "Limit non-interactive results")
 	checkCmd.Flags().StringVarP(&checkFormat, "format", "f", formatTable, "Output format (table, json, csv)")
 
+	var whyTool string
+
+	whyCmd := &command{
+		Use:   "why <package>",
+		Short: "Show how a package was installed and upgraded",
+		RunE:  explainPackage,
+	}
+	whyCmd.Flags().StringVarP(&whyTool, "tool", "t", "", "Only look at packages of this tool")
+
 	var (
 		manageTool      string
 		manageSearch    string
@@ -140,6 +272,23 @@ func main() {
 	manageCmd.Flags().BoolVarP(&manageYes, "yes", "y", false, "Skip uninstall confirmation")
 	manageCmd.Flags().BoolVar(&manageDryRun, "dry-run", false, "Print uninstall command without running it")
 
+	var (
+		pruneTool   string
+		pruneUnused string
+		pruneApply  bool
+		pruneYes    bool
+	)
+
+	pruneCmd := &command{
+		Use:   "prune",
+		Short: "Print or run uninstall commands for unused packages",
+		RunE:  prunePackages,
+	}
+	pruneCmd.Flags().StringVarP(&pruneTool, "tool", "t", "", "Filter by tool")
+	pruneCmd.Flags().StringVarP(&pruneUnused, "unused", "u", "90d", "Prune packages not used in duration")
+	pruneCmd.Flags().BoolVar(&pruneApply, "apply", false, "Run the uninstall commands after confirmation")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Skip the --apply confirmation")
+
 	// Config command
 	configCmd := &command{
 		Use:   "config",
@@ -164,7 +313,13 @@ func main() {
 		RunE:  listConfig,
 	}
 
-	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd)
+	configValidateCmd := &command{
+		Use:   "validate",
+		Short: "Check the configuration for errors",
+		RunE:  validateConfig,
+	}
+
+	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd, configValidateCmd)
 
 	// Maintenance commands
 	cleanupCmd := &command{
@@ -173,11 +328,272 @@ func main() {
 		RunE:  cleanup,
 	}
 
+	fsckCmd := &command{
+		Use:   "fsck",
+		Short: "Check storage integrity and optionally repair it",
+		Long:  "Check that the storage file and its shards are valid JSON, that execution IDs are unique, and that the statistics and package usage counts agree with the executions. With --repair, recompute what is derived from the executions.",
+		RunE:  checkStorage,
+	}
+	var fsckRepair bool
+	fsckCmd.Flags().BoolVar(&fsckRepair, "repair", false, "Fix the problems found by recomputing derived data")
+
+	monitorsCmd := &command{
+		Use:   "monitors",
+		Short: "List, enable, and disable tool monitors",
+		RunE:  listMonitors,
+	}
+	monitorsCmd.AddCommand(&command{
+		Use:   "list",
+		Short: "List the tools diu can monitor and whether each is enabled and running",
+		RunE:  listMonitors,
+	}, &command{
+		Use:   "enable <tool>",
+		Short: "Start monitoring a tool, in the running daemon and the config file",
+		RunE:  enableMonitor,
+	}, &command{
+		Use:   "disable <tool>",
+		Short: "Stop monitoring a tool, in the running daemon and the config file",
+		RunE:  disableMonitor,
+	})
+
+	pluginCmd := &command{
+		Use:   "plugin",
+		Short: "Work with monitor plugins",
+	}
+	pluginCheckCmd := &command{
+		Use:   "check <path> [-- <args>...]",
+		Short: "Check that a plugin follows the plugin protocol",
+		Long:  "Run a plugin's list-installed and parse subcommands, parsing the binary alone and, when given, the binary with the arguments after --, and check that each succeeds with valid JSON and that an unknown subcommand fails.",
+		RunE:  checkPlugin,
+	}
+	var pluginName, pluginBinary, pluginTimeout string
+	pluginCheckCmd.Flags().StringVar(&pluginName, "name", "", "Tool the plugin tracks (default the file name without diu-plugin-)")
+	pluginCheckCmd.Flags().StringVar(&pluginBinary, "binary", "", "Command the plugin parses (default the tool name)")
+	pluginCheckCmd.Flags().StringVar(&pluginTimeout, "timeout", "", "Time each request may take (default 30s)")
+	pluginCmd.AddCommand(pluginCheckCmd)
+
+	generateCmd := &command{
+		Use:   "generate",
+		Short: "Generate setup files from the packages you actually use",
+	}
+	var generateUsedWithin, generateOut string
+	generateCmd.PersistentFlags().StringVar(&generateUsedWithin, "used-within", defaultGenerateUsedWithin, "Only include packages used within this duration (e.g., 90d)")
+	generateCmd.PersistentFlags().StringVarP(&generateOut, "out", "o", "", "Write to file instead of stdout")
+	generateCmd.AddCommand(&command{
+		Use:   "brewfile",
+		Short: "Print a Brewfile of the Homebrew formulae and casks used recently",
+		Long:  "Print a Brewfile listing the Homebrew formulae and casks used within --used-within, with the taps of tapped formulae, so brew bundle can set up a new machine with only what you use.",
+		RunE:  generateBrewfile,
+	})
+	generateNPMGlobalsCmd := &command{
+		Use:   "npm-globals",
+		Short: "Print an install script or list of the global npm packages used recently",
+		Long:  "Print a shell script that installs the global npm packages used within --used-within, or with --format list one package per line, to recreate a trimmed global environment on another machine. npm and corepack, which ship with Node.js, are left out.",
+		RunE:  generateNPMGlobals,
+	}
+	var generateFormat string
+	var generatePin bool
+	generateNPMGlobalsCmd.Flags().StringVarP(&generateFormat, "format", "f", generateFormatScript, "Output format (script, list)")
+	generateNPMGlobalsCmd.Flags().BoolVar(&generatePin, "pin", false, "Pin each package to its installed version")
+	generateCmd.AddCommand(generateNPMGlobalsCmd)
+	generatePythonToolsCmd := &command{
+		Use:   "python-tools",
+		Short: "Print uv tool or pipx install commands for the Python CLI tools used recently",
+		Long:  "Print a shell script that reinstalls the Python CLI tools used within --used-within, one uv tool install line per tool, or pipx install lines with --installer pipx. --format list prints one package per line instead.",
+		RunE:  generatePythonTools,
+	}
+	var generatePythonFormat, generateInstaller string
+	var generatePythonPin bool
+	generatePythonToolsCmd.Flags().StringVarP(&generatePythonFormat, "format", "f", generateFormatScript, "Output format (script, list)")
+	generatePythonToolsCmd.Flags().StringVar(&generateInstaller, "installer", pythonInstallerUV, "Installer the script uses (uv, pipx)")
+	generatePythonToolsCmd.Flags().BoolVar(&generatePythonPin, "pin", false, "Pin each tool to its installed version")
+	generateCmd.AddCommand(generatePythonToolsCmd)
+	generateCmd.AddCommand(&command{
+		Use:   "xbar",
+		Short: "Print an xbar or SwiftBar plugin showing diu in the menu bar",
+		Long:  "Print a plugin script for xbar or SwiftBar that polls the daemon's /api/v1/summary and shows today's command count, the last command, and unused packages in the menu bar, with items to pause and resume recording. Save it as " + xbarPluginName + " in the plugin folder, or pass --out to write it executable. The daemon API must be enabled.",
+		RunE:  generateXbar,
+	})
+
+	pauseCmd := &command{
+		Use:   "pause [duration]",
+		Short: "Stop recording executions, for a duration such as 30m or until diu resume",
+		Long:  "Drop executions instead of recording them, e.g. while screen sharing or doing sensitive work. With a duration such as 30m or 2h recording resumes by itself; without one it stays paused until diu resume. The pause applies to the running daemon and to wrappers that record without it.",
+		RunE:  pauseRecording,
+	}
+	resumeCmd := &command{
+		Use:   "resume",
+		Short: "Resume recording after diu pause",
+		RunE:  resumeRecording,
+	}
+
+	vacuumCmd := &command{
+		Use:   "vacuum",
+		Short: "Rewrite storage compactly and report its size before and after",
+		Long:  "Rewrite the storage file and every shard: drop executions past retention that are still on disk, keep executions from shards the storage file does not list, write the shards of past months without indentation, recount the statistics, and remove temporary files left by interrupted saves.",
+		RunE:  vacuumStorage,
+	}
+
 	backupCmd := &command{
 		Use:   "backup",
 		Short: "Create manual backup",
 		RunE:  backup,
 	}
+	var backupTo string
+	backupCmd.Flags().StringVar(&backupTo, "to", "", "Upload the backup to s3://, gs://, or WebDAV URL")
+	backupCmd.AddCommand(&command{
+		Use:   "list",
+		Short: "List available backups with sizes",
+		RunE:  listBackups,
+	})
+
+	restoreCmd := &command{
+		Use:   "restore [backup-file]",
+		Short: "Restore storage from a local or remote backup",
+		RunE:  restoreBackup,
+	}
+	var restoreFrom string
+	var restoreDryRun, restoreYes bool
+	restoreCmd.Flags().StringVar(&restoreFrom, "from", "", "Remote backup URL or prefix (newest backup is used)")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Show record counts without restoring")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "Skip restore confirmation")
+
+	var (
+		exportFormat  string
+		exportOut     string
+		exportDataset string
+		exportTool    string
+		exportPackage string
+		exportLast    string
+		exportLimit   int
+	)
+
+	exportCmd := &command{
+		Use:   "export",
+		Short: "Export executions and packages",
+		RunE:  exportHistory,
+	}
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", formatJSON, "Export format (csv, json, jsonl, sqlite)")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "Write to file instead of stdout")
+	exportCmd.Flags().StringVar(&exportDataset, "data", exportDataAll, "Records to export (all, executions, packages)")
+	exportCmd.Flags().StringVarP(&exportTool, "tool", "t", "", "Filter by tool (brew, npm, go, etc.)")
+	exportCmd.Flags().StringVarP(&exportPackage, "package", "p", "", "Filter by package name")
+	exportCmd.Flags().StringVarP(&exportLast, "last", "l", "", "Export executions in last duration (e.g., 24h, 7d)")
+	exportCmd.Flags().IntVarP(&exportLimit, "limit", "n", 0, "Limit number of executions (0 for all)")
+	var exportAnonymize bool
+	var exportSalt string
+	exportCmd.Flags().BoolVar(&exportAnonymize, "anonymize", false, "Replace user names, hosts, and directories with pseudonyms")
+	exportCmd.Flags().StringVar(&exportSalt, "salt", "", "Key pseudonyms with this value so separate exports line up (default random)")
+
+	sbomCmd := &command{
+		Use:   "sbom",
+		Short: "Generate a software bill of materials of tracked packages",
+		RunE:  generateSBOM,
+	}
+	var sbomFormat, sbomOut, sbomTool string
+	sbomCmd.Flags().StringVarP(&sbomFormat, "format", "f", formatCycloneDX, "SBOM format (cyclonedx, spdx)")
+	sbomCmd.Flags().StringVarP(&sbomOut, "out", "o", "", "Write to file instead of stdout")
+	sbomCmd.Flags().StringVarP(&sbomTool, "tool", "t", "", "Filter by tool (brew, npm, go, etc.)")
+
+	outdatedCmd := &command{
+		Use:   "outdated",
+		Short: "List packages with newer versions, most used first",
+		RunE:  listOutdated,
+	}
+	var outdatedTool string
+	outdatedCmd.Flags().StringVarP(&outdatedTool, "tool", "t", "", "Only ask this tool (brew, npm, pnpm, pip)")
+
+	syncCheckCmd := &command{
+		Use:   "sync-check",
+		Short: "Compare installed packages with the packages you use",
+		Long:  "Ask each tracked tool what it has installed and sort the packages into installed and used, installed but never used, and used but not installed, such as packages run again and again with npx or dlx that are worth installing.",
+		RunE:  checkDrift,
+	}
+	var syncCheckTool, syncCheckUsedWithin string
+	syncCheckCmd.Flags().StringVarP(&syncCheckTool, "tool", "t", "", "Only check this tool")
+	syncCheckCmd.Flags().StringVar(&syncCheckUsedWithin, "used-within", "", "Only count usage within this duration (e.g., 90d; default all history)")
+
+	auditCmd := &command{
+		Use:   "audit",
+		Short: "Check tracked packages for known vulnerabilities",
+		Long:  "Look up tracked npm, Go, Python, and Cargo packages in the OSV.dev database and list known vulnerabilities, most recently used packages first. Answers are cached for audit.cache_ttl.",
+		RunE:  auditPackages,
+	}
+	var auditTool string
+	var auditRefresh bool
+	auditCmd.Flags().StringVarP(&auditTool, "tool", "t", "", "Filter by tool (npm, go, pip, cargo, etc.)")
+	auditCmd.Flags().BoolVar(&auditRefresh, "refresh", false, "Ignore cached answers and ask OSV again")
+
+	reportCmd := &command{
+		Use:   "report",
+		Short: "Show the daily or weekly usage summary",
+		RunE:  showReport,
+	}
+	var reportWeekly, reportEmail bool
+	reportCmd.Flags().BoolVarP(&reportWeekly, "weekly", "w", false, "Summarize the last 7 days instead of 24 hours")
+	reportCmd.Flags().BoolVar(&reportEmail, "email", false, "Email the report using reporting.smtp")
+
+	importCmd := &command{
+		Use:   "import <file>",
+		Short: "Import executions and packages from an export or storage file",
+		RunE:  importHistory,
+	}
+
+	syncCmd := &command{
+		Use:   "sync",
+		Short: "Push executions to a central diu daemon and show stats across machines",
+		RunE:  syncHistory,
+	}
+	var syncRemote string
+	var syncFull bool
+	syncCmd.Flags().StringVar(&syncRemote, "remote", "", "Base URL of the central daemon (default sync.remote)")
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "Push every execution, not only those since the last sync")
+
+	serverCmd := &command{
+		Use:   "server",
+		Short: "Collect history from a team's daemons, authenticated per user",
+		RunE:  runServer,
+	}
+	var serverHost string
+	var serverPort int
+	serverCmd.Flags().StringVar(&serverHost, "host", "", "Address to listen on (default server.host)")
+	serverCmd.Flags().IntVar(&serverPort, "port", 0, "Port to listen on (default server.port)")
+	serverTokenCmd := &command{
+		Use:   "token <user>",
+		Short: "Create a sync token for a user, replacing any previous one",
+		RunE:  createServerToken,
+	}
+	var serverRevoke bool
+	serverTokenCmd.Flags().BoolVar(&serverRevoke, "revoke", false, "Revoke the user's token instead")
+	serverStatsCmd := &command{
+		Use:   "stats",
+		Short: "Show which tools and packages the team uses",
+		RunE:  showTeamStats,
+	}
+	var serverStatsLast string
+	var serverStatsTop int
+	serverStatsCmd.Flags().StringVarP(&serverStatsLast, "last", "l", "", "Only count executions in this duration (e.g., 30d)")
+	serverStatsCmd.Flags().IntVar(&serverStatsTop, "top", 20, "Number of tools and packages to show (0 for all)")
+	serverCmd.AddCommand(serverTokenCmd, serverStatsCmd)
+
+	snapshotCmd := &command{
+		Use:   "snapshot [name]",
+		Short: "Save the installed-package inventory for later diffs",
+		RunE:  createSnapshot,
+	}
+	var snapshotScan bool
+	snapshotCmd.Flags().BoolVar(&snapshotScan, "scan", false, "Scan installed packages before saving")
+	snapshotCmd.AddCommand(&command{
+		Use:   "list",
+		Short: "List saved snapshots",
+		RunE:  listSnapshots,
+	})
+
+	diffCmd := &command{
+		Use:   "diff <snapshotA> [snapshotB]",
+		Short: "Show installs, removals, and version changes between snapshots",
+		RunE:  diffSnapshots,
+	}
 
 	setupCmd := &command{
 		Use:   "setup",
@@ -191,6 +607,14 @@ func main() {
 		RunE:  scanPackages,
 	}
 
+	completionCmd := &command{
+		Use:               "completion <bash|zsh|fish>",
+		Short:             "Print a shell completion script",
+		Long:              "Print a shell completion script. Tools, packages, and snapshots complete from tracked data, e.g. source <(diu completion bash).",
+		RunE:              printCompletionScript,
+		ValidArgsFunction: completeShells,
+	}
+
 	recordCmd := &command{
 		Use:    "record",
 		Short:  "Record an execution event from stdin",
@@ -202,18 +626,57 @@ func main() {
 	rootCmd.AddCommand(
 		daemonCmd,
 		queryCmd,
+		watchCmd,
 		statsCmd,
+		topCmd,
 		packagesCmd,
 		checkCmd,
+		whyCmd,
 		manageCmd,
+		pruneCmd,
 		configCmd,
 		cleanupCmd,
+		fsckCmd,
+		vacuumCmd,
+		monitorsCmd,
+		pauseCmd,
+		resumeCmd,
+		pluginCmd,
 		backupCmd,
+		restoreCmd,
+		exportCmd,
+		sbomCmd,
+		generateCmd,
+		auditCmd,
+		outdatedCmd,
+		syncCheckCmd,
+		importCmd,
+		syncCmd,
+		serverCmd,
+		reportCmd,
+		snapshotCmd,
+		diffCmd,
 		setupCmd,
 		scanCmd,
 		recordCmd,
+		completionCmd,
 	)
 
+	for _, cmd := range []*command{queryCmd, watchCmd, statsCmd, topCmd, packagesCmd, checkCmd, whyCmd, manageCmd, pruneCmd, exportCmd, sbomCmd, auditCmd, outdatedCmd, syncCheckCmd} {
+		cmd.RegisterFlagCompletionFunc("tool", completeTools)
+	}
+	for _, cmd := range []*command{queryCmd, exportCmd} {
+		cmd.RegisterFlagCompletionFunc("package", completePackages)
+	}
+	checkCmd.ValidArgsFunction = completePackages
+	whyCmd.ValidArgsFunction = completePackages
+	manageCmd.ValidArgsFunction = completePackages
+	diffCmd.ValidArgsFunction = completeSnapshots
+
+	if runCompletion(rootCmd, os.Args[1:]) {
+		return
+	}
+
 	if err := rootCmd.Execute(os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, errorStyle.RenderTo(err.Error(), os.Stderr))
 		os.Exit(1)
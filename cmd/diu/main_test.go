@@ -1002,6 +1002,8 @@ func setupTestHomeConfig(t *testing.T) *core.Config {
 
 	homeDir := t.TempDir()
 	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
 
 	config := core.DefaultConfig()
 	config.Monitoring.EnabledTools = []string{}
@@ -1071,16 +1073,38 @@ func withStdin(t *testing.T, input string, fn func()) {
 	fn()
 }
 
+// withGlobalJSON attaches cmd to a root command whose persistent --json flag
+// is set, as if the user had passed diu --json
+func withGlobalJSON(cmd *command) *command {
+	enabled := true
+	root := &command{Use: "diu"}
+	root.PersistentFlags().BoolVar(&enabled, "json", true, "json")
+	root.AddCommand(cmd)
+	cmd.inheritFlags()
+	return cmd
+}
+
 func queryCommandForTest(t *testing.T, args ...string) *command {
 	t.Helper()
 	cmd := &command{}
-	var tool, pkg, last, format string
+	var tool, pkg, last, format, columns, exitCode, dir, project, session, host, match, glob string
 	var limit int
+	var wide, failed bool
 	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
 	cmd.Flags().StringVarP(&pkg, "package", "p", "", "package")
 	cmd.Flags().StringVarP(&last, "last", "l", "", "last")
 	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "limit")
 	cmd.Flags().StringVarP(&format, "format", "f", formatTable, "format")
+	cmd.Flags().StringVar(&columns, "columns", defaultQueryColumns, "columns")
+	cmd.Flags().BoolVar(&wide, "wide", false, "wide")
+	cmd.Flags().BoolVar(&failed, "failed", false, "failed")
+	cmd.Flags().StringVar(&exitCode, "exit-code", "", "exit code")
+	cmd.Flags().StringVar(&dir, "dir", "", "dir")
+	cmd.Flags().StringVar(&project, "project", "", "project")
+	cmd.Flags().StringVar(&session, "session", "", "session")
+	cmd.Flags().StringVar(&host, "host", "", "host")
+	cmd.Flags().StringVar(&match, "match", "", "match")
+	cmd.Flags().StringVar(&glob, "glob", "", "glob")
 	parseTestFlags(t, cmd, args...)
 	return cmd
 }
@@ -1091,6 +1115,22 @@ func packagesCommandForTest(t *testing.T, args ...string) *command {
 	var tool, unused string
 	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
 	cmd.Flags().StringVarP(&unused, "unused", "u", "", "unused")
+	var showLicense bool
+	cmd.Flags().BoolVar(&showLicense, "license", false, "license")
+	parseTestFlags(t, cmd, args...)
+	return cmd
+}
+
+func generateCommandForTest(t *testing.T, args ...string) *command {
+	t.Helper()
+	cmd := &command{}
+	var usedWithin, out, format, installer string
+	var pin bool
+	cmd.Flags().StringVar(&usedWithin, "used-within", defaultGenerateUsedWithin, "used within")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "out")
+	cmd.Flags().StringVarP(&format, "format", "f", generateFormatScript, "format")
+	cmd.Flags().StringVar(&installer, "installer", pythonInstallerUV, "installer")
+	cmd.Flags().BoolVar(&pin, "pin", false, "pin")
 	parseTestFlags(t, cmd, args...)
 	return cmd
 }
@@ -1126,13 +1166,26 @@ func manageCommandForTest(t *testing.T, args ...string) *command {
 func statsCommandForTest(t *testing.T, args ...string) *command {
 	t.Helper()
 	cmd := &command{}
-	var daily, weekly bool
-	var tool string
+	var daily, weekly, heatmap, timeline bool
+	var tool, project, by string
 	var top int
 	cmd.Flags().BoolVarP(&daily, "daily", "d", false, "daily")
 	cmd.Flags().BoolVarP(&weekly, "weekly", "w", false, "weekly")
 	cmd.Flags().StringVarP(&tool, "tool", "t", "", "tool")
 	cmd.Flags().IntVar(&top, "top", 10, "top")
+	cmd.Flags().BoolVar(&heatmap, "heatmap", false, "heatmap")
+	cmd.Flags().BoolVar(&timeline, "timeline", false, "timeline")
+	cmd.Flags().StringVar(&project, "project", "", "project")
+	cmd.Flags().StringVar(&by, "by", "", "by")
+	var upgrades bool
+	cmd.Flags().BoolVar(&upgrades, "upgrades", false, "upgrades")
+	var waiting, rebuild bool
+	cmd.Flags().BoolVar(&waiting, "time", false, "time")
+	cmd.Flags().BoolVar(&rebuild, "rebuild", false, "rebuild")
+	var actions bool
+	cmd.Flags().BoolVar(&actions, "actions", false, "actions")
+	var scripts bool
+	cmd.Flags().BoolVar(&scripts, "scripts", false, "scripts")
 	parseTestFlags(t, cmd, args...)
 	return cmd
 }
@@ -1360,7 +1413,7 @@ func TestNewMonitorSupportsConfiguredTools(t *testing.T) {
 		core.ToolUV,
 		core.ToolPoetry,
 	} {
-		monitor, err := newMonitor(tool)
+		monitor, err := newMonitor(core.DefaultConfig(), tool)
 		if err != nil {
 			t.Fatalf("newMonitor(%s) failed: %v", tool, err)
 		}
@@ -1369,7 +1422,7 @@ func TestNewMonitorSupportsConfiguredTools(t *testing.T) {
 		}
 	}
 
-	if _, err := newMonitor("bogus"); err == nil {
+	if _, err := newMonitor(core.DefaultConfig(), "bogus"); err == nil {
 		t.Fatal("newMonitor bogus expected error")
 	}
 }
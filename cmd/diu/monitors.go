@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/pkg/client"
+)
+
+// monitorsDaemon returns a client for the running daemon, or nil when the
+// daemon is not running or its API is disabled
+func monitorsDaemon(config *core.Config) *client.Client {
+	if !defaultDaemonChecker.IsRunning(config) {
+		return nil
+	}
+	daemon, err := client.NewFromConfig(config)
+	if err != nil {
+		return nil
+	}
+	return daemon
+}
+
+// listMonitors shows every tool diu can monitor, whether it is enabled, and
+// whether the daemon is running its monitor
+func listMonitors(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var statuses []core.MonitorStatus
+	if daemon := monitorsDaemon(config); daemon != nil {
+		statuses, err = daemon.Monitors(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to list monitors: %w", err)
+		}
+	} else {
+		statuses = monitors.Statuses(config, nil)
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(statuses)
+	}
+	output := newTable([]tableColumn{
+		{Header: "TOOL"},
+		{Header: "ENABLED"},
+		{Header: "RUNNING"},
+		{Header: "PLUGIN"},
+	}, false)
+	for _, status := range statuses {
+		output.AddRow(status.Tool, yesNo(status.Enabled), yesNo(status.Active), status.Plugin)
+	}
+	return output.Render(os.Stdout)
+}
+
+func enableMonitor(cmd *command, args []string) error {
+	return setMonitorEnabled(cmd, args, true)
+}
+
+func disableMonitor(cmd *command, args []string) error {
+	return setMonitorEnabled(cmd, args, false)
+}
+
+// setMonitorEnabled turns a tool's monitor on or off. A running daemon
+// saves the change to its config file and applies it at once; otherwise the
+// config file is edited and the change applies when the daemon starts.
+func setMonitorEnabled(cmd *command, args []string, enabled bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one tool required")
+	}
+	tool := core.NormalizeToolName(args[0])
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if daemon := monitorsDaemon(config); daemon != nil {
+		set := daemon.DisableMonitor
+		if enabled {
+			set = daemon.EnableMonitor
+		}
+		status, err := set(context.Background(), tool)
+		if err != nil {
+			return fmt.Errorf("failed to update the %s monitor: %w", tool, err)
+		}
+		if jsonOutput(cmd) {
+			return printJSON(status)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("%s monitor %s", tool, state)))
+		if status.Enabled && !status.Active {
+			fmt.Println(infoStyle.Render("The monitor did not start; see diu daemon logs"))
+		}
+		return nil
+	}
+
+	if _, err := newMonitor(config, tool); err != nil {
+		return err
+	}
+	if err := config.SetToolEnabled(tool, enabled); err != nil {
+		return err
+	}
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if jsonOutput(cmd) {
+		for _, status := range monitors.Statuses(config, nil) {
+			if status.Tool == tool {
+				return printJSON(status)
+			}
+		}
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("%s monitor %s", tool, state)))
+	fmt.Println(infoStyle.Render("The daemon applies the change when it next loads the config"))
+	return nil
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// outdatedPackage is an outdated package with its recorded usage
+type outdatedPackage struct {
+	monitors.OutdatedPackage
+	UsageCount int       `json:"usage_count"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// listOutdated asks each tracked tool which installed packages have newer
+// versions and lists them most used first
+func listOutdated(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	tools := config.TrackedTools()
+	if tool := core.NormalizeToolName(flagString(cmd, "tool")); tool != "" {
+		tools = []string{tool}
+	}
+	listConfig := *config
+	listConfig.Monitoring.Process.AutoInstallWrappers = false
+
+	var found []monitors.OutdatedPackage
+	for _, tool := range tools {
+		monitor, err := newMonitor(config, tool)
+		if err != nil {
+			continue
+		}
+		lister, ok := monitor.(monitors.OutdatedLister)
+		if !ok {
+			continue
+		}
+		if err := monitor.Initialize(&listConfig); err != nil {
+			continue
+		}
+		packages, err := lister.OutdatedPackages()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		found = append(found, packages...)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	outdated := make([]outdatedPackage, 0, len(found))
+	for _, pkg := range found {
+		entry := outdatedPackage{OutdatedPackage: pkg}
+		if tracked, err := store.GetPackage(pkg.Tool, pkg.Name); err == nil {
+			entry.UsageCount, entry.LastUsed = tracked.UsageCount, tracked.LastUsed
+		}
+		outdated = append(outdated, entry)
+	}
+	sortOutdated(outdated)
+
+	if jsonOutput(cmd) {
+		return printJSON(outdated)
+	}
+	if len(outdated) == 0 {
+		fmt.Println(successStyle.Render("Everything is up to date"))
+		return nil
+	}
+
+	fmt.Println(titleStyle.Render("Outdated Packages"))
+	fmt.Println()
+	output := newTable([]tableColumn{
+		{Header: "TOOL", MaxWidth: packageToolColumnWidth, Color: getToolColor},
+		{Header: "PACKAGE", MaxWidth: 32},
+		{Header: "CURRENT", MaxWidth: 16},
+		{Header: "LATEST", MaxWidth: 16},
+		{Header: "USED", AlignRight: true},
+		{Header: "LAST USED"},
+	}, false)
+	for _, pkg := range outdated {
+		output.AddRow(pkg.Tool, pkg.Name, pkg.Current, pkg.Latest, strconv.Itoa(pkg.UsageCount), formatLastUsed(pkg.LastUsed))
+	}
+	return output.Render(os.Stdout)
+}
+
+// sortOutdated orders outdated packages by how often, then how recently,
+// they were used
+func sortOutdated(outdated []outdatedPackage) {
+	sort.SliceStable(outdated, func(i, j int) bool {
+		a, b := outdated[i], outdated[j]
+		if a.UsageCount != b.UsageCount {
+			return a.UsageCount > b.UsageCount
+		}
+		if !a.LastUsed.Equal(b.LastUsed) {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Name < b.Name
+	})
+}
@@ -26,7 +26,7 @@ type packageListOptions struct {
 
 // listPackages lists all tracked packages
 func listPackages(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -44,7 +44,7 @@ func listPackages(cmd *command, args []string) error {
 		return fmt.Errorf("failed to get packages: %w", err)
 	}
 
-	if len(packages) == 0 {
+	if len(packages) == 0 && !jsonOutput(cmd) {
 		fmt.Println(infoStyle.Render("No packages tracked"))
 		return nil
 	}
@@ -56,14 +56,17 @@ func listPackages(cmd *command, args []string) error {
 	})
 
 	// Filter by unused duration if specified
-	if unusedStr, _ := cmd.Flags().GetString("unused"); unusedStr != "" {
+	unusedStr, _ := cmd.Flags().GetString("unused")
+	var reclaimable int64
+	var unsized int
+	if unusedStr != "" {
 		duration, err := parseDuration(unusedStr)
 		if err != nil {
 			return fmt.Errorf("invalid duration: %w", err)
 		}
 
 		cutoff := time.Now().Add(-duration)
-		var filtered []*core.PackageInfo
+		filtered := []*core.PackageInfo{}
 		for _, pkg := range packages {
 			if pkg.LastUsed.Before(cutoff) {
 				filtered = append(filtered, pkg)
@@ -71,12 +74,28 @@ func listPackages(cmd *command, args []string) error {
 		}
 		packages = filtered
 
-		if len(packages) == 0 {
+		if len(packages) == 0 && !jsonOutput(cmd) {
 			fmt.Println(successStyle.Render("No unused packages found"))
 			return nil
 		}
 	}
 
+	showLicenses := flagBool(cmd, "license")
+	var licenseErr error
+	if showLicenses {
+		licenseErr = resolveLicenses(store, packages)
+	}
+	if unusedStr != "" {
+		reclaimable, unsized = reclaimableSpace(packages)
+	}
+
+	if jsonOutput(cmd) {
+		if packages == nil {
+			packages = []*core.PackageInfo{}
+		}
+		return printJSON(packages)
+	}
+
 	fmt.Println(titleStyle.Render("Tracked Packages"))
 	fmt.Println()
 
@@ -98,15 +117,46 @@ func listPackages(cmd *command, args []string) error {
 		if !pkg.LastUsed.IsZero() {
 			lastUsed = pkg.LastUsed.Format("2006-01-02")
 		}
-		fmt.Printf(" - used %d times, last: %s\n",
-			pkg.UsageCount,
-			lastUsed,
-		)
+		fmt.Printf(" - used %d times, last: %s", pkg.UsageCount, lastUsed)
+		if pkg.SizeBytes > 0 {
+			fmt.Printf(", %s", formatBytes(pkg.SizeBytes))
+		}
+		if showLicenses {
+			license := pkg.License
+			if license == "" {
+				license = "unknown"
+			}
+			fmt.Printf(", license: %s", license)
+		}
+		fmt.Println()
 	}
 
+	if unusedStr != "" {
+		printReclaimableSpace(len(packages), reclaimable, unsized)
+	}
+	if showLicenses {
+		printCopyleftReport(packages)
+		if licenseErr != nil {
+			fmt.Println(infoStyle.Render(licenseErr.Error()))
+		}
+	}
 	return nil
 }
 
+// printReclaimableSpace prints how much disk space uninstalling count
+// unused packages would free
+func printReclaimableSpace(count int, reclaimable int64, unsized int) {
+	fmt.Println()
+	if unsized == count {
+		fmt.Println(infoStyle.Render("Disk usage unknown for these packages"))
+		return
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("You could reclaim %s by removing %d unused packages", formatBytes(reclaimable), count)))
+	if unsized > 0 {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Disk usage unknown for %d of them", unsized)))
+	}
+}
+
 // checkPackages checks installed package usage
 func checkPackages(cmd *command, args []string) error {
 	opts := packageListOptions{
@@ -180,7 +230,7 @@ func shouldUseInteractive(cmd *command, args []string) bool {
 
 // loadFilteredPackages loads packages from storage with filtering
 func loadFilteredPackages(opts packageListOptions) ([]*core.PackageInfo, error) {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
@@ -526,7 +576,7 @@ func runUninstall(pkg *core.PackageInfo) error {
 
 // removeUninstalledPackageState removes package state from storage
 func removeUninstalledPackageState(pkg *core.PackageInfo) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err == nil {
 		if wrapperName := wrapperNameForPackage(pkg); wrapperName != "" {
 			wrapperPath, pathErr := executableWrapperPath(config.Monitoring.Process.WrapperDir, wrapperName)
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// pauseRecording pauses recording for the duration in args, or until diu
+// resume without one. The pause is kept in the data directory, where the
+// running daemon and diu record both read it.
+func pauseRecording(cmd *command, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: diu pause [duration]")
+	}
+	var duration time.Duration
+	if len(args) == 1 {
+		parsed, err := parseDuration(args[0])
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid duration %q", args[0])
+		}
+		duration = parsed
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	state, err := core.Pause(config.Daemon.DataDir, duration, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to pause recording: %w", err)
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(state)
+	}
+	fmt.Println(successStyle.Render("Recording paused " + pausedUntil(state)))
+	return nil
+}
+
+// resumeRecording ends a pause started by diu pause
+func resumeRecording(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	wasPaused, _ := core.LoadPauseState(config.Daemon.DataDir, time.Now())
+	if err := core.Resume(config.Daemon.DataDir); err != nil {
+		return fmt.Errorf("failed to resume recording: %w", err)
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(core.PauseState{})
+	}
+	if !wasPaused.Paused {
+		fmt.Println(infoStyle.Render("Recording was not paused"))
+		return nil
+	}
+	fmt.Println(successStyle.Render("Recording resumed"))
+	return nil
+}
+
+// pausedUntil describes when a pause ends
+func pausedUntil(state core.PauseState) string {
+	if state.Until.IsZero() {
+		return "until diu resume"
+	}
+	return "until " + state.Until.Local().Format("2006-01-02 15:04")
+}
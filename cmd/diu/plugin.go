@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+)
+
+// pluginNamePrefix is dropped from a plugin's file name to name its tool,
+// so diu-plugin-cargo tracks cargo
+const pluginNamePrefix = "diu-plugin-"
+
+// pluginCheckResult is one check in the --json form of diu plugin check
+type pluginCheckResult struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// checkPlugin runs the plugin protocol's conformance checks against the
+// plugin at args[0], parsing the command in the remaining arguments
+func checkPlugin(cmd *command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: diu plugin check <path> [-- <args>...]")
+	}
+	plugin := core.PluginConfig{
+		Name:   flagString(cmd, "name"),
+		Path:   args[0],
+		Binary: flagString(cmd, "binary"),
+	}
+	if plugin.Name == "" {
+		plugin.Name = strings.TrimPrefix(filepath.Base(plugin.Path), pluginNamePrefix)
+	}
+	if timeout := flagString(cmd, "timeout"); timeout != "" {
+		duration, err := parseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		plugin.Timeout = duration
+	}
+
+	checks := monitors.CheckPlugin(plugin, args[1:])
+	results := make([]pluginCheckResult, 0, len(checks))
+	failed := 0
+	for _, check := range checks {
+		result := pluginCheckResult{Check: check.Name, Passed: check.Err == nil}
+		if check.Err != nil {
+			result.Error = check.Err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if jsonOutput(cmd) {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			if result.Passed {
+				fmt.Printf("ok    %s\n", result.Check)
+			} else {
+				fmt.Printf("FAIL  %s: %s\n", result.Check, result.Error)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d plugin checks failed", failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedSysProcAttr starts the daemon in its own session so it outlives
+// the terminal that launched it.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+func terminateDaemonProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+func signalDaemonReload(process *os.Process) error {
+	return process.Signal(syscall.SIGHUP)
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachedSysProcAttr starts the daemon without a console in its own process
+// group so closing the launching console does not stop it.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS,
+	}
+}
+
+// terminateDaemonProcess kills the daemon; Windows has no way to deliver a
+// termination signal to a detached process. Install the daemon as a Windows
+// service for a graceful stop.
+func terminateDaemonProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+func signalDaemonReload(process *os.Process) error {
+	return fmt.Errorf("reload signals are not supported on Windows; use POST /api/v1/reload or restart the daemon")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// prunePlanEntry is one unused package and the command that removes it
+type prunePlanEntry struct {
+	Package *core.PackageInfo
+	Command []string
+	Skip    string
+}
+
+// prunePackages prints uninstall commands for packages unused within the
+// given duration, running them after confirmation with --apply
+func prunePackages(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	unused := flagString(cmd, "unused")
+	if unused == "" {
+		return fmt.Errorf("--unused duration is required")
+	}
+
+	packages, err := loadFilteredPackages(packageListOptions{
+		Tool:   flagString(cmd, "tool"),
+		Unused: unused,
+	})
+	if err != nil {
+		return err
+	}
+
+	plan := buildPrunePlan(packages, config.Prune)
+	if len(plan) == 0 {
+		fmt.Println(successStyle.Render(fmt.Sprintf("No packages unused for %s", unused)))
+		return nil
+	}
+
+	removable := printPrunePlan(plan, unused)
+	if !flagBool(cmd, "apply") || removable == 0 {
+		return nil
+	}
+
+	if !flagBool(cmd, "yes") {
+		prompt := fmt.Sprintf("Uninstall %d packages? [y/N] ", removable)
+		answer, err := readPrompt(bufio.NewReader(os.Stdin), prompt)
+		if err != nil || !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			return fmt.Errorf("prune cancelled")
+		}
+	}
+
+	failed := 0
+	for _, entry := range plan {
+		if entry.Skip != "" {
+			continue
+		}
+		if err := uninstallPackage(entry.Package, true); err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, errorStyle.RenderTo(fmt.Sprintf("%s: %v", entry.Package.Name, err), os.Stderr))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d uninstalls failed", failed, removable)
+	}
+	return nil
+}
+
+// buildPrunePlan resolves the uninstall command for each package, leaving out
+// packages on the prune ignore list
+func buildPrunePlan(packages []*core.PackageInfo, prune core.PruneConfig) []prunePlanEntry {
+	var plan []prunePlanEntry
+	for _, pkg := range packages {
+		if prune.Ignores(pkg.Tool, pkg.Name) {
+			continue
+		}
+		entry := prunePlanEntry{Package: pkg}
+		command, err := uninstallPlan(pkg)
+		if err != nil {
+			entry.Skip = err.Error()
+		} else {
+			entry.Command = printableUninstallPlan(pkg, command)
+		}
+		plan = append(plan, entry)
+	}
+	return plan
+}
+
+// printPrunePlan prints the plan and returns how many packages it can remove
+func printPrunePlan(plan []prunePlanEntry, unused string) int {
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Packages unused for %s", unused)))
+	fmt.Println()
+
+	removable := 0
+	for _, entry := range plan {
+		if entry.Skip != "" {
+			fmt.Println(subtitleStyle.Render(fmt.Sprintf("# %s (%s): %s", entry.Package.Name, entry.Package.Tool, entry.Skip)))
+			continue
+		}
+		removable++
+		fmt.Println(strings.Join(entry.Command, " "))
+	}
+	return removable
+}
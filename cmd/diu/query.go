@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,9 +19,127 @@ import (
 	"github.com/yowainwright/diu/internal/storage"
 )
 
+// defaultQueryColumns are the table columns diu query shows without --columns.
+const defaultQueryColumns = "time,ago,tool,duration,exit,command,packages"
+
+// queryColumn is a selectable column of the diu query table
+type queryColumn struct {
+	tableColumn
+	value func(exec *core.ExecutionRecord, now time.Time) string
+}
+
+var queryColumns = map[string]queryColumn{
+	"time": {
+		tableColumn: tableColumn{Header: "TIME"},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.Timestamp.Local().Format("2006-01-02 15:04:05")
+		},
+	},
+	"ago": {
+		tableColumn: tableColumn{Header: "AGO", AlignRight: true},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return formatRelativeTime(exec.Timestamp, now)
+		},
+	},
+	"tool": {
+		tableColumn: tableColumn{Header: "TOOL", MaxWidth: packageToolColumnWidth, Color: getToolColor},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.Tool
+		},
+	},
+	"duration": {
+		tableColumn: tableColumn{Header: "DURATION", AlignRight: true},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return formatExecutionDuration(exec.Duration)
+		},
+	},
+	"exit": {
+		tableColumn: tableColumn{Header: "EXIT", AlignRight: true, Color: exitCodeColor},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return strconv.Itoa(exec.ExitCode)
+		},
+	},
+	"command": {
+		tableColumn: tableColumn{Header: "COMMAND", MaxWidth: 60},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.Command
+		},
+	},
+	"packages": {
+		tableColumn: tableColumn{Header: "PACKAGES", MaxWidth: 30},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return strings.Join(exec.PackagesAffected, ", ")
+		},
+	},
+	"project": {
+		tableColumn: tableColumn{Header: "PROJECT", MaxWidth: 30},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.Project()
+		},
+	},
+	"dir": {
+		tableColumn: tableColumn{Header: "DIR", MaxWidth: 40},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.WorkingDir
+		},
+	},
+	"user": {
+		tableColumn: tableColumn{Header: "USER", MaxWidth: 16},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.User
+		},
+	},
+	"host": {
+		tableColumn: tableColumn{Header: "HOST", MaxWidth: 24},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.Host
+		},
+	},
+	"session": {
+		tableColumn: tableColumn{Header: "SESSION", MaxWidth: 24},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.SessionID
+		},
+	},
+	"id": {
+		tableColumn: tableColumn{Header: "ID"},
+		value: func(exec *core.ExecutionRecord, now time.Time) string {
+			return exec.ID
+		},
+	},
+}
+
+// parseQueryColumns resolves a comma-separated --columns value
+func parseQueryColumns(spec string) ([]queryColumn, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = defaultQueryColumns
+	}
+	var columns []queryColumn
+	for _, name := range core.SplitList(spec) {
+		column, ok := queryColumns[strings.ToLower(name)]
+		if !ok {
+			names := make([]string, 0, len(queryColumns))
+			for known := range queryColumns {
+				names = append(names, known)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown column %q: choose from %s", name, strings.Join(names, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func exitCodeColor(value string) color {
+	if value == "0" {
+		return color("42")
+	}
+	return color("196")
+}
+
 // queryExecutions queries and displays execution history
 func queryExecutions(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -34,6 +158,32 @@ func queryExecutions(cmd *command, args []string) error {
 	limit, _ := cmd.Flags().GetInt("limit")
 	opts.Limit = limit
 
+	if exitCode := flagString(cmd, "exit-code"); exitCode != "" {
+		code, err := strconv.Atoi(exitCode)
+		if err != nil {
+			return fmt.Errorf("invalid exit code: %s", exitCode)
+		}
+		opts.ExitCode = &code
+	}
+	opts.FailedOnly = flagBool(cmd, "failed")
+	opts.Project = flagString(cmd, "project")
+	opts.SessionID = flagString(cmd, "session")
+	opts.Host = flagString(cmd, "host")
+
+	if dir := flagString(cmd, "dir"); dir != "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("invalid directory: %w", err)
+		}
+		opts.WorkingDir = absDir
+	}
+
+	pattern, err := commandPatternFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	opts.CommandPattern = pattern
+
 	if lastStr, _ := cmd.Flags().GetString("last"); lastStr != "" {
 		duration, err := parseDuration(lastStr)
 		if err != nil {
@@ -43,12 +193,23 @@ func queryExecutions(cmd *command, args []string) error {
 		opts.Since = &since
 	}
 
+	format, _ := cmd.Flags().GetString("format")
+	if jsonOutput(cmd) && format == formatTable {
+		format = formatJSON
+	}
+	if format == formatNDJSON {
+		return streamExecutionsNDJSON(os.Stdout, store, opts)
+	}
+
 	executions, err := store.GetExecutions(opts)
 	if err != nil {
 		return fmt.Errorf("failed to query executions: %w", err)
 	}
+	if opts.SessionID != "" {
+		// A session reads as the sequence of commands it ran.
+		slices.Reverse(executions)
+	}
 
-	format, _ := cmd.Flags().GetString("format")
 	switch format {
 	case "json":
 		enc := json.NewEncoder(os.Stdout)
@@ -75,6 +236,11 @@ func queryExecutions(cmd *command, args []string) error {
 		return writer.Error()
 
 	default: // table
+		columns, err := parseQueryColumns(flagString(cmd, "columns"))
+		if err != nil {
+			return err
+		}
+
 		if len(executions) == 0 {
 			fmt.Println(infoStyle.Render("No executions found"))
 			return nil
@@ -83,39 +249,94 @@ func queryExecutions(cmd *command, args []string) error {
 		fmt.Println(titleStyle.Render("Execution History"))
 		fmt.Println()
 
+		tableColumns := make([]tableColumn, len(columns))
+		for i, column := range columns {
+			tableColumns[i] = column.tableColumn
+		}
+		output := newTable(tableColumns, flagBool(cmd, "wide"))
+		now := time.Now()
 		for _, exec := range executions {
-			toolColor := getToolColor(exec.Tool)
-			toolStyle := newStyle().Foreground(toolColor)
-
-			fmt.Printf("%s %s %s\n",
-				exec.Timestamp.Format("2006-01-02 15:04:05"),
-				toolStyle.Render(fmt.Sprintf("[%s]", exec.Tool)),
-				exec.Command,
-			)
-
-			if len(exec.PackagesAffected) > 0 {
-				fmt.Printf("  %s %s\n",
-					subtitleStyle.Render("Packages:"),
-					strings.Join(exec.PackagesAffected, ", "),
-				)
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = column.value(exec, now)
 			}
+			output.AddRow(cells...)
+		}
+		if err := output.Render(os.Stdout); err != nil {
+			return err
+		}
+		if opts.FailedOnly {
+			printCapturedOutput(os.Stdout, executions)
+		}
+		return nil
+	}
+}
 
-			if exec.ExitCode != 0 {
-				fmt.Printf("  %s %d\n",
-					errorStyle.Render("Exit code:"),
-					exec.ExitCode,
-				)
+// printCapturedOutput prints what each execution printed, for those
+// recorded with monitoring.process.capture_output on
+func printCapturedOutput(w io.Writer, executions []*core.ExecutionRecord) {
+	for _, exec := range executions {
+		if exec.Output == nil {
+			continue
+		}
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, subtitleStyle.Render(fmt.Sprintf("%s  %s (exit %d)",
+			exec.Timestamp.Local().Format("2006-01-02 15:04:05"), exec.Command, exec.ExitCode)))
+		for _, stream := range []struct{ name, text string }{
+			{"stdout", exec.Output.Stdout},
+			{"stderr", exec.Output.Stderr},
+		} {
+			if stream.text == "" {
+				continue
+			}
+			for _, line := range strings.Split(stream.text, "\n") {
+				_, _ = fmt.Fprintf(w, "  %s | %s\n", stream.name, line)
 			}
-			fmt.Println()
+		}
+		if exec.Output.Truncated {
+			_, _ = fmt.Fprintln(w, infoStyle.Render("  (lines between the first and last were not kept)"))
 		}
 	}
-
-	return nil
 }
 
 // showStats displays usage statistics
+// streamExecutionsNDJSON writes each matching execution as one line of JSON
+// as storage yields it, so memory stays flat for large result sets
+func streamExecutionsNDJSON(w io.Writer, store storage.Storage, opts storage.QueryOptions) error {
+	buffered := bufio.NewWriter(w)
+	enc := json.NewEncoder(buffered)
+	if err := store.StreamExecutions(opts, func(exec *core.ExecutionRecord) error {
+		return enc.Encode(exec)
+	}); err != nil {
+		return fmt.Errorf("failed to query executions: %w", err)
+	}
+	return buffered.Flush()
+}
+
+// commandPatternFromFlags compiles --match or --glob; only one may be set
+func commandPatternFromFlags(cmd *command) (*regexp.Regexp, error) {
+	match, glob := flagString(cmd, "match"), flagString(cmd, "glob")
+	switch {
+	case match != "" && glob != "":
+		return nil, fmt.Errorf("--match and --glob cannot be combined")
+	case match != "":
+		pattern, err := storage.CompileCommandMatch(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --match pattern: %w", err)
+		}
+		return pattern, nil
+	case glob != "":
+		pattern, err := storage.CompileCommandGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --glob pattern: %w", err)
+		}
+		return pattern, nil
+	}
+	return nil, nil
+}
+
 func showStats(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -130,39 +351,110 @@ func showStats(cmd *command, args []string) error {
 	weekly, _ := cmd.Flags().GetBool("weekly")
 	toolFilter, _ := cmd.Flags().GetString("tool")
 
-	opts := storage.QueryOptions{}
+	opts := storage.QueryOptions{Project: flagString(cmd, "project")}
 	if toolFilter != "" {
 		opts.Tool = core.NormalizeToolName(toolFilter)
 	}
 
+	if flagBool(cmd, "upgrades") {
+		return showUpgradeStats(cmd, store, opts.Tool)
+	}
+
+	title := "DIU Statistics"
+	period := "all"
 	if daily {
 		since := time.Now().Add(-24 * time.Hour)
 		opts.Since = &since
-		fmt.Println(titleStyle.Render("DIU Statistics (Last 24 Hours)"))
+		title, period = "DIU Statistics (Last 24 Hours)", "24h"
 	} else if weekly {
 		since := time.Now().Add(-7 * 24 * time.Hour)
 		opts.Since = &since
-		fmt.Println(titleStyle.Render("DIU Statistics (Last 7 Days)"))
-	} else {
-		fmt.Println(titleStyle.Render("DIU Statistics"))
+		title, period = "DIU Statistics (Last 7 Days)", "7d"
 	}
-	fmt.Println()
 
-	executions, err := store.GetExecutions(opts)
+	if flagBool(cmd, "rebuild") {
+		if err := store.UpdateStatistics(); err != nil {
+			return fmt.Errorf("failed to rebuild statistics: %w", err)
+		}
+	}
+
+	var executions []*core.ExecutionRecord
+	if statsNeedExecutions(cmd) {
+		executions, err = store.GetExecutions(opts)
+		if err != nil {
+			return fmt.Errorf("failed to get executions: %w", err)
+		}
+		if flagBool(cmd, "time") {
+			return showWaitingStats(cmd, period, executions)
+		}
+		if flagBool(cmd, "actions") {
+			return showActionStats(cmd, period, executions)
+		}
+		if flagBool(cmd, "scripts") {
+			return showScriptStats(cmd, period, executions)
+		}
+	}
+
+	// Storage answers all-time counts from the daily aggregates kept as
+	// executions are stored, without reading the executions.
+	counted, err := store.Aggregate(storage.AggregateOptions{Query: opts, GroupBy: storage.GroupByTool})
 	if err != nil {
-		return fmt.Errorf("failed to get executions: %w", err)
+		return fmt.Errorf("failed to count executions: %w", err)
+	}
+	total := counted.Total.Count
+	toolCounts := make(map[string]int, len(counted.Groups))
+	for _, group := range counted.Groups {
+		toolCounts[group.Key] = group.Count
 	}
 
-	toolCounts := make(map[string]int)
-	for _, exec := range executions {
-		toolCounts[exec.Tool]++
+	by := flagString(cmd, "by")
+	var buckets []statsBucket
+	bucketTotal := 0
+	if by != "" {
+		buckets, bucketTotal, err = countStatsBy(store, opts, by, executions)
+		if err != nil {
+			return err
+		}
 	}
 
+	if jsonOutput(cmd) {
+		return printStatsJSON(cmd, store, period, executions, total, toolCounts, buckets)
+	}
+
+	fmt.Println(titleStyle.Render(title))
+	fmt.Println()
 	fmt.Printf("%s %d\n",
 		infoStyle.Render("Total executions:"),
-		len(executions),
+		total,
 	)
 
+	if by != "" {
+		top, _ := cmd.Flags().GetInt("top")
+		printStatsBuckets(by, buckets, bucketTotal, top)
+		return nil
+	}
+
+	heatmap, _ := cmd.Flags().GetBool("heatmap")
+	timeline, _ := cmd.Flags().GetBool("timeline")
+	if heatmap || timeline {
+		if timeline {
+			end := time.Now()
+			start := end.AddDate(0, 0, -(defaultTimelineDays - 1))
+			if opts.Since != nil {
+				start = *opts.Since
+			}
+			fmt.Println()
+			fmt.Println(subtitleStyle.Render("Daily executions:"))
+			fmt.Print(renderTimeline(executions, start, end))
+		}
+		if heatmap {
+			fmt.Println()
+			fmt.Println(subtitleStyle.Render("Activity by weekday and hour:"))
+			fmt.Print(renderHeatmap(executions))
+		}
+		return nil
+	}
+
 	stats, _ := store.GetStatistics()
 	if stats.MostActiveDay != "" && !daily && !weekly {
 		fmt.Printf("%s %s\n",
@@ -181,20 +473,14 @@ func showStats(cmd *command, args []string) error {
 
 	top, _ := cmd.Flags().GetInt("top")
 	if top > 0 {
-		packages, _ := store.GetPackages(core.NormalizeToolName(toolFilter))
-		sort.Slice(packages, func(i, j int) bool {
-			if packages[i].UsageCount == packages[j].UsageCount {
-				return packages[i].Name < packages[j].Name
-			}
-			return packages[i].UsageCount > packages[j].UsageCount
-		})
+		packages := topPackages(store, opts.Tool, top)
+		if opts.Project != "" {
+			packages = topExecutedPackages(executions, top)
+		}
 		fmt.Println()
 		fmt.Printf(subtitleStyle.Render("Top %d packages:\n"), top)
 
 		for i, pkg := range packages {
-			if i >= top {
-				break
-			}
 			fmt.Printf("  %d. %s (%s) - used %d times\n",
 				i+1,
 				pkg.Name,
@@ -206,3 +492,97 @@ func showStats(cmd *command, args []string) error {
 
 	return nil
 }
+
+// statsReport is the --json form of diu stats
+type statsReport struct {
+	Period          string              `json:"period"`
+	TotalExecutions int                 `json:"total_executions"`
+	MostActiveDay   string              `json:"most_active_day,omitempty"`
+	Tools           map[string]int      `json:"tools"`
+	TopPackages     []*core.PackageInfo `json:"top_packages,omitempty"`
+	By              string              `json:"by,omitempty"`
+	Buckets         []statsBucket       `json:"buckets,omitempty"`
+}
+
+// statsNeedExecutions reports whether diu stats must read executions, as
+// opposed to counting them with Aggregate, to show what cmd asks for
+func statsNeedExecutions(cmd *command) bool {
+	by := flagString(cmd, "by")
+	return flagString(cmd, "project") != "" || (by != "" && by != statsByHour && by != statsByWeekday) ||
+		flagBool(cmd, "heatmap") || flagBool(cmd, "timeline") || flagBool(cmd, "time") || flagBool(cmd, "actions") ||
+		flagBool(cmd, "scripts")
+}
+
+func printStatsJSON(cmd *command, store storage.Storage, period string, executions []*core.ExecutionRecord, total int, toolCounts map[string]int, buckets []statsBucket) error {
+	report := statsReport{
+		Period:          period,
+		TotalExecutions: total,
+		Tools:           toolCounts,
+	}
+
+	if by := flagString(cmd, "by"); by != "" {
+		report.By, report.Buckets = by, buckets
+		return printJSON(report)
+	}
+
+	if period == "all" {
+		if stats, err := store.GetStatistics(); err == nil {
+			report.MostActiveDay = stats.MostActiveDay
+		}
+	}
+	if top := flagInt(cmd, "top"); top > 0 {
+		report.TopPackages = topPackages(store, core.NormalizeToolName(flagString(cmd, "tool")), top)
+		if flagString(cmd, "project") != "" {
+			report.TopPackages = topExecutedPackages(executions, top)
+		}
+	}
+	return printJSON(report)
+}
+
+// topExecutedPackages ranks the packages affected by executions, for stats
+// scoped to a subset of history such as one project. UsageCount is the
+// number of those executions that affected the package.
+func topExecutedPackages(executions []*core.ExecutionRecord, limit int) []*core.PackageInfo {
+	byKey := make(map[string]*core.PackageInfo)
+	var packages []*core.PackageInfo
+	for _, exec := range executions {
+		for _, name := range exec.PackagesAffected {
+			key := exec.Tool + "/" + name
+			pkg, ok := byKey[key]
+			if !ok {
+				pkg = &core.PackageInfo{Name: name, Tool: exec.Tool}
+				byKey[key] = pkg
+				packages = append(packages, pkg)
+			}
+			pkg.UsageCount++
+			if exec.Timestamp.After(pkg.LastUsed) {
+				pkg.LastUsed = exec.Timestamp
+			}
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].UsageCount == packages[j].UsageCount {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].UsageCount > packages[j].UsageCount
+	})
+	if len(packages) > limit {
+		packages = packages[:limit]
+	}
+	return packages
+}
+
+// topPackages returns up to limit packages by descending usage count
+func topPackages(store storage.Storage, tool string, limit int) []*core.PackageInfo {
+	packages, _ := store.GetPackages(tool)
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].UsageCount == packages[j].UsageCount {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].UsageCount > packages[j].UsageCount
+	})
+	if len(packages) > limit {
+		packages = packages[:limit]
+	}
+	return packages
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yowainwright/diu/internal/report"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// showReport prints the daily or weekly summary and optionally emails it
+// through the configured SMTP server
+func showReport(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	period := report.PeriodDaily
+	if flagBool(cmd, "weekly") {
+		period = report.PeriodWeekly
+	}
+	summary, err := report.Generate(store, period, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !flagBool(cmd, "email") {
+		fmt.Print(summary.Text())
+		return nil
+	}
+	if err := report.SendEmail(config.Reporting.SMTP, summary); err != nil {
+		return err
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Sent %s report to %d recipients", period, len(config.Reporting.SMTP.To))))
+	return nil
+}
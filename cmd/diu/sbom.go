@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	formatCycloneDX = "cyclonedx"
+	formatSPDX      = "spdx"
+
+	cycloneDXSpecVersion = "1.5"
+	spdxVersion          = "SPDX-2.3"
+	spdxNoAssertion      = "NOASSERTION"
+)
+
+// cycloneDXBOM is a CycloneDX JSON document
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// spdxDocument is an SPDX JSON document
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// generateSBOM writes a software bill of materials of the tracked packages
+func generateSBOM(cmd *command, args []string) error {
+	format := flagString(cmd, "format")
+	switch format {
+	case formatCycloneDX, formatSPDX:
+	default:
+		return fmt.Errorf("unsupported SBOM format: %s (use cyclonedx or spdx)", format)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	packages, err := store.GetPackages(core.NormalizeToolName(flagString(cmd, "tool")))
+	if err != nil {
+		return fmt.Errorf("failed to get packages: %w", err)
+	}
+	sortSBOMPackages(packages)
+
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	write := func(w io.Writer) error {
+		return writeSBOM(w, format, host, time.Now().UTC(), packages)
+	}
+
+	out := flagString(cmd, "out")
+	if out == "" {
+		return write(os.Stdout)
+	}
+	if err := writeExportFile(out, write); err != nil {
+		return err
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Wrote %s SBOM of %d packages to %s", format, len(packages), out)))
+	return nil
+}
+
+// writeSBOM writes packages as a CycloneDX or SPDX JSON document describing
+// host at time now
+func writeSBOM(w io.Writer, format, host string, now time.Time, packages []*core.PackageInfo) error {
+	var document interface{}
+	if format == formatSPDX {
+		document = spdxDocumentFor(host, now, packages)
+	} else {
+		document = cycloneDXBOMFor(host, now, packages)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(document)
+}
+
+func cycloneDXBOMFor(host string, now time.Time, packages []*core.PackageInfo) cycloneDXBOM {
+	components := make([]cycloneDXComponent, 0, len(packages))
+	for _, pkg := range packages {
+		component := cycloneDXComponent{
+			Type:       "application",
+			BOMRef:     pkg.Tool + "/" + pkg.Name,
+			Name:       pkg.Name,
+			Version:    pkg.Version,
+			PURL:       packageURL(pkg),
+			Properties: []cycloneDXProperty{{Name: "diu:tool", Value: pkg.Tool}},
+		}
+		if pkg.License != "" {
+			component.Licenses = []cycloneDXLicense{{Expression: pkg.License}}
+		}
+		components = append(components, component)
+	}
+	return cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + core.NewID(),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: now.Format(time.RFC3339),
+			Tools:     cycloneDXTools{Components: []cycloneDXComponent{{Type: "application", Name: "diu", Version: core.Version}}},
+			Component: cycloneDXComponent{Type: "device", Name: host},
+		},
+		Components: components,
+	}
+}
+
+func spdxDocumentFor(host string, now time.Time, packages []*core.PackageInfo) spdxDocument {
+	document := spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "diu-" + host,
+		DocumentNamespace: "https://spdx.org/spdxdocs/diu-" + url.PathEscape(host) + "-" + core.NewID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  now.Format(time.RFC3339),
+			Creators: []string{"Tool: diu-" + core.Version},
+		},
+		Packages:      make([]spdxPackage, 0, len(packages)),
+		Relationships: make([]spdxRelationship, 0, len(packages)),
+	}
+	for i, pkg := range packages {
+		id := "SPDXRef-Package-" + strconv.Itoa(i+1)
+		declared := pkg.License
+		if declared == "" {
+			declared = spdxNoAssertion
+		}
+		document.Packages = append(document.Packages, spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           id,
+			VersionInfo:      pkg.Version,
+			Supplier:         spdxNoAssertion,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  declared,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  packageURL(pkg),
+			}},
+			Comment: "Installed with " + pkg.Tool,
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{
+			SPDXElementID:      document.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+	}
+	return document
+}
+
+// purlTypes maps tools to the package URL type of the registry they install
+// from; other tools get the generic type
+var purlTypes = map[string]string{
+	core.ToolNPM:    "npm",
+	core.ToolPNPM:   "npm",
+	core.ToolBun:    "npm",
+	core.ToolYarn:   "npm",
+	core.ToolPip:    "pypi",
+	core.ToolUV:     "pypi",
+	core.ToolPoetry: "pypi",
+	core.ToolGem:    "gem",
+	core.ToolCargo:  "cargo",
+	core.ToolGo:     "golang",
+}
+
+// packageURL returns the package URL (purl) identifying pkg
+func packageURL(pkg *core.PackageInfo) string {
+	purlType, ok := purlTypes[pkg.Tool]
+	if !ok {
+		purlType = "generic"
+	}
+	name := pkg.Name
+	if purlType == "pypi" {
+		name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	}
+	if purlType == "golang" && !strings.Contains(name, "/") {
+		purlType = "generic"
+	}
+
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = purlEscape(segment)
+	}
+	purl := "pkg:" + purlType + "/" + strings.Join(segments, "/")
+	if pkg.Version != "" {
+		purl += "@" + purlEscape(pkg.Version)
+	}
+	return purl
+}
+
+// purlEscaper escapes the characters url.PathEscape leaves that separate
+// parts of a purl
+var purlEscaper = strings.NewReplacer("@", "%40", "+", "%2B", ":", "%3A")
+
+func purlEscape(segment string) string {
+	return purlEscaper.Replace(url.PathEscape(segment))
+}
+
+// sortSBOMPackages orders packages by tool, then name
+func sortSBOMPackages(packages []*core.PackageInfo) {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Tool == packages[j].Tool {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Tool < packages[j].Tool
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/project"
+)
+
+// scriptPeriodTitles names the --daily and --weekly periods in the diu
+// stats --scripts title
+var scriptPeriodTitles = map[string]string{
+	"24h": "package.json Scripts (Last 24 Hours)",
+	"7d":  "package.json Scripts (Last 7 Days)",
+}
+
+// projectScripts is how often the scripts of one package.json were run
+type projectScripts struct {
+	Project  string         `json:"project"`
+	Root     string         `json:"root,omitempty"`
+	Runs     int            `json:"runs"`
+	Scripts  []scriptBucket `json:"scripts"`
+	NeverRun []string       `json:"never_run,omitempty"`
+}
+
+// scriptBucket is the runs of one script
+type scriptBucket struct {
+	Script  string    `json:"script"`
+	Count   int       `json:"count"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// scriptReport is the --json form of diu stats --scripts
+type scriptReport struct {
+	Period   string           `json:"period"`
+	Projects []projectScripts `json:"projects"`
+}
+
+// countScripts counts the script runs among executions per package.json,
+// and lists the scripts each package.json still defines that were never
+// run. A package.json is named by the project of its latest run. Runs
+// recorded without the package.json's directory are grouped by project and
+// have no never-run list. Projects are ordered by descending runs and each
+// project's scripts by descending count.
+func countScripts(executions []*core.ExecutionRecord) []projectScripts {
+	type scriptGroup struct {
+		entry   projectScripts
+		latest  time.Time
+		buckets map[string]*scriptBucket
+	}
+	groups := make(map[string]*scriptGroup)
+	for _, exec := range executions {
+		script, _ := exec.Metadata["script"].(string)
+		if script == "" {
+			continue
+		}
+		root, _ := exec.Metadata[project.MetadataScriptRoot].(string)
+		name := executionProject(exec)
+		key := root
+		if root == "" {
+			key = "\x00" + name
+		} else if exec.ProjectName == "" {
+			name = filepath.Base(root)
+		}
+
+		group := groups[key]
+		if group == nil {
+			group = &scriptGroup{entry: projectScripts{Root: root}, buckets: make(map[string]*scriptBucket)}
+			groups[key] = group
+		}
+		if group.entry.Project == "" || exec.Timestamp.After(group.latest) {
+			group.entry.Project = name
+			group.latest = exec.Timestamp
+		}
+		group.entry.Runs++
+
+		bucket := group.buckets[script]
+		if bucket == nil {
+			bucket = &scriptBucket{Script: script}
+			group.buckets[script] = bucket
+		}
+		bucket.Count++
+		if exec.Timestamp.After(bucket.LastRun) {
+			bucket.LastRun = exec.Timestamp
+		}
+	}
+
+	projects := make([]projectScripts, 0, len(groups))
+	for _, group := range groups {
+		entry := group.entry
+		for _, bucket := range group.buckets {
+			entry.Scripts = append(entry.Scripts, *bucket)
+		}
+		sort.Slice(entry.Scripts, func(i, j int) bool {
+			if entry.Scripts[i].Count != entry.Scripts[j].Count {
+				return entry.Scripts[i].Count > entry.Scripts[j].Count
+			}
+			return entry.Scripts[i].Script < entry.Scripts[j].Script
+		})
+		if root, defined, ok := project.PackageScripts(entry.Root); ok && root == entry.Root {
+			for _, script := range defined {
+				if group.buckets[script] == nil {
+					entry.NeverRun = append(entry.NeverRun, script)
+				}
+			}
+		}
+		projects = append(projects, entry)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Runs != projects[j].Runs {
+			return projects[i].Runs > projects[j].Runs
+		}
+		if projects[i].Project != projects[j].Project {
+			return projects[i].Project < projects[j].Project
+		}
+		return projects[i].Root < projects[j].Root
+	})
+	return projects
+}
+
+// showScriptStats prints which package.json scripts are run most in each
+// project, and which scripts a project defines but never runs
+func showScriptStats(cmd *command, period string, executions []*core.ExecutionRecord) error {
+	report := scriptReport{Period: period, Projects: countScripts(executions)}
+	if jsonOutput(cmd) {
+		return printJSON(report)
+	}
+
+	title, ok := scriptPeriodTitles[period]
+	if !ok {
+		title = "package.json Scripts"
+	}
+	fmt.Println(titleStyle.Render(title))
+	if len(report.Projects) == 0 {
+		fmt.Println()
+		fmt.Println(infoStyle.Render("No script runs found"))
+		return nil
+	}
+
+	top := flagInt(cmd, "top")
+	now := time.Now()
+	for _, entry := range report.Projects {
+		output := newTable([]tableColumn{
+			{Header: "SCRIPT", MaxWidth: packageNameColumnWidth},
+			{Header: "RUNS", AlignRight: true},
+			{Header: "LAST RUN"},
+		}, false)
+		for i, script := range entry.Scripts {
+			if top > 0 && i >= top {
+				break
+			}
+			output.AddRow(script.Script, strconv.Itoa(script.Count), formatRelativeTime(script.LastRun, now))
+		}
+
+		fmt.Println()
+		heading := entry.Project
+		if entry.Root != "" {
+			heading += " (" + entry.Root + ")"
+		}
+		fmt.Printf("%s %d runs\n", heading+":", entry.Runs)
+		_ = output.Render(os.Stdout)
+		if top > 0 && len(entry.Scripts) > top {
+			fmt.Println(subtitleStyle.Render(fmt.Sprintf("  ... %d more (raise --top to show)", len(entry.Scripts)-top)))
+		}
+		if len(entry.NeverRun) > 0 {
+			fmt.Println(subtitleStyle.Render("  Never run: " + strings.Join(entry.NeverRun, ", ")))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/logging"
+	"github.com/yowainwright/diu/internal/server"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// runServer serves team syncs in the foreground until interrupted
+func runServer(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if host := flagString(cmd, "host"); host != "" {
+		config.Server.Host = host
+	}
+	if port := flagInt(cmd, "port"); port != 0 {
+		config.Server.Port = port
+	}
+	if len(config.Server.Users) == 0 {
+		return fmt.Errorf("no server users: create a token with diu server token <user>")
+	}
+
+	// The server runs in the foreground, so it logs to stderr rather than
+	// the daemon log.
+	logConfig := config.Daemon
+	logConfig.LogFile = ""
+	logger, closer, err := logging.New(logConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer func() {
+		_ = closer.Close()
+	}()
+
+	srv, err := server.New(config, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srv.Close()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return srv.ListenAndServe(ctx)
+}
+
+// createServerToken issues a token for a user, or revokes it, and keeps its
+// hash in server.users
+func createServerToken(cmd *command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("user name required")
+	}
+	user := args[0]
+	if !core.ValidTenantName(user) {
+		return fmt.Errorf("invalid user name %q: use letters, digits, '.', '_', and '-'", user)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if flagBool(cmd, "revoke") {
+		if _, ok := config.Server.Users[user]; !ok {
+			return fmt.Errorf("no server user %s", user)
+		}
+		delete(config.Server.Users, user)
+		if err := config.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("Revoked the token for %s", user)))
+		return nil
+	}
+
+	token, hash, err := server.NewToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	if config.Server.Users == nil {
+		config.Server.Users = make(map[string]string)
+	}
+	config.Server.Users[user] = hash
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Created a token for %s; it is not shown again", user)))
+	fmt.Println(token)
+	fmt.Println(infoStyle.Render(fmt.Sprintf("On their machines: diu config set sync.token %s", token)))
+	return nil
+}
+
+// showTeamStats prints the tools and packages used across the team server's
+// data, read directly from its data directory
+func showTeamStats(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	opts := server.StatsOptions{Limit: flagInt(cmd, "top")}
+	if last := flagString(cmd, "last"); last != "" {
+		duration, err := core.ParseDuration(last)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+		opts.Last = duration
+	}
+
+	store, err := storage.NewTenantStorage(config, config.ServerDataDir())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	stats, err := server.Team(store, opts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(stats)
+	}
+	fmt.Println(titleStyle.Render("Team Usage"))
+	fmt.Printf("  %d users, %d machines, %d executions\n", stats.Users, stats.Machines, stats.TotalExecutions)
+	printTeamUsage("Tools", stats.Tools)
+	printTeamUsage("Packages", stats.Packages)
+	return nil
+}
+
+func printTeamUsage(title string, usage []server.Usage) {
+	if len(usage) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println(infoStyle.Render(title))
+	fmt.Printf("  %-30s %6s %10s  %s\n", "NAME", "USERS", "EXECUTIONS", "LAST USED")
+	for _, u := range usage {
+		name := u.Name
+		if u.Tool != "" {
+			name = u.Tool + "/" + u.Name
+		}
+		fmt.Printf("  %-30s %6d %10d  %s\n", truncate(name, 30), u.Users, u.Executions, u.LastUsed.Local().Format("2006-01-02"))
+	}
+}
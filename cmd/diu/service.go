@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const (
+	launchdLabel          = "com.github.yowainwright.diu"
+	launchctlCommandName  = "launchctl"
+	serviceManagerLaunchd = "launchd"
+
+	systemdServiceUnit    = "diu.service"
+	systemdSocketUnit     = "diu.socket"
+	systemctlCommandName  = "systemctl"
+	systemctlUserFlag     = "--user"
+	serviceManagerSystemd = "systemd"
+
+	windowsServiceName    = "diu"
+	serviceManagerWindows = "windows"
+)
+
+// serviceManager installs and controls the daemon under an OS service manager
+type serviceManager interface {
+	Name() string
+	Installed() bool
+	Install(config *core.Config, execPath string) error
+	Uninstall() error
+	Start() error
+	Stop() error
+	Running() bool
+}
+
+// serviceCommandRunner runs service manager commands (overridable for testing)
+var serviceCommandRunner = func(name string, args ...string) ([]byte, error) {
+	// #nosec G204 -- callers pass fixed service manager commands with DIU-generated arguments.
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// serviceGOOS is the platform used to pick a service manager (overridable for testing)
+var serviceGOOS = runtime.GOOS
+
+// serviceManagerByName returns the service manager for an explicit name
+func serviceManagerByName(name string) (serviceManager, error) {
+	switch name {
+	case serviceManagerLaunchd:
+		return newLaunchdManager()
+	case serviceManagerSystemd:
+		return newSystemdManager()
+	case serviceManagerWindows:
+		return newWindowsServiceManager()
+	default:
+		return nil, fmt.Errorf("unsupported service manager: %s", name)
+	}
+}
+
+// defaultServiceManagerName returns the service manager for the current platform
+func defaultServiceManagerName() (string, error) {
+	switch serviceGOOS {
+	case "darwin":
+		return serviceManagerLaunchd, nil
+	case "linux":
+		return serviceManagerSystemd, nil
+	case "windows":
+		return serviceManagerWindows, nil
+	default:
+		return "", fmt.Errorf("no supported service manager for %s", serviceGOOS)
+	}
+}
+
+// installedServiceManager returns the service manager the daemon is installed under, if any
+func installedServiceManager() serviceManager {
+	name, err := defaultServiceManagerName()
+	if err != nil {
+		return nil
+	}
+	manager, err := serviceManagerByName(name)
+	if err != nil || !manager.Installed() {
+		return nil
+	}
+	return manager
+}
+
+// installDaemonService installs the daemon as a user service
+func installDaemonService(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	name := ""
+	for flag, manager := range map[string]string{
+		"launchd": serviceManagerLaunchd,
+		"systemd": serviceManagerSystemd,
+		"windows": serviceManagerWindows,
+	} {
+		if !flagBool(cmd, flag) {
+			continue
+		}
+		if name != "" {
+			return fmt.Errorf("only one of --launchd, --systemd, or --windows may be given")
+		}
+		name = manager
+	}
+	if name == "" {
+		name, err = defaultServiceManagerName()
+		if err != nil {
+			return err
+		}
+	}
+
+	manager, err := serviceManagerByName(name)
+	if err != nil {
+		return err
+	}
+	if flagBool(cmd, "socket") {
+		systemd, ok := manager.(*systemdManager)
+		if !ok {
+			return fmt.Errorf("--socket is only supported with systemd")
+		}
+		systemd.socketActivation = true
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	execPath, err = validateExecutablePath(execPath)
+	if err != nil {
+		return fmt.Errorf("invalid daemon executable path: %w", err)
+	}
+
+	if defaultDaemonChecker.IsRunning(config) {
+		if err := stopDaemonWithConfig(config); err != nil {
+			return err
+		}
+	}
+
+	if err := manager.Install(config, execPath); err != nil {
+		return err
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("DIU daemon installed as a %s service", manager.Name())))
+	return nil
+}
+
+// uninstallDaemonService removes the daemon user service
+func uninstallDaemonService(cmd *command, args []string) error {
+	manager := installedServiceManager()
+	if manager == nil {
+		fmt.Println(infoStyle.Render("DIU daemon service is not installed"))
+		return nil
+	}
+
+	if err := manager.Uninstall(); err != nil {
+		return err
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("DIU daemon %s service removed", manager.Name())))
+	return nil
+}
+
+// launchdManager manages the daemon as a per-user LaunchAgent
+type launchdManager struct {
+	plistPath string
+	domain    string
+}
+
+func newLaunchdManager() (serviceManager, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return &launchdManager{
+		plistPath: filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist"),
+		domain:    "gui/" + strconv.Itoa(os.Getuid()),
+	}, nil
+}
+
+func (m *launchdManager) Name() string {
+	return serviceManagerLaunchd
+}
+
+func (m *launchdManager) Installed() bool {
+	_, err := os.Stat(m.plistPath)
+	return err == nil
+}
+
+func (m *launchdManager) Install(config *core.Config, execPath string) error {
+	plist, err := launchdPlist(config, execPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.plistPath), core.OwnerDirectoryMode); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.WriteFile(m.plistPath, plist, core.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write LaunchAgent: %w", err)
+	}
+
+	if m.Running() {
+		_ = m.Stop()
+	}
+	return m.Start()
+}
+
+func (m *launchdManager) Uninstall() error {
+	if m.Running() {
+		if err := m.Stop(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(m.plistPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove LaunchAgent: %w", err)
+	}
+	return nil
+}
+
+func (m *launchdManager) Start() error {
+	if m.Running() {
+		if output, err := serviceCommandRunner(launchctlCommandName, "kickstart", m.serviceTarget()); err != nil {
+			return fmt.Errorf("launchctl kickstart failed: %w: %s", err, bytes.TrimSpace(output))
+		}
+		return nil
+	}
+	if output, err := serviceCommandRunner(launchctlCommandName, "bootstrap", m.domain, m.plistPath); err != nil {
+		return fmt.Errorf("launchctl bootstrap failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func (m *launchdManager) Stop() error {
+	if output, err := serviceCommandRunner(launchctlCommandName, "bootout", m.serviceTarget()); err != nil {
+		return fmt.Errorf("launchctl bootout failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func (m *launchdManager) Running() bool {
+	_, err := serviceCommandRunner(launchctlCommandName, "print", m.serviceTarget())
+	return err == nil
+}
+
+func (m *launchdManager) serviceTarget() string {
+	return m.domain + "/" + launchdLabel
+}
+
+// launchdPlist renders the LaunchAgent property list for the daemon
+func launchdPlist(config *core.Config, execPath string) ([]byte, error) {
+	escape := func(value string) (string, error) {
+		var buf bytes.Buffer
+		if err := xml.EscapeText(&buf, []byte(value)); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	values := []string{launchdLabel, config.Daemon.StdoutLogFile(), config.Daemon.DataDir}
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if escaped[i], err = escape(value); err != nil {
+			return nil, fmt.Errorf("failed to render LaunchAgent: %w", err)
+		}
+	}
+
+	var arguments strings.Builder
+	for _, arg := range append([]string{execPath}, daemonStartArgs(config)...) {
+		value, err := escape(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render LaunchAgent: %w", err)
+		}
+		fmt.Fprintf(&arguments, "\t\t<string>%s</string>\n", value)
+	}
+
+	logPath := escaped[1]
+	if logPath == "" {
+		logPath = os.DevNull
+	}
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ProcessType</key>
+	<string>Background</string>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, escaped[0], arguments.String(), escaped[2], logPath, logPath)), nil
+}
+
+// systemdManager manages the daemon as a systemd user service, optionally
+// started on demand through a socket unit
+type systemdManager struct {
+	unitDir          string
+	socketActivation bool
+}
+
+func newSystemdManager() (serviceManager, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	manager := &systemdManager{unitDir: filepath.Join(configHome, "systemd", "user")}
+	if _, err := os.Stat(manager.socketPath()); err == nil {
+		manager.socketActivation = true
+	}
+	return manager, nil
+}
+
+func (m *systemdManager) Name() string {
+	return serviceManagerSystemd
+}
+
+func (m *systemdManager) Installed() bool {
+	_, err := os.Stat(m.servicePath())
+	return err == nil
+}
+
+func (m *systemdManager) Install(config *core.Config, execPath string) error {
+	if err := os.MkdirAll(m.unitDir, core.OwnerDirectoryMode); err != nil {
+		return fmt.Errorf("failed to create systemd user unit directory: %w", err)
+	}
+	if err := os.WriteFile(m.servicePath(), []byte(systemdServiceUnitFile(execPath, daemonStartArgs(config))), core.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write systemd service unit: %w", err)
+	}
+	if m.socketActivation {
+		if err := os.WriteFile(m.socketPath(), []byte(systemdSocketUnitFile(config.Daemon.SocketPath)), core.PrivateFileMode); err != nil {
+			return fmt.Errorf("failed to write systemd socket unit: %w", err)
+		}
+	} else if err := os.Remove(m.socketPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove systemd socket unit: %w", err)
+	}
+
+	if err := m.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if m.socketActivation {
+		if err := m.systemctl("enable", systemdSocketUnit); err != nil {
+			return err
+		}
+	}
+	if err := m.systemctl("enable", systemdServiceUnit); err != nil {
+		return err
+	}
+	return m.Start()
+}
+
+func (m *systemdManager) Uninstall() error {
+	if m.socketActivation {
+		if err := m.systemctl("disable", "--now", systemdSocketUnit); err != nil {
+			return err
+		}
+	}
+	if err := m.systemctl("disable", "--now", systemdServiceUnit); err != nil {
+		return err
+	}
+	for _, path := range []string{m.servicePath(), m.socketPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove systemd unit %s: %w", path, err)
+		}
+	}
+	return m.systemctl("daemon-reload")
+}
+
+func (m *systemdManager) Start() error {
+	if m.socketActivation {
+		if err := m.systemctl("start", systemdSocketUnit); err != nil {
+			return err
+		}
+	}
+	return m.systemctl("start", systemdServiceUnit)
+}
+
+func (m *systemdManager) Stop() error {
+	if m.socketActivation {
+		if err := m.systemctl("stop", systemdSocketUnit); err != nil {
+			return err
+		}
+	}
+	return m.systemctl("stop", systemdServiceUnit)
+}
+
+func (m *systemdManager) Running() bool {
+	_, err := serviceCommandRunner(systemctlCommandName, systemctlUserFlag, "is-active", "--quiet", systemdServiceUnit)
+	return err == nil
+}
+
+func (m *systemdManager) systemctl(args ...string) error {
+	output, err := serviceCommandRunner(systemctlCommandName, append([]string{systemctlUserFlag}, args...)...)
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func (m *systemdManager) servicePath() string {
+	return filepath.Join(m.unitDir, systemdServiceUnit)
+}
+
+func (m *systemdManager) socketPath() string {
+	return filepath.Join(m.unitDir, systemdSocketUnit)
+}
+
+// systemdQuote quotes a value for use in a systemd unit command line
+func systemdQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "%", "%%")
+	return `"` + value + `"`
+}
+
+// systemdServiceUnitFile renders the user service unit that runs execPath
+// with args
+func systemdServiceUnitFile(execPath string, args []string) string {
+	command := []string{systemdQuote(execPath)}
+	for _, arg := range args {
+		if strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./") != "" {
+			arg = systemdQuote(arg)
+		}
+		command = append(command, arg)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=DIU package manager execution tracker
+Documentation=https://github.com/yowainwright/diu
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`, strings.Join(command, " "))
+}
+
+// systemdSocketUnitFile renders the socket unit that activates the daemon
+func systemdSocketUnitFile(socketPath string) string {
+	return fmt.Sprintf(`[Unit]
+Description=DIU daemon socket
+
+[Socket]
+ListenStream=%s
+SocketMode=0600
+
+[Install]
+WantedBy=sockets.target
+`, strings.ReplaceAll(socketPath, "%", "%%"))
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/yowainwright/diu/internal/daemon"
+)
+
+func newWindowsServiceManager() (serviceManager, error) {
+	return nil, fmt.Errorf("Windows services are only supported on Windows")
+}
+
+func runningAsWindowsService() bool {
+	return false
+}
+
+func runWindowsService(d *daemon.Daemon) error {
+	return fmt.Errorf("Windows services are only supported on Windows")
+}
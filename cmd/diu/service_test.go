@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordedServiceCommand struct {
+	name string
+	args []string
+}
+
+func stubServiceCommands(t *testing.T, goos string, loaded bool) *[]recordedServiceCommand {
+	t.Helper()
+
+	var calls []recordedServiceCommand
+	oldRunner := serviceCommandRunner
+	oldGOOS := serviceGOOS
+	serviceGOOS = goos
+	serviceCommandRunner = func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, recordedServiceCommand{name: name, args: args})
+		if len(args) > 0 && args[0] == "print" && !loaded {
+			return nil, errors.New("not loaded")
+		}
+		return nil, nil
+	}
+	t.Cleanup(func() {
+		serviceCommandRunner = oldRunner
+		serviceGOOS = oldGOOS
+	})
+	return &calls
+}
+
+func TestInstallDaemonServiceLaunchd(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	calls := stubServiceCommands(t, "darwin", false)
+
+	restore := SetDaemonChecker(MockDaemonChecker{isRunning: false})
+	defer restore()
+
+	cmd := &command{}
+	var launchd bool
+	cmd.Flags().BoolVar(&launchd, "launchd", true, "")
+
+	output := captureStdout(t, func() {
+		if err := installDaemonService(cmd, nil); err != nil {
+			t.Fatalf("installDaemonService failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "launchd service") {
+		t.Fatalf("Expected install message, got %q", output)
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist")
+	data, err := os.ReadFile(plistPath)
+	if err != nil {
+		t.Fatalf("Expected LaunchAgent plist: %v", err)
+	}
+	plist := string(data)
+	for _, want := range []string{launchdLabel, "<string>daemon</string>", "<string>--foreground</string>", config.Daemon.StdoutLogFile()} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("Expected plist to contain %q", want)
+		}
+	}
+
+	bootstrapped := false
+	for _, call := range *calls {
+		if call.name == launchctlCommandName && len(call.args) == 3 && call.args[0] == "bootstrap" && call.args[2] == plistPath {
+			bootstrapped = true
+		}
+	}
+	if !bootstrapped {
+		t.Errorf("Expected launchctl bootstrap call, got %+v", *calls)
+	}
+
+	if manager := installedServiceManager(); manager == nil || manager.Name() != serviceManagerLaunchd {
+		t.Errorf("Expected launchd to be detected as installed, got %v", manager)
+	}
+}
+
+func TestUninstallDaemonServiceLaunchd(t *testing.T) {
+	setupTestHomeConfig(t)
+	calls := stubServiceCommands(t, "darwin", true)
+
+	homeDir, _ := os.UserHomeDir()
+	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist")
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0o700); err != nil {
+		t.Fatalf("Failed to create LaunchAgents dir: %v", err)
+	}
+	if err := os.WriteFile(plistPath, []byte("<plist/>"), 0o600); err != nil {
+		t.Fatalf("Failed to write plist: %v", err)
+	}
+
+	captureStdout(t, func() {
+		if err := uninstallDaemonService(&command{}, nil); err != nil {
+			t.Fatalf("uninstallDaemonService failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(plistPath); !os.IsNotExist(err) {
+		t.Errorf("Expected plist to be removed, stat err = %v", err)
+	}
+	bootedOut := false
+	for _, call := range *calls {
+		if len(call.args) > 0 && call.args[0] == "bootout" {
+			bootedOut = true
+		}
+	}
+	if !bootedOut {
+		t.Errorf("Expected launchctl bootout call, got %+v", *calls)
+	}
+}
+
+func TestInstalledServiceManagerNoneOnUnsupportedPlatform(t *testing.T) {
+	setupTestHomeConfig(t)
+	stubServiceCommands(t, "plan9", false)
+
+	if manager := installedServiceManager(); manager != nil {
+		t.Errorf("Expected no service manager, got %s", manager.Name())
+	}
+}
+
+func TestLaunchdPlistEscapesPaths(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	plist, err := launchdPlist(config, "/Applications/A&B/diu")
+	if err != nil {
+		t.Fatalf("launchdPlist failed: %v", err)
+	}
+	if !strings.Contains(string(plist), "/Applications/A&amp;B/diu") {
+		t.Errorf("Expected executable path to be XML escaped, got %s", plist)
+	}
+}
+
+func TestDaemonStartArgsPassConfigFlag(t *testing.T) {
+	setupTestHomeConfig(t)
+	path := filepath.Join(t.TempDir(), "my config.toml")
+	configFlagPath = path
+	t.Cleanup(func() { configFlagPath = "" })
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	args := daemonStartArgs(config)
+	if got := strings.Join(args, " "); got != "daemon start --foreground --config "+path {
+		t.Fatalf("daemonStartArgs = %q", got)
+	}
+
+	unit := systemdServiceUnitFile("/usr/bin/diu", args)
+	if want := `ExecStart="/usr/bin/diu" daemon start --foreground --config "` + path + `"`; !strings.Contains(unit, want) {
+		t.Errorf("Expected unit to contain %q, got %s", want, unit)
+	}
+	plist, err := launchdPlist(config, "/usr/bin/diu")
+	if err != nil {
+		t.Fatalf("launchdPlist failed: %v", err)
+	}
+	if !strings.Contains(string(plist), "<string>--config</string>\n\t\t<string>"+path+"</string>") {
+		t.Errorf("Expected plist to pass --config, got %s", plist)
+	}
+}
+
+func TestInstallDaemonServiceSystemdWithSocket(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	calls := stubServiceCommands(t, "linux", false)
+
+	cmd := &command{}
+	var systemd, socket bool
+	cmd.Flags().BoolVar(&systemd, "systemd", true, "")
+	cmd.Flags().BoolVar(&socket, "socket", true, "")
+
+	captureStdout(t, func() {
+		if err := installDaemonService(cmd, nil); err != nil {
+			t.Fatalf("installDaemonService failed: %v", err)
+		}
+	})
+
+	homeDir, _ := os.UserHomeDir()
+	unitDir := filepath.Join(homeDir, ".config", "systemd", "user")
+	service, err := os.ReadFile(filepath.Join(unitDir, systemdServiceUnit))
+	if err != nil {
+		t.Fatalf("Expected service unit: %v", err)
+	}
+	for _, want := range []string{"daemon start --foreground", "WantedBy=default.target"} {
+		if !strings.Contains(string(service), want) {
+			t.Errorf("Expected service unit to contain %q", want)
+		}
+	}
+	socketUnit, err := os.ReadFile(filepath.Join(unitDir, systemdSocketUnit))
+	if err != nil {
+		t.Fatalf("Expected socket unit: %v", err)
+	}
+	if !strings.Contains(string(socketUnit), "ListenStream="+config.Daemon.SocketPath) {
+		t.Errorf("Expected socket unit to listen on %s, got %s", config.Daemon.SocketPath, socketUnit)
+	}
+
+	var enabled []string
+	for _, call := range *calls {
+		if call.name != systemctlCommandName || len(call.args) == 0 || call.args[0] != systemctlUserFlag {
+			t.Errorf("Expected systemctl --user call, got %+v", call)
+			continue
+		}
+		if len(call.args) == 3 && call.args[1] == "enable" {
+			enabled = append(enabled, call.args[2])
+		}
+	}
+	if len(enabled) != 2 || enabled[0] != systemdSocketUnit || enabled[1] != systemdServiceUnit {
+		t.Errorf("Expected socket and service to be enabled, got %v", enabled)
+	}
+}
+
+func TestInstallDaemonServiceRejectsSocketWithoutSystemd(t *testing.T) {
+	setupTestHomeConfig(t)
+	stubServiceCommands(t, "darwin", false)
+
+	cmd := &command{}
+	var launchd, socket bool
+	cmd.Flags().BoolVar(&launchd, "launchd", true, "")
+	cmd.Flags().BoolVar(&socket, "socket", true, "")
+
+	if err := installDaemonService(cmd, nil); err == nil {
+		t.Fatal("Expected --socket to be rejected for launchd")
+	}
+}
+
+func TestDaemonCheckerPrefersSystemd(t *testing.T) {
+	config := setupTestHomeConfig(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	calls := stubServiceCommands(t, "linux", false)
+
+	unitDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "systemd", "user")
+	if err := os.MkdirAll(unitDir, 0o700); err != nil {
+		t.Fatalf("Failed to create unit dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(unitDir, systemdServiceUnit), []byte("[Unit]\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write unit: %v", err)
+	}
+
+	if !(RealDaemonChecker{}).IsRunning(config) {
+		t.Error("Expected systemd is-active to report the daemon as running")
+	}
+	last := (*calls)[len(*calls)-1]
+	if last.name != systemctlCommandName || !strings.Contains(strings.Join(last.args, " "), "is-active") {
+		t.Errorf("Expected systemctl is-active call, got %+v", last)
+	}
+}
+
+func TestSystemdServiceUnitQuotesExecPath(t *testing.T) {
+	unit := systemdServiceUnitFile(`/opt/my tools/100%/diu`, []string{"daemon", "start", "--foreground"})
+	if !strings.Contains(unit, `ExecStart="/opt/my tools/100%%/diu" daemon start --foreground`) {
+		t.Errorf("Expected quoted ExecStart, got %s", unit)
+	}
+}
+
+func TestInstallDaemonServiceRejectsMultipleManagers(t *testing.T) {
+	setupTestHomeConfig(t)
+	stubServiceCommands(t, "linux", false)
+
+	cmd := &command{}
+	var systemd, windows bool
+	cmd.Flags().BoolVar(&systemd, "systemd", true, "")
+	cmd.Flags().BoolVar(&windows, "windows", true, "")
+
+	if err := installDaemonService(cmd, nil); err == nil {
+		t.Fatal("Expected conflicting service manager flags to be rejected")
+	}
+}
@@ -0,0 +1,224 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/daemon"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	windowsServiceDisplayName  = "DIU daemon"
+	windowsServiceDescription  = "Tracks package manager executions for diu."
+	windowsServiceRestartDelay = 5 * time.Second
+	windowsServiceResetPeriod  = 24 * 60 * 60 // seconds
+	windowsServiceRegistryPath = `SYSTEM\CurrentControlSet\Services\` + windowsServiceName
+)
+
+// windowsServiceManager manages the daemon as a Windows service. Installing,
+// removing, starting, and stopping the service require an elevated prompt;
+// querying its state does not.
+type windowsServiceManager struct{}
+
+func newWindowsServiceManager() (serviceManager, error) {
+	return windowsServiceManager{}, nil
+}
+
+func (windowsServiceManager) Name() string {
+	return serviceManagerWindows
+}
+
+func (windowsServiceManager) Installed() bool {
+	_, err := queryWindowsService()
+	return err == nil
+}
+
+// Install registers the service to run "diu daemon start" at boot. The
+// service runs as LocalSystem, so the installing user's profile directory is
+// passed through the service environment to resolve the same config and
+// data files.
+func (windowsServiceManager) Install(config *core.Config, execPath string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(windowsServiceName, execPath, mgr.Config{
+		DisplayName: windowsServiceDisplayName,
+		Description: windowsServiceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, daemonStartArgs(config)...)
+	if err != nil {
+		return fmt.Errorf("failed to create Windows service: %w", err)
+	}
+	defer s.Close()
+
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: windowsServiceRestartDelay},
+	}, windowsServiceResetPeriod); err != nil {
+		return fmt.Errorf("failed to set service recovery actions: %w", err)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, windowsServiceRegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open service registry key: %w", err)
+	}
+	defer key.Close()
+	if err := key.SetStringsValue("Environment", []string{
+		"USERPROFILE=" + homeDir,
+		"HOME=" + homeDir,
+	}); err != nil {
+		return fmt.Errorf("failed to set service environment: %w", err)
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start Windows service: %w", err)
+	}
+	return nil
+}
+
+func (m windowsServiceManager) Uninstall() error {
+	if m.Running() {
+		if err := m.Stop(); err != nil {
+			return err
+		}
+	}
+	return withWindowsService(func(s *mgr.Service) error {
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to delete Windows service: %w", err)
+		}
+		return nil
+	})
+}
+
+func (windowsServiceManager) Start() error {
+	return withWindowsService(func(s *mgr.Service) error {
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("failed to start Windows service: %w", err)
+		}
+		return nil
+	})
+}
+
+func (windowsServiceManager) Stop() error {
+	return withWindowsService(func(s *mgr.Service) error {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop Windows service: %w", err)
+		}
+		return nil
+	})
+}
+
+func (windowsServiceManager) Running() bool {
+	state, err := queryWindowsService()
+	return err == nil && state == windows.SERVICE_RUNNING
+}
+
+func withWindowsService(fn func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(windowsServiceName)
+	if err != nil {
+		return fmt.Errorf("failed to open Windows service: %w", err)
+	}
+	defer s.Close()
+	return fn(s)
+}
+
+// queryWindowsService reads the service state with query-only access so it
+// works from an unelevated prompt.
+func queryWindowsService() (uint32, error) {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	name, err := windows.UTF16PtrFromString(windowsServiceName)
+	if err != nil {
+		return 0, err
+	}
+	service, err := windows.OpenService(scm, name, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseServiceHandle(service)
+
+	var status windows.SERVICE_STATUS
+	if err := windows.QueryServiceStatus(service, &status); err != nil {
+		return 0, err
+	}
+	return status.CurrentState, nil
+}
+
+// runningAsWindowsService reports whether the service control manager
+// started this process.
+func runningAsWindowsService() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
+
+// runWindowsService runs the daemon under the service control manager until
+// the service is stopped.
+func runWindowsService(d *daemon.Daemon) error {
+	handler := &windowsServiceHandler{daemon: d}
+	if err := svc.Run(windowsServiceName, handler); err != nil {
+		return fmt.Errorf("failed to run Windows service: %w", err)
+	}
+	return handler.err
+}
+
+type windowsServiceHandler struct {
+	daemon *daemon.Daemon
+	err    error
+}
+
+// Windows service-specific exit codes reported when the daemon fails.
+const (
+	windowsServiceStartFailed = 1
+	windowsServiceStopFailed  = 2
+)
+
+func (h *windowsServiceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	if err := h.daemon.Start(); err != nil {
+		h.err = err
+		return true, windowsServiceStartFailed
+	}
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			changes <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending}
+			if err := h.daemon.Stop(); err != nil {
+				h.err = err
+				return true, windowsServiceStopFailed
+			}
+			h.daemon.Wait()
+			return false, 0
+		}
+	}
+	h.err = errors.New("service control channel closed")
+	return false, 0
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/ignore"
+	"github.com/yowainwright/diu/internal/monitors"
 	"github.com/yowainwright/diu/internal/storage"
 )
 
@@ -22,7 +25,7 @@ type executableWrapper struct {
 
 // setupProject initializes DIU storage and wrappers
 func setupProject(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -55,7 +58,7 @@ func setupProject(cmd *command, args []string) error {
 
 // scanPackages scans for installed packages
 func scanPackages(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -70,8 +73,8 @@ func scanPackages(cmd *command, args []string) error {
 	scanConfig.Monitoring.Process.AutoInstallWrappers = false
 
 	total := 0
-	for _, tool := range scanConfig.Monitoring.EnabledTools {
-		monitor, err := newMonitor(core.NormalizeToolName(tool))
+	for _, tool := range scanConfig.TrackedTools() {
+		monitor, err := newMonitor(&scanConfig, tool)
 		if err != nil {
 			continue
 		}
@@ -90,6 +93,9 @@ func scanPackages(cmd *command, args []string) error {
 			if existing, err := store.GetPackage(pkg.Tool, pkg.Name); err == nil {
 				pkg.LastUsed = existing.LastUsed
 				pkg.UsageCount = existing.UsageCount
+				if pkg.License == "" {
+					pkg.License = existing.License
+				}
 			}
 			if err := store.UpdatePackage(pkg); err != nil {
 				return fmt.Errorf("failed to update package %s/%s: %w", pkg.Tool, pkg.Name, err)
@@ -116,6 +122,7 @@ func scanPackages(cmd *command, args []string) error {
 			pkg.InstallDate = existing.InstallDate
 			pkg.LastUsed = existing.LastUsed
 			pkg.UsageCount = existing.UsageCount
+			pkg.License = existing.License
 			if existing.Path != "" {
 				pkg.Path = existing.Path
 			}
@@ -132,7 +139,7 @@ func scanPackages(cmd *command, args []string) error {
 
 // cleanup cleans up old execution records
 func cleanup(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -143,38 +150,45 @@ func cleanup(cmd *command, args []string) error {
 	}
 	defer closeStore(store)
 
+	before, err := countExecutions(store)
+	if err != nil {
+		return err
+	}
 	if err := store.Cleanup(time.Time{}); err != nil {
 		return fmt.Errorf("cleanup failed: %w", err)
 	}
-
-	fmt.Println(successStyle.Render("Cleanup completed"))
-	return nil
-}
-
-// backup creates a manual backup
-func backup(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	after, err := countExecutions(store)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	store, err := storage.NewJSONStorage(config)
-	if err != nil {
-		return fmt.Errorf("failed to open storage: %w", err)
+	if jsonOutput(cmd) {
+		return printJSON(cleanupReport{RemovedExecutions: before - after, RemainingExecutions: after})
 	}
-	defer closeStore(store)
+	fmt.Println(successStyle.Render(fmt.Sprintf("Cleanup completed (%d executions removed)", before-after)))
+	return nil
+}
 
-	if err := store.Backup(); err != nil {
-		return fmt.Errorf("backup failed: %w", err)
-	}
+// cleanupReport is the --json form of diu cleanup
+type cleanupReport struct {
+	RemovedExecutions   int `json:"removed_executions"`
+	RemainingExecutions int `json:"remaining_executions"`
+}
 
-	fmt.Println(successStyle.Render("Backup created"))
-	return nil
+func countExecutions(store storage.Storage) (int, error) {
+	count := 0
+	if err := store.StreamExecutions(storage.QueryOptions{}, func(*core.ExecutionRecord) error {
+		count++
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("failed to count executions: %w", err)
+	}
+	return count, nil
 }
 
 // recordExecution records an execution event from stdin
 func recordExecution(cmd *command, args []string) error {
-	config, err := core.LoadConfig("")
+	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -184,15 +198,32 @@ func recordExecution(cmd *command, args []string) error {
 		return fmt.Errorf("failed to decode execution record: %w", err)
 	}
 
-	enrichExecutionRecord(config, &record)
+	if pause, _ := core.LoadPauseState(config.Daemon.DataDir, time.Now()); pause.Paused {
+		return nil
+	}
+	if config.ToolDisabled(record.Tool) {
+		return nil
+	}
+	ignoreRules, err := ignore.New(config.Ignore)
+	if err != nil {
+		return err
+	}
+	monitor := enrichExecutionRecord(config, &record)
+	if ignoreRules.Match(&record) || config.IgnoreExecution(&record) {
+		return nil
+	}
 
 	store, err := storage.NewJSONStorage(config)
 	if err != nil {
 		return fmt.Errorf("failed to open storage: %w", err)
 	}
 	defer closeStore(store)
+	if monitor != nil {
+		monitors.RecordVersionChanges(monitor, &record, storage.KnownVersions(store, record.Tool))
+	}
 
-	if err := store.AddExecution(&record); err != nil {
+	// A wrapper retrying the same record is not an error.
+	if err := store.AddExecution(&record); err != nil && !errors.Is(err, storage.ErrDuplicateExecution) {
 		return fmt.Errorf("failed to record execution: %w", err)
 	}
 
@@ -201,8 +232,8 @@ func recordExecution(cmd *command, args []string) error {
 
 // installWrappers installs monitors for enabled tools
 func installWrappers(config *core.Config) error {
-	for _, tool := range config.Monitoring.EnabledTools {
-		monitor, err := newMonitor(core.NormalizeToolName(tool))
+	for _, tool := range config.TrackedTools() {
+		monitor, err := newMonitor(config, tool)
 		if err != nil {
 			continue
 		}
@@ -227,14 +258,6 @@ func installExecutableWrappers(config *core.Config) error {
 // discoverExecutableWrappers discovers executables to wrap
 func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 	targets := make(map[string]executableWrapper)
-	toolEnabled := func(tool string) bool {
-		for _, enabled := range config.Monitoring.EnabledTools {
-			if core.NormalizeToolName(enabled) == tool {
-				return true
-			}
-		}
-		return false
-	}
 	addExecutableDir := func(tool, dir string) {
 		if dir == "" {
 			return
@@ -265,12 +288,12 @@ func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 		}
 	}
 
-	if toolEnabled(core.ToolHomebrew) {
+	if config.ToolEnabled(core.ToolHomebrew) {
 		for _, dir := range config.Monitoring.Filesystem.WatchPaths[core.ToolHomebrew] {
 			addExecutableDir(core.ToolHomebrew, dir)
 		}
 	}
-	if toolEnabled(core.ToolNPM) {
+	if config.ToolEnabled(core.ToolNPM) {
 		if npmBin := npmGlobalBinDir(); npmBin != "" {
 			addExecutableDir(core.ToolNPM, npmBin)
 		}
@@ -278,7 +301,7 @@ func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 			addExecutableDir(core.ToolNPM, dir)
 		}
 	}
-	if toolEnabled(core.ToolPNPM) {
+	if config.ToolEnabled(core.ToolPNPM) {
 		if pnpmBin := pnpmGlobalBinDir(); pnpmBin != "" {
 			addExecutableDir(core.ToolPNPM, pnpmBin)
 		}
@@ -286,7 +309,7 @@ func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 			addExecutableDir(core.ToolPNPM, dir)
 		}
 	}
-	if toolEnabled(core.ToolBun) {
+	if config.ToolEnabled(core.ToolBun) {
 		if bunBin := bunGlobalBinDir(); bunBin != "" {
 			addExecutableDir(core.ToolBun, bunBin)
 		}
@@ -294,12 +317,12 @@ func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 			addExecutableDir(core.ToolBun, dir)
 		}
 	}
-	if toolEnabled(core.ToolGo) {
+	if config.ToolEnabled(core.ToolGo) {
 		if goBin := goBinaryDir(config); goBin != "" {
 			addExecutableDir(core.ToolGo, goBin)
 		}
 	}
-	if toolEnabled(core.ToolPip) {
+	if config.ToolEnabled(core.ToolPip) {
 		if pythonBin := pythonUserBaseBinDir(); pythonBin != "" {
 			addExecutableDir(core.ToolPip, pythonBin)
 		}
@@ -307,7 +330,7 @@ func discoverExecutableWrappers(config *core.Config) []executableWrapper {
 			addExecutableDir(core.ToolPip, dir)
 		}
 	}
-	if toolEnabled(core.ToolUV) {
+	if config.ToolEnabled(core.ToolUV) {
 		if uvBin := uvToolBinDir(); uvBin != "" {
 			addExecutableDir(core.ToolUV, uvBin)
 		}
@@ -340,6 +363,13 @@ ORIGINAL_BINARY="%s"
 DIU_TOOL="%s"
 DIU_PACKAGE="%s"
 DIU_EXECUTABLE="%s"
+
+# DIU_DISABLE or DIU_PRIVATE set to anything but 0 opts this command, or
+# every command of a shell that exports it, out of recording.
+if [ "${DIU_DISABLE:-0}" != 0 ] || [ "${DIU_PRIVATE:-0}" != 0 ]; then
+    exec "$ORIGINAL_BINARY" "$@"
+fi
+
 START_TIME=$(date +%%s)
 
 "$ORIGINAL_BINARY" "$@"
@@ -348,6 +378,13 @@ EXIT_CODE=$?
 END_TIME=$(date +%%s)
 DURATION=$(( (END_TIME - START_TIME) * 1000 ))
 
+# Executions from one terminal share a session ID: the shell's PID and start
+# time, exported by the shell config or derived from the parent process.
+DIU_SESSION="${DIU_SESSION_ID:-}"
+if [ -z "$DIU_SESSION" ]; then
+    DIU_SESSION="$PPID-$(ps -o lstart= -p "$PPID" 2>/dev/null | cksum | cut -d ' ' -f 1)"
+fi
+
 json_escape() {
     local value="$1"
     value="${value//\\/\\\\}"
@@ -380,6 +417,7 @@ payload=$(cat <<EOF
         "timestamp": "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)",
         "working_dir": "$(json_escape "$(pwd)")",
         "user": "$(json_escape "$(whoami)")",
+        "session_id": "$(json_escape "$DIU_SESSION")",
         "packages_affected": ["$(json_escape "$DIU_PACKAGE")"],
         "metadata": {
             "executable": "$(json_escape "$DIU_EXECUTABLE")",
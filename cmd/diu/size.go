@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// packageInstallPath returns the file or directory uninstalling pkg would
+// remove, or "" when diu does not know where the package lives
+func packageInstallPath(pkg *core.PackageInfo) string {
+	if pkg.Path == "" {
+		return ""
+	}
+	resolved, err := filepath.EvalSymlinks(pkg.Path)
+	if err != nil {
+		return ""
+	}
+	slashPath := filepath.ToSlash(resolved)
+
+	switch pkg.Tool {
+	case core.ToolHomebrew:
+		// Every installed version of a formula or cask lives under one directory.
+		for _, marker := range []string{"/Cellar/", "/Caskroom/"} {
+			if name := pathSegmentAfter(slashPath, marker); name != "" {
+				prefix := strings.SplitN(slashPath, marker, 2)[0]
+				return filepath.FromSlash(prefix + marker + name)
+			}
+		}
+	case core.ToolNPM, core.ToolPNPM, core.ToolBun, core.ToolYarn:
+		if name := npmPackageFromPath(slashPath); name != "" {
+			prefix := strings.SplitN(slashPath, "/node_modules/", 2)[0]
+			return filepath.FromSlash(prefix + "/node_modules/" + name)
+		}
+	case core.ToolGo, core.ToolGoBinary:
+		return resolved
+	}
+	return ""
+}
+
+// diskUsage returns the total size of the regular files at or under path
+func diskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// reclaimableSpace fills in the size of each package and returns their
+// total, along with how many packages could not be sized
+func reclaimableSpace(packages []*core.PackageInfo) (total int64, unknown int) {
+	for _, pkg := range packages {
+		path := packageInstallPath(pkg)
+		if path == "" {
+			unknown++
+			continue
+		}
+		size, err := diskUsage(path)
+		if err != nil {
+			unknown++
+			continue
+		}
+		pkg.SizeBytes = size
+		total += size
+	}
+	return total, unknown
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	snapshotDirName       = "snapshots"
+	snapshotFileExtension = ".json"
+	snapshotNameLayout    = "20060102-150405"
+	snapshotCurrent       = "current"
+)
+
+// packageSnapshot is the installed-package inventory at a point in time
+type packageSnapshot struct {
+	Name     string            `json:"name"`
+	Created  time.Time         `json:"created"`
+	Hostname string            `json:"hostname,omitempty"`
+	Packages []snapshotPackage `json:"packages"`
+}
+
+type snapshotPackage struct {
+	Tool    string `json:"tool"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// snapshotChange is one difference between two snapshots
+type snapshotChange struct {
+	Tool       string
+	Name       string
+	OldVersion string
+	NewVersion string
+}
+
+// createSnapshot saves the tracked package inventory under a name
+func createSnapshot(cmd *command, args []string) error {
+	if flagBool(cmd, "scan") {
+		if err := scanPackages(cmd, nil); err != nil {
+			return err
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	name := time.Now().Format(snapshotNameLayout)
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if err := validateSnapshotName(name); err != nil {
+		return err
+	}
+
+	snapshot, err := currentSnapshot(config)
+	if err != nil {
+		return err
+	}
+	snapshot.Name = name
+
+	path := snapshotPath(config, name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot %s already exists", name)
+	}
+	if err := writeSnapshot(path, snapshot); err != nil {
+		return err
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("Snapshot %s saved (%d packages)", name, len(snapshot.Packages))))
+	return nil
+}
+
+// listSnapshots prints saved snapshots, oldest first
+func listSnapshots(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	snapshots, err := loadSnapshots(config)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println(infoStyle.Render("No snapshots found"))
+		return nil
+	}
+
+	fmt.Println(titleStyle.Render("Snapshots"))
+	fmt.Println()
+	for _, snapshot := range snapshots {
+		fmt.Printf("  %-24s %s  %d packages\n",
+			snapshot.Name,
+			snapshot.Created.Local().Format("2006-01-02 15:04"),
+			len(snapshot.Packages),
+		)
+	}
+	return nil
+}
+
+// diffSnapshots shows installs, removals, and version changes between two
+// snapshots. The second snapshot defaults to the current inventory.
+func diffSnapshots(cmd *command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: diu diff <snapshotA> [snapshotB]")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	from, err := resolveSnapshot(config, args[0])
+	if err != nil {
+		return err
+	}
+	toName := snapshotCurrent
+	if len(args) == 2 {
+		toName = args[1]
+	}
+	to, err := resolveSnapshot(config, toName)
+	if err != nil {
+		return err
+	}
+
+	added, removed, changed := compareSnapshots(from, to)
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Changes from %s to %s", from.Name, to.Name)))
+	if len(added)+len(removed)+len(changed) == 0 {
+		fmt.Println()
+		fmt.Println(infoStyle.Render("No changes"))
+		return nil
+	}
+
+	printSnapshotChanges("Installed", "+", successStyle, added)
+	printSnapshotChanges("Removed", "-", errorStyle, removed)
+	printSnapshotChanges("Version changes", "~", infoStyle, changed)
+	return nil
+}
+
+func printSnapshotChanges(heading, marker string, markerStyle style, changes []snapshotChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println(subtitleStyle.Render(fmt.Sprintf("%s (%d):", heading, len(changes))))
+	for _, change := range changes {
+		line := fmt.Sprintf("%s/%s", change.Tool, change.Name)
+		switch {
+		case change.OldVersion != "" && change.NewVersion != "":
+			line += fmt.Sprintf(" %s -> %s", change.OldVersion, change.NewVersion)
+		case change.NewVersion != "":
+			line += " " + change.NewVersion
+		case change.OldVersion != "":
+			line += " " + change.OldVersion
+		}
+		fmt.Printf("  %s %s\n", markerStyle.Render(marker), line)
+	}
+}
+
+// compareSnapshots returns packages only in to, only in from, and in both
+// with different versions, each sorted by tool and name
+func compareSnapshots(from, to *packageSnapshot) (added, removed, changed []snapshotChange) {
+	before := snapshotIndex(from)
+	after := snapshotIndex(to)
+
+	for key, pkg := range after {
+		old, ok := before[key]
+		switch {
+		case !ok:
+			added = append(added, snapshotChange{Tool: pkg.Tool, Name: pkg.Name, NewVersion: pkg.Version})
+		case old.Version != pkg.Version:
+			changed = append(changed, snapshotChange{Tool: pkg.Tool, Name: pkg.Name, OldVersion: old.Version, NewVersion: pkg.Version})
+		}
+	}
+	for key, pkg := range before {
+		if _, ok := after[key]; !ok {
+			removed = append(removed, snapshotChange{Tool: pkg.Tool, Name: pkg.Name, OldVersion: pkg.Version})
+		}
+	}
+
+	for _, changes := range [][]snapshotChange{added, removed, changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Tool != changes[j].Tool {
+				return changes[i].Tool < changes[j].Tool
+			}
+			return changes[i].Name < changes[j].Name
+		})
+	}
+	return added, removed, changed
+}
+
+func snapshotIndex(snapshot *packageSnapshot) map[string]snapshotPackage {
+	index := make(map[string]snapshotPackage, len(snapshot.Packages))
+	for _, pkg := range snapshot.Packages {
+		index[pkg.Tool+"/"+pkg.Name] = pkg
+	}
+	return index
+}
+
+// currentSnapshot builds an unsaved snapshot of the tracked inventory
+func currentSnapshot(config *core.Config) (*packageSnapshot, error) {
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	packages, err := store.GetPackages("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	snapshot := &packageSnapshot{
+		Name:     snapshotCurrent,
+		Created:  time.Now(),
+		Hostname: hostname,
+		Packages: make([]snapshotPackage, 0, len(packages)),
+	}
+	for _, pkg := range packages {
+		snapshot.Packages = append(snapshot.Packages, snapshotPackage{Tool: pkg.Tool, Name: pkg.Name, Version: pkg.Version})
+	}
+	sort.Slice(snapshot.Packages, func(i, j int) bool {
+		if snapshot.Packages[i].Tool != snapshot.Packages[j].Tool {
+			return snapshot.Packages[i].Tool < snapshot.Packages[j].Tool
+		}
+		return snapshot.Packages[i].Name < snapshot.Packages[j].Name
+	})
+	return snapshot, nil
+}
+
+// resolveSnapshot loads a saved snapshot by name or file path, or the live
+// inventory for "current"
+func resolveSnapshot(config *core.Config, ref string) (*packageSnapshot, error) {
+	if ref == snapshotCurrent {
+		return currentSnapshot(config)
+	}
+	path := ref
+	if validateSnapshotName(ref) == nil {
+		path = snapshotPath(config, ref)
+	}
+	snapshot, err := readSnapshot(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot not found: %s", ref)
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func loadSnapshots(config *core.Config) ([]*packageSnapshot, error) {
+	dir := snapshotDir(config)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snapshots []*packageSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != snapshotFileExtension {
+			continue
+		}
+		snapshot, err := readSnapshot(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+func readSnapshot(path string) (*packageSnapshot, error) {
+	data, err := safefs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot packageSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	if snapshot.Name == "" {
+		snapshot.Name = strings.TrimSuffix(filepath.Base(path), snapshotFileExtension)
+	}
+	return &snapshot, nil
+}
+
+func writeSnapshot(path string, snapshot *packageSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, core.PrivateFileMode); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func snapshotDir(config *core.Config) string {
+	return filepath.Join(config.Daemon.DataDir, snapshotDirName)
+}
+
+func snapshotPath(config *core.Config, name string) string {
+	return filepath.Join(snapshotDir(config), name+snapshotFileExtension)
+}
+
+// validateSnapshotName keeps snapshot names usable as file names
+func validateSnapshotName(name string) error {
+	if name == "" || name == snapshotCurrent {
+		return fmt.Errorf("invalid snapshot name: %q", name)
+	}
+	for _, char := range name {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '-' || char == '_' || char == '.':
+		default:
+			return fmt.Errorf("snapshot name may only contain letters, numbers, '-', '_', and '.': %s", name)
+		}
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("snapshot name cannot start with '.': %s", name)
+	}
+	return nil
+}
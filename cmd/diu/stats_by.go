@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/gitinfo"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	statsByTool    = "tool"
+	statsByPackage = "package"
+	statsByProject = "project"
+	statsByRepo    = "repo"
+	statsByDir     = "dir"
+	statsByUser    = "user"
+	statsByHost    = "host"
+	statsByWeekday = "weekday"
+	statsByHour    = "hour"
+
+	statsUnknownKey = "(unknown)"
+)
+
+// statsByDimensions lists the values accepted by diu stats --by.
+var statsByDimensions = []string{statsByTool, statsByPackage, statsByProject, statsByRepo, statsByDir, statsByUser, statsByHost, statsByWeekday, statsByHour}
+
+// statsBucket is the execution count for one value of a --by dimension
+type statsBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// groupExecutionsBy counts executions per value of dimension. Package
+// grouping counts an execution once for each package it affected; weekday
+// buckets are returned Monday first, hour buckets from midnight, the others
+// by descending count.
+func groupExecutionsBy(executions []*core.ExecutionRecord, dimension string) ([]statsBucket, error) {
+	counts := make(map[string]int)
+	for _, exec := range executions {
+		switch dimension {
+		case statsByTool:
+			counts[exec.Tool]++
+		case statsByPackage:
+			for _, pkg := range exec.PackagesAffected {
+				counts[exec.Tool+"/"+pkg]++
+			}
+		case statsByProject:
+			counts[executionProject(exec)]++
+		case statsByRepo:
+			counts[executionRepo(exec)]++
+		case statsByDir:
+			counts[valueOrUnknown(exec.WorkingDir)]++
+		case statsByUser:
+			counts[valueOrUnknown(exec.User)]++
+		case statsByHost:
+			counts[valueOrUnknown(exec.Host)]++
+		case statsByWeekday:
+			counts[exec.Timestamp.Local().Weekday().String()]++
+		case statsByHour:
+			counts[hourKey(exec.Timestamp.Local().Hour())]++
+		default:
+			return nil, fmt.Errorf("invalid --by value %q: must be one of %s", dimension, strings.Join(statsByDimensions, ", "))
+		}
+	}
+
+	if dimension == statsByWeekday || dimension == statsByHour {
+		return timeOfDayBuckets(dimension, counts), nil
+	}
+	buckets := make([]statsBucket, 0, len(counts))
+	for key, count := range counts {
+		buckets = append(buckets, statsBucket{Key: key, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	return buckets, nil
+}
+
+// countStatsBy counts the executions opts selects per value of dimension.
+// Storage keeps hour and weekday histograms, so those are counted without
+// reading executions; the other dimensions are grouped from executions.
+func countStatsBy(store storage.Storage, opts storage.QueryOptions, dimension string, executions []*core.ExecutionRecord) ([]statsBucket, int, error) {
+	groupBy := ""
+	switch dimension {
+	case statsByHour:
+		groupBy = storage.GroupByHour
+	case statsByWeekday:
+		groupBy = storage.GroupByWeekday
+	default:
+		buckets, err := groupExecutionsBy(executions, dimension)
+		return buckets, len(executions), err
+	}
+
+	result, err := store.Aggregate(storage.AggregateOptions{Query: opts, GroupBy: groupBy})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count executions: %w", err)
+	}
+	counts := make(map[string]int, len(result.Groups))
+	for _, group := range result.Groups {
+		counts[group.Key] = group.Count
+	}
+	return timeOfDayBuckets(dimension, counts), result.Total.Count, nil
+}
+
+// timeOfDayBuckets lists every weekday, Monday first, or every hour from
+// midnight with its count, including the quiet ones
+func timeOfDayBuckets(dimension string, counts map[string]int) []statsBucket {
+	var keys []string
+	if dimension == statsByWeekday {
+		for _, day := range heatmapDays {
+			keys = append(keys, day.String())
+		}
+	} else {
+		for hour := 0; hour < 24; hour++ {
+			keys = append(keys, hourKey(hour))
+		}
+	}
+	buckets := make([]statsBucket, 0, len(keys))
+	for _, key := range keys {
+		buckets = append(buckets, statsBucket{Key: key, Count: counts[key]})
+	}
+	return buckets
+}
+
+// hourKey is the bucket key of a local hour, matching storage.GroupByHour
+func hourKey(hour int) string {
+	return fmt.Sprintf("%02d", hour)
+}
+
+func executionProject(exec *core.ExecutionRecord) string {
+	return valueOrUnknown(exec.Project())
+}
+
+// executionRepo names the git repository an execution ran in by its remote
+// org/repo, or by its directory name when it has no remote
+func executionRepo(exec *core.ExecutionRecord) string {
+	if remote, ok := exec.Metadata[gitinfo.MetadataRemote].(string); ok && remote != "" {
+		return remote
+	}
+	if root, ok := exec.Metadata[gitinfo.MetadataRoot].(string); ok && root != "" {
+		return filepath.Base(root)
+	}
+	return statsUnknownKey
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return statsUnknownKey
+	}
+	return value
+}
+
+// printStatsBuckets prints up to limit buckets with their share of the total
+func printStatsBuckets(dimension string, buckets []statsBucket, total, limit int) {
+	fmt.Println()
+	fmt.Println(subtitleStyle.Render(fmt.Sprintf("Executions by %s:", dimension)))
+	if len(buckets) == 0 {
+		fmt.Println(infoStyle.Render("  No executions found"))
+		return
+	}
+
+	shown := buckets
+	if dimension != statsByWeekday && dimension != statsByHour && limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	width := 0
+	for _, bucket := range shown {
+		if len(bucket.Key) > width {
+			width = len(bucket.Key)
+		}
+	}
+	if width > packageNameColumnWidth {
+		width = packageNameColumnWidth
+	}
+
+	for _, bucket := range shown {
+		share := 0.0
+		if total > 0 {
+			share = float64(bucket.Count) * 100 / float64(total)
+		}
+		label := truncate(bucket.Key, width)
+		if dimension == statsByTool {
+			label = newStyle().Foreground(getToolColor(bucket.Key)).Render(fmt.Sprintf("%-*s", width, label))
+		} else {
+			label = fmt.Sprintf("%-*s", width, label)
+		}
+		fmt.Printf("  %s %6d %5.1f%%\n", label, bucket.Count, share)
+	}
+	if len(shown) < len(buckets) {
+		fmt.Println(subtitleStyle.Render(fmt.Sprintf("  ... %d more (raise --top to show)", len(buckets)-len(shown))))
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/yowainwright/diu/internal/fleet"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// syncHistory pushes local executions to a central diu daemon and prints the
+// totals it reports across every machine that syncs with it
+func syncHistory(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	remote := flagString(cmd, "remote")
+	if remote == "" {
+		remote = config.Sync.Remote
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	result, err := fleet.Sync(context.Background(), config, store, remote, flagBool(cmd, "full"))
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(result)
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Synced with %s", result.Remote)))
+	fmt.Printf("  %-12s %d pushed, %d inserted, %d already synced\n", "Executions:", result.Pushed, result.Inserted, result.Skipped)
+	if result.Rejected > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  %d executions rejected by the remote", result.Rejected)))
+	}
+	fmt.Printf("  %-12s %d executions\n", "Remote total:", result.Stats.TotalExecutions)
+	printSyncCounts("By host:", result.Stats.Hosts)
+	printSyncCounts("By tool:", result.Stats.Tools)
+	return nil
+}
+
+func printSyncCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Println(infoStyle.Render(title))
+	for _, name := range names {
+		fmt.Printf("  %-20s %d\n", name, counts[name])
+	}
+}
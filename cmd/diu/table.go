@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tableColumn describes one column of a rendered table. MaxWidth bounds the
+// column unless the table is wide; Color picks a foreground per cell value.
+type tableColumn struct {
+	Header     string
+	MaxWidth   int
+	AlignRight bool
+	Color      func(value string) color
+}
+
+// table renders rows as aligned columns, truncating long cells with an
+// ellipsis unless wide is set
+type table struct {
+	columns []tableColumn
+	rows    [][]string
+	wide    bool
+}
+
+func newTable(columns []tableColumn, wide bool) *table {
+	return &table{columns: columns, wide: wide}
+}
+
+// AddRow appends a row; missing cells render empty
+func (t *table) AddRow(cells ...string) {
+	row := make([]string, len(t.columns))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+func (t *table) Render(w io.Writer) error {
+	widths := make([]int, len(t.columns))
+	cells := make([][]string, len(t.rows))
+	for i, column := range t.columns {
+		widths[i] = utf8.RuneCountInString(column.Header)
+	}
+	for r, row := range t.rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			value = strings.ReplaceAll(value, "\n", " ")
+			if !t.wide && t.columns[i].MaxWidth > 0 {
+				value = truncateCell(value, t.columns[i].MaxWidth)
+			}
+			cells[r][i] = value
+			if width := utf8.RuneCountInString(value); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	headers := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		headers[i] = subtitleStyle.Render(padCell(column.Header, widths[i], column.AlignRight, i == len(t.columns)-1))
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(headers, "  ")); err != nil {
+		return err
+	}
+
+	for _, row := range cells {
+		parts := make([]string, len(row))
+		for i, value := range row {
+			column := t.columns[i]
+			padded := padCell(value, widths[i], column.AlignRight, i == len(row)-1)
+			if column.Color != nil {
+				padded = newStyle().Foreground(column.Color(value)).Render(padded)
+			}
+			parts[i] = padded
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(parts, "  ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// padCell pads value to width; the last left-aligned column is not padded so
+// lines carry no trailing spaces
+func padCell(value string, width int, alignRight, last bool) string {
+	gap := width - utf8.RuneCountInString(value)
+	if gap <= 0 {
+		return value
+	}
+	if alignRight {
+		return strings.Repeat(" ", gap) + value
+	}
+	if last {
+		return value
+	}
+	return value + strings.Repeat(" ", gap)
+}
+
+// truncateCell shortens value to at most width runes, ending with an ellipsis
+func truncateCell(value string, width int) string {
+	if utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	if width <= 1 {
+		return string([]rune(value)[:width])
+	}
+	return string([]rune(value)[:width-1]) + "…"
+}
+
+// formatRelativeTime describes how long before now t was, e.g. "5m ago"
+func formatRelativeTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	elapsed := now.Sub(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed/time.Minute))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed/time.Hour))
+	case elapsed < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(elapsed/(24*time.Hour)))
+	case elapsed < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(elapsed/(30*24*time.Hour)))
+	default:
+		return fmt.Sprintf("%dy ago", int(elapsed/(365*24*time.Hour)))
+	}
+}
+
+// formatExecutionDuration rounds a duration to a precision that suits its size
+func formatExecutionDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	topSortCount    = "count"
+	topSortDuration = "duration"
+	topSortLastUsed = "last"
+
+	topByPackage = "package"
+	topByTool    = "tool"
+
+	topDefaultInterval = 2 * time.Second
+	topClearScreen     = "\x1b[H\x1b[2J"
+)
+
+// topOptions controls what diu top ranks and how often it refreshes
+type topOptions struct {
+	Tool     string
+	Last     time.Duration
+	SortBy   string
+	By       string
+	Limit    int
+	Interval time.Duration
+}
+
+// topRow is one ranked package or tool in the diu top view
+type topRow struct {
+	Tool          string
+	Name          string
+	Count         int
+	TotalDuration time.Duration
+	LastUsed      time.Time
+}
+
+// showTop ranks packages or tools by recent usage, refreshing until quit when
+// attached to a terminal
+func showTop(cmd *command, args []string) error {
+	opts, err := topOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if flagBool(cmd, "once") || !isTerminal() {
+		rows, err := loadTopRows(config, opts)
+		if err != nil {
+			return err
+		}
+		renderTop(os.Stdout, rows, opts, false)
+		return nil
+	}
+	return runTopLoop(config, opts)
+}
+
+func topOptionsFromFlags(cmd *command) (topOptions, error) {
+	opts := topOptions{
+		Tool:   core.NormalizeToolName(flagString(cmd, "tool")),
+		SortBy: flagString(cmd, "sort"),
+		By:     flagString(cmd, "by"),
+		Limit:  flagInt(cmd, "limit"),
+	}
+
+	last, err := parseDuration(flagString(cmd, "last"))
+	if err != nil || last <= 0 {
+		return opts, fmt.Errorf("invalid duration: %s", flagString(cmd, "last"))
+	}
+	opts.Last = last
+
+	interval, err := parseDuration(flagString(cmd, "interval"))
+	if err != nil || interval <= 0 {
+		return opts, fmt.Errorf("invalid refresh interval: %s", flagString(cmd, "interval"))
+	}
+	opts.Interval = interval
+
+	switch opts.SortBy {
+	case topSortCount, topSortDuration, topSortLastUsed:
+	default:
+		return opts, fmt.Errorf("invalid sort: must be count, duration, or last")
+	}
+	switch opts.By {
+	case topByPackage, topByTool:
+	default:
+		return opts, fmt.Errorf("invalid grouping: must be package or tool")
+	}
+	return opts, nil
+}
+
+// runTopLoop redraws the view every interval. Commands are read a line at a
+// time: c, d, and l change the sort, t and p switch grouping, q quits.
+func runTopLoop(config *core.Config, opts topOptions) error {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	inputs := make(chan string)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(inputs)
+				return
+			}
+			inputs <- strings.TrimSpace(line)
+		}
+	}()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		rows, err := loadTopRows(config, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(topClearScreen)
+		renderTop(os.Stdout, rows, opts, true)
+
+		select {
+		case <-ticker.C:
+		case <-interrupts:
+			return nil
+		case input, ok := <-inputs:
+			if !ok {
+				return nil
+			}
+			switch input {
+			case actionQuit:
+				return nil
+			case "c":
+				opts.SortBy = topSortCount
+			case "d":
+				opts.SortBy = topSortDuration
+			case "l":
+				opts.SortBy = topSortLastUsed
+			case "t":
+				opts.By = topByTool
+			case "p":
+				opts.By = topByPackage
+			}
+		}
+	}
+}
+
+// loadTopRows reads executions from storage within the window. Storage is
+// reopened on every call so the view picks up executions the daemon saved.
+func loadTopRows(config *core.Config, opts topOptions) ([]topRow, error) {
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	since := time.Now().Add(-opts.Last)
+	executions, err := store.GetExecutions(storage.QueryOptions{Tool: opts.Tool, Since: &since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query executions: %w", err)
+	}
+
+	rows := buildTopRows(executions, opts.By)
+	sortTopRows(rows, opts.SortBy)
+	if opts.Limit > 0 && len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+	}
+	return rows, nil
+}
+
+// buildTopRows totals executions per tool or per affected package
+func buildTopRows(executions []*core.ExecutionRecord, by string) []topRow {
+	index := make(map[string]*topRow)
+	add := func(tool, name string, exec *core.ExecutionRecord) {
+		key := tool + "/" + name
+		row, ok := index[key]
+		if !ok {
+			row = &topRow{Tool: tool, Name: name}
+			index[key] = row
+		}
+		row.Count++
+		row.TotalDuration += exec.Duration
+		if exec.Timestamp.After(row.LastUsed) {
+			row.LastUsed = exec.Timestamp
+		}
+	}
+
+	for _, exec := range executions {
+		if by == topByTool {
+			add(exec.Tool, exec.Tool, exec)
+			continue
+		}
+		for _, pkg := range exec.PackagesAffected {
+			add(exec.Tool, pkg, exec)
+		}
+	}
+
+	rows := make([]topRow, 0, len(index))
+	for _, row := range index {
+		rows = append(rows, *row)
+	}
+	return rows
+}
+
+func sortTopRows(rows []topRow, sortBy string) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch sortBy {
+		case topSortDuration:
+			if a.TotalDuration != b.TotalDuration {
+				return a.TotalDuration > b.TotalDuration
+			}
+		case topSortLastUsed:
+			if !a.LastUsed.Equal(b.LastUsed) {
+				return a.LastUsed.After(b.LastUsed)
+			}
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Name < b.Name
+	})
+}
+
+func renderTop(w io.Writer, rows []topRow, opts topOptions, interactive bool) {
+	title := fmt.Sprintf("DIU Top %ss (last %s, by %s)", opts.By, formatTopWindow(opts.Last), opts.SortBy)
+	_, _ = fmt.Fprintln(w, titleStyle.Render(title))
+	_, _ = fmt.Fprintln(w)
+
+	if len(rows) == 0 {
+		_, _ = fmt.Fprintln(w, infoStyle.Render("No executions in this window"))
+	} else {
+		_, _ = fmt.Fprintf(w, "%*s  %-*s %-*s %6s %10s  %s\n",
+			packageIndexColumnWidth, "#",
+			packageToolColumnWidth, "TOOL",
+			packageNameColumnWidth, "NAME",
+			"COUNT", "TIME", "LAST USED")
+		for i, row := range rows {
+			_, _ = fmt.Fprintf(w, "%*d  %-*s %-*s %6d %10s  %s\n",
+				packageIndexColumnWidth, i+1,
+				packageToolColumnWidth, row.Tool,
+				packageNameColumnWidth, truncate(row.Name, packageNameColumnWidth),
+				row.Count,
+				row.TotalDuration.Round(time.Second),
+				row.LastUsed.Local().Format("2006-01-02 15:04"))
+		}
+	}
+
+	if interactive {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, subtitleStyle.Render(fmt.Sprintf(
+			"sort: c count  d duration  l last used   group: p package  t tool   q quit   (refresh %s)",
+			opts.Interval)))
+	}
+}
+
+// formatTopWindow prints whole-day windows in days, e.g. 7d rather than 168h0m0s
+func formatTopWindow(window time.Duration) string {
+	const day = 24 * time.Hour
+	if window >= day && window%day == 0 {
+		return fmt.Sprintf("%dd", window/day)
+	}
+	return window.String()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	// upgradeUsageWindow is how far back recent use is counted.
+	upgradeUsageWindow = 30 * 24 * time.Hour
+	// dailyUseDays is how many days of the usage window a package must be
+	// used on to count as used daily.
+	dailyUseDays = 15
+	// staleUpgradeAge is how long since its last install or upgrade a
+	// package counts as stale.
+	staleUpgradeAge = 365 * 24 * time.Hour
+)
+
+// upgradeCadence summarizes how often a package is upgraded and used
+type upgradeCadence struct {
+	Tool     string    `json:"tool"`
+	Name     string    `json:"name"`
+	Upgrades int       `json:"upgrades"`
+	Interval string    `json:"mean_interval,omitempty"`
+	Last     time.Time `json:"last_upgrade"`
+	// Staleness is the time since the last install or upgrade.
+	Staleness  string `json:"staleness,omitempty"`
+	ActiveDays int    `json:"active_days_30d"`
+	StaleDaily bool   `json:"stale_daily_use"`
+
+	staleness time.Duration
+}
+
+// upgradeHistory collects the installs and upgrades and the days of use of
+// one package
+type upgradeHistory struct {
+	upgrades []time.Time
+	days     map[string]bool
+}
+
+// showUpgradeStats prints how often each package is upgraded next to how
+// much it is used, flagging packages used daily but not upgraded in a year
+func showUpgradeStats(cmd *command, store storage.Storage, tool string) error {
+	now := time.Now()
+	histories := make(map[string]*upgradeHistory)
+	history := func(tool, name string) *upgradeHistory {
+		key := tool + "/" + name
+		if histories[key] == nil {
+			histories[key] = &upgradeHistory{days: make(map[string]bool)}
+		}
+		return histories[key]
+	}
+
+	err := store.StreamExecutions(storage.QueryOptions{Tool: tool}, func(record *core.ExecutionRecord) error {
+		upgraded := make(map[string]bool)
+		for _, change := range record.VersionChanges() {
+			upgraded[change.Package] = true
+		}
+		if monitors.ChangesVersions(record) {
+			for _, name := range record.PackagesAffected {
+				upgraded[name] = true
+			}
+		}
+		for name := range upgraded {
+			h := history(record.Tool, name)
+			h.upgrades = append(h.upgrades, record.Timestamp)
+		}
+		if now.Sub(record.Timestamp) <= upgradeUsageWindow {
+			for _, name := range record.PackagesAffected {
+				if !upgraded[name] {
+					history(record.Tool, name).days[record.Timestamp.Local().Format("2006-01-02")] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read executions: %w", err)
+	}
+
+	packages, err := store.GetPackages(tool)
+	if err != nil {
+		return fmt.Errorf("failed to get packages: %w", err)
+	}
+	var cadences []upgradeCadence
+	for _, pkg := range packages {
+		h := histories[pkg.Tool+"/"+pkg.Name]
+		if h == nil {
+			h = &upgradeHistory{}
+		}
+		if len(h.upgrades) == 0 && len(h.days) == 0 {
+			continue
+		}
+		cadences = append(cadences, cadenceOf(pkg, h, now))
+	}
+	sort.SliceStable(cadences, func(i, j int) bool {
+		a, b := cadences[i], cadences[j]
+		if a.StaleDaily != b.StaleDaily {
+			return a.StaleDaily
+		}
+		if a.ActiveDays != b.ActiveDays {
+			return a.ActiveDays > b.ActiveDays
+		}
+		if a.staleness != b.staleness {
+			return a.staleness > b.staleness
+		}
+		return a.Tool+"/"+a.Name < b.Tool+"/"+b.Name
+	})
+
+	if jsonOutput(cmd) {
+		if cadences == nil {
+			cadences = []upgradeCadence{}
+		}
+		return printJSON(cadences)
+	}
+	printUpgradeStats(cadences, flagInt(cmd, "top"))
+	return nil
+}
+
+// cadenceOf computes the upgrade cadence of pkg. A package never installed
+// or upgraded while diu watched is as old as its install date, if known.
+func cadenceOf(pkg *core.PackageInfo, h *upgradeHistory, now time.Time) upgradeCadence {
+	slices.SortFunc(h.upgrades, func(a, b time.Time) int { return a.Compare(b) })
+	cadence := upgradeCadence{
+		Tool:       pkg.Tool,
+		Name:       pkg.Name,
+		Upgrades:   len(h.upgrades),
+		ActiveDays: len(h.days),
+	}
+	if n := len(h.upgrades); n > 0 {
+		cadence.Last = h.upgrades[n-1]
+		if n > 1 {
+			cadence.Interval = formatCadence(h.upgrades[n-1].Sub(h.upgrades[0]) / time.Duration(n-1))
+		}
+	}
+	since := cadence.Last
+	if since.IsZero() {
+		since = pkg.InstallDate
+	}
+	if !since.IsZero() {
+		cadence.staleness = now.Sub(since)
+		cadence.Staleness = formatCadence(cadence.staleness)
+	}
+	cadence.StaleDaily = cadence.ActiveDays >= dailyUseDays && cadence.staleness >= staleUpgradeAge
+	return cadence
+}
+
+// formatCadence rounds a duration to days, months, or years
+func formatCadence(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d < day:
+		return "<1d"
+	case d < 30*day:
+		return fmt.Sprintf("%dd", int(d/day))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", int(d/(30*day)))
+	default:
+		return fmt.Sprintf("%.1fy", float64(d)/float64(365*day))
+	}
+}
+
+func printUpgradeStats(cadences []upgradeCadence, top int) {
+	fmt.Println(titleStyle.Render("Upgrade Cadence"))
+	fmt.Println()
+	if len(cadences) == 0 {
+		fmt.Println(infoStyle.Render("No installs, upgrades, or recent use recorded"))
+		return
+	}
+
+	output := newTable([]tableColumn{
+		{Header: "TOOL", MaxWidth: packageToolColumnWidth, Color: getToolColor},
+		{Header: "PACKAGE", MaxWidth: 32},
+		{Header: "UPGRADES", AlignRight: true},
+		{Header: "EVERY", AlignRight: true},
+		{Header: "LAST UPGRADE"},
+		{Header: "AGE", AlignRight: true},
+		{Header: "DAYS USED (30D)", AlignRight: true},
+	}, false)
+	for i, cadence := range cadences {
+		if top > 0 && i >= top {
+			break
+		}
+		output.AddRow(cadence.Tool, cadence.Name, strconv.Itoa(cadence.Upgrades), valueOrDash(cadence.Interval),
+			formatLastUsed(cadence.Last), valueOrDash(cadence.Staleness), strconv.Itoa(cadence.ActiveDays))
+	}
+	_ = output.Render(os.Stdout)
+
+	var stale []string
+	for _, cadence := range cadences {
+		if !cadence.StaleDaily {
+			continue
+		}
+		event := "last upgraded"
+		if cadence.Last.IsZero() {
+			event = "installed"
+		}
+		stale = append(stale, fmt.Sprintf("  %s (%s) - used %d of the last 30 days, %s %s ago",
+			cadence.Name, cadence.Tool, cadence.ActiveDays, event, cadence.Staleness))
+	}
+	if len(stale) > 0 {
+		fmt.Println()
+		fmt.Println(subtitleStyle.Render("Used daily but not upgraded in a year:"))
+		fmt.Println(strings.Join(stale, "\n"))
+	}
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// vacuumStorage rewrites the storage files, dropping what retention has
+// expired and what interrupted saves left behind, and reports the size
+// before and after
+func vacuumStorage(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	result, err := storage.Vacuum(config)
+	if err != nil {
+		return fmt.Errorf("vacuum failed: %w", err)
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(result)
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Vacuum completed: %s -> %s in %d shards",
+		formatBytes(result.SizeBefore), formatBytes(result.SizeAfter), result.Shards)))
+	if result.Expired > 0 {
+		fmt.Printf("Dropped %d executions past retention\n", result.Expired)
+	}
+	if result.Recovered > 0 {
+		fmt.Printf("Kept %d executions from shards the storage file did not list\n", result.Recovered)
+	}
+	if result.RemovedFiles > 0 {
+		fmt.Printf("Removed %d leftover files\n", result.RemovedFiles)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// waitPeriodTitles names the --daily and --weekly periods in the diu stats
+// --time title
+var waitPeriodTitles = map[string]string{
+	"24h": "Time Spent Waiting (Last 24 Hours)",
+	"7d":  "Time Spent Waiting (Last 7 Days)",
+}
+
+// waitBucket is the time spent in executions sharing a command type, tool,
+// package, or day
+type waitBucket struct {
+	Key     string `json:"key"`
+	Runs    int    `json:"runs"`
+	TotalMS int64  `json:"total_ms"`
+	P50MS   int64  `json:"p50_ms"`
+	P95MS   int64  `json:"p95_ms"`
+
+	durations []time.Duration
+}
+
+// waitReport is the --json form of diu stats --time
+type waitReport struct {
+	Period     string       `json:"period"`
+	Executions int          `json:"executions"`
+	Untimed    int          `json:"untimed"`
+	TotalMS    int64        `json:"total_ms"`
+	Commands   []waitBucket `json:"commands"`
+	Tools      []waitBucket `json:"tools"`
+	Packages   []waitBucket `json:"packages"`
+	Days       []waitBucket `json:"days"`
+}
+
+// waitingTime adds up the durations of executions per command type, tool,
+// package, and day. An execution counts in full toward each package it
+// affected. Executions without a recorded duration are only counted.
+func waitingTime(period string, executions []*core.ExecutionRecord) waitReport {
+	report := waitReport{Period: period}
+	commands := make(map[string]*waitBucket)
+	tools := make(map[string]*waitBucket)
+	packages := make(map[string]*waitBucket)
+	days := make(map[string]*waitBucket)
+	add := func(buckets map[string]*waitBucket, key string, d time.Duration) {
+		if buckets[key] == nil {
+			buckets[key] = &waitBucket{Key: key}
+		}
+		buckets[key].durations = append(buckets[key].durations, d)
+	}
+
+	var total time.Duration
+	for _, exec := range executions {
+		if exec.Duration <= 0 {
+			report.Untimed++
+			continue
+		}
+		report.Executions++
+		total += exec.Duration
+		add(commands, commandType(exec), exec.Duration)
+		add(tools, exec.Tool, exec.Duration)
+		for _, pkg := range exec.PackagesAffected {
+			add(packages, exec.Tool+"/"+pkg, exec.Duration)
+		}
+		add(days, exec.Timestamp.Local().Format("2006-01-02"), exec.Duration)
+	}
+	report.TotalMS = total.Milliseconds()
+	report.Commands = sortedWaitBuckets(commands)
+	report.Tools = sortedWaitBuckets(tools)
+	report.Packages = sortedWaitBuckets(packages)
+	report.Days = sortedWaitBuckets(days)
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Key > report.Days[j].Key
+	})
+	return report
+}
+
+// commandType names an execution by its tool and subcommand, such as
+// "npm install"
+func commandType(exec *core.ExecutionRecord) string {
+	subcommand, _ := exec.Metadata["subcommand"].(string)
+	if subcommand == "" && len(exec.Args) > 0 {
+		subcommand = exec.Args[0]
+	}
+	if subcommand == "" {
+		return exec.Tool
+	}
+	return exec.Tool + " " + subcommand
+}
+
+// sortedWaitBuckets totals each bucket and orders them by descending total
+func sortedWaitBuckets(buckets map[string]*waitBucket) []waitBucket {
+	sorted := make([]waitBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		slices.Sort(bucket.durations)
+		var total time.Duration
+		for _, d := range bucket.durations {
+			total += d
+		}
+		bucket.Runs = len(bucket.durations)
+		bucket.TotalMS = total.Milliseconds()
+		bucket.P50MS = percentile(bucket.durations, 50).Milliseconds()
+		bucket.P95MS = percentile(bucket.durations, 95).Milliseconds()
+		sorted = append(sorted, *bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TotalMS != sorted[j].TotalMS {
+			return sorted[i].TotalMS > sorted[j].TotalMS
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// percentile returns the nearest-rank pth percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+// showWaitingStats prints how long package manager commands kept the user
+// waiting, with the typical and slow runs of each command type
+func showWaitingStats(cmd *command, period string, executions []*core.ExecutionRecord) error {
+	report := waitingTime(period, executions)
+	if jsonOutput(cmd) {
+		return printJSON(report)
+	}
+
+	title, ok := waitPeriodTitles[period]
+	if !ok {
+		title = "Time Spent Waiting"
+	}
+	fmt.Println(titleStyle.Render(title))
+	fmt.Println()
+	fmt.Printf("%s %s across %d executions\n",
+		infoStyle.Render("Total time waiting:"),
+		formatWaitTotal(time.Duration(report.TotalMS)*time.Millisecond),
+		report.Executions,
+	)
+	if report.Untimed > 0 {
+		fmt.Println(subtitleStyle.Render(fmt.Sprintf("%d executions have no recorded duration", report.Untimed)))
+	}
+	if report.Executions == 0 {
+		return nil
+	}
+
+	top := flagInt(cmd, "top")
+	printWaitBuckets("By command:", "COMMAND", report.Commands, top, true)
+	printWaitBuckets("By tool:", "TOOL", report.Tools, top, true)
+	printWaitBuckets("Top packages:", "PACKAGE", report.Packages, top, false)
+	printWaitBuckets("By day:", "DAY", report.Days, top, false)
+	return nil
+}
+
+// printWaitBuckets prints up to limit buckets as a table, with p50 and p95
+// columns when percentiles is set
+func printWaitBuckets(title, header string, buckets []waitBucket, limit int, percentiles bool) {
+	columns := []tableColumn{
+		{Header: header, MaxWidth: packageNameColumnWidth},
+		{Header: "RUNS", AlignRight: true},
+		{Header: "TOTAL", AlignRight: true},
+	}
+	if percentiles {
+		columns = append(columns, tableColumn{Header: "P50", AlignRight: true}, tableColumn{Header: "P95", AlignRight: true})
+	}
+	output := newTable(columns, false)
+	for i, bucket := range buckets {
+		if limit > 0 && i >= limit {
+			break
+		}
+		row := []string{bucket.Key, strconv.Itoa(bucket.Runs), formatWaitTotal(time.Duration(bucket.TotalMS) * time.Millisecond)}
+		if percentiles {
+			row = append(row,
+				formatExecutionDuration(time.Duration(bucket.P50MS)*time.Millisecond),
+				formatExecutionDuration(time.Duration(bucket.P95MS)*time.Millisecond))
+		}
+		output.AddRow(row...)
+	}
+
+	fmt.Println()
+	fmt.Println(subtitleStyle.Render(title))
+	_ = output.Render(os.Stdout)
+	if limit > 0 && len(buckets) > limit {
+		fmt.Println(subtitleStyle.Render(fmt.Sprintf("  ... %d more (raise --top to show)", len(buckets)-limit)))
+	}
+}
+
+// formatWaitTotal shows long totals in hours and shorter ones as durations
+func formatWaitTotal(d time.Duration) string {
+	if d >= time.Hour {
+		return fmt.Sprintf("%.1fh", d.Hours())
+	}
+	return formatExecutionDuration(d)
+}
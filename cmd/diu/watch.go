@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/pkg/client"
+)
+
+// watchExecutions streams executions recorded by the daemon until interrupted
+func watchExecutions(cmd *command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	format := flagString(cmd, "format")
+	switch format {
+	case formatTable, formatJSON:
+	default:
+		return fmt.Errorf("unsupported watch format: %s", format)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tool := core.NormalizeToolName(flagString(cmd, "tool"))
+	if format == formatTable {
+		label := "all tools"
+		if tool != "" {
+			label = tool
+		}
+		fmt.Println(titleStyle.Render(fmt.Sprintf("Watching %s (Ctrl-C to stop)", label)))
+		fmt.Println()
+	}
+
+	err = streamExecutions(ctx, config, tool, func(record *core.ExecutionRecord) error {
+		return writeWatchedExecution(os.Stdout, record, format)
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// streamExecutions calls handle for each execution the daemon streams until
+// ctx is done or the daemon closes the stream
+func streamExecutions(ctx context.Context, config *core.Config, tool string, handle func(*core.ExecutionRecord) error) error {
+	daemon, err := client.NewFromConfig(config)
+	if err != nil {
+		return err
+	}
+	return daemon.StreamExecutions(ctx, tool, handle)
+}
+
+// writeWatchedExecution prints one streamed execution as a table row or JSON line
+func writeWatchedExecution(w io.Writer, record *core.ExecutionRecord, format string) error {
+	if format == formatJSON {
+		return json.NewEncoder(w).Encode(record)
+	}
+
+	toolStyle := newStyle().Foreground(getToolColor(record.Tool))
+	line := fmt.Sprintf("%s %s %s",
+		record.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		toolStyle.Render(fmt.Sprintf("[%s]", record.Tool)),
+		record.Command,
+	)
+	if len(record.PackagesAffected) > 0 {
+		line += " " + subtitleStyle.Render("("+strings.Join(record.PackagesAffected, ", ")+")")
+	}
+	if record.ExitCode != 0 {
+		line += " " + errorStyle.Render(fmt.Sprintf("exit %d", record.ExitCode))
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
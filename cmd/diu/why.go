@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// packageExplanation is what diu why reports about one tracked package
+type packageExplanation struct {
+	Package *core.PackageInfo `json:"package"`
+	History []versionEvent    `json:"history"`
+}
+
+// versionEvent is an execution that installed or upgraded a package
+type versionEvent struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+}
+
+// explainPackage shows how a package came to be installed: its usage and
+// the versions install and upgrade executions left behind
+func explainPackage(cmd *command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("package name required")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer closeStore(store)
+
+	packages, err := store.GetPackages(core.NormalizeToolName(flagString(cmd, "tool")))
+	if err != nil {
+		return fmt.Errorf("failed to get packages: %w", err)
+	}
+	matches := exactPackageMatches(packages, args[0])
+	if len(matches) == 0 {
+		return fmt.Errorf("package %s is not tracked", args[0])
+	}
+	sortPackages(matches)
+
+	explanations := make([]packageExplanation, 0, len(matches))
+	for _, pkg := range matches {
+		history, err := versionHistory(store, pkg)
+		if err != nil {
+			return err
+		}
+		explanations = append(explanations, packageExplanation{Package: pkg, History: history})
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON(explanations)
+	}
+	for _, explanation := range explanations {
+		printPackageDetail(explanation.Package)
+		if !explanation.Package.InstallDate.IsZero() {
+			fmt.Printf("%s %s\n", subtitleStyle.Render("First seen:"), formatLastUsed(explanation.Package.InstallDate))
+		}
+		if len(explanation.History) == 0 {
+			fmt.Println(infoStyle.Render("No recorded installs or upgrades"))
+			continue
+		}
+		fmt.Println()
+		fmt.Println(infoStyle.Render("Version history"))
+		for _, event := range explanation.History {
+			change := event.To
+			if event.From != "" && event.From != event.To {
+				change = event.From + " -> " + event.To
+			}
+			fmt.Printf("  %s  %-22s %s\n", event.Time.Local().Format("2006-01-02"), truncate(change, 22), event.Command)
+		}
+	}
+	return nil
+}
+
+// versionHistory returns the recorded version changes of pkg, oldest first
+func versionHistory(store storage.Storage, pkg *core.PackageInfo) ([]versionEvent, error) {
+	var history []versionEvent
+	err := store.StreamExecutions(storage.QueryOptions{Tool: pkg.Tool}, func(record *core.ExecutionRecord) error {
+		for _, change := range record.VersionChanges() {
+			if change.Package == pkg.Name {
+				history = append(history, versionEvent{Time: record.Timestamp, Command: record.Command, From: change.From, To: change.To})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read executions: %w", err)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Time.Before(history[j].Time)
+	})
+	return history, nil
+}
@@ -0,0 +1,126 @@
+// Package anonymize replaces what identifies people and machines in
+// execution records with pseudonyms, so usage data can be shared for team
+// analysis. Tools, packages, versions, exit codes, and timing are kept.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/gitinfo"
+	"github.com/yowainwright/diu/internal/project"
+)
+
+// Kinds of pseudonymized values. Each is prefixed to its pseudonyms, e.g.
+// user-3fa9c1d2e4b5, so readers can tell them apart.
+const (
+	KindUser    = "user"
+	KindHost    = "host"
+	KindMachine = "machine"
+	KindDir     = "dir"
+	KindProject = "project"
+	KindRepo    = "repo"
+	KindBranch  = "branch"
+)
+
+// pseudonymBytes is how much of the HMAC each pseudonym keeps
+const pseudonymBytes = 6
+
+// Anonymizer maps identifying values to pseudonyms. The same value always
+// maps to the same pseudonym for one key, so grouping by user, host, or
+// directory still works on the anonymized data.
+type Anonymizer struct {
+	key  []byte
+	home string
+}
+
+// New returns an Anonymizer keyed by salt. With an empty salt a random key
+// is used, so pseudonyms cannot be matched across exports or reversed by
+// hashing guessed names; pass the same salt to exports that must line up.
+func New(salt string) (*Anonymizer, error) {
+	key := []byte(salt)
+	if salt == "" {
+		key = make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate anonymization key: %w", err)
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return &Anonymizer{key: key, home: filepath.Clean(home)}, nil
+}
+
+// Value returns the pseudonym for value, or "" when value is empty
+func (a *Anonymizer) Value(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind + "\x00" + value))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil)[:pseudonymBytes])
+}
+
+// Record anonymizes record in place. The user, host, machine ID, working
+// directory, project, and git repository become pseudonyms, paths in the
+// command, arguments, and captured output are rewritten to match, and the
+// environment is dropped since its values are often paths and names.
+func (a *Anonymizer) Record(record *core.ExecutionRecord) {
+	dir := record.WorkingDir
+	record.Command = a.paths(record.Command, dir)
+	for i, arg := range record.Args {
+		record.Args[i] = a.paths(arg, dir)
+	}
+	if record.Output != nil {
+		record.Output.Stdout = a.paths(record.Output.Stdout, dir)
+		record.Output.Stderr = a.paths(record.Output.Stderr, dir)
+	}
+	for key, value := range record.Metadata {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		switch key {
+		case gitinfo.MetadataRoot, project.MetadataScriptRoot:
+			record.Metadata[key] = a.Value(KindDir, filepath.Clean(text))
+		case gitinfo.MetadataRemote:
+			record.Metadata[key] = a.Value(KindRepo, gitinfo.RepoPath(text))
+		case gitinfo.MetadataBranch:
+			record.Metadata[key] = a.Value(KindBranch, text)
+		default:
+			record.Metadata[key] = a.paths(text, dir)
+		}
+	}
+
+	if dir != "" {
+		record.WorkingDir = a.Value(KindDir, filepath.Clean(dir))
+	}
+	record.User = a.Value(KindUser, record.User)
+	record.Host = a.Value(KindHost, strings.ToLower(record.Host))
+	record.MachineID = a.Value(KindMachine, record.MachineID)
+	record.ProjectName = a.Value(KindProject, record.ProjectName)
+	record.Environment = nil
+}
+
+// Package anonymizes pkg in place. Only its install path can identify
+// anyone, when it is inside the home directory.
+func (a *Anonymizer) Package(pkg *core.PackageInfo) {
+	pkg.Path = a.paths(pkg.Path, "")
+}
+
+// paths rewrites dir, and any path below it, to dir's pseudonym and the
+// home directory to ~, which between them hide user names in paths
+func (a *Anonymizer) paths(text, dir string) string {
+	if dir = filepath.Clean(dir); dir != "." && dir != string(filepath.Separator) && dir != a.home {
+		text = strings.ReplaceAll(text, dir, a.Value(KindDir, dir))
+	}
+	if a.home != "." && a.home != string(filepath.Separator) {
+		text = strings.ReplaceAll(text, a.home, "~")
+	}
+	return text
+}
@@ -0,0 +1,90 @@
+package anonymize
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/gitinfo"
+)
+
+func TestRecordReplacesIdentifyingFields(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	a, err := New("team-salt")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	dir := filepath.Join(home, "code", "web")
+	timestamp := time.Now()
+	record := &core.ExecutionRecord{
+		Tool:             core.ToolNPM,
+		Command:          "npm install " + dir + "/packages/ui " + home + "/tmp/eslint.tgz",
+		Args:             []string{"install", dir + "/packages/ui"},
+		Timestamp:        timestamp,
+		Duration:         time.Second,
+		WorkingDir:       dir,
+		User:             "ana",
+		Host:             "Anas-MacBook",
+		MachineID:        "3f2a9c",
+		ProjectName:      "@acme/web",
+		Environment:      map[string]string{"npm_config_prefix": home + "/.npm-global"},
+		PackagesAffected: []string{"ui", "eslint"},
+		Metadata: map[string]interface{}{
+			gitinfo.MetadataRoot:   dir,
+			gitinfo.MetadataRemote: "git@github.com:acme/web.git",
+			gitinfo.MetadataBranch: "ana/secret-feature",
+			"action":               "install",
+		},
+	}
+	a.Record(record)
+
+	dirAlias := a.Value(KindDir, dir)
+	if record.WorkingDir != dirAlias || record.Metadata[gitinfo.MetadataRoot] != dirAlias {
+		t.Errorf("Expected the working dir and git root to share a pseudonym, got %q and %v", record.WorkingDir, record.Metadata[gitinfo.MetadataRoot])
+	}
+	if want := "npm install " + dirAlias + "/packages/ui ~/tmp/eslint.tgz"; record.Command != want {
+		t.Errorf("Command = %q, want %q", record.Command, want)
+	}
+	if record.Args[1] != dirAlias+"/packages/ui" {
+		t.Errorf("Expected paths in args to be rewritten, got %v", record.Args)
+	}
+	for _, value := range []string{record.User, record.Host, record.MachineID, record.ProjectName} {
+		if value == "" || strings.Contains(value, "ana") || strings.Contains(value, "Anas") || strings.Contains(value, "acme") {
+			t.Errorf("Expected a pseudonym, got %q", value)
+		}
+	}
+	if !strings.HasPrefix(record.User, KindUser+"-") || !strings.HasPrefix(record.Host, KindHost+"-") {
+		t.Errorf("Expected pseudonyms to name their kind, got %q and %q", record.User, record.Host)
+	}
+	if record.Metadata[gitinfo.MetadataRemote] != a.Value(KindRepo, "acme/web") || record.Metadata["action"] != "install" {
+		t.Errorf("Unexpected metadata: %v", record.Metadata)
+	}
+	if record.Environment != nil {
+		t.Errorf("Expected the environment to be dropped, got %v", record.Environment)
+	}
+	if record.Tool != core.ToolNPM || !record.Timestamp.Equal(timestamp) || record.Duration != time.Second || len(record.PackagesAffected) != 2 {
+		t.Errorf("Expected tool, timing, and packages to be kept, got %+v", record)
+	}
+}
+
+func TestValueIsStablePerSalt(t *testing.T) {
+	a, _ := New("salt")
+	b, _ := New("salt")
+	c, _ := New("")
+	if a.Value(KindUser, "ana") != b.Value(KindUser, "ana") {
+		t.Error("Expected the same salt to give the same pseudonym")
+	}
+	if a.Value(KindUser, "ana") == c.Value(KindUser, "ana") {
+		t.Error("Expected a random key to give a different pseudonym")
+	}
+	if a.Value(KindUser, "ana") == a.Value(KindHost, "ana") {
+		t.Error("Expected kinds to be keyed separately")
+	}
+	if a.Value(KindUser, "") != "" {
+		t.Error("Expected empty values to stay empty")
+	}
+}
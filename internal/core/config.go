@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/yowainwright/diu/internal/safefs"
@@ -18,14 +21,50 @@ type Config struct {
 	Tools      ToolsConfig      `json:"tools"`
 	API        APIConfig        `json:"api"`
 	Reporting  ReportingConfig  `json:"reporting"`
+	// Notifications posts daemon events to chat services.
+	Notifications NotificationsConfig `json:"notifications"`
+	Prune         PruneConfig         `json:"prune"`
+	// Redaction scrubs secrets from commands before they are stored.
+	Redaction RedactionConfig `json:"redaction"`
+	// Ignore drops executions by where, what, or who ran them.
+	Ignore IgnoreConfig `json:"ignore"`
+	// Sync pushes history to a central diu daemon.
+	Sync SyncConfig `json:"sync"`
+	// Server configures diu server, which collects a team's history.
+	Server ServerConfig `json:"server"`
+	// Audit configures the vulnerability lookups of diu audit.
+	Audit AuditConfig `json:"audit"`
+
+	// path is the file the config was loaded from; Save writes back to it.
+	path string
+	// unknownKeys are keys in that file that match no setting.
+	unknownKeys []string
 }
 
 type DaemonConfig struct {
-	Port       int    `json:"port"`
-	LogLevel   string `json:"log_level"`
-	DataDir    string `json:"data_dir"`
-	PIDFile    string `json:"pid_file"`
-	SocketPath string `json:"socket_path"`
+	Port          int    `json:"port"`
+	LogLevel      string `json:"log_level"`
+	LogFormat     string `json:"log_format"`
+	LogFile       string `json:"log_file"`
+	LogMaxSizeMB  int    `json:"log_max_size_mb"`
+	LogMaxAgeDays int    `json:"log_max_age_days"`
+	LogMaxBackups int    `json:"log_max_backups"`
+	DataDir       string `json:"data_dir"`
+	PIDFile       string `json:"pid_file"`
+	SocketPath    string `json:"socket_path"`
+}
+
+// StdoutLogFile returns the file a detached or service-managed daemon's
+// stdout and stderr go to, beside LogFile: diu.log's is diu.stdout.log. It
+// is kept apart from LogFile because LogFile is rotated by renaming, which
+// would leave those descriptors writing to the rotated backup. It is ""
+// when LogFile is.
+func (c DaemonConfig) StdoutLogFile() string {
+	if c.LogFile == "" {
+		return ""
+	}
+	ext := filepath.Ext(c.LogFile)
+	return strings.TrimSuffix(c.LogFile, ext) + ".stdout" + ext
 }
 
 type StorageConfig struct {
@@ -37,6 +76,214 @@ type StorageConfig struct {
 	MaxExecutions   int           `json:"max_executions"`
 	MaxStorageBytes int64         `json:"max_storage_bytes"`
 	MaxBackups      int           `json:"max_backups"`
+	// BackupKeep bounds how many backups are kept, either as a count ("7")
+	// or an age ("30d"). When empty, MaxBackups applies.
+	BackupKeep string `json:"backup_keep,omitempty"`
+	// BackupRemote is the default remote target for diu backup and diu
+	// restore, e.g. s3://bucket/diu, gs://bucket/diu, or a WebDAV URL.
+	BackupRemote string `json:"backup_remote,omitempty"`
+	// BackupS3Endpoint points s3:// targets at an S3-compatible service.
+	BackupS3Endpoint string `json:"backup_s3_endpoint,omitempty"`
+	// AutoCleanup makes the daemon apply retention every CleanupInterval.
+	AutoCleanup     bool          `json:"auto_cleanup"`
+	CleanupInterval time.Duration `json:"cleanup_interval"`
+	// DedupeWindow is how close together two executions with the same
+	// content must be for the second to be dropped as a repeat report of
+	// the first. Zero keeps every execution.
+	DedupeWindow time.Duration `json:"dedupe_window"`
+	// FlushInterval delays rewriting the storage file after executions are
+	// added, so a burst is saved once. Executions waiting to be saved are
+	// kept in a journal beside the file. Zero saves every addition.
+	FlushInterval time.Duration `json:"flush_interval"`
+	// FlushCount saves early once this many executions are waiting. Zero
+	// waits for FlushInterval alone.
+	FlushCount int `json:"flush_count"`
+	// MemoryDays keeps only the monthly shards with executions from the
+	// last this many days in memory; older shards are read when a query
+	// reaches back that far. Zero keeps every execution in memory.
+	MemoryDays int `json:"memory_days"`
+}
+
+// BackupRetention parses BackupKeep into a backup count or maximum age.
+// Exactly one of the results is non-zero unless backups are kept forever.
+func (c StorageConfig) BackupRetention() (int, time.Duration, error) {
+	keep := strings.TrimSpace(c.BackupKeep)
+	if keep == "" {
+		return c.MaxBackups, 0, nil
+	}
+	if count, err := strconv.Atoi(keep); err == nil {
+		if count < 0 {
+			return 0, 0, fmt.Errorf("backup_keep must be non-negative")
+		}
+		return count, 0, nil
+	}
+	age, err := ParseDuration(keep)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid backup_keep %q: want a count or a duration like 30d", keep)
+	}
+	if age < 0 {
+		return 0, 0, fmt.Errorf("backup_keep must be non-negative")
+	}
+	return 0, age, nil
+}
+
+// RetentionCutoff returns the time before which executions of tool are
+// pruned, preferring tools.<tool>.retention_days over
+// storage.retention_days. The zero time means the tool's history is kept
+// indefinitely.
+func (c *Config) RetentionCutoff(tool string, now time.Time) time.Time {
+	days := c.Storage.RetentionDays
+	if override := c.Tools.Settings(tool).RetentionDays; override != nil {
+		days = *override
+	}
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// ToolEnabled reports whether tool is tracked: tools.<tool>.enabled or the
+// enabled setting of its plugin when either is set, and otherwise whether
+// monitoring.enabled_tools lists the tool or monitoring.plugins has a
+// plugin for it
+func (c *Config) ToolEnabled(tool string) bool {
+	if enabled := c.toolEnabledSetting(tool); enabled != nil {
+		return *enabled
+	}
+	tool = NormalizeToolName(tool)
+	for _, enabled := range c.Monitoring.EnabledTools {
+		if NormalizeToolName(enabled) == tool {
+			return true
+		}
+	}
+	_, plugin := c.Monitoring.Plugin(tool)
+	return plugin
+}
+
+// ToolDisabled reports whether tools.<tool>.enabled or the enabled setting
+// of its plugin is explicitly false, in which case the tool's executions are
+// dropped instead of recorded
+func (c *Config) ToolDisabled(tool string) bool {
+	enabled := c.toolEnabledSetting(tool)
+	return enabled != nil && !*enabled
+}
+
+// IgnoreExecution applies the ignore rules of record's tool: it removes
+// the packages in ignore_packages from record's affected packages, and
+// reports whether record should be dropped instead of recorded because its
+// subcommand is in ignore_subcommands or missing from a non-empty
+// track_subcommands, or every package it affected is ignored. The
+// subcommand is the one its monitor recorded in metadata, otherwise its
+// first argument that is not a flag.
+func (c *Config) IgnoreExecution(record *ExecutionRecord) bool {
+	settings := c.Tools.Settings(record.Tool)
+	if len(settings.TrackSubcommands) > 0 || len(settings.IgnoreSubcommands) > 0 {
+		subcommand, _ := record.Metadata["subcommand"].(string)
+		if subcommand == "" {
+			for _, arg := range record.Args {
+				if !strings.HasPrefix(arg, "-") {
+					subcommand = arg
+					break
+				}
+			}
+		}
+		if len(settings.TrackSubcommands) > 0 && !containsTrimmed(settings.TrackSubcommands, subcommand) {
+			return true
+		}
+		if subcommand != "" && containsTrimmed(settings.IgnoreSubcommands, subcommand) {
+			return true
+		}
+	}
+
+	if len(settings.IgnorePackages) == 0 || len(record.PackagesAffected) == 0 {
+		return false
+	}
+	kept := record.PackagesAffected[:0:0]
+	for _, name := range record.PackagesAffected {
+		if !containsFold(settings.IgnorePackages, name) {
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) == 0 {
+		return true
+	}
+	record.PackagesAffected = kept
+	return false
+}
+
+// containsTrimmed reports whether list has value once the entries'
+// surrounding space is trimmed
+func containsTrimmed(list []string, value string) bool {
+	for _, entry := range list {
+		if strings.TrimSpace(entry) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether list has value, ignoring case and the
+// entries' surrounding space
+func containsFold(list []string, value string) bool {
+	for _, entry := range list {
+		if strings.EqualFold(strings.TrimSpace(entry), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolEnabledSetting returns tools.<tool>.enabled, or the enabled setting
+// of tool's plugin when that is unset
+func (c *Config) toolEnabledSetting(tool string) *bool {
+	if enabled := c.Tools.Settings(tool).Enabled; enabled != nil {
+		return enabled
+	}
+	plugin, _ := c.Monitoring.Plugin(tool)
+	return plugin.Enabled
+}
+
+// SetToolEnabled turns tracking of tool on or off by setting
+// tools.<tool>.enabled, or the enabled setting of its plugin for tools
+// without a tools entry
+func (c *Config) SetToolEnabled(tool string, enabled bool) error {
+	if settings := c.Tools.settings(tool); settings != nil {
+		settings.Enabled = &enabled
+		return nil
+	}
+	tool = NormalizeToolName(tool)
+	for i, plugin := range c.Monitoring.Plugins {
+		if NormalizeToolName(plugin.Name) == tool {
+			c.Monitoring.Plugins[i].Enabled = &enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tool: %s", tool)
+}
+
+// TrackedTools returns the normalized names of the tools to monitor: those
+// in monitoring.enabled_tools that are not disabled, followed by any other
+// tool enabled under tools and then the tools of monitoring.plugins
+func (c *Config) TrackedTools() []string {
+	var tools []string
+	seen := make(map[string]bool)
+	add := func(tool string) {
+		tool = NormalizeToolName(tool)
+		if tool != "" && !seen[tool] && c.ToolEnabled(tool) {
+			seen[tool] = true
+			tools = append(tools, tool)
+		}
+	}
+	for _, tool := range c.Monitoring.EnabledTools {
+		add(tool)
+	}
+	for _, tool := range configurableTools {
+		add(tool)
+	}
+	for _, plugin := range c.Monitoring.Plugins {
+		add(plugin.Name)
+	}
+	return tools
 }
 
 type MonitoringConfig struct {
@@ -44,11 +291,56 @@ type MonitoringConfig struct {
 	Methods      []string         `json:"methods"`
 	Process      ProcessConfig    `json:"process"`
 	Filesystem   FilesystemConfig `json:"filesystem"`
+	// Plugins track tools diu has no monitor for through executables that
+	// speak the plugin protocol.
+	Plugins []PluginConfig `json:"plugins,omitempty"`
+}
+
+// PluginConfig is a monitor plugin: an executable that answers parse and
+// list-installed requests for the tool Name, whose Binary wrappers
+// intercept. Each request must finish within Timeout, or
+// DefaultPluginTimeout when it is zero.
+type PluginConfig struct {
+	Name    string        `json:"name"`
+	Path    string        `json:"path"`
+	Binary  string        `json:"binary,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Enabled turns the plugin off when false, unless tools.<name>.enabled
+	// is set.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// Plugin returns the plugin configured for tool, if any
+func (c MonitoringConfig) Plugin(tool string) (PluginConfig, bool) {
+	tool = NormalizeToolName(tool)
+	for _, plugin := range c.Plugins {
+		if NormalizeToolName(plugin.Name) == tool {
+			return plugin, true
+		}
+	}
+	return PluginConfig{}, false
 }
 
 type ProcessConfig struct {
 	WrapperDir          string `json:"wrapper_dir"`
 	AutoInstallWrappers bool   `json:"auto_install_wrappers"`
+	// CaptureOutput makes wrappers keep the first and last CaptureLines
+	// lines of each stream. Captured commands' stdout is a pipe rather than
+	// the terminal, so some tools print without color or progress bars.
+	CaptureOutput bool `json:"capture_output"`
+	CaptureLines  int  `json:"capture_lines"`
+}
+
+// OutputLines is how many lines wrappers keep from the start and the end
+// of each stream, or 0 when output is not captured
+func (c ProcessConfig) OutputLines() int {
+	if !c.CaptureOutput {
+		return 0
+	}
+	if c.CaptureLines <= 0 {
+		return DefaultCaptureLines
+	}
+	return c.CaptureLines
 }
 
 type FilesystemConfig struct {
@@ -56,24 +348,111 @@ type FilesystemConfig struct {
 	WatchPaths   map[string][]string `json:"watch_paths"`
 }
 
+// ToolsConfig holds per-tool settings under tools.<name>. Every tool accepts
+// the ToolSettings keys; homebrew, npm, and go have extra settings.
 type ToolsConfig struct {
 	Homebrew HomebrewConfig `json:"homebrew"`
 	NPM      NPMConfig      `json:"npm"`
 	Go       GoConfig       `json:"go"`
+	PNPM     ToolSettings   `json:"pnpm"`
+	Bun      ToolSettings   `json:"bun"`
+	Yarn     ToolSettings   `json:"yarn"`
+	Pip      ToolSettings   `json:"pip"`
+	UV       ToolSettings   `json:"uv"`
+	Poetry   ToolSettings   `json:"poetry"`
+	Gem      ToolSettings   `json:"gem"`
+	Cargo    ToolSettings   `json:"cargo"`
+	GoBinary ToolSettings   `json:"go-binary"`
+	Make     ToolSettings   `json:"make"`
+	Just     ToolSettings   `json:"just"`
+	Task     ToolSettings   `json:"task"`
+}
+
+// ToolSettings are the settings shared by every tool.
+type ToolSettings struct {
+	// Enabled overrides whether the tool is tracked. When unset,
+	// monitoring.enabled_tools decides; when false, executions of the tool
+	// are not recorded.
+	Enabled *bool `json:"enabled,omitempty"`
+	// RetentionDays overrides storage.retention_days for the tool. A value
+	// of 0 keeps its history regardless of age. Entries of
+	// storage.tool_retention_days in older configs are moved here on load.
+	RetentionDays *int `json:"retention_days,omitempty"`
+	// TrackSubcommands, when set, lists the only subcommands, such as get
+	// or install, whose executions are recorded; the rest are dropped at
+	// ingest.
+	TrackSubcommands []string `json:"track_subcommands,omitempty"`
+	// IgnoreSubcommands lists subcommands, such as run or test, whose
+	// executions are dropped at ingest.
+	IgnoreSubcommands []string `json:"ignore_subcommands,omitempty"`
+	// IgnorePackages lists packages left out of executions' affected
+	// packages; an execution that affected only these is dropped.
+	IgnorePackages []string `json:"ignore_packages,omitempty"`
+}
+
+// Settings returns the ToolSettings for tool, which are empty for tools
+// without a tools entry
+func (c ToolsConfig) Settings(tool string) ToolSettings {
+	if settings := c.settings(tool); settings != nil {
+		return *settings
+	}
+	return ToolSettings{}
+}
+
+// settings returns the ToolSettings of tool's tools entry, or nil when it
+// has none
+func (c *ToolsConfig) settings(tool string) *ToolSettings {
+	switch NormalizeToolName(tool) {
+	case ToolHomebrew:
+		return &c.Homebrew.ToolSettings
+	case ToolNPM:
+		return &c.NPM.ToolSettings
+	case ToolGo:
+		return &c.Go.ToolSettings
+	case ToolPNPM:
+		return &c.PNPM
+	case ToolBun:
+		return &c.Bun
+	case ToolYarn:
+		return &c.Yarn
+	case ToolPip:
+		return &c.Pip
+	case ToolUV:
+		return &c.UV
+	case ToolPoetry:
+		return &c.Poetry
+	case ToolGem:
+		return &c.Gem
+	case ToolCargo:
+		return &c.Cargo
+	case ToolGoBinary:
+		return &c.GoBinary
+	case ToolMake:
+		return &c.Make
+	case ToolJust:
+		return &c.Just
+	case ToolTask:
+		return &c.Task
+	default:
+		return nil
+	}
 }
 
 type HomebrewConfig struct {
+	ToolSettings
 	CellarPaths   []string `json:"cellar_paths"`
 	TrackCasks    bool     `json:"track_casks"`
 	TrackServices bool     `json:"track_services"`
 }
 
 type NPMConfig struct {
+	ToolSettings
 	TrackGlobalOnly       bool `json:"track_global_only"`
 	IgnoreDevDependencies bool `json:"ignore_dev_dependencies"`
 }
 
 type GoConfig struct {
+	ToolSettings
 	GoPath string `json:"gopath"`
 	GoBin  string `json:"gobin"`
 }
@@ -88,7 +467,226 @@ type APIConfig struct {
 type ReportingConfig struct {
 	DailySummary  bool `json:"daily_summary"`
 	WeeklySummary bool `json:"weekly_summary"`
-	EmailReports  bool `json:"email_reports"`
+	// EmailReports makes the daemon mail the enabled summaries through SMTP.
+	EmailReports bool       `json:"email_reports"`
+	SMTP         SMTPConfig `json:"smtp"`
+	// Thresholds make the daemon alert when activity crosses a limit.
+	Thresholds []ThresholdConfig `json:"thresholds,omitempty"`
+}
+
+// ThresholdConfig alerts once Metric for Tool (every tool when empty)
+// exceeds Max. The installs, global_installs, and executions metrics count
+// over the trailing Window, default a day; tracked_packages counts the
+// packages tracked now and ignores Window.
+type ThresholdConfig struct {
+	Tool   string        `json:"tool,omitempty"`
+	Metric string        `json:"metric"`
+	Max    int           `json:"max"`
+	Window time.Duration `json:"window,omitempty"`
+}
+
+// WindowOrDefault returns Window, or DefaultThresholdWindow when it is
+// unset.
+func (t ThresholdConfig) WindowOrDefault() time.Duration {
+	if t.Window <= 0 {
+		return DefaultThresholdWindow
+	}
+	return t.Window
+}
+
+// SMTPConfig is the mail server used for email reports. When Password is
+// empty, DIU_SMTP_PASSWORD is used so it can stay out of the config file.
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+type NotificationsConfig struct {
+	Chat []ChatConfig `json:"chat,omitempty"`
+	// Webhooks receive events as JSON for custom automation.
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+	// Rules raise desktop notifications and webhooks for unused and newly
+	// installed packages, per tool.
+	Rules []NotificationRuleConfig `json:"rules,omitempty"`
+}
+
+// ChatConfig posts the listed events to a Slack or Discord incoming
+// webhook. Template is a Go text/template; when empty a default message is
+// used for each event.
+type ChatConfig struct {
+	Service  string   `json:"service"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	Template string   `json:"template,omitempty"`
+}
+
+// WebhookConfig posts the listed events as JSON to URL, retrying failed
+// deliveries with exponential backoff. UnusedDays sets the threshold for
+// package_unused events.
+type WebhookConfig struct {
+	URL        string            `json:"url"`
+	Events     []string          `json:"events"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	UnusedDays int               `json:"unused_days,omitempty"`
+	MaxRetries int               `json:"max_retries,omitempty"`
+}
+
+// NotificationRuleConfig sends Event, package_unused or package_installed,
+// for the packages of Tools (every tool when empty) as a desktop
+// notification when Desktop is set and as JSON to WebhookURL when it is
+// set. UnusedDays is the package_unused threshold; GlobalOnly skips
+// package_installed for packages installed into a project or run once.
+type NotificationRuleConfig struct {
+	Event      string   `json:"event"`
+	Tools      []string `json:"tools,omitempty"`
+	UnusedDays int      `json:"unused_days,omitempty"`
+	GlobalOnly bool     `json:"global_only,omitempty"`
+	Desktop    bool     `json:"desktop,omitempty"`
+	WebhookURL string   `json:"webhook_url,omitempty"`
+}
+
+// PruneConfig controls which packages diu prune may suggest removing.
+type PruneConfig struct {
+	// Ignore lists packages to keep, as "name" for any tool or "tool/name".
+	Ignore []string `json:"ignore,omitempty"`
+	// Auto lets the daemon queue unused packages for removal.
+	Auto AutoPruneConfig `json:"auto"`
+}
+
+// AutoPruneConfig is the daemon's opt-in removal policy. A package unused
+// for UnusedDays is queued for removal and reported as a package_queued
+// event. The daemon uninstalls queued packages only for the tools listed in
+// UninstallTools, once they have stayed queued and unused for GraceDays;
+// the queued packages of other tools are only reported. Packages on the
+// prune ignore list are never queued.
+type AutoPruneConfig struct {
+	Enabled        bool     `json:"enabled"`
+	UnusedDays     int      `json:"unused_days"`
+	GraceDays      int      `json:"grace_days"`
+	UninstallTools []string `json:"uninstall_tools,omitempty"`
+}
+
+// Uninstalls reports whether the daemon may uninstall the queued packages
+// of tool
+func (c AutoPruneConfig) Uninstalls(tool string) bool {
+	tool = NormalizeToolName(tool)
+	for _, entry := range c.UninstallTools {
+		if NormalizeToolName(entry) == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignores reports whether the package is on the prune ignore list.
+func (c PruneConfig) Ignores(tool, name string) bool {
+	tool = NormalizeToolName(tool)
+	for _, entry := range c.Ignore {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entryTool, entryName, ok := strings.Cut(entry, "/"); ok && !strings.HasPrefix(entry, "@") {
+			if NormalizeToolName(entryTool) == tool && strings.EqualFold(entryName, name) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(entry, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactionConfig controls how secrets are scrubbed from recorded commands,
+// arguments, and environment values. Credential-bearing URLs, secret flags
+// such as --password, variables such as NPM_TOKEN, and well-known token
+// formats are always redacted unless Disabled is set. Patterns adds regular
+// expressions; when a pattern has a capture group only the first group is
+// replaced, otherwise the whole match is.
+type RedactionConfig struct {
+	Disabled bool     `json:"disabled,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// IgnoreConfig lists rules for executions that are dropped instead of
+// stored. Each rule is a glob, where * matches any run of characters
+// including / and ? matches one, or, with IgnoreRegexpPrefix, a regular
+// expression that may match anywhere. Globs must match the whole value.
+type IgnoreConfig struct {
+	// Paths match the working directory or any directory above it, so
+	// /tmp ignores everything run under /tmp.
+	Paths []string `json:"paths,omitempty"`
+	// Commands match the full command text, such as "npm run *".
+	Commands []string `json:"commands,omitempty"`
+	// Users match the user who ran the command.
+	Users []string `json:"users,omitempty"`
+}
+
+// SyncConfig pushes executions to the API of a central diu daemon, e.g. one
+// on a dev box shared with a laptop, and keeps the aggregate stats it
+// returns. Remote is its base URL such as http://devbox:8081. When Interval
+// is set the daemon syncs on that schedule; otherwise only diu sync does.
+type SyncConfig struct {
+	Remote   string        `json:"remote,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"`
+	// Token authenticates to a diu server, and is required of machines
+	// syncing to this daemon. DIU_SYNC_TOKEN is used when it is empty.
+	Token string `json:"token,omitempty"`
+}
+
+// AuthToken returns Token, or DIU_SYNC_TOKEN when it is empty
+func (c SyncConfig) AuthToken() string {
+	if c.Token != "" {
+		return c.Token
+	}
+	return os.Getenv(SyncTokenEnv)
+}
+
+// ServerConfig configures diu server, which accepts syncs from the daemons
+// of a whole team and keeps each user's machines apart. Users maps each user
+// name to the SHA-256 of their token, as written by diu server token, so
+// the config never holds a usable token. DataDir defaults to the server
+// directory inside daemon.data_dir.
+type ServerConfig struct {
+	Host    string            `json:"host"`
+	Port    int               `json:"port"`
+	DataDir string            `json:"data_dir,omitempty"`
+	Users   map[string]string `json:"users,omitempty"`
+	// MaxMachinesPerUser bounds how many machines each user may sync from,
+	// since machine names are chosen by the client; a sync from one more is
+	// refused. Zero allows any number.
+	MaxMachinesPerUser int `json:"max_machines_per_user"`
+}
+
+// AuditConfig configures diu audit. OSVURL is the OSV API to query, the
+// public OSV.dev API when empty, and CacheTTL how long its answers are
+// reused before asking again.
+type AuditConfig struct {
+	OSVURL   string        `json:"osv_url,omitempty"`
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
+}
+
+var tenantNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidTenantName reports whether name can name a diu server user or
+// machine. Names become directory names, so only letters, digits, '.', '_',
+// and '-' are allowed.
+func ValidTenantName(name string) bool {
+	return tenantNamePattern.MatchString(name)
+}
+
+// ServerDataDir returns where diu server keeps each user's history
+func (c *Config) ServerDataDir() string {
+	if c.Server.DataDir != "" {
+		return c.Server.DataDir
+	}
+	return filepath.Join(c.Daemon.DataDir, ServerDirName)
 }
 
 func DefaultConfig() *Config {
@@ -101,11 +699,16 @@ func DefaultConfig() *Config {
 	return &Config{
 		Version: ConfigVersion,
 		Daemon: DaemonConfig{
-			Port:       DefaultDaemonPort,
-			LogLevel:   DefaultLogLevel,
-			DataDir:    dataDir,
-			PIDFile:    DefaultPIDFilePath(dataDir),
-			SocketPath: DefaultSocketPath(dataDir),
+			Port:          DefaultDaemonPort,
+			LogLevel:      DefaultLogLevel,
+			LogFormat:     DefaultLogFormat,
+			LogFile:       DefaultLogFilePath(dataDir),
+			LogMaxSizeMB:  DefaultLogMaxSizeMB,
+			LogMaxAgeDays: DefaultLogMaxAgeDays,
+			LogMaxBackups: DefaultLogMaxBackups,
+			DataDir:       dataDir,
+			PIDFile:       DefaultPIDFilePath(dataDir),
+			SocketPath:    DefaultSocketPath(dataDir),
 		},
 		Storage: StorageConfig{
 			Backend:         StorageBackendJSON,
@@ -116,6 +719,11 @@ func DefaultConfig() *Config {
 			MaxExecutions:   DefaultMaxExecutions,
 			MaxStorageBytes: DefaultMaxStorageBytes,
 			MaxBackups:      DefaultMaxBackups,
+			AutoCleanup:     true,
+			CleanupInterval: DefaultCleanupInterval,
+			DedupeWindow:    DefaultDedupeWindow,
+			FlushInterval:   DefaultFlushInterval,
+			FlushCount:      DefaultFlushCount,
 		},
 		Monitoring: MonitoringConfig{
 			EnabledTools: DefaultEnabledTools,
@@ -123,6 +731,7 @@ func DefaultConfig() *Config {
 			Process: ProcessConfig{
 				WrapperDir:          filepath.Join(homeDir, ".local", "bin", "diu-wrappers"),
 				AutoInstallWrappers: true,
+				CaptureLines:        DefaultCaptureLines,
 			},
 			Filesystem: FilesystemConfig{
 				ScanInterval: 30 * time.Second,
@@ -155,28 +764,83 @@ func DefaultConfig() *Config {
 			Port:        DefaultAPIPort,
 			CORSEnabled: false,
 		},
+		Server: ServerConfig{
+			Host:               DefaultAPIHost,
+			Port:               DefaultServerPort,
+			MaxMachinesPerUser: DefaultServerMachines,
+		},
+		Audit: AuditConfig{
+			CacheTTL: DefaultAuditCacheTTL,
+		},
+		Prune: PruneConfig{
+			Auto: AutoPruneConfig{
+				UnusedDays: DefaultAutoPruneUnusedDays,
+				GraceDays:  DefaultAutoPruneGraceDays,
+			},
+		},
 		Reporting: ReportingConfig{
 			DailySummary:  true,
 			WeeklySummary: true,
 			EmailReports:  false,
+			SMTP: SMTPConfig{
+				Port: DefaultSMTPPort,
+			},
 		},
 	}
 }
 
+// DefaultConfigDir returns $XDG_CONFIG_HOME/diu, falling back to
+// ~/.config/diu when XDG_CONFIG_HOME is unset
+func DefaultConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "diu")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".config", "diu")
+}
+
+// DefaultConfigPath returns the first config file that exists in the config
+// directory, or config.json there when none does
+func DefaultConfigPath() string {
+	dir := DefaultConfigDir()
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+// LoadConfig reads the config at path, or at DefaultConfigPath when path is
+// empty. The format follows the file extension: .yaml, .yml, .toml, or JSON.
 func LoadConfig(path string) (*Config, error) {
 	if path == "" {
-		homeDir, _ := os.UserHomeDir()
-		path = filepath.Join(homeDir, ".config", "diu", "config.json")
+		path = DefaultConfigPath()
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
 	}
 
 	data, err := safefs.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return DefaultConfig(), nil
+			cfg := DefaultConfig()
+			cfg.path = path
+			return cfg, nil
 		}
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	data, err = configToJSON(ConfigFormatForPath(path), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	data, unknownKeys, err := normalizeConfigJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
 	cfg := DefaultConfig()
 	defaultWatchPaths := cfg.Monitoring.Filesystem.WatchPaths
 	cfg.Monitoring.Filesystem.WatchPaths = nil
@@ -186,23 +850,43 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Monitoring.Filesystem.WatchPaths == nil {
 		cfg.Monitoring.Filesystem.WatchPaths = defaultWatchPaths
 	}
+	cfg.path = path
+	cfg.unknownKeys = unknownKeys
 
 	return cfg, nil
 }
 
+// Path returns the file the config was loaded from, or "" for a config that
+// was built in memory
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Save writes the config back to the file it was loaded from, or to
+// DefaultConfigPath when it was not loaded from a file
 func (c *Config) Save() error {
-	homeDir, _ := os.UserHomeDir()
-	path := filepath.Join(homeDir, ".config", "diu", "config.json")
-	return c.SaveTo(path)
+	if c.path != "" {
+		return c.SaveTo(c.path)
+	}
+	return c.SaveTo(DefaultConfigPath())
 }
 
+// SaveTo writes the config to path in the format its extension names. An
+// existing YAML or TOML file is not overwritten, since its comments and key
+// order would be lost; ErrConfigNotWritable is returned instead.
 func (c *Config) SaveTo(path string) error {
+	if ConfigFormatForPath(path) != ConfigFormatJSON {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s: %w", path, ErrConfigNotWritable)
+		}
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, OwnerDirectoryMode); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := encodeConfig(ConfigFormatForPath(path), c)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -218,6 +902,7 @@ func (c *Config) EnsureDirectories() error {
 	dirs := []string{
 		c.Daemon.DataDir,
 		filepath.Dir(c.Daemon.PIDFile),
+		filepath.Dir(c.Daemon.LogFile),
 		filepath.Dir(c.Daemon.SocketPath),
 		filepath.Dir(c.Storage.JSONFile),
 		c.Monitoring.Process.WrapperDir,
@@ -3,7 +3,9 @@ package core
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -156,6 +158,30 @@ func TestLoadConfigHonorsPartialWatchPathsOverride(t *testing.T) {
 	}
 }
 
+func TestLoadConfigMigratesToolRetentionDays(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	data := []byte(`{"storage":{"tool_retention_days":{"brew":0,"go":1,"mise":7}},"tools":{"go":{"retention_days":30}}}`)
+	if err := os.WriteFile(configPath, data, PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if days := config.Tools.Homebrew.RetentionDays; days == nil || *days != 0 {
+		t.Errorf("Expected brew's entry moved to tools.homebrew.retention_days, got %v", days)
+	}
+	if days := config.Tools.Go.RetentionDays; days == nil || *days != 30 {
+		t.Errorf("Expected tools.go.retention_days kept over the old entry, got %v", days)
+	}
+	if got := strings.Join(config.UnknownKeys(), ","); got != "tools.mise" {
+		t.Errorf("Expected the entry for a tool without settings reported, got %q", got)
+	}
+}
+
 func TestConfigSave(t *testing.T) {
 	tempDir := t.TempDir()
 	configPath := filepath.Join(tempDir, "config.json")
@@ -187,6 +213,7 @@ func TestConfigSave(t *testing.T) {
 func TestConfigSaveUsesDefaultPath(t *testing.T) {
 	homeDir := t.TempDir()
 	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
 
 	config := DefaultConfig()
 	config.Daemon.Port = 9091
@@ -203,6 +230,56 @@ func TestConfigSaveUsesDefaultPath(t *testing.T) {
 	}
 }
 
+func TestConfigSaveWritesBackToLoadedPath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	path := filepath.Join(t.TempDir(), "custom.yaml")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Path() != path {
+		t.Fatalf("Path() = %s, want %s", config.Path(), path)
+	}
+	config.Daemon.Port = 9092
+	if err := config.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if loaded.Daemon.Port != 9092 {
+		t.Errorf("Loaded daemon port = %d, want 9092", loaded.Daemon.Port)
+	}
+	if _, err := os.Stat(DefaultConfigPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected no config at the default path, got %v", err)
+	}
+}
+
+func TestDefaultDirsHonorXDG(t *testing.T) {
+	configHome := t.TempDir()
+	dataHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	if got, want := DefaultConfigPath(), filepath.Join(configHome, "diu", "config.json"); got != want {
+		t.Errorf("DefaultConfigPath() = %s, want %s", got, want)
+	}
+	if got, want := DefaultDataDir(), filepath.Join(dataHome, "diu"); got != want {
+		t.Errorf("DefaultDataDir() = %s, want %s", got, want)
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", "relative/path")
+	homeDir, _ := os.UserHomeDir()
+	if got, want := DefaultDataDir(), filepath.Join(homeDir, ".local", "share", "diu"); got != want {
+		t.Errorf("DefaultDataDir() with relative XDG_DATA_HOME = %s, want %s", got, want)
+	}
+}
+
 func TestLoadConfigErrors(t *testing.T) {
 	tempDir := t.TempDir()
 	malformedPath := filepath.Join(tempDir, "malformed.json")
@@ -257,11 +334,168 @@ func TestShellEscapeString(t *testing.T) {
 	}
 }
 
-func containsString(values []string, target string) bool {
-	for _, value := range values {
-		if value == target {
-			return true
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	month, forever := 30, 0
+	config := DefaultConfig()
+	config.Storage.RetentionDays = 365
+	config.Tools.Go.RetentionDays = &month
+	config.Tools.Homebrew.RetentionDays = &forever
+
+	tests := []struct {
+		tool string
+		want time.Time
+	}{
+		{ToolGo, now.AddDate(0, 0, -30)},
+		{ToolNPM, now.AddDate(0, 0, -365)},
+		{ToolHomebrew, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := config.RetentionCutoff(tt.tool, now); !got.Equal(tt.want) {
+			t.Errorf("RetentionCutoff(%s) = %v, want %v", tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestConfigToolSettings(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	disabled, enabled, days := false, true, 30
+	config := DefaultConfig()
+	config.Monitoring.EnabledTools = []string{"brew", ToolGo, ToolNPM}
+	config.Tools.Go.Enabled = &disabled
+	config.Tools.Go.RetentionDays = &days
+	config.Tools.Cargo.Enabled = &enabled
+	config.Monitoring.Plugins = []PluginConfig{{Name: "mise", Path: "diu-plugin-mise"}, {Name: "Cargo", Path: "diu-plugin-cargo"}}
+
+	if got := config.RetentionCutoff(ToolGo, now); !got.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("RetentionCutoff(go) = %v, want tools.go.retention_days", got)
+	}
+	if got := config.RetentionCutoff(ToolNPM, now); !got.Equal(now.AddDate(0, 0, -DefaultRetentionDays)) {
+		t.Errorf("RetentionCutoff(npm) = %v, want storage.retention_days", got)
+	}
+
+	if got := strings.Join(config.TrackedTools(), ","); got != "homebrew,npm,cargo,mise" {
+		t.Errorf("TrackedTools() = %q", got)
+	}
+	if !config.ToolDisabled("golang") || config.ToolDisabled(ToolNPM) || config.ToolDisabled(ToolPip) {
+		t.Error("Expected only go to be disabled")
+	}
+	if config.ToolEnabled(ToolPip) || !config.ToolEnabled(ToolCargo) {
+		t.Error("Expected pip to follow enabled_tools and cargo to be enabled explicitly")
+	}
+	if plugin, ok := config.Monitoring.Plugin("cargo"); !config.ToolEnabled("mise") || !ok || plugin.Path != "diu-plugin-cargo" {
+		t.Errorf("Expected plugins' tools tracked, got cargo's plugin %+v", plugin)
+	}
+
+	if err := config.SetToolEnabled("mise", false); err != nil || !config.ToolDisabled("mise") || config.ToolEnabled("mise") {
+		t.Errorf("Expected the mise plugin disabled, got %v", err)
+	}
+	if err := config.SetToolEnabled("cargo", false); err != nil || config.Tools.Cargo.Enabled == nil || *config.Tools.Cargo.Enabled || config.Monitoring.Plugins[1].Enabled != nil {
+		t.Errorf("Expected tools.cargo.enabled set rather than the plugin, got %v", err)
+	}
+	if err := config.SetToolEnabled("apt", true); err == nil {
+		t.Error("Expected a tool without settings or a plugin rejected")
+	}
+
+	if err := config.SetValue("tools.pip.retention_days", "14"); err != nil || *config.Tools.Pip.RetentionDays != 14 {
+		t.Fatalf("SetValue(tools.pip.retention_days) = %v", err)
+	}
+	if err := config.SetValue("tools.homebrew.enabled", "false"); err != nil || *config.Tools.Homebrew.Enabled {
+		t.Fatalf("SetValue(tools.homebrew.enabled) = %v", err)
+	}
+	if err := config.SetValue("tools.pip.retention_days", ""); err != nil || config.Tools.Pip.RetentionDays != nil {
+		t.Fatalf("Expected an empty value to clear tools.pip.retention_days, got %v", err)
+	}
+}
+
+func TestConfigIgnoreExecution(t *testing.T) {
+	config := DefaultConfig()
+	config.Tools.NPM.IgnoreSubcommands = []string{"run", "test"}
+	config.Tools.NPM.IgnorePackages = []string{"Noisy-Pkg"}
+	config.Tools.Homebrew.IgnoreSubcommands = []string{"list"}
+	config.Tools.Go.TrackSubcommands = []string{"get", " install"}
+
+	tests := []struct {
+		name     string
+		record   ExecutionRecord
+		ignored  bool
+		packages []string
+	}{
+		{"ignored subcommand", ExecutionRecord{Tool: ToolNPM, Args: []string{"run", "build"}, Metadata: map[string]interface{}{"subcommand": "run"}}, true, nil},
+		{"subcommand after flags", ExecutionRecord{Tool: ToolNPM, Args: []string{"--silent", "test"}}, true, nil},
+		{"other subcommand", ExecutionRecord{Tool: ToolNPM, Args: []string{"install", "tsx"}, PackagesAffected: []string{"tsx"}}, false, []string{"tsx"}},
+		{"only ignored packages", ExecutionRecord{Tool: ToolNPM, Args: []string{"install", "noisy-pkg"}, PackagesAffected: []string{"noisy-pkg"}}, true, nil},
+		{"some ignored packages", ExecutionRecord{Tool: ToolNPM, Args: []string{"install"}, PackagesAffected: []string{"noisy-pkg", "tsx"}}, false, []string{"tsx"}},
+		{"another tool's rules", ExecutionRecord{Tool: "brew", Args: []string{"list"}}, true, nil},
+		{"tracked subcommand", ExecutionRecord{Tool: ToolGo, Args: []string{"install", "golang.org/x/tools/gopls@latest"}, Metadata: map[string]interface{}{"subcommand": "install"}}, false, nil},
+		{"untracked subcommand", ExecutionRecord{Tool: ToolGo, Args: []string{"test", "./..."}}, true, nil},
+		{"no subcommand with tracking", ExecutionRecord{Tool: ToolGo}, true, nil},
+		{"tool without rules", ExecutionRecord{Tool: ToolPip, Args: []string{"run"}, PackagesAffected: []string{"noisy-pkg"}}, false, []string{"noisy-pkg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			if got := config.IgnoreExecution(&record); got != tt.ignored {
+				t.Fatalf("IgnoreExecution() = %v, want %v", got, tt.ignored)
+			}
+			if !tt.ignored && strings.Join(record.PackagesAffected, ",") != strings.Join(tt.packages, ",") {
+				t.Errorf("PackagesAffected = %v, want %v", record.PackagesAffected, tt.packages)
+			}
+		})
+	}
+
+	if err := config.SetValue("tools.go.track_subcommands", "get,install"); err != nil || strings.Join(config.Tools.Go.TrackSubcommands, ",") != "get,install" {
+		t.Errorf("SetValue(tools.go.track_subcommands) = %v, got %v", err, config.Tools.Go.TrackSubcommands)
+	}
+	if err := config.SetValue("tools.pip.ignore_subcommands", "list, freeze"); err != nil || strings.Join(config.Tools.Pip.IgnoreSubcommands, ",") != "list,freeze" {
+		t.Errorf("SetValue(tools.pip.ignore_subcommands) = %v, got %v", err, config.Tools.Pip.IgnoreSubcommands)
+	}
+}
+
+func TestStorageBackupRetention(t *testing.T) {
+	tests := []struct {
+		keep      string
+		wantCount int
+		wantAge   time.Duration
+		wantErr   bool
+	}{
+		{keep: "", wantCount: 7},
+		{keep: "3", wantCount: 3},
+		{keep: "30d", wantAge: 30 * 24 * time.Hour},
+		{keep: "-1", wantErr: true},
+		{keep: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		config := StorageConfig{MaxBackups: 7, BackupKeep: tt.keep}
+		count, age, err := config.BackupRetention()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("BackupRetention(%q) error = %v, wantErr %v", tt.keep, err, tt.wantErr)
+		}
+		if count != tt.wantCount || age != tt.wantAge {
+			t.Errorf("BackupRetention(%q) = %d, %v; want %d, %v", tt.keep, count, age, tt.wantCount, tt.wantAge)
+		}
+	}
+}
+
+func TestPruneConfigIgnores(t *testing.T) {
+	config := PruneConfig{Ignore: []string{"git", "npm/typescript", "@types/node", "brew/wget"}}
+
+	tests := []struct {
+		tool string
+		name string
+		want bool
+	}{
+		{ToolHomebrew, "git", true},
+		{ToolNPM, "git", true},
+		{ToolNPM, "typescript", true},
+		{ToolPNPM, "typescript", false},
+		{ToolNPM, "@types/node", true},
+		{ToolHomebrew, "wget", true},
+		{ToolHomebrew, "jq", false},
+	}
+	for _, tt := range tests {
+		if got := config.Ignores(tt.tool, tt.name); got != tt.want {
+			t.Errorf("Ignores(%s, %s) = %v, want %v", tt.tool, tt.name, got, tt.want)
 		}
 	}
-	return false
 }
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Config file formats, chosen by file extension.
+const (
+	ConfigFormatJSON = "json"
+	ConfigFormatYAML = "yaml"
+	ConfigFormatTOML = "toml"
+)
+
+// ErrConfigNotWritable is returned by SaveTo for an existing YAML or TOML
+// config, which diu leaves to be edited by hand rather than rewrite it
+// without its comments and key order.
+var ErrConfigNotWritable = errors.New("diu does not rewrite YAML or TOML configs, which would drop their comments; edit the file by hand")
+
+// configFileNames are the names LoadConfig looks for in the config
+// directory, in order; JSON stays first so existing setups are unaffected.
+var configFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// ConfigFormatForPath returns the format of a config file from its
+// extension, defaulting to JSON
+func ConfigFormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYAML
+	case ".toml":
+		return ConfigFormatTOML
+	default:
+		return ConfigFormatJSON
+	}
+}
+
+// configToJSON converts YAML or TOML config data to the equivalent JSON so
+// every format decodes through the same struct tags
+func configToJSON(format string, data []byte) ([]byte, error) {
+	var (
+		value interface{}
+		err   error
+	)
+	switch format {
+	case ConfigFormatYAML:
+		value, err = parseYAML(data)
+	case ConfigFormatTOML:
+		value, err = parseTOML(data)
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("config must be a mapping of keys to values")
+	}
+	return json.Marshal(value)
+}
+
+// encodeConfig writes c in the given format
+func encodeConfig(format string, c *Config) ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil || format == ConfigFormatJSON {
+		return data, err
+	}
+
+	var value map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if format == ConfigFormatTOML {
+		return encodeTOML(value)
+	}
+	return encodeYAML(value)
+}
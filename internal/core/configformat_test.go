@@ -0,0 +1,194 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlConfigFixture = `# Hand-edited config
+daemon:
+  port: 9191          # API port
+  log_level: "debug"
+monitoring:
+  enabled_tools: [homebrew, npm]
+  filesystem:
+    watch_paths:
+      npm:
+        - /opt/npm/bin
+storage:
+  tool_retention_days: {npm: 30, go: 0}
+notifications:
+  webhooks:
+    - url: https://hooks.example.com/diu
+      events:
+      - package_installed
+      headers:
+        Authorization: 'Bearer x''y'
+  chat:
+    - service: slack
+      url: https://hooks.slack.com/T
+      events: [execution_failed]
+      template: |
+        {{.Tool}} failed
+        in {{.Dir}}
+`
+
+const tomlConfigFixture = `# Hand-edited config
+[daemon]
+port = 9_191 # API port
+log_level = "debug"
+
+[monitoring]
+enabled_tools = [
+  "homebrew",
+  "npm", # trailing comma allowed
+]
+
+[monitoring.filesystem.watch_paths]
+npm = ['/opt/npm/bin']
+
+[storage]
+tool_retention_days = { npm = 30, go = 0 }
+
+[[notifications.webhooks]]
+url = "https://hooks.example.com/diu"
+events = ["package_installed"]
+
+[notifications.webhooks.headers]
+Authorization = "Bearer x'y"
+
+[[notifications.chat]]
+service = "slack"
+url = "https://hooks.slack.com/T"
+events = ["execution_failed"]
+template = """
+{{.Tool}} failed
+in {{.Dir}}
+"""
+`
+
+func TestLoadConfigYAMLAndTOML(t *testing.T) {
+	for name, fixture := range map[string]string{"config.yaml": yamlConfigFixture, "config.toml": tomlConfigFixture} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(fixture), PrivateFileMode); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: LoadConfig failed: %v", name, err)
+		}
+		if config.Daemon.Port != 9191 || config.Daemon.LogLevel != "debug" {
+			t.Errorf("%s: daemon = %+v", name, config.Daemon)
+		}
+		if config.Storage.RetentionDays != DefaultRetentionDays {
+			t.Errorf("%s: expected defaults for missing keys, got retention %d", name, config.Storage.RetentionDays)
+		}
+		if len(config.Monitoring.EnabledTools) != 2 || config.Monitoring.EnabledTools[1] != "npm" {
+			t.Errorf("%s: enabled_tools = %v", name, config.Monitoring.EnabledTools)
+		}
+		if paths := config.Monitoring.Filesystem.WatchPaths["npm"]; len(paths) != 1 || paths[0] != "/opt/npm/bin" {
+			t.Errorf("%s: watch_paths = %v", name, config.Monitoring.Filesystem.WatchPaths)
+		}
+		if days := config.Tools.Go.RetentionDays; days == nil || *days != 0 {
+			t.Errorf("%s: tools.go.retention_days = %v", name, days)
+		}
+		if days := config.Tools.NPM.RetentionDays; days == nil || *days != 30 {
+			t.Errorf("%s: tools.npm.retention_days = %v", name, days)
+		}
+		if len(config.Notifications.Webhooks) != 1 || config.Notifications.Webhooks[0].Headers["Authorization"] != "Bearer x'y" {
+			t.Errorf("%s: webhooks = %+v", name, config.Notifications.Webhooks)
+		}
+		if len(config.Notifications.Chat) != 1 || config.Notifications.Chat[0].Template != "{{.Tool}} failed\nin {{.Dir}}\n" {
+			t.Errorf("%s: chat = %+v", name, config.Notifications.Chat)
+		}
+	}
+}
+
+func TestConfigSaveRoundTripsEachFormat(t *testing.T) {
+	config := DefaultConfig()
+	config.Daemon.Port = 9292
+	config.Prune.Ignore = []string{"git", "npm/@scope/pkg"}
+	week := 7
+	config.Tools.NPM.RetentionDays = &week
+	config.Notifications.Webhooks = []WebhookConfig{{
+		URL:     "https://hooks.example.com/a b",
+		Events:  []string{"package_installed"},
+		Headers: map[string]string{"X-Token": "a\"b#c"},
+	}}
+	config.Notifications.Chat = []ChatConfig{{Service: "discord", URL: "https://discord.test", Events: []string{}, Template: "line one\nline two: {{.Tool}}"}}
+	want, _ := json.Marshal(config)
+
+	for _, name := range []string{"config.json", "config.yaml", "config.yml", "config.toml"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := config.SaveTo(path); err != nil {
+			t.Fatalf("%s: SaveTo failed: %v", name, err)
+		}
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			data, _ := os.ReadFile(path)
+			t.Fatalf("%s: LoadConfig failed: %v\n%s", name, err, data)
+		}
+		if got, _ := json.Marshal(loaded); string(got) != string(want) {
+			t.Errorf("%s: round trip changed config\n got %s\nwant %s", name, got, want)
+		}
+	}
+}
+
+func TestDefaultConfigPathFindsYAML(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	if got := DefaultConfigPath(); filepath.Base(got) != "config.json" {
+		t.Fatalf("DefaultConfigPath() = %s, want config.json when no file exists", got)
+	}
+
+	dir := filepath.Join(homeDir, ".config", "diu")
+	if err := os.MkdirAll(dir, OwnerDirectoryMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("daemon:\n  port: 9393\n"), PrivateFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Daemon.Port != 9393 {
+		t.Errorf("Expected port from config.yaml, got %d", config.Daemon.Port)
+	}
+
+	config.Daemon.Port = 9494
+	if err := config.Save(); !errors.Is(err, ErrConfigNotWritable) {
+		t.Fatalf("Expected Save to refuse rewriting config.yaml, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.yaml")); string(data) != "daemon:\n  port: 9393\n" {
+		t.Errorf("Expected config.yaml left as written, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); !os.IsNotExist(err) {
+		t.Error("Save should not create config.json beside config.yaml")
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad.yaml": "daemon:\n\tport: 1\n",
+		"dup.yaml": "a: 1\na: 2\n",
+		"bad.toml": "[daemon\nport = 1\n",
+		"val.toml": "port = nope\n",
+		"dup.toml": "a = 1\na = 2\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), PrivateFileMode); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: expected a parse error", name)
+		}
+	}
+}
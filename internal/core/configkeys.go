@@ -0,0 +1,202 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetValue returns the setting named by a dotted key of JSON field names,
+// e.g. tools.homebrew.track_casks. The last segment may name a map entry,
+// as in monitoring.filesystem.watch_paths.npm.
+func (c *Config) GetValue(key string) (interface{}, error) {
+	target, mapKey, err := c.resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !mapKey.IsValid() {
+		return target.Interface(), nil
+	}
+	value := target.MapIndex(mapKey)
+	if !value.IsValid() {
+		return nil, fmt.Errorf("unknown config key: %s", key)
+	}
+	return value.Interface(), nil
+}
+
+// SetValue parses value for the type of the setting key names and stores
+// it. Lists are comma-separated, durations accept units such as "12h" or
+// "7d", and maps, structs, and lists of structs are given as JSON.
+func (c *Config) SetValue(key, value string) error {
+	target, mapKey, err := c.resolveKey(key)
+	if err != nil {
+		return err
+	}
+
+	name := key[strings.LastIndex(key, ".")+1:]
+	valueType := target.Type()
+	if mapKey.IsValid() {
+		valueType = valueType.Elem()
+	}
+	parsed, err := parseConfigValue(valueType, value)
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %w", name, err)
+	}
+
+	if !mapKey.IsValid() {
+		target.Set(parsed)
+		return nil
+	}
+	if target.IsNil() {
+		target.Set(reflect.MakeMap(target.Type()))
+	}
+	target.SetMapIndex(mapKey, parsed)
+	return nil
+}
+
+// resolveKey walks key through c. When the last segment names a map entry,
+// the map is returned with the entry's key, since map entries are not
+// addressable.
+func (c *Config) resolveKey(key string) (reflect.Value, reflect.Value, error) {
+	unknown := fmt.Errorf("unknown config key: %s", key)
+	if key == "" {
+		return reflect.Value{}, reflect.Value{}, unknown
+	}
+
+	segments := strings.Split(key, ".")
+	current := reflect.ValueOf(c).Elem()
+	for i, segment := range segments {
+		switch current.Kind() {
+		case reflect.Struct:
+			field, ok := configField(current, segment)
+			if !ok {
+				return reflect.Value{}, reflect.Value{}, unknown
+			}
+			current = field
+		case reflect.Map:
+			if i != len(segments)-1 || segment == "" || current.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, reflect.Value{}, unknown
+			}
+			return current, reflect.ValueOf(segment).Convert(current.Type().Key()), nil
+		default:
+			return reflect.Value{}, reflect.Value{}, unknown
+		}
+	}
+	return current, reflect.Value{}, nil
+}
+
+// configField returns the exported field of v whose JSON name is name
+func configField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && tagName == "" && field.Type.Kind() == reflect.Struct {
+			if embedded, ok := configField(v.Field(i), name); ok {
+				return embedded, true
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if tagName == "" {
+			tagName = field.Name
+		}
+		if tagName == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func parseConfigValue(t reflect.Type, value string) (reflect.Value, error) {
+	parsed := reflect.New(t).Elem()
+	if t == durationType {
+		duration, err := ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		parsed.SetInt(int64(duration))
+		return parsed, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		parsed.SetString(value)
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		parsed.SetBool(enabled)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		parsed.SetInt(number)
+	case reflect.Ptr:
+		// An empty value clears an optional setting.
+		if strings.TrimSpace(value) == "" {
+			return parsed, nil
+		}
+		elem, err := parseConfigValue(t.Elem(), value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		parsed.Set(reflect.New(t.Elem()))
+		parsed.Elem().Set(elem)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			parsed.Set(reflect.ValueOf(SplitList(value)).Convert(t))
+			return parsed, nil
+		}
+		fallthrough
+	default:
+		if err := json.Unmarshal([]byte(value), parsed.Addr().Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return parsed, nil
+}
+
+// FormatConfigValue renders a value returned by GetValue: lists are joined
+// with commas, durations use their short form, unset optional settings are
+// empty, and anything structured is printed as JSON.
+func FormatConfigValue(value interface{}) (string, error) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		return FormatConfigValue(v.Elem().Interface())
+	}
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String(), nil
+	case string, bool, int, int64:
+		return fmt.Sprint(v), nil
+	case []string:
+		return strings.Join(v, ", "), nil
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SplitList splits a comma-separated value, trimming items and dropping
+// blanks
+func SplitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
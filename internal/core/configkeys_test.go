@@ -0,0 +1,73 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigSetValueParsesByType(t *testing.T) {
+	config := DefaultConfig()
+
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"tools.homebrew.track_casks", "false", "false"},
+		{"monitoring.process.wrapper_dir", "/opt/wrappers", "/opt/wrappers"},
+		{"monitoring.enabled_tools", "npm, go,", "npm, go"},
+		{"monitoring.filesystem.scan_interval", "2m", "2m0s"},
+		{"storage.backup_interval", "1d", "24h0m0s"},
+		{"storage.max_storage_bytes", "1048576", "1048576"},
+		{"tools.npm.retention_days", "30", "30"},
+		{"monitoring.filesystem.watch_paths.bun", "/a,/b", "/a, /b"},
+		{"notifications.webhooks", `[{"url": "https://example.com", "events": ["package_installed"]}]`, `"url": "https://example.com"`},
+	}
+	for _, tt := range tests {
+		if err := config.SetValue(tt.key, tt.value); err != nil {
+			t.Fatalf("SetValue(%s, %q) failed: %v", tt.key, tt.value, err)
+		}
+		value, err := config.GetValue(tt.key)
+		if err != nil {
+			t.Fatalf("GetValue(%s) failed: %v", tt.key, err)
+		}
+		got, err := FormatConfigValue(value)
+		if err != nil {
+			t.Fatalf("FormatConfigValue(%s) failed: %v", tt.key, err)
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("GetValue(%s) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if config.Storage.BackupInterval != 24*time.Hour || config.Tools.Homebrew.TrackCasks {
+		t.Errorf("Expected fields to be set directly, got %+v", config.Storage)
+	}
+	if len(config.Notifications.Webhooks) != 1 || config.Notifications.Webhooks[0].Events[0] != "package_installed" {
+		t.Errorf("Unexpected webhooks: %+v", config.Notifications.Webhooks)
+	}
+}
+
+func TestConfigValueErrors(t *testing.T) {
+	config := DefaultConfig()
+
+	for _, key := range []string{"", "daemon.nope", "daemon.port.x", "monitoring.filesystem.watch_paths.go.x", "path"} {
+		if _, err := config.GetValue(key); err == nil || !strings.Contains(err.Error(), "unknown config key") {
+			t.Errorf("GetValue(%q) error = %v, want unknown config key", key, err)
+		}
+	}
+	if _, err := config.GetValue("monitoring.filesystem.watch_paths.nope"); err == nil {
+		t.Error("Expected a missing map entry to be unknown")
+	}
+
+	if err := config.SetValue("daemon.port", "http"); err == nil || !strings.Contains(err.Error(), "invalid port value") {
+		t.Errorf("Expected invalid port value error, got %v", err)
+	}
+	if err := config.SetValue("api.enabled", "maybe"); err == nil {
+		t.Error("Expected an invalid boolean to fail")
+	}
+	if err := config.SetValue("storage.cleanup_interval", "soon"); err == nil {
+		t.Error("Expected an invalid duration to fail")
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -11,11 +12,15 @@ var Version = "0.1.2" // x-release-please-version
 
 const (
 	ConfigVersion = "1.0"
+	// StorageVersion is the schema version of the storage files this build
+	// writes; older files are migrated to it when opened.
+	StorageVersion = "1.2.0"
 
 	ToolHomebrew = "homebrew"
 	ToolNPM      = "npm"
 	ToolPNPM     = "pnpm"
 	ToolBun      = "bun"
+	ToolYarn     = "yarn"
 	ToolGo       = "go"
 	ToolPip      = "pip"
 	ToolUV       = "uv"
@@ -23,31 +28,98 @@ const (
 	ToolGem      = "gem"
 	ToolCargo    = "cargo"
 	ToolGoBinary = "go-binary"
-
-	DefaultDaemonPort        = 8080
-	DefaultAPIPort           = 8081
-	DefaultAPIHost           = "127.0.0.1"
-	DefaultLogLevel          = "info"
-	DefaultRetentionDays     = 365
-	DefaultMaxExecutions     = 50000
-	DefaultMaxStorageBytes   = 10 * 1024 * 1024
-	DefaultMaxBackups        = 7
-	DefaultEventBuffer       = 100
-	DefaultShutdownTimeout   = 5 * time.Second
-	DefaultSocketReadTimeout = 30 * time.Second
+	ToolMake     = "make"
+	ToolJust     = "just"
+	ToolTask     = "task"
+
+	DefaultDaemonPort          = 8080
+	DefaultAPIPort             = 8081
+	DefaultAPIHost             = "127.0.0.1"
+	DefaultServerPort          = 8090
+	DefaultServerMachines      = 16
+	DefaultServerOpenStores    = 64
+	DefaultLogLevel            = "info"
+	DefaultLogFormat           = "text"
+	DefaultLogMaxSizeMB        = 10
+	DefaultLogMaxAgeDays       = 30
+	DefaultLogMaxBackups       = 5
+	DefaultRetentionDays       = 365
+	DefaultMaxExecutions       = 50000
+	DefaultMaxStorageBytes     = 10 * 1024 * 1024
+	DefaultMaxBackups          = 7
+	DefaultCleanupInterval     = 24 * time.Hour
+	DefaultDedupeWindow        = 2 * time.Second
+	DefaultFlushInterval       = time.Second
+	DefaultFlushCount          = 500
+	DefaultAuditCacheTTL       = 24 * time.Hour
+	DefaultReportCheckInterval = time.Hour
+	DefaultConfigPollInterval  = 2 * time.Second
+	DefaultSyncCheckInterval   = time.Minute
+	DefaultSMTPPort            = 587
+	DefaultWebhookRetries      = 3
+	DefaultWebhookUnusedDays   = 90
+	DefaultAutoPruneUnusedDays = 180
+	DefaultAutoPruneGraceDays  = 7
+	DefaultThresholdWindow     = 24 * time.Hour
+	DefaultThresholdInterval   = 10 * time.Minute
+	DefaultUninstallTimeout    = 5 * time.Minute
+	MaxCommandLength           = 4096
+	MaxOutputLength            = 4096
+	DefaultCaptureLines        = 20
+	DefaultPluginTimeout       = 30 * time.Second
+	DefaultEventBuffer         = 100
+	DefaultEventSpillBytes     = 64 * 1024 * 1024
+	DefaultShutdownTimeout     = 5 * time.Second
+	DefaultSocketReadTimeout   = 30 * time.Second
+	DefaultSocketIdleTimeout   = 10 * time.Second
+	DefaultSocketWorkers       = 16
 
 	OwnerDirectoryMode  = 0o700
 	PrivateFileMode     = 0o600
 	OwnerExecutableMode = 0o700
 	ExecutableModeMask  = 0o111
 
-	DefaultPIDFileName    = "diu.pid"
-	DefaultSocketFileName = "diu.sock"
+	DefaultPIDFileName     = "diu.pid"
+	DefaultSocketFileName  = "diu.sock"
+	DefaultLogFileName     = "diu.log"
+	ReportStateFileName    = "reports.json"
+	NotifyStateFileName    = "notifications.json"
+	SyncStateFileName      = "sync.json"
+	AutoPruneStateFileName = "auto_prune.json"
+	ThresholdStateFileName = "thresholds.json"
+	OSVCacheFileName       = "osv-cache.json"
+	EventSpillFileName     = "events.spill"
+	ServerDirName          = "server"
+
+	SMTPPasswordEnv = "DIU_SMTP_PASSWORD"
+	SyncTokenEnv    = "DIU_SYNC_TOKEN"
+	// SessionIDEnv is exported by the shell config diu setup writes, as the
+	// shell's PID and start time, and read by the wrappers.
+	SessionIDEnv = "DIU_SESSION_ID"
 
 	StorageBackendJSON = "json"
 
+	// The metrics reporting.thresholds can alert on.
+	ThresholdMetricInstalls        = "installs"
+	ThresholdMetricGlobalInstalls  = "global_installs"
+	ThresholdMetricExecutions      = "executions"
+	ThresholdMetricTrackedPackages = "tracked_packages"
+
+	// IgnoreRegexpPrefix marks an ignore rule as a regular expression
+	// rather than a glob.
+	IgnoreRegexpPrefix = "re:"
+
 	MonitorMethodProcess    = "process"
 	MonitorMethodFilesystem = "filesystem"
+
+	HealthStatusHealthy  = "healthy"
+	HealthStatusDegraded = "degraded"
+	HealthStatusOK       = "ok"
+	HealthStatusError    = "error"
+	HealthStatusLowDisk  = "low_disk"
+	HealthStatusActive   = "active"
+
+	MinHealthyDiskFreeBytes = 64 * 1024 * 1024
 )
 
 var (
@@ -56,10 +128,31 @@ var (
 		ToolNPM,
 		ToolPNPM,
 		ToolBun,
+		ToolYarn,
+		ToolGo,
+		ToolPip,
+		ToolUV,
+		ToolPoetry,
+	}
+
+	// configurableTools are the tools with an entry under tools, in the
+	// order TrackedTools appends explicitly enabled ones.
+	configurableTools = []string{
+		ToolHomebrew,
+		ToolNPM,
+		ToolPNPM,
+		ToolBun,
+		ToolYarn,
 		ToolGo,
 		ToolPip,
 		ToolUV,
 		ToolPoetry,
+		ToolGem,
+		ToolCargo,
+		ToolGoBinary,
+		ToolMake,
+		ToolJust,
+		ToolTask,
 	}
 
 	DefaultMonitorMethods = []string{
@@ -98,7 +191,42 @@ func NormalizeToolName(tool string) string {
 	}
 }
 
+// ParseDuration parses durations like "24h", "7d", "1w", and "1mo" in
+// addition to the units accepted by time.ParseDuration.
+func ParseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	if strings.HasSuffix(s, "w") {
+		weeks, err := strconv.Atoi(strings.TrimSuffix(s, "w"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(weeks) * 7 * 24 * time.Hour, nil
+	}
+
+	if strings.HasSuffix(s, "mo") {
+		months, err := strconv.Atoi(strings.TrimSuffix(s, "mo"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(months) * 30 * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+// DefaultDataDir returns $XDG_DATA_HOME/diu, falling back to
+// ~/.local/share/diu when XDG_DATA_HOME is unset
 func DefaultDataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "diu")
+	}
 	homeDir := os.Getenv("HOME")
 	if dir, err := os.UserHomeDir(); err == nil {
 		homeDir = dir
@@ -113,6 +241,10 @@ func DefaultPIDFilePath(dataDir string) string {
 	return filepath.Join(dataDir, DefaultPIDFileName)
 }
 
+func DefaultLogFilePath(dataDir string) string {
+	return filepath.Join(dataDir, DefaultLogFileName)
+}
+
 func DefaultSocketPath(dataDir string) string {
 	return filepath.Join(dataDir, DefaultSocketFileName)
 }
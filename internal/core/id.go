@@ -0,0 +1,53 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var (
+	idMu   sync.Mutex
+	lastID uint64
+)
+
+// NewID returns a UUIDv7 (RFC 9562) for a new record. The first 48 bits are
+// the Unix time in milliseconds and the next 12 a fraction of the
+// millisecond, so IDs sort by creation time; the remaining 62 bits are
+// random. IDs made by this process always increase, even within one clock
+// tick or when the clock steps back.
+func NewID() string {
+	var id [16]byte
+	// crypto/rand.Read never returns an error as of Go 1.24.
+	_, _ = rand.Read(id[6:])
+
+	now := time.Now()
+	// 48 bits of milliseconds followed by 12 bits of sub-millisecond time.
+	stamp := uint64(now.UnixMilli())<<12 | uint64(now.Nanosecond()%int(time.Millisecond))*4096/uint64(time.Millisecond)
+	idMu.Lock()
+	if stamp <= lastID {
+		stamp = lastID + 1
+	}
+	lastID = stamp
+	idMu.Unlock()
+
+	ms := stamp >> 12
+	id[0], id[1], id[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	id[3], id[4], id[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	id[6] = 0x70 | byte(stamp>>8)&0x0f
+	id[7] = byte(stamp)
+	id[8] = 0x80 | id[8]&0x3f
+
+	var out [36]byte
+	hex.Encode(out[0:8], id[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], id[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], id[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], id[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], id[10:])
+	return string(out[:])
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+var uuidV7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewIDIsOrderedUUIDv7(t *testing.T) {
+	before := time.Now().UnixMilli()
+	ids := make([]string, 10000)
+	seen := make(map[string]bool, len(ids))
+	for i := range ids {
+		ids[i] = NewID()
+		if !uuidV7Pattern.MatchString(ids[i]) {
+			t.Fatalf("NewID() = %q, not a UUIDv7", ids[i])
+		}
+		if seen[ids[i]] {
+			t.Fatalf("NewID() repeated %q", ids[i])
+		}
+		seen[ids[i]] = true
+		if i > 0 && ids[i] <= ids[i-1] {
+			t.Fatalf("Expected IDs to increase, got %q after %q", ids[i], ids[i-1])
+		}
+	}
+
+	var ms int64
+	for _, c := range ids[0][:8] + ids[0][9:13] {
+		ms = ms<<4 | int64(hexValue(c))
+	}
+	if ms < before || ms > time.Now().UnixMilli() {
+		t.Errorf("Expected the ID to carry the current time, got %d", ms)
+	}
+}
+
+func hexValue(c rune) int {
+	if c >= 'a' {
+		return int(c-'a') + 10
+	}
+	return int(c - '0')
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MachineIDFileName holds the generated machine ID on systems that do not
+// provide one
+const MachineIDFileName = "machine_id"
+
+var platformUUIDPattern = regexp.MustCompile(`"IOPlatformUUID"\s*=\s*"([^"]+)"`)
+
+// MachineID returns a stable identifier for this machine, so records merged
+// from several machines stay attributable even when hostnames collide or
+// change. It is derived from the system's machine ID (/etc/machine-id on
+// Linux, the hardware UUID on macOS) and hashed so the system ID itself is
+// never stored. Elsewhere a random ID is generated once and kept in dataDir.
+// It returns "" when no ID can be found or stored.
+func MachineID(dataDir string) string {
+	if id := systemMachineID(); id != "" {
+		sum := sha256.Sum256([]byte("diu:" + id))
+		return hex.EncodeToString(sum[:8])
+	}
+	if dataDir == "" {
+		return ""
+	}
+
+	path := filepath.Join(dataDir, MachineIDFileName)
+	// #nosec G304 -- path is the machine ID file inside the configured data directory.
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id
+		}
+	}
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return ""
+	}
+	id := hex.EncodeToString(random)
+	if err := os.MkdirAll(dataDir, OwnerDirectoryMode); err != nil {
+		return ""
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), PrivateFileMode); err != nil {
+		return ""
+	}
+	return id
+}
+
+func systemMachineID() string {
+	switch runtime.GOOS {
+	case "linux":
+		for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+			// #nosec G304 -- fixed system paths.
+			if data, err := os.ReadFile(path); err == nil {
+				if id := strings.TrimSpace(string(data)); id != "" {
+					return id
+				}
+			}
+		}
+	case "darwin":
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, "ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+		if err != nil {
+			return ""
+		}
+		if match := platformUUIDPattern.FindSubmatch(output); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package core
+
+import "testing"
+
+func TestMachineIDIsStable(t *testing.T) {
+	dataDir := t.TempDir()
+	first := MachineID(dataDir)
+	if first == "" {
+		t.Fatal("Expected a machine ID")
+	}
+	if second := MachineID(dataDir); second != first {
+		t.Errorf("MachineID changed between calls: %q then %q", first, second)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PauseFileName holds the pause state in the data directory, so diu pause
+// works whether or not the daemon is running
+const PauseFileName = "paused.json"
+
+// PauseState says whether recording is paused. While it is, the daemon and
+// diu record drop executions instead of storing them.
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitempty"`
+	// Until is when recording resumes by itself; zero keeps it paused
+	// until diu resume.
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Active reports whether s pauses recording at now
+func (s PauseState) Active(now time.Time) bool {
+	return s.Paused && (s.Until.IsZero() || now.Before(s.Until))
+}
+
+// PauseStatePath is where the pause state of the data directory is kept
+func PauseStatePath(dataDir string) string {
+	return filepath.Join(dataDir, PauseFileName)
+}
+
+// LoadPauseState reads the pause state kept in dataDir. A missing file, or
+// a pause that has run out by now, is not paused.
+func LoadPauseState(dataDir string, now time.Time) (PauseState, error) {
+	path := PauseStatePath(dataDir)
+	// #nosec G304 -- path is the pause file inside the configured data directory.
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return PauseState{}, nil
+	}
+	if err != nil {
+		return PauseState{}, err
+	}
+	var state PauseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return PauseState{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if !state.Active(now) {
+		return PauseState{}, nil
+	}
+	return state, nil
+}
+
+// SavePauseState keeps state in dataDir, removing the file when state is
+// not paused
+func SavePauseState(dataDir string, state PauseState) error {
+	path := PauseStatePath(dataDir)
+	if !state.Paused {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, OwnerDirectoryMode); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, PrivateFileMode)
+}
+
+// Pause pauses recording in dataDir from now, for duration when it is
+// positive and until Resume otherwise, and returns the new state
+func Pause(dataDir string, duration time.Duration, now time.Time) (PauseState, error) {
+	state := PauseState{Paused: true, Since: now}
+	if current, err := LoadPauseState(dataDir, now); err == nil && current.Paused {
+		state.Since = current.Since
+	}
+	if duration > 0 {
+		state.Until = now.Add(duration)
+	}
+	return state, SavePauseState(dataDir, state)
+}
+
+// Resume resumes recording in dataDir
+func Resume(dataDir string) error {
+	return SavePauseState(dataDir, PauseState{})
+}
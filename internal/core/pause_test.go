@@ -0,0 +1,45 @@
+package core
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	dataDir := t.TempDir()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	if state, err := LoadPauseState(dataDir, now); err != nil || state.Paused {
+		t.Fatalf("Expected recording not paused without a pause file, got %+v, %v", state, err)
+	}
+
+	state, err := Pause(dataDir, time.Hour, now)
+	if err != nil || !state.Paused || !state.Since.Equal(now) || !state.Until.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Pause = %+v, %v", state, err)
+	}
+	if loaded, err := LoadPauseState(dataDir, now.Add(59*time.Minute)); err != nil || !loaded.Active(now.Add(59*time.Minute)) {
+		t.Errorf("Expected the pause to hold within the hour, got %+v, %v", loaded, err)
+	}
+	if loaded, err := LoadPauseState(dataDir, now.Add(time.Hour)); err != nil || loaded.Paused {
+		t.Errorf("Expected the pause to run out after an hour, got %+v, %v", loaded, err)
+	}
+
+	extended, err := Pause(dataDir, 0, now.Add(30*time.Minute))
+	if err != nil || !extended.Since.Equal(now) || !extended.Until.IsZero() {
+		t.Errorf("Expected an open-ended pause keeping its start, got %+v, %v", extended, err)
+	}
+	if loaded, err := LoadPauseState(dataDir, now.AddDate(1, 0, 0)); err != nil || !loaded.Paused {
+		t.Errorf("Expected an open-ended pause to hold, got %+v, %v", loaded, err)
+	}
+
+	if err := Resume(dataDir); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if _, err := os.Stat(PauseStatePath(dataDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected the pause file removed, got %v", err)
+	}
+	if err := Resume(dataDir); err != nil {
+		t.Errorf("Expected resuming twice to succeed, got %v", err)
+	}
+}
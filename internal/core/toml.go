@@ -0,0 +1,569 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of TOML that config files need: tables,
+// arrays of tables, dotted and quoted keys, strings (including multi-line),
+// integers, floats, booleans, arrays, inline tables, and comments. Dates
+// and times are not supported.
+
+// parseTOML decodes a TOML document into maps, slices, and scalars
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{src: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+	root := make(map[string]interface{})
+	current := root
+
+	for {
+		p.skipSpaceAndComments(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			table, err := p.parseTableHeader(root)
+			if err != nil {
+				return nil, err
+			}
+			current = table
+			continue
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume('=') {
+			return nil, p.errorf("expected = after key %s", strings.Join(key, "."))
+		}
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setTOMLKey(current, key, value); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("toml line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) consume(char byte) bool {
+	if p.peek() == char {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipSpaceAndComments skips blanks and comments, and newlines when
+// newlines is set
+func (p *tomlParser) skipSpaceAndComments(newlines bool) {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.pos++
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.pos++
+			p.line++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endLine requires that only a comment follows on the current line
+func (p *tomlParser) endLine() error {
+	p.skipSpaceAndComments(false)
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	return nil
+}
+
+// parseTableHeader handles [table] and [[array.of.tables]] and returns the
+// table that following keys belong to
+func (p *tomlParser) parseTableHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	p.pos++
+	array := p.consume('[')
+	p.skipSpace()
+	key, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.consume(']') || (array && !p.consume(']')) {
+		return nil, p.errorf("unterminated table header")
+	}
+	if err := p.endLine(); err != nil {
+		return nil, err
+	}
+
+	parent, err := tomlTable(root, key[:len(key)-1])
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	last := key[len(key)-1]
+	if array {
+		existing, ok := parent[last]
+		if !ok {
+			existing = []interface{}{}
+		}
+		tables, ok := existing.([]interface{})
+		if !ok {
+			return nil, p.errorf("%s is not an array of tables", strings.Join(key, "."))
+		}
+		table := make(map[string]interface{})
+		parent[last] = append(tables, table)
+		return table, nil
+	}
+	return tomlTable(parent, []string{last})
+}
+
+// tomlTable walks key from table, creating tables as needed and descending
+// into the newest entry of arrays of tables
+func tomlTable(table map[string]interface{}, key []string) (map[string]interface{}, error) {
+	for _, part := range key {
+		switch next := table[part].(type) {
+		case nil:
+			child := make(map[string]interface{})
+			table[part] = child
+			table = child
+		case map[string]interface{}:
+			table = next
+		case []interface{}:
+			if len(next) == 0 {
+				return nil, fmt.Errorf("%s is an empty array", part)
+			}
+			child, ok := next[len(next)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", part)
+			}
+			table = child
+		default:
+			return nil, fmt.Errorf("%s is already a value", part)
+		}
+	}
+	return table, nil
+}
+
+func setTOMLKey(table map[string]interface{}, key []string, value interface{}) error {
+	parent, err := tomlTable(table, key[:len(key)-1])
+	if err != nil {
+		return err
+	}
+	last := key[len(key)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("duplicate key %s", strings.Join(key, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey reads a bare, quoted, or dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpace()
+		var part string
+		switch p.peek() {
+		case '"', '\'':
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			part = value
+		default:
+			start := p.pos
+			for !p.eof() && isBareConfigKey(string(p.peek())) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			part = p.src[start:p.pos]
+		}
+		parts = append(parts, part)
+		p.skipSpace()
+		if !p.consume('.') {
+			return parts, nil
+		}
+	}
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch char := p.peek(); {
+	case char == '"' || char == '\'':
+		return p.parseString()
+	case char == '[':
+		return p.parseArray()
+	case char == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\n#,]}", rune(p.peek())) {
+		p.pos++
+	}
+	raw := p.src[start:p.pos]
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, p.errorf("expected a value")
+	}
+	if number, ok := parseConfigNumber(raw); ok {
+		return number, nil
+	}
+	return nil, p.errorf("unsupported value %q", raw)
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	result := []interface{}{}
+	for {
+		p.skipSpaceAndComments(true)
+		if p.consume(']') {
+			return result, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+		p.skipSpaceAndComments(true)
+		if p.consume(']') {
+			return result, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	result := make(map[string]interface{})
+	p.skipSpace()
+	if p.consume('}') {
+		return result, nil
+	}
+	for {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume('=') {
+			return nil, p.errorf("expected = in inline table")
+		}
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setTOMLKey(result, key, value); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.skipSpace()
+		if p.consume('}') {
+			return result, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected , or } in inline table")
+		}
+		p.skipSpace()
+	}
+}
+
+// parseString reads basic, literal, and multi-line strings
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3)) {
+		return p.parseMultilineString(quote)
+	}
+
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != quote && p.peek() != '\n' {
+		if quote == '"' && p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if !p.consume(quote) {
+		return "", p.errorf("unterminated string")
+	}
+	body := p.src[start : p.pos-1]
+	if quote == '\'' {
+		return body, nil
+	}
+	return unescapeTOMLString(body, p)
+}
+
+func (p *tomlParser) parseMultilineString(quote byte) (string, error) {
+	delimiter := strings.Repeat(string(quote), 3)
+	p.pos += 3
+	end := strings.Index(p.src[p.pos:], delimiter)
+	if end < 0 {
+		return "", p.errorf("unterminated multi-line string")
+	}
+	body := p.src[p.pos : p.pos+end]
+	p.pos += end + 3
+	p.line += strings.Count(body, "\n")
+
+	// A newline right after the opening delimiter is trimmed.
+	body = strings.TrimPrefix(body, "\n")
+	if quote == '\'' {
+		return body, nil
+	}
+	return unescapeTOMLString(body, p)
+}
+
+func unescapeTOMLString(body string, p *tomlParser) (string, error) {
+	if !strings.Contains(body, "\\") {
+		return body, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return "", p.errorf("invalid escape at end of string")
+		}
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '"', '\\':
+			b.WriteByte(body[i])
+		case 'u', 'U':
+			size := 4
+			if body[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(body) {
+				return "", p.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(body[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			i += size
+		case '\n', ' ', '\t':
+			// A line-ending backslash joins lines, dropping leading whitespace.
+			for i < len(body) && strings.ContainsRune(" \t\n", rune(body[i])) {
+				i++
+			}
+			i--
+		default:
+			return "", p.errorf("invalid escape \\%c", body[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// encodeTOML writes a decoded JSON object as TOML with sorted keys. Nulls
+// are left out since TOML has no null.
+func encodeTOML(value map[string]interface{}) ([]byte, error) {
+	var b strings.Builder
+	if err := writeTOMLTable(&b, nil, value, false); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimPrefix(b.String(), "\n")), nil
+}
+
+func writeTOMLTable(b *strings.Builder, path []string, table map[string]interface{}, arrayItem bool) error {
+	keys := sortedKeys(table)
+	if len(path) > 0 {
+		header := tomlPath(path)
+		if arrayItem {
+			b.WriteString("\n[[" + header + "]]\n")
+		} else if hasTOMLValues(table) {
+			b.WriteString("\n[" + header + "]\n")
+		}
+	}
+
+	for _, key := range keys {
+		value := table[key]
+		if value == nil || isTOMLTable(value) || isTOMLTableArray(value) {
+			continue
+		}
+		inline, err := tomlInline(value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(tomlKey(key) + " = " + inline + "\n")
+	}
+
+	for _, key := range keys {
+		childPath := append(append([]string(nil), path...), key)
+		switch value := table[key].(type) {
+		case map[string]interface{}:
+			if !isTOMLTable(value) {
+				continue
+			}
+			if err := writeTOMLTable(b, childPath, value, false); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !isTOMLTableArray(value) {
+				continue
+			}
+			for _, item := range value {
+				if err := writeTOMLTable(b, childPath, item.(map[string]interface{}), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isTOMLTable reports whether value is written as a [table] section; empty
+// maps are written inline as {}
+func isTOMLTable(value interface{}) bool {
+	m, ok := value.(map[string]interface{})
+	return ok && len(m) > 0
+}
+
+// isTOMLTableArray reports whether value is a non-empty array of tables
+func isTOMLTableArray(value interface{}) bool {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTOMLValues reports whether a table has keys written under its own
+// header rather than only in subtables
+func hasTOMLValues(table map[string]interface{}) bool {
+	for _, value := range table {
+		if value != nil && !isTOMLTable(value) && !isTOMLTableArray(value) {
+			return true
+		}
+	}
+	return len(table) == 0
+}
+
+func tomlInline(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return quoteConfigString(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			part, err := tomlInline(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case map[string]interface{}:
+		parts := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			if v[key] == nil {
+				continue
+			}
+			part, err := tomlInline(v[key])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKey(key)+" = "+part)
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case nil:
+		return "", fmt.Errorf("toml cannot represent null values in arrays")
+	}
+	return "", fmt.Errorf("unsupported value %T", value)
+}
+
+func tomlKey(key string) string {
+	if isBareConfigKey(key) {
+		return key
+	}
+	quoted, _ := quoteConfigString(key)
+	return quoted
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, part := range path {
+		keys[i] = tomlKey(part)
+	}
+	return strings.Join(keys, ".")
+}
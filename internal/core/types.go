@@ -2,7 +2,12 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type ExecutionRecord struct {
@@ -18,6 +23,47 @@ type ExecutionRecord struct {
 	Environment      map[string]string      `json:"environment,omitempty"`
 	PackagesAffected []string               `json:"packages_affected,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	// ProjectName is the project named by the nearest package manifest
+	// above WorkingDir when the execution was recorded.
+	ProjectName string `json:"project,omitempty"`
+	// SessionID links executions run from the same shell session; see
+	// SessionIDEnv.
+	SessionID string `json:"session_id,omitempty"`
+	// Host and MachineID name the machine that recorded the execution; see
+	// MachineID.
+	Host      string `json:"host,omitempty"`
+	MachineID string `json:"machine_id,omitempty"`
+	// Output is the start and end of what the execution printed, when
+	// monitoring.process.capture_output is on.
+	Output *Output `json:"output,omitempty"`
+}
+
+// Output holds the first and last lines an execution wrote to stdout and
+// stderr, so a failed install keeps its error message
+type Output struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// Truncated is set when lines between the first and last were left out.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Limit keeps at most max bytes of each stream, dropping from the start
+// since tools print their errors last
+func (o *Output) Limit(max int) {
+	if o == nil {
+		return
+	}
+	for _, stream := range []*string{&o.Stdout, &o.Stderr} {
+		if len(*stream) <= max {
+			continue
+		}
+		start := len(*stream) - max
+		for start < len(*stream) && !utf8.RuneStart((*stream)[start]) {
+			start++
+		}
+		*stream = (*stream)[start:]
+		o.Truncated = true
+	}
 }
 
 type executionRecordJSON struct {
@@ -33,6 +79,11 @@ type executionRecordJSON struct {
 	Environment      map[string]string      `json:"environment,omitempty"`
 	PackagesAffected []string               `json:"packages_affected,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	Project          string                 `json:"project,omitempty"`
+	SessionID        string                 `json:"session_id,omitempty"`
+	Host             string                 `json:"host,omitempty"`
+	MachineID        string                 `json:"machine_id,omitempty"`
+	Output           *Output                `json:"output,omitempty"`
 }
 
 func (r ExecutionRecord) MarshalJSON() ([]byte, error) {
@@ -49,6 +100,11 @@ func (r ExecutionRecord) MarshalJSON() ([]byte, error) {
 		Environment:      r.Environment,
 		PackagesAffected: r.PackagesAffected,
 		Metadata:         r.Metadata,
+		Project:          r.ProjectName,
+		SessionID:        r.SessionID,
+		Host:             r.Host,
+		MachineID:        r.MachineID,
+		Output:           r.Output,
 	})
 }
 
@@ -70,9 +126,108 @@ func (r *ExecutionRecord) UnmarshalJSON(data []byte) error {
 	r.Environment = raw.Environment
 	r.PackagesAffected = raw.PackagesAffected
 	r.Metadata = raw.Metadata
+	r.ProjectName = raw.Project
+	r.SessionID = raw.SessionID
+	r.Host = raw.Host
+	r.MachineID = raw.MachineID
+	r.Output = raw.Output
 	return nil
 }
 
+// Project names the project an execution ran in: ProjectName when it was
+// detected, otherwise the last element of its working directory, or "" when
+// the directory is unknown
+func (r *ExecutionRecord) Project() string {
+	if r.ProjectName != "" {
+		return r.ProjectName
+	}
+	if r.WorkingDir == "" {
+		return ""
+	}
+	return filepath.Base(filepath.Clean(r.WorkingDir))
+}
+
+// Validate reports the first reason a submitted record cannot be stored
+func (r *ExecutionRecord) Validate() error {
+	if strings.TrimSpace(r.Tool) == "" {
+		return fmt.Errorf("tool is required")
+	}
+	if strings.TrimSpace(r.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if len(r.Command) > MaxCommandLength {
+		return fmt.Errorf("command exceeds %d bytes", MaxCommandLength)
+	}
+	if r.Duration < 0 {
+		return fmt.Errorf("duration_ms must be non-negative")
+	}
+	for _, pkg := range r.PackagesAffected {
+		if strings.TrimSpace(pkg) == "" {
+			return fmt.Errorf("packages_affected cannot contain empty values")
+		}
+	}
+	return nil
+}
+
+// MetadataVersionChanges is the metadata key install and upgrade
+// executions keep their VersionChanges under
+const MetadataVersionChanges = "version_changes"
+
+// VersionChange is a package's version before and after an execution that
+// installed or upgraded it. From is empty for a first install.
+type VersionChange struct {
+	Package string `json:"package"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+}
+
+// VersionChanges returns the package versions the execution installed or
+// upgraded, as kept in its metadata
+func (r *ExecutionRecord) VersionChanges() []VersionChange {
+	value, ok := r.Metadata[MetadataVersionChanges]
+	if !ok {
+		return nil
+	}
+	if changes, ok := value.([]VersionChange); ok {
+		return changes
+	}
+	// Metadata read back from JSON holds plain maps.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var changes []VersionChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil
+	}
+	return changes
+}
+
+// FromHost reports whether the execution was recorded on host, given as a
+// hostname (case-insensitive) or a machine ID
+func (r *ExecutionRecord) FromHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	return strings.EqualFold(r.Host, host) || r.MachineID == host
+}
+
+// InDir reports whether the execution ran in dir or one of its subdirectories
+func (r *ExecutionRecord) InDir(dir string) bool {
+	if r.WorkingDir == "" || dir == "" {
+		return false
+	}
+	workingDir := filepath.Clean(r.WorkingDir)
+	dir = filepath.Clean(dir)
+	if workingDir == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+	return strings.HasPrefix(workingDir, dir)
+}
+
 func durationFromJSONMilliseconds(value int64) time.Duration {
 	return time.Duration(value) * time.Millisecond
 }
@@ -86,6 +241,8 @@ type PackageInfo struct {
 	UsageCount   int       `json:"usage_count"`
 	Path         string    `json:"path,omitempty"`
 	Dependencies []string  `json:"dependencies,omitempty"`
+	License      string    `json:"license,omitempty"`
+	SizeBytes    int64     `json:"size_bytes,omitempty"`
 }
 
 type StorageData struct {
@@ -102,6 +259,24 @@ type StorageMetadata struct {
 	Hostname    string    `json:"hostname"`
 	User        string    `json:"user"`
 	DIUVersion  string    `json:"diu_version"`
+	// Shards lists the per-month files holding the executions, which the
+	// storage file itself does not.
+	Shards []ShardInfo `json:"shards,omitempty"`
+	// JournalSeq is the sequence number of the last journaled execution
+	// the file holds; journal entries up to it are not replayed.
+	JournalSeq int64 `json:"journal_seq,omitempty"`
+}
+
+// ShardInfo describes one month's shard file as of the last save
+type ShardInfo struct {
+	// Month is the UTC month the shard covers, as "2006-01".
+	Month      string    `json:"month"`
+	Executions int       `json:"executions"`
+	From       time.Time `json:"from"`
+	Through    time.Time `json:"through"`
+	// Oldest is the oldest execution per tool, so retention can tell
+	// whether the shard holds any expired ones without reading it.
+	Oldest map[string]time.Time `json:"oldest,omitempty"`
 }
 
 type StorageStatistics struct {
@@ -109,6 +284,147 @@ type StorageStatistics struct {
 	ToolsUsed          []string       `json:"tools_used"`
 	MostActiveDay      string         `json:"most_active_day"`
 	ExecutionFrequency map[string]int `json:"execution_frequency"`
+	// Days holds the counts of each day with executions, keyed by
+	// YYYY-MM-DD, so stats over days need not scan the executions.
+	Days map[string]DayAggregate `json:"days,omitempty"`
+	// Hours counts executions by local hour of day, 0 through 23, and
+	// Weekdays by local weekday, Sunday first.
+	Hours    []int `json:"hours,omitempty"`
+	Weekdays []int `json:"weekdays,omitempty"`
+}
+
+// DayAggregate counts the executions of one day per tool and per package,
+// keyed tool/name
+type DayAggregate struct {
+	Executions int            `json:"executions"`
+	Tools      map[string]int `json:"tools"`
+	Packages   map[string]int `json:"packages,omitempty"`
+}
+
+// Count adds record to the totals, to its day's counts, and to the hour and
+// weekday it ran, keeping MostActiveDay up to date
+func (s *StorageStatistics) Count(record *ExecutionRecord) {
+	s.TotalExecutions++
+	if record.Tool != "" {
+		if s.ExecutionFrequency == nil {
+			s.ExecutionFrequency = make(map[string]int)
+		}
+		if _, exists := s.ExecutionFrequency[record.Tool]; !exists {
+			s.ToolsUsed = append(s.ToolsUsed, record.Tool)
+		}
+		s.ExecutionFrequency[record.Tool]++
+	}
+	if record.Timestamp.IsZero() {
+		return
+	}
+
+	key := record.Timestamp.Format(time.DateOnly)
+	if s.Days == nil {
+		s.Days = make(map[string]DayAggregate)
+	}
+	day := s.Days[key]
+	if day.Tools == nil {
+		day.Tools = make(map[string]int)
+	}
+	day.Executions++
+	if record.Tool != "" {
+		day.Tools[record.Tool]++
+	}
+	for _, pkg := range record.PackagesAffected {
+		if day.Packages == nil {
+			day.Packages = make(map[string]int)
+		}
+		day.Packages[record.Tool+"/"+pkg]++
+	}
+	s.Days[key] = day
+
+	if s.Hours == nil {
+		s.Hours = make([]int, 24)
+	}
+	if s.Weekdays == nil {
+		s.Weekdays = make([]int, 7)
+	}
+	local := record.Timestamp.Local()
+	s.Hours[local.Hour()]++
+	s.Weekdays[local.Weekday()]++
+
+	if most := s.Days[s.MostActiveDay].Executions; day.Executions > most || (day.Executions == most && key > s.MostActiveDay) {
+		s.MostActiveDay = key
+	}
+}
+
+// SumDays adds up the days from first up to but not including last, both
+// YYYY-MM-DD. An empty bound leaves that end open.
+func (s *StorageStatistics) SumDays(first, last string) DayAggregate {
+	sum := DayAggregate{Tools: make(map[string]int), Packages: make(map[string]int)}
+	for key, day := range s.Days {
+		if (first != "" && key < first) || (last != "" && key >= last) {
+			continue
+		}
+		sum.Executions += day.Executions
+		for tool, count := range day.Tools {
+			sum.Tools[tool] += count
+		}
+		for pkg, count := range day.Packages {
+			sum.Packages[pkg] += count
+		}
+	}
+	return sum
+}
+
+type HealthStatus struct {
+	Status         string           `json:"status"`
+	Version        string           `json:"version"`
+	Uptime         string           `json:"uptime"`
+	MonitorsActive int              `json:"monitors_active"`
+	Storage        StorageHealth    `json:"storage"`
+	EventQueue     EventQueueHealth `json:"event_queue"`
+	Monitors       []MonitorHealth  `json:"monitors"`
+	// Pause is set while recording is paused.
+	Pause *PauseState `json:"pause,omitempty"`
+}
+
+type StorageHealth struct {
+	Backend       string    `json:"backend"`
+	Status        string    `json:"status"`
+	Path          string    `json:"path"`
+	SizeBytes     int64     `json:"size_bytes"`
+	LastSaved     time.Time `json:"last_saved,omitempty"`
+	DiskFreeBytes uint64    `json:"disk_free_bytes"`
+	Error         string    `json:"error,omitempty"`
+}
+
+type EventQueueHealth struct {
+	Length      int     `json:"length"`
+	Capacity    int     `json:"capacity"`
+	Utilization float64 `json:"utilization"`
+	// Spilled counts the events written to disk while the queue was full
+	// and not yet replayed; Dropped counts those lost altogether.
+	Spilled int64 `json:"spilled"`
+	Dropped int64 `json:"dropped"`
+}
+
+// MonitorHealth is what the daemon has seen of one monitor since it last
+// loaded its monitors
+type MonitorHealth struct {
+	Name             string    `json:"name"`
+	Status           string    `json:"status"`
+	Initialized      bool      `json:"initialized"`
+	WrapperPath      string    `json:"wrapper_path,omitempty"`
+	WrapperInstalled bool      `json:"wrapper_installed"`
+	LastEvent        time.Time `json:"last_event,omitempty"`
+	Errors           int64     `json:"errors"`
+	LastError        string    `json:"last_error,omitempty"`
+}
+
+// MonitorStatus describes a tool diu has a monitor for: whether the config
+// tracks it, whether the daemon's monitor for it is running, and the path
+// of its plugin when a plugin provides it
+type MonitorStatus struct {
+	Tool    string `json:"tool"`
+	Enabled bool   `json:"enabled"`
+	Active  bool   `json:"active"`
+	Plugin  string `json:"plugin,omitempty"`
 }
 
 type QueryOptions struct {
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -149,3 +150,47 @@ func TestStorageData(t *testing.T) {
 		t.Errorf("Expected user testuser, got %s", data.Metadata.User)
 	}
 }
+
+func TestExecutionRecordProjectAndInDir(t *testing.T) {
+	record := &ExecutionRecord{WorkingDir: filepath.Join("/home", "me", "src", "app")}
+
+	if got := record.Project(); got != "app" {
+		t.Errorf("Project() = %q, want app", got)
+	}
+	if got := (&ExecutionRecord{}).Project(); got != "" {
+		t.Errorf("Project() without working dir = %q, want empty", got)
+	}
+	detected := &ExecutionRecord{WorkingDir: record.WorkingDir, ProjectName: "@acme/app"}
+	if got := detected.Project(); got != "@acme/app" {
+		t.Errorf("Project() = %q, want the detected name", got)
+	}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{filepath.Join("/home", "me", "src", "app"), true},
+		{filepath.Join("/home", "me", "src"), true},
+		{filepath.Join("/home", "me", "src") + string(filepath.Separator), true},
+		{filepath.Join("/home", "me", "src", "ap"), false},
+		{filepath.Join("/home", "me", "src", "app", "web"), false},
+		{string(filepath.Separator), true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := record.InDir(tt.dir); got != tt.want {
+			t.Errorf("InDir(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestOutputLimitKeepsTheEnd(t *testing.T) {
+	output := &Output{Stdout: "short", Stderr: "héllo wörld"}
+	output.Limit(7)
+	if output.Stdout != "short" || output.Stderr != " wörld" || !output.Truncated {
+		t.Errorf("Unexpected limited output: %+v", output)
+	}
+
+	var missing *Output
+	missing.Limit(7)
+}
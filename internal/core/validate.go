@@ -0,0 +1,566 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+
+	validLogLevels        = []string{"", "debug", "info", "warn", "warning", "error"}
+	validLogFormats       = []string{"", "text", "json"}
+	validMonitorMethod    = []string{MonitorMethodProcess, MonitorMethodFilesystem}
+	validThresholdMetrics = []string{ThresholdMetricInstalls, ThresholdMetricGlobalInstalls, ThresholdMetricExecutions, ThresholdMetricTrackedPackages}
+)
+
+// ConfigIssue is one problem found by Validate. Warnings are reported but do
+// not stop the daemon from starting.
+type ConfigIssue struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (i ConfigIssue) String() string {
+	return i.Key + ": " + i.Message
+}
+
+// ConfigError lists the errors that make a config unusable
+type ConfigError struct {
+	Issues []ConfigIssue
+}
+
+func (e *ConfigError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.String()
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// ValidationError returns a *ConfigError for the non-warning issues, or nil
+// when there are none
+func ValidationError(issues []ConfigIssue) error {
+	var errs []ConfigIssue
+	for _, issue := range issues {
+		if !issue.Warning {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ConfigError{Issues: errs}
+}
+
+// UnknownKeys lists the keys in the loaded config file that do not match any
+// setting, most likely typos
+func (c *Config) UnknownKeys() []string {
+	return c.unknownKeys
+}
+
+// Validate checks ports, durations, limits, the storage backend, monitoring
+// methods, and that data paths are writable. Keys in the config file that
+// match no setting are reported as warnings.
+func (c *Config) Validate() []ConfigIssue {
+	var issues []ConfigIssue
+	fail := func(key, format string, args ...interface{}) {
+		issues = append(issues, ConfigIssue{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Port 0 is accepted so the API can listen on any free port.
+	checkPort := func(key string, port int) {
+		if port < 0 || port > 65535 {
+			fail(key, "port %d is out of range (0-65535)", port)
+		}
+	}
+	checkPort("daemon.port", c.Daemon.Port)
+	if c.API.Enabled {
+		checkPort("api.port", c.API.Port)
+		if strings.TrimSpace(c.API.Host) == "" {
+			fail("api.host", "must be set when the API is enabled")
+		}
+	}
+	if c.Reporting.EmailReports {
+		checkPort("reporting.smtp.port", c.Reporting.SMTP.Port)
+	}
+
+	if !containsString(validLogLevels, strings.ToLower(strings.TrimSpace(c.Daemon.LogLevel))) {
+		fail("daemon.log_level", "unknown level %q (want debug, info, warn, or error)", c.Daemon.LogLevel)
+	}
+	if !containsString(validLogFormats, c.Daemon.LogFormat) {
+		fail("daemon.log_format", "unknown format %q (want text or json)", c.Daemon.LogFormat)
+	}
+
+	if c.Storage.Backend != "" && c.Storage.Backend != StorageBackendJSON {
+		fail("storage.backend", "unsupported backend %q (want %s)", c.Storage.Backend, StorageBackendJSON)
+	}
+	if _, _, err := c.Storage.BackupRetention(); err != nil {
+		fail("storage.backup_keep", "%v", err)
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value int64
+	}{
+		{"daemon.log_max_size_mb", int64(c.Daemon.LogMaxSizeMB)},
+		{"daemon.log_max_age_days", int64(c.Daemon.LogMaxAgeDays)},
+		{"daemon.log_max_backups", int64(c.Daemon.LogMaxBackups)},
+		{"storage.retention_days", int64(c.Storage.RetentionDays)},
+		{"storage.max_executions", int64(c.Storage.MaxExecutions)},
+		{"storage.max_storage_bytes", c.Storage.MaxStorageBytes},
+		{"storage.max_backups", int64(c.Storage.MaxBackups)},
+		{"server.max_machines_per_user", int64(c.Server.MaxMachinesPerUser)},
+	} {
+		if limit.value < 0 {
+			fail(limit.key, "must be non-negative")
+		}
+	}
+
+	for _, tool := range configurableTools {
+		settings := c.Tools.Settings(tool)
+		if days := settings.RetentionDays; days != nil && *days < 0 {
+			fail("tools."+tool+".retention_days", "must be non-negative")
+		}
+		for _, list := range []struct {
+			key     string
+			entries []string
+		}{
+			{"track_subcommands", settings.TrackSubcommands},
+			{"ignore_subcommands", settings.IgnoreSubcommands},
+			{"ignore_packages", settings.IgnorePackages},
+		} {
+			for i, entry := range list.entries {
+				if strings.TrimSpace(entry) == "" {
+					fail(fmt.Sprintf("tools.%s.%s[%d]", tool, list.key, i), "must not be empty")
+				}
+			}
+		}
+	}
+
+	checkInterval := func(key string, interval time.Duration, required bool) {
+		if interval < 0 || (required && interval == 0) {
+			fail(key, "interval %s must be positive", interval)
+		}
+	}
+	checkInterval("storage.backup_interval", c.Storage.BackupInterval, c.Storage.BackupEnabled)
+	checkInterval("storage.cleanup_interval", c.Storage.CleanupInterval, c.Storage.AutoCleanup)
+	if c.Storage.DedupeWindow < 0 {
+		fail("storage.dedupe_window", "must be non-negative")
+	}
+	if c.Storage.FlushInterval < 0 {
+		fail("storage.flush_interval", "must be non-negative")
+	}
+	if c.Storage.FlushCount < 0 {
+		fail("storage.flush_count", "must be non-negative")
+	}
+	if c.Storage.MemoryDays < 0 {
+		fail("storage.memory_days", "must be non-negative")
+	}
+	if c.Monitoring.Process.CaptureLines < 0 {
+		fail("monitoring.process.capture_lines", "must be non-negative")
+	}
+	checkInterval("monitoring.filesystem.scan_interval", c.Monitoring.Filesystem.ScanInterval,
+		containsString(c.Monitoring.Methods, MonitorMethodFilesystem))
+
+	for _, method := range c.Monitoring.Methods {
+		if !containsString(validMonitorMethod, method) {
+			fail("monitoring.methods", "unknown method %q (want %s or %s)", method, MonitorMethodProcess, MonitorMethodFilesystem)
+		}
+	}
+
+	plugins := make(map[string]bool, len(c.Monitoring.Plugins))
+	for i, plugin := range c.Monitoring.Plugins {
+		key := fmt.Sprintf("monitoring.plugins[%d]", i)
+		name := NormalizeToolName(plugin.Name)
+		switch {
+		case name == "":
+			fail(key+".name", "must be set")
+		case plugins[name]:
+			fail(key+".name", "another plugin already tracks %s", name)
+		}
+		plugins[name] = true
+		if strings.TrimSpace(plugin.Path) == "" {
+			fail(key+".path", "must be set")
+		}
+		if plugin.Timeout < 0 {
+			fail(key+".timeout", "must be non-negative")
+		}
+	}
+
+	if c.Daemon.DataDir == "" {
+		fail("daemon.data_dir", "must be set")
+	}
+	if c.Storage.JSONFile == "" {
+		fail("storage.json_file", "must be set")
+	}
+	for _, path := range []struct {
+		key  string
+		path string
+		dir  bool
+	}{
+		{"daemon.data_dir", c.Daemon.DataDir, true},
+		{"daemon.pid_file", c.Daemon.PIDFile, false},
+		{"daemon.log_file", c.Daemon.LogFile, false},
+		{"daemon.socket_path", c.Daemon.SocketPath, false},
+		{"storage.json_file", c.Storage.JSONFile, false},
+		{"monitoring.process.wrapper_dir", c.Monitoring.Process.WrapperDir, true},
+	} {
+		if path.path == "" {
+			continue
+		}
+		if err := checkWritablePath(path.path, path.dir); err != nil {
+			fail(path.key, "%v", err)
+		}
+	}
+
+	if c.Prune.Auto.Enabled && c.Prune.Auto.UnusedDays <= 0 {
+		fail("prune.auto.unused_days", "must be positive")
+	}
+	if c.Prune.Auto.GraceDays < 0 {
+		fail("prune.auto.grace_days", "must be non-negative")
+	}
+	for i, tool := range c.Prune.Auto.UninstallTools {
+		if strings.TrimSpace(tool) == "" {
+			fail(fmt.Sprintf("prune.auto.uninstall_tools[%d]", i), "must not be empty")
+		}
+	}
+
+	for i, threshold := range c.Reporting.Thresholds {
+		key := fmt.Sprintf("reporting.thresholds[%d]", i)
+		if !containsString(validThresholdMetrics, threshold.Metric) {
+			fail(key+".metric", "unknown metric %q (want %s)", threshold.Metric, strings.Join(validThresholdMetrics, ", "))
+		}
+		if threshold.Max < 0 {
+			fail(key+".max", "must be non-negative")
+		}
+		if threshold.Window < 0 {
+			fail(key+".window", "must be non-negative")
+		}
+	}
+
+	checkInterval("sync.interval", c.Sync.Interval, false)
+	if c.Sync.Remote != "" {
+		if remote, err := url.Parse(c.Sync.Remote); err != nil || (remote.Scheme != "http" && remote.Scheme != "https") || remote.Host == "" {
+			fail("sync.remote", "must be an http or https URL")
+		}
+	} else if c.Sync.Interval > 0 {
+		fail("sync.remote", "must be set when sync.interval is")
+	}
+
+	if c.Audit.OSVURL != "" {
+		if osv, err := url.Parse(c.Audit.OSVURL); err != nil || (osv.Scheme != "http" && osv.Scheme != "https") || osv.Host == "" {
+			fail("audit.osv_url", "must be an http or https URL")
+		}
+	}
+	if c.Audit.CacheTTL < 0 {
+		fail("audit.cache_ttl", "must be non-negative")
+	}
+
+	checkPort("server.port", c.Server.Port)
+	for user, hash := range c.Server.Users {
+		if !ValidTenantName(user) {
+			fail("server.users", "invalid user name %q", user)
+		}
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+			fail("server.users", "%s: want the SHA-256 of a token, as written by diu server token", user)
+		}
+	}
+
+	for i, pattern := range c.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			fail("redaction.patterns", "pattern %d: %v", i, err)
+		}
+	}
+
+	for _, list := range []struct {
+		key   string
+		rules []string
+	}{
+		{"ignore.paths", c.Ignore.Paths},
+		{"ignore.commands", c.Ignore.Commands},
+		{"ignore.users", c.Ignore.Users},
+	} {
+		for i, rule := range list.rules {
+			key := fmt.Sprintf("%s[%d]", list.key, i)
+			pattern, isRegexp := strings.CutPrefix(rule, IgnoreRegexpPrefix)
+			switch {
+			case strings.TrimSpace(pattern) == "":
+				fail(key, "must not be empty")
+			case isRegexp:
+				if _, err := regexp.Compile(pattern); err != nil {
+					fail(key, "%v", err)
+				}
+			}
+		}
+	}
+
+	for _, key := range c.unknownKeys {
+		issues = append(issues, ConfigIssue{Key: key, Message: "unknown key", Warning: true})
+	}
+	return issues
+}
+
+// checkWritablePath reports whether path, or the file path names, can be
+// created or written. Missing directories are checked at their nearest
+// existing ancestor since they are created on demand.
+func checkWritablePath(path string, dir bool) error {
+	if info, err := os.Stat(path); err == nil {
+		if dir && !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", path)
+		}
+		if !dir && info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+	}
+	if !dir {
+		path = filepath.Dir(path)
+	}
+
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			probe, err := os.CreateTemp(path, ".diu-validate-*")
+			if err != nil {
+				return fmt.Errorf("%s is not writable", path)
+			}
+			_ = probe.Close()
+			return os.Remove(probe.Name())
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return nil
+		}
+		path = parent
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeConfigJSON accepts duration strings such as "24h" or "7d" for
+// duration settings, converting them to the nanosecond counts Config
+// decodes, and returns the dotted keys that match no setting
+func normalizeConfigJSON(data []byte) ([]byte, []string, error) {
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	migrateToolRetention(raw)
+
+	var unknown []string
+	raw, err := normalizeConfigValue(raw, reflect.TypeOf(Config{}), "", &unknown)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(unknown)
+
+	data, err = json.Marshal(raw)
+	return data, unknown, err
+}
+
+// migrateToolRetention moves the entries of storage.tool_retention_days, the
+// per-tool retention of older configs, to tools.<tool>.retention_days,
+// leaving any retention_days already set there. An entry for a tool without
+// a tools entry is then reported as an unknown key.
+func migrateToolRetention(raw interface{}) {
+	root, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+	storage, ok := root["storage"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	legacy, ok := storage["tool_retention_days"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if root["tools"] == nil {
+		root["tools"] = make(map[string]interface{})
+	}
+	tools, ok := root["tools"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(storage, "tool_retention_days")
+
+	for tool, days := range legacy {
+		name := NormalizeToolName(tool)
+		if tools[name] == nil {
+			tools[name] = make(map[string]interface{})
+		}
+		settings, ok := tools[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, set := settings["retention_days"]; !set {
+			settings["retention_days"] = days
+		}
+	}
+}
+
+// normalizeConfigValue walks value alongside the Go type it decodes into.
+// Type mismatches are left for json.Unmarshal to report.
+func normalizeConfigValue(value interface{}, t reflect.Type, key string, unknown *[]string) (interface{}, error) {
+	if t == durationType {
+		if text, ok := value.(string); ok {
+			duration, err := ParseDuration(text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid duration %q", key, text)
+			}
+			return int64(duration), nil
+		}
+		return value, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		fields := configFieldTypes(t)
+		for name, child := range object {
+			childKey := joinConfigKey(key, name)
+			fieldType, ok := fields[name]
+			if !ok {
+				*unknown = append(*unknown, childKey)
+				continue
+			}
+			normalized, err := normalizeConfigValue(child, fieldType, childKey, unknown)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = normalized
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for name, child := range object {
+			normalized, err := normalizeConfigValue(child, t.Elem(), joinConfigKey(key, name), unknown)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = normalized
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		for i, child := range items {
+			normalized, err := normalizeConfigValue(child, t.Elem(), fmt.Sprintf("%s[%d]", key, i), unknown)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = normalized
+		}
+	}
+	return value, nil
+}
+
+// configFieldTypes maps the JSON names of t's exported fields to their types
+func configFieldTypes(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, fieldType := range configFieldTypes(field.Type) {
+				fields[embedded] = fieldType
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinConfigKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// packageNameCharacters are the punctuation allowed in package names
+const packageNameCharacters = "@._+-/"
+
+// ValidatePackageName checks that name is safe to pass to a package
+// manager: no flags, paths, or characters outside package names
+func ValidatePackageName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("package name cannot be empty")
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("package name cannot contain leading or trailing whitespace")
+	}
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("package name cannot start with a flag prefix: %s", name)
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return fmt.Errorf("package name cannot be an absolute or incomplete path: %s", name)
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "//") {
+		return fmt.Errorf("package name contains an unsafe path segment: %s", name)
+	}
+
+	hasAlnum := false
+	for _, char := range name {
+		if char >= 'a' && char <= 'z' {
+			hasAlnum = true
+			continue
+		}
+		if char >= 'A' && char <= 'Z' {
+			hasAlnum = true
+			continue
+		}
+		if char >= '0' && char <= '9' {
+			hasAlnum = true
+			continue
+		}
+		if strings.ContainsRune(packageNameCharacters, char) {
+			continue
+		}
+		return fmt.Errorf("package name contains unsupported character %q", char)
+	}
+	if !hasAlnum {
+		return fmt.Errorf("package name must contain a letter or number")
+	}
+	return nil
+}
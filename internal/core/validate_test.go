@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfigForTest(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	return DefaultConfig()
+}
+
+func TestDefaultConfigValidates(t *testing.T) {
+	config := validConfigForTest(t)
+	if issues := config.Validate(); len(issues) != 0 {
+		t.Fatalf("Expected default config to validate, got %v", issues)
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	config := validConfigForTest(t)
+	dataFile := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(dataFile, nil, PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	config.API.Port = 70000
+	config.Daemon.LogLevel = "loud"
+	config.Daemon.DataDir = dataFile
+	config.Storage.Backend = "sqlite"
+	config.Storage.CleanupInterval = 0
+	config.Storage.RetentionDays = -1
+	config.Storage.BackupKeep = "forever"
+	config.Storage.DedupeWindow = -time.Second
+	config.Storage.FlushInterval = -time.Second
+	config.Storage.FlushCount = -1
+	config.Storage.MemoryDays = -1
+	config.Monitoring.Methods = []string{"ebpf"}
+	config.Monitoring.Plugins = []PluginConfig{{Name: "mise", Path: "diu-plugin-mise"}, {Name: "mise", Timeout: -time.Second}}
+	config.Tools.NPM.IgnoreSubcommands = []string{"run", " "}
+	config.Tools.Go.TrackSubcommands = []string{""}
+	config.Ignore.Paths = []string{"/tmp", ""}
+	config.Ignore.Commands = []string{"re:npm (run"}
+	config.Redaction.Patterns = []string{"("}
+	config.Sync.Remote = "devbox:8081"
+	config.Server.Users = map[string]string{"ana": "plaintext-token"}
+	config.Audit.OSVURL = "api.osv.dev"
+	config.Prune.Auto = AutoPruneConfig{Enabled: true, GraceDays: -1, UninstallTools: []string{" "}}
+	config.Reporting.Thresholds = []ThresholdConfig{{Metric: ThresholdMetricInstalls, Max: 3}, {Metric: "downloads", Max: -1, Window: -time.Hour}}
+
+	issues := config.Validate()
+	err := ValidationError(issues)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected *ConfigError, got %v", err)
+	}
+
+	keys := make(map[string]bool)
+	for _, issue := range configErr.Issues {
+		keys[issue.Key] = true
+	}
+	for _, key := range []string{
+		"api.port", "daemon.log_level", "daemon.data_dir", "storage.backend",
+		"storage.cleanup_interval", "storage.retention_days", "storage.backup_keep", "storage.dedupe_window",
+		"storage.flush_interval", "storage.flush_count", "storage.memory_days",
+		"monitoring.methods", "monitoring.plugins[1].name", "monitoring.plugins[1].path", "monitoring.plugins[1].timeout",
+		"tools.npm.ignore_subcommands[1]", "tools.go.track_subcommands[0]", "ignore.paths[1]", "ignore.commands[0]", "redaction.patterns", "sync.remote", "server.users",
+		"audit.osv_url", "prune.auto.unused_days", "prune.auto.grace_days", "prune.auto.uninstall_tools[0]",
+		"reporting.thresholds[1].metric", "reporting.thresholds[1].max", "reporting.thresholds[1].window",
+	} {
+		if !keys[key] {
+			t.Errorf("Expected an issue for %s, got %v", key, configErr.Issues)
+		}
+	}
+	if !strings.Contains(err.Error(), `unsupported backend "sqlite"`) {
+		t.Errorf("Expected backend in error, got %v", err)
+	}
+}
+
+func TestLoadConfigParsesDurationsAndWarnsOnUnknownKeys(t *testing.T) {
+	validConfigForTest(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "tools:\n  homebrew:\n    enabled: false\n  pip:\n    retention_days: 7\nstorage:\n  backup_interval: 12h\n  cleanup_interval: 7d\n  retension_days: 30\nnotifications:\n  webhooks:\n    - url: https://example.com\n      event: [package_installed]\n"
+	if err := os.WriteFile(path, []byte(data), PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Storage.BackupInterval != 12*time.Hour || config.Storage.CleanupInterval != 7*24*time.Hour {
+		t.Errorf("Unexpected intervals: %s, %s", config.Storage.BackupInterval, config.Storage.CleanupInterval)
+	}
+
+	want := []string{"notifications.webhooks[0].event", "storage.retension_days"}
+	if got := config.UnknownKeys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("UnknownKeys() = %v, want %v", got, want)
+	}
+	issues := config.Validate()
+	if ValidationError(issues) != nil || len(issues) != 2 || !issues[0].Warning {
+		t.Errorf("Expected only unknown key warnings, got %v", issues)
+	}
+}
+
+func TestLoadConfigRejectsInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"storage": {"cleanup_interval": "often"}}`), PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), `storage.cleanup_interval: invalid duration "often"`) {
+		t.Fatalf("Expected invalid duration error, got %v", err)
+	}
+}
@@ -0,0 +1,589 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of YAML that config files need: block
+// mappings and sequences, flow sequences and mappings, quoted and plain
+// scalars, literal and folded block scalars, and comments. Anchors, tags,
+// and multiple documents are not supported.
+
+// parseYAML decodes a YAML document into maps, slices, and scalars
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	for i, line := range p.lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") && strings.TrimSpace(line) != "" {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos >= len(p.lines) {
+		return map[string]interface{}{}, nil
+	}
+	indent, _ := p.current()
+	value, err := p.parseBlock(indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content %q", strings.TrimSpace(p.lines[p.pos]))
+	}
+	return value, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("yaml line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// skipBlank moves past empty and comment-only lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && stripYAMLComment(p.lines[p.pos]) == "" {
+		p.pos++
+	}
+}
+
+// current returns the indentation and comment-free content of the line at
+// the cursor
+func (p *yamlParser) current() (int, string) {
+	line := p.lines[p.pos]
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	return indent, stripYAMLComment(line)
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	_, content := p.current()
+	if isYAMLSequenceItem(content) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(content); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(content)
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return result, nil
+		}
+		lineIndent, content := p.current()
+		if lineIndent < indent {
+			return result, nil
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isYAMLSequenceItem(content) {
+			return result, nil
+		}
+		key, rest, ok := splitYAMLKey(content)
+		if !ok {
+			return nil, p.errorf("expected key: value, got %q", content)
+		}
+		if _, exists := result[key]; exists {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+
+		value, err := p.parseValue(indent, rest, true)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	result := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return result, nil
+		}
+		lineIndent, content := p.current()
+		if lineIndent != indent || !isYAMLSequenceItem(content) {
+			if lineIndent > indent {
+				return nil, p.errorf("unexpected indentation")
+			}
+			return result, nil
+		}
+
+		rest := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+		if _, _, ok := splitYAMLKey(rest); ok && !strings.HasPrefix(rest, "[") && !strings.HasPrefix(rest, "{") {
+			// "- key: value" starts a mapping indented to where key begins.
+			itemIndent := indent + len(content) - len(strings.TrimLeft(strings.TrimPrefix(content, "-"), " "))
+			p.lines[p.pos] = strings.Repeat(" ", itemIndent) + rest
+			item, err := p.parseMapping(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(indent, rest, false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+}
+
+// parseValue parses the value after "key:" or "-". An empty value takes a
+// nested block from the following lines; under a mapping key a sequence may
+// sit at the key's own indentation.
+func (p *yamlParser) parseValue(indent int, rest string, sameIndentSequence bool) (interface{}, error) {
+	if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+		return p.parseBlockScalar(indent, rest)
+	}
+	if rest != "" {
+		return parseYAMLScalar(rest)
+	}
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	nextIndent, next := p.current()
+	switch {
+	case nextIndent > indent:
+		return p.parseBlock(nextIndent)
+	case nextIndent == indent && sameIndentSequence && isYAMLSequenceItem(next):
+		return p.parseSequence(indent)
+	}
+	return nil, nil
+}
+
+// parseBlockScalar reads a | (literal) or > (folded) scalar whose lines are
+// indented deeper than indent
+func (p *yamlParser) parseBlockScalar(indent int, header string) (string, error) {
+	folded := strings.HasPrefix(header, ">")
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", p.errorf("unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if lineIndent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = lineIndent
+		}
+		if lineIndent < blockIndent {
+			return "", p.errorf("block scalar line is less indented than the first")
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "":
+				b.WriteString("\n")
+			case lines[i-1] != "":
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case "-":
+	case "+":
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		if len(lines) > 0 {
+			text += "\n"
+		}
+	}
+	return text, nil
+}
+
+func isYAMLSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// splitYAMLKey splits "key: value" or "key:" outside quotes and brackets
+func splitYAMLKey(content string) (string, string, bool) {
+	if content == "" || strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return "", "", false
+	}
+	end := -1
+	if content[0] == '"' || content[0] == '\'' {
+		end = closingQuote(content, 0)
+		if end < 0 {
+			return "", "", false
+		}
+		end++
+		if end >= len(content) || content[end] != ':' {
+			return "", "", false
+		}
+	} else {
+		for i := 0; i < len(content); i++ {
+			if content[i] == ':' && (i == len(content)-1 || content[i+1] == ' ') {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return "", "", false
+		}
+	}
+
+	rawKey := strings.TrimSpace(content[:end])
+	key := rawKey
+	if rawKey != "" && (rawKey[0] == '"' || rawKey[0] == '\'') {
+		unquoted, err := parseYAMLScalar(rawKey)
+		if err != nil {
+			return "", "", false
+		}
+		key = fmt.Sprint(unquoted)
+	}
+	return key, strings.TrimSpace(content[end+1:]), key != ""
+}
+
+// closingQuote returns the index of the quote closing the string that opens
+// at start, or -1
+func closingQuote(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment trims whitespace and a trailing # comment outside quotes
+func stripYAMLComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"', '\'':
+			if end := closingQuote(line, i); end > 0 {
+				i = end
+			}
+		case '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return strings.TrimSpace(line[:i])
+			}
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+func parseYAMLScalar(value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "" || value == "~" || value == "null" || value == "Null" || value == "NULL":
+		return nil, nil
+	case value == "true" || value == "True" || value == "TRUE":
+		return true, nil
+	case value == "false" || value == "False" || value == "FALSE":
+		return false, nil
+	case value[0] == '"':
+		if closingQuote(value, 0) != len(value)-1 {
+			return nil, fmt.Errorf("unterminated string %s", value)
+		}
+		var s string
+		if err := json.Unmarshal([]byte(value), &s); err == nil {
+			return s, nil
+		}
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s: %w", value, err)
+		}
+		return s, nil
+	case value[0] == '\'':
+		if closingQuote(value, 0) != len(value)-1 {
+			return nil, fmt.Errorf("unterminated string %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case value[0] == '[':
+		return parseYAMLFlowSequence(value)
+	case value[0] == '{':
+		return parseYAMLFlowMapping(value)
+	}
+	if number, ok := parseConfigNumber(value); ok {
+		return number, nil
+	}
+	return value, nil
+}
+
+func parseYAMLFlowSequence(value string) ([]interface{}, error) {
+	if !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("unterminated flow sequence %s", value)
+	}
+	items, err := splitFlowItems(value[1 : len(value)-1])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		parsed, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}
+
+func parseYAMLFlowMapping(value string) (map[string]interface{}, error) {
+	if !strings.HasSuffix(value, "}") {
+		return nil, fmt.Errorf("unterminated flow mapping %s", value)
+	}
+	items, err := splitFlowItems(value[1 : len(value)-1])
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		key, rest, ok := splitYAMLKey(item)
+		if !ok {
+			return nil, fmt.Errorf("invalid flow mapping entry %q", item)
+		}
+		parsed, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = parsed
+	}
+	return result, nil
+}
+
+// splitFlowItems splits comma-separated items outside quotes and brackets
+func splitFlowItems(body string) ([]string, error) {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '"', '\'':
+			end := closingQuote(body, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", body)
+			}
+			i = end
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items, nil
+}
+
+// parseConfigNumber parses decimal integers and floats, allowing the _
+// digit separators TOML permits
+func parseConfigNumber(value string) (interface{}, bool) {
+	if strings.HasPrefix(value, "_") || strings.HasSuffix(value, "_") || strings.Contains(value, "__") {
+		return nil, false
+	}
+	clean := strings.ReplaceAll(value, "_", "")
+	if clean == "" {
+		return nil, false
+	}
+	if i, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return i, true
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil && !strings.ContainsAny(clean, "xXpP") {
+		lower := strings.ToLower(clean)
+		if strings.Contains(lower, "inf") || strings.Contains(lower, "nan") {
+			return nil, false
+		}
+		return f, true
+	}
+	return nil, false
+}
+
+// encodeYAML writes a decoded JSON value as block YAML with sorted keys
+func encodeYAML(value interface{}) ([]byte, error) {
+	var b strings.Builder
+	if err := writeYAMLValue(&b, value, 0); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+func writeYAMLValue(b *strings.Builder, value interface{}, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			child := v[key]
+			b.WriteString(pad + yamlKey(key) + ":")
+			if isYAMLBlock(child) {
+				b.WriteString("\n")
+				if err := writeYAMLValue(b, child, indent+2); err != nil {
+					return err
+				}
+				continue
+			}
+			scalar, err := yamlScalar(child)
+			if err != nil {
+				return err
+			}
+			b.WriteString(" " + scalar + "\n")
+		}
+	case []interface{}:
+		for _, item := range v {
+			if isYAMLBlock(item) {
+				if m, ok := item.(map[string]interface{}); ok {
+					// Put the first key on the dash line, the rest under it.
+					var nested strings.Builder
+					if err := writeYAMLValue(&nested, m, indent+2); err != nil {
+						return err
+					}
+					b.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+					continue
+				}
+				b.WriteString(pad + "-\n")
+				if err := writeYAMLValue(b, item, indent+2); err != nil {
+					return err
+				}
+				continue
+			}
+			scalar, err := yamlScalar(item)
+			if err != nil {
+				return err
+			}
+			b.WriteString(pad + "- " + scalar + "\n")
+		}
+	default:
+		scalar, err := yamlScalar(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(pad + scalar + "\n")
+	}
+	return nil
+}
+
+// isYAMLBlock reports whether value is a non-empty map or slice, which is
+// written as an indented block rather than inline
+func isYAMLBlock(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+func yamlScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return quoteConfigString(v)
+	case map[string]interface{}:
+		return "{}", nil
+	case []interface{}:
+		return "[]", nil
+	}
+	return "", fmt.Errorf("unsupported value %T", value)
+}
+
+func yamlKey(key string) string {
+	if isBareConfigKey(key) {
+		return key
+	}
+	quoted, _ := quoteConfigString(key)
+	return quoted
+}
+
+// isBareConfigKey reports whether key can be written unquoted in both YAML
+// and TOML
+func isBareConfigKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, char := range key {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '_' || char == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// quoteConfigString writes s as a double-quoted string valid in YAML and TOML
+func quoteConfigString(s string) (string, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
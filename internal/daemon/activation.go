@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+const (
+	listenPIDEnv     = "LISTEN_PID"
+	listenFDsEnv     = "LISTEN_FDS"
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+
+	// listenFDsStart is the first file descriptor passed by systemd socket
+	// activation (SD_LISTEN_FDS_START).
+	listenFDsStart = 3
+)
+
+// activatedListener returns the unix listener handed over by systemd socket
+// activation, or nil when the daemon was not started through a socket unit.
+func activatedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv(listenPIDEnv))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv(listenFDsEnv))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	// The descriptors belong to this process only; keep them from leaking
+	// into any children.
+	for _, name := range []string{listenPIDEnv, listenFDsEnv, listenFDNamesEnv} {
+		if err := os.Unsetenv(name); err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", name, err)
+		}
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	if file == nil {
+		return nil, fmt.Errorf("invalid activation file descriptor %d", listenFDsStart)
+	}
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use activation socket: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,24 @@
+package daemon
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestActivatedListenerIgnoresOtherProcess(t *testing.T) {
+	t.Setenv(listenPIDEnv, strconv.Itoa(os.Getpid()+1))
+	t.Setenv(listenFDsEnv, "1")
+
+	listener, err := activatedListener()
+	if err != nil {
+		t.Fatalf("activatedListener failed: %v", err)
+	}
+	if listener != nil {
+		listener.Close()
+		t.Fatal("Expected no listener for another process's activation environment")
+	}
+	if os.Getenv(listenFDsEnv) != "1" {
+		t.Error("Expected activation environment to be left untouched")
+	}
+}
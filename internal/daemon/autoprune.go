@@ -0,0 +1,287 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// The actions of the executions recording the auto-prune queue. The
+// uninstall itself is recorded with the uninstall action.
+const (
+	autoPruneQueuedAction    = "auto_prune_queued"
+	autoPruneCancelledAction = "auto_prune_cancelled"
+	autoPruneCommand         = "diu prune --auto"
+)
+
+// autoPruneState is the removal queue, keyed by tool/name, so restarting
+// the daemon neither reports a package twice nor restarts its grace period.
+type autoPruneState struct {
+	Queued map[string]queuedPackage `json:"queued"`
+}
+
+// queuedPackage is a package waiting for removal. QueuedAt starts the grace
+// period; a failed uninstall restarts it so it is retried a grace period
+// later rather than every check.
+type queuedPackage struct {
+	Tool     string    `json:"tool"`
+	Name     string    `json:"name"`
+	LastUsed time.Time `json:"last_used"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// runAutoPrune applies the prune.auto policy at startup and then hourly.
+// The policy is re-read on every check so reloaded settings apply without
+// a restart.
+func (d *Daemon) runAutoPrune() {
+	defer d.wg.Done()
+	check := func(now time.Time) {
+		if d.currentConfig().Prune.Auto.Enabled {
+			d.autoPrune(now)
+		}
+	}
+
+	check(time.Now())
+	ticker := time.NewTicker(core.DefaultReportCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			check(now)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// autoPrune queues the packages unused for prune.auto.unused_days, takes
+// back those used again, and uninstalls those of the tools in
+// prune.auto.uninstall_tools whose grace period has passed. Each step is
+// recorded as an execution of the package's tool and reported to
+// notification targets.
+func (d *Daemon) autoPrune(now time.Time) {
+	config := d.currentConfig()
+	policy := config.Prune.Auto
+	path := filepath.Join(config.Daemon.DataDir, core.AutoPruneStateFileName)
+	state, err := loadAutoPruneState(path)
+	if err != nil {
+		d.logger.Error("Failed to read auto-prune state", "error", err)
+		return
+	}
+
+	packages, err := d.storage.GetPackages("")
+	if err != nil {
+		d.logger.Error("Failed to get packages for auto-prune", "error", err)
+		return
+	}
+
+	unusedFor := time.Duration(policy.UnusedDays) * 24 * time.Hour
+	grace := time.Duration(policy.GraceDays) * 24 * time.Hour
+	tracked := make(map[string]bool, len(packages))
+	changed := false
+	for _, pkg := range packages {
+		key := pkg.Tool + "/" + pkg.Name
+		tracked[key] = true
+		lastUsed := pkg.LastUsed
+		if lastUsed.IsZero() {
+			lastUsed = pkg.InstallDate
+		}
+		queued, isQueued := state.Queued[key]
+
+		switch {
+		case lastUsed.IsZero() || config.Prune.Ignores(pkg.Tool, pkg.Name) || now.Sub(lastUsed) < unusedFor:
+			if isQueued {
+				delete(state.Queued, key)
+				changed = true
+				d.recordAutoPrune(pkg, autoPruneCancelledAction, now)
+				d.logger.Info("Package no longer queued for removal", "tool", pkg.Tool, "package", pkg.Name)
+			}
+		case !isQueued:
+			state.Queued[key] = queuedPackage{Tool: pkg.Tool, Name: pkg.Name, LastUsed: lastUsed, QueuedAt: now}
+			changed = true
+			d.recordAutoPrune(pkg, autoPruneQueuedAction, now)
+			d.logger.Info("Queued unused package for removal", "tool", pkg.Tool, "package", pkg.Name, "last_used", lastUsed)
+			d.notifyAsync(notify.Event{
+				Type:       notify.EventPackageQueued,
+				Time:       now,
+				Tool:       pkg.Tool,
+				Package:    pkg.Name,
+				LastUsed:   lastUsed,
+				UnusedDays: policy.UnusedDays,
+			})
+		case policy.Uninstalls(pkg.Tool) && now.Sub(queued.QueuedAt) >= grace:
+			if d.autoUninstall(pkg, lastUsed, now) {
+				delete(state.Queued, key)
+			} else {
+				queued.QueuedAt = now
+				state.Queued[key] = queued
+			}
+			changed = true
+		}
+	}
+	// Packages removed by hand leave the queue with their package state.
+	for key := range state.Queued {
+		if !tracked[key] {
+			delete(state.Queued, key)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := saveAutoPruneState(path, state); err != nil {
+			d.logger.Error("Failed to save auto-prune state", "error", err)
+		}
+	}
+}
+
+// autoUninstall runs the uninstall command of pkg's monitor, records it as
+// an execution, and forgets the package when it succeeds. It reports
+// whether the package was removed.
+func (d *Daemon) autoUninstall(pkg *core.PackageInfo, lastUsed, now time.Time) bool {
+	monitor, ok := d.monitorRegistry().Get(monitors.MonitorTool(pkg.Tool))
+	if !ok {
+		d.logger.Warn("No monitor to uninstall queued package", "tool", pkg.Tool, "package", pkg.Name)
+		return false
+	}
+	uninstaller, ok := monitor.(monitors.Uninstaller)
+	if !ok {
+		d.logger.Warn("Uninstall is not supported for queued package", "tool", pkg.Tool, "package", pkg.Name)
+		return false
+	}
+	command, err := uninstaller.UninstallCommand(pkg)
+	if err != nil {
+		d.logger.Error("Refusing to uninstall queued package", "tool", pkg.Tool, "package", pkg.Name, "error", err)
+		return false
+	}
+
+	start := time.Now()
+	output, err := d.uninstall(d.ctx, command)
+	record := &core.ExecutionRecord{
+		ID:               core.NewID(),
+		Tool:             pkg.Tool,
+		Command:          strings.Join(command, " "),
+		Args:             command[1:],
+		Timestamp:        now,
+		Duration:         time.Since(start),
+		PackagesAffected: []string{pkg.Name},
+		Output:           output,
+		Metadata: map[string]interface{}{
+			"action":     "uninstall",
+			"auto_prune": true,
+		},
+	}
+	if err != nil {
+		// A failed uninstall names the package in metadata so it does not
+		// count as a use that takes the package off the queue.
+		record.PackagesAffected = nil
+		record.Metadata["package"] = pkg.Name
+		record.ExitCode = 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			record.ExitCode = exitErr.ExitCode()
+		}
+	}
+	d.storeAutoPruneExecution(record)
+	if err != nil {
+		d.logger.Error("Failed to uninstall queued package", "tool", pkg.Tool, "package", pkg.Name, "error", err)
+		return false
+	}
+
+	// The stored execution counts as a use of the package, so its state is
+	// deleted after the execution is stored.
+	if err := d.storage.DeletePackage(pkg.Tool, pkg.Name); err != nil {
+		d.logger.Error("Failed to forget uninstalled package", "tool", pkg.Tool, "package", pkg.Name, "error", err)
+	}
+	d.logger.Info("Uninstalled unused package", "tool", pkg.Tool, "package", pkg.Name, "command", record.Command)
+	d.notifyAsync(notify.Event{
+		Type:       notify.EventPackageRemoved,
+		Time:       now,
+		Tool:       pkg.Tool,
+		Package:    pkg.Name,
+		Command:    record.Command,
+		LastUsed:   lastUsed,
+		UnusedDays: d.currentConfig().Prune.Auto.UnusedDays,
+	})
+	return true
+}
+
+// recordAutoPrune records a change to the removal queue as an execution.
+// The package is named in metadata rather than PackagesAffected, which
+// would count the record as a use of it.
+func (d *Daemon) recordAutoPrune(pkg *core.PackageInfo, action string, now time.Time) {
+	d.storeAutoPruneExecution(&core.ExecutionRecord{
+		ID:        core.NewID(),
+		Tool:      pkg.Tool,
+		Command:   autoPruneCommand,
+		Timestamp: now,
+		Metadata: map[string]interface{}{
+			"action":     action,
+			"package":    pkg.Name,
+			"auto_prune": true,
+		},
+	})
+}
+
+// storeAutoPruneExecution stores record as the daemon's own action: it is
+// not parsed, filtered, or held back while recording is paused.
+func (d *Daemon) storeAutoPruneExecution(record *core.ExecutionRecord) {
+	if err := d.storage.AddExecution(record); err != nil {
+		d.logger.Error("Failed to record auto-prune execution", "tool", record.Tool, "error", err)
+		return
+	}
+	d.stream.publish(record)
+}
+
+// runUninstallCommand runs command, giving up after
+// core.DefaultUninstallTimeout, and returns the end of what it printed
+func runUninstallCommand(ctx context.Context, command []string) (*core.Output, error) {
+	ctx, cancel := context.WithTimeout(ctx, core.DefaultUninstallTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	// #nosec G204 -- the command is a monitor's allowlisted uninstall command and the package name is validated.
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	output := &core.Output{Stdout: stdout.String(), Stderr: stderr.String()}
+	output.Limit(core.MaxOutputLength)
+	return output, err
+}
+
+func loadAutoPruneState(path string) (*autoPruneState, error) {
+	state := &autoPruneState{Queued: make(map[string]queuedPackage)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Queued == nil {
+		state.Queued = make(map[string]queuedPackage)
+	}
+	return state, nil
+}
+
+func saveAutoPruneState(path string, state *autoPruneState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
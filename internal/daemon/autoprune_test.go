@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+)
+
+func TestAutoPruneQueuesThenUninstallsOptedInTools(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Prune.Auto = core.AutoPruneConfig{Enabled: true, UnusedDays: 30, GraceDays: 7, UninstallTools: []string{"npm"}}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.registry.Register(monitors.NewNPMMonitor())
+	var ran [][]string
+	fail := false
+	d.uninstall = func(ctx context.Context, command []string) (*core.Output, error) {
+		ran = append(ran, command)
+		if fail {
+			return &core.Output{Stderr: "npm error code EACCES"}, errors.New("exit status 1")
+		}
+		return &core.Output{}, nil
+	}
+
+	now := time.Now()
+	mock := newMockStorage()
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "npm", Name: "yo", LastUsed: now.Add(-40 * 24 * time.Hour)})
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "npm", Name: "typescript", LastUsed: now.Add(-time.Hour)})
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "homebrew", Name: "jq", LastUsed: now.Add(-40 * 24 * time.Hour)})
+	d.storage = mock
+
+	actions := func() []string {
+		var got []string
+		for _, exec := range mock.executions {
+			action, _ := exec.Metadata["action"].(string)
+			got = append(got, exec.Tool+":"+action)
+		}
+		return got
+	}
+
+	d.autoPrune(now)
+	d.autoPrune(now.Add(time.Hour))
+	if got := actions(); len(got) != 2 || !slices.Contains(got, "npm:"+autoPruneQueuedAction) || !slices.Contains(got, "homebrew:"+autoPruneQueuedAction) {
+		t.Fatalf("Expected yo and jq queued once, got %v", got)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("Expected nothing uninstalled during the grace period, ran %v", ran)
+	}
+
+	fail = true
+	d.autoPrune(now.Add(8 * 24 * time.Hour))
+	if len(ran) != 1 || mock.executions[2].ExitCode != 1 || mock.executions[2].Output.Stderr == "" || len(mock.executions[2].PackagesAffected) != 0 {
+		t.Fatalf("Expected a failed uninstall recorded, ran %v, executions %v", ran, actions())
+	}
+	d.autoPrune(now.Add(9 * 24 * time.Hour))
+	if len(ran) != 1 {
+		t.Fatalf("Expected a failed uninstall retried only after another grace period, ran %v", ran)
+	}
+
+	fail = false
+	d.autoPrune(now.Add(16 * 24 * time.Hour))
+	if len(ran) != 2 || !slices.Equal(ran[1], []string{"npm", "uninstall", "-g", "yo"}) {
+		t.Fatalf("Expected npm uninstall -g yo, ran %v", ran)
+	}
+	last := mock.executions[len(mock.executions)-1]
+	if last.ExitCode != 0 || last.Metadata["action"] != "uninstall" || !slices.Equal(last.PackagesAffected, []string{"yo"}) {
+		t.Fatalf("Expected the uninstall recorded, got %+v", last)
+	}
+	if pkg, _ := mock.GetPackage("npm", "yo"); pkg != nil {
+		t.Fatal("Expected the uninstalled package forgotten")
+	}
+
+	mock.packages["homebrew"][0].LastUsed = now.Add(16 * 24 * time.Hour)
+	d.autoPrune(now.Add(17 * 24 * time.Hour))
+	if got := actions(); got[len(got)-1] != "homebrew:"+autoPruneCancelledAction {
+		t.Fatalf("Expected jq taken off the queue once used, got %v", got)
+	}
+	if len(ran) != 2 {
+		t.Fatalf("Expected homebrew packages never uninstalled without opting in, ran %v", ran)
+	}
+}
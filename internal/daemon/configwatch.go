@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/ignore"
+	"github.com/yowainwright/diu/internal/notify"
+)
+
+// configFileState identifies a version of the config file; a missing file
+// is its own state so creating the file triggers a reload.
+type configFileState struct {
+	exists  bool
+	size    int64
+	modTime int64
+}
+
+func statConfigFile(path string) configFileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileState{}
+	}
+	return configFileState{exists: true, size: info.Size(), modTime: info.ModTime().UnixNano()}
+}
+
+// runConfigWatcher reloads the daemon whenever the config file changes. The
+// file is polled so that editors which replace the file rather than write
+// it in place are noticed the same way on every platform.
+func (d *Daemon) runConfigWatcher(path string, interval time.Duration) {
+	defer d.wg.Done()
+	last := statConfigFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			state := statConfigFile(path)
+			if state == last {
+				continue
+			}
+			last = state
+			d.logger.Info("Config file changed", "path", path)
+			if err := d.Reload(); err != nil {
+				d.logger.Error("Error reloading daemon", "error", err)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Daemon) currentConfig() *core.Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config
+}
+
+func (d *Daemon) currentNotifier() *notify.Notifier {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.notifier
+}
+
+func (d *Daemon) currentIgnoreRules() *ignore.Rules {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.ignoreRules
+}
+
+// liveConfig returns loaded with the settings that only take effect at
+// startup kept at their current values
+func liveConfig(current, loaded *core.Config) *core.Config {
+	live := *loaded
+	live.Daemon = current.Daemon
+	live.API = current.API
+	live.Storage.Backend = current.Storage.Backend
+	live.Storage.JSONFile = current.Storage.JSONFile
+	live.Storage.AutoCleanup = current.Storage.AutoCleanup
+	live.Storage.CleanupInterval = current.Storage.CleanupInterval
+	return &live
+}
+
+// restartRequiredSettings lists the changed settings that liveConfig does
+// not apply
+func restartRequiredSettings(current, loaded *core.Config) []string {
+	settings := changedFields("daemon", current.Daemon, loaded.Daemon)
+	settings = append(settings, changedFields("api", current.API, loaded.API)...)
+	for _, field := range []struct {
+		key     string
+		changed bool
+	}{
+		{"storage.backend", current.Storage.Backend != loaded.Storage.Backend},
+		{"storage.json_file", current.Storage.JSONFile != loaded.Storage.JSONFile},
+		{"storage.auto_cleanup", current.Storage.AutoCleanup != loaded.Storage.AutoCleanup},
+		{"storage.cleanup_interval", current.Storage.CleanupInterval != loaded.Storage.CleanupInterval},
+	} {
+		if field.changed {
+			settings = append(settings, field.key)
+		}
+	}
+	return settings
+}
+
+// changedFields names the fields of two structs of the same type that
+// differ, using their JSON keys under prefix
+func changedFields(prefix string, current, loaded interface{}) []string {
+	currentValue := reflect.ValueOf(current)
+	loadedValue := reflect.ValueOf(loaded)
+	var changed []string
+	for i := 0; i < currentValue.NumField(); i++ {
+		if reflect.DeepEqual(currentValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(currentValue.Type().Field(i).Tag.Get("json"), ",")
+		changed = append(changed, prefix+"."+name)
+	}
+	return changed
+}
@@ -1,31 +1,59 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+	"github.com/yowainwright/diu/internal/gitinfo"
+	"github.com/yowainwright/diu/internal/ignore"
+	"github.com/yowainwright/diu/internal/logging"
 	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/project"
+	"github.com/yowainwright/diu/internal/report"
 	"github.com/yowainwright/diu/internal/storage"
 )
 
 const (
 	maxExecutionRecordBodyBytes = 1 << 20
-	maxRecordedCommandLength    = 4096
+	maxExecutionBatchBodyBytes  = 32 << 20
+	maxExecutionBatchRecords    = 10000
+
+	batchStatusAccepted  = "accepted"
+	batchStatusDuplicate = "duplicate"
+	batchStatusRejected  = "rejected"
+	batchStatusSkipped   = "skipped"
+
+	statsGroupByTool    = "tool"
+	statsGroupByDay     = "day"
+	statsGroupByPackage = "package"
+	statsGroupByHour    = "hour"
+	statsGroupByWeekday = "weekday"
+
+	defaultProjectTopPackages = 10
+
+	packageSortUsageCount = "usage_count"
+	packageSortLastUsed   = "last_used"
+	sortOrderAsc          = "asc"
+	sortOrderDesc         = "desc"
 )
 
 type Daemon struct {
@@ -33,54 +61,87 @@ type Daemon struct {
 	storage        storage.Storage
 	registry       *monitors.MonitorRegistry
 	eventChan      chan *core.ExecutionRecord
+	spill          *eventSpill
 	httpServer     *http.Server
 	socketListener net.Listener
-	ctx            context.Context
-	cancel         context.CancelFunc
-	wg             sync.WaitGroup
-	startTime      time.Time
-	stopOnce       sync.Once
-	stopped        atomic.Bool
+	// socketActivated is set when the socket was handed over by systemd,
+	// which then owns the socket file.
+	socketActivated bool
+	// pidLock is held from NewDaemon until Stop; see acquirePIDLock.
+	pidLock       *os.File
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+	startTime     time.Time
+	stopOnce      sync.Once
+	stopped       atomic.Bool
+	droppedEvents atomic.Int64
+	registryMu    sync.RWMutex
+	reloadMu      sync.Mutex
+	// configMu guards config, notifier, and ignoreRules, which Reload
+	// replaces.
+	configMu sync.RWMutex
+	// configFileMu serializes the daemon's own edits of the config file.
+	configFileMu sync.Mutex
+	loadConfig   func() (*core.Config, error)
+	sendReport   func(core.SMTPConfig, *report.Report) error
+	uninstall    func(context.Context, []string) (*core.Output, error)
+	notifier     *notify.Notifier
+	ignoreRules  *ignore.Rules
+	stream       *executionBroadcaster
+	logger       *slog.Logger
+	logCloser    io.Closer
 }
 
 func NewDaemon(config *core.Config) (*Daemon, error) {
-	store, err := storage.NewJSONStorage(config)
+	issues := config.Validate()
+	if err := core.ValidationError(issues); err != nil {
+		return nil, err
+	}
+
+	pidLock, err := acquirePIDLock(config.Daemon.PIDFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		var running *AlreadyRunningError
+		if errors.As(err, &running) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
 	}
 
-	registry := monitors.NewMonitorRegistry()
+	logger, logCloser, err := logging.New(config.Daemon)
+	if err != nil {
+		abandonPIDLock(config.Daemon.PIDFile, pidLock)
+		return nil, fmt.Errorf("failed to initialize logging: %w", err)
+	}
+	for _, issue := range issues {
+		logger.Warn("Config warning", "key", issue.Key, "message", issue.Message)
+	}
 
-	for _, tool := range config.Monitoring.EnabledTools {
-		tool = core.NormalizeToolName(tool)
-		var monitor monitors.Monitor
-		switch tool {
-		case core.ToolHomebrew:
-			monitor = monitors.NewHomebrewMonitor()
-		case core.ToolNPM:
-			monitor = monitors.NewNPMMonitor()
-		case core.ToolPNPM:
-			monitor = monitors.NewPNPMMonitor()
-		case core.ToolBun:
-			monitor = monitors.NewBunMonitor()
-		case core.ToolGo:
-			monitor = monitors.NewGoMonitor()
-		case core.ToolPip:
-			monitor = monitors.NewPipMonitor()
-		case core.ToolUV:
-			monitor = monitors.NewUVMonitor()
-		case core.ToolPoetry:
-			monitor = monitors.NewPoetryMonitor()
-		default:
-			log.Printf("Unknown tool: %s", tool)
-			continue
-		}
+	store, recovery, err := storage.OpenWithRecovery(config)
+	if err != nil {
+		_ = logCloser.Close()
+		abandonPIDLock(config.Daemon.PIDFile, pidLock)
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	if recovery != nil {
+		logger.Error("Recovered corrupt storage from backup",
+			"file", recovery.Path,
+			"error", recovery.Err,
+			"moved_to", recovery.CorruptPath,
+			"backup", recovery.Backup,
+			"recovered_from_shards", recovery.Recovered)
+	}
 
-		if err := monitor.Initialize(config); err != nil {
-			log.Printf("Failed to initialize %s monitor: %v", tool, err)
-			continue
-		}
-		registry.Register(monitor)
+	notifier, err := notify.New(config.Notifications)
+	if err != nil {
+		logger.Warn("Notifications disabled", "error", err)
+	}
+	ignoreRules, err := ignore.New(config.Ignore)
+	if err != nil {
+		_ = store.Close()
+		_ = logCloser.Close()
+		abandonPIDLock(config.Daemon.PIDFile, pidLock)
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -88,38 +149,94 @@ func NewDaemon(config *core.Config) (*Daemon, error) {
 	d := &Daemon{
 		config:    config,
 		storage:   store,
-		registry:  registry,
+		logger:    logger,
+		logCloser: logCloser,
+		pidLock:   pidLock,
+		registry:  newMonitorRegistry(config, logger),
 		eventChan: make(chan *core.ExecutionRecord, core.DefaultEventBuffer),
+		spill:     newEventSpill(filepath.Join(config.Daemon.DataDir, core.EventSpillFileName), core.DefaultEventSpillBytes),
 		ctx:       ctx,
 		cancel:    cancel,
 		startTime: time.Now(),
+		loadConfig: func() (*core.Config, error) {
+			return core.LoadConfig(config.Path())
+		},
+		sendReport:  report.SendEmail,
+		uninstall:   runUninstallCommand,
+		notifier:    notifier,
+		ignoreRules: ignoreRules,
+		stream:      newExecutionBroadcaster(),
+	}
+	if recovery != nil {
+		d.notifyAsync(notify.Event{
+			Type:    notify.EventStorageRecovered,
+			Time:    time.Now(),
+			Summary: recovery.String(),
+		})
 	}
 
 	return d, nil
 }
 
-func (d *Daemon) Start() error {
-	log.Printf("Starting DIU daemon v%s", core.Version)
+func newMonitorRegistry(config *core.Config, logger *slog.Logger) *monitors.MonitorRegistry {
+	registry := monitors.NewMonitorRegistry()
 
-	if err := d.writePIDFile(); err != nil {
-		return fmt.Errorf("failed to write PID file: %w", err)
+	for _, tool := range config.TrackedTools() {
+		monitor, err := monitors.ForConfig(config, tool)
+		if err != nil {
+			logger.Warn("Unknown tool", "tool", tool)
+			continue
+		}
+
+		if err := monitor.Initialize(config); err != nil {
+			logger.Warn("Failed to initialize monitor", "tool", tool, "error", err)
+			registry.RecordError(monitor.Name(), err)
+			continue
+		}
+		registry.Register(monitor)
 	}
 
+	return registry
+}
+
+func (d *Daemon) Start() error {
+	d.logger.Info("Starting DIU daemon", "version", core.Version)
+
 	d.wg.Add(1)
 	go d.processEvents()
 
 	d.wg.Add(1)
 	go d.runPeriodicCleanup()
 
-	if err := d.registry.StartAll(d.ctx, d.eventChan); err != nil {
+	d.wg.Add(1)
+	go d.runReportScheduler()
+
+	d.wg.Add(1)
+	go d.runUnusedPackageCheck()
+
+	d.wg.Add(1)
+	go d.runAutoPrune()
+
+	d.wg.Add(1)
+	go d.runThresholdCheck()
+
+	d.wg.Add(1)
+	go d.runScheduledSync()
+
+	if path := d.currentConfig().Path(); path != "" {
+		d.wg.Add(1)
+		go d.runConfigWatcher(path, core.DefaultConfigPollInterval)
+	}
+
+	if err := d.monitorRegistry().StartAll(d.ctx, d.eventChan); err != nil {
 		return fmt.Errorf("failed to start monitors: %w", err)
 	}
 
 	if err := d.startSocketListener(); err != nil {
-		log.Printf("Failed to start socket listener: %v", err)
+		d.logger.Error("Failed to start socket listener", "error", err)
 	}
 
-	if d.config.API.Enabled {
+	if d.currentConfig().API.Enabled {
 		if err := d.startHTTPServer(); err != nil {
 			return fmt.Errorf("failed to start HTTP server: %w", err)
 		}
@@ -133,47 +250,127 @@ func (d *Daemon) Start() error {
 func (d *Daemon) Stop() error {
 	var stopErr error
 	d.stopOnce.Do(func() {
-		log.Println("Stopping DIU daemon...")
+		d.logger.Info("Stopping DIU daemon")
 		d.stopped.Store(true)
 
 		d.cancel()
 
-		if err := d.registry.StopAll(); err != nil {
-			log.Printf("Error stopping monitors: %v", err)
+		if err := d.monitorRegistry().StopAll(); err != nil {
+			d.logger.Error("Error stopping monitors", "error", err)
 		}
 
 		if d.httpServer != nil {
 			ctx, cancel := context.WithTimeout(context.Background(), core.DefaultShutdownTimeout)
 			defer cancel()
 			if err := d.httpServer.Shutdown(ctx); err != nil {
-				log.Printf("Error shutting down HTTP server: %v", err)
+				d.logger.Error("Error shutting down HTTP server", "error", err)
 			}
 		}
 
 		if d.socketListener != nil {
 			if err := d.socketListener.Close(); err != nil {
-				log.Printf("Error closing socket listener: %v", err)
+				d.logger.Error("Error closing socket listener", "error", err)
 			}
 		}
 
 		d.wg.Wait()
 
 		if err := d.storage.Close(); err != nil {
-			log.Printf("Error closing storage: %v", err)
+			d.logger.Error("Error closing storage", "error", err)
 		}
 
-		if err := os.Remove(d.config.Daemon.PIDFile); err != nil && !os.IsNotExist(err) {
-			log.Printf("Error removing PID file: %v", err)
-		}
-		if err := os.Remove(d.config.Daemon.SocketPath); err != nil && !os.IsNotExist(err) {
-			log.Printf("Error removing socket file: %v", err)
+		d.releasePIDLock()
+		if !d.socketActivated {
+			if err := removeLocalSocket(d.currentConfig().Daemon.SocketPath); err != nil {
+				d.logger.Error("Error removing socket file", "error", err)
+			}
 		}
 
-		log.Println("DIU daemon stopped")
+		d.logger.Info("DIU daemon stopped")
+		if err := d.logCloser.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log file: %v\n", err)
+		}
 	})
 	return stopErr
 }
 
+// Reload re-reads the config file, rebuilds the enabled monitors, applies
+// retention, reporting, and notification settings, and reopens the log file.
+// The socket listener, HTTP server, and queued events are left untouched;
+// changes to daemon, API, and storage location settings are logged as
+// needing a restart.
+func (d *Daemon) Reload() error {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	if d.IsStopped() {
+		return fmt.Errorf("daemon is stopping")
+	}
+
+	config, err := d.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := core.ValidationError(config.Validate()); err != nil {
+		return err
+	}
+	ignoreRules, err := ignore.New(config.Ignore)
+	if err != nil {
+		return err
+	}
+
+	current := d.currentConfig()
+	live := liveConfig(current, config)
+
+	// Start the new monitors before stopping the running ones, so a
+	// failed start leaves the daemon monitoring with the old config.
+	registry := newMonitorRegistry(live, d.logger)
+	if err := registry.StartAll(d.ctx, d.eventChan); err != nil {
+		if stopErr := registry.StopAll(); stopErr != nil {
+			d.logger.Error("Error stopping monitors", "error", stopErr)
+		}
+		return fmt.Errorf("failed to start monitors: %w", err)
+	}
+	if err := d.monitorRegistry().StopAll(); err != nil {
+		d.logger.Error("Error stopping monitors", "error", err)
+	}
+
+	d.registryMu.Lock()
+	d.registry = registry
+	d.registryMu.Unlock()
+
+	notifier, err := notify.New(live.Notifications)
+	if err != nil {
+		d.logger.Warn("Notifications disabled", "error", err)
+	}
+	d.configMu.Lock()
+	d.config = live
+	d.notifier = notifier
+	d.ignoreRules = ignoreRules
+	d.configMu.Unlock()
+	if configurable, ok := d.storage.(interface{ SetConfig(*core.Config) }); ok {
+		configurable.SetConfig(live)
+	}
+
+	if reopener, ok := d.logCloser.(interface{ Reopen() error }); ok {
+		if err := reopener.Reopen(); err != nil {
+			d.logger.Error("Failed to reopen log file", "error", err)
+		}
+	}
+
+	if restart := restartRequiredSettings(current, config); len(restart) > 0 {
+		d.logger.Warn("Config changes require a restart", "settings", strings.Join(restart, ", "))
+	}
+	d.logger.Info("Configuration reloaded", "monitors_active", len(registry.GetAll()))
+	return nil
+}
+
+func (d *Daemon) monitorRegistry() *monitors.MonitorRegistry {
+	d.registryMu.RLock()
+	defer d.registryMu.RUnlock()
+	return d.registry
+}
+
 func (d *Daemon) Wait() {
 	d.wg.Wait()
 }
@@ -185,6 +382,8 @@ func (d *Daemon) IsStopped() bool {
 func (d *Daemon) processEvents() {
 	defer d.wg.Done()
 
+	// Events spilled before the last stop are stored first.
+	d.replaySpill()
 	for {
 		select {
 		case event, ok := <-d.eventChan:
@@ -192,6 +391,14 @@ func (d *Daemon) processEvents() {
 				return
 			}
 			d.storeExecution(event)
+			if len(d.eventChan) == 0 {
+				d.replaySpill()
+			}
+
+		case <-d.spill.ready:
+			if len(d.eventChan) == 0 {
+				d.replaySpill()
+			}
 
 		case <-d.ctx.Done():
 			d.drainQueuedEvents()
@@ -200,6 +407,43 @@ func (d *Daemon) processEvents() {
 	}
 }
 
+// replaySpill stores the events spilled to disk while the queue was full.
+// Events still spilled when the daemon stops are replayed when it starts.
+func (d *Daemon) replaySpill() {
+	if d.spill.waiting.Load() == 0 {
+		return
+	}
+	if err := d.spill.replay(d.storeExecution); err != nil {
+		d.logger.Error("Failed to replay spilled events", "error", err)
+	}
+}
+
+// enqueue hands record to processEvents, spilling it to disk when the
+// queue is full. It reports whether the record was accepted.
+func (d *Daemon) enqueue(record *core.ExecutionRecord) bool {
+	select {
+	case <-d.ctx.Done():
+		d.droppedEvents.Add(1)
+		d.logger.Warn("Daemon stopping, dropping event")
+		return false
+	case d.eventChan <- record:
+		return true
+	default:
+	}
+
+	// Storage skips an ID it has seen, so an event replayed twice after
+	// a crash is stored once.
+	if record.ID == "" {
+		record.ID = core.NewID()
+	}
+	if err := d.spill.add(record); err != nil {
+		d.droppedEvents.Add(1)
+		d.logger.Warn("Event queue full, dropping event", "error", err)
+		return false
+	}
+	return true
+}
+
 func (d *Daemon) drainQueuedEvents() {
 	for {
 		select {
@@ -215,30 +459,144 @@ func (d *Daemon) drainQueuedEvents() {
 }
 
 func (d *Daemon) storeExecution(event *core.ExecutionRecord) {
+	if !d.admitExecution(event) {
+		return
+	}
+	d.recordVersionChanges(event)
+	newPackages := d.unseenPackages(event)
+	if err := d.storage.AddExecution(event); errors.Is(err, storage.ErrDuplicateExecution) {
+		d.logger.Debug("Dropping duplicate execution", "id", event.ID)
+		return
+	} else if err != nil {
+		d.logger.Error("Failed to store execution", "tool", event.Tool, "error", err)
+		return
+	}
+	d.executionStored(event, newPackages)
+}
+
+// recordVersionChanges keeps the versions an install or upgrade left
+// installed in event, before it is stored over the previous ones
+func (d *Daemon) recordVersionChanges(event *core.ExecutionRecord) {
+	if monitor, ok := d.monitorRegistry().Get(event.Tool); ok {
+		monitors.RecordVersionChanges(monitor, event, storage.KnownVersions(d.storage, event.Tool))
+	}
+}
+
+// executionStored publishes event, once stored, to stream clients and
+// execution_ingested webhooks, and announces newPackages, the packages it
+// installed that were not tracked before
+func (d *Daemon) executionStored(event *core.ExecutionRecord, newPackages []string) {
+	d.stream.publish(event)
+	if d.currentNotifier().Subscribed(notify.EventExecutionIngested) {
+		record := *event
+		d.notifyAsync(notify.Event{
+			Type:      notify.EventExecutionIngested,
+			Time:      event.Timestamp,
+			Tool:      event.Tool,
+			Command:   event.Command,
+			Execution: &record,
+		})
+	}
+	global, ok := event.Metadata["global"].(bool)
+	local := (ok && !global) || event.Metadata["ephemeral"] == true
+	for _, name := range newPackages {
+		d.notifyAsync(notify.Event{
+			Type:    notify.EventPackageInstalled,
+			Time:    event.Timestamp,
+			Tool:    event.Tool,
+			Package: name,
+			Command: event.Command,
+			Local:   local,
+		})
+	}
+}
+
+// admitExecution enriches event and reports whether it should be stored.
+// Every ingest path calls it, so an execution dropped when sent to the
+// socket is dropped from a batch too.
+func (d *Daemon) admitExecution(event *core.ExecutionRecord) bool {
+	if d.pauseState().Paused {
+		d.logger.Debug("Dropping execution while recording is paused", "tool", event.Tool)
+		return false
+	}
+	if d.currentConfig().ToolDisabled(event.Tool) {
+		d.logger.Debug("Dropping execution of disabled tool", "tool", event.Tool)
+		return false
+	}
 	d.enrichExecution(event)
-	if err := d.storage.AddExecution(event); err != nil {
-		log.Printf("Failed to store execution: %v", err)
+	d.monitorRegistry().RecordEvent(event.Tool, event.Timestamp)
+	if d.currentIgnoreRules().Match(event) || d.currentConfig().IgnoreExecution(event) {
+		d.logger.Debug("Dropping ignored execution", "tool", event.Tool)
+		return false
 	}
+	return true
+}
+
+// unseenPackages returns the packages affected by record that are not yet
+// tracked, when a notification is subscribed to new packages.
+func (d *Daemon) unseenPackages(record *core.ExecutionRecord) []string {
+	if !d.currentNotifier().Subscribed(notify.EventPackageInstalled) {
+		return nil
+	}
+	var unseen []string
+	for _, name := range record.PackagesAffected {
+		if pkg, err := d.storage.GetPackage(record.Tool, name); err != nil || pkg == nil {
+			unseen = append(unseen, name)
+		}
+	}
+	return unseen
+}
+
+// notifyAsync posts event without holding up event processing.
+func (d *Daemon) notifyAsync(event notify.Event) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.currentNotifier().Notify(d.ctx, event); err != nil {
+			d.logger.Error("Failed to send notification", "event", event.Type, "error", err)
+		}
+	}()
 }
 
 func (d *Daemon) enrichExecution(record *core.ExecutionRecord) {
 	// Normalize tool name before looking up monitor
 	record.Tool = core.NormalizeToolName(record.Tool)
+	if record.ID == "" {
+		record.ID = core.NewID()
+	}
 	if record.Timestamp.IsZero() {
 		record.Timestamp = time.Now()
 	}
+	gitinfo.Annotate(record)
+	project.Annotate(record)
 
-	monitor, ok := d.registry.Get(record.Tool)
+	registry := d.monitorRegistry()
+	monitor, ok := registry.Get(record.Tool)
 	if !ok {
 		return
 	}
-	monitors.EnrichExecutionRecord(monitor, record)
+	if err := monitors.EnrichExecutionRecord(monitor, record); err != nil {
+		d.logger.Debug("Failed to parse execution", "tool", record.Tool, "error", err)
+		registry.RecordError(record.Tool, err)
+	}
 }
 
+// runPeriodicCleanup applies the storage retention policies at startup and
+// then every storage.cleanup_interval, the same as running diu cleanup.
 func (d *Daemon) runPeriodicCleanup() {
 	defer d.wg.Done()
+	if !d.currentConfig().Storage.AutoCleanup {
+		d.logger.Info("Scheduled retention cleanup disabled")
+		return
+	}
+
+	interval := d.currentConfig().Storage.CleanupInterval
+	if interval <= 0 {
+		interval = core.DefaultCleanupInterval
+	}
+
 	d.pruneOldRecords()
-	ticker := time.NewTicker(24 * time.Hour)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	for {
 		select {
@@ -251,31 +609,68 @@ func (d *Daemon) runPeriodicCleanup() {
 }
 
 func (d *Daemon) pruneOldRecords() {
+	before, err := d.storage.GetStatistics()
+	if err != nil {
+		d.logger.Error("Failed to read statistics before cleanup", "error", err)
+		return
+	}
 	if err := d.storage.Cleanup(time.Time{}); err != nil {
-		log.Printf("Failed to prune old records: %v", err)
+		d.logger.Error("Failed to prune old records", "error", err)
+		return
 	}
+	after, err := d.storage.GetStatistics()
+	if err != nil {
+		d.logger.Error("Failed to read statistics after cleanup", "error", err)
+		return
+	}
+	d.logger.Info("Applied retention policies",
+		"removed", before.TotalExecutions-after.TotalExecutions,
+		"remaining", after.TotalExecutions)
 }
 
-func (d *Daemon) startSocketListener() error {
-	socketPath := d.config.Daemon.SocketPath
-
-	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove stale socket: %w", err)
+// openSocketListener uses the socket passed by systemd when the daemon was
+// socket-activated and otherwise creates the unix socket itself.
+func (d *Daemon) openSocketListener() (net.Listener, error) {
+	listener, err := activatedListener()
+	if err != nil {
+		return nil, err
 	}
-	if err := os.MkdirAll(filepath.Dir(socketPath), core.OwnerDirectoryMode); err != nil {
-		return fmt.Errorf("failed to create socket directory: %w", err)
+	if listener != nil {
+		d.socketActivated = true
+		d.logger.Info("Using systemd activation socket")
+		return listener, nil
 	}
 
-	listener, err := net.Listen("unix", socketPath)
+	return listenLocal(d.currentConfig().Daemon.SocketPath)
+}
+
+// startSocketListener accepts wrapper connections and hands them to a fixed
+// pool of workers. When every worker is busy the accept loop waits, so a
+// burst of wrappers queues in the listen backlog instead of starting a
+// goroutine per connection.
+func (d *Daemon) startSocketListener() error {
+	listener, err := d.openSocketListener()
 	if err != nil {
-		return fmt.Errorf("failed to create socket: %w", err)
+		return err
 	}
 
 	d.socketListener = listener
 
+	conns := make(chan net.Conn)
+	for i := 0; i < core.DefaultSocketWorkers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for conn := range conns {
+				d.handleSocketConnection(conn)
+			}
+		}()
+	}
+
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
+		defer close(conns)
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
@@ -283,53 +678,55 @@ func (d *Daemon) startSocketListener() error {
 				case <-d.ctx.Done():
 					return
 				default:
-					log.Printf("Socket accept error: %v", err)
+					d.logger.Warn("Socket accept error", "error", err)
 					continue
 				}
 			}
 
-			d.wg.Add(1)
-			go func() {
-				defer d.wg.Done()
-				d.handleSocketConnection(conn)
-			}()
+			select {
+			case conns <- conn:
+			case <-d.ctx.Done():
+				if err := conn.Close(); err != nil {
+					d.logger.Warn("Error closing socket connection", "error", err)
+				}
+				return
+			}
 		}
 	}()
 
 	return nil
 }
 
+// handleSocketConnection reads execution records from conn until the client
+// closes it. Each record must arrive within the idle timeout, so a client
+// that stops writing cannot hold a worker.
 func (d *Daemon) handleSocketConnection(conn net.Conn) {
+	// Stopping the daemon closes the connection to end a pending read.
+	stopWatch := context.AfterFunc(d.ctx, func() { _ = conn.Close() })
 	defer func() {
+		if !stopWatch() {
+			return
+		}
 		if err := conn.Close(); err != nil {
-			log.Printf("Error closing socket connection: %v", err)
+			d.logger.Warn("Error closing socket connection", "error", err)
 		}
 	}()
 
-	if err := conn.SetReadDeadline(time.Now().Add(core.DefaultSocketReadTimeout)); err != nil {
-		log.Printf("Failed to set socket read deadline: %v", err)
-	}
-
 	decoder := json.NewDecoder(conn)
-	var record core.ExecutionRecord
-	if err := decoder.Decode(&record); err != nil {
-		log.Printf("Failed to decode execution record: %v", err)
-		return
-	}
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(core.DefaultSocketIdleTimeout)); err != nil {
+			d.logger.Warn("Failed to set socket read deadline", "error", err)
+		}
 
-	select {
-	case <-d.ctx.Done():
-		log.Printf("Daemon stopping, dropping socket event")
-		return
-	default:
-	}
+		var record core.ExecutionRecord
+		if err := decoder.Decode(&record); err != nil {
+			if err != io.EOF && d.ctx.Err() == nil {
+				d.logger.Warn("Failed to decode execution record", "error", err)
+			}
+			return
+		}
 
-	select {
-	case d.eventChan <- &record:
-	case <-d.ctx.Done():
-		log.Printf("Daemon stopping, dropping socket event")
-	case <-time.After(time.Second):
-		log.Printf("Event channel full, dropping event")
+		d.enqueue(&record)
 	}
 }
 
@@ -337,11 +734,27 @@ func (d *Daemon) startHTTPServer() error {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/api/v1/executions", d.handleExecutions)
+	mux.HandleFunc("/api/v1/executions/batch", d.handleExecutionBatch)
+	mux.HandleFunc("/api/v1/executions/stream", d.handleExecutionStream)
 	mux.HandleFunc("/api/v1/packages", d.handlePackages)
 	mux.HandleFunc("/api/v1/stats", d.handleStats)
+	mux.HandleFunc("/api/v1/projects", d.handleProjects)
+	mux.HandleFunc("/api/v1/series", d.handleSeries)
+	mux.HandleFunc(monitorsPath, d.handleMonitors)
+	mux.HandleFunc(monitorsPath+"/", d.handleMonitor)
+	mux.HandleFunc(grafanaPath, d.handleGrafana)
+	mux.HandleFunc(grafanaPath+"/search", d.handleGrafanaSearch)
+	mux.HandleFunc(grafanaPath+"/query", d.handleGrafanaQuery)
+	mux.HandleFunc(grafanaPath+"/annotations", d.handleGrafanaAnnotations)
+	mux.HandleFunc(fleet.Path, d.handleSync)
 	mux.HandleFunc("/api/v1/health", d.handleHealth)
+	mux.HandleFunc(summaryPath, d.handleSummary)
+	mux.HandleFunc("/api/v1/openapi.json", d.handleOpenAPI)
+	mux.HandleFunc("/api/v1/reload", d.handleReload)
+	mux.HandleFunc(pausePath, d.handlePause)
+	mux.HandleFunc(resumePath, d.handleResume)
 
-	addr := fmt.Sprintf("%s:%d", d.config.API.Host, d.config.API.Port)
+	addr := fmt.Sprintf("%s:%d", d.currentConfig().API.Host, d.currentConfig().API.Port)
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -361,9 +774,9 @@ func (d *Daemon) startHTTPServer() error {
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
-		log.Printf("HTTP API server listening on %s", actualAddr)
+		d.logger.Info("HTTP API server listening", "addr", actualAddr)
 		if err := d.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
+			d.logger.Error("HTTP server error", "error", err)
 		}
 	}()
 
@@ -374,8 +787,12 @@ func (d *Daemon) handleExecutions(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		opts := storage.QueryOptions{
-			Tool:    core.NormalizeToolName(r.URL.Query().Get("tool")),
-			Package: r.URL.Query().Get("package"),
+			Tool:       core.NormalizeToolName(r.URL.Query().Get("tool")),
+			Package:    r.URL.Query().Get("package"),
+			WorkingDir: r.URL.Query().Get("dir"),
+			Project:    r.URL.Query().Get("project"),
+			SessionID:  r.URL.Query().Get("session"),
+			Host:       r.URL.Query().Get("host"),
 		}
 
 		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -387,6 +804,46 @@ func (d *Daemon) handleExecutions(w http.ResponseWriter, r *http.Request) {
 			opts.Limit = limit
 		}
 
+		if exitCodeStr := r.URL.Query().Get("exit_code"); exitCodeStr != "" {
+			exitCode, err := strconv.Atoi(exitCodeStr)
+			if err != nil {
+				http.Error(w, "invalid exit_code", http.StatusBadRequest)
+				return
+			}
+			opts.ExitCode = &exitCode
+		}
+
+		if failedStr := r.URL.Query().Get("failed"); failedStr != "" {
+			failed, err := strconv.ParseBool(failedStr)
+			if err != nil {
+				http.Error(w, "invalid failed: must be true or false", http.StatusBadRequest)
+				return
+			}
+			opts.FailedOnly = failed
+		}
+
+		if match := r.URL.Query().Get("match"); match != "" {
+			pattern, err := storage.CompileCommandMatch(match)
+			if err != nil {
+				http.Error(w, "invalid match: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts.CommandPattern = pattern
+		}
+
+		if glob := r.URL.Query().Get("glob"); glob != "" {
+			if opts.CommandPattern != nil {
+				http.Error(w, "match and glob cannot be combined", http.StatusBadRequest)
+				return
+			}
+			pattern, err := storage.CompileCommandGlob(glob)
+			if err != nil {
+				http.Error(w, "invalid glob: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts.CommandPattern = pattern
+		}
+
 		executions, err := d.storage.GetExecutions(opts)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -395,7 +852,7 @@ func (d *Daemon) handleExecutions(w http.ResponseWriter, r *http.Request) {
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(executions); err != nil {
-			log.Printf("Failed to encode executions response: %v", err)
+			d.logger.Warn("Failed to encode executions response", "error", err)
 		}
 
 	case http.MethodPost:
@@ -405,14 +862,11 @@ func (d *Daemon) handleExecutions(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		select {
-		case d.eventChan <- record:
-			w.WriteHeader(http.StatusAccepted)
-		case <-d.ctx.Done():
-			http.Error(w, "Daemon stopping", http.StatusServiceUnavailable)
-		default:
+		if !d.enqueue(record) {
 			http.Error(w, "Event queue full", http.StatusServiceUnavailable)
+			return
 		}
+		w.WriteHeader(http.StatusAccepted)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -430,31 +884,153 @@ func decodeExecutionRecordRequest(w http.ResponseWriter, r *http.Request) (*core
 	if err := decoder.Decode(&struct{}{}); err != io.EOF {
 		return nil, fmt.Errorf("request body must contain a single JSON object")
 	}
-	if err := validateExecutionRecord(record); err != nil {
+	if err := record.Validate(); err != nil {
 		return nil, err
 	}
 	return &record, nil
 }
 
-func validateExecutionRecord(record core.ExecutionRecord) error {
-	if strings.TrimSpace(record.Tool) == "" {
-		return fmt.Errorf("tool is required")
+type batchRecordResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Accepted   int                 `json:"accepted"`
+	Duplicates int                 `json:"duplicates"`
+	Rejected   int                 `json:"rejected"`
+	Skipped    int                 `json:"skipped"`
+	Results    []batchRecordResult `json:"results"`
+}
+
+func (d *Daemon) handleExecutionBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	if strings.TrimSpace(record.Command) == "" {
-		return fmt.Errorf("command is required")
+
+	select {
+	case <-d.ctx.Done():
+		http.Error(w, "Daemon stopping", http.StatusServiceUnavailable)
+		return
+	default:
 	}
-	if len(record.Command) > maxRecordedCommandLength {
-		return fmt.Errorf("command exceeds %d bytes", maxRecordedCommandLength)
+
+	rawRecords, err := decodeExecutionBatchRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if record.Duration < 0 {
-		return fmt.Errorf("duration_ms must be non-negative")
+
+	response := batchResponse{Results: make([]batchRecordResult, len(rawRecords))}
+	accepted := make([]*core.ExecutionRecord, 0, len(rawRecords))
+	acceptedIndexes := make([]int, 0, len(rawRecords))
+	for i, raw := range rawRecords {
+		response.Results[i] = batchRecordResult{Index: i, Status: batchStatusRejected}
+
+		var record core.ExecutionRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			response.Results[i].Error = err.Error()
+			response.Rejected++
+			continue
+		}
+		if err := record.Validate(); err != nil {
+			response.Results[i].Error = err.Error()
+			response.Rejected++
+			continue
+		}
+
+		if !d.admitExecution(&record) {
+			response.Results[i].Status = batchStatusSkipped
+			response.Skipped++
+			continue
+		}
+		d.recordVersionChanges(&record)
+		accepted = append(accepted, &record)
+		acceptedIndexes = append(acceptedIndexes, i)
 	}
-	for _, pkg := range record.PackagesAffected {
-		if strings.TrimSpace(pkg) == "" {
-			return fmt.Errorf("packages_affected cannot contain empty values")
+
+	// A package new to storage is announced once, for the first record in
+	// the batch to install it.
+	newPackages := make([][]string, len(accepted))
+	announced := make(map[string]bool)
+	for i, record := range accepted {
+		for _, name := range d.unseenPackages(record) {
+			if key := record.Tool + "/" + name; !announced[key] {
+				announced[key] = true
+				newPackages[i] = append(newPackages[i], name)
+			}
 		}
 	}
-	return nil
+
+	var stored []bool
+	if len(accepted) > 0 {
+		stored, err = d.storage.AddExecutions(accepted)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to store executions: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Records storage skipped, as already stored or as repeats within
+	// storage.dedupe_window, are reported as duplicates and not published.
+	for i, record := range accepted {
+		result := &response.Results[acceptedIndexes[i]]
+		result.ID = record.ID
+		if !stored[i] {
+			result.Status = batchStatusDuplicate
+			response.Duplicates++
+			continue
+		}
+		d.executionStored(record, newPackages[i])
+		result.Status = batchStatusAccepted
+		response.Accepted++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode batch response", "error", err)
+	}
+}
+
+// decodeExecutionBatchRequest splits a batch body into raw records. The body
+// may be a JSON array or newline-delimited JSON objects; individual records are
+// decoded later so one malformed entry does not reject the whole batch.
+func decodeExecutionBatchRequest(w http.ResponseWriter, r *http.Request) ([]json.RawMessage, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxExecutionBatchBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("request body must contain at least one record")
+	}
+
+	var records []json.RawMessage
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+	} else {
+		for _, line := range bytes.Split(trimmed, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			records = append(records, json.RawMessage(line))
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("request body must contain at least one record")
+	}
+	if len(records) > maxExecutionBatchRecords {
+		return nil, fmt.Errorf("batch exceeds %d records", maxExecutionBatchRecords)
+	}
+	return records, nil
 }
 
 func (d *Daemon) handlePackages(w http.ResponseWriter, r *http.Request) {
@@ -463,106 +1039,480 @@ func (d *Daemon) handlePackages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tool := core.NormalizeToolName(r.URL.Query().Get("tool"))
+	query := r.URL.Query()
+	tool := core.NormalizeToolName(query.Get("tool"))
+
+	var cutoff time.Time
+	if unusedFor := query.Get("unused_for"); unusedFor != "" {
+		duration, err := core.ParseDuration(unusedFor)
+		if err != nil || duration < 0 {
+			http.Error(w, "invalid unused_for", http.StatusBadRequest)
+			return
+		}
+		cutoff = time.Now().Add(-duration)
+	}
+
+	sortBy := query.Get("sort")
+	switch sortBy {
+	case "", packageSortUsageCount, packageSortLastUsed:
+	default:
+		http.Error(w, "invalid sort: must be usage_count or last_used", http.StatusBadRequest)
+		return
+	}
+
+	order := query.Get("order")
+	switch order {
+	case "":
+		order = sortOrderDesc
+	case sortOrderAsc, sortOrderDesc:
+	default:
+		http.Error(w, "invalid order: must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
 	packages, err := d.storage.GetPackages(tool)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if !cutoff.IsZero() {
+		filtered := make([]*core.PackageInfo, 0, len(packages))
+		for _, pkg := range packages {
+			if pkg.LastUsed.IsZero() || pkg.LastUsed.Before(cutoff) {
+				filtered = append(filtered, pkg)
+			}
+		}
+		packages = filtered
+	}
+
+	if sortBy != "" {
+		sortPackageInfos(packages, sortBy, order == sortOrderAsc)
+	} else {
+		// Storage returns packages in map order; sort them so limit keeps
+		// the same ones each time.
+		sort.Slice(packages, func(i, k int) bool {
+			if packages[i].Tool != packages[k].Tool {
+				return packages[i].Tool < packages[k].Tool
+			}
+			return packages[i].Name < packages[k].Name
+		})
+	}
+
+	if limit > 0 && len(packages) > limit {
+		packages = packages[:limit]
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(packages); err != nil {
-		log.Printf("Failed to encode packages response: %v", err)
+		d.logger.Warn("Failed to encode packages response", "error", err)
 	}
 }
 
+func sortPackageInfos(packages []*core.PackageInfo, sortBy string, ascending bool) {
+	sort.SliceStable(packages, func(i, k int) bool {
+		a, b := packages[i], packages[k]
+		if ascending {
+			a, b = b, a
+		}
+		switch sortBy {
+		case packageSortLastUsed:
+			if !a.LastUsed.Equal(b.LastUsed) {
+				return a.LastUsed.After(b.LastUsed)
+			}
+		default:
+			if a.UsageCount != b.UsageCount {
+				return a.UsageCount > b.UsageCount
+			}
+		}
+		if packages[i].Tool != packages[k].Tool {
+			return packages[i].Tool < packages[k].Tool
+		}
+		return packages[i].Name < packages[k].Name
+	})
+}
+
+type statsGroup struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type statsResponse struct {
+	Since           *time.Time   `json:"since,omitempty"`
+	Until           *time.Time   `json:"until,omitempty"`
+	GroupBy         string       `json:"group_by,omitempty"`
+	TotalExecutions int          `json:"total_executions"`
+	Groups          []statsGroup `json:"groups,omitempty"`
+}
+
 func (d *Daemon) handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	stats, err := d.storage.GetStatistics()
+	query := r.URL.Query()
+	if query.Get("since") == "" && query.Get("until") == "" && query.Get("group_by") == "" && query.Get("tool") == "" {
+		stats, err := d.storage.GetStatistics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			d.logger.Warn("Failed to encode stats response", "error", err)
+		}
+		return
+	}
+
+	opts := storage.QueryOptions{Tool: core.NormalizeToolName(query.Get("tool"))}
+	var err error
+	if opts.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Until, err = parseUntilParam(query.Get("until")); err != nil {
+		http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groupBy := query.Get("group_by")
+	switch groupBy {
+	case "", statsGroupByTool, statsGroupByDay, statsGroupByPackage, statsGroupByHour, statsGroupByWeekday:
+	default:
+		http.Error(w, "invalid group_by: must be tool, day, package, hour, or weekday", http.StatusBadRequest)
+		return
+	}
+
+	// Plain dates select whole days, which storage answers from its daily
+	// aggregates.
+	result, err := d.storage.Aggregate(storage.AggregateOptions{Query: opts, GroupBy: groupBy})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	response := statsResponse{Since: opts.Since, Until: opts.Until, GroupBy: groupBy, TotalExecutions: result.Total.Count}
+	for _, group := range result.Groups {
+		response.Groups = append(response.Groups, statsGroup{Key: group.Key, Count: group.Count})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		log.Printf("Failed to encode stats response: %v", err)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode stats response", "error", err)
 	}
 }
 
-func (d *Daemon) handleHealth(w http.ResponseWriter, r *http.Request) {
+// parseTimeParam accepts RFC 3339 timestamps or plain YYYY-MM-DD dates.
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+	parsed, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	return &parsed, nil
+}
+
+// parseUntilParam is parseTimeParam for the end of a range: a plain date
+// includes that whole day, ending at its last instant.
+func parseUntilParam(value string) (*time.Time, error) {
+	parsed, err := parseTimeParam(value)
+	if err != nil || parsed == nil {
+		return parsed, err
+	}
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		end := parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		return &end, nil
+	}
+	return parsed, nil
+}
+
+func groupExecutions(executions []*core.ExecutionRecord, groupBy string) []statsGroup {
+	if groupBy == "" {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, exec := range executions {
+		switch groupBy {
+		case statsGroupByTool:
+			counts[exec.Tool]++
+		case statsGroupByDay:
+			counts[exec.Timestamp.Format(time.DateOnly)]++
+		case statsGroupByPackage:
+			for _, pkg := range exec.PackagesAffected {
+				counts[exec.Tool+"/"+pkg]++
+			}
+		}
+	}
+
+	return sortStatsGroups(counts, groupBy)
+}
+
+// sortStatsGroups orders day groups by date and the others by descending
+// count
+func sortStatsGroups(counts map[string]int, groupBy string) []statsGroup {
+	groups := make([]statsGroup, 0, len(counts))
+	for key, count := range counts {
+		groups = append(groups, statsGroup{Key: key, Count: count})
+	}
+	sort.Slice(groups, func(i, k int) bool {
+		if groupBy == statsGroupByDay {
+			return groups[i].Key < groups[k].Key
+		}
+		if groups[i].Count != groups[k].Count {
+			return groups[i].Count > groups[k].Count
+		}
+		return groups[i].Key < groups[k].Key
+	})
+	return groups
+}
+
+// projectSummary is what one project's work touched: the package managers
+// it ran and its most used packages
+type projectSummary struct {
+	Project    string       `json:"project"`
+	Executions int          `json:"executions"`
+	LastUsed   time.Time    `json:"last_used"`
+	Tools      []statsGroup `json:"tools"`
+	Packages   []statsGroup `json:"packages"`
+}
+
+// handleProjects summarizes executions per project, busiest first.
+// Executions without a working directory belong to no project.
+func (d *Daemon) handleProjects(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	health := map[string]interface{}{
-		"status":          "healthy",
-		"version":         core.Version,
-		"uptime":          time.Since(d.startTime).String(),
-		"monitors_active": len(d.registry.GetAll()),
+	query := r.URL.Query()
+	opts := storage.QueryOptions{
+		Tool:    core.NormalizeToolName(query.Get("tool")),
+		Project: query.Get("project"),
+	}
+	var err error
+	if opts.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Until, err = parseUntilParam(query.Get("until")); err != nil {
+		http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	top := defaultProjectTopPackages
+	if topStr := query.Get("top"); topStr != "" {
+		if top, err = strconv.Atoi(topStr); err != nil || top < 0 {
+			http.Error(w, "invalid top", http.StatusBadRequest)
+			return
+		}
+	}
+
+	executions, err := d.storage.GetExecutions(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	byProject := make(map[string][]*core.ExecutionRecord)
+	for _, exec := range executions {
+		if project := exec.Project(); project != "" {
+			byProject[project] = append(byProject[project], exec)
+		}
+	}
+	summaries := make([]projectSummary, 0, len(byProject))
+	for project, projectExecutions := range byProject {
+		summary := projectSummary{
+			Project:    project,
+			Executions: len(projectExecutions),
+			Tools:      groupExecutions(projectExecutions, statsGroupByTool),
+			Packages:   groupExecutions(projectExecutions, statsGroupByPackage),
+		}
+		for _, exec := range projectExecutions {
+			if exec.Timestamp.After(summary.LastUsed) {
+				summary.LastUsed = exec.Timestamp
+			}
+		}
+		if top > 0 && len(summary.Packages) > top {
+			summary.Packages = summary.Packages[:top]
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, k int) bool {
+		if summaries[i].Executions != summaries[k].Executions {
+			return summaries[i].Executions > summaries[k].Executions
+		}
+		return summaries[i].Project < summaries[k].Project
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		d.logger.Warn("Failed to encode projects response", "error", err)
+	}
+}
+
+func (d *Daemon) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	health := d.healthStatus()
+
 	w.Header().Set("Content-Type", "application/json")
+	if health.Status != core.HealthStatusHealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	if err := json.NewEncoder(w).Encode(health); err != nil {
-		log.Printf("Failed to encode health response: %v", err)
+		d.logger.Warn("Failed to encode health response", "error", err)
 	}
 }
 
-func (d *Daemon) writePIDFile() error {
-	pid := os.Getpid()
-	if err := os.MkdirAll(filepath.Dir(d.config.Daemon.PIDFile), core.OwnerDirectoryMode); err != nil {
-		return err
+func (d *Daemon) healthStatus() core.HealthStatus {
+	registry := d.monitorRegistry()
+	registered := registry.GetAll()
+	monitorHealth := registry.Health()
+
+	queue := core.EventQueueHealth{
+		Length:   len(d.eventChan),
+		Capacity: cap(d.eventChan),
+		Spilled:  d.spill.waiting.Load(),
+		Dropped:  d.droppedEvents.Load(),
+	}
+	if queue.Capacity > 0 {
+		queue.Utilization = float64(queue.Length) / float64(queue.Capacity)
+	}
+
+	health := core.HealthStatus{
+		Status:         core.HealthStatusHealthy,
+		Version:        core.Version,
+		Uptime:         time.Since(d.startTime).String(),
+		MonitorsActive: len(registered),
+		Storage:        d.storageHealth(),
+		EventQueue:     queue,
+		Monitors:       monitorHealth,
+	}
+	if pause := d.pauseState(); pause.Paused {
+		health.Pause = &pause
+	}
+	if health.Storage.Status != core.HealthStatusOK {
+		health.Status = core.HealthStatusDegraded
+	}
+	return health
+}
+
+// storageHealth reports on the storage file. Saves are atomic renames, so the
+// file's modification time is the last successful save.
+func (d *Daemon) storageHealth() core.StorageHealth {
+	health := core.StorageHealth{
+		Backend: d.currentConfig().Storage.Backend,
+		Status:  core.HealthStatusOK,
+		Path:    d.currentConfig().Storage.JSONFile,
+	}
+	if health.Backend == "" {
+		health.Backend = core.StorageBackendJSON
+	}
+
+	info, err := os.Stat(health.Path)
+	if err != nil {
+		health.Status = core.HealthStatusError
+		health.Error = err.Error()
+		return health
+	}
+	health.SizeBytes = info.Size()
+	health.LastSaved = info.ModTime()
+
+	free, err := diskFreeBytes(filepath.Dir(health.Path))
+	if err != nil {
+		health.Status = core.HealthStatusError
+		health.Error = fmt.Sprintf("failed to check disk space: %v", err)
+		return health
+	}
+	health.DiskFreeBytes = free
+	if health.DiskFreeBytes < core.MinHealthyDiskFreeBytes {
+		health.Status = core.HealthStatusLowDisk
+	}
+	return health
+}
+
+func (d *Daemon) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := d.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":          "reloaded",
+		"monitors_active": len(d.monitorRegistry().GetAll()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode reload response", "error", err)
 	}
-	return os.WriteFile(d.config.Daemon.PIDFile, []byte(strconv.Itoa(pid)), core.PrivateFileMode)
 }
 
 func (d *Daemon) handleSignals() {
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, daemonSignals...)
 
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
 		defer signal.Stop(sigChan)
-		select {
-		case sig := <-sigChan:
-			log.Printf("Received signal: %v", sig)
-			go func() {
-				if err := d.Stop(); err != nil {
-					log.Printf("Error stopping daemon: %v", err)
+		for {
+			select {
+			case sig := <-sigChan:
+				d.logger.Info("Received signal", "signal", sig.String())
+				if isReloadSignal(sig) {
+					if err := d.Reload(); err != nil {
+						d.logger.Error("Error reloading daemon", "error", err)
+					}
+					continue
 				}
-			}()
-		case <-d.ctx.Done():
-			return
+				go func() {
+					if err := d.Stop(); err != nil {
+						d.logger.Error("Error stopping daemon", "error", err)
+					}
+				}()
+				return
+			case <-d.ctx.Done():
+				return
+			}
 		}
 	}()
 }
 
+// IsRunning reports whether a daemon holds the PID lock, falling back to a
+// liveness check of the PID recorded in the PID file.
 func IsRunning(config *core.Config) bool {
-	if _, err := os.Stat(config.Daemon.PIDFile); err != nil {
-		return false
+	if _, held := LockHolder(config); held {
+		return true
 	}
 
-	pidBytes, err := os.ReadFile(config.Daemon.PIDFile)
+	pid, err := readPIDFile(config.Daemon.PIDFile)
 	if err != nil {
 		return false
 	}
-
-	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
-	if err != nil {
-		return false
-	}
-
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false
-	}
-
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	return processAlive(pid)
 }
@@ -3,12 +3,16 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +20,8 @@ import (
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/internal/notify"
 	"github.com/yowainwright/diu/internal/storage"
 )
 
@@ -58,6 +64,32 @@ func (m *mockStorage) AddExecution(record *core.ExecutionRecord) error {
 	return nil
 }
 
+func (m *mockStorage) AddExecutions(records []*core.ExecutionRecord) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.addErr != nil {
+		return nil, m.addErr
+	}
+	stored := make([]bool, len(records))
+	for i, record := range records {
+		if m.hasExecution(record.ID) {
+			continue
+		}
+		m.executions = append(m.executions, record)
+		stored[i] = true
+	}
+	return stored, nil
+}
+
+func (m *mockStorage) hasExecution(id string) bool {
+	for _, exec := range m.executions {
+		if exec.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *mockStorage) GetExecutions(opts storage.QueryOptions) ([]*core.ExecutionRecord, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -70,6 +102,33 @@ func (m *mockStorage) GetExecutions(opts storage.QueryOptions) ([]*core.Executio
 		if opts.Tool != "" && e.Tool != opts.Tool {
 			continue
 		}
+		if opts.Since != nil && e.Timestamp.Before(*opts.Since) {
+			continue
+		}
+		if opts.Until != nil && e.Timestamp.After(*opts.Until) {
+			continue
+		}
+		if opts.ExitCode != nil && e.ExitCode != *opts.ExitCode {
+			continue
+		}
+		if opts.FailedOnly && e.ExitCode == 0 {
+			continue
+		}
+		if opts.WorkingDir != "" && !e.InDir(opts.WorkingDir) {
+			continue
+		}
+		if opts.Project != "" && e.Project() != opts.Project {
+			continue
+		}
+		if opts.SessionID != "" && e.SessionID != opts.SessionID {
+			continue
+		}
+		if opts.Host != "" && !e.FromHost(opts.Host) {
+			continue
+		}
+		if opts.CommandPattern != nil && !opts.CommandPattern.MatchString(e.Command) {
+			continue
+		}
 		result = append(result, e)
 	}
 
@@ -79,6 +138,19 @@ func (m *mockStorage) GetExecutions(opts storage.QueryOptions) ([]*core.Executio
 	return result, nil
 }
 
+func (m *mockStorage) StreamExecutions(opts storage.QueryOptions, fn func(*core.ExecutionRecord) error) error {
+	executions, err := m.GetExecutions(opts)
+	if err != nil {
+		return err
+	}
+	for _, e := range executions {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockStorage) GetExecutionByID(id string) (*core.ExecutionRecord, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -155,21 +227,31 @@ func (m *mockStorage) DeletePackage(tool, name string) error {
 func (m *mockStorage) GetStatistics() (*core.StorageStatistics, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return &core.StorageStatistics{
-		TotalExecutions: len(m.executions),
-		ExecutionFrequency: map[string]int{
-			"homebrew": 5,
-			"npm":      3,
-		},
-	}, nil
+	stats := &core.StorageStatistics{}
+	for _, exec := range m.executions {
+		stats.Count(exec)
+	}
+	return stats, nil
 }
 
 func (m *mockStorage) UpdateStatistics() error {
 	return nil
 }
 
-func (m *mockStorage) Backup() error {
-	return nil
+func (m *mockStorage) Aggregate(opts storage.AggregateOptions) (*storage.AggregateResult, error) {
+	return storage.AggregateExecutions(m, opts)
+}
+
+func (m *mockStorage) Backup() (string, error) {
+	return "", nil
+}
+
+func (m *mockStorage) ImportBackup(data []byte) (string, error) {
+	return "", nil
+}
+
+func (m *mockStorage) ImportRecords(executions []*core.ExecutionRecord, packages []*core.PackageInfo) (*storage.ImportResult, error) {
+	return &storage.ImportResult{}, nil
 }
 
 func (m *mockStorage) Restore(path string) error {
@@ -307,6 +389,20 @@ func TestNewDaemon(t *testing.T) {
 	}
 }
 
+func TestNewDaemonRejectsInvalidConfig(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Storage.Backend = "postgres"
+
+	_, err := NewDaemon(cfg)
+	var configErr *core.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected *core.ConfigError, got %v", err)
+	}
+	if len(configErr.Issues) != 1 || configErr.Issues[0].Key != "storage.backend" {
+		t.Errorf("Unexpected issues: %v", configErr.Issues)
+	}
+}
+
 func TestDaemonStartStop(t *testing.T) {
 	cfg := testConfig(t)
 
@@ -344,6 +440,51 @@ func TestDaemonStartStop(t *testing.T) {
 	}
 }
 
+func TestDaemonStartFailsWhilePIDLockHeld(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.API.Enabled = false
+
+	first, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	if err := first.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer first.Stop()
+
+	pid, held := LockHolder(cfg)
+	if !held || pid != os.Getpid() {
+		t.Fatalf("LockHolder() = %d, %v; want %d, true", pid, held, os.Getpid())
+	}
+	if !IsRunning(cfg) {
+		t.Error("Expected IsRunning while the PID lock is held")
+	}
+
+	// The second daemon must fail before it opens storage or the log file
+	if err := os.Remove(cfg.Storage.JSONFile); err != nil {
+		t.Fatalf("Failed to remove storage file: %v", err)
+	}
+	_, err = NewDaemon(cfg)
+	var running *AlreadyRunningError
+	if !errors.As(err, &running) {
+		t.Fatalf("Expected AlreadyRunningError, got %v", err)
+	}
+	if running.PID != os.Getpid() {
+		t.Errorf("Expected lock holder PID %d, got %d", os.Getpid(), running.PID)
+	}
+	if _, err := os.Stat(cfg.Storage.JSONFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the second daemon not to open storage, got %v", err)
+	}
+
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if _, held := LockHolder(cfg); held {
+		t.Error("Expected PID lock to be released after Stop")
+	}
+}
+
 func TestDaemonDoubleStop(t *testing.T) {
 	cfg := testConfig(t)
 
@@ -724,6 +865,93 @@ func TestDaemonSocketListener(t *testing.T) {
 	}
 }
 
+func TestDaemonSocketAcceptsManyRecordsAndConnections(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	// More connections than workers, each sending several records.
+	const conns, perConn = core.DefaultSocketWorkers * 2, 3
+	var wg sync.WaitGroup
+	errs := make(chan error, conns)
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("unix", cfg.Daemon.SocketPath)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			encoder := json.NewEncoder(conn)
+			for j := 0; j < perConn; j++ {
+				record := core.ExecutionRecord{
+					ID:        fmt.Sprintf("socket-%d-%d", i, j),
+					Tool:      "npm",
+					Command:   "npm ci",
+					Timestamp: time.Now(),
+				}
+				if err := encoder.Encode(record); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed to send records: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for mockStore.getExecutionCount() != conns*perConn {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d executions from socket, got %d", conns*perConn, mockStore.getExecutionCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDaemonStopClosesIdleSocketConnections(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	conn, err := net.Dial("unix", cfg.Daemon.SocketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect to socket: %v", err)
+	}
+	defer closeForTest(t, conn)
+	time.Sleep(50 * time.Millisecond)
+
+	started := time.Now()
+	stopDaemonForTest(t, d)
+	if elapsed := time.Since(started); elapsed >= core.DefaultSocketIdleTimeout {
+		t.Errorf("Expected stop not to wait for an idle connection, took %v", elapsed)
+	}
+}
+
 func TestIsRunning(t *testing.T) {
 	cfg := testConfig(t)
 
@@ -1046,3 +1274,991 @@ func TestProcessEventsDrainsQueuedEventsOnCancel(t *testing.T) {
 		t.Fatalf("Expected 2 drained executions, got %d", got)
 	}
 }
+
+func TestHandleExecutionBatch(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	t.Run("JSON array with per-record status", func(t *testing.T) {
+		body := `[
+			{"tool": "brew", "command": "brew install jq", "args": ["install", "jq"]},
+			{"tool": "", "command": "missing tool"},
+			{"tool": "npm", "command": "npm install -g tsx"}
+		]`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		d.handleExecutionBatch(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var response batchResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.Accepted != 2 || response.Rejected != 1 {
+			t.Fatalf("Expected 2 accepted and 1 rejected, got %+v", response)
+		}
+		if response.Results[1].Status != batchStatusRejected || response.Results[1].Error == "" {
+			t.Errorf("Expected record 1 to be rejected with an error, got %+v", response.Results[1])
+		}
+		if response.Results[0].Status != batchStatusAccepted {
+			t.Errorf("Expected record 0 to be accepted, got %+v", response.Results[0])
+		}
+		if mockStore.executions[0].Tool != core.ToolHomebrew {
+			t.Errorf("Expected tool to be normalized, got %q", mockStore.executions[0].Tool)
+		}
+	})
+
+	t.Run("NDJSON with malformed line", func(t *testing.T) {
+		before := mockStore.getExecutionCount()
+		body := "{\"tool\": \"uv\", \"command\": \"uv tool install ruff\"}\n{not json}\n\n{\"tool\": \"pip\", \"command\": \"pip install black\"}\n"
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		d.handleExecutionBatch(w, req)
+
+		var response batchResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.Accepted != 2 || response.Rejected != 1 {
+			t.Fatalf("Expected 2 accepted and 1 rejected, got %+v", response)
+		}
+		if got := mockStore.getExecutionCount() - before; got != 2 {
+			t.Errorf("Expected 2 stored executions, got %d", got)
+		}
+	})
+
+	t.Run("already stored IDs are duplicates", func(t *testing.T) {
+		before := mockStore.getExecutionCount()
+		existing := mockStore.executions[0].ID
+		updates := d.stream.subscribe()
+		defer d.stream.unsubscribe(updates)
+
+		body := `[
+			{"id": "` + existing + `", "tool": "brew", "command": "brew install jq"},
+			{"tool": "cargo", "command": "cargo install ripgrep"}
+		]`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		d.handleExecutionBatch(w, req)
+
+		var response batchResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.Accepted != 1 || response.Duplicates != 1 || response.Rejected != 0 {
+			t.Fatalf("Expected 1 accepted and 1 duplicate, got %+v", response)
+		}
+		if response.Results[0].Status != batchStatusDuplicate || response.Results[0].ID != existing {
+			t.Errorf("Expected record 0 to be a duplicate of %s, got %+v", existing, response.Results[0])
+		}
+		if got := mockStore.getExecutionCount() - before; got != 1 {
+			t.Errorf("Expected 1 stored execution, got %d", got)
+		}
+		if got := len(updates); got != 1 {
+			t.Errorf("Expected only the stored execution to be published, got %d", got)
+		}
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader("  "))
+		w := httptest.NewRecorder()
+
+		d.handleExecutionBatch(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/executions/batch", nil)
+		w := httptest.NewRecorder()
+
+		d.handleExecutionBatch(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status 405, got %d", w.Code)
+		}
+	})
+}
+
+// postBatch posts body to the batch endpoint and decodes its response
+func postBatch(t *testing.T, d *Daemon, body string) batchResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	d.handleExecutionBatch(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response batchResponse
+	decodeRecorderJSON(t, w, &response)
+	return response
+}
+
+func TestHandleExecutionBatchReportsDedupeWindowRepeats(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Storage.DedupeWindow = 2 * time.Second
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	updates := d.stream.subscribe()
+	defer d.stream.unsubscribe(updates)
+
+	// The same run reported by both a wrapper and a shell hook
+	body := `[
+		{"tool": "npm", "command": "npm install -g tsx", "timestamp": "2026-03-01T12:00:00Z"},
+		{"tool": "npm", "command": "npm install -g tsx", "timestamp": "2026-03-01T12:00:01Z"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	d.handleExecutionBatch(w, req)
+
+	var response batchResponse
+	decodeRecorderJSON(t, w, &response)
+
+	if response.Accepted != 1 || response.Duplicates != 1 {
+		t.Fatalf("Expected 1 accepted and 1 duplicate, got %+v", response)
+	}
+	if response.Results[1].Status != batchStatusDuplicate {
+		t.Errorf("Expected the repeat to be a duplicate, got %+v", response.Results[1])
+	}
+	if got := len(updates); got != 1 {
+		t.Errorf("Expected only the stored execution to be published, got %d", got)
+	}
+}
+
+func TestHandleExecutionBatchSkipsDisabledTools(t *testing.T) {
+	cfg := testConfig(t)
+	if err := cfg.SetToolEnabled(core.ToolNPM, false); err != nil {
+		t.Fatalf("SetToolEnabled failed: %v", err)
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	response := postBatch(t, d, `[{"tool": "npm", "command": "npm install -g tsx"}, {"tool": "brew", "command": "brew install jq"}]`)
+	if response.Accepted != 1 || response.Skipped != 1 || response.Results[0].Status != batchStatusSkipped {
+		t.Fatalf("Expected the disabled tool's record skipped, got %+v", response)
+	}
+	if got := mockStore.getExecutionCount(); got != 1 {
+		t.Errorf("Expected 1 stored execution, got %d", got)
+	}
+}
+
+func TestHandleExecutionBatchNotifiesWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		commands = append(commands, body["command"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Webhooks = []core.WebhookConfig{{URL: server.URL, Events: []string{notify.EventExecutionIngested}}}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	response := postBatch(t, d, `[{"tool": "npm", "command": "npm install -g tsx"}, {"tool": "brew", "command": "brew install jq"}]`)
+	if response.Accepted != 2 {
+		t.Fatalf("Expected 2 accepted records, got %+v", response)
+	}
+	d.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(commands) != 2 {
+		t.Errorf("Expected an execution_ingested webhook per stored record, got %v", commands)
+	}
+}
+
+// listingMonitor is a homebrew monitor whose installed packages are fixed
+type listingMonitor struct {
+	*monitors.BaseMonitor
+	installed []*core.PackageInfo
+}
+
+func (m *listingMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return nil
+}
+
+func (m *listingMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	return m.installed, nil
+}
+
+func (m *listingMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	return &core.ExecutionRecord{}, nil
+}
+
+func TestHandleExecutionBatchRecordsVersionChanges(t *testing.T) {
+	d, err := NewDaemon(testConfig(t))
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.registry.Register(&listingMonitor{
+		BaseMonitor: monitors.NewBaseMonitor(core.ToolHomebrew),
+		installed:   []*core.PackageInfo{{Name: "jq", Version: "1.7.1"}},
+	})
+	mockStore := newMockStorage()
+	updateMockPackage(t, mockStore, &core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.7"})
+	d.storage = mockStore
+
+	response := postBatch(t, d, `[{"tool": "homebrew", "command": "brew upgrade jq", "packages_affected": ["jq"], "metadata": {"action": "update"}}]`)
+	if response.Accepted != 1 {
+		t.Fatalf("Expected the record accepted, got %+v", response)
+	}
+	stored, err := mockStore.GetExecutionByID(response.Results[0].ID)
+	if err != nil {
+		t.Fatalf("GetExecutionByID failed: %v", err)
+	}
+	want := []core.VersionChange{{Package: "jq", From: "1.7", To: "1.7.1"}}
+	if got := stored.VersionChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the upgrade's versions recorded, got %+v", got)
+	}
+}
+
+func TestHandleStatsGrouping(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day1, PackagesAffected: []string{"tsx"}})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day2, PackagesAffected: []string{"tsx"}})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "homebrew", Timestamp: day2, PackagesAffected: []string{"jq"}})
+
+	t.Run("group by tool", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?group_by=tool", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.TotalExecutions != 3 {
+			t.Errorf("Expected 3 executions, got %d", response.TotalExecutions)
+		}
+		if len(response.Groups) != 2 || response.Groups[0].Key != "npm" || response.Groups[0].Count != 2 {
+			t.Errorf("Unexpected tool groups: %+v", response.Groups)
+		}
+	})
+
+	t.Run("group by day with range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?group_by=day&since=2026-03-02T00:00:00Z", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.TotalExecutions != 2 {
+			t.Errorf("Expected 2 executions since day 2, got %d", response.TotalExecutions)
+		}
+		if len(response.Groups) != 1 || response.Groups[0].Key != "2026-03-02" {
+			t.Errorf("Unexpected day groups: %+v", response.Groups)
+		}
+	})
+
+	t.Run("group by package", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?group_by=package&until=2026-03-03", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if len(response.Groups) != 2 || response.Groups[0].Key != "npm/tsx" {
+			t.Errorf("Unexpected package groups: %+v", response.Groups)
+		}
+	})
+
+	t.Run("whole days from aggregates", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?group_by=day&tool=npm&since=2026-03-02&until=2026-03-03", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.TotalExecutions != 1 || len(response.Groups) != 1 || response.Groups[0] != (statsGroup{Key: "2026-03-02", Count: 1}) {
+			t.Errorf("Expected one npm execution on day 2, got %+v", response)
+		}
+	})
+
+	t.Run("until date includes the day", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?group_by=day&until=2026-03-01", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.TotalExecutions != 1 || len(response.Groups) != 1 || response.Groups[0] != (statsGroup{Key: "2026-03-01", Count: 1}) {
+			t.Errorf("Expected the execution on day 1, got %+v", response)
+		}
+	})
+
+	t.Run("tool alone", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?tool=homebrew", nil)
+		w := httptest.NewRecorder()
+
+		d.handleStats(w, req)
+
+		var response statsResponse
+		decodeRecorderJSON(t, w, &response)
+
+		if response.TotalExecutions != 1 {
+			t.Errorf("Expected 1 homebrew execution, got %+v", response)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"group_by=user", "since=yesterday"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?"+query, nil)
+			w := httptest.NewRecorder()
+
+			d.handleStats(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+			}
+		}
+	})
+}
+
+func TestHandleProjects(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day1, WorkingDir: "/src/api", PackagesAffected: []string{"tsx"}})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day2, WorkingDir: "/src/api", PackagesAffected: []string{"tsx", "zod"}})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "uv", Timestamp: day2, WorkingDir: "/src/api", PackagesAffected: []string{"ruff"}})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "cargo", Timestamp: day1, WorkingDir: "/src/cli", ProjectName: "cli"})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "homebrew", Timestamp: day2, PackagesAffected: []string{"jq"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/projects?top=1", nil)
+	w := httptest.NewRecorder()
+	d.handleProjects(w, req)
+
+	var summaries []projectSummary
+	decodeRecorderJSON(t, w, &summaries)
+	if len(summaries) != 2 || summaries[0].Project != "api" || summaries[1].Project != "cli" {
+		t.Fatalf("Expected api then cli, got %+v", summaries)
+	}
+	api := summaries[0]
+	if api.Executions != 3 || !api.LastUsed.Equal(day2) {
+		t.Errorf("Unexpected api summary: %+v", api)
+	}
+	if len(api.Tools) != 2 || api.Tools[0] != (statsGroup{Key: "npm", Count: 2}) {
+		t.Errorf("Unexpected api tools: %+v", api.Tools)
+	}
+	if len(api.Packages) != 1 || api.Packages[0] != (statsGroup{Key: "npm/tsx", Count: 2}) {
+		t.Errorf("Expected only the top api package, got %+v", api.Packages)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/projects?project=cli", nil)
+	w = httptest.NewRecorder()
+	d.handleProjects(w, req)
+	summaries = nil
+	decodeRecorderJSON(t, w, &summaries)
+	if len(summaries) != 1 || summaries[0].Project != "cli" {
+		t.Errorf("Expected only the cli project, got %+v", summaries)
+	}
+
+	for _, query := range []string{"top=-1", "since=yesterday"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects?"+query, nil)
+		w := httptest.NewRecorder()
+		d.handleProjects(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHandlePackagesFiltersAndSorting(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	now := time.Now()
+	updateMockPackage(t, mockStore, &core.PackageInfo{Name: "jq", Tool: "homebrew", UsageCount: 10, LastUsed: now})
+	updateMockPackage(t, mockStore, &core.PackageInfo{Name: "wget", Tool: "homebrew", UsageCount: 2, LastUsed: now.Add(-60 * 24 * time.Hour)})
+	updateMockPackage(t, mockStore, &core.PackageInfo{Name: "tsx", Tool: "npm", UsageCount: 5, LastUsed: now.Add(-40 * 24 * time.Hour)})
+	updateMockPackage(t, mockStore, &core.PackageInfo{Name: "cowsay", Tool: "npm"})
+
+	get := func(t *testing.T, query string) []*core.PackageInfo {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/packages?"+query, nil)
+		w := httptest.NewRecorder()
+		d.handlePackages(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", query, w.Code)
+		}
+		var packages []*core.PackageInfo
+		decodeRecorderJSON(t, w, &packages)
+		return packages
+	}
+
+	t.Run("unused_for", func(t *testing.T) {
+		packages := get(t, "unused_for=30d&sort=last_used&order=asc")
+		if len(packages) != 3 {
+			t.Fatalf("Expected 3 unused packages, got %d", len(packages))
+		}
+		if packages[0].Name != "cowsay" || packages[1].Name != "wget" {
+			t.Errorf("Unexpected ascending last_used order: %s, %s", packages[0].Name, packages[1].Name)
+		}
+	})
+
+	t.Run("sort by usage with limit", func(t *testing.T) {
+		packages := get(t, "sort=usage_count&limit=2")
+		if len(packages) != 2 || packages[0].Name != "jq" || packages[1].Name != "tsx" {
+			t.Errorf("Unexpected usage order: %+v", packages)
+		}
+	})
+
+	t.Run("limit without sort", func(t *testing.T) {
+		packages := get(t, "limit=3")
+		if len(packages) != 3 || packages[0].Name != "jq" || packages[1].Name != "wget" || packages[2].Name != "cowsay" {
+			t.Errorf("Expected the first packages by tool and name, got %+v", packages)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"unused_for=soon", "sort=name", "order=up", "limit=-1"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/packages?"+query, nil)
+			w := httptest.NewRecorder()
+			d.handlePackages(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+			}
+		}
+	})
+}
+
+func TestHealthReportsStorageAndQueue(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	d.eventChan <- &core.ExecutionRecord{Tool: "npm", Command: "npm ls"}
+	d.droppedEvents.Add(2)
+
+	health := d.healthStatus()
+	if health.Status != core.HealthStatusHealthy {
+		t.Fatalf("Expected healthy status, got %s (%+v)", health.Status, health.Storage)
+	}
+	if health.Storage.Status != core.HealthStatusOK || health.Storage.SizeBytes == 0 || health.Storage.LastSaved.IsZero() {
+		t.Errorf("Unexpected storage health: %+v", health.Storage)
+	}
+	if health.Storage.DiskFreeBytes == 0 {
+		t.Error("Expected disk free bytes to be reported")
+	}
+	if health.EventQueue.Length != 1 || health.EventQueue.Capacity != core.DefaultEventBuffer || health.EventQueue.Dropped != 2 {
+		t.Errorf("Unexpected event queue health: %+v", health.EventQueue)
+	}
+	if health.EventQueue.Utilization <= 0 {
+		t.Error("Expected non-zero queue utilization")
+	}
+}
+
+func TestHealthDegradedWhenStorageMissing(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	removeFileForTest(t, cfg.Storage.JSONFile)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	w := httptest.NewRecorder()
+
+	d.handleHealth(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	var health core.HealthStatus
+	decodeRecorderJSON(t, w, &health)
+	if health.Status != core.HealthStatusDegraded || health.Storage.Status != core.HealthStatusError {
+		t.Errorf("Expected degraded storage error, got %+v", health)
+	}
+}
+
+func TestHealthReportsMonitorState(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin needs a POSIX shell")
+	}
+	cfg := testConfig(t)
+	failing := filepath.Join(t.TempDir(), "diu-plugin-mise")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho 'cannot parse' >&2\nexit 1\n"), 0o700); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	cfg.Monitoring.EnabledTools = []string{"npm"}
+	cfg.Monitoring.Plugins = []core.PluginConfig{
+		{Name: "mise", Path: failing},
+		{Name: "cargo", Path: filepath.Join(t.TempDir(), "missing")},
+	}
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"install", "tsx"}, Timestamp: at})
+	d.storeExecution(&core.ExecutionRecord{Tool: "mise", Command: "mise", Args: []string{"install"}, Timestamp: at})
+
+	health := d.healthStatus()
+	if health.Status != core.HealthStatusHealthy || health.MonitorsActive != 2 {
+		t.Errorf("Expected a healthy daemon with 2 monitors, got %s with %d", health.Status, health.MonitorsActive)
+	}
+	byName := make(map[string]core.MonitorHealth, len(health.Monitors))
+	for _, monitor := range health.Monitors {
+		byName[monitor.Name] = monitor
+	}
+	if got := byName["npm"]; !got.Initialized || !got.LastEvent.Equal(at) || got.Errors != 0 {
+		t.Errorf("Unexpected npm monitor health: %+v", got)
+	}
+	if got := byName["mise"]; !got.Initialized || !got.LastEvent.Equal(at) || got.Errors != 1 || !strings.Contains(got.LastError, "cannot parse") {
+		t.Errorf("Expected the plugin's parse failure counted, got %+v", got)
+	}
+	if got := byName["cargo"]; got.Status != core.HealthStatusError || got.Initialized || got.Errors != 1 {
+		t.Errorf("Expected the missing plugin reported, got %+v", got)
+	}
+}
+
+func TestDaemonReloadRebuildsMonitors(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	reloaded := testConfig(t)
+	reloaded.Monitoring.EnabledTools = []string{"homebrew", "npm"}
+	d.loadConfig = func() (*core.Config, error) {
+		return reloaded, nil
+	}
+
+	d.eventChan <- &core.ExecutionRecord{Tool: "npm", Command: "npm ls"}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reload", nil)
+	w := httptest.NewRecorder()
+
+	d.handleReload(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := len(d.monitorRegistry().GetAll()); got != 2 {
+		t.Errorf("Expected 2 monitors after reload, got %d", got)
+	}
+	if len(d.eventChan) != 1 {
+		t.Errorf("Expected queued event to survive reload, got %d queued", len(d.eventChan))
+	}
+}
+
+func TestDaemonReloadReportsConfigError(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.loadConfig = func() (*core.Config, error) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reload", nil)
+	w := httptest.NewRecorder()
+
+	d.handleReload(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestDaemonReloadAppliesLiveSettings(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	reloaded := *cfg
+	reloaded.Storage.RetentionDays = 30
+	reloaded.Reporting.DailySummary = true
+	reloaded.Notifications.Webhooks = []core.WebhookConfig{{URL: "https://example.com/hook", Events: []string{notify.EventPackageInstalled}}}
+	reloaded.Daemon.LogLevel = "debug"
+	reloaded.API.Port = 9999
+	d.loadConfig = func() (*core.Config, error) {
+		return &reloaded, nil
+	}
+
+	if err := d.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	live := d.currentConfig()
+	if live.Storage.RetentionDays != 30 || !live.Reporting.DailySummary {
+		t.Errorf("Expected retention and reporting to apply, got %+v %+v", live.Storage, live.Reporting)
+	}
+	if !d.currentNotifier().Subscribed(notify.EventPackageInstalled) {
+		t.Error("Expected reloaded webhook to be subscribed")
+	}
+	if live.Daemon.LogLevel != "info" || live.API.Port != cfg.API.Port {
+		t.Errorf("Expected restart-only settings to be kept, got %+v %+v", live.Daemon, live.API)
+	}
+	if got := strings.Join(restartRequiredSettings(cfg, &reloaded), ","); got != "daemon.log_level,api.port" {
+		t.Errorf("restartRequiredSettings = %q", got)
+	}
+}
+
+func TestConfigWatcherReloadsOnChange(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{}"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	reloaded := make(chan struct{}, 1)
+	d.loadConfig = func() (*core.Config, error) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+		return cfg, nil
+	}
+
+	d.wg.Add(1)
+	go d.runConfigWatcher(path, 10*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(`{"storage": {"retention_days": 30}}`), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the config change to trigger a reload")
+	}
+}
+
+func TestHandleExecutionStream(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	server := httptest.NewServer(http.HandlerFunc(d.handleExecutionStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?tool=npm")
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer closeForTest(t, resp.Body)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Expected NDJSON content type, got %q", ct)
+	}
+
+	d.storeExecution(&core.ExecutionRecord{Tool: "brew", Command: "brew install jq"})
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm install -g typescript"})
+
+	var record core.ExecutionRecord
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		t.Fatalf("Failed to decode streamed execution: %v", err)
+	}
+	if record.Tool != core.ToolNPM || record.Command != "npm install -g typescript" {
+		t.Errorf("Expected filtered npm execution, got %+v", record)
+	}
+}
+
+func TestStoreExecutionAppliesIgnoreRules(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Monitoring.EnabledTools = []string{"npm"}
+	cfg.Tools.NPM.IgnoreSubcommands = []string{"run"}
+	cfg.Tools.NPM.IgnorePackages = []string{"left-pad"}
+	cfg.Ignore.Paths = []string{"/tmp"}
+	cfg.Ignore.Users = []string{"runner"}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"run", "build"}})
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"install", "left-pad"}})
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"install", "tsx"}, WorkingDir: "/tmp/scratch"})
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"install", "tsx"}, User: "runner"})
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm", Args: []string{"install", "left-pad", "tsx"}})
+
+	mockStore.mu.RLock()
+	defer mockStore.mu.RUnlock()
+	if len(mockStore.executions) != 1 {
+		t.Fatalf("Expected only the install of tsx stored, got %d executions", len(mockStore.executions))
+	}
+	if got := mockStore.executions[0].PackagesAffected; len(got) != 1 || got[0] != "tsx" {
+		t.Errorf("Expected left-pad left out of the affected packages, got %v", got)
+	}
+}
+
+func TestHandleExecutionBatchAppliesIgnoreRules(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Ignore.Paths = []string{"/tmp"}
+	cfg.Ignore.Commands = []string{"npm run *"}
+	cfg.Ignore.Users = []string{"runner"}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	response := postBatch(t, d, `[
+		{"tool": "npm", "command": "npm install tsx", "working_dir": "/tmp/scratch"},
+		{"tool": "npm", "command": "npm run build"},
+		{"tool": "npm", "command": "npm install tsx", "user": "runner"},
+		{"tool": "npm", "command": "npm install tsx"}
+	]`)
+	if response.Accepted != 1 || response.Skipped != 3 {
+		t.Fatalf("Expected 3 ignored records skipped, got %+v", response)
+	}
+	if got := mockStore.getExecutionCount(); got != 1 {
+		t.Errorf("Expected only the record not ignored stored, got %d", got)
+	}
+}
+
+func TestHandleExecutionBatchAppliesToolIgnoreRules(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Monitoring.EnabledTools = []string{"npm"}
+	cfg.Tools.NPM.IgnoreSubcommands = []string{"run"}
+	cfg.Tools.NPM.IgnorePackages = []string{"left-pad"}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	response := postBatch(t, d, `[
+		{"tool": "npm", "command": "npm", "args": ["run", "build"]},
+		{"tool": "npm", "command": "npm", "args": ["install", "left-pad"]},
+		{"tool": "npm", "command": "npm", "args": ["install", "left-pad", "tsx"]}
+	]`)
+	if response.Accepted != 1 || response.Skipped != 2 || response.Results[2].Status != batchStatusAccepted {
+		t.Fatalf("Expected only the install of tsx accepted, got %+v", response)
+	}
+
+	mockStore.mu.RLock()
+	defer mockStore.mu.RUnlock()
+	if got := mockStore.executions[0].PackagesAffected; len(got) != 1 || got[0] != "tsx" {
+		t.Errorf("Expected left-pad left out of the affected packages, got %v", got)
+	}
+}
+
+func TestHandleExecutionBatchAppliesTrackSubcommands(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Tools.Cargo.TrackSubcommands = []string{"install", "uninstall"}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	response := postBatch(t, d, `[
+		{"tool": "cargo", "command": "cargo", "args": ["build", "--release"]},
+		{"tool": "cargo", "command": "cargo", "args": ["install", "ripgrep"]}
+	]`)
+	if response.Accepted != 1 || response.Skipped != 1 || response.Results[0].Status != batchStatusSkipped {
+		t.Fatalf("Expected the untracked subcommand skipped, got %+v", response)
+	}
+	if got := mockStore.getExecutionCount(); got != 1 {
+		t.Errorf("Expected 1 stored execution, got %d", got)
+	}
+}
+
+func TestHandleExecutionsExitCodeFilters(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+	for i, code := range []int{0, 1, 2} {
+		addMockExecution(t, mockStore, &core.ExecutionRecord{ID: "exec-" + strconv.Itoa(i), Tool: "npm", ExitCode: code, Timestamp: time.Now()})
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"failed=true", http.StatusOK, 2},
+		{"exit_code=2", http.StatusOK, 1},
+		{"exit_code=0", http.StatusOK, 1},
+		{"exit_code=abc", http.StatusBadRequest, 0},
+		{"failed=maybe", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/executions?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		d.handleExecutions(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.wantStatus, w.Code)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var executions []*core.ExecutionRecord
+		decodeRecorderJSON(t, w, &executions)
+		if len(executions) != tt.wantCount {
+			t.Errorf("%s: expected %d executions, got %d", tt.query, tt.wantCount, len(executions))
+		}
+	}
+}
+
+func TestHandleExecutionsDirAndProjectFilters(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+	for i, dir := range []string{"/src/app", "/src/app/web", "/src/api"} {
+		addMockExecution(t, mockStore, &core.ExecutionRecord{ID: "exec-" + strconv.Itoa(i), Tool: "npm", WorkingDir: dir, Timestamp: time.Now()})
+	}
+
+	for query, want := range map[string]int{
+		"dir=/src/app": 2,
+		"dir=/src":     3,
+		"project=api":  1,
+		"project=web":  1,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/executions?"+query, nil)
+		w := httptest.NewRecorder()
+		d.handleExecutions(w, req)
+
+		var executions []*core.ExecutionRecord
+		decodeRecorderJSON(t, w, &executions)
+		if len(executions) != want {
+			t.Errorf("%s: expected %d executions, got %d", query, want, len(executions))
+		}
+	}
+}
+
+func TestHandleExecutionsCommandMatch(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+	for i, command := range []string{"npm install typescript", "npm install eslint", "npm run build"} {
+		addMockExecution(t, mockStore, &core.ExecutionRecord{ID: "exec-" + strconv.Itoa(i), Tool: "npm", Command: command, Timestamp: time.Now()})
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"match=install+.*script", http.StatusOK, 1},
+		{"glob=npm+install+*", http.StatusOK, 2},
+		{"match=(", http.StatusBadRequest, 0},
+		{"match=install&glob=npm*", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/executions?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		d.handleExecutions(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.wantStatus, w.Code)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var executions []*core.ExecutionRecord
+		decodeRecorderJSON(t, w, &executions)
+		if len(executions) != tt.wantCount {
+			t.Errorf("%s: expected %d executions, got %d", tt.query, tt.wantCount, len(executions))
+		}
+	}
+}
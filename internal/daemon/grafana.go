@@ -0,0 +1,258 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// grafanaPath is where the endpoints of Grafana's JSON data source
+// protocol, as the SimpleJSON and Infinity plugins use it, are served
+const grafanaPath = "/api/v1/grafana"
+
+const (
+	maxGrafanaBodyBytes   = 1 << 20
+	maxGrafanaAnnotations = 500
+	// grafanaTargetSeparator splits a target such as executions:npm into
+	// its metric and tool.
+	grafanaTargetSeparator = ":"
+)
+
+var errGrafanaAnnotationLimit = errors.New("annotation limit reached")
+
+// grafanaRange is the dashboard's time range
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange    `json:"range"`
+	IntervalMS    int64           `json:"intervalMs"`
+	MaxDataPoints int             `json:"maxDataPoints"`
+	Targets       []grafanaTarget `json:"targets"`
+}
+
+type grafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+}
+
+// grafanaTimeSeries is one target's points as [value, unix milliseconds]
+type grafanaTimeSeries struct {
+	Target     string     `json:"target"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange           `json:"range"`
+	Annotation map[string]interface{} `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Annotation map[string]interface{} `json:"annotation"`
+	Time       int64                  `json:"time"`
+	Title      string                 `json:"title"`
+	Text       string                 `json:"text"`
+	Tags       []string               `json:"tags"`
+}
+
+// handleGrafana answers the connection test Grafana sends when the data
+// source is saved
+func (d *Daemon) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGrafanaSearch lists the targets a panel can graph: each series
+// metric, alone and for each tool used, that contains the typed text
+func (d *Daemon) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request grafanaSearchRequest
+	if !decodeGrafanaRequest(w, r, &request) {
+		return
+	}
+
+	stats, err := d.storage.GetStatistics()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tools := append([]string(nil), stats.ToolsUsed...)
+	sort.Strings(tools)
+
+	targets := []string{}
+	for _, metric := range []string{seriesMetricExecutions, seriesMetricFailures, seriesMetricDuration} {
+		candidates := []string{metric}
+		for _, tool := range tools {
+			candidates = append(candidates, metric+grafanaTargetSeparator+tool)
+		}
+		for _, target := range candidates {
+			if strings.Contains(target, request.Target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	d.writeGrafanaJSON(w, targets)
+}
+
+// handleGrafanaQuery returns each target as a time series over the
+// dashboard's range, in buckets of the panel's interval, widened when it
+// would give more points than the panel or the series API allows
+func (d *Daemon) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request grafanaQueryRequest
+	if !decodeGrafanaRequest(w, r, &request) {
+		return
+	}
+	if !request.Range.From.Before(request.Range.To) {
+		http.Error(w, "invalid range: from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	interval := grafanaInterval(request)
+	results := make([]grafanaTimeSeries, 0, len(request.Targets))
+	for _, target := range request.Targets {
+		if target.Target == "" {
+			continue
+		}
+		metric, tool, _ := strings.Cut(target.Target, grafanaTargetSeparator)
+		switch metric {
+		case seriesMetricExecutions, seriesMetricFailures, seriesMetricDuration:
+		default:
+			http.Error(w, fmt.Sprintf("invalid target %q: metric must be executions, failures, or duration", target.Target), http.StatusBadRequest)
+			return
+		}
+
+		bounds, err := seriesBuckets(request.Range.From, request.Range.To, interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		series := seriesResponse{Metric: metric, Tool: core.NormalizeToolName(tool)}
+		if err := d.collectSeries(&series, bounds, interval); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result := grafanaTimeSeries{Target: target.Target, Datapoints: make([][2]int64, 0, len(series.Points))}
+		for _, point := range series.Points {
+			result.Datapoints = append(result.Datapoints, [2]int64{point.Value, point.Start.UnixMilli()})
+		}
+		results = append(results, result)
+	}
+	d.writeGrafanaJSON(w, results)
+}
+
+// grafanaInterval is the panel's interval, at least a minute and wide
+// enough that the range fits in the points the panel and the series API
+// allow
+func grafanaInterval(request grafanaQueryRequest) time.Duration {
+	// Aligning the range to bucket edges can add a bucket at either end.
+	limit := time.Duration(maxSeriesPoints - 2)
+	if request.MaxDataPoints > 0 && time.Duration(request.MaxDataPoints) < limit {
+		limit = time.Duration(request.MaxDataPoints)
+	}
+	interval := time.Duration(request.IntervalMS) * time.Millisecond
+	span := request.Range.To.Sub(request.Range.From)
+	if fit := (span + limit - 1) / limit; interval < fit {
+		interval = fit
+	}
+	interval = (interval + time.Minute - 1) / time.Minute * time.Minute
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// handleGrafanaAnnotations marks the executions in the dashboard's range
+// that installed, upgraded, or removed packages, newest first. The
+// annotation's query, when set, names the one tool to mark.
+func (d *Daemon) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request grafanaAnnotationRequest
+	if !decodeGrafanaRequest(w, r, &request) {
+		return
+	}
+
+	opts := storage.QueryOptions{}
+	if !request.Range.From.IsZero() {
+		opts.Since = &request.Range.From
+	}
+	if !request.Range.To.IsZero() {
+		opts.Until = &request.Range.To
+	}
+	if query, _ := request.Annotation["query"].(string); strings.TrimSpace(query) != "" {
+		opts.Tool = core.NormalizeToolName(query)
+	}
+
+	annotations := []grafanaAnnotation{}
+	err := d.storage.StreamExecutions(opts, func(exec *core.ExecutionRecord) error {
+		if len(exec.PackagesAffected) == 0 {
+			return nil
+		}
+		tags := []string{exec.Tool}
+		if action, _ := exec.Metadata["action"].(string); action != "" {
+			tags = append(tags, action)
+		}
+		if exec.ExitCode != 0 {
+			tags = append(tags, "failed")
+		}
+		annotations = append(annotations, grafanaAnnotation{
+			Annotation: request.Annotation,
+			Time:       exec.Timestamp.UnixMilli(),
+			Title:      exec.Command,
+			Text:       strings.Join(exec.PackagesAffected, ", "),
+			Tags:       tags,
+		})
+		if len(annotations) >= maxGrafanaAnnotations {
+			return errGrafanaAnnotationLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGrafanaAnnotationLimit) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.writeGrafanaJSON(w, annotations)
+}
+
+// decodeGrafanaRequest decodes r's JSON body into request, replying with
+// 400 and returning false when it cannot
+func decodeGrafanaRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGrafanaBodyBytes)).Decode(request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (d *Daemon) writeGrafanaJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode Grafana response", "error", err)
+	}
+}
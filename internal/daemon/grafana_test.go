@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestGrafanaEndpoints(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	day1 := time.Date(2026, 3, 2, 9, 15, 0, 0, time.Local)
+	addMockExecution(t, mockStore, &core.ExecutionRecord{
+		Tool: "npm", Command: "npm install tsx", Timestamp: day1, PackagesAffected: []string{"tsx"},
+		Metadata: map[string]interface{}{"action": "install"},
+	})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: day1.Add(time.Hour), ExitCode: 1})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "homebrew", Command: "brew install jq", Timestamp: day1.AddDate(0, 0, 1), PackagesAffected: []string{"jq"}})
+
+	post := func(t *testing.T, handler http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local).UTC().Format(time.RFC3339)
+	to := time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local).UTC().Format(time.RFC3339)
+	dashboard := `"range": {"from": "` + from + `", "to": "` + to + `"}`
+
+	t.Run("connection test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		d.handleGrafana(w, httptest.NewRequest(http.MethodGet, grafanaPath, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		var targets []string
+		decodeRecorderJSON(t, post(t, d.handleGrafanaSearch, grafanaPath+"/search", `{"target": "fail"}`), &targets)
+		want := []string{"failures", "failures:homebrew", "failures:npm"}
+		if !reflect.DeepEqual(targets, want) {
+			t.Errorf("Expected the failure targets, got %v", targets)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		body := `{` + dashboard + `, "intervalMs": 86400000, "maxDataPoints": 100,
+			"targets": [{"target": "executions", "refId": "A"}, {"target": "failures:npm", "refId": "B"}]}`
+		var series []grafanaTimeSeries
+		decodeRecorderJSON(t, post(t, d.handleGrafanaQuery, grafanaPath+"/query", body), &series)
+		if len(series) != 2 {
+			t.Fatalf("Expected two series, got %+v", series)
+		}
+		day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local).UnixMilli()
+		next := time.Date(2026, 3, 3, 0, 0, 0, 0, time.Local).UnixMilli()
+		if want := [][2]int64{{2, day}, {1, next}}; !reflect.DeepEqual(series[0].Datapoints, want) {
+			t.Errorf("Expected daily executions, got %v", series[0].Datapoints)
+		}
+		if want := [][2]int64{{1, day}, {0, next}}; series[1].Target != "failures:npm" || !reflect.DeepEqual(series[1].Datapoints, want) {
+			t.Errorf("Expected npm's failures, got %+v", series[1])
+		}
+	})
+
+	t.Run("query widens the interval to fit the points", func(t *testing.T) {
+		body := `{` + dashboard + `, "intervalMs": 1000, "maxDataPoints": 4, "targets": [{"target": "executions"}]}`
+		var series []grafanaTimeSeries
+		decodeRecorderJSON(t, post(t, d.handleGrafanaQuery, grafanaPath+"/query", body), &series)
+		if len(series) != 1 || len(series[0].Datapoints) > 5 {
+			t.Errorf("Expected at most five points, got %+v", series)
+		}
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		body := `{` + dashboard + `, "annotation": {"name": "installs", "query": "npm"}}`
+		var annotations []grafanaAnnotation
+		decodeRecorderJSON(t, post(t, d.handleGrafanaAnnotations, grafanaPath+"/annotations", body), &annotations)
+		if len(annotations) != 1 || annotations[0].Title != "npm install tsx" || annotations[0].Text != "tsx" ||
+			!reflect.DeepEqual(annotations[0].Tags, []string{"npm", "install"}) || annotations[0].Time != day1.UnixMilli() {
+			t.Errorf("Expected npm's install annotated, got %+v", annotations)
+		}
+		if annotations[0].Annotation["name"] != "installs" {
+			t.Errorf("Expected the annotation echoed, got %+v", annotations[0].Annotation)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		for _, body := range []string{
+			`{`,
+			`{` + dashboard + `, "targets": [{"target": "users"}]}`,
+			`{"range": {"from": "` + to + `", "to": "` + from + `"}, "targets": [{"target": "executions"}]}`,
+		} {
+			if w := post(t, d.handleGrafanaQuery, grafanaPath+"/query", body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+			}
+		}
+	})
+}
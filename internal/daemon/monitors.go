@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/monitors"
+)
+
+// monitorsPath lists the monitors; POST monitorsPath/<tool>/enable or
+// /disable turns one on or off
+const monitorsPath = "/api/v1/monitors"
+
+const (
+	monitorActionEnable  = "enable"
+	monitorActionDisable = "disable"
+)
+
+var errUnknownMonitor = errors.New("no monitor or plugin for tool")
+
+// handleMonitors lists every tool diu can monitor with whether the config
+// enables it and whether its monitor is running
+func (d *Daemon) handleMonitors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.writeMonitorsJSON(w, monitors.Statuses(d.currentConfig(), d.monitorRegistry()))
+}
+
+// handleMonitor enables or disables the monitor of one tool, saving the
+// change to the config file and reloading so it applies at once
+func (d *Daemon) handleMonitor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tool, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, monitorsPath+"/"), "/")
+	if !ok || tool == "" || (action != monitorActionEnable && action != monitorActionDisable) {
+		http.NotFound(w, r)
+		return
+	}
+
+	status, err := d.setMonitorEnabled(tool, action == monitorActionEnable)
+	var configErr *core.ConfigError
+	switch {
+	case errors.Is(err, errUnknownMonitor):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.As(err, &configErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.writeMonitorsJSON(w, status)
+}
+
+// setMonitorEnabled turns tool's monitor on or off in the config file and
+// reloads it, returning the tool's status afterwards
+func (d *Daemon) setMonitorEnabled(tool string, enabled bool) (*core.MonitorStatus, error) {
+	d.configFileMu.Lock()
+	defer d.configFileMu.Unlock()
+
+	tool = core.NormalizeToolName(tool)
+	config, err := d.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if _, err := monitors.ForConfig(config, tool); err != nil {
+		return nil, fmt.Errorf("%w: %s", errUnknownMonitor, tool)
+	}
+	if err := config.SetToolEnabled(tool, enabled); err != nil {
+		return nil, err
+	}
+	if err := core.ValidationError(config.Validate()); err != nil {
+		return nil, err
+	}
+	if err := config.Save(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+
+	for _, status := range monitors.Statuses(d.currentConfig(), d.monitorRegistry()) {
+		if status.Tool == tool {
+			return &status, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errUnknownMonitor, tool)
+}
+
+func (d *Daemon) writeMonitorsJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode monitors response", "error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestMonitorEndpoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin needs a POSIX shell")
+	}
+	cfg := testConfig(t)
+	plugin := filepath.Join(t.TempDir(), "diu-plugin-mise")
+	if err := os.WriteFile(plugin, []byte("#!/bin/sh\necho '{}'\n"), 0o700); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	cfg.Monitoring.Plugins = []core.PluginConfig{{Name: "mise", Path: plugin}}
+	configPath := filepath.Join(cfg.Daemon.DataDir, "config.json")
+	if err := cfg.SaveTo(configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	cfg, err := core.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	defer stopDaemonForTest(t, d)
+
+	post := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		d.handleMonitor(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w
+	}
+	list := func(t *testing.T) map[string]core.MonitorStatus {
+		t.Helper()
+		w := httptest.NewRecorder()
+		d.handleMonitors(w, httptest.NewRequest(http.MethodGet, monitorsPath, nil))
+		var statuses []core.MonitorStatus
+		decodeRecorderJSON(t, w, &statuses)
+		byTool := make(map[string]core.MonitorStatus, len(statuses))
+		for _, status := range statuses {
+			byTool[status.Tool] = status
+		}
+		return byTool
+	}
+
+	if status := list(t)["mise"]; !status.Enabled || !status.Active || status.Plugin != plugin {
+		t.Errorf("Expected the mise plugin enabled and running, got %+v", status)
+	}
+
+	var status core.MonitorStatus
+	decodeRecorderJSON(t, post(t, monitorsPath+"/mise/disable"), &status)
+	if status.Enabled || status.Active {
+		t.Errorf("Expected mise disabled, got %+v", status)
+	}
+	decodeRecorderJSON(t, post(t, monitorsPath+"/mise/enable"), &status)
+	if !status.Enabled || !status.Active {
+		t.Errorf("Expected mise enabled and running again, got %+v", status)
+	}
+	if _, ok := d.monitorRegistry().Get("mise"); !ok {
+		t.Error("Expected the mise monitor registered")
+	}
+
+	decodeRecorderJSON(t, post(t, monitorsPath+"/npm/disable"), &status)
+	saved, err := core.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !saved.ToolDisabled(core.ToolNPM) || !saved.ToolEnabled("mise") {
+		t.Errorf("Expected the changes saved, got tools %+v and plugins %+v", saved.Tools, saved.Monitoring.Plugins)
+	}
+
+	for path, code := range map[string]int{
+		monitorsPath + "/apt/enable":  http.StatusNotFound,
+		monitorsPath + "/npm/restart": http.StatusNotFound,
+		monitorsPath + "/npm":         http.StatusNotFound,
+	} {
+		if w := post(t, path); w.Code != code {
+			t.Errorf("Expected status %d for %s, got %d", code, path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	d.handleMonitor(w, httptest.NewRequest(http.MethodGet, monitorsPath+"/npm/enable", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// notifyState remembers which packages were already reported unused, keyed
+// by threshold and package, with the LastUsed time they were reported at. A
+// package is reported again only after it is used and goes unused again.
+type notifyState struct {
+	Unused map[string]time.Time `json:"unused"`
+}
+
+// runUnusedPackageCheck sends package_unused events for packages that have
+// crossed a subscribed threshold. Thresholds are re-read on every check so
+// reloaded webhooks apply without a restart.
+func (d *Daemon) runUnusedPackageCheck() {
+	defer d.wg.Done()
+	check := func(now time.Time) {
+		if thresholds := d.currentNotifier().UnusedThresholds(); len(thresholds) > 0 {
+			d.notifyUnusedPackages(thresholds, now)
+		}
+	}
+
+	check(time.Now())
+	ticker := time.NewTicker(core.DefaultReportCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			check(now)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Daemon) notifyUnusedPackages(thresholds []int, now time.Time) {
+	path := filepath.Join(d.currentConfig().Daemon.DataDir, core.NotifyStateFileName)
+	state, err := loadNotifyState(path)
+	if err != nil {
+		d.logger.Error("Failed to read notification state", "error", err)
+		return
+	}
+
+	packages, err := d.storage.GetPackages("")
+	if err != nil {
+		d.logger.Error("Failed to get packages for unused check", "error", err)
+		return
+	}
+
+	changed := false
+	for _, pkg := range packages {
+		lastUsed := pkg.LastUsed
+		if lastUsed.IsZero() {
+			lastUsed = pkg.InstallDate
+		}
+		if lastUsed.IsZero() {
+			continue
+		}
+
+		for _, days := range thresholds {
+			key := fmt.Sprintf("%d:%s/%s", days, pkg.Tool, pkg.Name)
+			if now.Sub(lastUsed) < time.Duration(days)*24*time.Hour {
+				if _, ok := state.Unused[key]; ok {
+					delete(state.Unused, key)
+					changed = true
+				}
+				continue
+			}
+			if reported, ok := state.Unused[key]; ok && reported.Equal(lastUsed) {
+				continue
+			}
+
+			err := d.currentNotifier().Notify(d.ctx, notify.Event{
+				Type:       notify.EventPackageUnused,
+				Time:       now,
+				Tool:       pkg.Tool,
+				Package:    pkg.Name,
+				LastUsed:   lastUsed,
+				UnusedDays: days,
+			})
+			if err != nil {
+				d.logger.Error("Failed to send unused package notification", "package", pkg.Name, "error", err)
+				continue
+			}
+			state.Unused[key] = lastUsed
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := saveNotifyState(path, state); err != nil {
+			d.logger.Error("Failed to save notification state", "error", err)
+		}
+	}
+}
+
+func loadNotifyState(path string) (*notifyState, error) {
+	state := &notifyState{Unused: make(map[string]time.Time)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Unused == nil {
+		state.Unused = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+func saveNotifyState(path string, state *notifyState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
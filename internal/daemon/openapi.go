@@ -0,0 +1,397 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+)
+
+const openAPIVersion = "3.0.3"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// openAPIComponents lists the Go types published under components/schemas.
+// Schemas are derived from struct fields and json tags so the document stays
+// in step with the types the handlers actually encode.
+var openAPIComponents = map[string]reflect.Type{
+	"ExecutionRecord":          reflect.TypeOf(core.ExecutionRecord{}),
+	"PackageInfo":              reflect.TypeOf(core.PackageInfo{}),
+	"StorageStatistics":        reflect.TypeOf(core.StorageStatistics{}),
+	"BatchResponse":            reflect.TypeOf(batchResponse{}),
+	"BatchRecordResult":        reflect.TypeOf(batchRecordResult{}),
+	"StatsResponse":            reflect.TypeOf(statsResponse{}),
+	"StatsGroup":               reflect.TypeOf(statsGroup{}),
+	"ProjectSummary":           reflect.TypeOf(projectSummary{}),
+	"SeriesResponse":           reflect.TypeOf(seriesResponse{}),
+	"SeriesPoint":              reflect.TypeOf(seriesPoint{}),
+	"GrafanaSearchRequest":     reflect.TypeOf(grafanaSearchRequest{}),
+	"GrafanaQueryRequest":      reflect.TypeOf(grafanaQueryRequest{}),
+	"GrafanaTimeSeries":        reflect.TypeOf(grafanaTimeSeries{}),
+	"GrafanaAnnotationRequest": reflect.TypeOf(grafanaAnnotationRequest{}),
+	"GrafanaAnnotation":        reflect.TypeOf(grafanaAnnotation{}),
+	"MonitorStatus":            reflect.TypeOf(core.MonitorStatus{}),
+	"PauseState":               reflect.TypeOf(core.PauseState{}),
+	"HealthStatus":             reflect.TypeOf(core.HealthStatus{}),
+	"SummaryResponse":          reflect.TypeOf(summaryResponse{}),
+	"SummaryExecution":         reflect.TypeOf(summaryExecution{}),
+	"SyncRequest":              reflect.TypeOf(fleet.Request{}),
+	"SyncResponse":             reflect.TypeOf(fleet.Response{}),
+	"SyncStats":                reflect.TypeOf(fleet.Stats{}),
+}
+
+func (d *Daemon) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildOpenAPIDocument()); err != nil {
+		d.logger.Warn("Failed to encode OpenAPI response", "error", err)
+	}
+}
+
+func buildOpenAPIDocument() map[string]interface{} {
+	schemas := make(map[string]interface{}, len(openAPIComponents))
+	for name, typ := range openAPIComponents {
+		schemas[name] = schemaForType(typ)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "DIU Local API",
+			"version":     core.Version,
+			"description": "Local API exposed by the DIU daemon for recorded package manager executions.",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": "/api/v1"},
+		},
+		"paths":      openAPIPaths(),
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func openAPIPaths() map[string]interface{} {
+	return map[string]interface{}{
+		"/executions": map[string]interface{}{
+			"get": openAPIOperation("List recorded executions", []interface{}{
+				queryParameter("tool", "string", "Filter by tool name"),
+				queryParameter("package", "string", "Filter by affected package"),
+				queryParameter("exit_code", "integer", "Only executions that exited with this code"),
+				queryParameter("failed", "boolean", "Only executions with a non-zero exit code"),
+				queryParameter("dir", "string", "Only executions run in this directory or below it"),
+				queryParameter("project", "string", "Only executions in this project, named by its nearest package manifest"),
+				queryParameter("session", "string", "Only executions from this shell session"),
+				queryParameter("host", "string", "Only executions recorded on this hostname or machine ID"),
+				queryParameter("match", "string", "Only executions whose command matches this regular expression"),
+				queryParameter("glob", "string", "Only executions whose whole command matches this glob; cannot be combined with match"),
+				queryParameter("limit", "integer", "Maximum number of results"),
+			}, arraySchema(schemaRef("ExecutionRecord"))),
+			"post": map[string]interface{}{
+				"summary":     "Record a single execution",
+				"requestBody": jsonRequestBody(schemaRef("ExecutionRecord")),
+				"responses": map[string]interface{}{
+					"202": map[string]interface{}{"description": "Accepted"},
+					"400": map[string]interface{}{"description": "Invalid record"},
+					"503": map[string]interface{}{"description": "Event queue full or daemon stopping"},
+				},
+			},
+		},
+		"/executions/batch": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary": "Record many executions as a JSON array or NDJSON",
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json":     map[string]interface{}{"schema": arraySchema(schemaRef("ExecutionRecord"))},
+						"application/x-ndjson": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Per-record results", schemaRef("BatchResponse")),
+					"400": map[string]interface{}{"description": "Invalid batch"},
+				},
+			},
+		},
+		"/executions/stream": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Stream executions as NDJSON as they are recorded",
+				"parameters": []interface{}{
+					queryParameter("tool", "string", "Filter by tool name"),
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "One ExecutionRecord per line",
+						"content": map[string]interface{}{
+							"application/x-ndjson": map[string]interface{}{"schema": schemaRef("ExecutionRecord")},
+						},
+					},
+				},
+			},
+		},
+		"/packages": map[string]interface{}{
+			"get": openAPIOperation("List tracked packages", []interface{}{
+				queryParameter("tool", "string", "Filter by tool name"),
+				queryParameter("unused_for", "string", "Only packages not used within this duration (e.g. 30d)"),
+				queryParameter("sort", "string", "Sort by usage_count or last_used (default tool, then name)"),
+				queryParameter("order", "string", "Sort order, asc or desc (default desc)"),
+				queryParameter("limit", "integer", "Maximum number of results"),
+			}, arraySchema(schemaRef("PackageInfo"))),
+		},
+		"/stats": map[string]interface{}{
+			"get": openAPIOperation("Get usage statistics", []interface{}{
+				queryParameter("since", "string", "Only count executions at or after this RFC 3339 time or date"),
+				queryParameter("until", "string", "Only count executions at or before this RFC 3339 time, or through the end of this date"),
+				queryParameter("group_by", "string", "Aggregate by tool, day, package, hour, or weekday"),
+				queryParameter("tool", "string", "Filter by tool name when computing aggregates"),
+			}, map[string]interface{}{
+				"oneOf": []interface{}{schemaRef("StorageStatistics"), schemaRef("StatsResponse")},
+			}),
+		},
+		"/series": map[string]interface{}{
+			"get": openAPIOperation("Count executions or sum their durations in time buckets for charting", []interface{}{
+				queryParameter("metric", "string", "executions, failures, or duration in milliseconds (default executions)"),
+				queryParameter("interval", "string", "Bucket length such as 1h, 1d, or 1w (default 1d); whole days start at local midnight"),
+				queryParameter("since", "string", "Start of the first bucket, an RFC 3339 time or date (default 30 intervals before until)"),
+				queryParameter("until", "string", "End of the series, an RFC 3339 time or the end of a date (default now)"),
+				queryParameter("tool", "string", "Filter by tool name"),
+			}, schemaRef("SeriesResponse")),
+		},
+		"/grafana": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":   "Grafana JSON data source connection test",
+				"responses": map[string]interface{}{"200": map[string]interface{}{"description": "OK"}},
+			},
+		},
+		"/grafana/search": map[string]interface{}{
+			"post": grafanaOperation("List the metric targets Grafana can query, such as executions or failures:npm",
+				"GrafanaSearchRequest", arraySchema(map[string]interface{}{"type": "string"})),
+		},
+		"/grafana/query": map[string]interface{}{
+			"post": grafanaOperation("Return targets as Grafana time series over the dashboard range",
+				"GrafanaQueryRequest", arraySchema(schemaRef("GrafanaTimeSeries"))),
+		},
+		"/grafana/annotations": map[string]interface{}{
+			"post": grafanaOperation("Annotate executions that changed packages, for one tool when the annotation query names it",
+				"GrafanaAnnotationRequest", arraySchema(schemaRef("GrafanaAnnotation"))),
+		},
+		"/projects": map[string]interface{}{
+			"get": openAPIOperation("Summarize the tools and packages each project uses", []interface{}{
+				queryParameter("since", "string", "Only count executions at or after this RFC 3339 time or date"),
+				queryParameter("until", "string", "Only count executions at or before this RFC 3339 time, or through the end of this date"),
+				queryParameter("tool", "string", "Filter by tool name"),
+				queryParameter("project", "string", "Only summarize this project"),
+				queryParameter("top", "integer", "Most used packages listed per project (default 10, 0 for all)"),
+			}, arraySchema(schemaRef("ProjectSummary"))),
+		},
+		"/sync": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Merge executions pushed by another machine, skipping IDs already stored",
+				"requestBody": jsonRequestBody(schemaRef("SyncRequest")),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Merge counts and aggregate stats across machines", schemaRef("SyncResponse")),
+					"400": map[string]interface{}{"description": "Invalid sync request"},
+				},
+			},
+		},
+		"/monitors": map[string]interface{}{
+			"get": openAPIOperation("List the tools diu can monitor, whether each is enabled, and whether its monitor is running",
+				nil, arraySchema(schemaRef("MonitorStatus"))),
+		},
+		"/monitors/{tool}/enable": map[string]interface{}{
+			"post": monitorOperation("Enable a tool's monitor in the config file and start it"),
+		},
+		"/monitors/{tool}/disable": map[string]interface{}{
+			"post": monitorOperation("Disable a tool's monitor in the config file and stop it"),
+		},
+		"/pause": map[string]interface{}{
+			"get": openAPIOperation("Get whether recording is paused", nil, schemaRef("PauseState")),
+			"post": openAPIOperation("Pause recording, dropping incoming executions", []interface{}{
+				queryParameter("duration", "string", "Resume after this duration, such as 30m or 2h; without it recording stays paused until resumed"),
+			}, schemaRef("PauseState")),
+		},
+		"/resume": map[string]interface{}{
+			"post": openAPIOperation("Resume recording", nil, schemaRef("PauseState")),
+		},
+		"/health": map[string]interface{}{
+			"get": openAPIOperation("Get daemon health", nil, schemaRef("HealthStatus")),
+		},
+		"/summary": map[string]interface{}{
+			"get": openAPIOperation("Get today's counts, the last execution, the unused package count, and daemon status for menu-bar apps", []interface{}{
+				queryParameter("unused_for", "string", "Count packages unused for this duration, such as 30d (default 90d)"),
+			}, schemaRef("SummaryResponse")),
+		},
+		"/reload": map[string]interface{}{
+			"post": openAPIOperation("Reload configuration and monitors", nil, map[string]interface{}{"type": "object"}),
+		},
+		"/openapi.json": map[string]interface{}{
+			"get": openAPIOperation("Get this OpenAPI document", nil, map[string]interface{}{"type": "object"}),
+		},
+	}
+}
+
+func openAPIOperation(summary string, parameters []interface{}, response map[string]interface{}) map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary": summary,
+		"responses": map[string]interface{}{
+			"200": jsonResponse("OK", response),
+		},
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+// grafanaOperation describes a Grafana JSON data source endpoint, which
+// takes a JSON body
+func grafanaOperation(summary, request string, response map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"summary":     summary,
+		"requestBody": jsonRequestBody(schemaRef(request)),
+		"responses": map[string]interface{}{
+			"200": jsonResponse("OK", response),
+			"400": map[string]interface{}{"description": "Invalid request"},
+		},
+	}
+}
+
+// monitorOperation describes enabling or disabling the monitor of the tool
+// in the path
+func monitorOperation(summary string) map[string]interface{} {
+	return map[string]interface{}{
+		"summary": summary,
+		"parameters": []interface{}{map[string]interface{}{
+			"name":        "tool",
+			"in":          "path",
+			"required":    true,
+			"description": "Tool name, such as npm or a plugin's name",
+			"schema":      map[string]interface{}{"type": "string"},
+		}},
+		"responses": map[string]interface{}{
+			"200": jsonResponse("The tool's monitor after the config reloaded", schemaRef("MonitorStatus")),
+			"400": map[string]interface{}{"description": "The change would make the config invalid"},
+			"404": map[string]interface{}{"description": "No built-in monitor or plugin for the tool"},
+		},
+	}
+}
+
+func queryParameter(name, schemaType, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]interface{}{"type": schemaType},
+	}
+}
+
+func jsonRequestBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func arraySchema(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+// schemaForType converts a Go type into an OpenAPI schema. Types already
+// published as components are referenced rather than inlined.
+func schemaForType(typ reflect.Type) map[string]interface{} {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in milliseconds"}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return arraySchema(fieldSchema(typ.Elem()))
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": fieldSchema(typ.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		return structSchema(typ)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func fieldSchema(typ reflect.Type) map[string]interface{} {
+	base := typ
+	if base.Kind() == reflect.Pointer {
+		base = base.Elem()
+	}
+	for name, component := range openAPIComponents {
+		if component == base {
+			return schemaRef(name)
+		}
+	}
+	return schemaForType(typ)
+}
+
+func structSchema(typ reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = fieldSchema(field.Type)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
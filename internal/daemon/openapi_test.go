@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleOpenAPI(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	d.handleOpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var document map[string]interface{}
+	decodeRecorderJSON(t, w, &document)
+
+	if document["openapi"] != openAPIVersion {
+		t.Errorf("Expected openapi %s, got %v", openAPIVersion, document["openapi"])
+	}
+
+	paths, ok := document["paths"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected paths object")
+	}
+	for _, path := range []string{"/executions", "/executions/batch", "/packages", "/stats", "/projects", "/health", "/summary", "/monitors", "/monitors/{tool}/enable", "/pause", "/resume"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("Expected path %s in document", path)
+		}
+	}
+
+	components := document["components"].(map[string]interface{})
+	schemas := components["schemas"].(map[string]interface{})
+	record, ok := schemas["ExecutionRecord"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected ExecutionRecord schema")
+	}
+	properties := record["properties"].(map[string]interface{})
+	duration := properties["duration_ms"].(map[string]interface{})
+	if duration["type"] != "integer" {
+		t.Errorf("Expected duration_ms to be an integer, got %v", duration["type"])
+	}
+	timestamp := properties["timestamp"].(map[string]interface{})
+	if timestamp["format"] != "date-time" {
+		t.Errorf("Expected timestamp to be date-time, got %v", timestamp["format"])
+	}
+}
+
+func TestHandleOpenAPIMethodNotAllowed(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	d.handleOpenAPI(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// pausePath reports the pause state on GET and pauses recording on POST,
+// for the duration query parameter when given; posting resumePath resumes
+const (
+	pausePath  = "/api/v1/pause"
+	resumePath = "/api/v1/resume"
+)
+
+// pauseState reads the pause state from the data directory on each call,
+// so a pause made by diu pause while the daemon runs applies at once. An
+// unreadable state is logged and does not pause recording.
+func (d *Daemon) pauseState() core.PauseState {
+	state, err := core.LoadPauseState(d.currentConfig().Daemon.DataDir, time.Now())
+	if err != nil {
+		d.logger.Warn("Failed to read pause state", "error", err)
+	}
+	return state
+}
+
+func (d *Daemon) handlePause(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		d.writePauseJSON(w, d.pauseState())
+	case http.MethodPost:
+		var duration time.Duration
+		if value := r.URL.Query().Get("duration"); value != "" {
+			parsed, err := core.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("invalid duration %q", value), http.StatusBadRequest)
+				return
+			}
+			duration = parsed
+		}
+		state, err := core.Pause(d.currentConfig().Daemon.DataDir, duration, time.Now())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to pause recording: %v", err), http.StatusInternalServerError)
+			return
+		}
+		d.logger.Info("Recording paused", "until", state.Until)
+		d.writePauseJSON(w, state)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *Daemon) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := core.Resume(d.currentConfig().Daemon.DataDir); err != nil {
+		http.Error(w, fmt.Sprintf("failed to resume recording: %v", err), http.StatusInternalServerError)
+		return
+	}
+	d.logger.Info("Recording resumed")
+	d.writePauseJSON(w, core.PauseState{})
+}
+
+func (d *Daemon) writePauseJSON(w http.ResponseWriter, state core.PauseState) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		d.logger.Warn("Failed to encode pause response", "error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestPauseEndpoints(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	var state core.PauseState
+	w := httptest.NewRecorder()
+	d.handlePause(w, httptest.NewRequest(http.MethodPost, pausePath+"?duration=30m", nil))
+	decodeRecorderJSON(t, w, &state)
+	if !state.Paused || state.Until.IsZero() {
+		t.Fatalf("Expected recording paused for 30 minutes, got %+v", state)
+	}
+
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm install tsx"})
+	if len(mockStore.executions) != 0 {
+		t.Errorf("Expected executions dropped while paused, got %d", len(mockStore.executions))
+	}
+	if health := d.healthStatus(); health.Pause == nil || !health.Pause.Until.Equal(state.Until) {
+		t.Errorf("Expected health to report the pause, got %+v", health.Pause)
+	}
+
+	w = httptest.NewRecorder()
+	d.handleResume(w, httptest.NewRequest(http.MethodPost, resumePath, nil))
+	decodeRecorderJSON(t, w, &state)
+	if state.Paused {
+		t.Errorf("Expected recording resumed, got %+v", state)
+	}
+	d.storeExecution(&core.ExecutionRecord{Tool: "npm", Command: "npm install tsx"})
+	if len(mockStore.executions) != 1 {
+		t.Errorf("Expected the execution stored after resuming, got %d", len(mockStore.executions))
+	}
+
+	w = httptest.NewRecorder()
+	d.handlePause(w, httptest.NewRequest(http.MethodGet, pausePath, nil))
+	decodeRecorderJSON(t, w, &state)
+	if state.Paused || d.healthStatus().Pause != nil {
+		t.Errorf("Expected recording not paused, got %+v", state)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		handler      http.HandlerFunc
+		code         int
+	}{
+		{http.MethodPost, pausePath + "?duration=soon", d.handlePause, http.StatusBadRequest},
+		{http.MethodPost, pausePath + "?duration=-1h", d.handlePause, http.StatusBadRequest},
+		{http.MethodDelete, pausePath, d.handlePause, http.StatusMethodNotAllowed},
+		{http.MethodGet, resumePath, d.handleResume, http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		tc.handler(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+}
+
+func TestExecutionBatchSkippedWhilePaused(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+	if _, err := core.Pause(cfg.Daemon.DataDir, 0, time.Now()); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	updates := d.stream.subscribe()
+	defer d.stream.unsubscribe(updates)
+
+	response := postBatch(t, d, `[{"tool": "npm", "command": "npm install tsx"}, {"tool": ""}]`)
+	if response.Skipped != 1 || response.Rejected != 1 || response.Accepted != 0 {
+		t.Fatalf("Expected 1 skipped and 1 rejected, got %+v", response)
+	}
+	if response.Results[0].Status != batchStatusSkipped {
+		t.Errorf("Expected record 0 to be skipped, got %+v", response.Results[0])
+	}
+	if len(mockStore.executions) != 0 || len(updates) != 0 {
+		t.Errorf("Expected nothing stored or published while paused, got %d stored and %d published", len(mockStore.executions), len(updates))
+	}
+}
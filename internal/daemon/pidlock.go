@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// errLockHeld is returned by tryLockFile when another process holds the lock.
+var errLockHeld = errors.New("lock held by another process")
+
+// AlreadyRunningError reports that another daemon holds the PID lock.
+type AlreadyRunningError struct {
+	// PID is the process holding the lock, or 0 if it could not be read.
+	PID int
+}
+
+func (e *AlreadyRunningError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("daemon already running with PID %d", e.PID)
+	}
+	return "daemon already running"
+}
+
+// pidLockPath returns the lock file that guards the PID file. A dedicated
+// file is used because Windows byte-range locks would also block readers of
+// the PID file itself.
+func pidLockPath(pidFile string) string {
+	return pidFile + ".lock"
+}
+
+// acquirePIDLock takes the daemon's exclusive lock and writes the PID file,
+// returning the held lock file. NewDaemon takes it before opening storage or
+// the log file, so a second daemon fails here instead of replaying the
+// journal or restoring a backup under the running one, or racing past
+// IsRunning and binding the same socket.
+func acquirePIDLock(pidFile string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(pidFile), core.OwnerDirectoryMode); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := safefs.OpenFile(pidLockPath(pidFile), os.O_CREATE|os.O_RDWR, core.PrivateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PID lock: %w", err)
+	}
+	if err := tryLockFile(lockFile); err != nil {
+		_ = lockFile.Close()
+		if errors.Is(err, errLockHeld) {
+			pid, _ := readPIDFile(pidFile)
+			return nil, &AlreadyRunningError{PID: pid}
+		}
+		return nil, fmt.Errorf("failed to lock PID file: %w", err)
+	}
+
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), core.PrivateFileMode); err != nil {
+		_ = unlockFile(lockFile)
+		_ = lockFile.Close()
+		return nil, err
+	}
+	return lockFile, nil
+}
+
+// abandonPIDLock releases a lock taken by a NewDaemon call that then failed.
+func abandonPIDLock(pidFile string, lockFile *os.File) {
+	_ = os.Remove(pidFile)
+	_ = unlockFile(lockFile)
+	_ = lockFile.Close()
+}
+
+// releasePIDLock removes the PID file and drops the lock. The lock file
+// itself is left in place: removing it would let a new daemon lock a fresh
+// file while another still waits on the old one.
+func (d *Daemon) releasePIDLock() {
+	if err := os.Remove(d.currentConfig().Daemon.PIDFile); err != nil && !os.IsNotExist(err) {
+		d.logger.Error("Error removing PID file", "error", err)
+	}
+	if d.pidLock == nil {
+		return
+	}
+	if err := unlockFile(d.pidLock); err != nil {
+		d.logger.Error("Error unlocking PID file", "error", err)
+	}
+	if err := d.pidLock.Close(); err != nil {
+		d.logger.Error("Error closing PID lock", "error", err)
+	}
+	d.pidLock = nil
+}
+
+// LockHolder reports whether a daemon holds the PID lock and, if so, the PID
+// recorded in the PID file.
+func LockHolder(config *core.Config) (int, bool) {
+	lockFile, err := safefs.OpenFile(pidLockPath(config.Daemon.PIDFile), os.O_RDWR, core.PrivateFileMode)
+	if err != nil {
+		return 0, false
+	}
+	defer lockFile.Close()
+
+	if err := tryLockFile(lockFile); err != nil {
+		if !errors.Is(err, errLockHeld) {
+			return 0, false
+		}
+		pid, _ := readPIDFile(config.Daemon.PIDFile)
+		return pid, true
+	}
+	_ = unlockFile(lockFile)
+	return 0, false
+}
+
+func readPIDFile(path string) (int, error) {
+	pidBytes, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+}
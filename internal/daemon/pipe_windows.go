@@ -0,0 +1,214 @@
+//go:build windows
+
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	pipePrefix     = `\\.\pipe\`
+	pipeBufferSize = 64 * 1024
+	// pipeSecurityDescriptor limits the pipe to its owner and LocalSystem.
+	pipeSecurityDescriptor = "D:P(A;;GA;;;OW)(A;;GA;;;SY)"
+)
+
+// pipeName maps the configured socket path onto a named pipe. Explicit pipe
+// paths are used as-is; anything else gets a stable per-path pipe name so
+// different data directories do not collide.
+func pipeName(socketPath string) string {
+	if strings.HasPrefix(strings.ToLower(socketPath), pipePrefix) {
+		return socketPath
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(socketPath)))
+	return pipePrefix + "diu-" + hex.EncodeToString(sum[:8])
+}
+
+// listenLocal creates the named pipe wrappers send execution records to.
+func listenLocal(socketPath string) (net.Listener, error) {
+	sd, err := windows.SecurityDescriptorFromString(pipeSecurityDescriptor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pipe security descriptor: %w", err)
+	}
+	listener := &pipeListener{
+		name: pipeName(socketPath),
+		sa: &windows.SecurityAttributes{
+			Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+			SecurityDescriptor: sd,
+		},
+	}
+
+	handle, err := listener.createInstance(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe %s: %w", listener.name, err)
+	}
+	listener.next = handle
+	return listener, nil
+}
+
+// pipeListener accepts connections on a named pipe. Each accepted client
+// gets its own pipe instance; a fresh instance is created before Accept
+// returns so clients never see the pipe missing.
+type pipeListener struct {
+	name string
+	sa   *windows.SecurityAttributes
+
+	mu     sync.Mutex
+	next   windows.Handle
+	closed bool
+}
+
+func (l *pipeListener) createInstance(first bool) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	openMode := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		openMode |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	pipeMode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(name, openMode, pipeMode, windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	handle := l.next
+	l.mu.Unlock()
+
+	err := windows.ConnectNamedPipe(handle, nil)
+	if err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		windows.CloseHandle(handle)
+		return nil, l.acceptError(err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		// The connection was Close waking us up.
+		windows.CloseHandle(handle)
+		return nil, net.ErrClosed
+	}
+	next, err := l.createInstance(false)
+	if err != nil {
+		l.closed = true
+		windows.CloseHandle(handle)
+		return nil, fmt.Errorf("failed to create pipe instance: %w", err)
+	}
+	l.next = next
+
+	return newPipeConn(handle, l.name), nil
+}
+
+func (l *pipeListener) acceptError(err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return net.ErrClosed
+	}
+	return err
+}
+
+// Close stops the listener. Accept blocks in ConnectNamedPipe, so Close
+// connects to the pipe itself to release it.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return err
+	}
+	client, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil
+	}
+	return windows.CloseHandle(client)
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.name)
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is a server-side pipe instance. Pipe handles opened without
+// FILE_FLAG_OVERLAPPED cannot use the runtime poller, so read deadlines are
+// enforced by cancelling the pending read.
+type pipeConn struct {
+	*os.File
+	handle windows.Handle
+	addr   pipeAddr
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func newPipeConn(handle windows.Handle, name string) *pipeConn {
+	return &pipeConn{
+		File:   os.NewFile(uintptr(handle), name),
+		handle: handle,
+		addr:   pipeAddr(name),
+	}
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if t.IsZero() {
+		return nil
+	}
+	c.timer = time.AfterFunc(time.Until(t), func() {
+		_ = windows.CancelIoEx(c.handle, nil)
+	})
+	return nil
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+	_ = windows.DisconnectNamedPipe(c.handle)
+	return c.File.Close()
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package daemon
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code GetExitCodeProcess reports for a running
+// process (STILL_ACTIVE).
+const stillActive = 259
+
+// daemonSignals are the console events Go delivers on Windows. There is no
+// SIGHUP equivalent, so reloads go through POST /api/v1/reload.
+var daemonSignals = []os.Signal{os.Interrupt, windows.SIGTERM}
+
+func isReloadSignal(sig os.Signal) bool {
+	return false
+}
+
+// removeLocalSocket is a no-op on Windows: named pipes disappear with the
+// last open handle.
+func removeLocalSocket(socketPath string) error {
+	return nil
+}
+
+func diskFreeBytes(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
+
+func processAlive(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(handle)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/report"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// reportState records when each summary was last sent so restarting the
+// daemon does not resend it.
+type reportState struct {
+	LastSent map[string]time.Time `json:"last_sent"`
+}
+
+// runReportScheduler delivers the enabled summaries once per period, by
+// email and to subscribed chats. The first summary goes out one period
+// after reports are enabled. Periods are re-read on every check so reloaded
+// reporting settings apply without a restart.
+func (d *Daemon) runReportScheduler() {
+	defer d.wg.Done()
+	check := func(now time.Time) {
+		if periods := d.reportPeriods(); len(periods) > 0 {
+			d.sendDueReports(periods, now)
+		}
+	}
+
+	check(time.Now())
+	ticker := time.NewTicker(core.DefaultReportCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			check(now)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// reportPeriods returns the periods with at least one delivery target.
+func (d *Daemon) reportPeriods() []string {
+	var periods []string
+	for _, period := range []string{report.PeriodDaily, report.PeriodWeekly} {
+		if d.emailsReport(period) || d.currentNotifier().Subscribed(summaryEvent(period)) {
+			periods = append(periods, period)
+		}
+	}
+	return periods
+}
+
+func (d *Daemon) emailsReport(period string) bool {
+	reporting := d.currentConfig().Reporting
+	if !reporting.EmailReports {
+		return false
+	}
+	if period == report.PeriodWeekly {
+		return reporting.WeeklySummary
+	}
+	return reporting.DailySummary
+}
+
+func summaryEvent(period string) string {
+	if period == report.PeriodWeekly {
+		return notify.EventWeeklySummary
+	}
+	return notify.EventDailySummary
+}
+
+// sendDueReports sends each summary whose period has elapsed since it was
+// last sent, recording successful sends in the report state file.
+func (d *Daemon) sendDueReports(periods []string, now time.Time) {
+	path := reportStatePath(d.currentConfig())
+	state, err := loadReportState(path)
+	if err != nil {
+		d.logger.Error("Failed to read report state", "error", err)
+		return
+	}
+
+	changed := false
+	for _, period := range periods {
+		last, ok := state.LastSent[period]
+		if !ok {
+			state.LastSent[period] = now
+			changed = true
+			continue
+		}
+		length, err := report.PeriodLength(period)
+		if err != nil || now.Sub(last) < length {
+			continue
+		}
+
+		summary, err := report.Generate(d.storage, period, now)
+		if err != nil {
+			d.logger.Error("Failed to generate report", "period", period, "error", err)
+			continue
+		}
+		if !d.deliverReport(summary) {
+			continue
+		}
+		d.logger.Info("Sent report", "period", period, "executions", summary.TotalExecutions)
+		state.LastSent[period] = now
+		changed = true
+	}
+
+	if changed {
+		if err := saveReportState(path, state); err != nil {
+			d.logger.Error("Failed to save report state", "error", err)
+		}
+	}
+}
+
+// deliverReport sends summary to its targets and reports whether any of
+// them received it.
+func (d *Daemon) deliverReport(summary *report.Report) bool {
+	delivered := false
+	if d.emailsReport(summary.Period) {
+		if err := d.sendReport(d.currentConfig().Reporting.SMTP, summary); err != nil {
+			d.logger.Error("Failed to email report", "period", summary.Period, "error", err)
+		} else {
+			delivered = true
+		}
+	}
+
+	event := summaryEvent(summary.Period)
+	if d.currentNotifier().Subscribed(event) {
+		err := d.currentNotifier().Notify(d.ctx, notify.Event{
+			Type:    event,
+			Time:    summary.Until,
+			Summary: summary.Text(),
+		})
+		if err != nil {
+			d.logger.Error("Failed to post report", "period", summary.Period, "error", err)
+		} else {
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+func reportStatePath(config *core.Config) string {
+	return filepath.Join(config.Daemon.DataDir, core.ReportStateFileName)
+}
+
+func loadReportState(path string) (*reportState, error) {
+	state := &reportState{LastSent: make(map[string]time.Time)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.LastSent == nil {
+		state.LastSent = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+func saveReportState(path string, state *reportState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
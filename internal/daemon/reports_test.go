@@ -0,0 +1,299 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/report"
+)
+
+func TestSendDueReportsWaitsOnePeriodThenSends(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Reporting = core.ReportingConfig{EmailReports: true, DailySummary: true, WeeklySummary: true}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	var sent []string
+	d.sendReport = func(config core.SMTPConfig, summary *report.Report) error {
+		sent = append(sent, summary.Period)
+		return nil
+	}
+
+	periods := []string{report.PeriodDaily, report.PeriodWeekly}
+	start := time.Now()
+	d.sendDueReports(periods, start)
+	if len(sent) != 0 {
+		t.Fatalf("Expected no reports on first run, sent %v", sent)
+	}
+
+	d.sendDueReports(periods, start.Add(25*time.Hour))
+	if len(sent) != 1 || sent[0] != report.PeriodDaily {
+		t.Fatalf("Expected daily report after a day, sent %v", sent)
+	}
+
+	d.sendDueReports(periods, start.Add(26*time.Hour))
+	if len(sent) != 1 {
+		t.Fatalf("Expected daily report not to repeat within a day, sent %v", sent)
+	}
+
+	d.sendDueReports(periods, start.Add(8*24*time.Hour))
+	if len(sent) != 3 {
+		t.Fatalf("Expected daily and weekly reports after a week, sent %v", sent)
+	}
+
+	state, err := loadReportState(reportStatePath(cfg))
+	if err != nil {
+		t.Fatalf("loadReportState failed: %v", err)
+	}
+	if !state.LastSent[report.PeriodWeekly].Equal(start.Add(8 * 24 * time.Hour)) {
+		t.Fatalf("Expected weekly send recorded, got %v", state.LastSent)
+	}
+}
+
+func TestSendDueReportsRetriesAfterFailure(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Reporting = core.ReportingConfig{EmailReports: true, DailySummary: true}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	attempts := 0
+	d.sendReport = func(config core.SMTPConfig, summary *report.Report) error {
+		attempts++
+		return errors.New("connection refused")
+	}
+
+	periods := []string{report.PeriodDaily}
+	start := time.Now()
+	d.sendDueReports(periods, start)
+	d.sendDueReports(periods, start.Add(25*time.Hour))
+	d.sendDueReports(periods, start.Add(26*time.Hour))
+	if attempts != 2 {
+		t.Fatalf("Expected a retry on the next check after a failure, got %d attempts", attempts)
+	}
+}
+
+func TestReportPeriods(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Reporting = core.ReportingConfig{EmailReports: true, WeeklySummary: true}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	periods := d.reportPeriods()
+	if len(periods) != 1 || periods[0] != report.PeriodWeekly {
+		t.Fatalf("Expected only weekly period, got %v", periods)
+	}
+
+	d.config.Reporting.EmailReports = false
+	if periods := d.reportPeriods(); len(periods) != 0 {
+		t.Fatalf("Expected no periods without delivery targets, got %v", periods)
+	}
+}
+
+func TestStoreExecutionNotifiesNewPackages(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		messages = append(messages, body["text"])
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Chat = []core.ChatConfig{
+		{Service: notify.ServiceSlack, URL: server.URL, Events: []string{notify.EventPackageInstalled}},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mock := newMockStorage()
+	mock.packages["npm"] = []*core.PackageInfo{{Name: "eslint", Tool: "npm"}}
+	d.storage = mock
+
+	d.storeExecution(&core.ExecutionRecord{
+		Tool:             "npm",
+		Command:          "npm install -g eslint typescript",
+		PackagesAffected: []string{"eslint", "typescript"},
+	})
+	d.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) != 1 || messages[0] != "New npm package installed: typescript" {
+		t.Fatalf("Expected one new package notification, got %v", messages)
+	}
+}
+
+func TestHandleExecutionBatchNotifiesNewPackages(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		messages = append(messages, body["text"])
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Chat = []core.ChatConfig{
+		{Service: notify.ServiceSlack, URL: server.URL, Events: []string{notify.EventPackageInstalled}},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mock := newMockStorage()
+	mock.packages["npm"] = []*core.PackageInfo{{Name: "eslint", Tool: "npm"}}
+	d.storage = mock
+
+	response := postBatch(t, d, `[
+		{"tool": "npm", "command": "npm install -g eslint typescript", "packages_affected": ["eslint", "typescript"]},
+		{"tool": "npm", "command": "npm install -g typescript", "packages_affected": ["typescript"]}
+	]`)
+	if response.Accepted != 2 {
+		t.Fatalf("Expected 2 accepted records, got %+v", response)
+	}
+	d.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) != 1 || messages[0] != "New npm package installed: typescript" {
+		t.Fatalf("Expected one new package notification, got %v", messages)
+	}
+}
+
+func TestStoreExecutionSkipsLocalInstallsForGlobalOnlyRules(t *testing.T) {
+	var mu sync.Mutex
+	var packages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		packages = append(packages, body["package"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Rules = []core.NotificationRuleConfig{
+		{Event: notify.EventPackageInstalled, Tools: []string{"npm"}, GlobalOnly: true, WebhookURL: server.URL},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	d.storeExecution(&core.ExecutionRecord{
+		Tool:             "npm",
+		Command:          "npm install left-pad",
+		PackagesAffected: []string{"left-pad"},
+		Metadata:         map[string]interface{}{"global": false},
+	})
+	d.storeExecution(&core.ExecutionRecord{
+		Tool:             "npm",
+		Command:          "npm install -g typescript",
+		PackagesAffected: []string{"typescript"},
+		Metadata:         map[string]interface{}{"global": true},
+	})
+	d.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(packages) != 1 || packages[0] != "typescript" {
+		t.Fatalf("Expected only the global install posted, got %v", packages)
+	}
+}
+
+func TestDeliverReportPostsSummaryToChat(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Chat = []core.ChatConfig{
+		{Service: notify.ServiceDiscord, URL: server.URL, Events: []string{notify.EventWeeklySummary}},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	if periods := d.reportPeriods(); len(periods) != 1 || periods[0] != report.PeriodWeekly {
+		t.Fatalf("Expected weekly period from chat subscription, got %v", periods)
+	}
+	summary := &report.Report{Period: report.PeriodWeekly, Until: time.Now(), TotalExecutions: 7}
+	if !d.deliverReport(summary) {
+		t.Fatal("Expected report delivered to chat")
+	}
+	if !strings.Contains(body["content"], "Executions: 7") {
+		t.Fatalf("Expected summary in Discord message, got %v", body)
+	}
+}
+
+func TestNotifyUnusedPackagesReportsOnce(t *testing.T) {
+	var mu sync.Mutex
+	var unused []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		unused = append(unused, body["package"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Webhooks = []core.WebhookConfig{
+		{URL: server.URL, Events: []string{notify.EventPackageUnused}, UnusedDays: 30},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	now := time.Now()
+	mock := newMockStorage()
+	mock.packages["homebrew"] = []*core.PackageInfo{
+		{Name: "jq", Tool: "homebrew", LastUsed: now.Add(-40 * 24 * time.Hour)},
+		{Name: "wget", Tool: "homebrew", LastUsed: now.Add(-time.Hour)},
+	}
+	d.storage = mock
+
+	thresholds := d.notifier.UnusedThresholds()
+	d.notifyUnusedPackages(thresholds, now)
+	d.notifyUnusedPackages(thresholds, now.Add(time.Hour))
+	if len(unused) != 1 || unused[0] != "jq" {
+		t.Fatalf("Expected jq reported once, got %v", unused)
+	}
+
+	mock.packages["homebrew"][0].LastUsed = now.Add(time.Hour)
+	d.notifyUnusedPackages(thresholds, now.Add(2*time.Hour))
+	mock.packages["homebrew"][0].LastUsed = now.Add(-31 * 24 * time.Hour)
+	d.notifyUnusedPackages(thresholds, now.Add(3*time.Hour))
+	if len(unused) != 2 {
+		t.Fatalf("Expected jq reported again after going unused again, got %v", unused)
+	}
+}
@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// Metrics accepted by /api/v1/series
+const (
+	seriesMetricExecutions = "executions"
+	seriesMetricFailures   = "failures"
+	seriesMetricDuration   = "duration"
+
+	seriesUnitCount        = "count"
+	seriesUnitMilliseconds = "ms"
+
+	defaultSeriesInterval = "1d"
+	// defaultSeriesPoints is how many intervals a series without since
+	// covers, and maxSeriesPoints the most one may have.
+	defaultSeriesPoints = 30
+	maxSeriesPoints     = 5000
+)
+
+// seriesResponse is the executions in consecutive intervals from Since up
+// to Until, every interval listed even when nothing ran in it
+type seriesResponse struct {
+	Metric   string        `json:"metric"`
+	Unit     string        `json:"unit"`
+	Interval string        `json:"interval"`
+	Tool     string        `json:"tool,omitempty"`
+	Since    time.Time     `json:"since"`
+	Until    time.Time     `json:"until"`
+	Points   []seriesPoint `json:"points"`
+}
+
+// seriesPoint is one interval's value: a count of executions, or their
+// summed duration in milliseconds
+type seriesPoint struct {
+	Start time.Time `json:"start"`
+	Value int64     `json:"value"`
+}
+
+// handleSeries returns a metric in time buckets for charting. Intervals of
+// whole days start at local midnight and are counted from the daily
+// aggregates when the metric allows; shorter ones are counted from the
+// executions.
+func (d *Daemon) handleSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	response := seriesResponse{
+		Metric:   query.Get("metric"),
+		Interval: query.Get("interval"),
+		Tool:     core.NormalizeToolName(query.Get("tool")),
+	}
+	if response.Metric == "" {
+		response.Metric = seriesMetricExecutions
+	}
+	switch response.Metric {
+	case seriesMetricExecutions, seriesMetricFailures:
+		response.Unit = seriesUnitCount
+	case seriesMetricDuration:
+		response.Unit = seriesUnitMilliseconds
+	default:
+		http.Error(w, "invalid metric: must be executions, failures, or duration", http.StatusBadRequest)
+		return
+	}
+	if response.Interval == "" {
+		response.Interval = defaultSeriesInterval
+	}
+	interval, err := core.ParseDuration(response.Interval)
+	if err != nil || interval < time.Minute {
+		http.Error(w, "invalid interval: expected a duration of at least 1m such as 1h or 1d", http.StatusBadRequest)
+		return
+	}
+
+	until := time.Now()
+	if parsed, err := parseUntilParam(query.Get("until")); err != nil {
+		http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if parsed != nil {
+		until = *parsed
+	}
+	since := until.Add(-defaultSeriesPoints * interval)
+	if parsed, err := parseTimeParam(query.Get("since")); err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if parsed != nil {
+		since = *parsed
+	}
+	if !since.Before(until) {
+		http.Error(w, "invalid range: since must be before until", http.StatusBadRequest)
+		return
+	}
+
+	bounds, err := seriesBuckets(since, until, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := d.collectSeries(&response, bounds, interval); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode series response", "error", err)
+	}
+}
+
+// seriesBuckets returns the start of every interval covering since up to
+// until, followed by the end of the last. Whole-day intervals start at
+// local midnight and step by calendar days, so they stay aligned across
+// daylight saving changes; shorter ones are counted from the midnight
+// before since.
+func seriesBuckets(since, until time.Time, interval time.Duration) ([]time.Time, error) {
+	days := 0
+	if interval%(24*time.Hour) == 0 {
+		days = int(interval / (24 * time.Hour))
+	}
+	next := func(t time.Time) time.Time {
+		if days > 0 {
+			return t.AddDate(0, 0, days)
+		}
+		return t.Add(interval)
+	}
+
+	local := since.Local()
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	if days == 0 {
+		start = start.Add(since.Sub(start) / interval * interval)
+	}
+	starts := []time.Time{start}
+	for starts[len(starts)-1].Before(until) {
+		if len(starts) > maxSeriesPoints {
+			return nil, fmt.Errorf("invalid range: more than %d intervals; use a longer interval or a shorter range", maxSeriesPoints)
+		}
+		starts = append(starts, next(starts[len(starts)-1]))
+	}
+	return starts, nil
+}
+
+// collectSeries sets response's points to the intervals between bounds,
+// as seriesBuckets returns them, and their values
+func (d *Daemon) collectSeries(response *seriesResponse, bounds []time.Time, interval time.Duration) error {
+	response.Since, response.Until = bounds[0], bounds[len(bounds)-1]
+	response.Points = make([]seriesPoint, len(bounds)-1)
+	for i := range response.Points {
+		response.Points[i].Start = bounds[i]
+	}
+	return d.fillSeries(response, bounds[:len(bounds)-1], interval%(24*time.Hour) == 0)
+}
+
+// fillSeries sets the value of each point, whose intervals begin at
+// starts. Whole-day intervals are summed from per-day totals, which storage
+// keeps for execution counts.
+func (d *Daemon) fillSeries(response *seriesResponse, starts []time.Time, wholeDays bool) error {
+	opts := storage.AggregateOptions{
+		Query: storage.QueryOptions{
+			Tool:       response.Tool,
+			Since:      &response.Since,
+			Until:      &response.Until,
+			FailedOnly: response.Metric == seriesMetricFailures,
+		},
+		GroupBy:   storage.GroupByDay,
+		Durations: response.Metric == seriesMetricDuration,
+	}
+	add := func(at time.Time, count int, duration time.Duration) {
+		if at.Before(response.Since) || !at.Before(response.Until) {
+			return
+		}
+		i := sort.Search(len(starts), func(i int) bool { return starts[i].After(at) }) - 1
+		if response.Metric == seriesMetricDuration {
+			response.Points[i].Value += duration.Milliseconds()
+		} else {
+			response.Points[i].Value += int64(count)
+		}
+	}
+
+	if !wholeDays {
+		return d.storage.StreamExecutions(opts.Query, func(exec *core.ExecutionRecord) error {
+			add(exec.Timestamp, 1, exec.Duration)
+			return nil
+		})
+	}
+	result, err := d.storage.Aggregate(opts)
+	if err != nil {
+		return err
+	}
+	for _, group := range result.Groups {
+		day, err := time.ParseInLocation(time.DateOnly, group.Key, time.Local)
+		if err != nil {
+			continue
+		}
+		add(day, group.Count, group.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestHandleSeries(t *testing.T) {
+	cfg := testConfig(t)
+
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+
+	mockStore := newMockStorage()
+	d.storage = mockStore
+
+	day1 := time.Date(2026, 3, 2, 9, 15, 0, 0, time.Local)
+	day3 := day1.AddDate(0, 0, 2)
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day1, Duration: 2 * time.Second})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day1.Add(30 * time.Minute), Duration: time.Second, ExitCode: 1})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "npm", Timestamp: day3, Duration: 4 * time.Second})
+	addMockExecution(t, mockStore, &core.ExecutionRecord{Tool: "homebrew", Timestamp: day3})
+
+	get := func(t *testing.T, query string) seriesResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/series?"+query, nil)
+		w := httptest.NewRecorder()
+		d.handleSeries(w, req)
+		var response seriesResponse
+		decodeRecorderJSON(t, w, &response)
+		return response
+	}
+	values := func(points []seriesPoint) []int64 {
+		var values []int64
+		for _, point := range points {
+			values = append(values, point.Value)
+		}
+		return values
+	}
+
+	t.Run("daily executions", func(t *testing.T) {
+		response := get(t, "tool=npm&interval=1d&since=2026-03-02&until=2026-03-04")
+		if got := values(response.Points); len(got) != 3 || got[0] != 2 || got[1] != 0 || got[2] != 1 {
+			t.Errorf("Expected npm executions of 2, 0, and 1 per day, got %v", got)
+		}
+		if start := response.Points[1].Start.Local(); start.Hour() != 0 || start.Day() != 3 {
+			t.Errorf("Expected days to start at midnight, got %v", response.Points[1].Start)
+		}
+		if response.Unit != seriesUnitCount || response.Metric != seriesMetricExecutions {
+			t.Errorf("Expected an execution count, got %+v", response)
+		}
+	})
+
+	t.Run("hourly durations", func(t *testing.T) {
+		hour := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+		since := url.QueryEscape(hour.Format(time.RFC3339))
+		until := url.QueryEscape(hour.Add(2 * time.Hour).Format(time.RFC3339))
+		response := get(t, "metric=duration&interval=1h&since="+since+"&until="+until)
+		if got := values(response.Points); len(got) != 2 || got[0] != 3000 || got[1] != 0 {
+			t.Errorf("Expected 3000ms in the first hour, got %v", got)
+		}
+		if response.Unit != seriesUnitMilliseconds {
+			t.Errorf("Expected milliseconds, got %q", response.Unit)
+		}
+	})
+
+	t.Run("failures over two days", func(t *testing.T) {
+		response := get(t, "metric=failures&interval=2d&since=2026-03-02&until=2026-03-05")
+		if got := values(response.Points); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+			t.Errorf("Expected one failure in the first two days, got %v", got)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"metric=users", "interval=soon", "interval=1s", "since=2026-03-05&until=2026-03-02", "interval=1m&since=2020-01-01"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/series?"+query, nil)
+			w := httptest.NewRecorder()
+
+			d.handleSeries(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+			}
+		}
+	})
+}
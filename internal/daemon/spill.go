@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// errSpillFull is returned once the spill file reaches its size limit
+var errSpillFull = errors.New("event spill file is full")
+
+// maxSpillLineBytes bounds one spilled record when it is read back
+const maxSpillLineBytes = 4 << 20
+
+// eventSpill holds the events that arrive while the event queue is full,
+// one JSON record per line, until processEvents catches up and replays
+// them. The file survives a restart, so spilled events are not lost when
+// the daemon stops first.
+type eventSpill struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	size     int64
+	// waiting counts the events spilled and not yet being replayed, for
+	// health and to tell processEvents whether there is anything to replay.
+	waiting atomic.Int64
+	// ready is signalled when an event is spilled.
+	ready chan struct{}
+}
+
+func newEventSpill(path string, maxBytes int64) *eventSpill {
+	s := &eventSpill{path: path, maxBytes: maxBytes, ready: make(chan struct{}, 1)}
+	for _, name := range []string{path, s.replayingPath()} {
+		if data, err := safefs.ReadFile(name); err == nil {
+			if name == path {
+				s.size = int64(len(data))
+			}
+			s.waiting.Add(int64(countSpilled(data)))
+		}
+	}
+	return s
+}
+
+// countSpilled counts the records in spill file data, one per non-blank line
+func countSpilled(data []byte) int {
+	count := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// replayingPath names the file spilled events are moved to while they are
+// replayed, so events spilled meanwhile start a new file
+func (s *eventSpill) replayingPath() string {
+	return s.path + ".replaying"
+}
+
+// add appends record to the spill file and syncs it
+func (s *eventSpill) add(record *core.ExecutionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode spilled event: %w", err)
+	}
+	// Start on a new line in case the last write was cut short.
+	line = append(append([]byte("\n"), line...), '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBytes > 0 && s.size+int64(len(line)) > s.maxBytes {
+		return errSpillFull
+	}
+	file, err := safefs.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, core.PrivateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open event spill file: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write event spill file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync event spill file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close event spill file: %w", err)
+	}
+	s.size += int64(len(line))
+	s.waiting.Add(1)
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// replay calls fn with each spilled event in the order they were spilled,
+// finishing a replay cut short by a crash first. fn must cope with seeing
+// an event twice, as storage does by ID.
+func (s *eventSpill) replay(fn func(*core.ExecutionRecord)) error {
+	if err := s.replayFile(fn); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if err := os.Rename(s.path, s.replayingPath()); err != nil && !os.IsNotExist(err) {
+		s.mu.Unlock()
+		return fmt.Errorf("failed to move event spill file: %w", err)
+	}
+	s.size = 0
+	s.waiting.Store(0)
+	s.mu.Unlock()
+
+	return s.replayFile(fn)
+}
+
+// replayFile calls fn with each event in the replaying file and removes it
+func (s *eventSpill) replayFile(fn func(*core.ExecutionRecord)) error {
+	data, err := safefs.ReadFile(s.replayingPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read event spill file: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpillLineBytes)
+	for scanner.Scan() {
+		var record core.ExecutionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A blank line, or one cut short by a crash mid-write.
+			continue
+		}
+		fn(&record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event spill file: %w", err)
+	}
+	if err := os.Remove(s.replayingPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove event spill file: %w", err)
+	}
+	return nil
+}
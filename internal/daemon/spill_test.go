@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestEnqueueSpillsWhenQueueFullAndReplays(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	d.storage = mockStore
+	d.eventChan = make(chan *core.ExecutionRecord, 2)
+
+	for _, command := range []string{"npm ci", "npm test", "npm run build", "npm run lint"} {
+		if !d.enqueue(&core.ExecutionRecord{Tool: "npm", Command: command}) {
+			t.Fatalf("Expected %q accepted", command)
+		}
+	}
+	health := d.healthStatus()
+	if health.EventQueue.Length != 2 || health.EventQueue.Spilled != 2 || health.EventQueue.Dropped != 0 {
+		t.Errorf("Expected two events queued and two spilled, got %+v", health.EventQueue)
+	}
+	spillPath := filepath.Join(cfg.Daemon.DataDir, core.EventSpillFileName)
+	if _, err := os.Stat(spillPath); err != nil {
+		t.Fatalf("Expected a spill file: %v", err)
+	}
+
+	d.wg.Add(1)
+	go d.processEvents()
+	deadline := time.Now().Add(5 * time.Second)
+	for mockStore.getExecutionCount() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected spilled events replayed, got %d stored", mockStore.getExecutionCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.cancel()
+	d.wg.Wait()
+
+	if spilled := d.healthStatus().EventQueue.Spilled; spilled != 0 {
+		t.Errorf("Expected nothing left spilled, got %d", spilled)
+	}
+	for _, path := range []string{spillPath, spillPath + ".replaying"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed after replay, got %v", path, err)
+		}
+	}
+}
+
+func TestSpilledEventsSurviveRestart(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.eventChan = make(chan *core.ExecutionRecord)
+	if !d.enqueue(&core.ExecutionRecord{Tool: "npm", Command: "npm ci"}) {
+		t.Fatal("Expected the event spilled")
+	}
+
+	// The spill limit is reached: the event is dropped and counted.
+	d.spill.maxBytes = 1
+	if d.enqueue(&core.ExecutionRecord{Tool: "npm", Command: "npm test"}) {
+		t.Fatal("Expected the event dropped once the spill file is full")
+	}
+	if dropped := d.healthStatus().EventQueue.Dropped; dropped != 1 {
+		t.Errorf("Expected one dropped event, got %d", dropped)
+	}
+
+	// The daemon exits without draining its queue, releasing its lock
+	d.releasePIDLock()
+	restarted, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	mockStore := newMockStorage()
+	restarted.storage = mockStore
+	if spilled := restarted.healthStatus().EventQueue.Spilled; spilled != 1 {
+		t.Errorf("Expected the spilled event found on start, got %d", spilled)
+	}
+	restarted.wg.Add(1)
+	go restarted.processEvents()
+	restarted.cancel()
+	restarted.wg.Wait()
+	if got := mockStore.getExecutionCount(); got != 1 {
+		t.Errorf("Expected the spilled event stored on start, got %d", got)
+	}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// streamSubscriberBuffer is how many executions a slow stream client may lag
+// behind before further executions are dropped for that client.
+const streamSubscriberBuffer = 64
+
+// executionBroadcaster fans stored executions out to live stream clients.
+// Publishing never blocks event processing; a subscriber whose buffer is full
+// misses the execution.
+type executionBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan *core.ExecutionRecord]struct{}
+}
+
+func newExecutionBroadcaster() *executionBroadcaster {
+	return &executionBroadcaster{
+		subscribers: make(map[chan *core.ExecutionRecord]struct{}),
+	}
+}
+
+func (b *executionBroadcaster) subscribe() chan *core.ExecutionRecord {
+	ch := make(chan *core.ExecutionRecord, streamSubscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *executionBroadcaster) unsubscribe(ch chan *core.ExecutionRecord) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+func (b *executionBroadcaster) publish(record *core.ExecutionRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		copied := *record
+		select {
+		case ch <- &copied:
+		default:
+		}
+	}
+}
+
+// handleExecutionStream streams executions as newline-delimited JSON as they
+// are stored, until the client disconnects or the daemon stops.
+func (d *Daemon) handleExecutionStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tool := core.NormalizeToolName(r.URL.Query().Get("tool"))
+
+	// The server's write timeout would otherwise end the stream.
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		d.logger.Debug("Failed to clear stream write deadline", "error", err)
+	}
+
+	updates := d.stream.subscribe()
+	defer d.stream.unsubscribe(updates)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		d.logger.Warn("Failed to flush execution stream", "error", err)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case record := <-updates:
+			if tool != "" && record.Tool != tool {
+				continue
+			}
+			if err := encoder.Encode(record); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	summaryPath = "/api/v1/summary"
+
+	// defaultSummaryUnusedFor is how long a package goes unused before
+	// /api/v1/summary counts it, matching diu prune.
+	defaultSummaryUnusedFor = 90 * 24 * time.Hour
+)
+
+// summaryResponse is the compact status polled by menu-bar and tray apps:
+// small enough to fetch every few seconds.
+type summaryResponse struct {
+	Status          string            `json:"status"`
+	Paused          bool              `json:"paused"`
+	PausedUntil     *time.Time        `json:"paused_until,omitempty"`
+	Version         string            `json:"version"`
+	Uptime          string            `json:"uptime"`
+	TodayExecutions int               `json:"today_executions"`
+	TodayFailures   int               `json:"today_failures"`
+	TodayByTool     map[string]int    `json:"today_by_tool"`
+	LastExecution   *summaryExecution `json:"last_execution,omitempty"`
+	TrackedPackages int               `json:"tracked_packages"`
+	UnusedPackages  int               `json:"unused_packages"`
+	UnusedDays      int               `json:"unused_days"`
+}
+
+// summaryExecution is the most recent execution in a summaryResponse.
+type summaryExecution struct {
+	Tool      string    `json:"tool"`
+	Command   string    `json:"command"`
+	Timestamp time.Time `json:"timestamp"`
+	ExitCode  int       `json:"exit_code"`
+}
+
+func (d *Daemon) handleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	unusedFor := defaultSummaryUnusedFor
+	if value := r.URL.Query().Get("unused_for"); value != "" {
+		duration, err := core.ParseDuration(value)
+		if err != nil || duration < 0 {
+			http.Error(w, "invalid unused_for", http.StatusBadRequest)
+			return
+		}
+		unusedFor = duration
+	}
+
+	summary, err := d.summary(time.Now(), unusedFor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		d.logger.Warn("Failed to encode summary response", "error", err)
+	}
+}
+
+// summary counts today's executions from the daily counters, fetches only
+// the latest execution, and counts packages unused for unusedFor.
+func (d *Daemon) summary(now time.Time, unusedFor time.Duration) (*summaryResponse, error) {
+	health := d.healthStatus()
+	summary := &summaryResponse{
+		Status:      health.Status,
+		Version:     health.Version,
+		Uptime:      health.Uptime,
+		TodayByTool: make(map[string]int),
+		UnusedDays:  int(unusedFor / (24 * time.Hour)),
+	}
+	if health.Pause != nil {
+		summary.Paused = true
+		if !health.Pause.Until.IsZero() {
+			summary.PausedUntil = &health.Pause.Until
+		}
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	today, err := d.storage.Aggregate(storage.AggregateOptions{
+		Query:   storage.QueryOptions{Since: &midnight},
+		GroupBy: storage.GroupByTool,
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary.TodayExecutions = today.Total.Count
+	for _, group := range today.Groups {
+		summary.TodayByTool[group.Key] = group.Count
+	}
+	failures, err := d.storage.Aggregate(storage.AggregateOptions{
+		Query: storage.QueryOptions{Since: &midnight, FailedOnly: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary.TodayFailures = failures.Total.Count
+
+	latest, err := d.storage.GetExecutions(storage.QueryOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) > 0 {
+		summary.LastExecution = &summaryExecution{
+			Tool:      latest[0].Tool,
+			Command:   latest[0].Command,
+			Timestamp: latest[0].Timestamp,
+			ExitCode:  latest[0].ExitCode,
+		}
+	}
+
+	packages, err := d.storage.GetPackages("")
+	if err != nil {
+		return nil, err
+	}
+	summary.TrackedPackages = len(packages)
+	cutoff := now.Add(-unusedFor)
+	for _, pkg := range packages {
+		if pkg.LastUsed.IsZero() || pkg.LastUsed.Before(cutoff) {
+			summary.UnusedPackages++
+		}
+	}
+	return summary, nil
+}
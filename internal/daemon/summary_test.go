@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestHandleSummary(t *testing.T) {
+	d, err := NewDaemon(testConfig(t))
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	mock := newMockStorage()
+	// Storage returns executions newest first.
+	mock.executions = []*core.ExecutionRecord{
+		{Tool: "homebrew", Command: "brew install jq", Timestamp: midnight.Add(2 * time.Minute)},
+		{Tool: "npm", Command: "npm test", Timestamp: midnight.Add(time.Minute), ExitCode: 1},
+		{Tool: "npm", Command: "npm install -g yo", Timestamp: midnight.Add(-time.Hour)},
+	}
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "npm", Name: "yo", LastUsed: now.Add(-40 * 24 * time.Hour)})
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "homebrew", Name: "jq", LastUsed: now})
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "homebrew", Name: "wget"})
+	d.storage = mock
+
+	req := httptest.NewRequest(http.MethodGet, summaryPath+"?unused_for=30d", nil)
+	w := httptest.NewRecorder()
+	d.handleSummary(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var summary summaryResponse
+	decodeRecorderJSON(t, w, &summary)
+	if summary.Status != core.HealthStatusHealthy || summary.Paused {
+		t.Errorf("Unexpected status: %+v", summary)
+	}
+	if summary.TodayExecutions != 2 || summary.TodayFailures != 1 || summary.TodayByTool["npm"] != 1 || summary.TodayByTool["homebrew"] != 1 {
+		t.Errorf("Unexpected today counts: %+v", summary)
+	}
+	if summary.LastExecution == nil || summary.LastExecution.Command != "brew install jq" {
+		t.Errorf("Expected brew install jq as the last execution, got %+v", summary.LastExecution)
+	}
+	if summary.TrackedPackages != 3 || summary.UnusedPackages != 2 || summary.UnusedDays != 30 {
+		t.Errorf("Unexpected package counts: %+v", summary)
+	}
+
+	w = httptest.NewRecorder()
+	d.handleSummary(w, httptest.NewRequest(http.MethodGet, summaryPath+"?unused_for=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid unused_for, got %d", w.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+)
+
+// handleSync merges executions pushed by another machine's diu sync.
+// Records are matched by ID, so repeated pushes insert nothing new, and keep
+// the host, project, and session of the machine that recorded them. They
+// are admitted like local executions, so ones this daemon would drop, for
+// example while paused, are skipped. When sync.token is set, pushes must
+// carry it as a bearer token.
+func (d *Daemon) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if token := d.currentConfig().Sync.AuthToken(); token != "" {
+		given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(given)), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var request fleet.Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExecutionBatchBodyBytes)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid sync request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(request.Executions) > maxExecutionBatchRecords {
+		http.Error(w, fmt.Sprintf("sync exceeds %d records", maxExecutionBatchRecords), http.StatusBadRequest)
+		return
+	}
+
+	var response fleet.Response
+	accepted, rejected := request.Accepted()
+	response.Rejected = rejected
+
+	admitted := make([]*core.ExecutionRecord, 0, len(accepted))
+	for _, record := range accepted {
+		if d.admitExecution(record) {
+			admitted = append(admitted, record)
+		} else {
+			response.Skipped++
+		}
+	}
+	if len(admitted) > 0 {
+		result, err := d.storage.ImportRecords(admitted, nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to store executions: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response.Inserted = result.ExecutionsInserted
+		response.Skipped += result.ExecutionsSkipped
+	}
+	d.logger.Info("Synced executions", "host", request.Host, "inserted", response.Inserted,
+		"skipped", response.Skipped, "rejected", response.Rejected)
+
+	stats, err := fleet.Aggregate(d.storage, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Stats = stats
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		d.logger.Warn("Failed to encode sync response", "error", err)
+	}
+}
+
+// runScheduledSync pushes to sync.remote every sync.interval. Both are
+// re-read on every tick so a reloaded config applies without a restart.
+func (d *Daemon) runScheduledSync() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(core.DefaultSyncCheckInterval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case now := <-ticker.C:
+			config := d.currentConfig()
+			if config.Sync.Remote == "" || config.Sync.Interval <= 0 || now.Sub(last) < config.Sync.Interval {
+				continue
+			}
+			last = now
+			result, err := fleet.Sync(d.ctx, config, d.storage, config.Sync.Remote, false)
+			if err != nil {
+				d.logger.Error("Scheduled sync failed", "remote", config.Sync.Remote, "error", err)
+				continue
+			}
+			d.logger.Info("Synced with remote", "remote", result.Remote, "pushed", result.Pushed, "inserted", result.Inserted)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+func TestSyncMergesMachinesByRecordID(t *testing.T) {
+	central, err := NewDaemon(testConfig(t))
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	centralStore, err := storage.NewJSONStorage(central.currentConfig())
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer func() { _ = centralStore.Close() }()
+	central.storage = centralStore
+	server := httptest.NewServer(http.HandlerFunc(central.handleSync))
+	defer server.Close()
+
+	push := func(host string, tools ...string) *fleet.Result {
+		t.Helper()
+		cfg := testConfig(t)
+		store, err := storage.NewJSONStorage(cfg)
+		if err != nil {
+			t.Fatalf("NewJSONStorage failed: %v", err)
+		}
+		defer func() { _ = store.Close() }()
+		for _, tool := range tools {
+			if err := store.AddExecution(&core.ExecutionRecord{Tool: tool, Command: tool + " install x", Host: host, Timestamp: time.Now()}); err != nil {
+				t.Fatalf("AddExecution failed: %v", err)
+			}
+		}
+
+		result, err := fleet.Sync(context.Background(), cfg, store, server.URL, false)
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		again, err := fleet.Sync(context.Background(), cfg, store, server.URL, true)
+		if err != nil {
+			t.Fatalf("full re-sync failed: %v", err)
+		}
+		if again.Inserted != 0 || again.Skipped != len(tools) {
+			t.Errorf("Expected re-sync to skip all %d records, got %+v", len(tools), again)
+		}
+		return result
+	}
+
+	if result := push("laptop", core.ToolHomebrew, core.ToolNPM); result.Inserted != 2 {
+		t.Errorf("Expected 2 inserted from laptop, got %+v", result)
+	}
+	result := push("devbox", core.ToolNPM)
+	if result.Inserted != 1 {
+		t.Errorf("Expected 1 inserted from devbox, got %+v", result)
+	}
+
+	stats := result.Stats
+	if stats.TotalExecutions != 3 || stats.Hosts["laptop"] != 2 || stats.Hosts["devbox"] != 1 || stats.Tools[core.ToolNPM] != 2 {
+		t.Errorf("Unexpected aggregate stats: %+v", stats)
+	}
+}
+
+func TestHandleSyncRejectsInvalidRecords(t *testing.T) {
+	d, err := NewDaemon(testConfig(t))
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+
+	body := `{"host": "laptop", "executions": [{"id": "a", "tool": "npm"}, {"tool": "npm", "command": "npm ls"}]}`
+	w := httptest.NewRecorder()
+	d.handleSync(w, httptest.NewRequest(http.MethodPost, fleet.Path, strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response fleet.Response
+	decodeRecorderJSON(t, w, &response)
+	if response.Rejected != 2 {
+		t.Errorf("Expected records without a command or ID to be rejected, got %+v", response)
+	}
+
+	w = httptest.NewRecorder()
+	d.handleSync(w, httptest.NewRequest(http.MethodGet, fleet.Path, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestSyncSavesStatsPerRemote(t *testing.T) {
+	cfg := testConfig(t)
+	d, err := NewDaemon(testConfig(t))
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	d.storage = newMockStorage()
+	server := httptest.NewServer(http.HandlerFunc(d.handleSync))
+	defer server.Close()
+
+	if _, err := fleet.Sync(context.Background(), cfg, newMockStorage(), server.URL, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	last, stats, err := fleet.LastSync(cfg, server.URL)
+	if err != nil {
+		t.Fatalf("LastSync failed: %v", err)
+	}
+	if last.IsZero() || stats == nil {
+		t.Errorf("Expected the sync to be saved, got %v %+v", last, stats)
+	}
+	if last, _, _ := fleet.LastSync(cfg, "http://elsewhere:8081"); !last.IsZero() {
+		t.Errorf("Expected no sync saved for another remote, got %v", last)
+	}
+}
+
+func TestHandleSyncAdmitsRecordsAndChecksToken(t *testing.T) {
+	t.Setenv(core.SyncTokenEnv, "")
+	cfg := testConfig(t)
+	cfg.Sync.Token = "diu_secret"
+	if err := cfg.SetToolEnabled(core.ToolNPM, false); err != nil {
+		t.Fatalf("SetToolEnabled failed: %v", err)
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	store, err := storage.NewJSONStorage(cfg)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	d.storage = store
+
+	body := `{"host": "laptop", "executions": [{"id": "a", "tool": "npm", "command": "npm install -g tsx"}, {"id": "b", "tool": "brew", "command": "brew install jq"}]}`
+	sync := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fleet.Path, strings.NewReader(body))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		d.handleSync(w, req)
+		return w
+	}
+
+	for _, authorization := range []string{"", "Bearer wrong"} {
+		if w := sync(authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for %q, got %d", authorization, w.Code)
+		}
+	}
+
+	w := sync("Bearer diu_secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response fleet.Response
+	decodeRecorderJSON(t, w, &response)
+	if response.Inserted != 1 || response.Skipped != 1 {
+		t.Errorf("Expected the disabled tool's record skipped, got %+v", response)
+	}
+	if executions, _ := store.GetExecutions(storage.QueryOptions{}); len(executions) != 1 || executions[0].ID != "b" {
+		t.Errorf("Expected only the homebrew execution stored, got %+v", executions)
+	}
+}
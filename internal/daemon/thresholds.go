@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/notify"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// installActions are the actions, or Homebrew subcommands, of executions
+// that install the packages they affect
+var installActions = []string{"install", "add", "pip_install", "tool_install", "self_add"}
+
+// thresholdState records when each threshold last alerted, keyed by
+// thresholdKey, so a limit that stays exceeded alerts once per window and
+// restarting the daemon does not alert again.
+type thresholdState struct {
+	Alerted map[string]time.Time `json:"alerted"`
+}
+
+// runThresholdCheck evaluates reporting.thresholds at startup and then
+// every core.DefaultThresholdInterval. Thresholds are re-read on every
+// check so reloaded settings apply without a restart.
+func (d *Daemon) runThresholdCheck() {
+	defer d.wg.Done()
+	check := func(now time.Time) {
+		if len(d.currentConfig().Reporting.Thresholds) > 0 {
+			d.checkThresholds(now)
+		}
+	}
+
+	check(time.Now())
+	ticker := time.NewTicker(core.DefaultThresholdInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			check(now)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkThresholds alerts for each threshold whose metric exceeds its max.
+// A windowed threshold alerts again only once its window has passed since
+// the last alert; tracked_packages alerts again only after the count drops
+// back within the limit.
+func (d *Daemon) checkThresholds(now time.Time) {
+	config := d.currentConfig()
+	path := filepath.Join(config.Daemon.DataDir, core.ThresholdStateFileName)
+	state, err := loadThresholdState(path)
+	if err != nil {
+		d.logger.Error("Failed to read threshold state", "error", err)
+		return
+	}
+
+	changed := false
+	active := make(map[string]bool, len(config.Reporting.Thresholds))
+	for _, threshold := range config.Reporting.Thresholds {
+		key := thresholdKey(threshold)
+		active[key] = true
+		count, err := d.thresholdCount(threshold, now)
+		if err != nil {
+			d.logger.Error("Failed to evaluate threshold", "metric", threshold.Metric, "tool", threshold.Tool, "error", err)
+			continue
+		}
+
+		alerted, wasAlerted := state.Alerted[key]
+		if count <= threshold.Max {
+			if wasAlerted && threshold.Metric == core.ThresholdMetricTrackedPackages {
+				delete(state.Alerted, key)
+				changed = true
+			}
+			continue
+		}
+		if wasAlerted && (threshold.Metric == core.ThresholdMetricTrackedPackages || now.Sub(alerted) < threshold.WindowOrDefault()) {
+			continue
+		}
+
+		summary := thresholdSummary(threshold, count)
+		d.logger.Warn("Threshold exceeded", "metric", threshold.Metric, "tool", threshold.Tool, "count", count, "max", threshold.Max)
+		d.notifyAsync(notify.Event{
+			Type:    notify.EventThresholdExceeded,
+			Time:    now,
+			Tool:    core.NormalizeToolName(threshold.Tool),
+			Summary: summary,
+		})
+		state.Alerted[key] = now
+		changed = true
+	}
+	// Thresholds removed from the config leave the state with them.
+	for key := range state.Alerted {
+		if !active[key] {
+			delete(state.Alerted, key)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := saveThresholdState(path, state); err != nil {
+			d.logger.Error("Failed to save threshold state", "error", err)
+		}
+	}
+}
+
+// thresholdCount measures the metric of threshold at now.
+func (d *Daemon) thresholdCount(threshold core.ThresholdConfig, now time.Time) (int, error) {
+	tool := core.NormalizeToolName(threshold.Tool)
+	if threshold.Metric == core.ThresholdMetricTrackedPackages {
+		packages, err := d.storage.GetPackages(tool)
+		return len(packages), err
+	}
+
+	since := now.Add(-threshold.WindowOrDefault())
+	executions, err := d.storage.GetExecutions(storage.QueryOptions{Tool: tool, Since: &since})
+	if err != nil {
+		return 0, err
+	}
+	if threshold.Metric == core.ThresholdMetricExecutions {
+		return len(executions), nil
+	}
+	count := 0
+	for _, exec := range executions {
+		if !isInstall(exec) {
+			continue
+		}
+		if threshold.Metric == core.ThresholdMetricGlobalInstalls && isLocalInstall(exec) {
+			continue
+		}
+		count += len(exec.PackagesAffected)
+	}
+	return count, nil
+}
+
+// isInstall reports whether exec installed the packages it affects.
+// Homebrew records its subcommand rather than an action.
+func isInstall(exec *core.ExecutionRecord) bool {
+	action, ok := exec.Metadata["action"].(string)
+	if !ok {
+		action, _ = exec.Metadata["subcommand"].(string)
+	}
+	return slices.Contains(installActions, action)
+}
+
+// isLocalInstall reports whether exec installed into a project, or ran a
+// package once, rather than installing globally.
+func isLocalInstall(exec *core.ExecutionRecord) bool {
+	global, ok := exec.Metadata["global"].(bool)
+	return (ok && !global) || exec.Metadata["ephemeral"] == true
+}
+
+// thresholdKey identifies a threshold in the state file; editing its max or
+// window makes it a new threshold.
+func thresholdKey(threshold core.ThresholdConfig) string {
+	return fmt.Sprintf("%s:%s:%d:%s", threshold.Metric, core.NormalizeToolName(threshold.Tool), threshold.Max, threshold.WindowOrDefault())
+}
+
+// thresholdSummary describes a crossed threshold, such as "12 global npm
+// installs in 1d, over the limit of 10".
+func thresholdSummary(threshold core.ThresholdConfig, count int) string {
+	subject := strings.ReplaceAll(threshold.Metric, "_", " ")
+	if tool := core.NormalizeToolName(threshold.Tool); tool != "" {
+		if first, rest, ok := strings.Cut(subject, " "); ok && first == "global" {
+			subject = first + " " + tool + " " + rest
+		} else {
+			subject = tool + " " + subject
+		}
+	}
+	if threshold.Metric == core.ThresholdMetricTrackedPackages {
+		return fmt.Sprintf("%d %s, over the limit of %d", count, subject, threshold.Max)
+	}
+	return fmt.Sprintf("%d %s in %s, over the limit of %d", count, subject, formatWindow(threshold.WindowOrDefault()), threshold.Max)
+}
+
+// formatWindow writes whole days as "7d" and other windows as
+// time.Duration does.
+func formatWindow(window time.Duration) string {
+	day := 24 * time.Hour
+	if window%day == 0 {
+		return fmt.Sprintf("%dd", window/day)
+	}
+	return window.String()
+}
+
+func loadThresholdState(path string) (*thresholdState, error) {
+	state := &thresholdState{Alerted: make(map[string]time.Time)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Alerted == nil {
+		state.Alerted = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+func saveThresholdState(path string, state *thresholdState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/notify"
+)
+
+func TestCheckThresholdsAlertsOncePerWindow(t *testing.T) {
+	var mu sync.Mutex
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		alerts = append(alerts, body["summary"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(t)
+	cfg.Notifications.Webhooks = []core.WebhookConfig{{URL: server.URL, Events: []string{notify.EventThresholdExceeded}}}
+	cfg.Reporting.Thresholds = []core.ThresholdConfig{
+		{Tool: "npm", Metric: core.ThresholdMetricGlobalInstalls, Max: 1},
+		{Metric: core.ThresholdMetricInstalls, Max: 5},
+		{Metric: core.ThresholdMetricTrackedPackages, Max: 2},
+	}
+	d, err := NewDaemon(cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	now := time.Now()
+	mock := newMockStorage()
+	mock.executions = []*core.ExecutionRecord{
+		{Tool: "npm", Timestamp: now.Add(-time.Hour), PackagesAffected: []string{"yo", "tsx"}, Metadata: map[string]interface{}{"action": "install", "global": true}},
+		{Tool: "npm", Timestamp: now.Add(-time.Hour), PackagesAffected: []string{"left-pad"}, Metadata: map[string]interface{}{"action": "install", "global": false}},
+		{Tool: "npm", Timestamp: now.Add(-time.Hour), PackagesAffected: []string{"cowsay"}, Metadata: map[string]interface{}{"action": "exec", "ephemeral": true}},
+		{Tool: "homebrew", Timestamp: now.Add(-time.Hour), PackagesAffected: []string{"jq"}, Metadata: map[string]interface{}{"subcommand": "install"}},
+	}
+	for _, name := range []string{"yo", "tsx", "left-pad"} {
+		updateMockPackage(t, mock, &core.PackageInfo{Tool: "npm", Name: name})
+	}
+	d.storage = mock
+
+	check := func(at time.Time) []string {
+		t.Helper()
+		d.checkThresholds(at)
+		d.wg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		got := alerts
+		alerts = nil
+		return got
+	}
+
+	want := []string{"2 global npm installs in 1d, over the limit of 1", "3 tracked packages, over the limit of 2"}
+	if got := check(now); !slices.Equal(got, want) && !slices.Equal(got, []string{want[1], want[0]}) {
+		t.Fatalf("Alerts = %q, want %q", got, want)
+	}
+	if got := check(now.Add(time.Hour)); len(got) != 0 {
+		t.Fatalf("Expected no repeated alerts within the window, got %q", got)
+	}
+
+	mock.executions = append(mock.executions, &core.ExecutionRecord{
+		Tool: "npm", Timestamp: now.Add(25 * time.Hour), PackagesAffected: []string{"serve", "vite"}, Metadata: map[string]interface{}{"action": "add", "global": true},
+	})
+	if got := check(now.Add(26 * time.Hour)); !slices.Equal(got, []string{"2 global npm installs in 1d, over the limit of 1"}) {
+		t.Fatalf("Expected a new window to alert again, got %q", got)
+	}
+
+	mock.packages["npm"] = mock.packages["npm"][:2]
+	if got := check(now.Add(27 * time.Hour)); len(got) != 0 {
+		t.Fatalf("Expected no alert within the limit, got %q", got)
+	}
+	updateMockPackage(t, mock, &core.PackageInfo{Tool: "npm", Name: "left-pad"})
+	if got := check(now.Add(28 * time.Hour)); !slices.Equal(got, []string{"3 tracked packages, over the limit of 2"}) {
+		t.Fatalf("Expected tracked packages to alert after dropping back, got %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Package fleet syncs execution history from several machines into a
+// central diu daemon or a diu server. Each machine pushes its executions to
+// the /api/v1/sync endpoint, which merges them by record ID, so pushing the
+// same record twice or from two machines never duplicates it, and answers
+// with aggregate stats across every machine.
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// Path is the API path a central daemon serves sync requests on
+const Path = "/api/v1/sync"
+
+const (
+	// batchSize bounds the executions sent in one request.
+	batchSize = 500
+	// cursorOverlap re-sends executions recorded shortly before the last
+	// sync, since records can be stored a little after their timestamp.
+	cursorOverlap    = 10 * time.Minute
+	requestTimeout   = 30 * time.Second
+	maxResponseBytes = 1 << 20
+)
+
+// Request is the body of a sync request
+type Request struct {
+	Host       string                  `json:"host,omitempty"`
+	MachineID  string                  `json:"machine_id,omitempty"`
+	Executions []*core.ExecutionRecord `json:"executions"`
+}
+
+// Accepted returns the executions in the request that can be merged and
+// counts the rest. Executions must carry an ID, since that is what repeated
+// pushes are matched by; those that do not name a host are attributed to the
+// machine that sent them.
+func (r *Request) Accepted() ([]*core.ExecutionRecord, int) {
+	accepted := make([]*core.ExecutionRecord, 0, len(r.Executions))
+	rejected := 0
+	for _, record := range r.Executions {
+		if record == nil || record.ID == "" || record.Validate() != nil {
+			rejected++
+			continue
+		}
+		if record.Host == "" {
+			record.Host, record.MachineID = r.Host, r.MachineID
+		}
+		accepted = append(accepted, record)
+	}
+	return accepted, rejected
+}
+
+// Response reports how a sync request was merged and the central store's
+// totals afterwards
+type Response struct {
+	Inserted int   `json:"inserted"`
+	Skipped  int   `json:"skipped"`
+	Rejected int   `json:"rejected"`
+	Stats    Stats `json:"stats"`
+}
+
+// Stats aggregates the executions in a central store
+type Stats struct {
+	TotalExecutions int            `json:"total_executions"`
+	Tools           map[string]int `json:"tools"`
+	Hosts           map[string]int `json:"hosts"`
+	Updated         time.Time      `json:"updated"`
+}
+
+// Aggregate counts executions in store by tool and host
+func Aggregate(store storage.Storage, now time.Time) (Stats, error) {
+	stats := Stats{Tools: make(map[string]int), Hosts: make(map[string]int), Updated: now}
+	err := store.StreamExecutions(storage.QueryOptions{}, func(record *core.ExecutionRecord) error {
+		stats.TotalExecutions++
+		stats.Tools[record.Tool]++
+		host := record.Host
+		if host == "" {
+			host = "(unknown)"
+		}
+		stats.Hosts[host]++
+		return nil
+	})
+	return stats, err
+}
+
+// Result summarizes one sync with a remote
+type Result struct {
+	Remote   string    `json:"remote"`
+	Pushed   int       `json:"pushed"`
+	Inserted int       `json:"inserted"`
+	Skipped  int       `json:"skipped"`
+	Rejected int       `json:"rejected"`
+	Time     time.Time `json:"time"`
+	// Stats is the remote's aggregate after the push.
+	Stats Stats `json:"stats"`
+}
+
+// remoteState is what the last successful sync with a remote left behind
+type remoteState struct {
+	Cursor   time.Time `json:"cursor"`
+	LastSync time.Time `json:"last_sync"`
+	Stats    Stats     `json:"stats"`
+}
+
+// state is the sync state file, keyed by remote URL
+type state struct {
+	Remotes map[string]*remoteState `json:"remotes"`
+}
+
+// Sync pushes the executions recorded since the last sync with remote, or
+// every execution when full is set, and returns the remote's aggregate
+// stats. Progress is saved after each batch, so an interrupted sync resumes
+// where it stopped.
+func Sync(ctx context.Context, config *core.Config, store storage.Storage, remote string, full bool) (*Result, error) {
+	endpoint, err := Endpoint(remote)
+	if err != nil {
+		return nil, err
+	}
+	statePath := StatePath(config)
+	st, err := loadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	last := st.Remotes[remote]
+	if last == nil {
+		last = &remoteState{}
+		st.Remotes[remote] = last
+	}
+
+	opts := storage.QueryOptions{}
+	if !full && !last.Cursor.IsZero() {
+		since := last.Cursor.Add(-cursorOverlap)
+		opts.Since = &since
+	}
+	executions, err := store.GetExecutions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read executions: %w", err)
+	}
+	// GetExecutions returns newest first; push oldest first so the cursor
+	// only advances past executions the remote has.
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].Timestamp.Before(executions[j].Timestamp)
+	})
+
+	host, _ := os.Hostname()
+	machineID := core.MachineID(config.Daemon.DataDir)
+	result := &Result{Remote: remote, Time: time.Now()}
+	client := &http.Client{Timeout: requestTimeout}
+	token := config.Sync.AuthToken()
+
+	for start := 0; start == 0 || start < len(executions); start += batchSize {
+		batch := executions[start:min(start+batchSize, len(executions))]
+		for _, record := range batch {
+			// Records from before hosts were recorded came from here.
+			if record.Host == "" && record.MachineID == "" {
+				record.Host, record.MachineID = host, machineID
+			}
+		}
+
+		response, err := push(ctx, client, endpoint, token, Request{Host: host, MachineID: machineID, Executions: batch})
+		if err != nil {
+			return nil, err
+		}
+		result.Pushed += len(batch)
+		result.Inserted += response.Inserted
+		result.Skipped += response.Skipped
+		result.Rejected += response.Rejected
+		result.Stats = response.Stats
+
+		if len(batch) > 0 {
+			last.Cursor = batch[len(batch)-1].Timestamp
+		}
+		last.LastSync, last.Stats = result.Time, response.Stats
+		if err := saveState(statePath, st); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// LastSync returns the stats saved by the last successful sync with remote
+func LastSync(config *core.Config, remote string) (time.Time, *Stats, error) {
+	st, err := loadState(StatePath(config))
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	last, ok := st.Remotes[remote]
+	if !ok {
+		return time.Time{}, nil, nil
+	}
+	return last.LastSync, &last.Stats, nil
+}
+
+// Endpoint returns the sync URL for a remote daemon's base URL. A remote
+// that already ends in the API path is used as given.
+func Endpoint(remote string) (string, error) {
+	remote = strings.TrimRight(strings.TrimSpace(remote), "/")
+	if remote == "" {
+		return "", fmt.Errorf("no sync remote: pass --remote or set sync.remote")
+	}
+	if !strings.HasPrefix(remote, "http://") && !strings.HasPrefix(remote, "https://") {
+		return "", fmt.Errorf("sync remote must be an http or https URL: %s", remote)
+	}
+	if strings.HasSuffix(remote, Path) {
+		return remote, nil
+	}
+	return remote + Path, nil
+}
+
+func push(ctx context.Context, client *http.Client, endpoint, token string, request Request) (*Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sync request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sync rejected: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var response Response
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("invalid sync response: %w", err)
+	}
+	return &response, nil
+}
+
+// StatePath is where the cursor and stats of each remote are kept
+func StatePath(config *core.Config) string {
+	return filepath.Join(config.Daemon.DataDir, core.SyncStateFileName)
+}
+
+func loadState(path string) (*state, error) {
+	st := &state{Remotes: make(map[string]*remoteState)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if st.Remotes == nil {
+		st.Remotes = make(map[string]*remoteState)
+	}
+	return st, nil
+}
+
+func saveState(path string, st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
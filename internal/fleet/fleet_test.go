@@ -0,0 +1,93 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{remote: "http://devbox:8081", want: "http://devbox:8081/api/v1/sync"},
+		{remote: "https://devbox.example.com/", want: "https://devbox.example.com/api/v1/sync"},
+		{remote: "http://devbox:8081/api/v1/sync", want: "http://devbox:8081/api/v1/sync"},
+		{remote: "", wantErr: true},
+		{remote: "devbox:8081", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Endpoint(tt.remote)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Endpoint(%q) = %q, %v; want %q, error %v", tt.remote, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSyncPushesOnlyNewExecutions(t *testing.T) {
+	dir := t.TempDir()
+	config := &core.Config{
+		Daemon:  core.DaemonConfig{DataDir: dir},
+		Storage: core.StorageConfig{JSONFile: filepath.Join(dir, "executions.json")},
+	}
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	var pushed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		for _, record := range request.Executions {
+			pushed = append(pushed, record.Command)
+		}
+		_ = json.NewEncoder(w).Encode(Response{Inserted: len(request.Executions)})
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	add := func(command string, at time.Time) {
+		t.Helper()
+		if err := store.AddExecution(&core.ExecutionRecord{Tool: core.ToolNPM, Command: command, Timestamp: at}); err != nil {
+			t.Fatalf("AddExecution failed: %v", err)
+		}
+	}
+	add("npm install old", now.Add(-48*time.Hour))
+	add("npm install last", now.Add(-24*time.Hour))
+	if _, err := Sync(context.Background(), config, store, server.URL, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	add("npm install new", now)
+
+	pushed = nil
+	result, err := Sync(context.Background(), config, store, server.URL, false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	// The last synced execution falls inside the cursor overlap and is
+	// pushed again; the remote skips it by ID.
+	if result.Pushed != 2 || len(pushed) != 2 || pushed[0] != "npm install last" || pushed[1] != "npm install new" {
+		t.Errorf("Expected only executions since the cursor to be pushed, got %v", pushed)
+	}
+
+	pushed = nil
+	if _, err := Sync(context.Background(), config, store, server.URL, true); err != nil {
+		t.Fatalf("full Sync failed: %v", err)
+	}
+	if len(pushed) != 3 || pushed[0] != "npm install old" {
+		t.Errorf("Expected a full sync to push every execution oldest first, got %v", pushed)
+	}
+}
@@ -0,0 +1,185 @@
+// Package gitinfo finds the git repository enclosing a directory and reads
+// its branch and remote without running git.
+package gitinfo
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// Metadata keys set by Annotate
+const (
+	MetadataRoot   = "git_root"
+	MetadataBranch = "git_branch"
+	MetadataRemote = "git_remote"
+)
+
+// Info describes the repository enclosing a directory. Remote is the
+// org/repo path of the origin remote, e.g. yowainwright/diu, and Branch is
+// empty for a detached HEAD.
+type Info struct {
+	Root   string `json:"root"`
+	Branch string `json:"branch,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+// Detect walks up from dir to the nearest repository. It reports false when
+// dir is not inside one.
+func Detect(dir string) (Info, bool) {
+	if dir == "" {
+		return Info{}, false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Info{}, false
+	}
+
+	for {
+		if gitDir, ok := resolveGitDir(filepath.Join(dir, ".git")); ok {
+			info := Info{Root: dir, Branch: readBranch(gitDir)}
+			info.Remote = RepoPath(readRemoteURL(commonDir(gitDir)))
+			return info, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Info{}, false
+		}
+		dir = parent
+	}
+}
+
+// Annotate adds the repository enclosing record.WorkingDir to its metadata.
+// Records that already carry a repository root are left as they are.
+func Annotate(record *core.ExecutionRecord) {
+	if _, exists := record.Metadata[MetadataRoot]; exists {
+		return
+	}
+	info, ok := Detect(record.WorkingDir)
+	if !ok {
+		return
+	}
+	if record.Metadata == nil {
+		record.Metadata = make(map[string]interface{})
+	}
+	record.Metadata[MetadataRoot] = info.Root
+	if info.Branch != "" {
+		record.Metadata[MetadataBranch] = info.Branch
+	}
+	if info.Remote != "" {
+		record.Metadata[MetadataRemote] = info.Remote
+	}
+}
+
+// resolveGitDir returns the git directory for a .git entry, following the
+// "gitdir:" file that worktrees and submodules use in place of a directory
+func resolveGitDir(path string) (string, bool) {
+	info, err := safefs.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return path, true
+	}
+	data, err := safefs.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), true
+}
+
+// commonDir returns the directory holding the shared config of a worktree
+func commonDir(gitDir string) string {
+	data, err := safefs.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+func readBranch(gitDir string) string {
+	data, err := safefs.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref:")
+	if !ok {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(ref), "refs/heads/")
+}
+
+// readRemoteURL returns the URL of the origin remote, or of the first remote
+// when there is no origin
+func readRemoteURL(gitDir string) string {
+	data, err := safefs.ReadFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+
+	var section, first string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		if !strings.HasPrefix(section, "remote ") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		url := strings.Trim(strings.TrimSpace(value), `"`)
+		if section == `remote "origin"` {
+			return url
+		}
+		if first == "" {
+			first = url
+		}
+	}
+	return first
+}
+
+// RepoPath extracts org/repo from a remote URL in any of the forms git
+// accepts, e.g. https://github.com/org/repo.git or git@github.com:org/repo.
+// Credentials and the host are dropped.
+func RepoPath(url string) string {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return ""
+	}
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		_, url, _ = strings.Cut(rest, "/")
+	} else if at := strings.Index(url, "@"); at >= 0 {
+		// scp-like syntax: user@host:org/repo
+		_, url, _ = strings.Cut(url[at+1:], ":")
+	} else if host, path, ok := strings.Cut(url, ":"); ok && !strings.Contains(host, "/") {
+		url = path
+	}
+
+	url = strings.TrimSuffix(strings.Trim(url, "/"), ".git")
+	segments := strings.Split(url, "/")
+	if len(segments) < 2 {
+		return url
+	}
+	return strings.Join(segments[len(segments)-2:], "/")
+}
@@ -0,0 +1,93 @@
+// Package ignore drops executions that match the rules of the ignore config
+// section before they are stored.
+package ignore
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// Rules matches executions against compiled ignore rules.
+type Rules struct {
+	paths    []*regexp.Regexp
+	commands []*regexp.Regexp
+	users    []*regexp.Regexp
+}
+
+// New compiles the rules of config. It returns nil when there are none; a
+// nil Rules matches nothing.
+func New(config core.IgnoreConfig) (*Rules, error) {
+	if len(config.Paths) == 0 && len(config.Commands) == 0 && len(config.Users) == 0 {
+		return nil, nil
+	}
+	var rules Rules
+	var err error
+	if rules.paths, err = compile("ignore.paths", config.Paths); err != nil {
+		return nil, err
+	}
+	if rules.commands, err = compile("ignore.commands", config.Commands); err != nil {
+		return nil, err
+	}
+	if rules.users, err = compile("ignore.users", config.Users); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+func compile(key string, rules []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(rules))
+	for i, rule := range rules {
+		var pattern *regexp.Regexp
+		var err error
+		if expression, ok := strings.CutPrefix(rule, core.IgnoreRegexpPrefix); ok {
+			pattern, err = storage.CompileCommandMatch(expression)
+		} else {
+			pattern, err = storage.CompileCommandGlob(strings.TrimSpace(rule))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		compiled = append(compiled, pattern)
+	}
+	return compiled, nil
+}
+
+// Match reports whether record is ignored: its working directory or a
+// directory above it matches a path rule, its command matches a command
+// rule, or its user matches a user rule.
+func (r *Rules) Match(record *core.ExecutionRecord) bool {
+	if r == nil || record == nil {
+		return false
+	}
+	if matchAny(r.commands, record.Command) || matchAny(r.users, record.User) {
+		return true
+	}
+	if record.WorkingDir == "" || len(r.paths) == 0 {
+		return false
+	}
+	for dir := filepath.Clean(record.WorkingDir); ; dir = filepath.Dir(dir) {
+		if matchAny(r.paths, dir) {
+			return true
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return false
+		}
+	}
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package ignore
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestMatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the path rules use slash-separated paths")
+	}
+	rules, err := New(core.IgnoreConfig{
+		Paths:    []string{"/tmp", "/home/*/work/*", "re:/ci-[0-9]+$"},
+		Commands: []string{"npm run *", "re:^brew (list|info)\\b"},
+		Users:    []string{"runner", "build-?"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		record core.ExecutionRecord
+		want   bool
+	}{
+		{"path rule itself", core.ExecutionRecord{WorkingDir: "/tmp"}, true},
+		{"under a path rule", core.ExecutionRecord{WorkingDir: "/tmp/scratch/app"}, true},
+		{"path glob", core.ExecutionRecord{WorkingDir: "/home/ana/work/acme/site"}, true},
+		{"path regexp", core.ExecutionRecord{WorkingDir: "/builds/ci-42/src"}, true},
+		{"path sharing a prefix", core.ExecutionRecord{WorkingDir: "/tmpfiles"}, false},
+		{"command glob", core.ExecutionRecord{Command: "npm run build"}, true},
+		{"command glob matches the whole text", core.ExecutionRecord{Command: "pnpm npm run build"}, false},
+		{"command regexp", core.ExecutionRecord{Command: "brew info jq"}, true},
+		{"user", core.ExecutionRecord{User: "runner"}, true},
+		{"user glob", core.ExecutionRecord{User: "build-1"}, true},
+		{"nothing matches", core.ExecutionRecord{Command: "npm install tsx", User: "ana", WorkingDir: "/home/ana/src"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Match(&tt.record); got != tt.want {
+				t.Errorf("Match(%+v) = %v, want %v", tt.record, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	rules, err := New(core.IgnoreConfig{})
+	if err != nil || rules != nil {
+		t.Fatalf("Expected no rules for an empty config, got %v, %v", rules, err)
+	}
+	if rules.Match(&core.ExecutionRecord{WorkingDir: "/tmp"}) {
+		t.Error("Expected nil rules to match nothing")
+	}
+
+	if _, err := New(core.IgnoreConfig{Commands: []string{"re:("}}); err == nil {
+		t.Error("Expected an invalid regular expression rejected")
+	}
+}
@@ -0,0 +1,361 @@
+// Package license finds the license of installed packages: from npm package
+// metadata, from the license files of Go modules, and from Homebrew formula
+// info. Licenses are SPDX identifiers or expressions, such as "MIT" or
+// "Apache-2.0 OR MIT".
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const (
+	// DefaultNPMRegistry is used unless npm_config_registry names another.
+	DefaultNPMRegistry = "https://registry.npmjs.org"
+
+	requestTimeout   = 10 * time.Second
+	maxResponseBytes = 4 << 20
+	maxLicenseBytes  = 64 << 10
+)
+
+// Resolver looks up the licenses of packages
+type Resolver struct {
+	HTTPClient  *http.Client
+	NPMRegistry string
+	// GoModCache is the Go module cache, where module license files are read.
+	GoModCache string
+}
+
+// NewResolver returns a resolver for this machine's npm registry and Go
+// module cache
+func NewResolver() *Resolver {
+	registry := os.Getenv("npm_config_registry")
+	if registry == "" {
+		registry = os.Getenv("NPM_CONFIG_REGISTRY")
+	}
+	if registry == "" {
+		registry = DefaultNPMRegistry
+	}
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		goPath := os.Getenv("GOPATH")
+		if goPath == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				goPath = filepath.Join(home, "go")
+			}
+		}
+		if goPath != "" {
+			modCache = filepath.Join(filepath.SplitList(goPath)[0], "pkg", "mod")
+		}
+	}
+	return &Resolver{
+		HTTPClient:  &http.Client{Timeout: requestTimeout},
+		NPMRegistry: strings.TrimRight(registry, "/"),
+		GoModCache:  modCache,
+	}
+}
+
+// License returns the license of pkg, or "" when it cannot be found
+func (r *Resolver) License(ctx context.Context, pkg *core.PackageInfo) (string, error) {
+	if pkg.License != "" {
+		return pkg.License, nil
+	}
+	switch pkg.Tool {
+	case core.ToolHomebrew:
+		return homebrewLicense(ctx, pkg.Name)
+	case core.ToolNPM, core.ToolPNPM, core.ToolBun, core.ToolYarn:
+		if license := packageJSONLicense(filepath.Join(pkg.Path, "package.json")); license != "" {
+			return license, nil
+		}
+		return r.npmLicense(ctx, pkg.Name, pkg.Version)
+	case core.ToolGo:
+		return r.goModuleLicense(pkg.Name, pkg.Version), nil
+	}
+	return "", nil
+}
+
+// homebrewLicense asks brew for the license of a formula. Casks have none.
+func homebrewLicense(ctx context.Context, name string) (string, error) {
+	if _, err := exec.LookPath("brew"); err != nil {
+		return "", nil
+	}
+	output, err := exec.CommandContext(ctx, "brew", "info", "--json=v2", name).Output()
+	if err != nil {
+		return "", nil
+	}
+	var info struct {
+		Formulae []struct {
+			License string `json:"license"`
+		} `json:"formulae"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return "", fmt.Errorf("invalid brew info output: %w", err)
+	}
+	if len(info.Formulae) == 0 {
+		return "", nil
+	}
+	return info.Formulae[0].License, nil
+}
+
+// npmLicenseField is the license of an npm package: an SPDX expression, or
+// in old packages an object or list of objects with a type
+type npmLicenseField json.RawMessage
+
+func (f npmLicenseField) String() string {
+	var expression string
+	if json.Unmarshal(f, &expression) == nil {
+		return expression
+	}
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(f, &object) == nil {
+		return object.Type
+	}
+	var objects []struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(f, &objects) == nil {
+		types := make([]string, 0, len(objects))
+		for _, object := range objects {
+			if object.Type != "" {
+				types = append(types, object.Type)
+			}
+		}
+		return strings.Join(types, " OR ")
+	}
+	return ""
+}
+
+type npmManifest struct {
+	License  json.RawMessage `json:"license"`
+	Licenses json.RawMessage `json:"licenses"`
+}
+
+func (m npmManifest) license() string {
+	if license := npmLicenseField(m.License).String(); license != "" {
+		return license
+	}
+	return npmLicenseField(m.Licenses).String()
+}
+
+// packageJSONLicense reads the license of an installed npm package
+func packageJSONLicense(path string) string {
+	if !filepath.IsAbs(path) {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var manifest npmManifest
+	if json.Unmarshal(data, &manifest) != nil {
+		return ""
+	}
+	return manifest.license()
+}
+
+// npmLicense asks the registry for the license of an npm package version,
+// or of its latest version when the installed one is unknown
+func (r *Resolver) npmLicense(ctx context.Context, name, version string) (string, error) {
+	if version == "" {
+		version = "latest"
+	}
+	endpoint := r.NPMRegistry + "/" + strings.Replace(url.PathEscape(name), "%2F", "/", 1) + "/" + url.PathEscape(version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("npm registry request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("npm registry request failed: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read npm registry response: %w", err)
+	}
+	var manifest npmManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("invalid npm registry response: %w", err)
+	}
+	return manifest.license(), nil
+}
+
+// goModuleLicense classifies the license file of a module in the module
+// cache. name may be a package inside the module, so each parent path is
+// tried in turn.
+func (r *Resolver) goModuleLicense(name, version string) string {
+	if r.GoModCache == "" || version == "" {
+		return ""
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	for path := name; path != "." && path != "/" && path != ""; path = filepath.ToSlash(filepath.Dir(path)) {
+		dir := filepath.Join(r.GoModCache, filepath.FromSlash(escapeModulePath(path))+"@"+version)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		for _, pattern := range []string{"LICENSE*", "LICENCE*", "COPYING*"} {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, match := range matches {
+				if license := classifyFile(match); license != "" {
+					return license
+				}
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// escapeModulePath escapes upper-case letters the way the module cache
+// does, as '!' followed by the lower-case letter
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func classifyFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(file, maxLicenseBytes))
+	if err != nil {
+		return ""
+	}
+	return Classify(string(data))
+}
+
+// Classify names the license in the text of a license file, or returns ""
+// when it is not one of the common ones
+func Classify(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	upper := strings.ToUpper(text)
+	switch {
+	case strings.Contains(upper, "GNU AFFERO GENERAL PUBLIC LICENSE"):
+		return "AGPL-3.0"
+	case strings.Contains(upper, "GNU LESSER GENERAL PUBLIC LICENSE"):
+		if strings.Contains(upper, "VERSION 3") {
+			return "LGPL-3.0"
+		}
+		return "LGPL-2.1"
+	case strings.Contains(upper, "GNU GENERAL PUBLIC LICENSE"):
+		if strings.Contains(upper, "VERSION 3") {
+			return "GPL-3.0"
+		}
+		return "GPL-2.0"
+	case strings.Contains(upper, "MOZILLA PUBLIC LICENSE") && strings.Contains(upper, "2.0"):
+		return "MPL-2.0"
+	case strings.Contains(upper, "APACHE LICENSE") && strings.Contains(upper, "VERSION 2.0"):
+		return "Apache-2.0"
+	case strings.Contains(text, "Permission is hereby granted, free of charge"):
+		return "MIT"
+	case strings.Contains(text, "Permission to use, copy, modify, and/or distribute this software for any purpose"):
+		return "ISC"
+	case strings.Contains(text, "Redistribution and use in source and binary forms"):
+		if strings.Contains(text, "Neither the name") || strings.Contains(text, "names of its contributors") {
+			return "BSD-3-Clause"
+		}
+		return "BSD-2-Clause"
+	case strings.Contains(text, "This is free and unencumbered software released into the public domain"):
+		return "Unlicense"
+	}
+	return ""
+}
+
+// strongCopyleft and weakCopyleft are the SPDX identifier prefixes of
+// licenses that require derived works, or modified files, to be shared
+// under the same terms
+var (
+	strongCopyleft = []string{"AGPL-", "GPL-", "SSPL-", "OSL-", "CC-BY-SA-"}
+	weakCopyleft   = []string{"LGPL-", "MPL-", "EPL-", "CDDL-", "EUPL-", "CPL-"}
+)
+
+// Copyleft kinds
+const (
+	CopyleftStrong = "strong"
+	CopyleftWeak   = "weak"
+)
+
+// Copyleft returns CopyleftStrong or CopyleftWeak when every choice the
+// SPDX expression offers is copyleft, and "" otherwise
+func Copyleft(expression string) string {
+	kind := ""
+	for _, choice := range splitExpression(expression, " OR ") {
+		choiceKind := ""
+		for _, term := range splitExpression(choice, " AND ") {
+			switch termKind := copyleftTerm(term); {
+			case termKind == CopyleftStrong:
+				choiceKind = CopyleftStrong
+			case termKind == CopyleftWeak && choiceKind == "":
+				choiceKind = CopyleftWeak
+			}
+		}
+		if choiceKind == "" {
+			return ""
+		}
+		if kind == "" || choiceKind == CopyleftWeak {
+			kind = choiceKind
+		}
+	}
+	return kind
+}
+
+func splitExpression(expression, operator string) []string {
+	expression = strings.NewReplacer("(", " ", ")", " ", " or ", " OR ", " and ", " AND ").Replace(expression)
+	parts := strings.Split(expression, operator)
+	terms := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			terms = append(terms, part)
+		}
+	}
+	return terms
+}
+
+func copyleftTerm(term string) string {
+	id := strings.ToUpper(strings.Fields(term)[0])
+	for _, prefix := range weakCopyleft {
+		if strings.HasPrefix(id, prefix) {
+			return CopyleftWeak
+		}
+	}
+	for _, prefix := range strongCopyleft {
+		if strings.HasPrefix(id, prefix) {
+			return CopyleftStrong
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package license
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"MIT License\n\nPermission is hereby granted, free of\ncharge, to any person", "MIT"},
+		{"Apache License\n   Version 2.0, January 2004", "Apache-2.0"},
+		{"GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007", "GPL-3.0"},
+		{"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999", "LGPL-2.1"},
+		{"Redistribution and use in source and binary forms ... Neither the name of Google Inc.", "BSD-3-Clause"},
+		{"All rights reserved.", ""},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.text); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCopyleft(t *testing.T) {
+	tests := map[string]string{
+		"GPL-3.0-or-later":            CopyleftStrong,
+		"AGPL-3.0-only":               CopyleftStrong,
+		"LGPL-2.1-or-later":           CopyleftWeak,
+		"MPL-2.0":                     CopyleftWeak,
+		"MIT":                         "",
+		"MIT OR GPL-2.0":              "",
+		"(GPL-2.0 OR LGPL-2.1)":       CopyleftWeak,
+		"Apache-2.0 AND GPL-2.0-only": CopyleftStrong,
+		"":                            "",
+	}
+	for expression, want := range tests {
+		if got := Copyleft(expression); got != want {
+			t.Errorf("Copyleft(%q) = %q, want %q", expression, got, want)
+		}
+	}
+}
+
+func TestResolverLicense(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/@scope/tool/1.0.0":
+			_, _ = w.Write([]byte(`{"name": "@scope/tool", "license": "ISC"}`))
+		case "/old-tool/0.1.0":
+			_, _ = w.Write([]byte(`{"licenses": [{"type": "MIT"}, {"type": "Apache-2.0"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	installed := filepath.Join(dir, "node_modules", "local-tool")
+	modCache := filepath.Join(dir, "mod")
+	module := filepath.Join(modCache, "github.com", "!burnt!sushi", "toml@v1.3.2")
+	for path, content := range map[string]string{
+		filepath.Join(installed, "package.json"): `{"license": "BSD-2-Clause"}`,
+		filepath.Join(module, "COPYING"):         "The MIT License (MIT)\n\nPermission is hereby granted, free of charge, to any person",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	resolver := &Resolver{HTTPClient: server.Client(), NPMRegistry: server.URL, GoModCache: modCache}
+	tests := []struct {
+		pkg  core.PackageInfo
+		want string
+	}{
+		{core.PackageInfo{Tool: core.ToolNPM, Name: "@scope/tool", Version: "1.0.0"}, "ISC"},
+		{core.PackageInfo{Tool: core.ToolPNPM, Name: "old-tool", Version: "0.1.0"}, "MIT OR Apache-2.0"},
+		{core.PackageInfo{Tool: core.ToolBun, Name: "local-tool", Path: installed}, "BSD-2-Clause"},
+		{core.PackageInfo{Tool: core.ToolNPM, Name: "missing", Version: "1.0.0"}, ""},
+		{core.PackageInfo{Tool: core.ToolGo, Name: "github.com/BurntSushi/toml/cmd/tomlv", Version: "v1.3.2"}, "MIT"},
+		{core.PackageInfo{Tool: core.ToolPip, Name: "requests", License: "Apache-2.0"}, "Apache-2.0"},
+	}
+	for _, tt := range tests {
+		got, err := resolver.License(context.Background(), &tt.pkg)
+		if err != nil || got != tt.want {
+			t.Errorf("License(%s/%s) = %q, %v; want %q", tt.pkg.Tool, tt.pkg.Name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// New builds the daemon logger from config. The returned closer releases the
+// log file, if one was opened, and is safe to call when logging to stderr.
+func New(config core.DaemonConfig) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(config.LogLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var output io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if config.LogFile != "" {
+		maxBytes, maxAge, maxBackups := rotationSettings(config)
+		file, err := NewRotatingFile(config.LogFile, maxBytes, maxAge, maxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		output = file
+		closer = file
+	}
+
+	handler, err := NewHandler(output, config.LogFormat, level)
+	if err != nil {
+		_ = closer.Close()
+		return nil, nil, err
+	}
+	return slog.New(handler), closer, nil
+}
+
+func NewHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level: %s", level)
+	}
+}
+
+func openLogFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := safefs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, core.PrivateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return file, nil
+}
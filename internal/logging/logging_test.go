@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"":        slog.LevelInfo,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for input, want := range tests {
+		got, err := ParseLevel(input)
+		if err != nil {
+			t.Fatalf("ParseLevel(%q) failed: %v", input, err)
+		}
+		if got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestNewHandlerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewHandler(&buf, FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+
+	logger := slog.New(handler)
+	logger.Debug("hidden")
+	logger.Info("stored execution", "tool", "npm")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 log line above debug level, got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected JSON log line: %v", err)
+	}
+	if entry["tool"] != "npm" || entry["msg"] != "stored execution" {
+		t.Errorf("Unexpected log entry: %v", entry)
+	}
+}
+
+func TestNewHandlerRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewHandler(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestNewWritesToLogFile(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "logs", "diu.log")
+
+	logger, closer, err := New(core.DaemonConfig{
+		LogLevel:  "info",
+		LogFormat: FormatText,
+		LogFile:   logPath,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Info("daemon started")
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "daemon started") {
+		t.Errorf("Expected log file to contain message, got %q", string(data))
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Failed to stat log file: %v", err)
+	}
+	if got := info.Mode().Perm(); got != core.PrivateFileMode {
+		t.Errorf("Log file mode = %v, want %v", got, core.PrivateFileMode)
+	}
+}
@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const rotatedTimeFormat = "20060102_150405"
+
+// RotatingFile is an append-only log file that rotates itself once it grows
+// past MaxBytes. Rotated files are kept next to the active file and pruned by
+// count and age.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(path string, maxBytes int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("log file is closed")
+	}
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the active file, so external tools that move the
+// log aside are picked up without restarting the daemon.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeFile()
+}
+
+func (r *RotatingFile) open() error {
+	file, err := openLogFile(r.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	rotatedPath, err := r.nextRotatedPath(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, rotatedPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return r.prune(time.Now())
+}
+
+func (r *RotatingFile) nextRotatedPath(now time.Time) (string, error) {
+	base := r.path + "." + now.Format(rotatedTimeFormat)
+	for i := 0; i < 1000; i++ {
+		path := base
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", base, i)
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to stat rotated log path %s: %w", path, err)
+		}
+	}
+	return "", fmt.Errorf("failed to find available rotated log path")
+}
+
+func (r *RotatingFile) prune(now time.Time) error {
+	rotated, err := RotatedFiles(r.path)
+	if err != nil {
+		return err
+	}
+
+	for i, path := range rotated {
+		expired := false
+		if r.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > r.maxAge {
+				expired = true
+			}
+		}
+		overLimit := r.maxBackups > 0 && i < len(rotated)-r.maxBackups
+		if !expired && !overLimit {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove rotated log %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// RotatedFiles returns rotated copies of the log at path, oldest first.
+func RotatedFiles(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated logs: %w", err)
+	}
+
+	prefix := filepath.Base(path) + "."
+	rotated := matches[:0]
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(filepath.Base(match), prefix)
+		if len(suffix) < len(rotatedTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, suffix[:len(rotatedTimeFormat)]); err != nil {
+			continue
+		}
+		rotated = append(rotated, match)
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+func rotationSettings(config core.DaemonConfig) (int64, time.Duration, int) {
+	maxBytes := int64(config.LogMaxSizeMB) * 1024 * 1024
+	maxAge := time.Duration(config.LogMaxAgeDays) * 24 * time.Hour
+	return maxBytes, maxAge, config.LogMaxBackups
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "diu.log")
+
+	file, err := NewRotatingFile(logPath, 64, 0, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}()
+
+	line := strings.Repeat("x", 40) + "\n"
+	for i := 0; i < 5; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	rotated, err := RotatedFiles(logPath)
+	if err != nil {
+		t.Fatalf("RotatedFiles failed: %v", err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("Expected rotated files to be pruned to 2, got %d: %v", len(rotated), rotated)
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Failed to stat active log: %v", err)
+	}
+	if info.Size() != int64(len(line)) {
+		t.Errorf("Expected active log to hold one line, got %d bytes", info.Size())
+	}
+}
+
+func TestRotatingFilePrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "diu.log")
+
+	old := logPath + ".20200101_000000"
+	if err := os.WriteFile(old, []byte("old\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write old log: %v", err)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatalf("Failed to age old log: %v", err)
+	}
+
+	file, err := NewRotatingFile(logPath, 8, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		if _, err := file.Write([]byte("0123456\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected expired rotated log to be removed, stat err = %v", err)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "diu.log")
+
+	file, err := NewRotatingFile(logPath, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}()
+
+	if err := os.Rename(logPath, logPath+".moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := file.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if _, err := file.Write([]byte("after reopen\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read reopened log: %v", err)
+	}
+	if string(data) != "after reopen\n" {
+		t.Errorf("Unexpected reopened log contents: %q", string(data))
+	}
+}
@@ -2,8 +2,13 @@ package monitors
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
 )
 
 type Monitor interface {
@@ -44,18 +49,182 @@ func (m *BaseMonitor) Stop() error {
 	return nil
 }
 
+// monitorConstructors builds the monitor for each tool diu can track
+var monitorConstructors = map[string]func() Monitor{
+	core.ToolHomebrew: NewHomebrewMonitor,
+	core.ToolNPM:      NewNPMMonitor,
+	core.ToolPNPM:     NewPNPMMonitor,
+	core.ToolBun:      NewBunMonitor,
+	core.ToolYarn:     NewYarnMonitor,
+	core.ToolGo:       NewGoMonitor,
+	core.ToolPip:      NewPipMonitor,
+	core.ToolUV:       NewUVMonitor,
+	core.ToolPoetry:   NewPoetryMonitor,
+	core.ToolMake:     NewMakeMonitor,
+	core.ToolJust:     NewJustMonitor,
+	core.ToolTask:     NewTaskMonitor,
+}
+
+// New creates an uninitialized monitor for tool, accepting the aliases
+// core.NormalizeToolName does
+func New(tool string) (Monitor, error) {
+	constructor, ok := monitorConstructors[core.NormalizeToolName(tool)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tool: %s", tool)
+	}
+	return constructor(), nil
+}
+
+// ForConfig creates an uninitialized monitor for tool, using the plugin
+// config has for it in place of any built-in monitor
+func ForConfig(config *core.Config, tool string) (Monitor, error) {
+	if plugin, ok := config.Monitoring.Plugin(tool); ok {
+		return NewPluginMonitor(plugin), nil
+	}
+	return New(tool)
+}
+
+// SupportedTools lists the tools New has a monitor for, sorted
+func SupportedTools() []string {
+	tools := make([]string, 0, len(monitorConstructors))
+	for tool := range monitorConstructors {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	return tools
+}
+
+// Statuses lists every tool with a built-in monitor or a plugin in config,
+// sorted, with whether config enables it and whether registry, which may
+// be nil, has its monitor
+func Statuses(config *core.Config, registry *MonitorRegistry) []core.MonitorStatus {
+	tools := SupportedTools()
+	for _, plugin := range config.Monitoring.Plugins {
+		if name := core.NormalizeToolName(plugin.Name); name != "" && monitorConstructors[name] == nil {
+			tools = append(tools, name)
+		}
+	}
+	sort.Strings(tools)
+
+	statuses := make([]core.MonitorStatus, 0, len(tools))
+	for i, tool := range tools {
+		if i > 0 && tools[i-1] == tool {
+			continue
+		}
+		status := core.MonitorStatus{Tool: tool, Enabled: config.ToolEnabled(tool)}
+		if plugin, ok := config.Monitoring.Plugin(tool); ok {
+			status.Plugin = plugin.Path
+		}
+		if registry != nil {
+			_, status.Active = registry.Get(tool)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
 type MonitorRegistry struct {
 	monitors map[string]Monitor
+
+	mu     sync.Mutex
+	states map[string]*monitorState
+}
+
+// monitorState is what the registry has seen of one monitor since it was
+// built, for Health
+type monitorState struct {
+	initialized bool
+	lastEvent   time.Time
+	errors      int64
+	lastError   string
+}
+
+// wrapperMonitor is a monitor that records executions through a wrapper
+// script in place of the tool's binary
+type wrapperMonitor interface {
+	WrapperPath() string
 }
 
 func NewMonitorRegistry() *MonitorRegistry {
 	return &MonitorRegistry{
 		monitors: make(map[string]Monitor),
+		states:   make(map[string]*monitorState),
 	}
 }
 
+// Register adds an initialized monitor
 func (r *MonitorRegistry) Register(monitor Monitor) {
 	r.monitors[monitor.Name()] = monitor
+	r.mu.Lock()
+	r.state(monitor.Name()).initialized = true
+	r.mu.Unlock()
+}
+
+// RecordEvent notes that the monitor for name reported an execution at t
+func (r *MonitorRegistry) RecordEvent(name string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if state := r.state(name); t.After(state.lastEvent) {
+		state.lastEvent = t
+	}
+}
+
+// RecordError counts an error of the monitor for name. Health lists a
+// monitor that failed to initialize through the error recorded for it.
+func (r *MonitorRegistry) RecordError(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.state(name)
+	state.errors++
+	state.lastError = err.Error()
+}
+
+// state returns the state of name, creating it. Callers hold mu.
+func (r *MonitorRegistry) state(name string) *monitorState {
+	state, ok := r.states[name]
+	if !ok {
+		state = &monitorState{}
+		r.states[name] = state
+	}
+	return state
+}
+
+// Health reports every registered monitor and every monitor that failed to
+// initialize, sorted by name. A monitor is active when it initialized and
+// its wrapper, if it uses one, is in place; degraded when the wrapper is
+// missing, since its tool's executions go unrecorded; and error when it
+// did not initialize.
+func (r *MonitorRegistry) Health() []core.MonitorHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	health := make([]core.MonitorHealth, 0, len(r.states))
+	for name, state := range r.states {
+		entry := core.MonitorHealth{
+			Name:        name,
+			Status:      core.HealthStatusError,
+			Initialized: state.initialized,
+			LastEvent:   state.lastEvent,
+			Errors:      state.errors,
+			LastError:   state.lastError,
+		}
+		if state.initialized {
+			entry.Status = core.HealthStatusActive
+		}
+		if wrapped, ok := r.monitors[name].(wrapperMonitor); ok && wrapped.WrapperPath() != "" {
+			entry.WrapperPath = wrapped.WrapperPath()
+			info, err := safefs.Stat(entry.WrapperPath)
+			entry.WrapperInstalled = err == nil && !info.IsDir()
+			if !entry.WrapperInstalled && state.initialized {
+				entry.Status = core.HealthStatusDegraded
+			}
+		}
+		health = append(health, entry)
+	}
+	sort.Slice(health, func(i, k int) bool {
+		return health[i].Name < health[k].Name
+	})
+	return health
 }
 
 func (r *MonitorRegistry) Get(name string) (Monitor, bool) {
@@ -98,28 +267,39 @@ func (r *MonitorRegistry) StopAll() error {
 	return nil
 }
 
+// workingDirAnnotator is implemented by monitors that add metadata read from
+// the directory an execution ran in, which ParseCommand does not see
+type workingDirAnnotator interface {
+	annotateWorkingDir(record *core.ExecutionRecord)
+}
+
 // EnrichExecutionRecord enriches an execution record with parsed metadata using the given monitor.
 // This is a shared helper used by both the CLI and daemon to avoid code duplication.
 // Note: The caller is responsible for normalizing the tool name and setting the timestamp before calling this function.
-func EnrichExecutionRecord(monitor Monitor, record *core.ExecutionRecord) {
+// It returns the monitor's parse error, leaving the record unchanged.
+func EnrichExecutionRecord(monitor Monitor, record *core.ExecutionRecord) error {
 	parsed, err := monitor.ParseCommand(record.Command, record.Args)
 	if err != nil {
-		return
+		return err
 	}
 
 	if len(record.PackagesAffected) == 0 {
 		record.PackagesAffected = parsed.PackagesAffected
 	}
 
-	if len(parsed.Metadata) == 0 {
-		return
-	}
-	if record.Metadata == nil {
-		record.Metadata = make(map[string]interface{})
-	}
-	for key, value := range parsed.Metadata {
-		if _, exists := record.Metadata[key]; !exists {
-			record.Metadata[key] = value
+	if len(parsed.Metadata) > 0 {
+		if record.Metadata == nil {
+			record.Metadata = make(map[string]interface{})
+		}
+		for key, value := range parsed.Metadata {
+			if _, exists := record.Metadata[key]; !exists {
+				record.Metadata[key] = value
+			}
 		}
 	}
+
+	if annotator, ok := monitor.(workingDirAnnotator); ok {
+		annotator.annotateWorkingDir(record)
+	}
+	return nil
 }
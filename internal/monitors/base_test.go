@@ -3,7 +3,11 @@ package monitors
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/yowainwright/diu/internal/core"
 )
@@ -156,6 +160,53 @@ func TestMonitorRegistryGetAll(t *testing.T) {
 	}
 }
 
+func TestMonitorRegistryHealth(t *testing.T) {
+	registry := NewMonitorRegistry()
+	wrapperDir := t.TempDir()
+
+	wrapped := NewProcessMonitor("wrapped", "wrapped")
+	wrapped.wrapperPath = filepath.Join(wrapperDir, "wrapped")
+	if err := os.WriteFile(wrapped.wrapperPath, []byte("#!/bin/sh\n"), 0o700); err != nil {
+		t.Fatalf("Failed to write wrapper: %v", err)
+	}
+	unwrapped := NewProcessMonitor("unwrapped", "unwrapped")
+	unwrapped.wrapperPath = filepath.Join(wrapperDir, "unwrapped")
+	registry.Register(wrapped)
+	registry.Register(unwrapped)
+	registry.Register(newMockMonitor("mock"))
+	registry.RecordError("broken", errors.New("binary not found"))
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	registry.RecordEvent("wrapped", at)
+	registry.RecordEvent("wrapped", at.Add(-time.Hour))
+	registry.RecordError("wrapped", errors.New("parse failed"))
+
+	health := registry.Health()
+	names := make([]string, 0, len(health))
+	byName := make(map[string]core.MonitorHealth, len(health))
+	for _, entry := range health {
+		names = append(names, entry.Name)
+		byName[entry.Name] = entry
+	}
+	if strings.Join(names, ",") != "broken,mock,unwrapped,wrapped" {
+		t.Fatalf("Expected every monitor sorted, got %v", names)
+	}
+
+	if got := byName["wrapped"]; got.Status != core.HealthStatusActive || !got.Initialized || !got.WrapperInstalled ||
+		!got.LastEvent.Equal(at) || got.Errors != 1 || got.LastError != "parse failed" {
+		t.Errorf("Unexpected health for the wrapped monitor: %+v", got)
+	}
+	if got := byName["unwrapped"]; got.Status != core.HealthStatusDegraded || got.WrapperInstalled || got.WrapperPath == "" {
+		t.Errorf("Expected a missing wrapper to degrade the monitor, got %+v", got)
+	}
+	if got := byName["mock"]; got.Status != core.HealthStatusActive || got.WrapperPath != "" || !got.LastEvent.IsZero() {
+		t.Errorf("Unexpected health for a monitor without a wrapper: %+v", got)
+	}
+	if got := byName["broken"]; got.Status != core.HealthStatusError || got.Initialized || got.LastError != "binary not found" {
+		t.Errorf("Expected the failed monitor listed as an error, got %+v", got)
+	}
+}
+
 func TestMonitorRegistryInitializeAll(t *testing.T) {
 	registry := NewMonitorRegistry()
 	config := core.DefaultConfig()
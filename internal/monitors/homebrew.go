@@ -235,6 +235,7 @@ func (m *HomebrewMonitor) getFormulae() ([]*core.PackageInfo, error) {
 			Name            string   `json:"name"`
 			FullName        string   `json:"full_name"`
 			Version         string   `json:"version"`
+			License         string   `json:"license"`
 			InstalledTime   string   `json:"installed_time"`
 			Dependencies    []string `json:"dependencies"`
 			InstalledAsPath string   `json:"installed_as_dependency"`
@@ -255,6 +256,7 @@ func (m *HomebrewMonitor) getFormulae() ([]*core.PackageInfo, error) {
 			Tool:         core.ToolHomebrew,
 			InstallDate:  installTime,
 			Dependencies: formula.Dependencies,
+			License:      formula.License,
 		}
 		packages = append(packages, pkg)
 	}
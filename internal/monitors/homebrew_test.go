@@ -252,7 +252,7 @@ if [ "$1" = "--prefix" ]; then
   exit 0
 fi
 if [ "$1" = "list" ] && [ "$2" = "--formula" ] && [ "$3" = "--json=v2" ]; then
-  printf '%s\n' '{"formulae":[{"name":"jq","full_name":"jq","version":"1.7","installed_time":"2024-01-02T03:04:05Z","dependencies":["oniguruma"]}]}'
+  printf '%s\n' '{"formulae":[{"name":"jq","full_name":"jq","version":"1.7","license":"MIT","installed_time":"2024-01-02T03:04:05Z","dependencies":["oniguruma"]}]}'
   exit 0
 fi
 if [ "$1" = "list" ] && [ "$2" = "--cask" ]; then
@@ -301,7 +301,7 @@ exit 2
 	for _, pkg := range packages {
 		byName[pkg.Name] = pkg
 	}
-	if byName["jq"].Version != "1.7" || byName["jq"].Tool != core.ToolHomebrew {
+	if byName["jq"].Version != "1.7" || byName["jq"].Tool != core.ToolHomebrew || byName["jq"].License != "MIT" {
 		t.Fatalf("Unexpected jq package: %#v", byName["jq"])
 	}
 	if byName["firefox"].Tool != homebrewCaskTool {
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/project"
 )
 
 const (
@@ -42,6 +43,10 @@ func (m *PNPMMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRe
 	return parseJavaScriptManagerCommand(core.ToolPNPM, cmd, args), nil
 }
 
+func (m *PNPMMonitor) annotateWorkingDir(record *core.ExecutionRecord) {
+	annotateScript(record)
+}
+
 func (m *PNPMMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
 	output, err := exec.Command(pnpmCommandName, "list", jsGlobalShortFlag, "--depth=0", "--json").Output()
 	if err == nil && len(output) > 0 {
@@ -82,6 +87,10 @@ func (m *BunMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRec
 	return parseJavaScriptManagerCommand(core.ToolBun, cmd, args), nil
 }
 
+func (m *BunMonitor) annotateWorkingDir(record *core.ExecutionRecord) {
+	annotateScript(record)
+}
+
 func (m *BunMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
 	output, err := exec.Command(bunCommandName, "pm", "ls", jsGlobalShortFlag, "--json").Output()
 	if err == nil && len(output) > 0 {
@@ -138,16 +147,75 @@ func parseJavaScriptManagerCommand(tool, cmd string, args []string) *core.Execut
 		}
 	case "dlx", "x", "exec":
 		record.Metadata["action"] = "exec"
-		if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
-			if pkg := cleanJavaScriptPackageSpec(args[1]); pkg != "" {
-				record.PackagesAffected = []string{pkg}
-			}
+		if subcommand != "exec" {
+			record.Metadata["ephemeral"] = true
 		}
+		record.PackagesAffected = extractEphemeralPackages(args[1:])
 	}
 
 	return record
 }
 
+// extractEphemeralPackages returns the packages a dlx-style command fetches
+// to run once: those named by -p or --package, otherwise the command itself
+func extractEphemeralPackages(args []string) []string {
+	var packages []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value, ok := strings.CutPrefix(arg, "--package=")
+		if !ok && (arg == "-p" || arg == "--package") && i+1 < len(args) {
+			i++
+			value, ok = args[i], true
+		}
+		if ok {
+			if pkg := cleanJavaScriptPackageSpec(value); pkg != "" {
+				packages = append(packages, pkg)
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if len(packages) == 0 {
+			if pkg := cleanJavaScriptPackageSpec(arg); pkg != "" {
+				packages = append(packages, pkg)
+			}
+		}
+		break
+	}
+	return packages
+}
+
+// annotateScript records in script_root the directory of the package.json
+// whose script record ran. A bare pnpm or yarn <name> that is not one of the
+// manager's own commands runs the script of that name when package.json
+// defines one.
+func annotateScript(record *core.ExecutionRecord) {
+	if record.Metadata == nil {
+		return
+	}
+	script, _ := record.Metadata["script"].(string)
+	if script == "" {
+		_, hasAction := record.Metadata["action"]
+		if hasAction || (record.Tool != core.ToolPNPM && record.Tool != core.ToolYarn) || len(record.Args) == 0 {
+			return
+		}
+	}
+
+	root, scripts, ok := project.PackageScripts(record.WorkingDir)
+	if !ok {
+		return
+	}
+	if script == "" {
+		if !contains(scripts, record.Args[0]) {
+			return
+		}
+		record.Metadata["action"] = "run"
+		record.Metadata["script"] = record.Args[0]
+	}
+	record.Metadata[project.MetadataScriptRoot] = root
+}
+
 func extractJavaScriptPackages(args []string) []string {
 	valueFlags := map[string]bool{
 		"--registry": true,
@@ -2,9 +2,12 @@ package monitors
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/project"
 )
 
 func TestPNPMParseCommand(t *testing.T) {
@@ -38,11 +41,53 @@ func TestBunParseCommand(t *testing.T) {
 	if len(record.PackagesAffected) != 1 || record.PackagesAffected[0] != "eslint" {
 		t.Fatalf("PackagesAffected = %#v, want eslint", record.PackagesAffected)
 	}
-	if record.Metadata["action"] != "exec" {
+	if record.Metadata["action"] != "exec" || record.Metadata["ephemeral"] != true {
 		t.Fatalf("Unexpected metadata: %#v", record.Metadata)
 	}
 }
 
+func TestJavaScriptManagerScripts(t *testing.T) {
+	root := t.TempDir()
+	manifest := `{"name": "web", "scripts": {"lint": "eslint .", "install": "node setup.js"}}`
+	if err := os.WriteFile(filepath.Join(root, "package.json"), []byte(manifest), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	dir := filepath.Join(root, "src")
+
+	tests := []struct {
+		name       string
+		monitor    Monitor
+		args       []string
+		wantScript string
+	}{
+		{name: "bare pnpm script", monitor: NewPNPMMonitor(), args: []string{"lint", "--fix"}, wantScript: "lint"},
+		{name: "bare yarn script", monitor: NewYarnMonitor(), args: []string{"lint"}, wantScript: "lint"},
+		{name: "npm run", monitor: NewNPMMonitor(), args: []string{"run", "lint"}, wantScript: "lint"},
+		{name: "undefined script", monitor: NewPNPMMonitor(), args: []string{"typecheck"}},
+		{name: "manager command", monitor: NewPNPMMonitor(), args: []string{"install"}},
+		{name: "bare bun name", monitor: NewBunMonitor(), args: []string{"lint"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &core.ExecutionRecord{Tool: tt.monitor.Name(), Command: tt.monitor.Name(), Args: tt.args, WorkingDir: dir}
+			if err := EnrichExecutionRecord(tt.monitor, record); err != nil {
+				t.Fatalf("EnrichExecutionRecord failed: %v", err)
+			}
+			script, _ := record.Metadata["script"].(string)
+			if script != tt.wantScript {
+				t.Fatalf("script = %q, want %q", script, tt.wantScript)
+			}
+			wantRoot := ""
+			if tt.wantScript != "" {
+				wantRoot = root
+			}
+			if got, _ := record.Metadata[project.MetadataScriptRoot].(string); got != wantRoot {
+				t.Errorf("%s = %q, want %q", project.MetadataScriptRoot, got, wantRoot)
+			}
+		})
+	}
+}
+
 func TestJavaScriptManagerParseCommandVariants(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -123,6 +123,11 @@ func (m *NPMMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRec
 			record.Metadata["depth"] = depth
 		}
 
+	case "exec", "x":
+		record.Metadata["action"] = "exec"
+		record.Metadata["ephemeral"] = true
+		record.PackagesAffected = extractEphemeralPackages(args[1:])
+
 	case "search", "s", "se", "find":
 		if len(args) > 1 {
 			record.Metadata["search_term"] = strings.Join(args[1:], " ")
@@ -135,9 +140,11 @@ func (m *NPMMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRec
 
 	case "test", "t", "tst":
 		record.Metadata["action"] = "test"
+		record.Metadata["script"] = "test"
 
 	case "start":
 		record.Metadata["action"] = "start"
+		record.Metadata["script"] = "start"
 
 	case "build":
 		record.Metadata["action"] = "build"
@@ -170,6 +177,10 @@ func (m *NPMMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRec
 	return record, nil
 }
 
+func (m *NPMMonitor) annotateWorkingDir(record *core.ExecutionRecord) {
+	annotateScript(record)
+}
+
 func (m *NPMMonitor) extractPackagesFromNPMArgs(args []string) []string {
 	var packages []string
 	skipNext := false
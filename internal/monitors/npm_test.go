@@ -246,6 +246,16 @@ func TestNPMParseCommand(t *testing.T) {
 				"action":     "fund",
 			},
 		},
+		{
+			name:     "exec package",
+			args:     []string{"exec", "--package=create-vite@latest", "--", "create-vite", "app"},
+			packages: []string{"create-vite"},
+			metadata: map[string]interface{}{
+				"subcommand": "exec",
+				"action":     "exec",
+				"ephemeral":  true,
+			},
+		},
 		{
 			name:     "outdated command",
 			args:     []string{"outdated"},
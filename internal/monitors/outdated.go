@@ -0,0 +1,153 @@
+package monitors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// OutdatedPackage is an installed package with a newer version available
+type OutdatedPackage struct {
+	Tool    string `json:"tool"`
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+}
+
+// OutdatedLister is implemented by monitors whose tool can list the
+// installed packages that have newer versions
+type OutdatedLister interface {
+	OutdatedPackages() ([]OutdatedPackage, error)
+}
+
+// outdatedOutput runs a command that lists outdated packages. Such commands
+// commonly exit non-zero when they find any, so output is used whenever
+// there is some.
+func outdatedOutput(tool string, cmd *exec.Cmd) ([]byte, error) {
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("failed to list outdated %s packages: %w", tool, err)
+	}
+	return output, nil
+}
+
+func (m *HomebrewMonitor) OutdatedPackages() ([]OutdatedPackage, error) {
+	output, err := outdatedOutput(core.ToolHomebrew, exec.Command(homebrewCommandName, "outdated", homebrewJSONV2Arg))
+	if err != nil {
+		return nil, err
+	}
+
+	var brewData struct {
+		Formulae []homebrewOutdated `json:"formulae"`
+		Casks    []homebrewOutdated `json:"casks"`
+	}
+	if err := json.Unmarshal(output, &brewData); err != nil {
+		return nil, fmt.Errorf("invalid brew outdated output: %w", err)
+	}
+
+	var outdated []OutdatedPackage
+	for _, formula := range brewData.Formulae {
+		outdated = append(outdated, formula.outdated(core.ToolHomebrew))
+	}
+	if m.config == nil || m.config.Tools.Homebrew.TrackCasks {
+		for _, cask := range brewData.Casks {
+			outdated = append(outdated, cask.outdated(homebrewCaskTool))
+		}
+	}
+	return outdated, nil
+}
+
+// homebrewOutdated is a formula or cask in brew outdated's JSON. Casks
+// report their installed version as a string, formulae as a list.
+type homebrewOutdated struct {
+	Name              string          `json:"name"`
+	InstalledVersions json.RawMessage `json:"installed_versions"`
+	CurrentVersion    string          `json:"current_version"`
+}
+
+func (h homebrewOutdated) outdated(tool string) OutdatedPackage {
+	pkg := OutdatedPackage{Tool: tool, Name: h.Name, Latest: h.CurrentVersion}
+	var versions []string
+	if json.Unmarshal(h.InstalledVersions, &versions) == nil && len(versions) > 0 {
+		pkg.Current = versions[len(versions)-1]
+	} else {
+		_ = json.Unmarshal(h.InstalledVersions, &pkg.Current)
+	}
+	return pkg
+}
+
+func (m *NPMMonitor) OutdatedPackages() ([]OutdatedPackage, error) {
+	output, err := outdatedOutput(core.ToolNPM, exec.Command(npmCommandName, "outdated", npmGlobalFlag, npmJSONFlag))
+	if err != nil {
+		return nil, err
+	}
+	return parseNodeOutdatedJSON(core.ToolNPM, output)
+}
+
+func (m *PNPMMonitor) OutdatedPackages() ([]OutdatedPackage, error) {
+	output, err := outdatedOutput(core.ToolPNPM, exec.Command(pnpmCommandName, "outdated", jsGlobalShortFlag, "--format", "json"))
+	if err != nil {
+		return nil, err
+	}
+	return parseNodeOutdatedJSON(core.ToolPNPM, output)
+}
+
+// parseNodeOutdatedJSON parses the package map npm and pnpm print for
+// outdated --json
+func parseNodeOutdatedJSON(tool string, output []byte) ([]OutdatedPackage, error) {
+	var packages map[string]struct {
+		Current string `json:"current"`
+		Latest  string `json:"latest"`
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(output, &packages); err != nil {
+		return nil, fmt.Errorf("invalid %s outdated output: %w", tool, err)
+	}
+
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	outdated := make([]OutdatedPackage, 0, len(names))
+	for _, name := range names {
+		info := packages[name]
+		outdated = append(outdated, OutdatedPackage{Tool: tool, Name: name, Current: info.Current, Latest: info.Latest})
+	}
+	return outdated, nil
+}
+
+var pipOutdatedCommands = map[string]func() *exec.Cmd{
+	pip3CommandName: func() *exec.Cmd { return exec.Command(pip3CommandName, "list", "--outdated", pythonListFormat) },
+	pipCommandName:  func() *exec.Cmd { return exec.Command(pipCommandName, "list", "--outdated", pythonListFormat) },
+}
+
+func (m *PipMonitor) OutdatedPackages() ([]OutdatedPackage, error) {
+	command, ok := pipOutdatedCommands[m.commandName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported pip command: %s", m.commandName)
+	}
+	output, err := outdatedOutput(core.ToolPip, command())
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []struct {
+		Name          string `json:"name"`
+		Version       string `json:"version"`
+		LatestVersion string `json:"latest_version"`
+	}
+	if err := json.Unmarshal(output, &packages); err != nil {
+		return nil, fmt.Errorf("invalid pip outdated output: %w", err)
+	}
+	outdated := make([]OutdatedPackage, 0, len(packages))
+	for _, pkg := range packages {
+		outdated = append(outdated, OutdatedPackage{Tool: core.ToolPip, Name: pkg.Name, Current: pkg.Version, Latest: pkg.LatestVersion})
+	}
+	return outdated, nil
+}
@@ -0,0 +1,52 @@
+package monitors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestOutdatedPackages(t *testing.T) {
+	prependFakeCommand(t, homebrewCommandName, `#!/bin/sh
+[ "$1" = "outdated" ] || exit 2
+printf '%s\n' '{"formulae":[{"name":"jq","installed_versions":["1.6","1.7"],"current_version":"1.7.1"}],"casks":[{"name":"firefox","installed_versions":"120.0","current_version":"121.0"}]}'
+exit 1
+`)
+	prependFakeCommand(t, npmCommandName, `#!/bin/sh
+[ "$1" = "outdated" ] || exit 2
+printf '%s\n' '{"typescript":{"current":"5.3.3","wanted":"5.3.3","latest":"5.4.2","location":"/usr/local/lib/node_modules/typescript"}}'
+exit 1
+`)
+	prependFakeCommand(t, pipCommandName, `#!/bin/sh
+[ "$1" = "list" ] && [ "$2" = "--outdated" ] || exit 2
+printf '%s\n' '[{"name":"requests","version":"2.31.0","latest_version":"2.32.3","latest_filetype":"wheel"}]'
+`)
+
+	config := core.DefaultConfig()
+	homebrew := NewHomebrewMonitor().(*HomebrewMonitor)
+	homebrew.config = config
+	pip := NewPipMonitor().(*PipMonitor)
+	pip.commandName = pipCommandName
+
+	tests := []struct {
+		lister OutdatedLister
+		want   []OutdatedPackage
+	}{
+		{homebrew, []OutdatedPackage{
+			{Tool: core.ToolHomebrew, Name: "jq", Current: "1.7", Latest: "1.7.1"},
+			{Tool: homebrewCaskTool, Name: "firefox", Current: "120.0", Latest: "121.0"},
+		}},
+		{NewNPMMonitor().(*NPMMonitor), []OutdatedPackage{{Tool: core.ToolNPM, Name: "typescript", Current: "5.3.3", Latest: "5.4.2"}}},
+		{pip, []OutdatedPackage{{Tool: core.ToolPip, Name: "requests", Current: "2.31.0", Latest: "2.32.3"}}},
+	}
+	for _, tt := range tests {
+		got, err := tt.lister.OutdatedPackages()
+		if err != nil {
+			t.Fatalf("%T.OutdatedPackages failed: %v", tt.lister, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%T.OutdatedPackages = %+v, want %+v", tt.lister, got, tt.want)
+		}
+	}
+}
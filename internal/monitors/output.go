@@ -0,0 +1,97 @@
+package monitors
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// ansiEscapePattern matches the color and cursor sequences tools print
+// to terminals
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// outputCapture keeps the first and last lines written to it. It holds at
+// most twice its line count, each line cut at core.MaxOutputLength, however
+// much is written.
+type outputCapture struct {
+	lines     int
+	head      []string
+	tail      []string
+	partial   []byte
+	truncated bool
+}
+
+func newOutputCapture(lines int) *outputCapture {
+	return &outputCapture{lines: lines}
+}
+
+func (c *outputCapture) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.appendPartial(p)
+			break
+		}
+		c.appendPartial(p[:i])
+		c.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (c *outputCapture) appendPartial(p []byte) {
+	if room := core.MaxOutputLength - len(c.partial); room > 0 {
+		c.partial = append(c.partial, p[:min(room, len(p))]...)
+	}
+}
+
+func (c *outputCapture) endLine() {
+	// A progress bar redraws its line after each \r; keep what was shown last.
+	line := strings.TrimSuffix(string(c.partial), "\r")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	c.partial = c.partial[:0]
+	if len(c.head) < c.lines {
+		c.head = append(c.head, line)
+		return
+	}
+	c.tail = append(c.tail, line)
+	if len(c.tail) > c.lines {
+		c.tail = c.tail[1:]
+		c.truncated = true
+	}
+}
+
+// String returns the kept lines without terminal escape sequences
+func (c *outputCapture) String() string {
+	if len(c.partial) > 0 {
+		c.endLine()
+	}
+	text := strings.Join(append(append([]string(nil), c.head...), c.tail...), "\n")
+	text = ansiEscapePattern.ReplaceAllString(text, "")
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// summarizeOutput returns what stdout and stderr captured, or nil when the
+// command printed nothing
+func summarizeOutput(stdout, stderr *outputCapture) *core.Output {
+	output := &core.Output{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+	if output.Stdout == "" && output.Stderr == "" {
+		return nil
+	}
+	output.Limit(core.MaxOutputLength)
+	return output
+}
@@ -0,0 +1,211 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// The plugin protocol lets a monitor be written in any language. diu runs
+// the plugin executable with one of these subcommands and reads JSON from
+// its stdout:
+//
+//	parse <command> [args...]  one object: {"packages_affected": [...], "metadata": {...}}
+//	list-installed             an array of packages: [{"name": ..., "version": ..., ...}]
+//
+// Packages use the fields of core.PackageInfo; tool is always the plugin's
+// and a missing install_date is the time of the scan. A plugin exits 0 on
+// success and otherwise exits non-zero with a message on stderr, as it must
+// for subcommands it does not know so later protocol versions can be
+// detected. PluginProtocolEnv carries the protocol version.
+const (
+	PluginParseCommand         = "parse"
+	PluginListInstalledCommand = "list-installed"
+	PluginProtocolEnv          = "DIU_PLUGIN_PROTOCOL"
+	PluginProtocolVersion      = "1"
+
+	maxPluginOutputBytes = 16 << 20
+	maxPluginErrorBytes  = 1024
+	// pluginWaitDelay bounds how long a plugin's output is read after it
+	// is killed, in case children it started still hold stdout open.
+	pluginWaitDelay = time.Second
+)
+
+var errPluginTimeout = errors.New("timed out")
+
+// PluginParseResult is what a plugin prints for parse
+type PluginParseResult struct {
+	PackagesAffected []string               `json:"packages_affected"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// PluginMonitor tracks a tool through a plugin executable, installing the
+// same wrapper as the built-in monitors for the plugin's binary
+type PluginMonitor struct {
+	*ProcessMonitor
+	plugin core.PluginConfig
+}
+
+func NewPluginMonitor(plugin core.PluginConfig) *PluginMonitor {
+	binary := plugin.Binary
+	if binary == "" {
+		binary = plugin.Name
+	}
+	return &PluginMonitor{
+		ProcessMonitor: NewProcessMonitor(core.NormalizeToolName(plugin.Name), binary),
+		plugin:         plugin,
+	}
+}
+
+func (m *PluginMonitor) Initialize(config *core.Config) error {
+	if _, err := exec.LookPath(m.plugin.Path); err != nil {
+		return fmt.Errorf("plugin %s not found: %w", m.name, err)
+	}
+	return m.ProcessMonitor.Initialize(config)
+}
+
+func (m *PluginMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	var result PluginParseResult
+	if err := m.run(&result, append([]string{PluginParseCommand, cmd}, args...)...); err != nil {
+		return nil, err
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	return &core.ExecutionRecord{
+		Tool:             m.name,
+		Command:          cmd,
+		Args:             args,
+		PackagesAffected: result.PackagesAffected,
+		Metadata:         result.Metadata,
+	}, nil
+}
+
+func (m *PluginMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	var packages []*core.PackageInfo
+	if err := m.run(&packages, PluginListInstalledCommand); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i, pkg := range packages {
+		if pkg == nil || strings.TrimSpace(pkg.Name) == "" {
+			return nil, fmt.Errorf("plugin %s listed a package without a name at index %d", m.name, i)
+		}
+		pkg.Tool = m.name
+		if pkg.InstallDate.IsZero() {
+			pkg.InstallDate = now
+		}
+	}
+	return packages, nil
+}
+
+// run runs the plugin with args and decodes its stdout into result
+func (m *PluginMonitor) run(result interface{}, args ...string) error {
+	output, err := runPlugin(m.plugin, args...)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(output, result); err != nil {
+		return fmt.Errorf("plugin %s %s printed invalid JSON: %w", m.name, args[0], err)
+	}
+	return nil
+}
+
+// runPlugin runs plugin with args and returns its stdout, failing when it
+// exits non-zero, outlives its timeout, or prints too much
+func runPlugin(plugin core.PluginConfig, args ...string) ([]byte, error) {
+	timeout := plugin.Timeout
+	if timeout <= 0 {
+		timeout = core.DefaultPluginTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, plugin.Path, args...)
+	cmd.Env = append(os.Environ(), PluginProtocolEnv+"="+PluginProtocolVersion)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = pluginWaitDelay
+	err := cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("plugin %s %s %w after %s", plugin.Name, args[0], errPluginTimeout, timeout)
+	case err != nil:
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > maxPluginErrorBytes {
+			message = message[:maxPluginErrorBytes]
+		}
+		if message == "" {
+			return nil, fmt.Errorf("plugin %s %s failed: %w", plugin.Name, args[0], err)
+		}
+		return nil, fmt.Errorf("plugin %s %s failed: %s", plugin.Name, args[0], message)
+	case stdout.Len() > maxPluginOutputBytes:
+		return nil, fmt.Errorf("plugin %s %s printed more than %d bytes", plugin.Name, args[0], maxPluginOutputBytes)
+	}
+	return stdout.Bytes(), nil
+}
+
+// PluginCheck is the outcome of one conformance check of a plugin
+type PluginCheck struct {
+	Name string
+	Err  error
+}
+
+// CheckPlugin runs the conformance checks of the plugin protocol against
+// plugin: it must list its installed packages, parse the sample command
+// and the bare binary, and reject a subcommand it cannot know. Sample is
+// the command's arguments after the binary.
+func CheckPlugin(plugin core.PluginConfig, sample []string) []PluginCheck {
+	monitor := NewPluginMonitor(plugin)
+	var checks []PluginCheck
+	check := func(name string, err error) {
+		checks = append(checks, PluginCheck{Name: name, Err: err})
+	}
+
+	if _, err := exec.LookPath(plugin.Path); err != nil {
+		check("plugin is executable", err)
+		return checks
+	}
+	check("plugin is executable", nil)
+
+	_, err := monitor.GetInstalledPackages()
+	check(PluginListInstalledCommand+" prints packages", err)
+
+	binary := monitor.binaryPath
+	samples := [][]string{nil}
+	if len(sample) > 0 {
+		samples = [][]string{sample, nil}
+	}
+	for _, args := range samples {
+		name := PluginParseCommand + " " + strings.Join(append([]string{binary}, args...), " ")
+		record, err := monitor.ParseCommand(binary, args)
+		if err == nil {
+			for _, pkg := range record.PackagesAffected {
+				if strings.TrimSpace(pkg) == "" {
+					err = errors.New("packages_affected lists an empty name")
+					break
+				}
+			}
+		}
+		check(name+" prints a result", err)
+	}
+
+	_, err = runPlugin(plugin, "diu-unknown-subcommand")
+	switch {
+	case err == nil:
+		err = errors.New("exited 0 for an unknown subcommand")
+	case !errors.Is(err, errPluginTimeout):
+		err = nil
+	}
+	check("unknown subcommands fail", err)
+	return checks
+}
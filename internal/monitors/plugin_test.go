@@ -0,0 +1,104 @@
+package monitors
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func writePlugin(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "diu-plugin-mise")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	return path
+}
+
+func TestPluginMonitor(t *testing.T) {
+	path := writePlugin(t, `[ "$DIU_PLUGIN_PROTOCOL" = 1 ] || exit 3
+case "$1" in
+parse) [ "$3" = install ] && echo '{"packages_affected": ["'"$4"'"], "metadata": {"action": "install"}}' || echo '{}' ;;
+list-installed) echo '[{"name": "node", "version": "22.1.0", "tool": "npm"}]' ;;
+*) echo "unknown subcommand $1" >&2; exit 2 ;;
+esac
+`)
+	monitor := NewPluginMonitor(core.PluginConfig{Name: "mise", Path: path})
+
+	record, err := monitor.ParseCommand("mise", []string{"install", "node@22"})
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if record.Tool != "mise" || !reflect.DeepEqual(record.PackagesAffected, []string{"node@22"}) || record.Metadata["action"] != "install" {
+		t.Errorf("Expected node's install, got %+v", record)
+	}
+	if record, err := monitor.ParseCommand("mise", nil); err != nil || record.Metadata == nil {
+		t.Errorf("Expected an empty result accepted, got %+v, %v", record, err)
+	}
+
+	packages, err := monitor.GetInstalledPackages()
+	if err != nil {
+		t.Fatalf("GetInstalledPackages failed: %v", err)
+	}
+	if len(packages) != 1 || packages[0].Tool != "mise" || packages[0].Version != "22.1.0" || packages[0].InstallDate.IsZero() {
+		t.Errorf("Expected node listed for mise, got %+v", packages)
+	}
+
+	if _, err := runPlugin(monitor.plugin, "upgrade"); err == nil || !strings.Contains(err.Error(), "unknown subcommand upgrade") {
+		t.Errorf("Expected the plugin's message, got %v", err)
+	}
+}
+
+func TestPluginMonitorErrors(t *testing.T) {
+	slow := NewPluginMonitor(core.PluginConfig{Name: "mise", Path: writePlugin(t, "sleep 5\n"), Timeout: 50 * time.Millisecond})
+	if _, err := slow.GetInstalledPackages(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+
+	invalid := NewPluginMonitor(core.PluginConfig{Name: "mise", Path: writePlugin(t, "echo '[{\"version\": \"1\"}]'\n")})
+	if _, err := invalid.GetInstalledPackages(); err == nil || !strings.Contains(err.Error(), "without a name") {
+		t.Errorf("Expected a nameless package rejected, got %v", err)
+	}
+	if _, err := invalid.ParseCommand("mise", nil); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected the array rejected for parse, got %v", err)
+	}
+
+	missing := NewPluginMonitor(core.PluginConfig{Name: "mise", Path: filepath.Join(t.TempDir(), "missing")})
+	if err := missing.Initialize(core.DefaultConfig()); err == nil {
+		t.Error("Expected a missing plugin reported")
+	}
+}
+
+func TestCheckPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the reference plugin needs a POSIX shell")
+	}
+	reference := core.PluginConfig{Name: core.ToolCargo, Path: filepath.Join("..", "..", "examples", "plugins", "diu-plugin-cargo")}
+	for _, check := range CheckPlugin(reference, []string{"install", "ripgrep", "--locked"}) {
+		if check.Err != nil {
+			t.Errorf("Expected the reference plugin to pass %q, got %v", check.Name, check.Err)
+		}
+	}
+	record, err := NewPluginMonitor(reference).ParseCommand("cargo", []string{"install", "--version", "14.1.0", "ripgrep"})
+	if err != nil || !reflect.DeepEqual(record.PackagesAffected, []string{"ripgrep"}) || record.Metadata["action"] != "install" {
+		t.Errorf("Expected ripgrep's install, got %+v, %v", record, err)
+	}
+
+	lenient := core.PluginConfig{Name: "mise", Path: writePlugin(t, "echo '[]'\n")}
+	failed := map[string]bool{}
+	for _, check := range CheckPlugin(lenient, nil) {
+		failed[check.Name] = check.Err != nil
+	}
+	if !failed["parse mise prints a result"] || !failed["unknown subcommands fail"] || failed["list-installed prints packages"] {
+		t.Errorf("Expected parse and unknown subcommands to fail, got %v", failed)
+	}
+}
@@ -3,6 +3,7 @@ package monitors
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
@@ -57,6 +58,12 @@ func (m *ProcessMonitor) Initialize(config *core.Config) error {
 	return nil
 }
 
+// WrapperPath is where the monitor's wrapper script is installed, once the
+// monitor is initialized
+func (m *ProcessMonitor) WrapperPath() string {
+	return m.wrapperPath
+}
+
 func (m *ProcessMonitor) findOriginalBinary() (string, error) {
 	if filepath.IsAbs(m.binaryPath) {
 		validatedPath, err := validateExecutablePath(m.binaryPath)
@@ -159,23 +166,53 @@ func writeOwnerExecutableFile(path string, data []byte) (err error) {
 }
 
 func (m *ProcessMonitor) generateWrapperScript() string {
-	return generateProcessWrapperScript(m.originalPath, "diu", m.config.Daemon.SocketPath, m.name)
+	return generateProcessWrapperScript(m.originalPath, "diu", m.config.Daemon.SocketPath, m.name,
+		m.config.Monitoring.Process.OutputLines())
 }
 
-func generateProcessWrapperScript(originalPath, diuPath, socketPath, tool string) string {
+// generateProcessWrapperScript returns a wrapper that runs originalPath and
+// reports the execution. With captureLines above 0 it also keeps the first
+// and last captureLines lines of stdout and stderr.
+func generateProcessWrapperScript(originalPath, diuPath, socketPath, tool string, captureLines int) string {
 	return fmt.Sprintf(`#!/bin/bash
 ORIGINAL="%s"
 DIU_BINARY="%s"
 DIU_SOCKET="%s"
 DIU_TOOL="%s"
+DIU_CAPTURE_LINES=%d
+
+# DIU_DISABLE or DIU_PRIVATE set to anything but 0 opts this command, or
+# every command of a shell that exports it, out of recording.
+if [ "${DIU_DISABLE:-0}" != 0 ] || [ "${DIU_PRIVATE:-0}" != 0 ]; then
+    exec "$ORIGINAL" "$@"
+fi
+
 START_TIME=$(date +%%s)
 
-"$ORIGINAL" "$@"
-EXIT_CODE=$?
+DIU_OUTPUT_DIR=""
+if [ "$DIU_CAPTURE_LINES" -gt 0 ]; then
+    DIU_OUTPUT_DIR=$(mktemp -d 2>/dev/null || true)
+fi
+if [ -n "$DIU_OUTPUT_DIR" ]; then
+    # Both streams go through tee, and the pipelines wait for it to finish
+    # writing before the output is summarized.
+    { "$ORIGINAL" "$@" 2>&1 1>&3 3>&- | tee "$DIU_OUTPUT_DIR/stderr" >&2 3>&-; exit "${PIPESTATUS[0]}"; } 3>&1 | tee "$DIU_OUTPUT_DIR/stdout"
+    EXIT_CODE=${PIPESTATUS[0]}
+else
+    "$ORIGINAL" "$@"
+    EXIT_CODE=$?
+fi
 
 END_TIME=$(date +%%s)
 DURATION=$(( (END_TIME - START_TIME) * 1000 ))
 
+# Executions from one terminal share a session ID: the shell's PID and start
+# time, exported by the shell config or derived from the parent process.
+DIU_SESSION="${DIU_SESSION_ID:-}"
+if [ -z "$DIU_SESSION" ]; then
+    DIU_SESSION="$PPID-$(ps -o lstart= -p "$PPID" 2>/dev/null | cksum | cut -d ' ' -f 1)"
+fi
+
 json_escape() {
     local value="$1"
     value="${value//\\/\\\\}"
@@ -198,6 +235,35 @@ for arg in "$@"; do
 done
 args_json="$args_json]"
 
+# output_summary prints the first and last DIU_CAPTURE_LINES lines of a
+# captured stream without terminal escapes.
+output_summary() {
+    local file="$1" esc
+    [ -s "$file" ] || return 0
+    esc=$(printf '\033')
+    if [ "$(wc -l < "$file")" -gt $((DIU_CAPTURE_LINES * 2)) ]; then
+        { head -n "$DIU_CAPTURE_LINES" "$file"; tail -n "$DIU_CAPTURE_LINES" "$file"; }
+    else
+        cat "$file"
+    fi | LC_ALL=C sed "s/${esc}\[[0-9;?]*[A-Za-z]//g" | LC_ALL=C tr -d '\000-\010\013\014\016-\037' | tail -c %d
+}
+
+output_field=""
+if [ -n "$DIU_OUTPUT_DIR" ]; then
+    output_truncated=false
+    for file in "$DIU_OUTPUT_DIR/stdout" "$DIU_OUTPUT_DIR/stderr"; do
+        if [ -s "$file" ] && [ "$(wc -l < "$file")" -gt $((DIU_CAPTURE_LINES * 2)) ]; then
+            output_truncated=true
+        fi
+    done
+    stdout_summary=$(output_summary "$DIU_OUTPUT_DIR/stdout")
+    stderr_summary=$(output_summary "$DIU_OUTPUT_DIR/stderr")
+    rm -rf "$DIU_OUTPUT_DIR"
+    if [ -n "$stdout_summary$stderr_summary" ]; then
+        output_field="\"output\": {\"stdout\": \"$(json_escape "$stdout_summary")\", \"stderr\": \"$(json_escape "$stderr_summary")\", \"truncated\": $output_truncated},"
+    fi
+fi
+
 payload=$(cat <<EOF
 {
     "tool": "$DIU_TOOL",
@@ -208,6 +274,8 @@ payload=$(cat <<EOF
     "timestamp": "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)",
     "working_dir": "$(json_escape "$(pwd)")",
     "user": "$(json_escape "$(whoami)")",
+    "session_id": "$(json_escape "$DIU_SESSION")",
+    $output_field
     "metadata": {
         "original_path": "$(json_escape "$ORIGINAL")"
     }
@@ -232,7 +300,8 @@ EOF
 } &>/dev/null &
 
 exit $EXIT_CODE
-`, core.ShellEscapeString(originalPath), core.ShellEscapeString(diuPath), core.ShellEscapeString(socketPath), core.ShellEscapeString(tool))
+`, core.ShellEscapeString(originalPath), core.ShellEscapeString(diuPath), core.ShellEscapeString(socketPath), core.ShellEscapeString(tool),
+		captureLines, core.MaxOutputLength)
 }
 
 func (m *ProcessMonitor) updateShellConfig() error {
@@ -248,9 +317,21 @@ func (m *ProcessMonitor) updateShellConfig() error {
 	appendPathConfigIfPresent(zshPath, posixLine)
 	appendPathConfigIfPresent(fishPath, fishLine)
 
+	appendShellConfigIfPresent(bashPath, "# DIU session ID", posixSessionLine)
+	appendShellConfigIfPresent(zshPath, "# DIU session ID", posixSessionLine)
+	appendShellConfigIfPresent(fishPath, "# DIU session ID", fishSessionLine)
+
 	return nil
 }
 
+// The session lines give every interactive shell a new session ID, which
+// commands it runs inherit. Non-interactive shells do not read these files,
+// so scripts keep the session of the terminal that started them.
+const (
+	posixSessionLine = `export ` + core.SessionIDEnv + `="$$-$(date +%s)"`
+	fishSessionLine  = `set -gx ` + core.SessionIDEnv + ` "$fish_pid-"(date +%s)`
+)
+
 func posixPathLine(wrapperDir string) string {
 	quotedWrapperDir := core.ShellEscapeString(wrapperDir)
 	return fmt.Sprintf("export PATH=\"%s:$PATH\"", quotedWrapperDir)
@@ -262,6 +343,12 @@ func fishPathLine(wrapperDir string) string {
 }
 
 func appendPathConfigIfPresent(path, line string) {
+	appendShellConfigIfPresent(path, "# DIU path configuration", line)
+}
+
+// appendShellConfigIfPresent appends line under comment to an existing
+// shell config file that does not contain it yet
+func appendShellConfigIfPresent(path, comment, line string) {
 	if _, err := safefs.Stat(path); err != nil {
 		return
 	}
@@ -274,7 +361,7 @@ func appendPathConfigIfPresent(path, line string) {
 		return
 	}
 	lineWithNewline := line + "\n"
-	_ = appendShellConfigLines(path, "\n# DIU path configuration\n", lineWithNewline)
+	_ = appendShellConfigLines(path, "\n"+comment+"\n", lineWithNewline)
 }
 
 func appendShellConfigLines(path string, lines ...string) (err error) {
@@ -314,6 +401,14 @@ func (m *ProcessMonitor) ExecuteAndTrack(cmd string, args []string) (*core.Execu
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
 	command.Stdin = os.Stdin
+	var stdout, stderr *outputCapture
+	if m.config != nil {
+		if lines := m.config.Monitoring.Process.OutputLines(); lines > 0 {
+			stdout, stderr = newOutputCapture(lines), newOutputCapture(lines)
+			command.Stdout = io.MultiWriter(os.Stdout, stdout)
+			command.Stderr = io.MultiWriter(os.Stderr, stderr)
+		}
+	}
 
 	err = command.Run()
 	exitCode := 0
@@ -328,7 +423,7 @@ func (m *ProcessMonitor) ExecuteAndTrack(cmd string, args []string) (*core.Execu
 	usr, _ := user.Current()
 
 	record := &core.ExecutionRecord{
-		ID:         fmt.Sprintf("exec_%s_%d", time.Now().Format("20060102_150405"), time.Now().UnixNano()),
+		ID:         core.NewID(),
 		Tool:       m.name,
 		Command:    fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")),
 		Args:       args,
@@ -338,6 +433,9 @@ func (m *ProcessMonitor) ExecuteAndTrack(cmd string, args []string) (*core.Execu
 		WorkingDir: workingDir,
 		User:       usr.Username,
 	}
+	if stdout != nil {
+		record.Output = summarizeOutput(stdout, stderr)
+	}
 
 	if parsed, err := m.ParseCommand(cmd, args); err == nil {
 		record.PackagesAffected = parsed.PackagesAffected
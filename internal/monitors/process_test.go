@@ -2,6 +2,8 @@ package monitors
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -190,7 +192,7 @@ func TestProcessMonitorWrapperRecordsWithoutDaemon(t *testing.T) {
 	}
 
 	wrapperPath := filepath.Join(t.TempDir(), "wrapped-tool")
-	script := generateProcessWrapperScript(originalPath, binaryPath, config.Daemon.SocketPath, "test-tool")
+	script := generateProcessWrapperScript(originalPath, binaryPath, config.Daemon.SocketPath, "test-tool", 0)
 	if err := os.WriteFile(wrapperPath, []byte(script), core.PrivateFileMode); err != nil {
 		t.Fatalf("Failed to write wrapper: %v", err)
 	}
@@ -199,7 +201,7 @@ func TestProcessMonitorWrapperRecordsWithoutDaemon(t *testing.T) {
 	}
 
 	run := exec.Command(wrapperPath, "alpha", "beta")
-	run.Env = append(os.Environ(), "HOME="+tempHome)
+	run.Env = append(os.Environ(), "HOME="+tempHome, core.SessionIDEnv+"=4242-1700000000")
 	if output, err := run.CombinedOutput(); err != nil {
 		t.Fatalf("Wrapper failed: %v\n%s", err, output)
 	}
@@ -219,6 +221,9 @@ func TestProcessMonitorWrapperRecordsWithoutDaemon(t *testing.T) {
 				if got := strings.Join(executions[0].Args, " "); got != "alpha beta" {
 					t.Fatalf("Recorded args = %q, want alpha beta", got)
 				}
+				if executions[0].SessionID != "4242-1700000000" {
+					t.Fatalf("Recorded session = %q, want the shell's session ID", executions[0].SessionID)
+				}
 				return
 			}
 		}
@@ -230,6 +235,114 @@ func TestProcessMonitorWrapperRecordsWithoutDaemon(t *testing.T) {
 	}
 }
 
+func TestProcessMonitorWrapperHonorsOptOut(t *testing.T) {
+	for _, env := range []string{"DIU_DISABLE=1", "DIU_PRIVATE=1", "DIU_DISABLE=0"} {
+		t.Run(env, func(t *testing.T) {
+			dir := t.TempDir()
+			ranMarker := filepath.Join(dir, "ran")
+			recordedMarker := filepath.Join(dir, "recorded")
+
+			originalPath := filepath.Join(dir, "original-tool")
+			if err := os.WriteFile(originalPath, []byte("#!/bin/bash\ntouch \""+ranMarker+"\"\nexit 3\n"), core.OwnerExecutableMode); err != nil {
+				t.Fatalf("Failed to write original command: %v", err)
+			}
+			diuPath := filepath.Join(dir, "diu")
+			if err := os.WriteFile(diuPath, []byte("#!/bin/bash\ntouch \""+recordedMarker+"\"\n"), core.OwnerExecutableMode); err != nil {
+				t.Fatalf("Failed to write fake diu: %v", err)
+			}
+
+			wrapperPath := filepath.Join(dir, "wrapped-tool")
+			script := generateProcessWrapperScript(originalPath, diuPath, filepath.Join(dir, "missing.sock"), "test-tool", 0)
+			if err := os.WriteFile(wrapperPath, []byte(script), core.OwnerExecutableMode); err != nil {
+				t.Fatalf("Failed to write wrapper: %v", err)
+			}
+
+			run := exec.Command(wrapperPath, "install")
+			run.Env = append(os.Environ(), "DIU_DISABLE=", "DIU_PRIVATE=", env)
+			err := run.Run()
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+				t.Fatalf("Wrapper error = %v, want the original's exit code 3", err)
+			}
+			if _, err := os.Stat(ranMarker); err != nil {
+				t.Fatalf("Original command did not run: %v", err)
+			}
+
+			if env != "DIU_DISABLE=0" {
+				time.Sleep(500 * time.Millisecond)
+				if _, err := os.Stat(recordedMarker); err == nil {
+					t.Fatal("Wrapper reported an execution that opted out")
+				}
+				return
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if _, err := os.Stat(recordedMarker); err == nil {
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("Timed out waiting for the wrapper to report the execution")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestProcessMonitorWrapperCapturesOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeScript := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/bash\n"+body), core.PrivateFileMode); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chmod(path, core.OwnerExecutableMode); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", name, err)
+		}
+		return path
+	}
+
+	payloadPath := filepath.Join(dir, "payload.json")
+	recorder := writeScript("diu", "cat > "+payloadPath+".tmp && mv "+payloadPath+".tmp "+payloadPath+"\n")
+	original := writeScript("original-tool", `for i in $(seq 1 5); do echo "fetch $i"; done
+printf '\033[31mnpm ERR!\033[0m 404 "left-pad" not found\n' >&2
+exit 1
+`)
+	wrapper := writeScript("wrapped-tool", generateProcessWrapperScript(original, recorder, filepath.Join(dir, "missing.sock"), "npm", 2))
+
+	run := exec.Command(wrapper, "install", "left-pad")
+	var stdout, stderr strings.Builder
+	run.Stdout, run.Stderr = &stdout, &stderr
+	if err := run.Run(); err == nil || run.ProcessState.ExitCode() != 1 {
+		t.Fatalf("Expected the wrapper to exit 1, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "fetch 5") || !strings.Contains(stderr.String(), "npm ERR!") {
+		t.Errorf("Expected output to still reach the terminal, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var payload []byte
+	for {
+		var err error
+		if payload, err = os.ReadFile(payloadPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the wrapper to record the execution")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	var record core.ExecutionRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		t.Fatalf("Wrapper sent invalid JSON: %v\n%s", err, payload)
+	}
+	want := core.Output{Stdout: "fetch 1\nfetch 2\nfetch 4\nfetch 5", Stderr: `npm ERR! 404 "left-pad" not found`, Truncated: true}
+	if record.Output == nil || *record.Output != want {
+		t.Errorf("Output = %+v, want %+v", record.Output, want)
+	}
+}
+
 func TestProcessMonitorInstallWrapper(t *testing.T) {
 	tmpDir := t.TempDir()
 	homeDir := t.TempDir()
@@ -577,6 +690,38 @@ func TestProcessMonitorExecuteAndTrack(t *testing.T) {
 	}
 }
 
+func TestProcessMonitorExecuteAndTrackCapturesOutput(t *testing.T) {
+	binaryPath := filepath.Join(t.TempDir(), "testtool")
+	script := "#!/bin/bash\necho resolving\necho 'error: no matching version' >&2\nexit 1\n"
+	if err := os.WriteFile(binaryPath, []byte(script), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write binary: %v", err)
+	}
+	if err := os.Chmod(binaryPath, core.OwnerExecutableMode); err != nil {
+		t.Fatalf("Failed to chmod binary: %v", err)
+	}
+
+	monitor := NewProcessMonitor("testtool", binaryPath)
+	monitor.originalPath = binaryPath
+	monitor.config = core.DefaultConfig()
+
+	record, err := monitor.ExecuteAndTrack("testtool", nil)
+	if err != nil {
+		t.Fatalf("ExecuteAndTrack failed: %v", err)
+	}
+	if record.Output != nil {
+		t.Errorf("Expected no output without capture_output, got %+v", record.Output)
+	}
+
+	monitor.config.Monitoring.Process.CaptureOutput = true
+	record, err = monitor.ExecuteAndTrack("testtool", nil)
+	if err != nil {
+		t.Fatalf("ExecuteAndTrack failed: %v", err)
+	}
+	if record.Output == nil || record.Output.Stdout != "resolving" || record.Output.Stderr != "error: no matching version" {
+		t.Errorf("Unexpected captured output: %+v", record.Output)
+	}
+}
+
 func TestProcessMonitorUpdateShellConfig(t *testing.T) {
 	homeDir := t.TempDir()
 	zshrc := filepath.Join(homeDir, ".zshrc")
@@ -614,6 +759,9 @@ func TestProcessMonitorUpdateShellConfig(t *testing.T) {
 	if strings.Count(string(content), exportLine) != 1 {
 		t.Fatalf("shell config content = %q, want one export line", content)
 	}
+	if strings.Count(string(content), posixSessionLine) != 1 {
+		t.Fatalf("shell config content = %q, want one session line", content)
+	}
 
 	fishContent, err := os.ReadFile(fishConfig)
 	if err != nil {
@@ -623,6 +771,9 @@ func TestProcessMonitorUpdateShellConfig(t *testing.T) {
 	if strings.Count(string(fishContent), fishLine) != 1 {
 		t.Fatalf("fish config content = %q, want one fish path line", fishContent)
 	}
+	if strings.Count(string(fishContent), fishSessionLine) != 1 {
+		t.Fatalf("fish config content = %q, want one session line", fishContent)
+	}
 	if strings.Contains(string(fishContent), "export PATH=") {
 		t.Fatalf("fish config content = %q, should not use POSIX export", fishContent)
 	}
@@ -0,0 +1,202 @@
+package monitors
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const (
+	makeCommandName = "make"
+	justCommandName = "just"
+	taskCommandName = "task"
+)
+
+// The flags of each task runner whose value is the next argument, and those
+// that only list targets. make's -j and -l take a value only when it is a
+// number.
+var (
+	makeValueFlags = map[string]bool{
+		"-f": true, "--file": true, "--makefile": true,
+		"-C": true, "--directory": true,
+		"-I": true, "--include-dir": true,
+		"-o": true, "--old-file": true, "--assume-old": true,
+		"-W": true, "--what-if": true, "--new-file": true, "--assume-new": true,
+	}
+	makeNumericFlags = map[string]bool{"-j": true, "--jobs": true, "-l": true, "--load-average": true, "--max-load": true}
+
+	justValueFlags = map[string]bool{
+		"-f": true, "--justfile": true,
+		"-d": true, "--working-directory": true,
+		"--shell": true, "--shell-arg": true, "--color": true,
+		"--dotenv-filename": true, "--dotenv-path": true, "-E": true,
+		"--list-heading": true, "--list-prefix": true, "--chooser": true,
+		"-s": true, "--show": true,
+	}
+	justListFlags = []string{"-l", "--list", "--summary", "--choose", "--evaluate", "--variables", "-s", "--show"}
+
+	taskValueFlags = map[string]bool{
+		"-d": true, "--dir": true,
+		"-t": true, "--taskfile": true,
+		"-o": true, "--output": true,
+		"-C": true, "--concurrency": true,
+		"--sort": true, "--interval": true,
+		"--output-group-begin": true, "--output-group-end": true,
+	}
+	taskListFlags = []string{"-l", "--list", "-a", "--list-all", "--summary"}
+)
+
+type MakeMonitor struct {
+	*ProcessMonitor
+}
+
+func NewMakeMonitor() Monitor {
+	return &MakeMonitor{
+		ProcessMonitor: NewProcessMonitor(core.ToolMake, makeCommandName),
+	}
+}
+
+func (m *MakeMonitor) Initialize(config *core.Config) error {
+	if _, err := exec.LookPath(makeCommandName); err != nil {
+		return fmt.Errorf("make not found: %w", err)
+	}
+	return m.ProcessMonitor.Initialize(config)
+}
+
+func (m *MakeMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	return parseTaskRunnerCommand(core.ToolMake, cmd, args, taskRunnerTargets(args, makeValueFlags, makeNumericFlags), nil), nil
+}
+
+// GetInstalledPackages returns nothing: make runs targets, not packages
+func (m *MakeMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	return nil, nil
+}
+
+func (m *MakeMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return m.ProcessMonitor.Start(ctx, eventChan)
+}
+
+type JustMonitor struct {
+	*ProcessMonitor
+}
+
+func NewJustMonitor() Monitor {
+	return &JustMonitor{
+		ProcessMonitor: NewProcessMonitor(core.ToolJust, justCommandName),
+	}
+}
+
+func (m *JustMonitor) Initialize(config *core.Config) error {
+	if _, err := exec.LookPath(justCommandName); err != nil {
+		return fmt.Errorf("just not found: %w", err)
+	}
+	return m.ProcessMonitor.Initialize(config)
+}
+
+// ParseCommand records the recipe just runs. Only the first is recorded:
+// the arguments after it may be its parameters rather than more recipes,
+// and only the justfile tells them apart.
+func (m *JustMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	targets := taskRunnerTargets(args, justValueFlags, nil)
+	if len(targets) > 1 {
+		targets = targets[:1]
+	}
+	return parseTaskRunnerCommand(core.ToolJust, cmd, args, targets, justListFlags), nil
+}
+
+// GetInstalledPackages returns nothing: just runs recipes, not packages
+func (m *JustMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	return nil, nil
+}
+
+func (m *JustMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return m.ProcessMonitor.Start(ctx, eventChan)
+}
+
+type TaskMonitor struct {
+	*ProcessMonitor
+}
+
+func NewTaskMonitor() Monitor {
+	return &TaskMonitor{
+		ProcessMonitor: NewProcessMonitor(core.ToolTask, taskCommandName),
+	}
+}
+
+func (m *TaskMonitor) Initialize(config *core.Config) error {
+	if _, err := exec.LookPath(taskCommandName); err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	return m.ProcessMonitor.Initialize(config)
+}
+
+func (m *TaskMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	return parseTaskRunnerCommand(core.ToolTask, cmd, args, taskRunnerTargets(args, taskValueFlags, nil), taskListFlags), nil
+}
+
+// GetInstalledPackages returns nothing: task runs tasks, not packages
+func (m *TaskMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	return nil, nil
+}
+
+func (m *TaskMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return m.ProcessMonitor.Start(ctx, eventChan)
+}
+
+// parseTaskRunnerCommand records the targets a task runner ran. The first
+// target is the subcommand, so ignore_subcommands and track_subcommands
+// apply to targets; a run without one runs the default target, unless a
+// flag in listFlags only lists the targets.
+func parseTaskRunnerCommand(tool, cmd string, args, targets, listFlags []string) *core.ExecutionRecord {
+	record := &core.ExecutionRecord{
+		Tool:     tool,
+		Command:  cmd,
+		Args:     args,
+		Metadata: make(map[string]interface{}),
+	}
+
+	if len(targets) > 0 {
+		record.Metadata["subcommand"] = targets[0]
+		record.Metadata["targets"] = targets
+		return record
+	}
+	for _, flag := range listFlags {
+		if contains(args, flag) {
+			record.Metadata["action"] = "list"
+			return record
+		}
+	}
+	record.Metadata["default_target"] = true
+	return record
+}
+
+// taskRunnerTargets returns the targets named in args: the arguments that
+// are not flags, the values of valueFlags, or VAR=value assignments, up to
+// a --. The flags in numericFlags take the next argument only when it is a
+// number.
+func taskRunnerTargets(args []string, valueFlags, numericFlags map[string]bool) []string {
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return targets
+		case valueFlags[arg]:
+			i++
+		case numericFlags[arg]:
+			if i+1 < len(args) {
+				if _, err := strconv.ParseFloat(args[i+1], 64); err == nil {
+					i++
+				}
+			}
+		case arg == "" || strings.HasPrefix(arg, "-") || strings.Contains(arg, "="):
+		default:
+			targets = append(targets, arg)
+		}
+	}
+	return targets
+}
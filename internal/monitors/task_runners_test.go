@@ -0,0 +1,74 @@
+package monitors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestTaskRunnerParseCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		monitor     Monitor
+		args        []string
+		wantTargets []string
+		wantAction  string
+	}{
+		{name: "make targets", monitor: NewMakeMonitor(), args: []string{"-j", "8", "-C", "src", "build", "VERBOSE=1", "test"}, wantTargets: []string{"build", "test"}},
+		{name: "make bare -j", monitor: NewMakeMonitor(), args: []string{"-j", "install"}, wantTargets: []string{"install"}},
+		{name: "make default", monitor: NewMakeMonitor(), args: []string{"-f", "build.mk"}},
+		{name: "just recipe with arguments", monitor: NewJustMonitor(), args: []string{"--justfile", "ci.just", "deploy", "staging"}, wantTargets: []string{"deploy"}},
+		{name: "just list", monitor: NewJustMonitor(), args: []string{"--list"}, wantAction: "list"},
+		{name: "task targets", monitor: NewTaskMonitor(), args: []string{"-t", "Taskfile.ci.yml", "lint", "test", "--", "-run", "TestX"}, wantTargets: []string{"lint", "test"}},
+		{name: "task list", monitor: NewTaskMonitor(), args: []string{"-a"}, wantAction: "list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := tt.monitor.ParseCommand(tt.monitor.Name(), tt.args)
+			if err != nil {
+				t.Fatalf("ParseCommand failed: %v", err)
+			}
+			if record.Tool != tt.monitor.Name() {
+				t.Fatalf("Tool = %s, want %s", record.Tool, tt.monitor.Name())
+			}
+			targets, _ := record.Metadata["targets"].([]string)
+			if !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("targets = %#v, want %#v", targets, tt.wantTargets)
+			}
+			if len(tt.wantTargets) > 0 && record.Metadata["subcommand"] != tt.wantTargets[0] {
+				t.Errorf("subcommand = %v, want %s", record.Metadata["subcommand"], tt.wantTargets[0])
+			}
+			if action, _ := record.Metadata["action"].(string); action != tt.wantAction {
+				t.Errorf("action = %q, want %q", action, tt.wantAction)
+			}
+			wantDefault := len(tt.wantTargets) == 0 && tt.wantAction == ""
+			if got := record.Metadata["default_target"] == true; got != wantDefault {
+				t.Errorf("default_target = %v, want %v", got, wantDefault)
+			}
+			if len(record.PackagesAffected) != 0 {
+				t.Errorf("PackagesAffected = %#v, want none", record.PackagesAffected)
+			}
+		})
+	}
+}
+
+func TestTaskRunnerTrackSubcommands(t *testing.T) {
+	config := core.DefaultConfig()
+	config.Tools.Make.TrackSubcommands = []string{"release"}
+
+	record, err := NewMakeMonitor().ParseCommand("make", []string{"release"})
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if config.IgnoreExecution(record) {
+		t.Error("Expected the tracked target to be recorded")
+	}
+	record, err = NewMakeMonitor().ParseCommand("make", []string{"lint"})
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if !config.IgnoreExecution(record) {
+		t.Error("Expected an untracked target to be dropped")
+	}
+}
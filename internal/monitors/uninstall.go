@@ -0,0 +1,78 @@
+package monitors
+
+import (
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const (
+	uninstallSubcommand = "uninstall"
+	removeSubcommand    = "remove"
+)
+
+// Uninstaller is implemented by monitors whose tool can remove a package it
+// installed. UninstallCommand returns the command line that removes pkg
+// without prompting.
+type Uninstaller interface {
+	UninstallCommand(pkg *core.PackageInfo) ([]string, error)
+}
+
+// MonitorTool returns the tool of the monitor that tracks the packages of
+// tool: casks are listed by the homebrew monitor
+func MonitorTool(tool string) string {
+	if tool == homebrewCaskTool {
+		return core.ToolHomebrew
+	}
+	return tool
+}
+
+func (m *HomebrewMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	if pkg.Tool == homebrewCaskTool {
+		return []string{homebrewCommandName, uninstallSubcommand, homebrewCaskArg, pkg.Name}, nil
+	}
+	return []string{homebrewCommandName, uninstallSubcommand, pkg.Name}, nil
+}
+
+func (m *NPMMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{npmCommandName, uninstallSubcommand, npmGlobalFlag, pkg.Name}, nil
+}
+
+func (m *PNPMMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{pnpmCommandName, removeSubcommand, jsGlobalShortFlag, pkg.Name}, nil
+}
+
+func (m *BunMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{bunCommandName, removeSubcommand, jsGlobalShortFlag, pkg.Name}, nil
+}
+
+func (m *YarnMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{yarnCommandName, yarnGlobalArg, removeSubcommand, pkg.Name}, nil
+}
+
+func (m *PipMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{m.commandName, uninstallSubcommand, "-y", pkg.Name}, nil
+}
+
+func (m *UVMonitor) UninstallCommand(pkg *core.PackageInfo) ([]string, error) {
+	if err := core.ValidatePackageName(pkg.Name); err != nil {
+		return nil, err
+	}
+	return []string{uvCommandName, "tool", uninstallSubcommand, pkg.Name}, nil
+}
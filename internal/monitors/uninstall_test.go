@@ -0,0 +1,44 @@
+package monitors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestUninstallCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		monitor Monitor
+		pkg     *core.PackageInfo
+		want    []string
+	}{
+		{name: "formula", monitor: NewHomebrewMonitor(), pkg: &core.PackageInfo{Tool: core.ToolHomebrew, Name: "wget"}, want: []string{"brew", "uninstall", "wget"}},
+		{name: "cask", monitor: NewHomebrewMonitor(), pkg: &core.PackageInfo{Tool: homebrewCaskTool, Name: "vlc"}, want: []string{"brew", "uninstall", "--cask", "vlc"}},
+		{name: "npm", monitor: NewNPMMonitor(), pkg: &core.PackageInfo{Tool: core.ToolNPM, Name: "@antfu/ni"}, want: []string{"npm", "uninstall", "-g", "@antfu/ni"}},
+		{name: "pnpm", monitor: NewPNPMMonitor(), pkg: &core.PackageInfo{Tool: core.ToolPNPM, Name: "tsx"}, want: []string{"pnpm", "remove", "-g", "tsx"}},
+		{name: "yarn", monitor: NewYarnMonitor(), pkg: &core.PackageInfo{Tool: core.ToolYarn, Name: "serve"}, want: []string{"yarn", "global", "remove", "serve"}},
+		{name: "pip", monitor: NewPipMonitor(), pkg: &core.PackageInfo{Tool: core.ToolPip, Name: "httpie"}, want: []string{"pip", "uninstall", "-y", "httpie"}},
+		{name: "uv", monitor: NewUVMonitor(), pkg: &core.PackageInfo{Tool: core.ToolUV, Name: "ruff"}, want: []string{"uv", "tool", "uninstall", "ruff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uninstaller, ok := tt.monitor.(Uninstaller)
+			if !ok {
+				t.Fatalf("%s monitor does not implement Uninstaller", tt.monitor.Name())
+			}
+			got, err := uninstaller.UninstallCommand(tt.pkg)
+			if err != nil {
+				t.Fatalf("UninstallCommand failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UninstallCommand = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewNPMMonitor().(Uninstaller).UninstallCommand(&core.PackageInfo{Tool: core.ToolNPM, Name: "--force"}); err == nil {
+		t.Error("Expected a flag-like package name to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package monitors
+
+import (
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// versionChangingActions are the metadata actions, and
+// versionChangingSubcommands the subcommands without one, of executions
+// that install or upgrade packages
+var (
+	versionChangingActions     = []string{"install", "add", "get", "update", "reinstall"}
+	versionChangingSubcommands = []string{"upgrade", "update", "up"}
+)
+
+// ChangesVersions reports whether record successfully installed or
+// upgraded packages
+func ChangesVersions(record *core.ExecutionRecord) bool {
+	if record.ExitCode != 0 {
+		return false
+	}
+	if action, _ := record.Metadata["action"].(string); action != "" {
+		return contains(versionChangingActions, action)
+	}
+	subcommand, _ := record.Metadata["subcommand"].(string)
+	return contains(versionChangingSubcommands, subcommand)
+}
+
+// RecordVersionChanges asks the monitor's tool which versions an install or
+// upgrade left installed and keeps them in record's metadata, next to the
+// versions known before from previous. An upgrade of everything records the
+// packages whose version changed. Other executions, and tools that cannot
+// list their packages, are left alone.
+func RecordVersionChanges(monitor Monitor, record *core.ExecutionRecord, previous func(name string) string) {
+	if !ChangesVersions(record) {
+		return
+	}
+	upgradeAll := record.Metadata["upgrade_all"] == true || record.Metadata["update_all"] == true
+	if len(record.PackagesAffected) == 0 && !upgradeAll {
+		return
+	}
+
+	installed, err := monitor.GetInstalledPackages()
+	if err != nil || len(installed) == 0 {
+		return
+	}
+
+	var changes []core.VersionChange
+	if len(record.PackagesAffected) > 0 {
+		versions := make(map[string]string, len(installed))
+		for _, pkg := range installed {
+			versions[pkg.Name] = pkg.Version
+		}
+		for _, name := range record.PackagesAffected {
+			if to := versions[name]; to != "" {
+				changes = append(changes, core.VersionChange{Package: name, From: previous(name), To: to})
+			}
+		}
+	} else {
+		for _, pkg := range installed {
+			if from := previous(pkg.Name); from != "" && pkg.Version != "" && from != pkg.Version {
+				changes = append(changes, core.VersionChange{Package: pkg.Name, From: from, To: pkg.Version})
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	if record.Metadata == nil {
+		record.Metadata = make(map[string]interface{})
+	}
+	record.Metadata[core.MetadataVersionChanges] = changes
+}
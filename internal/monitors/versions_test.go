@@ -0,0 +1,76 @@
+package monitors
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// listingMonitor is a monitor whose tool lists fixed packages
+type listingMonitor struct {
+	*BaseMonitor
+	installed []*core.PackageInfo
+	listed    int
+}
+
+func (m *listingMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return nil
+}
+
+func (m *listingMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	m.listed++
+	return m.installed, nil
+}
+
+func (m *listingMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	return &core.ExecutionRecord{}, nil
+}
+
+func TestRecordVersionChanges(t *testing.T) {
+	monitor := &listingMonitor{BaseMonitor: NewBaseMonitor(core.ToolHomebrew), installed: []*core.PackageInfo{
+		{Name: "jq", Version: "1.7.1"},
+		{Name: "ripgrep", Version: "14.1.0"},
+		{Name: "wget", Version: "1.24.5"},
+	}}
+	known := map[string]string{"jq": "1.7", "ripgrep": "14.1.0", "wget": "1.21"}
+	previous := func(name string) string { return known[name] }
+
+	tests := []struct {
+		name   string
+		record *core.ExecutionRecord
+		want   []core.VersionChange
+	}{
+		{
+			name: "install",
+			record: &core.ExecutionRecord{PackagesAffected: []string{"jq", "fd"},
+				Metadata: map[string]interface{}{"subcommand": "install", "action": "install"}},
+			want: []core.VersionChange{{Package: "jq", From: "1.7", To: "1.7.1"}},
+		},
+		{
+			name:   "upgrade all",
+			record: &core.ExecutionRecord{Metadata: map[string]interface{}{"subcommand": "upgrade", "upgrade_all": true}},
+			want:   []core.VersionChange{{Package: "jq", From: "1.7", To: "1.7.1"}, {Package: "wget", From: "1.21", To: "1.24.5"}},
+		},
+		{
+			name: "failed install",
+			record: &core.ExecutionRecord{ExitCode: 1, PackagesAffected: []string{"jq"},
+				Metadata: map[string]interface{}{"action": "install"}},
+		},
+		{
+			name: "uninstall",
+			record: &core.ExecutionRecord{PackagesAffected: []string{"jq"},
+				Metadata: map[string]interface{}{"subcommand": "uninstall", "action": "uninstall"}},
+		},
+	}
+	for _, tt := range tests {
+		RecordVersionChanges(monitor, tt.record, previous)
+		if got := tt.record.VersionChanges(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: version changes = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if monitor.listed != 2 {
+		t.Errorf("Expected the tool to be asked only after successful installs and upgrades, got %d listings", monitor.listed)
+	}
+}
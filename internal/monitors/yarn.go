@@ -0,0 +1,183 @@
+package monitors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+const (
+	yarnCommandName = "yarn"
+	yarnGlobalArg   = "global"
+
+	yarnRCFile       = ".yarnrc.yml"
+	yarnLockFile     = "yarn.lock"
+	yarnBerryLockKey = "__metadata:"
+
+	// Yarn's node linkers, recorded as node_linker. Berry uses pnp unless
+	// .yarnrc.yml sets nodeLinker; classic always installs node_modules.
+	YarnLinkerPnP         = "pnp"
+	YarnLinkerNodeModules = "node-modules"
+)
+
+type YarnMonitor struct {
+	*ProcessMonitor
+}
+
+func NewYarnMonitor() Monitor {
+	return &YarnMonitor{
+		ProcessMonitor: NewProcessMonitor(core.ToolYarn, yarnCommandName),
+	}
+}
+
+func (m *YarnMonitor) Initialize(config *core.Config) error {
+	if _, err := exec.LookPath(yarnCommandName); err != nil {
+		return fmt.Errorf("yarn not found: %w", err)
+	}
+	return m.ProcessMonitor.Initialize(config)
+}
+
+// ParseCommand parses yarn the way the other JavaScript managers are
+// parsed. A bare yarn installs the project, and classic's yarn global
+// prefix marks the command global.
+func (m *YarnMonitor) ParseCommand(cmd string, args []string) (*core.ExecutionRecord, error) {
+	parseArgs := args
+	global := len(parseArgs) > 0 && parseArgs[0] == yarnGlobalArg
+	if global {
+		parseArgs = parseArgs[1:]
+	}
+	if len(parseArgs) == 0 || strings.HasPrefix(parseArgs[0], "-") {
+		parseArgs = append([]string{"install"}, parseArgs...)
+	}
+
+	record := parseJavaScriptManagerCommand(core.ToolYarn, cmd, parseArgs)
+	record.Args = args
+	if global {
+		record.Metadata["global"] = true
+	}
+	return record, nil
+}
+
+// annotateWorkingDir records the package.json of a script run, and whether
+// the project record ran in uses Yarn Berry and which node linker installs
+// its packages
+func (m *YarnMonitor) annotateWorkingDir(record *core.ExecutionRecord) {
+	annotateScript(record)
+
+	berry, linker, ok := detectYarnProject(record.WorkingDir)
+	if !ok {
+		return
+	}
+	if record.Metadata == nil {
+		record.Metadata = make(map[string]interface{})
+	}
+	if _, exists := record.Metadata["yarn_berry"]; !exists {
+		record.Metadata["yarn_berry"] = berry
+	}
+	if _, exists := record.Metadata["node_linker"]; !exists {
+		record.Metadata["node_linker"] = linker
+	}
+}
+
+// detectYarnProject walks up from dir to the nearest directory with a
+// .yarnrc.yml or yarn.lock. The project uses Berry when it has a
+// .yarnrc.yml or its lockfile has Berry's __metadata entry. The
+// .yarnrc.yml in the home directory is Berry's user config rather than a
+// project's, so it is passed over. It reports false outside a yarn project.
+func detectYarnProject(dir string) (berry bool, linker string, ok bool) {
+	if dir == "" {
+		return false, "", false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, "", false
+	}
+	home, _ := os.UserHomeDir()
+
+	for {
+		if dir != home {
+			if data, err := safefs.ReadFile(filepath.Join(dir, yarnRCFile)); err == nil {
+				if linker := yarnNodeLinker(data); linker != "" {
+					return true, linker, true
+				}
+				return true, YarnLinkerPnP, true
+			}
+		}
+		if data, err := safefs.ReadFile(filepath.Join(dir, yarnLockFile)); err == nil {
+			if bytes.Contains(data, []byte(yarnBerryLockKey)) {
+				return true, YarnLinkerPnP, true
+			}
+			return false, YarnLinkerNodeModules, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false, "", false
+		}
+		dir = parent
+	}
+}
+
+// yarnNodeLinker returns the top-level nodeLinker setting of a .yarnrc.yml
+func yarnNodeLinker(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "nodeLinker:")
+		if !ok {
+			continue
+		}
+		value, _, _ = strings.Cut(value, "#")
+		return strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return ""
+}
+
+// GetInstalledPackages lists the packages yarn global add installed. Only
+// classic yarn has global packages; Berry runs tools with yarn dlx.
+func (m *YarnMonitor) GetInstalledPackages() ([]*core.PackageInfo, error) {
+	output, err := exec.Command(yarnCommandName, yarnGlobalArg, "list").Output()
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("failed to list global yarn packages: %w", err)
+	}
+	return parseYarnGlobalList(string(output)), nil
+}
+
+// parseYarnGlobalList reads the packages from lines such as
+// info "typescript@5.4.5" has binaries: in yarn global list
+func parseYarnGlobalList(output string) []*core.PackageInfo {
+	var packages []*core.PackageInfo
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), `info "`)
+		if !ok {
+			continue
+		}
+		spec, _, ok := strings.Cut(line, `"`)
+		if !ok {
+			continue
+		}
+		name, version := splitPackageVersion(spec)
+		if name == "" {
+			continue
+		}
+		packages = append(packages, &core.PackageInfo{
+			Name:        name,
+			Version:     version,
+			Tool:        core.ToolYarn,
+			InstallDate: time.Now(),
+		})
+	}
+	return packages
+}
+
+func (m *YarnMonitor) Start(ctx context.Context, eventChan chan<- *core.ExecutionRecord) error {
+	return m.ProcessMonitor.Start(ctx, eventChan)
+}
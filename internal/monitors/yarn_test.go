@@ -0,0 +1,123 @@
+package monitors
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestYarnParseCommand(t *testing.T) {
+	monitor := NewYarnMonitor().(*YarnMonitor)
+
+	tests := []struct {
+		name          string
+		args          []string
+		wantAction    string
+		wantPackages  []string
+		wantGlobal    bool
+		wantEphemeral bool
+	}{
+		{name: "bare install", args: nil, wantAction: "install"},
+		{name: "install flags", args: []string{"--immutable"}, wantAction: "install"},
+		{name: "add", args: []string{"add", "-D", "typescript@5.5.0", "@types/node"}, wantAction: "install", wantPackages: []string{"typescript", "@types/node"}},
+		{name: "remove", args: []string{"remove", "lodash"}, wantAction: "uninstall", wantPackages: []string{"lodash"}},
+		{name: "classic global add", args: []string{"global", "add", "serve@14"}, wantAction: "install", wantPackages: []string{"serve"}, wantGlobal: true},
+		{name: "dlx", args: []string{"dlx", "create-vite@5", "my-app"}, wantAction: "exec", wantPackages: []string{"create-vite"}, wantEphemeral: true},
+		{name: "dlx with packages", args: []string{"dlx", "-p", "typescript", "--package=@types/node", "tsc", "--init"}, wantAction: "exec", wantPackages: []string{"typescript", "@types/node"}, wantEphemeral: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := monitor.ParseCommand("yarn", tt.args)
+			if err != nil {
+				t.Fatalf("ParseCommand failed: %v", err)
+			}
+			if record.Tool != core.ToolYarn {
+				t.Fatalf("Tool = %s, want %s", record.Tool, core.ToolYarn)
+			}
+			if !reflect.DeepEqual(record.Args, tt.args) {
+				t.Errorf("Args = %#v, want %#v", record.Args, tt.args)
+			}
+			if record.Metadata["action"] != tt.wantAction {
+				t.Errorf("action = %v, want %s", record.Metadata["action"], tt.wantAction)
+			}
+			if len(record.PackagesAffected) != len(tt.wantPackages) || (len(tt.wantPackages) > 0 && !reflect.DeepEqual(record.PackagesAffected, tt.wantPackages)) {
+				t.Errorf("PackagesAffected = %#v, want %#v", record.PackagesAffected, tt.wantPackages)
+			}
+			if got := record.Metadata["global"] == true; got != tt.wantGlobal {
+				t.Errorf("global = %v, want %v", record.Metadata["global"], tt.wantGlobal)
+			}
+			if got := record.Metadata["ephemeral"] == true; got != tt.wantEphemeral {
+				t.Errorf("ephemeral = %v, want %v", record.Metadata["ephemeral"], tt.wantEphemeral)
+			}
+		})
+	}
+}
+
+func TestDetectYarnProject(t *testing.T) {
+	write := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), core.PrivateFileMode); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	root := t.TempDir()
+	write(t, filepath.Join(root, "berry", yarnRCFile), "yarnPath: .yarn/releases/yarn-4.1.0.cjs\n")
+	write(t, filepath.Join(root, "linker", yarnRCFile), "enableTelemetry: false\nnodeLinker: \"node-modules\" # for React Native\n")
+	write(t, filepath.Join(root, "lockfile", yarnLockFile), "__metadata:\n  version: 8\n")
+	write(t, filepath.Join(root, "classic", yarnLockFile), "# yarn lockfile v1\n")
+
+	tests := []struct {
+		dir        string
+		wantBerry  bool
+		wantLinker string
+		wantOK     bool
+	}{
+		{dir: filepath.Join(root, "berry", "packages", "app"), wantBerry: true, wantLinker: YarnLinkerPnP, wantOK: true},
+		{dir: filepath.Join(root, "linker"), wantBerry: true, wantLinker: YarnLinkerNodeModules, wantOK: true},
+		{dir: filepath.Join(root, "lockfile"), wantBerry: true, wantLinker: YarnLinkerPnP, wantOK: true},
+		{dir: filepath.Join(root, "classic"), wantBerry: false, wantLinker: YarnLinkerNodeModules, wantOK: true},
+		{dir: filepath.Join(root, "none")},
+		{dir: ""},
+	}
+	for _, tt := range tests {
+		berry, linker, ok := detectYarnProject(tt.dir)
+		if berry != tt.wantBerry || linker != tt.wantLinker || ok != tt.wantOK {
+			t.Errorf("detectYarnProject(%q) = %v, %q, %v; want %v, %q, %v", tt.dir, berry, linker, ok, tt.wantBerry, tt.wantLinker, tt.wantOK)
+		}
+	}
+
+	record := &core.ExecutionRecord{Tool: core.ToolYarn, Command: "yarn", Args: []string{"add", "zod"}, WorkingDir: filepath.Join(root, "linker")}
+	if err := EnrichExecutionRecord(NewYarnMonitor(), record); err != nil {
+		t.Fatalf("EnrichExecutionRecord failed: %v", err)
+	}
+	if record.Metadata["yarn_berry"] != true || record.Metadata["node_linker"] != YarnLinkerNodeModules {
+		t.Errorf("Metadata = %#v, want Berry with the node-modules linker", record.Metadata)
+	}
+}
+
+func TestParseYarnGlobalList(t *testing.T) {
+	output := `yarn global v1.22.22
+info "serve@14.2.1" has binaries:
+   - serve
+info "@vue/cli@5.0.8" has binaries:
+   - vue
+Done in 0.12s.
+`
+	packages := parseYarnGlobalList(output)
+	if len(packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %#v", packages)
+	}
+	if packages[0].Name != "serve" || packages[0].Version != "14.2.1" || packages[0].Tool != core.ToolYarn {
+		t.Errorf("Unexpected first package: %#v", packages[0])
+	}
+	if packages[1].Name != "@vue/cli" || packages[1].Version != "5.0.8" {
+		t.Errorf("Unexpected second package: %#v", packages[1])
+	}
+}
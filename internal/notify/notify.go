@@ -0,0 +1,313 @@
+// Package notify posts daemon events to chat services, webhooks, and the
+// desktop.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+const (
+	EventExecutionIngested = "execution_ingested"
+	EventPackageInstalled  = "package_installed"
+	EventPackageUnused     = "package_unused"
+	EventDailySummary      = "daily_summary"
+	EventWeeklySummary     = "weekly_summary"
+	EventStorageRecovered  = "storage_recovered"
+	EventPackageQueued     = "package_queued"
+	EventPackageRemoved    = "package_removed"
+	EventThresholdExceeded = "threshold_exceeded"
+
+	ServiceSlack   = "slack"
+	ServiceDiscord = "discord"
+
+	// discordContentLimit is the longest message Discord accepts.
+	discordContentLimit = 2000
+
+	defaultHTTPTimeout  = 10 * time.Second
+	defaultRetryBackoff = time.Second
+)
+
+var defaultTemplates = map[string]string{
+	EventExecutionIngested: "{{.Tool}}: {{.Command}}",
+	EventPackageInstalled:  "New {{.Tool}} package installed: {{.Package}}",
+	EventPackageUnused:     "{{.Package}} ({{.Tool}}) has not been used in {{.UnusedDays}} days",
+	EventDailySummary:      "```\n{{.Summary}}```",
+	EventWeeklySummary:     "```\n{{.Summary}}```",
+	EventStorageRecovered:  "diu restored its storage from a backup: {{.Summary}}",
+	EventPackageQueued:     "{{.Package}} ({{.Tool}}) is queued for removal after {{.UnusedDays}} days unused",
+	EventPackageRemoved:    "diu uninstalled {{.Package}} ({{.Tool}}), unused for {{.UnusedDays}} days: {{.Command}}",
+	EventThresholdExceeded: "diu alert: {{.Summary}}",
+}
+
+// Event is the data available to message templates and webhook payloads.
+type Event struct {
+	Type    string
+	Time    time.Time
+	Tool    string
+	Package string
+	Command string
+	// Execution is the stored record for execution_ingested events.
+	Execution *core.ExecutionRecord
+	// LastUsed and UnusedDays describe package_unused, package_queued, and
+	// package_removed events. UnusedDays is the threshold that was crossed.
+	LastUsed   time.Time
+	UnusedDays int
+	// Local marks package_installed events for packages installed into a
+	// project or run once, rather than installed globally.
+	Local bool
+	// Summary holds the rendered report for summary events, what was
+	// recovered for storage_recovered events, and the crossed limit for
+	// threshold_exceeded events.
+	Summary string
+}
+
+// Notifier delivers events to the configured chats, webhooks, and rules.
+type Notifier struct {
+	chats    []chat
+	webhooks []webhook
+	rules    []rule
+	client   *http.Client
+	// desktop shows a desktop notification; tests replace it.
+	desktop func(ctx context.Context, title, message string) error
+	// backoff is the delay before the first webhook retry; it doubles
+	// after each attempt.
+	backoff time.Duration
+}
+
+type chat struct {
+	service  string
+	url      string
+	events   map[string]bool
+	template *template.Template
+}
+
+// New validates config and returns a Notifier for it.
+func New(config core.NotificationsConfig) (*Notifier, error) {
+	n := &Notifier{
+		client:  &http.Client{Timeout: defaultHTTPTimeout},
+		backoff: defaultRetryBackoff,
+		desktop: showDesktopNotification,
+	}
+	for i, entry := range config.Chat {
+		c, err := newChat(entry)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.chat[%d]: %w", i, err)
+		}
+		n.chats = append(n.chats, c)
+	}
+	for i, entry := range config.Webhooks {
+		w, err := newWebhook(entry)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.webhooks[%d]: %w", i, err)
+		}
+		n.webhooks = append(n.webhooks, w)
+	}
+	for i, entry := range config.Rules {
+		r, err := newRule(entry)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.rules[%d]: %w", i, err)
+		}
+		n.rules = append(n.rules, r)
+	}
+	return n, nil
+}
+
+func validateURL(rawURL string) error {
+	if !strings.HasPrefix(rawURL, "https://") && !strings.HasPrefix(rawURL, "http://") {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	return nil
+}
+
+func parseEvents(events []string) (map[string]bool, error) {
+	parsed := make(map[string]bool, len(events))
+	for _, event := range events {
+		if _, ok := defaultTemplates[event]; !ok {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+		parsed[event] = true
+	}
+	return parsed, nil
+}
+
+func newChat(entry core.ChatConfig) (chat, error) {
+	service := strings.ToLower(entry.Service)
+	if service != ServiceSlack && service != ServiceDiscord {
+		return chat{}, fmt.Errorf("unknown service %q (use %s or %s)", entry.Service, ServiceSlack, ServiceDiscord)
+	}
+	if err := validateURL(entry.URL); err != nil {
+		return chat{}, err
+	}
+	events, err := parseEvents(entry.Events)
+	if err != nil {
+		return chat{}, err
+	}
+
+	c := chat{service: service, url: entry.URL, events: events}
+	if entry.Template != "" {
+		tmpl, err := template.New(service).Parse(entry.Template)
+		if err != nil {
+			return chat{}, fmt.Errorf("invalid template: %w", err)
+		}
+		c.template = tmpl
+	}
+	return c, nil
+}
+
+// Subscribed reports whether any chat, webhook, or rule wants events of
+// the given type.
+func (n *Notifier) Subscribed(eventType string) bool {
+	if n == nil {
+		return false
+	}
+	for _, c := range n.chats {
+		if c.events[eventType] {
+			return true
+		}
+	}
+	for _, w := range n.webhooks {
+		if w.events[eventType] {
+			return true
+		}
+	}
+	for _, r := range n.rules {
+		if r.event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// UnusedThresholds returns the distinct package_unused thresholds, in days,
+// that targets are subscribed to. Chats use the default threshold.
+func (n *Notifier) UnusedThresholds() []int {
+	if n == nil {
+		return nil
+	}
+	seen := make(map[int]bool)
+	var thresholds []int
+	add := func(days int) {
+		if !seen[days] {
+			seen[days] = true
+			thresholds = append(thresholds, days)
+		}
+	}
+	for _, c := range n.chats {
+		if c.events[EventPackageUnused] {
+			add(core.DefaultWebhookUnusedDays)
+		}
+	}
+	for _, w := range n.webhooks {
+		if w.events[EventPackageUnused] {
+			add(w.unusedDays)
+		}
+	}
+	for _, r := range n.rules {
+		if r.event == EventPackageUnused {
+			add(r.unusedDays)
+		}
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// Notify delivers event to every chat and webhook subscribed to it and to
+// the targets of every rule it matches.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	if n == nil {
+		return nil
+	}
+	var errs []error
+	for _, c := range n.chats {
+		if !c.events[event.Type] || !matchesThreshold(event, core.DefaultWebhookUnusedDays) {
+			continue
+		}
+		if err := n.post(ctx, c, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.service, err))
+		}
+	}
+	for _, w := range n.webhooks {
+		if !w.events[event.Type] || !matchesThreshold(event, w.unusedDays) {
+			continue
+		}
+		if err := n.deliver(ctx, w, event); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", w.url, err))
+		}
+	}
+	for _, r := range n.rules {
+		if r.matches(event) {
+			errs = append(errs, n.notifyRule(ctx, r, event)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchesThreshold keeps package_unused events for the threshold a target
+// subscribed with.
+func matchesThreshold(event Event, unusedDays int) bool {
+	return event.Type != EventPackageUnused || event.UnusedDays == unusedDays
+}
+
+// renderMessage renders event with tmpl, or with the event's default
+// template when tmpl is nil.
+func renderMessage(tmpl *template.Template, event Event) (string, error) {
+	if tmpl == nil {
+		tmpl = template.Must(template.New(event.Type).Parse(defaultTemplates[event.Type]))
+	}
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, event); err != nil {
+		return "", fmt.Errorf("failed to render message: %w", err)
+	}
+	return text.String(), nil
+}
+
+func (n *Notifier) post(ctx context.Context, c chat, event Event) error {
+	text, err := renderMessage(c.template, event)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(chatPayload(c.service, text))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// chatPayload builds the JSON body each service expects.
+func chatPayload(service, text string) map[string]string {
+	if service == ServiceDiscord {
+		if runes := []rune(text); len(runes) > discordContentLimit {
+			text = string(runes[:discordContentLimit-3]) + "..."
+		}
+		return map[string]string{"content": text}
+	}
+	return map[string]string{"text": text}
+}
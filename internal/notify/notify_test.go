@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+type recordedPost struct {
+	path string
+	body map[string]string
+}
+
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, func() []recordedPost) {
+	t.Helper()
+	var mu sync.Mutex
+	var posts []recordedPost
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid webhook body: %v", err)
+		}
+		mu.Lock()
+		posts = append(posts, recordedPost{path: r.URL.Path, body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []recordedPost {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedPost(nil), posts...)
+	}
+}
+
+func TestNotifyPostsToSubscribedChats(t *testing.T) {
+	server, posts := newWebhookServer(t, http.StatusOK)
+	notifier, err := New(core.NotificationsConfig{Chat: []core.ChatConfig{
+		{Service: "slack", URL: server.URL + "/slack", Events: []string{EventPackageInstalled}},
+		{Service: "discord", URL: server.URL + "/discord", Events: []string{EventPackageInstalled}, Template: "{{.Tool}}/{{.Package}} via `{{.Command}}`"},
+		{Service: "slack", URL: server.URL + "/summaries", Events: []string{EventDailySummary}},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if !notifier.Subscribed(EventPackageInstalled) || notifier.Subscribed(EventWeeklySummary) {
+		t.Fatal("Unexpected subscriptions")
+	}
+
+	err = notifier.Notify(context.Background(), Event{
+		Type:    EventPackageInstalled,
+		Tool:    "npm",
+		Package: "typescript",
+		Command: "npm install -g typescript",
+	})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	got := posts()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 posts, got %+v", got)
+	}
+	for _, post := range got {
+		switch post.path {
+		case "/slack":
+			if post.body["text"] != "New npm package installed: typescript" {
+				t.Errorf("Unexpected Slack message: %v", post.body)
+			}
+		case "/discord":
+			if post.body["content"] != "npm/typescript via `npm install -g typescript`" {
+				t.Errorf("Unexpected Discord message: %v", post.body)
+			}
+		default:
+			t.Errorf("Unexpected post to %s", post.path)
+		}
+	}
+}
+
+func TestNotifyReportsWebhookErrors(t *testing.T) {
+	server, _ := newWebhookServer(t, http.StatusForbidden)
+	notifier, err := New(core.NotificationsConfig{Chat: []core.ChatConfig{
+		{Service: "slack", URL: server.URL, Events: []string{EventDailySummary}},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	err = notifier.Notify(context.Background(), Event{Type: EventDailySummary, Summary: "report"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Expected 403 error, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidChat(t *testing.T) {
+	tests := []struct {
+		chat core.ChatConfig
+		want string
+	}{
+		{core.ChatConfig{Service: "teams", URL: "https://example.com"}, "unknown service"},
+		{core.ChatConfig{Service: "slack", URL: "example.com"}, "url"},
+		{core.ChatConfig{Service: "slack", URL: "https://example.com", Events: []string{"reboot"}}, "unknown event"},
+		{core.ChatConfig{Service: "slack", URL: "https://example.com", Template: "{{.Package"}, "invalid template"},
+	}
+	for _, tt := range tests {
+		_, err := New(core.NotificationsConfig{Chat: []core.ChatConfig{tt.chat}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New(%+v) error = %v, want %q", tt.chat, err, tt.want)
+		}
+	}
+}
+
+func TestChatPayloadTruncatesDiscord(t *testing.T) {
+	payload := chatPayload(ServiceDiscord, strings.Repeat("é", discordContentLimit+10))
+	if got := len([]rune(payload["content"])); got != discordContentLimit {
+		t.Fatalf("Expected %d runes, got %d", discordContentLimit, got)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var notifier *Notifier
+	if notifier.Subscribed(EventPackageInstalled) {
+		t.Fatal("Nil notifier should have no subscriptions")
+	}
+	if err := notifier.Notify(context.Background(), Event{Type: EventPackageInstalled}); err != nil {
+		t.Fatalf("Nil notifier Notify failed: %v", err)
+	}
+}
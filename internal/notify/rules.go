@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// desktopTitle is the title of desktop notifications.
+const desktopTitle = "diu"
+
+// rule is a notifications.rules entry: one event, narrowed to some tools,
+// delivered to the desktop and/or a webhook.
+type rule struct {
+	event      string
+	tools      map[string]bool
+	unusedDays int
+	globalOnly bool
+	desktop    bool
+	webhook    *webhook
+}
+
+func newRule(entry core.NotificationRuleConfig) (rule, error) {
+	if entry.Event != EventPackageUnused && entry.Event != EventPackageInstalled {
+		return rule{}, fmt.Errorf("unknown event %q (use %s or %s)", entry.Event, EventPackageUnused, EventPackageInstalled)
+	}
+	if entry.UnusedDays < 0 {
+		return rule{}, fmt.Errorf("unused_days must be non-negative")
+	}
+	if !entry.Desktop && entry.WebhookURL == "" {
+		return rule{}, fmt.Errorf("set desktop or webhook_url")
+	}
+
+	r := rule{
+		event:      entry.Event,
+		unusedDays: entry.UnusedDays,
+		globalOnly: entry.GlobalOnly,
+		desktop:    entry.Desktop,
+	}
+	if r.unusedDays == 0 {
+		r.unusedDays = core.DefaultWebhookUnusedDays
+	}
+	if len(entry.Tools) > 0 {
+		r.tools = make(map[string]bool, len(entry.Tools))
+		for _, tool := range entry.Tools {
+			r.tools[core.NormalizeToolName(tool)] = true
+		}
+	}
+	if entry.WebhookURL != "" {
+		w, err := newWebhook(core.WebhookConfig{URL: entry.WebhookURL, Events: []string{entry.Event}, UnusedDays: r.unusedDays})
+		if err != nil {
+			return rule{}, err
+		}
+		r.webhook = &w
+	}
+	return r, nil
+}
+
+// matches reports whether event is the rule's event, for one of its tools,
+// at its threshold, and installed globally when the rule asks for that.
+func (r rule) matches(event Event) bool {
+	if event.Type != r.event || !matchesThreshold(event, r.unusedDays) {
+		return false
+	}
+	if r.tools != nil && !r.tools[event.Tool] {
+		return false
+	}
+	return !r.globalOnly || !event.Local
+}
+
+// notifyRule delivers event to the targets of r.
+func (n *Notifier) notifyRule(ctx context.Context, r rule, event Event) []error {
+	var errs []error
+	if r.desktop {
+		if err := n.notifyDesktop(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("desktop: %w", err))
+		}
+	}
+	if r.webhook != nil {
+		if err := n.deliver(ctx, *r.webhook, event); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", r.webhook.url, err))
+		}
+	}
+	return errs
+}
+
+func (n *Notifier) notifyDesktop(ctx context.Context, event Event) error {
+	message, err := renderMessage(nil, event)
+	if err != nil {
+		return err
+	}
+	return n.desktop(ctx, desktopTitle, message)
+}
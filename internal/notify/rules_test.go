@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestNotifyRules(t *testing.T) {
+	var mu sync.Mutex
+	var hooked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		hooked = append(hooked, payload.Package)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	notifier, err := New(core.NotificationsConfig{Rules: []core.NotificationRuleConfig{
+		{Event: EventPackageUnused, Tools: []string{"brew"}, UnusedDays: 30, Desktop: true},
+		{Event: EventPackageUnused, Tools: []string{"npm"}, WebhookURL: server.URL},
+		{Event: EventPackageInstalled, GlobalOnly: true, Desktop: true},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	var shown []string
+	notifier.desktop = func(ctx context.Context, title, message string) error {
+		shown = append(shown, message)
+		return nil
+	}
+
+	if thresholds := notifier.UnusedThresholds(); !slices.Equal(thresholds, []int{30, core.DefaultWebhookUnusedDays}) {
+		t.Fatalf("Unexpected thresholds: %v", thresholds)
+	}
+	if !notifier.Subscribed(EventPackageInstalled) {
+		t.Fatal("Expected the installed rule to subscribe to package_installed")
+	}
+
+	lastUsed := time.Now().Add(-100 * 24 * time.Hour)
+	for _, event := range []Event{
+		{Type: EventPackageUnused, Tool: core.ToolHomebrew, Package: "jq", LastUsed: lastUsed, UnusedDays: 30},
+		{Type: EventPackageUnused, Tool: core.ToolHomebrew, Package: "jq", LastUsed: lastUsed, UnusedDays: core.DefaultWebhookUnusedDays},
+		{Type: EventPackageUnused, Tool: core.ToolNPM, Package: "yo", LastUsed: lastUsed, UnusedDays: 30},
+		{Type: EventPackageUnused, Tool: core.ToolNPM, Package: "yo", LastUsed: lastUsed, UnusedDays: core.DefaultWebhookUnusedDays},
+		{Type: EventPackageInstalled, Tool: core.ToolNPM, Package: "left-pad", Local: true},
+		{Type: EventPackageInstalled, Tool: core.ToolNPM, Package: "typescript"},
+	} {
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			t.Fatalf("Notify(%+v) failed: %v", event, err)
+		}
+	}
+
+	want := []string{"jq (homebrew) has not been used in 30 days", "New npm package installed: typescript"}
+	if !slices.Equal(shown, want) {
+		t.Errorf("Desktop notifications = %q, want %q", shown, want)
+	}
+	if !slices.Equal(hooked, []string{"yo"}) {
+		t.Errorf("Expected only yo at the default threshold posted, got %v", hooked)
+	}
+}
+
+func TestNewRejectsInvalidRule(t *testing.T) {
+	tests := []struct {
+		rule core.NotificationRuleConfig
+		want string
+	}{
+		{core.NotificationRuleConfig{Event: EventDailySummary, Desktop: true}, "unknown event"},
+		{core.NotificationRuleConfig{Event: EventPackageUnused}, "desktop or webhook_url"},
+		{core.NotificationRuleConfig{Event: EventPackageUnused, UnusedDays: -1, Desktop: true}, "non-negative"},
+		{core.NotificationRuleConfig{Event: EventPackageUnused, WebhookURL: "example.com"}, "url"},
+	}
+	for _, tt := range tests {
+		_, err := New(core.NotificationsConfig{Rules: []core.NotificationRuleConfig{tt.rule}})
+		if err == nil || !strings.Contains(err.Error(), "notifications.rules[0]") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New(%+v) error = %v, want %q", tt.rule, err, tt.want)
+		}
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	if got := appleScriptString(`say "hi" \o/`); got != `"say \"hi\" \\o/"` {
+		t.Fatalf("appleScriptString = %s", got)
+	}
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+type webhook struct {
+	url        string
+	events     map[string]bool
+	headers    map[string]string
+	unusedDays int
+	maxRetries int
+}
+
+// webhookPayload is the JSON body posted to webhooks.
+type webhookPayload struct {
+	Event      string                `json:"event"`
+	Time       time.Time             `json:"time"`
+	Tool       string                `json:"tool,omitempty"`
+	Package    string                `json:"package,omitempty"`
+	Command    string                `json:"command,omitempty"`
+	Execution  *core.ExecutionRecord `json:"execution,omitempty"`
+	LastUsed   *time.Time            `json:"last_used,omitempty"`
+	UnusedDays int                   `json:"unused_days,omitempty"`
+	Summary    string                `json:"summary,omitempty"`
+}
+
+func newWebhook(entry core.WebhookConfig) (webhook, error) {
+	if err := validateURL(entry.URL); err != nil {
+		return webhook{}, err
+	}
+	events, err := parseEvents(entry.Events)
+	if err != nil {
+		return webhook{}, err
+	}
+	if entry.UnusedDays < 0 || entry.MaxRetries < 0 {
+		return webhook{}, fmt.Errorf("unused_days and max_retries must be non-negative")
+	}
+
+	w := webhook{
+		url:        entry.URL,
+		events:     events,
+		headers:    entry.Headers,
+		unusedDays: entry.UnusedDays,
+		maxRetries: entry.MaxRetries,
+	}
+	if w.unusedDays == 0 {
+		w.unusedDays = core.DefaultWebhookUnusedDays
+	}
+	if w.maxRetries == 0 {
+		w.maxRetries = core.DefaultWebhookRetries
+	}
+	return w, nil
+}
+
+// deliver posts event to w, retrying network errors, 429s, and 5xx
+// responses with exponential backoff.
+func (n *Notifier) deliver(ctx context.Context, w webhook, event Event) error {
+	payload := webhookPayload{
+		Event:      event.Type,
+		Time:       event.Time,
+		Tool:       event.Tool,
+		Package:    event.Package,
+		Command:    event.Command,
+		Execution:  event.Execution,
+		UnusedDays: event.UnusedDays,
+		Summary:    event.Summary,
+	}
+	if !event.LastUsed.IsZero() {
+		payload.LastUsed = &event.LastUsed
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	delay := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.postWebhook(ctx, w, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.maxRetries {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// postWebhook makes one delivery attempt and reports whether a failure is
+// worth retrying.
+func (n *Notifier) postWebhook(ctx context.Context, w webhook, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "diu/"+core.Version)
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
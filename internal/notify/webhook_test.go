@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Expected configured header, got %q", r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	notifier, err := New(core.NotificationsConfig{Webhooks: []core.WebhookConfig{{
+		URL:     server.URL,
+		Events:  []string{EventExecutionIngested},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+
+	record := &core.ExecutionRecord{ID: "exec_1", Tool: "npm", Command: "npm install -g typescript"}
+	err = notifier.Notify(context.Background(), Event{
+		Type:      EventExecutionIngested,
+		Tool:      record.Tool,
+		Command:   record.Command,
+		Execution: record,
+	})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", attempts.Load())
+	}
+	if payload.Event != EventExecutionIngested || payload.Execution == nil || payload.Execution.ID != "exec_1" {
+		t.Fatalf("Unexpected payload: %+v", payload)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier, err := New(core.NotificationsConfig{Webhooks: []core.WebhookConfig{{
+		URL:        server.URL,
+		Events:     []string{EventPackageInstalled},
+		MaxRetries: 1,
+	}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+
+	err = notifier.Notify(context.Background(), Event{Type: EventPackageInstalled})
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("Expected 502 error, got %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("Expected initial attempt plus one retry, got %d", attempts.Load())
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier, err := New(core.NotificationsConfig{Webhooks: []core.WebhookConfig{{
+		URL:    server.URL,
+		Events: []string{EventPackageInstalled},
+	}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+
+	if err := notifier.Notify(context.Background(), Event{Type: EventPackageInstalled}); err == nil {
+		t.Fatal("Expected error for 400 response")
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("Expected a single attempt, got %d", attempts.Load())
+	}
+}
+
+func TestWebhookUnusedThresholds(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if payload.UnusedDays != 30 || payload.LastUsed == nil {
+			t.Errorf("Unexpected unused payload: %+v", payload)
+		}
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	notifier, err := New(core.NotificationsConfig{Webhooks: []core.WebhookConfig{
+		{URL: server.URL, Events: []string{EventPackageUnused}, UnusedDays: 30},
+		{URL: server.URL, Events: []string{EventPackageUnused}},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	thresholds := notifier.UnusedThresholds()
+	if len(thresholds) != 2 || thresholds[0] != 30 || thresholds[1] != core.DefaultWebhookUnusedDays {
+		t.Fatalf("Unexpected thresholds: %v", thresholds)
+	}
+
+	err = notifier.Notify(context.Background(), Event{
+		Type:       EventPackageUnused,
+		Package:    "jq",
+		LastUsed:   time.Now().Add(-45 * 24 * time.Hour),
+		UnusedDays: 30,
+	})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if received.Load() != 1 {
+		t.Fatalf("Expected only the 30 day webhook to receive the event, got %d", received.Load())
+	}
+}
+
+func TestNewRejectsInvalidWebhook(t *testing.T) {
+	_, err := New(core.NotificationsConfig{Webhooks: []core.WebhookConfig{{URL: "https://example.com", UnusedDays: -1}}})
+	if err == nil || !strings.Contains(err.Error(), "notifications.webhooks[0]") {
+		t.Fatalf("Expected webhook validation error, got %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+// Package osv looks up known vulnerabilities of installed packages in the
+// OSV.dev database. Packages are queried in batches and the answers cached
+// on disk, so repeated audits only ask about package versions that are new
+// or whose answer has gone stale.
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// DefaultURL is the public OSV.dev API
+const DefaultURL = "https://api.osv.dev"
+
+const (
+	// batchSize is the most queries OSV.dev answers in one request.
+	batchSize        = 1000
+	requestTimeout   = 30 * time.Second
+	maxResponseBytes = 32 << 20
+)
+
+// ecosystems maps tools to the OSV ecosystem of the registry they install
+// from
+var ecosystems = map[string]string{
+	core.ToolNPM:    "npm",
+	core.ToolPNPM:   "npm",
+	core.ToolBun:    "npm",
+	core.ToolYarn:   "npm",
+	core.ToolGo:     "Go",
+	core.ToolPip:    "PyPI",
+	core.ToolUV:     "PyPI",
+	core.ToolPoetry: "PyPI",
+	core.ToolCargo:  "crates.io",
+}
+
+// Package is a package version to look up
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+}
+
+func (p Package) key() string {
+	return p.Ecosystem + "/" + p.Name + "@" + p.Version
+}
+
+// PackageFor returns what to look up for a tracked package. It reports
+// false for tools OSV does not cover, packages without a version, and Go
+// binaries not known by their module path.
+func PackageFor(pkg *core.PackageInfo) (Package, bool) {
+	ecosystem, ok := ecosystems[pkg.Tool]
+	if !ok || pkg.Version == "" {
+		return Package{}, false
+	}
+	version := pkg.Version
+	if ecosystem == "Go" {
+		if !strings.Contains(pkg.Name, "/") {
+			return Package{}, false
+		}
+		version = strings.TrimPrefix(version, "v")
+	}
+	return Package{Ecosystem: ecosystem, Name: pkg.Name, Version: version}, true
+}
+
+// Vulnerability is a known vulnerability of one package version
+type Vulnerability struct {
+	ID       string   `json:"id"`
+	Summary  string   `json:"summary,omitempty"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+	// Fixed lists the versions of the package that fix the vulnerability.
+	Fixed []string `json:"fixed,omitempty"`
+}
+
+// Client queries an OSV API
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	// CachePath is the file answers are cached in, and TTL how long they
+	// are reused.
+	CachePath string
+	TTL       time.Duration
+}
+
+// NewClient returns a client for the OSV API and cache config names
+func NewClient(config *core.Config) *Client {
+	endpoint := config.Audit.OSVURL
+	if endpoint == "" {
+		endpoint = DefaultURL
+	}
+	return &Client{
+		URL:        strings.TrimRight(endpoint, "/"),
+		HTTPClient: &http.Client{Timeout: requestTimeout},
+		CachePath:  CachePath(config),
+		TTL:        config.Audit.CacheTTL,
+	}
+}
+
+// CachePath is where answers from OSV are cached
+func CachePath(config *core.Config) string {
+	return filepath.Join(config.Daemon.DataDir, core.OSVCacheFileName)
+}
+
+// cacheEntry is the answer for one package version
+type cacheEntry struct {
+	Checked         time.Time       `json:"checked"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// cache is the cache file, keyed by ecosystem, name, and version
+type cache struct {
+	Packages map[string]*cacheEntry `json:"packages"`
+}
+
+// Query returns the known vulnerabilities of each package that has any.
+// Cached answers younger than the TTL are reused unless refresh is set; the
+// rest are asked for in batches, and the cache is saved after each one.
+func (c *Client) Query(ctx context.Context, packages []Package, refresh bool) (map[Package][]Vulnerability, error) {
+	cached, err := loadCache(c.CachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := make(map[Package][]Vulnerability)
+	var stale []Package
+	seen := make(map[Package]bool, len(packages))
+	for _, pkg := range packages {
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		if entry, ok := cached.Packages[pkg.key()]; ok && !refresh && now.Sub(entry.Checked) < c.TTL {
+			if len(entry.Vulnerabilities) > 0 {
+				results[pkg] = entry.Vulnerabilities
+			}
+			continue
+		}
+		stale = append(stale, pkg)
+	}
+
+	records := make(map[string]*record)
+	for start := 0; start < len(stale); start += batchSize {
+		batch := stale[start:min(start+batchSize, len(stale))]
+		ids, err := c.queryBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, pkg := range batch {
+			entry := &cacheEntry{Checked: now}
+			for _, id := range ids[i] {
+				rec, ok := records[id]
+				if !ok {
+					if rec, err = c.vulnerability(ctx, id); err != nil {
+						return nil, err
+					}
+					records[id] = rec
+				}
+				entry.Vulnerabilities = append(entry.Vulnerabilities, rec.vulnerabilityOf(pkg))
+			}
+			cached.Packages[pkg.key()] = entry
+			if len(entry.Vulnerabilities) > 0 {
+				results[pkg] = entry.Vulnerabilities
+			}
+		}
+		if err := saveCache(c.CachePath, cached); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// batchQuery is the body of a querybatch request
+type batchQuery struct {
+	Queries []query `json:"queries"`
+}
+
+type query struct {
+	Package queryPackage `json:"package"`
+	Version string       `json:"version"`
+}
+
+type queryPackage struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// batchResponse lists the IDs of the vulnerabilities found for each query,
+// in order
+type batchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	} `json:"results"`
+}
+
+// queryBatch returns the vulnerability IDs affecting each package
+func (c *Client) queryBatch(ctx context.Context, packages []Package) ([][]string, error) {
+	request := batchQuery{Queries: make([]query, 0, len(packages))}
+	for _, pkg := range packages {
+		request.Queries = append(request.Queries, query{
+			Package: queryPackage{Name: pkg.Name, Ecosystem: pkg.Ecosystem},
+			Version: pkg.Version,
+		})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var response batchResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/querybatch", body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Results) != len(packages) {
+		return nil, fmt.Errorf("OSV answered %d of %d queries", len(response.Results), len(packages))
+	}
+	ids := make([][]string, len(packages))
+	for i, result := range response.Results {
+		for _, vuln := range result.Vulns {
+			ids[i] = append(ids[i], vuln.ID)
+		}
+	}
+	return ids, nil
+}
+
+// record is the part of an OSV vulnerability record diu reports
+type record struct {
+	ID               string   `json:"id"`
+	Summary          string   `json:"summary"`
+	Details          string   `json:"details"`
+	Aliases          []string `json:"aliases"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	Affected []struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Ranges []struct {
+			Events []struct {
+				Fixed string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+}
+
+// vulnerability fetches the record of the vulnerability with id
+func (c *Client) vulnerability(ctx context.Context, id string) (*record, error) {
+	var rec record
+	if err := c.do(ctx, http.MethodGet, "/v1/vulns/"+url.PathEscape(id), nil, &rec); err != nil {
+		return nil, err
+	}
+	if rec.ID == "" {
+		rec.ID = id
+	}
+	return &rec, nil
+}
+
+// vulnerabilityOf summarizes the record for one affected package
+func (r *record) vulnerabilityOf(pkg Package) Vulnerability {
+	vuln := Vulnerability{
+		ID:       r.ID,
+		Summary:  r.Summary,
+		Aliases:  r.Aliases,
+		Severity: strings.ToUpper(r.DatabaseSpecific.Severity),
+	}
+	if vuln.Summary == "" {
+		vuln.Summary, _, _ = strings.Cut(strings.TrimSpace(r.Details), "\n")
+	}
+	for _, affected := range r.Affected {
+		if affected.Package.Ecosystem != pkg.Ecosystem || !strings.EqualFold(affected.Package.Name, pkg.Name) {
+			continue
+		}
+		for _, versionRange := range affected.Ranges {
+			for _, event := range versionRange.Events {
+				if event.Fixed != "" {
+					vuln.Fixed = append(vuln.Fixed, event.Fixed)
+				}
+			}
+		}
+	}
+	return vuln
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OSV request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read OSV response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OSV request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid OSV response: %w", err)
+	}
+	return nil
+}
+
+func loadCache(path string) (*cache, error) {
+	cached := &cache{Packages: make(map[string]*cacheEntry)}
+	data, err := safefs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cached, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cached); err != nil {
+		// A cache that cannot be read is asked for again.
+		return &cache{Packages: make(map[string]*cacheEntry)}, nil
+	}
+	if cached.Packages == nil {
+		cached.Packages = make(map[string]*cacheEntry)
+	}
+	return cached, nil
+}
+
+func saveCache(path string, cached *cache) error {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), core.OwnerDirectoryMode); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, core.PrivateFileMode)
+}
@@ -0,0 +1,101 @@
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestPackageFor(t *testing.T) {
+	tests := []struct {
+		pkg    core.PackageInfo
+		want   Package
+		wantOK bool
+	}{
+		{pkg: core.PackageInfo{Tool: core.ToolPNPM, Name: "lodash", Version: "4.17.20"}, want: Package{"npm", "lodash", "4.17.20"}, wantOK: true},
+		{pkg: core.PackageInfo{Tool: core.ToolGo, Name: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0"}, want: Package{"Go", "golang.org/x/tools/cmd/stringer", "0.1.0"}, wantOK: true},
+		{pkg: core.PackageInfo{Tool: core.ToolGo, Name: "stringer", Version: "v0.1.0"}},
+		{pkg: core.PackageInfo{Tool: core.ToolUV, Name: "ruff"}},
+		{pkg: core.PackageInfo{Tool: core.ToolHomebrew, Name: "jq", Version: "1.7"}},
+	}
+	for _, tt := range tests {
+		got, ok := PackageFor(&tt.pkg)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("PackageFor(%s/%s) = %+v, %v; want %+v, %v", tt.pkg.Tool, tt.pkg.Name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestQueryBatchesAndCaches(t *testing.T) {
+	var batches, lookups int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/querybatch":
+			batches++
+			var request batchQuery
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("invalid request: %v", err)
+			}
+			results := make([]map[string]interface{}, len(request.Queries))
+			for i, q := range request.Queries {
+				results[i] = map[string]interface{}{}
+				if q.Package.Name == "lodash" {
+					results[i]["vulns"] = []map[string]string{{"id": "GHSA-35jh-r3h4-6jhm"}}
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+		case "/v1/vulns/GHSA-35jh-r3h4-6jhm":
+			lookups++
+			_, _ = w.Write([]byte(`{
+  "id": "GHSA-35jh-r3h4-6jhm",
+  "summary": "Command Injection in lodash",
+  "aliases": ["CVE-2021-23337"],
+  "database_specific": {"severity": "HIGH"},
+  "affected": [{"package": {"name": "lodash", "ecosystem": "npm"}, "ranges": [{"events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]}]
+}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{URL: server.URL, HTTPClient: server.Client(), CachePath: filepath.Join(t.TempDir(), "osv-cache.json"), TTL: time.Hour}
+	lodash := Package{"npm", "lodash", "4.17.20"}
+	packages := []Package{lodash, {"PyPI", "requests", "2.32.3"}, lodash}
+
+	want := map[Package][]Vulnerability{lodash: {{
+		ID: "GHSA-35jh-r3h4-6jhm", Summary: "Command Injection in lodash", Aliases: []string{"CVE-2021-23337"},
+		Severity: "HIGH", Fixed: []string{"4.17.21"},
+	}}}
+	for _, refresh := range []bool{false, false, true} {
+		got, err := client.Query(context.Background(), packages, refresh)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Query = %+v, want %+v", got, want)
+		}
+	}
+	if batches != 2 || lookups != 2 {
+		t.Errorf("Expected the cached answers to be reused until refresh, got %d batches and %d lookups", batches, lookups)
+	}
+}
+
+func TestQueryReportsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &Client{URL: server.URL, HTTPClient: server.Client(), CachePath: filepath.Join(t.TempDir(), "osv-cache.json"), TTL: time.Hour}
+	if _, err := client.Query(context.Background(), []Package{{"npm", "lodash", "4.17.20"}}, false); err == nil {
+		t.Fatal("Expected a failed OSV request to be reported")
+	}
+}
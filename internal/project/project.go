@@ -0,0 +1,179 @@
+// Package project finds the project an execution ran in from the nearest
+// package manifest above its working directory.
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// Manifest file names, in the order they are looked for in each directory
+const (
+	ManifestPackageJSON = "package.json"
+	ManifestGoMod       = "go.mod"
+	ManifestPyProject   = "pyproject.toml"
+	ManifestCargo       = "Cargo.toml"
+)
+
+var manifests = []struct {
+	file string
+	name func(data []byte) string
+}{
+	{ManifestPackageJSON, packageJSONName},
+	{ManifestGoMod, goModName},
+	{ManifestPyProject, func(data []byte) string { return tomlName(data, "project", "tool.poetry") }},
+	{ManifestCargo, func(data []byte) string { return tomlName(data, "package") }},
+}
+
+// MetadataScriptRoot is the metadata key of the directory holding the
+// package.json whose script an execution ran
+const MetadataScriptRoot = "script_root"
+
+// Info describes the project enclosing a directory
+type Info struct {
+	Name     string `json:"name"`
+	Root     string `json:"root"`
+	Manifest string `json:"manifest"`
+}
+
+// Detect walks up from dir to the nearest directory holding a package.json,
+// go.mod, pyproject.toml, or Cargo.toml. The project is named by the
+// manifest (the package name, or the module path for go.mod), or by its
+// directory when the manifest declares no name. It reports false when no
+// manifest is found.
+func Detect(dir string) (Info, bool) {
+	if dir == "" {
+		return Info{}, false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Info{}, false
+	}
+
+	for {
+		for _, manifest := range manifests {
+			data, err := safefs.ReadFile(filepath.Join(dir, manifest.file))
+			if err != nil {
+				continue
+			}
+			name := manifest.name(data)
+			if name == "" {
+				name = filepath.Base(dir)
+			}
+			return Info{Name: name, Root: dir, Manifest: manifest.file}, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Info{}, false
+		}
+		dir = parent
+	}
+}
+
+// Annotate sets record.ProjectName from the project enclosing its working
+// directory, unless it is already set.
+func Annotate(record *core.ExecutionRecord) {
+	if record.ProjectName != "" {
+		return
+	}
+	if info, ok := Detect(record.WorkingDir); ok {
+		record.ProjectName = info.Name
+	}
+}
+
+// PackageScripts walks up from dir to the nearest package.json and returns
+// its directory and the names of its scripts, sorted. It reports false when
+// no package.json is found.
+func PackageScripts(dir string) (string, []string, bool) {
+	if dir == "" {
+		return "", nil, false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, false
+	}
+
+	for {
+		if data, err := safefs.ReadFile(filepath.Join(dir, ManifestPackageJSON)); err == nil {
+			var manifest struct {
+				Scripts map[string]string `json:"scripts"`
+			}
+			_ = json.Unmarshal(data, &manifest)
+			scripts := make([]string, 0, len(manifest.Scripts))
+			for name := range manifest.Scripts {
+				scripts = append(scripts, name)
+			}
+			sort.Strings(scripts)
+			return dir, scripts, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil, false
+		}
+		dir = parent
+	}
+}
+
+func packageJSONName(data []byte) string {
+	var manifest struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(manifest.Name)
+}
+
+func goModName(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if module, ok := strings.CutPrefix(line, "module"); ok && (module == "" || module[0] == ' ' || module[0] == '\t') {
+			module, _, _ = strings.Cut(module, "//")
+			return strings.Trim(strings.TrimSpace(module), `"`+"`")
+		}
+	}
+	return ""
+}
+
+// tomlName returns the name key of the first listed table that sets one.
+// Only the line-level syntax manifests use is read: [table] headers and
+// name = "value" pairs.
+func tomlName(data []byte, tables ...string) string {
+	names := make(map[string]string)
+	var table string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			table = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "name" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || (value[0] != '"' && value[0] != '\'') {
+			continue
+		}
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			if _, exists := names[table]; !exists {
+				names[table] = value[1 : end+1]
+			}
+		}
+	}
+	for _, table := range tables {
+		if name := names[table]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		content  string
+		want     string
+	}{
+		{"package.json", ManifestPackageJSON, `{"name": "@acme/web", "version": "1.0.0"}`, "@acme/web"},
+		{"go.mod", ManifestGoMod, "// comment\nmodule github.com/yowainwright/diu // main module\n\ngo 1.25\n", "github.com/yowainwright/diu"},
+		{"pyproject", ManifestPyProject, "[build-system]\nrequires = [\"hatchling\"]\n\n[project]\nname = \"analytics\"\n", "analytics"},
+		{"poetry", ManifestPyProject, "[tool.poetry]\nname = 'scraper'\nversion = \"0.1.0\"\n", "scraper"},
+		{"cargo", ManifestCargo, "[package]\nname = \"cli\" # the binary\n\n[dependencies]\nserde = \"1\"\n", "cli"},
+		{"unnamed", ManifestPackageJSON, `{"private": true}`, "unnamed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "unnamed")
+			writeFile(t, filepath.Join(root, tt.manifest), tt.content)
+			subdir := filepath.Join(root, "src", "lib")
+			if err := os.MkdirAll(subdir, 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			info, ok := Detect(subdir)
+			if !ok {
+				t.Fatal("Expected a project")
+			}
+			want := Info{Name: tt.want, Root: root, Manifest: tt.manifest}
+			if info != want {
+				t.Errorf("Detect = %+v, want %+v", info, want)
+			}
+		})
+	}
+}
+
+func TestDetectNearestManifest(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ManifestGoMod), "module example.com/monorepo\n")
+	writeFile(t, filepath.Join(root, "web", ManifestPackageJSON), `{"name": "web"}`)
+
+	if info, ok := Detect(filepath.Join(root, "web")); !ok || info.Name != "web" {
+		t.Errorf("Expected the nested package, got %+v", info)
+	}
+	if info, ok := Detect(root); !ok || info.Name != "example.com/monorepo" {
+		t.Errorf("Expected the root module, got %+v", info)
+	}
+}
+
+func TestPackageScripts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ManifestPackageJSON), `{"name": "web", "scripts": {"lint": "eslint .", "build": "vite build"}}`)
+	subdir := filepath.Join(root, "src")
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	dir, scripts, ok := PackageScripts(subdir)
+	if !ok || dir != root || strings.Join(scripts, ",") != "build,lint" {
+		t.Errorf("PackageScripts = %q, %v, %v; want %q, [build lint], true", dir, scripts, ok, root)
+	}
+	if _, _, ok := PackageScripts(""); ok {
+		t.Error("Expected no package.json for an empty directory")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ManifestCargo), "[package]\nname = \"engine\"\n")
+
+	record := &core.ExecutionRecord{WorkingDir: root}
+	Annotate(record)
+	if record.ProjectName != "engine" {
+		t.Errorf("ProjectName = %q, want engine", record.ProjectName)
+	}
+
+	preset := &core.ExecutionRecord{WorkingDir: root, ProjectName: "kept"}
+	Annotate(preset)
+	if preset.ProjectName != "kept" {
+		t.Errorf("Expected an existing project to be kept, got %q", preset.ProjectName)
+	}
+}
@@ -0,0 +1,130 @@
+// Package redact scrubs tokens, passwords, and credential-bearing URLs from
+// execution records before they are stored.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// Placeholder replaces every redacted value.
+const Placeholder = "[REDACTED]"
+
+// secretName matches flag and variable names that carry secrets, such as
+// --password, --auth-token, NPM_TOKEN, or AWS_SECRET_ACCESS_KEY.
+const secretName = `[A-Za-z0-9_-]*(?i:password|passwd|token|secret|api[_-]?key|access[_-]?key|credentials?)[A-Za-z0-9_-]*`
+
+var (
+	secretNamePattern = regexp.MustCompile(`^` + secretName + `$`)
+	secretFlagPattern = regexp.MustCompile(`^--?` + secretName + `$`)
+
+	// defaultRules apply to the command line, each argument, and
+	// environment values. Only the first capture group of a match is
+	// replaced, so the surrounding syntax stays readable.
+	defaultRules = []*regexp.Regexp{
+		// user:token@ in URLs, including git+https:// package specs
+		regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://([^/\s@]+)@`),
+		// --password=value and --token value
+		regexp.MustCompile(`(?:^|\s)--?` + secretName + `(?:=|\s+)([^\s-]\S*)`),
+		// NPM_TOKEN=value
+		regexp.MustCompile(`(?:^|\s)` + secretName + `=(\S+)`),
+		// Well-known token formats
+		regexp.MustCompile(`\b((?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|npm_[A-Za-z0-9]{36}|AKIA[0-9A-Z]{16}|xox[abprs]-[A-Za-z0-9-]{10,}))\b`),
+	}
+)
+
+// Redactor scrubs secrets from execution records.
+type Redactor struct {
+	rules []*regexp.Regexp
+}
+
+// New builds a Redactor with the default rules plus the configured
+// patterns. It returns nil when redaction is disabled; a nil Redactor
+// leaves records unchanged.
+func New(config core.RedactionConfig) (*Redactor, error) {
+	if config.Disabled {
+		return nil, nil
+	}
+	rules := append([]*regexp.Regexp(nil), defaultRules...)
+	for i, pattern := range config.Patterns {
+		rule, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction.patterns[%d]: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return &Redactor{rules: rules}, nil
+}
+
+// String replaces the secrets found in s. A rule with capture groups has
+// its first group replaced; a rule without has its whole match replaced.
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, rule := range r.rules {
+		s = replaceMatches(rule, s)
+	}
+	return s
+}
+
+// Record scrubs the command line, arguments, affected packages,
+// environment, and captured output of record in place. The value following
+// a secret flag such as --password is redacted even when it is a separate
+// argument, and environment variables with secret names are redacted
+// entirely.
+func (r *Redactor) Record(record *core.ExecutionRecord) {
+	if r == nil || record == nil {
+		return
+	}
+	record.Command = r.String(record.Command)
+
+	for i := 0; i < len(record.Args); i++ {
+		arg := record.Args[i]
+		if secretFlagPattern.MatchString(arg) && i+1 < len(record.Args) && !strings.HasPrefix(record.Args[i+1], "-") {
+			record.Args[i+1] = Placeholder
+			i++
+			continue
+		}
+		record.Args[i] = r.String(arg)
+	}
+	for i, pkg := range record.PackagesAffected {
+		record.PackagesAffected[i] = r.String(pkg)
+	}
+
+	for name, value := range record.Environment {
+		if secretNamePattern.MatchString(name) {
+			record.Environment[name] = Placeholder
+			continue
+		}
+		record.Environment[name] = r.String(value)
+	}
+	if record.Output != nil {
+		record.Output.Stdout = r.String(record.Output.Stdout)
+		record.Output.Stderr = r.String(record.Output.Stderr)
+	}
+}
+
+func replaceMatches(rule *regexp.Regexp, s string) string {
+	matches := rule.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) >= 4 && match[2] >= 0 {
+			start, end = match[2], match[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Placeholder)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
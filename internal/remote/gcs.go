@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const gcsDefaultBaseURL = "https://storage.googleapis.com"
+
+// gcsTarget stores objects in a Google Cloud Storage bucket through the JSON
+// API, authenticating with an OAuth access token such as the output of
+// `gcloud auth print-access-token`.
+type gcsTarget struct {
+	bucket  string
+	prefix  string
+	baseURL string
+	client  *http.Client
+	getenv  func(string) string
+}
+
+func newGCSTarget(bucket, prefix string, opts Options) *gcsTarget {
+	return &gcsTarget{
+		bucket:  bucket,
+		prefix:  prefix,
+		baseURL: gcsDefaultBaseURL,
+		client:  opts.HTTPClient,
+		getenv:  opts.Getenv,
+	}
+}
+
+func (t *gcsTarget) String() string {
+	return "gs://" + t.bucket + "/" + t.prefix
+}
+
+func (t *gcsTarget) Put(ctx context.Context, name string, data []byte) error {
+	query := url.Values{"uploadType": {"media"}, "name": {objectKey(t.prefix, name)}}
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", t.baseURL, url.PathEscape(t.bucket), query.Encode())
+	req, err := t.newRequest(ctx, http.MethodPost, endpoint, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = do(t.client, req)
+	return err
+}
+
+func (t *gcsTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", t.baseURL, url.PathEscape(t.bucket), url.PathEscape(objectKey(t.prefix, name)))
+	req, err := t.newRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(t.client, req)
+}
+
+type gcsListResult struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (t *gcsTarget) List(ctx context.Context) ([]string, error) {
+	prefix := t.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var keys []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", t.baseURL, url.PathEscape(t.bucket), query.Encode())
+		req, err := t.newRequest(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := do(t.client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result gcsListResult
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse GCS listing: %w", err)
+		}
+		for _, item := range result.Items {
+			keys = append(keys, item.Name)
+		}
+		if result.NextPageToken == "" {
+			break
+		}
+		pageToken = result.NextPageToken
+	}
+	return relativeNames(t.prefix, keys), nil
+}
+
+func (t *gcsTarget) newRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	token := t.getenv(envGCSAccessToken)
+	if token == "" {
+		return nil, fmt.Errorf("GCS credentials not set: export %s (for example from `gcloud auth print-access-token`)", envGCSAccessToken)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
@@ -0,0 +1,192 @@
+// Package remote copies storage backups to and from remote object stores.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SchemeS3  = "s3"
+	SchemeGCS = "gs"
+
+	// maxObjectBytes bounds downloads so a misconfigured target cannot
+	// exhaust memory.
+	maxObjectBytes = 512 << 20
+
+	defaultHTTPTimeout = 5 * time.Minute
+
+	envAWSAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envAWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envAWSSessionToken    = "AWS_SESSION_TOKEN"
+	envAWSRegion          = "AWS_REGION"
+	envAWSDefaultRegion   = "AWS_DEFAULT_REGION"
+	envGCSAccessToken     = "GOOGLE_OAUTH_ACCESS_TOKEN"
+	envWebDAVUsername     = "DIU_WEBDAV_USERNAME"
+	envWebDAVPassword     = "DIU_WEBDAV_PASSWORD"
+
+	defaultAWSRegion = "us-east-1"
+)
+
+// Target stores objects under a prefix at a remote location. Names passed
+// to a Target are relative to that prefix.
+type Target interface {
+	// Put uploads data as name, replacing any existing object.
+	Put(ctx context.Context, name string, data []byte) error
+	// Get downloads name.
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the names of the objects under the prefix.
+	List(ctx context.Context) ([]string, error)
+	// String returns the target URL.
+	String() string
+}
+
+// Options configures how targets reach their services.
+type Options struct {
+	// S3Endpoint overrides the AWS endpoint for S3-compatible services and
+	// switches to path-style addressing.
+	S3Endpoint string
+	// HTTPClient is used for all requests; a client with a generous timeout
+	// is used when nil.
+	HTTPClient *http.Client
+	// Getenv looks up credentials; os.Getenv is used when nil.
+	Getenv func(string) string
+}
+
+// Open returns the target for rawURL. Supported forms are
+// s3://bucket/prefix, gs://bucket/prefix, and http(s):// WebDAV collections.
+// When the URL names a single object (its last segment contains ".backup."),
+// that segment is returned as object and the target is its parent.
+func Open(rawURL string, opts Options) (Target, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid remote URL %q: %w", rawURL, err)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+	object := ""
+	if base := path.Base(prefix); strings.Contains(base, ".backup.") {
+		object = base
+		prefix = path.Dir(prefix)
+		if prefix == "." {
+			prefix = ""
+		}
+	}
+
+	switch u.Scheme {
+	case SchemeS3:
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("s3 URL requires a bucket: %s", rawURL)
+		}
+		return newS3Target(u.Host, prefix, opts), object, nil
+	case SchemeGCS:
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("gs URL requires a bucket: %s", rawURL)
+		}
+		return newGCSTarget(u.Host, prefix, opts), object, nil
+	case "http", "https":
+		return newWebDAVTarget(u, prefix, opts), object, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported remote scheme %q (want s3, gs, http, or https)", u.Scheme)
+	}
+}
+
+// LatestBackup returns the newest backup object on target. Backup names end
+// in a sortable timestamp, so the lexically greatest name is the newest.
+func LatestBackup(ctx context.Context, target Target) (string, error) {
+	names, err := target.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	backups := names[:0]
+	for _, name := range names {
+		if strings.Contains(name, ".backup.") {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups found at %s", target)
+	}
+	sort.Strings(backups)
+	return backups[len(backups)-1], nil
+}
+
+func objectKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// relativeNames strips prefix from keys and drops anything outside it.
+func relativeNames(prefix string, keys []string) []string {
+	if prefix != "" {
+		prefix += "/"
+	}
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// do sends req and returns the response body, failing on non-2xx statuses.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > maxObjectBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxObjectBytes)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{Method: req.Method, URL: req.URL.Redacted(), StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// StatusError reports an unexpected HTTP status from a remote service.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %s", e.Method, e.URL, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		const maxBody = 200
+		body := e.Body
+		if len(body) > maxBody {
+			body = body[:maxBody] + "..."
+		}
+		msg += ": " + body
+	}
+	return msg
+}
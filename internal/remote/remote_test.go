@@ -0,0 +1,312 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testEnv(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+// objectStore is an in-memory bucket shared by the fake services.
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newObjectStore() *objectStore {
+	return &objectStore{objects: make(map[string][]byte)}
+}
+
+func (s *objectStore) put(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+}
+
+func (s *objectStore) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+func (s *objectStore) keys(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantTarget string
+		wantObject string
+		wantErr    bool
+	}{
+		{raw: "s3://bucket/diu/laptop", wantTarget: "s3://bucket/diu/laptop"},
+		{raw: "s3://bucket/diu/executions.json.backup.20260101_000000_000000000", wantTarget: "s3://bucket/diu", wantObject: "executions.json.backup.20260101_000000_000000000"},
+		{raw: "gs://bucket", wantTarget: "gs://bucket/"},
+		{raw: "https://dav.example.com/remote.php/diu/", wantTarget: "https://dav.example.com/remote.php/diu"},
+		{raw: "s3:///missing-bucket", wantErr: true},
+		{raw: "ftp://example.com/diu", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			target, object, err := Open(tt.raw, Options{Getenv: testEnv(nil)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if target.String() != tt.wantTarget {
+				t.Errorf("target = %s, want %s", target, tt.wantTarget)
+			}
+			if object != tt.wantObject {
+				t.Errorf("object = %q, want %q", object, tt.wantObject)
+			}
+		})
+	}
+}
+
+func TestS3TargetRoundTrip(t *testing.T) {
+	store := newObjectStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+			http.Error(w, "bad authorization: "+auth, http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "session" {
+			http.Error(w, "missing session token", http.StatusForbidden)
+			return
+		}
+
+		key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+		switch {
+		case r.Method == http.MethodPut && ok:
+			body, _ := io.ReadAll(r.Body)
+			if sha256Hex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+				http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+				return
+			}
+			store.put(key, body)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			fmt.Fprint(w, `<ListBucketResult>`)
+			for _, key := range store.keys(r.URL.Query().Get("prefix")) {
+				fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, key)
+			}
+			fmt.Fprint(w, `<IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case r.Method == http.MethodGet && ok:
+			data, found := store.get(key)
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	env := testEnv(map[string]string{
+		envAWSAccessKeyID:     "AKID",
+		envAWSSecretAccessKey: "secret",
+		envAWSSessionToken:    "session",
+		envAWSRegion:          "eu-west-1",
+	})
+	target, _, err := Open("s3://bucket/diu", Options{S3Endpoint: server.URL, HTTPClient: server.Client(), Getenv: env})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	roundTrip(t, target)
+	if _, ok := store.get("diu/executions.json.backup.20260102_000000_000000000"); !ok {
+		t.Errorf("Expected object under the diu/ prefix, got %v", store.keys(""))
+	}
+}
+
+func TestS3TargetRequiresCredentials(t *testing.T) {
+	target, _, err := Open("s3://bucket/diu", Options{Getenv: testEnv(nil)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	err = target.Put(context.Background(), "x.backup.1", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), envAWSAccessKeyID) {
+		t.Fatalf("Expected missing credentials error, got %v", err)
+	}
+}
+
+func TestS3URIEncode(t *testing.T) {
+	if got := s3URIEncode("/diu/a b+c~d", false); got != "/diu/a%20b%2Bc~d" {
+		t.Errorf("s3URIEncode path = %q", got)
+	}
+	query := url.Values{"prefix": {"diu/"}, "list-type": {"2"}}
+	if got := s3CanonicalQuery(query); got != "list-type=2&prefix=diu%2F" {
+		t.Errorf("s3CanonicalQuery = %q", got)
+	}
+}
+
+func TestGCSTargetRoundTrip(t *testing.T) {
+	store := newObjectStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			body, _ := io.ReadAll(r.Body)
+			store.put(r.URL.Query().Get("name"), body)
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+			var result gcsListResult
+			for _, key := range store.keys(r.URL.Query().Get("prefix")) {
+				result.Items = append(result.Items, struct {
+					Name string `json:"name"`
+				}{Name: key})
+			}
+			_ = json.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodGet && r.URL.Query().Get("alt") == "media":
+			key := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+			data, found := store.get(key)
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	target, _, err := Open("gs://bucket/diu", Options{HTTPClient: server.Client(), Getenv: testEnv(map[string]string{envGCSAccessToken: "token"})})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	target.(*gcsTarget).baseURL = server.URL
+	roundTrip(t, target)
+}
+
+func TestWebDAVTargetRoundTrip(t *testing.T) {
+	store := newObjectStore()
+	collections := map[string]bool{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "pw" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "MKCOL":
+			collections[strings.TrimSuffix(r.URL.Path, "/")] = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			dir := r.URL.Path[:strings.LastIndex(r.URL.Path, "/")]
+			if !collections[dir] {
+				http.Error(w, "parent missing", http.StatusConflict)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			store.put(r.URL.Path, body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, found := store.get(r.URL.Path)
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		case "PROPFIND":
+			if r.Header.Get("Depth") != "1" {
+				http.Error(w, "depth required", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href></d:response>`, r.URL.Path)
+			for _, key := range store.keys(strings.TrimSuffix(r.URL.Path, "/") + "/") {
+				fmt.Fprintf(w, `<d:response><d:href>%s</d:href></d:response>`, key)
+			}
+			fmt.Fprint(w, `</d:multistatus>`)
+		default:
+			http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	u.User = url.UserPassword("me", "pw")
+	u.Path = "/dav/diu"
+	target, _, err := Open(u.String(), Options{HTTPClient: server.Client(), Getenv: testEnv(nil)})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if strings.Contains(target.String(), "pw") {
+		t.Errorf("Expected target string to omit credentials, got %s", target)
+	}
+	roundTrip(t, target)
+}
+
+// roundTrip uploads two backups and checks they list, download, and that
+// the newer one is picked as latest.
+func roundTrip(t *testing.T, target Target) {
+	t.Helper()
+	ctx := context.Background()
+
+	older := "executions.json.backup.20260101_000000_000000000"
+	newer := "executions.json.backup.20260102_000000_000000000"
+	for _, name := range []string{older, newer} {
+		if err := target.Put(ctx, name, []byte(`{"name":"`+name+`"}`)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", name, err)
+		}
+	}
+
+	names, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != older+","+newer {
+		t.Fatalf("List = %v, want both backups", names)
+	}
+
+	latest, err := LatestBackup(ctx, target)
+	if err != nil {
+		t.Fatalf("LatestBackup failed: %v", err)
+	}
+	if latest != newer {
+		t.Errorf("LatestBackup = %s, want %s", latest, newer)
+	}
+
+	data, err := target.Get(ctx, latest)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != `{"name":"`+newer+`"}` {
+		t.Errorf("Get = %s", data)
+	}
+}
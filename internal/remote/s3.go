@@ -0,0 +1,256 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service       = "s3"
+	s3SigningScheme = "AWS4-HMAC-SHA256"
+	s3TimeFormat    = "20060102T150405Z"
+)
+
+// s3Target stores objects in an S3 bucket, signing requests with AWS
+// Signature Version 4 using credentials from the standard AWS environment
+// variables.
+type s3Target struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint *url.URL // nil for AWS virtual-hosted addressing
+	client   *http.Client
+	getenv   func(string) string
+	now      func() time.Time
+}
+
+func newS3Target(bucket, prefix string, opts Options) *s3Target {
+	region := opts.Getenv(envAWSRegion)
+	if region == "" {
+		region = opts.Getenv(envAWSDefaultRegion)
+	}
+	if region == "" {
+		region = defaultAWSRegion
+	}
+
+	target := &s3Target{
+		bucket: bucket,
+		prefix: prefix,
+		region: region,
+		client: opts.HTTPClient,
+		getenv: opts.Getenv,
+		now:    time.Now,
+	}
+	if opts.S3Endpoint != "" {
+		if endpoint, err := url.Parse(opts.S3Endpoint); err == nil {
+			target.endpoint = endpoint
+		}
+	}
+	return target
+}
+
+func (t *s3Target) String() string {
+	return "s3://" + t.bucket + "/" + t.prefix
+}
+
+func (t *s3Target) Put(ctx context.Context, name string, data []byte) error {
+	req, err := t.newRequest(ctx, http.MethodPut, objectKey(t.prefix, name), nil, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = do(t.client, req)
+	return err
+}
+
+func (t *s3Target) Get(ctx context.Context, name string) ([]byte, error) {
+	req, err := t.newRequest(ctx, http.MethodGet, objectKey(t.prefix, name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(t.client, req)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (t *s3Target) List(ctx context.Context) ([]string, error) {
+	prefix := t.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := t.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := do(t.client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return relativeNames(t.prefix, keys), nil
+}
+
+// newRequest builds a signed request for key (empty for the bucket itself).
+func (t *s3Target) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	accessKey := t.getenv(envAWSAccessKeyID)
+	secretKey := t.getenv(envAWSSecretAccessKey)
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 credentials not set: export %s and %s", envAWSAccessKeyID, envAWSSecretAccessKey)
+	}
+
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", t.bucket, t.region)}
+	objectPath := "/" + key
+	if t.endpoint != nil {
+		u.Scheme = t.endpoint.Scheme
+		u.Host = t.endpoint.Host
+		objectPath = strings.TrimRight(t.endpoint.Path, "/") + "/" + t.bucket + "/" + key
+	}
+	u.Path = objectPath
+	u.RawPath = s3URIEncode(objectPath, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	t.sign(req, u.RawPath, body, accessKey, secretKey, t.getenv(envAWSSessionToken))
+	return req, nil
+}
+
+// sign adds Signature Version 4 headers to req.
+func (t *s3Target) sign(req *http.Request, escapedPath string, body []byte, accessKey, secretKey, sessionToken string) {
+	now := t.now().UTC()
+	amzDate := now.Format(s3TimeFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, t.region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{s3SigningScheme, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningScheme, accessKey, scope, signedHeaders, signature))
+}
+
+// s3CanonicalQuery encodes query with sorted keys as SigV4 requires.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3URIEncode percent-encodes everything except RFC 3986 unreserved
+// characters, and '/' unless encodeSlash is set.
+func s3URIEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
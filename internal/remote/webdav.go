@@ -0,0 +1,149 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const webDAVPropfindBody = `<?xml version="1.0" encoding="utf-8"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+
+// webDAVTarget stores objects in a WebDAV collection. Credentials come from
+// the URL's userinfo or DIU_WEBDAV_USERNAME and DIU_WEBDAV_PASSWORD.
+type webDAVTarget struct {
+	base     *url.URL // collection URL without credentials
+	username string
+	password string
+	client   *http.Client
+}
+
+func newWebDAVTarget(u *url.URL, prefix string, opts Options) *webDAVTarget {
+	base := *u
+	base.User = nil
+	base.Path = "/" + prefix
+	base.RawPath = ""
+	base.RawQuery = ""
+	base.Fragment = ""
+
+	target := &webDAVTarget{
+		base:     &base,
+		username: opts.Getenv(envWebDAVUsername),
+		password: opts.Getenv(envWebDAVPassword),
+		client:   opts.HTTPClient,
+	}
+	if u.User != nil {
+		target.username = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			target.password = password
+		}
+	}
+	return target
+}
+
+func (t *webDAVTarget) String() string {
+	return t.base.String()
+}
+
+func (t *webDAVTarget) Put(ctx context.Context, name string, data []byte) error {
+	err := t.put(ctx, name, data)
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusConflict {
+		return err
+	}
+
+	// 409 means the collection does not exist yet.
+	req, err := t.newRequest(ctx, "MKCOL", t.collectionURL(), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := do(t.client, req); err != nil {
+		return fmt.Errorf("failed to create WebDAV collection: %w", err)
+	}
+	return t.put(ctx, name, data)
+}
+
+func (t *webDAVTarget) put(ctx context.Context, name string, data []byte) error {
+	req, err := t.newRequest(ctx, http.MethodPut, t.objectURL(name), data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = do(t.client, req)
+	return err
+}
+
+func (t *webDAVTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	req, err := t.newRequest(ctx, http.MethodGet, t.objectURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(t.client, req)
+}
+
+type webDAVMultistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+	} `xml:"response"`
+}
+
+func (t *webDAVTarget) List(ctx context.Context) ([]string, error) {
+	req, err := t.newRequest(ctx, "PROPFIND", t.collectionURL(), []byte(webDAVPropfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	body, err := do(t.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result webDAVMultistatus
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse WebDAV listing: %w", err)
+	}
+
+	collection := strings.TrimSuffix(t.base.Path, "/")
+	var names []string
+	for _, response := range result.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		hrefPath := strings.TrimSuffix(href.Path, "/")
+		if hrefPath == collection || path.Dir(hrefPath) != collection {
+			continue
+		}
+		names = append(names, path.Base(hrefPath))
+	}
+	return names, nil
+}
+
+func (t *webDAVTarget) collectionURL() string {
+	u := *t.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	return u.String()
+}
+
+func (t *webDAVTarget) objectURL(name string) string {
+	u := *t.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	return u.String()
+}
+
+func (t *webDAVTarget) newRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return req, nil
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// sendMail is replaced in tests.
+var sendMail = smtp.SendMail
+
+// SendEmail mails the report through the configured SMTP server. The
+// connection is upgraded with STARTTLS when the server offers it.
+func SendEmail(config core.SMTPConfig, r *Report) error {
+	if config.Host == "" {
+		return fmt.Errorf("reporting.smtp.host is not set")
+	}
+	if config.From == "" {
+		return fmt.Errorf("reporting.smtp.from is not set")
+	}
+	if len(config.To) == 0 {
+		return fmt.Errorf("reporting.smtp.to is not set")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = core.DefaultSMTPPort
+	}
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		password := config.Password
+		if password == "" {
+			password = os.Getenv(core.SMTPPasswordEnv)
+		}
+		auth = smtp.PlainAuth("", config.Username, password, config.Host)
+	}
+
+	message := emailMessage(config.From, config.To, r, time.Now())
+	if err := sendMail(addr, auth, config.From, config.To, message); err != nil {
+		return fmt.Errorf("failed to send report email via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// emailMessage renders r as a plain-text RFC 5322 message.
+func emailMessage(from string, to []string, r *Report, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", r.Subject()))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(r.Text(), "\n", "\r\n"))
+	return []byte(b.String())
+}
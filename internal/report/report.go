@@ -0,0 +1,141 @@
+// Package report builds usage summaries and delivers them.
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+
+	topPackagesLimit = 10
+)
+
+// PackageUsage counts executions that affected a package.
+type PackageUsage struct {
+	Tool  string `json:"tool"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ToolUsage counts executions of a tool.
+type ToolUsage struct {
+	Tool  string `json:"tool"`
+	Count int    `json:"count"`
+}
+
+// Report summarizes executions recorded between Since and Until.
+type Report struct {
+	Period           string         `json:"period"`
+	Since            time.Time      `json:"since"`
+	Until            time.Time      `json:"until"`
+	TotalExecutions  int            `json:"total_executions"`
+	FailedExecutions int            `json:"failed_executions"`
+	Tools            []ToolUsage    `json:"tools"`
+	TopPackages      []PackageUsage `json:"top_packages"`
+}
+
+// PeriodLength returns how much history a report for period covers.
+func PeriodLength(period string) (time.Duration, error) {
+	switch period {
+	case PeriodDaily:
+		return 24 * time.Hour, nil
+	case PeriodWeekly:
+		return 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown report period: %s (use %s or %s)", period, PeriodDaily, PeriodWeekly)
+	}
+}
+
+// Generate builds the report for the period ending at now.
+func Generate(store storage.Storage, period string, now time.Time) (*Report, error) {
+	length, err := PeriodLength(period)
+	if err != nil {
+		return nil, err
+	}
+	since := now.Add(-length)
+
+	query := storage.QueryOptions{Since: &since, Until: &now}
+	tools, err := store.Aggregate(storage.AggregateOptions{Query: query, GroupBy: storage.GroupByTool})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count executions: %w", err)
+	}
+	packages, err := store.Aggregate(storage.AggregateOptions{Query: query, GroupBy: storage.GroupByPackage})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count packages: %w", err)
+	}
+	failedQuery := query
+	failedQuery.FailedOnly = true
+	failed, err := store.Aggregate(storage.AggregateOptions{Query: failedQuery})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count failed executions: %w", err)
+	}
+
+	report := &Report{
+		Period:           period,
+		Since:            since,
+		Until:            now,
+		TotalExecutions:  tools.Total.Count,
+		FailedExecutions: failed.Total.Count,
+	}
+	// Groups come sorted by descending count, then key.
+	for _, group := range tools.Groups {
+		report.Tools = append(report.Tools, ToolUsage{Tool: group.Key, Count: group.Count})
+	}
+	for _, group := range packages.Groups {
+		tool, name, _ := strings.Cut(group.Key, "/")
+		report.TopPackages = append(report.TopPackages, PackageUsage{Tool: tool, Name: name, Count: group.Count})
+	}
+	if len(report.TopPackages) > topPackagesLimit {
+		report.TopPackages = report.TopPackages[:topPackagesLimit]
+	}
+
+	return report, nil
+}
+
+// Subject returns a one-line title for the report.
+func (r *Report) Subject() string {
+	if r.Period == PeriodWeekly {
+		return fmt.Sprintf("diu weekly summary for %s to %s",
+			r.Since.Format("2006-01-02"), r.Until.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("diu daily summary for %s", r.Until.Format("2006-01-02"))
+}
+
+// Text renders the report as plain text.
+func (r *Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, r.Subject())
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Executions: %d", r.TotalExecutions)
+	if r.FailedExecutions > 0 {
+		fmt.Fprintf(&b, " (%d failed)", r.FailedExecutions)
+	}
+	fmt.Fprintln(&b)
+
+	if len(r.Tools) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "By tool:")
+		for _, tool := range r.Tools {
+			fmt.Fprintf(&b, "  %-12s %d\n", tool.Tool, tool.Count)
+		}
+	}
+
+	if len(r.TopPackages) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "Top packages:")
+		for i, pkg := range r.TopPackages {
+			fmt.Fprintf(&b, "  %d. %s (%s) - %d\n", i+1, pkg.Name, pkg.Tool, pkg.Count)
+		}
+	}
+
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Generated by diu %s\n", core.Version)
+	return b.String()
+}
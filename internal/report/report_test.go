@@ -0,0 +1,126 @@
+package report
+
+import (
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+func newTestStore(t *testing.T) storage.Storage {
+	t.Helper()
+	store, err := storage.NewJSONStorage(&core.Config{
+		Storage: core.StorageConfig{JSONFile: filepath.Join(t.TempDir(), "executions.json")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestGenerateSummarizesPeriod(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	records := []*core.ExecutionRecord{
+		{Tool: "npm", Command: "npm install -g typescript", Timestamp: now.Add(-time.Hour), PackagesAffected: []string{"typescript"}},
+		{Tool: "npm", Command: "npm install -g typescript", Timestamp: now.Add(-2 * time.Hour), PackagesAffected: []string{"typescript"}, ExitCode: 1},
+		{Tool: "homebrew", Command: "brew install jq", Timestamp: now.Add(-3 * time.Hour), PackagesAffected: []string{"jq"}},
+		{Tool: "homebrew", Command: "brew install wget", Timestamp: now.Add(-48 * time.Hour), PackagesAffected: []string{"wget"}},
+	}
+	if _, err := store.AddExecutions(records); err != nil {
+		t.Fatalf("AddExecutions failed: %v", err)
+	}
+
+	daily, err := Generate(store, PeriodDaily, now)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if daily.TotalExecutions != 3 || daily.FailedExecutions != 1 {
+		t.Fatalf("Unexpected daily totals: %+v", daily)
+	}
+	if daily.Tools[0] != (ToolUsage{Tool: "npm", Count: 2}) {
+		t.Fatalf("Expected npm to lead tools, got %+v", daily.Tools)
+	}
+	if daily.TopPackages[0] != (PackageUsage{Tool: "npm", Name: "typescript", Count: 2}) {
+		t.Fatalf("Expected typescript to lead packages, got %+v", daily.TopPackages)
+	}
+
+	weekly, err := Generate(store, PeriodWeekly, now)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if weekly.TotalExecutions != 4 {
+		t.Fatalf("Expected 4 weekly executions, got %d", weekly.TotalExecutions)
+	}
+
+	text := daily.Text()
+	for _, want := range []string{"diu daily summary", "Executions: 3 (1 failed)", "1. typescript (npm) - 2"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("Expected %q in report text:\n%s", want, text)
+		}
+	}
+}
+
+func TestGenerateRejectsUnknownPeriod(t *testing.T) {
+	if _, err := Generate(newTestStore(t), "hourly", time.Now()); err == nil {
+		t.Fatal("Expected error for unknown period")
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	oldSendMail := sendMail
+	defer func() { sendMail = oldSendMail }()
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMessage []byte
+	var gotAuth smtp.Auth
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMessage = addr, auth, from, to, msg
+		return nil
+	}
+	t.Setenv(core.SMTPPasswordEnv, "secret")
+
+	summary := &Report{Period: PeriodDaily, Until: time.Now(), TotalExecutions: 5}
+	err := SendEmail(core.SMTPConfig{
+		Host:     "smtp.example.com",
+		Username: "diu",
+		From:     "diu@example.com",
+		To:       []string{"me@example.com", "team@example.com"},
+	}, summary)
+	if err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+
+	if gotAddr != "smtp.example.com:587" || gotFrom != "diu@example.com" || len(gotTo) != 2 || gotAuth == nil {
+		t.Fatalf("Unexpected send: addr=%s from=%s to=%v auth=%v", gotAddr, gotFrom, gotTo, gotAuth)
+	}
+	message := string(gotMessage)
+	if !strings.Contains(message, "To: me@example.com, team@example.com\r\n") ||
+		!strings.Contains(message, "Subject: diu daily summary") ||
+		!strings.Contains(message, "\r\n\r\ndiu daily summary") {
+		t.Fatalf("Unexpected message:\n%s", message)
+	}
+}
+
+func TestSendEmailRequiresSettings(t *testing.T) {
+	summary := &Report{Period: PeriodDaily}
+	tests := []struct {
+		config core.SMTPConfig
+		want   string
+	}{
+		{core.SMTPConfig{From: "a@example.com", To: []string{"b@example.com"}}, "host"},
+		{core.SMTPConfig{Host: "smtp.example.com", To: []string{"b@example.com"}}, "from"},
+		{core.SMTPConfig{Host: "smtp.example.com", From: "a@example.com"}, "to"},
+	}
+	for _, tt := range tests {
+		if err := SendEmail(tt.config, summary); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SendEmail(%+v) error = %v, want mention of %s", tt.config, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package server implements diu server, a central collector for a team.
+// Each person's daemon syncs to it with their own token, as with a central
+// daemon, but records are kept per user and machine and the server answers
+// questions about the whole team, such as which tools and packages anyone
+// actually uses.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// StatsPath is the API path team stats are served on
+const StatsPath = "/api/v1/team/stats"
+
+const (
+	maxSyncBodyBytes  = 32 << 20
+	maxSyncRecords    = 10000
+	unknownMachine    = "unknown"
+	tokenBytes        = 32
+	defaultStatsLimit = 50
+)
+
+// Server accepts syncs from many users' daemons into a TenantStorage
+type Server struct {
+	config *core.Config
+	store  *storage.TenantStorage
+	logger *slog.Logger
+}
+
+// New opens the tenant stores in config.ServerDataDir()
+func New(config *core.Config, logger *slog.Logger) (*Server, error) {
+	store, err := storage.NewTenantStorage(config, config.ServerDataDir())
+	if err != nil {
+		return nil, err
+	}
+	return &Server{config: config, store: store, logger: logger}, nil
+}
+
+// Close closes the tenant stores
+func (s *Server) Close() error {
+	return s.store.Close()
+}
+
+// Handler serves the sync, team stats, and health endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(fleet.Path, s.handleSync)
+	mux.HandleFunc(StatsPath, s.handleStats)
+	mux.HandleFunc("/api/v1/health", s.handleHealth)
+	return mux
+}
+
+// ListenAndServe serves on server.host and server.port until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := net.JoinHostPort(s.config.Server.Host, strconv.Itoa(s.config.Server.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadTimeout:       core.DefaultSocketReadTimeout,
+		ReadHeaderTimeout: core.DefaultShutdownTimeout,
+		WriteTimeout:      core.DefaultSocketReadTimeout,
+		IdleTimeout:       core.DefaultSocketReadTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("Team server listening", "addr", listener.Addr().String(), "users", len(s.config.Server.Users))
+		errCh <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), core.DefaultShutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// NewToken returns a random token for a user and the hash to keep in
+// server.users
+func NewToken() (token, hash string, err error) {
+	random := make([]byte, tokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	token = "diu_" + hex.EncodeToString(random)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the SHA-256 of token as kept in server.users
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate returns the user whose token the request carries
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	hash := []byte(HashToken(strings.TrimSpace(token)))
+	for user, want := range s.config.Server.Users {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(want))) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request fleet.Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBodyBytes)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid sync request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(request.Executions) > maxSyncRecords {
+		http.Error(w, fmt.Sprintf("sync exceeds %d records", maxSyncRecords), http.StatusBadRequest)
+		return
+	}
+
+	tenant := storage.Tenant{User: user, Machine: machineName(request)}
+	store, err := s.store.Open(tenant)
+	if errors.Is(err, storage.ErrMachineLimit) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var response fleet.Response
+	accepted, rejected := request.Accepted()
+	response.Rejected = rejected
+	if len(accepted) > 0 {
+		result, err := store.ImportRecords(accepted, nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to store executions: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response.Inserted, response.Skipped = result.ExecutionsInserted, result.ExecutionsSkipped
+	}
+	s.logger.Info("Synced executions", "user", user, "machine", tenant.Machine, "inserted", response.Inserted,
+		"skipped", response.Skipped, "rejected", response.Rejected)
+
+	// A user's sync reports their own totals across their machines; the
+	// team's are at StatsPath.
+	stats := fleet.Stats{Tools: make(map[string]int), Hosts: make(map[string]int), Updated: time.Now()}
+	err = s.store.StreamExecutions(func(t storage.Tenant) bool { return t.User == user }, storage.QueryOptions{},
+		func(t storage.Tenant, record *core.ExecutionRecord) error {
+			stats.TotalExecutions++
+			stats.Tools[record.Tool]++
+			host := record.Host
+			if host == "" {
+				host = t.Machine
+			}
+			stats.Hosts[host]++
+			return nil
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Stats = stats
+	writeJSON(w, s.logger, response)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.authenticate(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	opts := StatsOptions{Limit: defaultStatsLimit}
+	if last := r.URL.Query().Get("last"); last != "" {
+		duration, err := core.ParseDuration(last)
+		if err != nil || duration <= 0 {
+			http.Error(w, fmt.Sprintf("invalid last: %q", last), http.StatusBadRequest)
+			return
+		}
+		opts.Last = duration
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit: %q", limit), http.StatusBadRequest)
+			return
+		}
+		opts.Limit = n
+	}
+
+	stats, err := Team(s.store, opts, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, s.logger, stats)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.logger, map[string]string{"status": core.HealthStatusOK, "version": core.Version})
+}
+
+// machineName names the tenant a sync request is stored under: its machine
+// ID, which survives renames, or else its hostname
+func machineName(request fleet.Request) string {
+	for _, name := range []string{request.MachineID, request.Host} {
+		name = strings.Map(func(r rune) rune {
+			if r < 0x80 && (r == '.' || r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z') {
+				return r
+			}
+			return '-'
+		}, name)
+		name = strings.TrimLeft(name, ".-_")
+		if len(name) > 64 {
+			name = name[:64]
+		}
+		if core.ValidTenantName(name) {
+			return name
+		}
+	}
+	return unknownMachine
+}
+
+func writeJSON(w http.ResponseWriter, logger *slog.Logger, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/fleet"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+func newTestServer(t *testing.T, users ...string) (*Server, map[string]string) {
+	t.Helper()
+	config := &core.Config{Daemon: core.DaemonConfig{DataDir: t.TempDir()}}
+	config.Server.Users = make(map[string]string)
+	tokens := make(map[string]string)
+	for _, user := range users {
+		token, hash, err := NewToken()
+		if err != nil {
+			t.Fatalf("NewToken failed: %v", err)
+		}
+		config.Server.Users[user] = hash
+		tokens[user] = token
+	}
+
+	srv, err := New(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv, tokens
+}
+
+// pushFrom syncs executions to url as the machine host would
+func pushFrom(t *testing.T, url, token, host string, commands ...string) fleet.Response {
+	t.Helper()
+	request := fleet.Request{Host: host, MachineID: host + "-id"}
+	for i, command := range commands {
+		tool, pkg, _ := strings.Cut(command, " ")
+		request.Executions = append(request.Executions, &core.ExecutionRecord{
+			ID: fmt.Sprintf("%s-%d", host, i), Tool: tool, Command: command,
+			PackagesAffected: []string{pkg}, Timestamp: time.Now(),
+		})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url+fleet.Path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	var response fleet.Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid sync response: %v", err)
+	}
+	return response
+}
+
+func TestSyncRequiresToken(t *testing.T) {
+	srv, tokens := newTestServer(t, "ana")
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	for _, token := range []string{"", "diu_wrong"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+fleet.Path, strings.NewReader(`{"executions": []}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for token %q, got %d", token, resp.StatusCode)
+		}
+	}
+
+	dir := t.TempDir()
+	config := &core.Config{
+		Daemon:  core.DaemonConfig{DataDir: dir},
+		Storage: core.StorageConfig{JSONFile: filepath.Join(dir, "executions.json")},
+		Sync:    core.SyncConfig{Token: tokens["ana"]},
+	}
+	store, err := storage.NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if err := store.AddExecution(&core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("AddExecution failed: %v", err)
+	}
+	if result, err := fleet.Sync(context.Background(), config, store, ts.URL, false); err != nil || result.Inserted != 1 {
+		t.Errorf("Expected diu sync with sync.token to be accepted, got %+v, %v", result, err)
+	}
+
+	resp, err := http.Get(ts.URL + StatsPath)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for team stats without a token, got %d", resp.StatusCode)
+	}
+}
+
+func TestSyncNamespacesUsersAndMachines(t *testing.T) {
+	srv, tokens := newTestServer(t, "ana", "ben")
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	pushFrom(t, ts.URL, tokens["ana"], "laptop", "npm typescript", "brew jq")
+	result := pushFrom(t, ts.URL, tokens["ana"], "devbox", "npm typescript")
+	pushFrom(t, ts.URL, tokens["ben"], "laptop", "npm typescript", "npm acme-internal-cli")
+	if again := pushFrom(t, ts.URL, tokens["ben"], "laptop", "npm typescript"); again.Inserted != 0 || again.Skipped != 1 {
+		t.Errorf("Expected a repeated push to be skipped, got %+v", again)
+	}
+
+	if result.Stats.TotalExecutions != 3 || result.Stats.Hosts["laptop"] != 2 || result.Stats.Hosts["devbox"] != 1 {
+		t.Errorf("Expected ana's sync to report only her machines, got %+v", result.Stats)
+	}
+
+	tenants, err := srv.store.Tenants()
+	if err != nil {
+		t.Fatalf("Tenants failed: %v", err)
+	}
+	want := []storage.Tenant{{User: "ana", Machine: "devbox-id"}, {User: "ana", Machine: "laptop-id"}, {User: "ben", Machine: "laptop-id"}}
+	if len(tenants) != len(want) {
+		t.Fatalf("Expected tenants %v, got %v", want, tenants)
+	}
+	for i := range want {
+		if tenants[i] != want[i] {
+			t.Errorf("Expected tenant %v, got %v", want[i], tenants[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(srv.config.ServerDataDir(), "ben", "laptop-id", "executions.json")); err != nil {
+		t.Errorf("Expected ben's laptop to have its own store: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+StatsPath+"?last=7d", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["ben"])
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var stats TeamStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("invalid team stats: %v", err)
+	}
+
+	if stats.Users != 2 || stats.Machines != 3 || stats.TotalExecutions != 5 {
+		t.Errorf("Unexpected team totals: %+v", stats)
+	}
+	if top := stats.Packages[0]; top.Name != "typescript" || top.Users != 2 || top.Executions != 3 {
+		t.Errorf("Expected typescript used by both users first, got %+v", top)
+	}
+	if top := stats.Tools[0]; top.Name != core.ToolNPM || top.Users != 2 {
+		t.Errorf("Expected npm used by both users first, got %+v", top)
+	}
+}
+
+func TestMachineName(t *testing.T) {
+	tests := []struct {
+		request fleet.Request
+		want    string
+	}{
+		{fleet.Request{MachineID: "3f2a9c", Host: "laptop"}, "3f2a9c"},
+		{fleet.Request{Host: "ana's MacBook Pro"}, "ana-s-MacBook-Pro"},
+		{fleet.Request{Host: "../etc"}, "etc"},
+		{fleet.Request{}, unknownMachine},
+	}
+	for _, tt := range tests {
+		if got := machineName(tt.request); got != tt.want {
+			t.Errorf("machineName(%+v) = %q, want %q", tt.request, got, tt.want)
+		}
+	}
+}
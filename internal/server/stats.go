@@ -0,0 +1,110 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/storage"
+)
+
+// StatsOptions narrows team stats
+type StatsOptions struct {
+	// Last only counts executions within this long of now; 0 counts all.
+	Last time.Duration
+	// Limit bounds the tools and packages listed; 0 lists all.
+	Limit int
+}
+
+// TeamStats summarizes usage across every user of a team server
+type TeamStats struct {
+	Users           int       `json:"users"`
+	Machines        int       `json:"machines"`
+	TotalExecutions int       `json:"total_executions"`
+	Tools           []Usage   `json:"tools"`
+	Packages        []Usage   `json:"packages"`
+	Updated         time.Time `json:"updated"`
+}
+
+// Usage counts how much a tool or package is used and by how many people,
+// which separates something the whole team relies on from one person's habit
+type Usage struct {
+	Name       string    `json:"name"`
+	Tool       string    `json:"tool,omitempty"`
+	Executions int       `json:"executions"`
+	Users      int       `json:"users"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// Team aggregates the executions of every tenant in store. Tools and
+// packages are ranked by the number of users, then executions.
+func Team(store *storage.TenantStorage, opts StatsOptions, now time.Time) (*TeamStats, error) {
+	query := storage.QueryOptions{}
+	if opts.Last > 0 {
+		since := now.Add(-opts.Last)
+		query.Since = &since
+	}
+
+	type usage struct {
+		Usage
+		users map[string]bool
+	}
+	tools := make(map[string]*usage)
+	packages := make(map[string]*usage)
+	count := func(counts map[string]*usage, key, name, tool, user string, at time.Time) {
+		u := counts[key]
+		if u == nil {
+			u = &usage{Usage: Usage{Name: name, Tool: tool}, users: make(map[string]bool)}
+			counts[key] = u
+		}
+		u.Executions++
+		u.users[user] = true
+		if at.After(u.LastUsed) {
+			u.LastUsed = at
+		}
+	}
+
+	stats := &TeamStats{Updated: now}
+	users := make(map[string]bool)
+	machines := make(map[storage.Tenant]bool)
+	err := store.StreamExecutions(nil, query, func(tenant storage.Tenant, record *core.ExecutionRecord) error {
+		users[tenant.User] = true
+		machines[tenant] = true
+		stats.TotalExecutions++
+		count(tools, record.Tool, record.Tool, "", tenant.User, record.Timestamp)
+		for _, pkg := range record.PackagesAffected {
+			count(packages, record.Tool+"/"+pkg, pkg, record.Tool, tenant.User, record.Timestamp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.Users, stats.Machines = len(users), len(machines)
+
+	rank := func(counts map[string]*usage) []Usage {
+		ranked := make([]Usage, 0, len(counts))
+		for _, u := range counts {
+			u.Users = len(u.users)
+			ranked = append(ranked, u.Usage)
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].Users != ranked[j].Users {
+				return ranked[i].Users > ranked[j].Users
+			}
+			if ranked[i].Executions != ranked[j].Executions {
+				return ranked[i].Executions > ranked[j].Executions
+			}
+			if ranked[i].Tool != ranked[j].Tool {
+				return ranked[i].Tool < ranked[j].Tool
+			}
+			return ranked[i].Name < ranked[j].Name
+		})
+		if opts.Limit > 0 && len(ranked) > opts.Limit {
+			ranked = ranked[:opts.Limit]
+		}
+		return ranked
+	}
+	stats.Tools, stats.Packages = rank(tools), rank(packages)
+	return stats, nil
+}
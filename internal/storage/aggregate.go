@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// Groupings accepted by AggregateOptions.GroupBy
+const (
+	GroupByTool    = "tool"
+	GroupByPackage = "package"
+	GroupByDay     = "day"
+	GroupByHour    = "hour"
+	GroupByWeekday = "weekday"
+)
+
+// AggregateOptions selects the executions to aggregate and how to group
+// them
+type AggregateOptions struct {
+	// Query selects the executions; Limit, Offset, and sorting are ignored.
+	Query QueryOptions
+	// GroupBy is GroupByTool, GroupByPackage (keyed tool/name, counting an
+	// execution once per package it affected), GroupByDay (keyed
+	// YYYY-MM-DD), GroupByHour (keyed by local hour, 00 through 23),
+	// GroupByWeekday (keyed by local weekday name), or empty for the totals
+	// alone.
+	GroupBy string
+	// Durations sums the duration of each group's executions as well as
+	// counting them.
+	Durations bool
+}
+
+// AggregateGroup totals the executions in one group
+type AggregateGroup struct {
+	Key      string
+	Count    int
+	Duration time.Duration
+}
+
+// AggregateResult holds the totals of every matching execution and, when
+// grouped, of each group: day, hour, and weekday groups in calendar order
+// and the others by descending count
+type AggregateResult struct {
+	Total  AggregateGroup
+	Groups []AggregateGroup
+}
+
+// validGroupBy reports an error for a grouping Aggregate does not know
+func validGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", GroupByTool, GroupByPackage, GroupByDay, GroupByHour, GroupByWeekday:
+		return nil
+	}
+	return fmt.Errorf("invalid group by %q: must be %s, %s, %s, %s, or %s",
+		groupBy, GroupByTool, GroupByPackage, GroupByDay, GroupByHour, GroupByWeekday)
+}
+
+// timeOfDay reports whether groupBy groups by when in the day or week
+// executions ran
+func timeOfDay(groupBy string) bool {
+	return groupBy == GroupByHour || groupBy == GroupByWeekday
+}
+
+// Aggregate counts the executions matching opts. Counts over whole days,
+// with Since at midnight or unset, Until at midnight, the last instant of a
+// day, or unset, and no filter other than Tool, are read from the daily
+// aggregates without reading executions; a midnight Until excludes its
+// day. Hour and weekday counts of every execution are read
+// from the stored histograms. Anything else, including durations, is
+// counted from the executions.
+func (j *JSONStorage) Aggregate(opts AggregateOptions) (*AggregateResult, error) {
+	if err := validGroupBy(opts.GroupBy); err != nil {
+		return nil, err
+	}
+	first, last, ok := opts.wholeDays()
+	if ok && timeOfDay(opts.GroupBy) {
+		ok = first == "" && last == "" && opts.Query.Tool == ""
+	}
+	if !ok {
+		return AggregateExecutions(j, opts)
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if timeOfDay(opts.GroupBy) {
+		return aggregateHistogram(&j.data.Statistics, opts.GroupBy), nil
+	}
+	return aggregateDays(j.data.Statistics.Days, first, last, opts), nil
+}
+
+// aggregateHistogram groups every execution by the stored hour or weekday
+// counts
+func aggregateHistogram(stats *core.StorageStatistics, groupBy string) *AggregateResult {
+	result := &AggregateResult{Total: AggregateGroup{Count: stats.TotalExecutions}}
+	counts := stats.Hours
+	if groupBy == GroupByWeekday {
+		counts = stats.Weekdays
+	}
+	groups := make(map[string]*AggregateGroup)
+	for i, count := range counts {
+		if count > 0 {
+			key := timeOfDayKey(groupBy, i)
+			groups[key] = &AggregateGroup{Key: key, Count: count}
+		}
+	}
+	result.Groups = sortAggregateGroups(groups, groupBy)
+	return result
+}
+
+// timeOfDayKey is the group key of hour or weekday index i
+func timeOfDayKey(groupBy string, i int) string {
+	if groupBy == GroupByWeekday {
+		return time.Weekday(i).String()
+	}
+	return fmt.Sprintf("%02d", i)
+}
+
+// wholeDays returns the first day and the day after the last that opts
+// covers when the daily aggregates can answer it
+func (opts AggregateOptions) wholeDays() (string, string, bool) {
+	q := opts.Query
+	if opts.Durations || q.Package != "" || q.ExitCode != nil || q.FailedOnly || q.WorkingDir != "" ||
+		q.Project != "" || q.SessionID != "" || q.Host != "" || q.CommandPattern != nil {
+		return "", "", false
+	}
+	first, ok := dayBound(q.Since)
+	if !ok {
+		return "", "", false
+	}
+	if q.Until != nil {
+		if next := q.Until.Add(time.Nanosecond); next.Day() != q.Until.Day() {
+			return first, next.Format(time.DateOnly), true
+		}
+	}
+	last, ok := dayBound(q.Until)
+	return first, last, ok
+}
+
+// dayBound returns t's date when it is midnight, "" for an open bound
+func dayBound(t *time.Time) (string, bool) {
+	if t == nil {
+		return "", true
+	}
+	year, month, day := t.Date()
+	if !t.Equal(time.Date(year, month, day, 0, 0, 0, 0, t.Location())) {
+		return "", false
+	}
+	return t.Format(time.DateOnly), true
+}
+
+// aggregateDays totals the daily aggregates from first up to but not
+// including last
+func aggregateDays(days map[string]core.DayAggregate, first, last string, opts AggregateOptions) *AggregateResult {
+	tool := opts.Query.Tool
+	result := &AggregateResult{}
+	counts := make(map[string]int)
+	for key, day := range days {
+		if (first != "" && key < first) || (last != "" && key >= last) {
+			continue
+		}
+		dayTotal := day.Executions
+		if tool != "" {
+			dayTotal = day.Tools[tool]
+		}
+		result.Total.Count += dayTotal
+		switch opts.GroupBy {
+		case GroupByTool:
+			for name, count := range day.Tools {
+				if tool == "" || name == tool {
+					counts[name] += count
+				}
+			}
+		case GroupByDay:
+			if dayTotal > 0 {
+				counts[key] += dayTotal
+			}
+		case GroupByPackage:
+			for name, count := range day.Packages {
+				if tool == "" || strings.HasPrefix(name, tool+"/") {
+					counts[name] += count
+				}
+			}
+		}
+	}
+	if opts.GroupBy != "" {
+		groups := make(map[string]*AggregateGroup, len(counts))
+		for key, count := range counts {
+			groups[key] = &AggregateGroup{Key: key, Count: count}
+		}
+		result.Groups = sortAggregateGroups(groups, opts.GroupBy)
+	}
+	return result
+}
+
+// AggregateExecutions aggregates by reading every matching execution from
+// store, for backends with no faster way to answer opts
+func AggregateExecutions(store Storage, opts AggregateOptions) (*AggregateResult, error) {
+	if err := validGroupBy(opts.GroupBy); err != nil {
+		return nil, err
+	}
+	query := opts.Query
+	query.Limit, query.Offset = 0, 0
+
+	result := &AggregateResult{}
+	groups := make(map[string]*AggregateGroup)
+	add := func(key string, exec *core.ExecutionRecord) {
+		group, ok := groups[key]
+		if !ok {
+			group = &AggregateGroup{Key: key}
+			groups[key] = group
+		}
+		group.Count++
+		if opts.Durations {
+			group.Duration += exec.Duration
+		}
+	}
+	err := store.StreamExecutions(query, func(exec *core.ExecutionRecord) error {
+		result.Total.Count++
+		if opts.Durations {
+			result.Total.Duration += exec.Duration
+		}
+		switch opts.GroupBy {
+		case GroupByTool:
+			add(exec.Tool, exec)
+		case GroupByDay:
+			add(exec.Timestamp.Format(time.DateOnly), exec)
+		case GroupByHour, GroupByWeekday:
+			if exec.Timestamp.IsZero() {
+				break
+			}
+			local := exec.Timestamp.Local()
+			if opts.GroupBy == GroupByHour {
+				add(timeOfDayKey(GroupByHour, local.Hour()), exec)
+			} else {
+				add(timeOfDayKey(GroupByWeekday, int(local.Weekday())), exec)
+			}
+		case GroupByPackage:
+			for _, name := range exec.PackagesAffected {
+				add(exec.Tool+"/"+name, exec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.GroupBy != "" {
+		result.Groups = sortAggregateGroups(groups, opts.GroupBy)
+	}
+	return result, nil
+}
+
+// sortAggregateGroups orders day, hour, and weekday groups by when they
+// fall, weeks starting on Sunday, and the others by descending count, then
+// key
+func sortAggregateGroups(groups map[string]*AggregateGroup, groupBy string) []AggregateGroup {
+	sorted := make([]AggregateGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, k int) bool {
+		switch groupBy {
+		case GroupByDay, GroupByHour:
+			return sorted[i].Key < sorted[k].Key
+		case GroupByWeekday:
+			return weekdayIndex(sorted[i].Key) < weekdayIndex(sorted[k].Key)
+		}
+		if sorted[i].Count != sorted[k].Count {
+			return sorted[i].Count > sorted[k].Count
+		}
+		return sorted[i].Key < sorted[k].Key
+	})
+	return sorted
+}
+
+// weekdayIndex returns the time.Weekday named name
+func weekdayIndex(name string) int {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if day.String() == name {
+			return int(day)
+		}
+	}
+	return 7
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestAggregateFromDailyCountsMatchesExecutions(t *testing.T) {
+	opened, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: filepath.Join(t.TempDir(), "executions.json")}})
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	store := opened.(*JSONStorage)
+	defer closeStorage(t, store)
+
+	day := time.Date(2026, 4, 6, 9, 0, 0, 0, time.Local)
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: "npm", Command: "npm install tsx", Timestamp: day, Duration: 2 * time.Second, PackagesAffected: []string{"tsx"}},
+		{Tool: "npm", Command: "npm install tsx vite", Timestamp: day.Add(time.Hour), Duration: 3 * time.Second, PackagesAffected: []string{"tsx", "vite"}},
+		{Tool: "go", Command: "go build", Timestamp: day.AddDate(0, 0, 1), Duration: time.Second, ExitCode: 1},
+		{Tool: "go", Command: "go test", Timestamp: day.AddDate(0, 0, 3)},
+	} {
+		addExecution(t, store, record)
+	}
+
+	since := time.Date(2026, 4, 6, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 2)
+	for _, groupBy := range []string{"", GroupByTool, GroupByPackage, GroupByDay} {
+		opts := AggregateOptions{Query: QueryOptions{Since: &since, Until: &until}, GroupBy: groupBy}
+		fromDays, err := store.Aggregate(opts)
+		if err != nil {
+			t.Fatalf("Aggregate by %q failed: %v", groupBy, err)
+		}
+		scanned, err := AggregateExecutions(store, opts)
+		if err != nil {
+			t.Fatalf("AggregateExecutions by %q failed: %v", groupBy, err)
+		}
+		if fromDays.Total.Count != 3 || !reflect.DeepEqual(fromDays, scanned) {
+			t.Errorf("Expected daily counts by %q to match the executions, got %+v and %+v", groupBy, fromDays, scanned)
+		}
+	}
+
+	endOfDay := since.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if first, last, ok := (AggregateOptions{Query: QueryOptions{Since: &since, Until: &endOfDay}}).wholeDays(); !ok || first != "2026-04-06" || last != "2026-04-07" {
+		t.Errorf("Expected an Until at the end of a day to cover that day, got %q to %q (%v)", first, last, ok)
+	}
+
+	packages, _ := store.Aggregate(AggregateOptions{Query: QueryOptions{Tool: "npm"}, GroupBy: GroupByPackage})
+	want := []AggregateGroup{{Key: "npm/tsx", Count: 2}, {Key: "npm/vite", Count: 1}}
+	if !reflect.DeepEqual(packages.Groups, want) {
+		t.Errorf("Expected packages by descending count, got %+v", packages.Groups)
+	}
+
+	durations, err := store.Aggregate(AggregateOptions{GroupBy: GroupByTool, Durations: true})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if durations.Total.Duration != 6*time.Second || durations.Groups[0].Key != "go" || durations.Groups[1].Duration != 5*time.Second {
+		t.Errorf("Expected durations summed per tool, got %+v", durations)
+	}
+
+	failed, _ := store.Aggregate(AggregateOptions{Query: QueryOptions{FailedOnly: true}})
+	if failed.Total.Count != 1 {
+		t.Errorf("Expected one failed execution, got %d", failed.Total.Count)
+	}
+
+	if _, err := store.Aggregate(AggregateOptions{GroupBy: "host"}); err == nil {
+		t.Error("Expected an unknown grouping rejected")
+	}
+}
+
+func TestAggregateByHourAndWeekdayFromHistograms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	config := &core.Config{Storage: core.StorageConfig{JSONFile: path}}
+	opened, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	store := opened.(*JSONStorage)
+
+	monday := time.Date(2026, 3, 2, 9, 30, 0, 0, time.Local)
+	for _, record := range []*core.ExecutionRecord{
+		{Tool: "npm", Command: "npm ci", Timestamp: monday},
+		{Tool: "npm", Command: "npm test", Timestamp: monday.Add(10 * time.Minute)},
+		{Tool: "go", Command: "go build", Timestamp: monday.AddDate(0, 0, 6).Add(12 * time.Hour)},
+	} {
+		addExecution(t, store, record)
+	}
+
+	stats, _ := store.GetStatistics()
+	if len(stats.Hours) != 24 || stats.Hours[9] != 2 || stats.Hours[21] != 1 {
+		t.Errorf("Expected executions counted by hour, got %v", stats.Hours)
+	}
+	if len(stats.Weekdays) != 7 || stats.Weekdays[time.Monday] != 2 || stats.Weekdays[time.Sunday] != 1 {
+		t.Errorf("Expected executions counted by weekday, got %v", stats.Weekdays)
+	}
+
+	for _, groupBy := range []string{GroupByHour, GroupByWeekday} {
+		stored, err := store.Aggregate(AggregateOptions{GroupBy: groupBy})
+		if err != nil {
+			t.Fatalf("Aggregate by %q failed: %v", groupBy, err)
+		}
+		scanned, _ := AggregateExecutions(store, AggregateOptions{GroupBy: groupBy})
+		if !reflect.DeepEqual(stored, scanned) {
+			t.Errorf("Expected the %s histogram to match the executions, got %+v and %+v", groupBy, stored, scanned)
+		}
+	}
+	weekdays, _ := store.Aggregate(AggregateOptions{GroupBy: GroupByWeekday})
+	want := []AggregateGroup{{Key: "Sunday", Count: 1}, {Key: "Monday", Count: 2}}
+	if !reflect.DeepEqual(weekdays.Groups, want) {
+		t.Errorf("Expected weekdays Sunday first, got %+v", weekdays.Groups)
+	}
+	npm, _ := store.Aggregate(AggregateOptions{Query: QueryOptions{Tool: "npm"}, GroupBy: GroupByHour})
+	if !reflect.DeepEqual(npm.Groups, []AggregateGroup{{Key: "09", Count: 2}}) {
+		t.Errorf("Expected npm's executions grouped by hour, got %+v", npm.Groups)
+	}
+	closeStorage(t, store)
+
+	// Files saved before the histograms were kept get them when opened.
+	var data map[string]interface{}
+	raw, _ := os.ReadFile(path)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("Failed to decode storage: %v", err)
+	}
+	statistics := data["statistics"].(map[string]interface{})
+	delete(statistics, "hours")
+	delete(statistics, "weekdays")
+	raw, _ = json.Marshal(data)
+	if err := os.WriteFile(path, raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write storage: %v", err)
+	}
+	reopened, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, reopened)
+	if stats, _ := reopened.GetStatistics(); len(stats.Hours) != 24 || stats.Hours[9] != 2 {
+		t.Errorf("Expected hour counts rebuilt on load, got %v", stats.Hours)
+	}
+}
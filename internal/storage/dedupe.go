@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// contentHash identifies what an execution did, leaving out what differs
+// between two reports of the same run: its ID, timing, and annotations.
+// A wrapper and a shell hook that both see one command produce the same
+// hash.
+func contentHash(record *core.ExecutionRecord) string {
+	h := sha256.New()
+	for _, field := range []string{
+		record.Tool, record.Command, record.WorkingDir, strconv.Itoa(record.ExitCode),
+		record.User, record.Host, record.MachineID, strconv.Itoa(len(record.Args)),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	for _, arg := range record.Args {
+		h.Write([]byte(arg))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recentExecutions finds repeats of executions stored within a window of
+// each other
+type recentExecutions struct {
+	window time.Duration
+	times  map[string][]time.Time
+}
+
+// newRecentExecutions indexes the stored executions that could repeat one
+// of records, which is none when window is zero
+func newRecentExecutions(stored []core.ExecutionRecord, records []*core.ExecutionRecord, window time.Duration) *recentExecutions {
+	recent := &recentExecutions{window: window, times: make(map[string][]time.Time)}
+	if window <= 0 {
+		return recent
+	}
+
+	var earliest, latest time.Time
+	for _, record := range records {
+		if record.Timestamp.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || record.Timestamp.Before(earliest) {
+			earliest = record.Timestamp
+		}
+		if record.Timestamp.After(latest) {
+			latest = record.Timestamp
+		}
+	}
+	if earliest.IsZero() {
+		return recent
+	}
+	earliest, latest = earliest.Add(-window), latest.Add(window)
+	for i := range stored {
+		if stored[i].Timestamp.Before(earliest) || stored[i].Timestamp.After(latest) {
+			continue
+		}
+		recent.add(&stored[i])
+	}
+	return recent
+}
+
+// duplicate reports whether an execution with the same content was stored
+// within the window of record
+func (r *recentExecutions) duplicate(record *core.ExecutionRecord) bool {
+	if r.window <= 0 || record.Timestamp.IsZero() {
+		return false
+	}
+	for _, at := range r.times[contentHash(record)] {
+		if diff := record.Timestamp.Sub(at); diff <= r.window && diff >= -r.window {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recentExecutions) add(record *core.ExecutionRecord) {
+	if r.window <= 0 || record.Timestamp.IsZero() {
+		return
+	}
+	hash := contentHash(record)
+	r.times[hash] = append(r.times[hash], record.Timestamp)
+}
@@ -0,0 +1,405 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// Kinds of problem Check reports
+const (
+	IssueInvalidJSON  = "invalid_json"
+	IssueShard        = "shard"
+	IssueMissingID    = "missing_id"
+	IssueDuplicateID  = "duplicate_id"
+	IssueStatistics   = "statistics"
+	IssuePackageUsage = "package_usage"
+)
+
+// CheckIssue is one problem Check found in a storage file
+type CheckIssue struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Repairable is set when Repair can fix the problem.
+	Repairable bool `json:"repairable"`
+}
+
+// CheckReport is what Check found in a storage file and its shards
+type CheckReport struct {
+	Path       string       `json:"path"`
+	Executions int          `json:"executions"`
+	Packages   int          `json:"packages"`
+	Issues     []CheckIssue `json:"issues"`
+}
+
+// Repairable reports whether Repair can fix every issue in the report
+func (r *CheckReport) Repairable() bool {
+	for _, issue := range r.Issues {
+		if !issue.Repairable {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CheckReport) add(kind string, repairable bool, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, CheckIssue{Kind: kind, Message: fmt.Sprintf(format, args...), Repairable: repairable})
+}
+
+// packageUse is how often the executions record using a package and when
+// they last did
+type packageUse struct {
+	count     int
+	firstUsed time.Time
+	lastUsed  time.Time
+}
+
+// Check reads the storage file at storagePath and its shards, without
+// changing them, and reports whether they are valid JSON, whether every
+// execution has a unique ID, and whether the statistics and package usage
+// agree with the executions. It returns an error only when the files
+// cannot be read at all.
+func Check(storagePath string) (*CheckReport, error) {
+	storagePath, err := cleanManagedPath(storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage path: %w", err)
+	}
+	report := &CheckReport{Path: storagePath, Issues: []CheckIssue{}}
+	err = lockStorageFile(storagePath, func() error {
+		return check(storagePath, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func check(storagePath string, report *CheckReport) error {
+	raw, err := readManagedFile(storagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read storage file: %w", err)
+	}
+	data, _, err := decodeStorage(raw)
+	if err != nil {
+		var unsupported *UnsupportedVersionError
+		if errors.As(err, &unsupported) {
+			return err
+		}
+		report.add(IssueInvalidJSON, false, "storage file is not valid: %v", err)
+		return nil
+	}
+
+	executions := data.Executions
+	for _, shard := range data.Metadata.Shards {
+		records, err := readShard(storagePath, shard.Month)
+		if err != nil {
+			report.add(IssueInvalidJSON, false, "%v", err)
+			continue
+		}
+		if _, statErr := os.Stat(shardPath(storagePath, shard.Month)); os.IsNotExist(statErr) {
+			report.add(IssueShard, true, "shard %s is listed but missing", shard.Month)
+		} else if len(records) != shard.Executions {
+			report.add(IssueShard, true, "shard %s holds %d executions, the storage file lists %d", shard.Month, len(records), shard.Executions)
+		}
+		misplaced := 0
+		for _, record := range records {
+			if shardMonth(record.Timestamp) != shard.Month {
+				misplaced++
+			}
+		}
+		if misplaced > 0 {
+			report.add(IssueShard, true, "shard %s holds %d executions from other months", shard.Month, misplaced)
+		}
+		executions = append(executions, records...)
+	}
+	report.Executions = len(executions)
+	for _, packages := range data.Packages {
+		report.Packages += len(packages)
+	}
+
+	checkIDs(executions, report)
+	checkStatistics(&data.Statistics, executions, report)
+	checkPackages(data.Packages, executions, report)
+	return nil
+}
+
+func checkIDs(executions []core.ExecutionRecord, report *CheckReport) {
+	seen := make(map[string]int, len(executions))
+	missing := 0
+	for _, exec := range executions {
+		if exec.ID == "" {
+			missing++
+			continue
+		}
+		seen[exec.ID]++
+	}
+	if missing > 0 {
+		report.add(IssueMissingID, true, "%d executions have no ID", missing)
+	}
+	ids := make([]string, 0)
+	for id, count := range seen {
+		if count > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		report.add(IssueDuplicateID, true, "execution ID %s is stored %d times", id, seen[id])
+	}
+}
+
+func checkStatistics(stored *core.StorageStatistics, executions []core.ExecutionRecord, report *CheckReport) {
+	var counted core.StorageStatistics
+	for i := range executions {
+		counted.Count(&executions[i])
+	}
+
+	if stored.TotalExecutions != counted.TotalExecutions {
+		report.add(IssueStatistics, true, "total_executions is %d, the executions count %d", stored.TotalExecutions, counted.TotalExecutions)
+	}
+	tools := make(map[string]bool)
+	for tool := range stored.ExecutionFrequency {
+		tools[tool] = true
+	}
+	for tool := range counted.ExecutionFrequency {
+		tools[tool] = true
+	}
+	for _, tool := range sortedKeys(tools) {
+		if stored.ExecutionFrequency[tool] != counted.ExecutionFrequency[tool] {
+			report.add(IssueStatistics, true, "execution_frequency for %s is %d, the executions count %d",
+				tool, stored.ExecutionFrequency[tool], counted.ExecutionFrequency[tool])
+		}
+	}
+	storedTools := make(map[string]bool, len(stored.ToolsUsed))
+	for _, tool := range stored.ToolsUsed {
+		storedTools[tool] = true
+	}
+	if len(storedTools) != len(counted.ToolsUsed) || len(stored.ToolsUsed) != len(storedTools) {
+		report.add(IssueStatistics, true, "tools_used lists %d tools, the executions use %d", len(stored.ToolsUsed), len(counted.ToolsUsed))
+	} else {
+		for _, tool := range counted.ToolsUsed {
+			if !storedTools[tool] {
+				report.add(IssueStatistics, true, "tools_used is missing %s", tool)
+			}
+		}
+	}
+	if stored.MostActiveDay != counted.MostActiveDay {
+		report.add(IssueStatistics, true, "most_active_day is %q, the executions make it %q", stored.MostActiveDay, counted.MostActiveDay)
+	}
+
+	days := make(map[string]bool)
+	for day := range stored.Days {
+		days[day] = true
+	}
+	for day := range counted.Days {
+		days[day] = true
+	}
+	differ := 0
+	for day := range days {
+		if !sameDay(stored.Days[day], counted.Days[day]) {
+			differ++
+		}
+	}
+	if differ > 0 {
+		report.add(IssueStatistics, true, "daily counts differ from the executions on %d days", differ)
+	}
+	if !sameHistogram(stored.Hours, counted.Hours) {
+		report.add(IssueStatistics, true, "hour of day counts differ from the executions")
+	}
+	if !sameHistogram(stored.Weekdays, counted.Weekdays) {
+		report.add(IssueStatistics, true, "weekday counts differ from the executions")
+	}
+}
+
+// sameHistogram reports whether two histograms hold the same counts, a
+// missing one counting nothing
+func sameHistogram(a, b []int) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDay reports whether two day aggregates hold the same counts
+func sameDay(a, b core.DayAggregate) bool {
+	return a.Executions == b.Executions && sameCounts(a.Tools, b.Tools) && sameCounts(a.Packages, b.Packages)
+}
+
+func sameCounts(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, count := range a {
+		if b[key] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPackages reports packages the executions used more often or more
+// recently than their usage says. Usage may exceed what the executions
+// show, since retention removes executions without lowering it.
+func checkPackages(packages map[string]map[string]core.PackageInfo, executions []core.ExecutionRecord, report *CheckReport) {
+	uses := countPackageUses(executions)
+	keys := make(map[string]bool, len(uses))
+	for key := range uses {
+		keys[key] = true
+	}
+	for _, key := range sortedKeys(keys) {
+		use := uses[key]
+		tool, name, _ := strings.Cut(key, "/")
+		pkg, exists := packages[tool][name]
+		switch {
+		case !exists:
+			report.add(IssuePackageUsage, true, "%s is used by %d executions but not tracked", key, use.count)
+		case pkg.UsageCount < use.count:
+			report.add(IssuePackageUsage, true, "%s has usage_count %d, the executions count %d", key, pkg.UsageCount, use.count)
+		case pkg.LastUsed.Before(use.lastUsed):
+			report.add(IssuePackageUsage, true, "%s was last used %s, not %s as recorded", key,
+				use.lastUsed.Format(time.RFC3339), pkg.LastUsed.Format(time.RFC3339))
+		}
+	}
+
+	for tool, byName := range packages {
+		for name, pkg := range byName {
+			if pkg.Tool != tool || pkg.Name != name {
+				report.add(IssuePackageUsage, true, "package stored as %s/%s is named %s/%s", tool, name, pkg.Tool, pkg.Name)
+			}
+		}
+	}
+}
+
+// countPackageUses counts the executions affecting each package, keyed
+// tool/name
+func countPackageUses(executions []core.ExecutionRecord) map[string]packageUse {
+	uses := make(map[string]packageUse)
+	for _, exec := range executions {
+		for _, name := range exec.PackagesAffected {
+			key := exec.Tool + "/" + name
+			use := uses[key]
+			use.count++
+			if use.firstUsed.IsZero() || exec.Timestamp.Before(use.firstUsed) {
+				use.firstUsed = exec.Timestamp
+			}
+			if exec.Timestamp.After(use.lastUsed) {
+				use.lastUsed = exec.Timestamp
+			}
+			uses[key] = use
+		}
+	}
+	return uses
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Repair fixes what Check reports as repairable in the storage file of
+// config: it gives executions without an ID one, keeps the first of
+// executions sharing an ID, moves executions to the shard of their month,
+// recounts the statistics, and raises package usage to what the
+// executions show. A storage file that is not valid JSON cannot be
+// repaired; restore it from a backup instead.
+func Repair(config *core.Config) error {
+	opened, err := NewJSONStorage(config)
+	if err != nil {
+		return err
+	}
+	j := opened.(*JSONStorage)
+	repairErr := j.repair()
+	if err := j.Close(); repairErr == nil && err != nil {
+		return err
+	}
+	return repairErr
+}
+
+func (j *JSONStorage) repair() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.withFileLock(func() error {
+		if err := j.reload(); err != nil {
+			return err
+		}
+		for _, shard := range j.data.Metadata.Shards {
+			if err := j.loadShard(shard.Month); err != nil {
+				return err
+			}
+			// Rewrite every shard, so misplaced executions move to their
+			// own month.
+			j.dirty[shard.Month] = true
+		}
+
+		seen := make(map[string]bool, len(j.data.Executions))
+		kept := make([]core.ExecutionRecord, 0, len(j.data.Executions))
+		for _, exec := range j.data.Executions {
+			if exec.ID == "" {
+				exec.ID = core.NewID()
+			}
+			if seen[exec.ID] {
+				continue
+			}
+			seen[exec.ID] = true
+			j.dirty[shardMonth(exec.Timestamp)] = true
+			kept = append(kept, exec)
+		}
+		j.data.Executions = kept
+		j.index = buildExecutionIndex(kept)
+		if err := j.rebuildStatistics(); err != nil {
+			return err
+		}
+		j.repairPackages()
+		return j.save()
+	})
+}
+
+// repairPackages tracks the packages the executions in memory use and
+// raises usage the executions show to be too low
+func (j *JSONStorage) repairPackages() {
+	if j.data.Packages == nil {
+		j.data.Packages = make(map[string]map[string]core.PackageInfo)
+	}
+	for tool, byName := range j.data.Packages {
+		for name, pkg := range byName {
+			pkg.Tool, pkg.Name = tool, name
+			byName[name] = pkg
+		}
+	}
+	for key, use := range countPackageUses(j.data.Executions) {
+		tool, name, _ := strings.Cut(key, "/")
+		if j.data.Packages[tool] == nil {
+			j.data.Packages[tool] = make(map[string]core.PackageInfo)
+		}
+		pkg, exists := j.data.Packages[tool][name]
+		if !exists {
+			pkg = core.PackageInfo{Name: name, Tool: tool, InstallDate: use.firstUsed}
+		}
+		if pkg.UsageCount < use.count {
+			pkg.UsageCount = use.count
+		}
+		if pkg.LastUsed.Before(use.lastUsed) {
+			pkg.LastUsed = use.lastUsed
+		}
+		j.data.Packages[tool][name] = pkg
+	}
+}
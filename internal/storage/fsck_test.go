@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestCheckFindsAndRepairRecomputesDerivedData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	config := &core.Config{Storage: core.StorageConfig{JSONFile: path}}
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	at := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	addExecution(t, store, &core.ExecutionRecord{ID: "a", Tool: "npm", Command: "npm install tsx", Timestamp: at, PackagesAffected: []string{"tsx"}})
+	addExecution(t, store, &core.ExecutionRecord{ID: "b", Tool: "go", Command: "go test", Timestamp: at.Add(time.Hour)})
+	closeStorage(t, store)
+
+	report, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 0 || report.Executions != 2 || report.Packages != 1 {
+		t.Fatalf("Expected a clean report, got %+v", report)
+	}
+
+	// Damage the files: a duplicated ID, an execution filed under the
+	// wrong month, statistics that miss both, and a package used more than
+	// its count says.
+	shard := shardFile{Month: "2026-03", Executions: []core.ExecutionRecord{
+		{ID: "a", Tool: "npm", Command: "npm install tsx", Timestamp: at, PackagesAffected: []string{"tsx"}},
+		{ID: "b", Tool: "go", Command: "go test", Timestamp: at.Add(time.Hour)},
+		{ID: "a", Tool: "npm", Command: "npm install tsx", Timestamp: at, PackagesAffected: []string{"tsx"}},
+		{ID: "c", Tool: "npm", Command: "npm install vite", Timestamp: at.AddDate(0, 1, 0), PackagesAffected: []string{"vite"}},
+	}}
+	raw, _ := json.Marshal(shard)
+	if err := os.WriteFile(shardPath(path, "2026-03"), raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write shard: %v", err)
+	}
+	raw, _ = os.ReadFile(path)
+	var manifest core.StorageData
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("Invalid storage file: %v", err)
+	}
+	manifest.Metadata.Shards[0].Executions = len(shard.Executions)
+	pkg := manifest.Packages["npm"]["tsx"]
+	pkg.UsageCount = 0
+	manifest.Packages["npm"]["tsx"] = pkg
+	raw, _ = json.Marshal(manifest)
+	if err := os.WriteFile(path, raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write storage file: %v", err)
+	}
+
+	report, err = Check(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	for _, kind := range []string{IssueShard, IssueDuplicateID, IssueStatistics, IssuePackageUsage} {
+		if kinds[kind] == 0 {
+			t.Errorf("Expected a %s issue, got %+v", kind, report.Issues)
+		}
+	}
+	if !report.Repairable() {
+		t.Fatalf("Expected every issue repairable, got %+v", report.Issues)
+	}
+
+	if err := Repair(config); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	report, err = Check(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 0 || report.Executions != 3 || report.Packages != 2 {
+		t.Errorf("Expected the repair to leave a clean report, got %+v", report)
+	}
+	if _, err := os.Stat(shardPath(path, "2026-04")); err != nil {
+		t.Errorf("Expected the April execution moved to its own shard: %v", err)
+	}
+}
+
+func TestCheckReportsInvalidJSONAsUnrepairable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	if err := os.WriteFile(path, []byte(`{"version": "1.2.0", "executions": [`), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write storage file: %v", err)
+	}
+
+	report, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IssueInvalidJSON || report.Repairable() {
+		t.Errorf("Expected one unrepairable invalid_json issue, got %+v", report.Issues)
+	}
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// executionIndex lists the positions of the stored executions oldest
+// first, overall and per tool and affected package. A query reads only the
+// list that can hold its matches, narrowed to its time range, and walks it
+// newest first without sorting.
+type executionIndex struct {
+	all       []int
+	byTool    map[string][]int
+	byPackage map[string][]int
+}
+
+func buildExecutionIndex(executions []core.ExecutionRecord) *executionIndex {
+	index := &executionIndex{
+		all:       make([]int, len(executions)),
+		byTool:    make(map[string][]int),
+		byPackage: make(map[string][]int),
+	}
+	for i := range executions {
+		index.all[i] = i
+	}
+	sort.SliceStable(index.all, func(a, b int) bool {
+		return executions[index.all[a]].Timestamp.Before(executions[index.all[b]].Timestamp)
+	})
+	for _, pos := range index.all {
+		exec := &executions[pos]
+		index.byTool[exec.Tool] = append(index.byTool[exec.Tool], pos)
+		for _, pkg := range distinctPackages(exec) {
+			index.byPackage[pkg] = append(index.byPackage[pkg], pos)
+		}
+	}
+	return index
+}
+
+// add indexes the execution stored at pos
+func (x *executionIndex) add(executions []core.ExecutionRecord, pos int) {
+	exec := &executions[pos]
+	x.all = insertByTime(x.all, executions, pos)
+	x.byTool[exec.Tool] = insertByTime(x.byTool[exec.Tool], executions, pos)
+	for _, pkg := range distinctPackages(exec) {
+		x.byPackage[pkg] = insertByTime(x.byPackage[pkg], executions, pos)
+	}
+}
+
+// candidates returns the shortest list holding every execution that can
+// match opts, cut to its time range
+func (x *executionIndex) candidates(executions []core.ExecutionRecord, opts QueryOptions) []int {
+	list := x.all
+	if opts.Tool != "" {
+		list = x.byTool[opts.Tool]
+	}
+	if opts.Package != "" {
+		if byPackage := x.byPackage[opts.Package]; len(byPackage) < len(list) {
+			list = byPackage
+		}
+	}
+
+	low, high := 0, len(list)
+	if opts.Since != nil {
+		low = sort.Search(len(list), func(k int) bool {
+			return !executions[list[k]].Timestamp.Before(*opts.Since)
+		})
+	}
+	if opts.Until != nil {
+		high = sort.Search(len(list), func(k int) bool {
+			return executions[list[k]].Timestamp.After(*opts.Until)
+		})
+	}
+	if high < low {
+		return nil
+	}
+	return list[low:high]
+}
+
+// insertByTime inserts pos after the positions of executions no newer than
+// it, keeping list oldest first
+func insertByTime(list []int, executions []core.ExecutionRecord, pos int) []int {
+	at := executions[pos].Timestamp
+	i := sort.Search(len(list), func(k int) bool {
+		return executions[list[k]].Timestamp.After(at)
+	})
+	list = append(list, 0)
+	copy(list[i+1:], list[i:])
+	list[i] = pos
+	return list
+}
+
+// newestMatching returns the executions matching opts, newest first and at
+// most opts.Limit of them. A limited query keeps the newest matches so far
+// in a bounded heap rather than sorting every match. Executions with the
+// same timestamp come later ones first, as the index walks them.
+func newestMatching(executions []core.ExecutionRecord, opts QueryOptions) []*core.ExecutionRecord {
+	h := &newestHeap{executions: executions}
+	for pos := range executions {
+		if !matchesQuery(&executions[pos], opts) {
+			continue
+		}
+		if opts.Limit <= 0 || h.Len() < opts.Limit {
+			heap.Push(h, pos)
+		} else if h.newer(pos, h.positions[0]) {
+			h.positions[0] = pos
+			heap.Fix(h, 0)
+		}
+	}
+
+	sort.Slice(h.positions, func(a, b int) bool {
+		return h.newer(h.positions[a], h.positions[b])
+	})
+	matches := make([]*core.ExecutionRecord, len(h.positions))
+	for i, pos := range h.positions {
+		matches[i] = &executions[pos]
+	}
+	return matches
+}
+
+// mergeNewestFirst merges two lists of executions sorted newest first,
+// taking a's first on equal timestamps, and keeps at most limit when limit
+// is positive
+func mergeNewestFirst(a, b []*core.ExecutionRecord, limit int) []*core.ExecutionRecord {
+	size := len(a) + len(b)
+	if limit > 0 && size > limit {
+		size = limit
+	}
+	merged := make([]*core.ExecutionRecord, 0, size)
+	for len(merged) < size {
+		if len(b) == 0 || len(a) > 0 && !b[0].Timestamp.After(a[0].Timestamp) {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	return merged
+}
+
+// newestHeap is a min-heap of positions in executions, the oldest on top
+type newestHeap struct {
+	executions []core.ExecutionRecord
+	positions  []int
+}
+
+// newer reports whether the execution at a sorts before the one at b
+func (h *newestHeap) newer(a, b int) bool {
+	at, bt := h.executions[a].Timestamp, h.executions[b].Timestamp
+	if at.Equal(bt) {
+		return a > b
+	}
+	return at.After(bt)
+}
+
+func (h *newestHeap) Len() int { return len(h.positions) }
+
+func (h *newestHeap) Less(a, b int) bool { return h.newer(h.positions[b], h.positions[a]) }
+
+func (h *newestHeap) Swap(a, b int) { h.positions[a], h.positions[b] = h.positions[b], h.positions[a] }
+
+func (h *newestHeap) Push(x interface{}) { h.positions = append(h.positions, x.(int)) }
+
+func (h *newestHeap) Pop() interface{} {
+	last := h.positions[len(h.positions)-1]
+	h.positions = h.positions[:len(h.positions)-1]
+	return last
+}
+
+func distinctPackages(exec *core.ExecutionRecord) []string {
+	if len(exec.PackagesAffected) < 2 {
+		return exec.PackagesAffected
+	}
+	seen := make(map[string]bool, len(exec.PackagesAffected))
+	packages := make([]string, 0, len(exec.PackagesAffected))
+	for _, pkg := range exec.PackagesAffected {
+		if !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
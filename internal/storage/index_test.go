@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestIndexedQueriesMatchFullScan(t *testing.T) {
+	store, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: filepath.Join(t.TempDir(), "executions.json")}})
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, store)
+
+	// Executions arrive out of order, as imports and late reports do.
+	random := rand.New(rand.NewSource(1))
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tools := []string{"npm", "go", "homebrew"}
+	packages := []string{"tsx", "zod", "jq"}
+	var records []*core.ExecutionRecord
+	for _, minute := range random.Perm(200) {
+		records = append(records, &core.ExecutionRecord{
+			Tool:             tools[random.Intn(len(tools))],
+			Command:          "run",
+			Timestamp:        base.Add(time.Duration(minute) * time.Minute),
+			PackagesAffected: []string{packages[random.Intn(len(packages))]},
+			ExitCode:         random.Intn(2),
+		})
+	}
+	if _, err := store.AddExecutions(records[:100]); err != nil {
+		t.Fatalf("AddExecutions failed: %v", err)
+	}
+	for _, record := range records[100:] {
+		addExecution(t, store, record)
+	}
+
+	all, err := store.GetExecutions(QueryOptions{})
+	if err != nil || len(all) != len(records) {
+		t.Fatalf("Expected %d executions, got %d (%v)", len(records), len(all), err)
+	}
+	since, until := base.Add(50*time.Minute), base.Add(120*time.Minute)
+	for _, opts := range []QueryOptions{
+		{},
+		{Tool: "npm"},
+		{Package: "zod"},
+		{Tool: "go", Package: "jq"},
+		{Since: &since},
+		{Until: &until},
+		{Tool: "homebrew", Since: &since, Until: &until, FailedOnly: true},
+		{Package: "tsx", Limit: 5},
+		{Since: &until, Until: &since},
+	} {
+		var want []*core.ExecutionRecord
+		for _, exec := range all {
+			if matchesQuery(exec, opts) {
+				want = append(want, exec)
+			}
+		}
+		sort.Slice(want, func(i, k int) bool { return want[i].Timestamp.After(want[k].Timestamp) })
+		if opts.Limit > 0 && len(want) > opts.Limit {
+			want = want[:opts.Limit]
+		}
+
+		got, err := store.GetExecutions(opts)
+		if err != nil {
+			t.Fatalf("GetExecutions failed: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("Expected %d executions for %+v, got %d", len(want), opts, len(got))
+			continue
+		}
+		for i := range got {
+			if got[i].ID != want[i].ID {
+				t.Errorf("Expected %s at %d for %+v, got %s", want[i].ID, i, opts, got[i].ID)
+				break
+			}
+		}
+	}
+
+	// Pruning reorders and drops executions; the index follows.
+	if err := store.Cleanup(base.Add(150 * time.Minute)); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	remaining, _ := store.GetExecutions(QueryOptions{Tool: "npm"})
+	for i, exec := range remaining {
+		if exec.Tool != "npm" || !exec.Timestamp.After(base.Add(150*time.Minute)) {
+			t.Fatalf("Unexpected execution after cleanup: %+v", exec)
+		}
+		if i > 0 && exec.Timestamp.After(remaining[i-1].Timestamp) {
+			t.Fatalf("Expected newest first after cleanup")
+		}
+	}
+}
+
+func TestNewestMatchingKeepsNewest(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	executions := make([]core.ExecutionRecord, 500)
+	for i := range executions {
+		// Few distinct minutes, so many executions share a timestamp.
+		executions[i] = core.ExecutionRecord{
+			ID:        core.NewID(),
+			Tool:      []string{"npm", "go"}[random.Intn(2)],
+			Timestamp: base.Add(time.Duration(random.Intn(50)) * time.Minute),
+		}
+	}
+
+	for _, opts := range []QueryOptions{{}, {Limit: 20}, {Tool: "go", Limit: 7}, {Limit: 1000}} {
+		var want []*core.ExecutionRecord
+		for pos := len(executions) - 1; pos >= 0; pos-- {
+			if matchesQuery(&executions[pos], opts) {
+				want = append(want, &executions[pos])
+			}
+		}
+		sort.SliceStable(want, func(i, k int) bool { return want[i].Timestamp.After(want[k].Timestamp) })
+		if opts.Limit > 0 && len(want) > opts.Limit {
+			want = want[:opts.Limit]
+		}
+
+		got := newestMatching(executions, opts)
+		if len(got) != len(want) {
+			t.Fatalf("Expected %d matches for %+v, got %d", len(want), opts, len(got))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("Expected %s at %d for %+v, got %s", want[i].ID, i, opts, got[i].ID)
+			}
+		}
+
+		merged := mergeNewestFirst(got, want, opts.Limit)
+		for i := 1; i < len(merged); i++ {
+			if merged[i].Timestamp.After(merged[i-1].Timestamp) {
+				t.Fatalf("Expected the merge newest first for %+v", opts)
+			}
+		}
+	}
+}
+
+// benchmarkStore returns a store holding count executions spread over the
+// last 90 days across a few tools and packages
+func benchmarkStore(b *testing.B, count, memoryDays int) Storage {
+	b.Helper()
+	store, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{
+		JSONFile:   filepath.Join(b.TempDir(), "executions.json"),
+		MemoryDays: memoryDays,
+	}})
+	if err != nil {
+		b.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	random := rand.New(rand.NewSource(1))
+	now := time.Now()
+	records := make([]*core.ExecutionRecord, count)
+	for i := range records {
+		records[i] = &core.ExecutionRecord{
+			Tool:             []string{"npm", "go", "homebrew", "pip"}[random.Intn(4)],
+			Command:          "install",
+			Timestamp:        now.Add(-time.Duration(random.Int63n(int64(90 * 24 * time.Hour)))),
+			PackagesAffected: []string{fmt.Sprintf("pkg-%d", random.Intn(200))},
+		}
+	}
+	if _, err := store.AddExecutions(records); err != nil {
+		b.Fatalf("AddExecutions failed: %v", err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func BenchmarkGetExecutionsLimit(b *testing.B) {
+	store := benchmarkStore(b, 50000, 0)
+	since := time.Now().AddDate(0, 0, -30)
+	for _, bench := range []struct {
+		name string
+		opts QueryOptions
+	}{
+		{"all", QueryOptions{Limit: 20}},
+		{"tool", QueryOptions{Tool: "go", Limit: 20}},
+		{"package", QueryOptions{Package: "pkg-7", Limit: 20}},
+		{"since", QueryOptions{Since: &since, Limit: 20}},
+		{"failed", QueryOptions{FailedOnly: true, Limit: 20}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := store.GetExecutions(bench.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNewestMatching(b *testing.B) {
+	random := rand.New(rand.NewSource(1))
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	executions := make([]core.ExecutionRecord, 50000)
+	for i := range executions {
+		executions[i] = core.ExecutionRecord{Tool: "npm", Timestamp: base.Add(time.Duration(random.Intn(1<<20)) * time.Second)}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newestMatching(executions, QueryOptions{Limit: 20})
+	}
+}
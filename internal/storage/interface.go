@@ -1,17 +1,25 @@
 package storage
 
 import (
+	"errors"
+	"regexp"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
 )
 
+// ErrDuplicateExecution is returned by AddExecution for a record whose ID is
+// already stored
+var ErrDuplicateExecution = errors.New("execution already recorded")
+
 type Storage interface {
 	Initialize(config *core.Config) error
 	Close() error
 
 	AddExecution(record *core.ExecutionRecord) error
+	AddExecutions(records []*core.ExecutionRecord) ([]bool, error)
 	GetExecutions(opts QueryOptions) ([]*core.ExecutionRecord, error)
+	StreamExecutions(opts QueryOptions, fn func(*core.ExecutionRecord) error) error
 	GetExecutionByID(id string) (*core.ExecutionRecord, error)
 
 	UpdatePackage(pkg *core.PackageInfo) error
@@ -22,8 +30,11 @@ type Storage interface {
 
 	GetStatistics() (*core.StorageStatistics, error)
 	UpdateStatistics() error
+	Aggregate(opts AggregateOptions) (*AggregateResult, error)
 
-	Backup() error
+	Backup() (string, error)
+	ImportBackup(data []byte) (string, error)
+	ImportRecords(executions []*core.ExecutionRecord, packages []*core.PackageInfo) (*ImportResult, error)
 	Restore(path string) error
 	Cleanup(before time.Time) error
 }
@@ -37,6 +48,41 @@ type QueryOptions struct {
 	Offset    int
 	SortBy    string
 	SortOrder string
+	// ExitCode keeps only executions that exited with this code.
+	ExitCode *int
+	// FailedOnly keeps only executions with a non-zero exit code.
+	FailedOnly bool
+	// WorkingDir keeps only executions run in this directory or below it.
+	WorkingDir string
+	// Project keeps only executions whose project name matches exactly.
+	Project string
+	// SessionID keeps only executions from this shell session.
+	SessionID string
+	// Host keeps only executions recorded on this machine, given as a
+	// hostname or machine ID.
+	Host string
+	// CommandPattern keeps only executions whose command text it matches.
+	CommandPattern *regexp.Regexp
+}
+
+// ImportResult counts the records ImportRecords inserted and skipped
+type ImportResult struct {
+	ExecutionsInserted int
+	ExecutionsSkipped  int
+	PackagesInserted   int
+	PackagesSkipped    int
 }
 
 type StorageFactory func(config *core.Config) (Storage, error)
+
+// KnownVersions returns a lookup of the versions store has recorded for
+// tool's packages, "" for packages it does not track
+func KnownVersions(store Storage, tool string) func(name string) string {
+	return func(name string) string {
+		pkg, err := store.GetPackage(tool, name)
+		if err != nil || pkg == nil {
+			return ""
+		}
+		return pkg.Version
+	}
+}
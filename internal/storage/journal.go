@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/safefs"
+)
+
+// journalSuffix names the file beside the storage file that holds the
+// executions added since it was last saved, one journalEntry per line
+const journalSuffix = ".journal"
+
+// maxJournalLineBytes bounds one journaled record
+const maxJournalLineBytes = 4 << 20
+
+// journalEntry is one journaled execution. Seq increases with every entry,
+// so entries the storage file already holds can be told apart from newer
+// ones even when a save stopped before removing the journal.
+type journalEntry struct {
+	Seq    int64                `json:"seq"`
+	Record core.ExecutionRecord `json:"record"`
+}
+
+// fileStamp identifies a version of a file by its size and modification
+// time, or its absence
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime int64
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime().UnixNano()}
+}
+
+// fileStamps are the stamps of the storage file and its journal as last
+// read or written by this process
+type fileStamps struct {
+	data    fileStamp
+	journal fileStamp
+}
+
+func (j *JSONStorage) journalPath() string {
+	return j.filepath + journalSuffix
+}
+
+func (j *JSONStorage) currentStamps() fileStamps {
+	return fileStamps{data: stampFile(j.filepath), journal: stampFile(j.journalPath())}
+}
+
+// buffered reports whether journaled additions are saved later rather than
+// at once
+func (j *JSONStorage) buffered() bool {
+	return j.config.Storage.FlushInterval > 0
+}
+
+// appendJournal writes records to the journal and syncs it, so they survive
+// a crash before or during the next save
+func (j *JSONStorage) appendJournal(records []*core.ExecutionRecord) error {
+	// Start on a new line in case the last write was cut short.
+	buf := bytes.NewBufferString("\n")
+	encoder := json.NewEncoder(buf)
+	seq := j.journalSeq
+	for _, record := range records {
+		seq++
+		if err := encoder.Encode(journalEntry{Seq: seq, Record: *record}); err != nil {
+			return fmt.Errorf("failed to encode journal record: %w", err)
+		}
+	}
+
+	file, err := safefs.OpenFile(j.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, core.PrivateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open storage journal: %w", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write storage journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync storage journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close storage journal: %w", err)
+	}
+
+	j.journalSeq = seq
+	j.pending += len(records)
+	j.stamps.journal = stampFile(j.journalPath())
+	return nil
+}
+
+// replayJournal adds the journaled executions newer than the loaded file,
+// skipping a line cut short by a crash mid-write. An execution already in
+// its shard, from a save that stopped before the storage file was written,
+// still counts toward its packages.
+func (j *JSONStorage) replayJournal() error {
+	j.journalSeq = j.data.Metadata.JournalSeq
+	data, err := safefs.ReadFile(j.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read storage journal: %w", err)
+	}
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJournalLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		if entry.Record.ID == "" || entry.Seq <= j.data.Metadata.JournalSeq {
+			continue
+		}
+		if err := j.loadShard(shardMonth(entry.Record.Timestamp)); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	seen := make(map[string]bool, len(j.data.Executions))
+	for _, exec := range j.data.Executions {
+		seen[exec.ID] = true
+	}
+	recount := false
+	for i := range entries {
+		record := &entries[i].Record
+		if entries[i].Seq > j.journalSeq {
+			j.journalSeq = entries[i].Seq
+		}
+		j.pending++
+		if seen[record.ID] {
+			if err := j.countPackages(record); err != nil {
+				return err
+			}
+			recount = true
+			continue
+		}
+		seen[record.ID] = true
+		if err := j.appendExecution(record); err != nil {
+			return err
+		}
+	}
+	if recount {
+		return j.rebuildStatistics()
+	}
+	return nil
+}
+
+// removeJournal drops the journal once the storage file holds everything
+// in it
+func (j *JSONStorage) removeJournal() error {
+	if err := os.Remove(j.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove storage journal: %w", err)
+	}
+	return nil
+}
+
+// scheduleFlush saves journaled executions once FlushInterval passes,
+// unless a save is already due
+func (j *JSONStorage) scheduleFlush() {
+	if j.pending == 0 || j.flushTimer != nil || !j.buffered() {
+		return
+	}
+	j.flushTimer = time.AfterFunc(j.config.Storage.FlushInterval, func() {
+		// A failed save leaves the executions in the journal for the next one.
+		_ = j.Flush()
+	})
+}
+
+// Flush saves the executions waiting in the journal
+func (j *JSONStorage) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.flush()
+}
+
+func (j *JSONStorage) flush() error {
+	if j.flushTimer != nil {
+		j.flushTimer.Stop()
+		j.flushTimer = nil
+	}
+	if j.pending == 0 {
+		return nil
+	}
+	err := j.withFileLock(func() error {
+		if err := j.reload(); err != nil {
+			return err
+		}
+		if err := j.enforceRetentionPolicies(time.Time{}); err != nil {
+			return err
+		}
+		return j.save()
+	})
+	if err != nil {
+		j.scheduleFlush()
+	}
+	return err
+}
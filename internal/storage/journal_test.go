@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func bufferedConfig(t *testing.T, interval time.Duration, count int) *core.Config {
+	t.Helper()
+	return &core.Config{Storage: core.StorageConfig{
+		JSONFile:      filepath.Join(t.TempDir(), "executions.json"),
+		FlushInterval: interval,
+		FlushCount:    count,
+	}}
+}
+
+// storedExecutionCount reads the storage file itself, without the journal,
+// and counts the executions in the shards it lists
+func storedExecutionCount(t *testing.T, path string) int {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read storage file: %v", err)
+	}
+	var data core.StorageData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("Invalid storage file: %v", err)
+	}
+	count := len(data.Executions)
+	for _, shard := range data.Metadata.Shards {
+		count += shard.Executions
+	}
+	return count
+}
+
+func TestBufferedWritesJournalUntilFlush(t *testing.T) {
+	config := bufferedConfig(t, time.Hour, 0)
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm install", Timestamp: now.Add(time.Duration(i) * time.Minute), PackagesAffected: []string{"tsx"}})
+	}
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 0 {
+		t.Errorf("Expected no executions saved before the flush, got %d", got)
+	}
+	if _, err := os.Stat(config.Storage.JSONFile + journalSuffix); err != nil {
+		t.Fatalf("Expected a journal, got %v", err)
+	}
+
+	// Another process sees the journaled executions and their package usage.
+	other, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	executions, _ := other.GetExecutions(QueryOptions{})
+	pkg, _ := other.GetPackage("npm", "tsx")
+	if len(executions) != 3 || pkg == nil || pkg.UsageCount != 3 {
+		t.Errorf("Expected the journal replayed, got %d executions and package %+v", len(executions), pkg)
+	}
+	stats, _ := other.GetStatistics()
+	if stats.TotalExecutions != 3 {
+		t.Errorf("Expected replayed executions counted once, got %d", stats.TotalExecutions)
+	}
+
+	closeStorage(t, store)
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 3 {
+		t.Errorf("Expected Close to save the journaled executions, got %d", got)
+	}
+	if _, err := os.Stat(config.Storage.JSONFile + journalSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal removed after saving, got %v", err)
+	}
+	closeStorage(t, other)
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 3 {
+		t.Errorf("Expected executions saved once, got %d", got)
+	}
+}
+
+func TestBufferedWritesFlushOnCountAndInterval(t *testing.T) {
+	config := bufferedConfig(t, time.Hour, 2)
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, store)
+
+	now := time.Now()
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: now})
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: now})
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 2 {
+		t.Errorf("Expected a save once flush_count executions waited, got %d", got)
+	}
+
+	config = bufferedConfig(t, 20*time.Millisecond, 0)
+	timed, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, timed)
+	addExecution(t, timed, &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: now})
+	deadline := time.Now().Add(5 * time.Second)
+	for storedExecutionCount(t, config.Storage.JSONFile) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a save once flush_interval passed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJournalReplaySurvivesTornWrite(t *testing.T) {
+	config := bufferedConfig(t, time.Hour, 0)
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: time.Now()})
+
+	// Simulate a crash: the store is never closed and its last write was
+	// cut short.
+	journal, err := os.OpenFile(config.Storage.JSONFile+journalSuffix, os.O_WRONLY|os.O_APPEND, core.PrivateFileMode)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	if _, err := journal.WriteString(`{"id":"torn","tool":"np`); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	_ = journal.Close()
+
+	recovered, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed after crash: %v", err)
+	}
+	addExecution(t, recovered, &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: time.Now()})
+	closeStorage(t, recovered)
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 2 {
+		t.Errorf("Expected the complete journaled executions recovered, got %d", got)
+	}
+}
+
+func TestJournalReplayAfterInterruptedSave(t *testing.T) {
+	config := bufferedConfig(t, 0, 0)
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	saved := &core.ExecutionRecord{Tool: "npm", Command: "npm install zod", Timestamp: at, PackagesAffected: []string{"zod"}}
+	addExecution(t, store, saved)
+	closeStorage(t, store)
+	if _, err := os.Stat(config.Storage.JSONFile + journalSuffix); !os.IsNotExist(err) {
+		t.Fatalf("Expected the journal removed once saved, got %v", err)
+	}
+
+	raw, _ := os.ReadFile(config.Storage.JSONFile)
+	var manifest core.StorageData
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("Invalid storage file: %v", err)
+	}
+	seq := manifest.Metadata.JournalSeq
+	if seq != 1 {
+		t.Fatalf("Expected the storage file to hold journal entry 1, got %d", seq)
+	}
+
+	// Simulate a crash in the middle of the next save: the shard holds the
+	// new execution, the storage file does not, and the journal still
+	// holds an entry that was already saved.
+	interrupted := core.ExecutionRecord{ID: "interrupted", Tool: "npm", Command: "npm install zod", Timestamp: at.Add(time.Hour), PackagesAffected: []string{"zod"}}
+	shard, _ := json.Marshal(shardFile{Month: "2026-03", Executions: []core.ExecutionRecord{*saved, interrupted}})
+	if err := os.WriteFile(shardPath(config.Storage.JSONFile, "2026-03"), shard, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write shard: %v", err)
+	}
+	var journal []byte
+	for _, entry := range []journalEntry{{Seq: seq, Record: *saved}, {Seq: seq + 1, Record: interrupted}} {
+		line, _ := json.Marshal(entry)
+		journal = append(append(journal, line...), '\n')
+	}
+	if err := os.WriteFile(config.Storage.JSONFile+journalSuffix, journal, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	recovered, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed after crash: %v", err)
+	}
+	defer closeStorage(t, recovered)
+	executions, _ := recovered.GetExecutions(QueryOptions{})
+	pkg, _ := recovered.GetPackage("npm", "zod")
+	stats, _ := recovered.GetStatistics()
+	if len(executions) != 2 || stats.TotalExecutions != 2 {
+		t.Errorf("Expected each execution once, got %d executions and %d counted", len(executions), stats.TotalExecutions)
+	}
+	if pkg == nil || pkg.UsageCount != 2 {
+		t.Errorf("Expected zod used once per execution, got %+v", pkg)
+	}
+}
@@ -1,26 +1,50 @@
 package storage
 
 import (
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/internal/redact"
 	"github.com/yowainwright/diu/internal/safefs"
 )
 
 type JSONStorage struct {
 	config   *core.Config
+	redactor *redact.Redactor
 	filepath string
 	data     *core.StorageData
-	mu       sync.RWMutex
+	// index is rebuilt whenever data.Executions is replaced or reordered
+	// and kept up to date as executions are appended.
+	index *executionIndex
+	mu    sync.RWMutex
+
+	// machineID is looked up on the first write; see core.MachineID.
+	machineID     string
+	machineIDOnce sync.Once
+
+	// pending counts the journaled executions the storage file does not
+	// hold yet; flushTimer saves them once storage.flush_interval passes.
+	// journalSeq is the sequence number of the last journaled execution.
+	pending    int
+	flushTimer *time.Timer
+	journalSeq int64
+	// stamps identify the files as last read or written, so reload can
+	// skip reading them when no other process changed them.
+	stamps fileStamps
+
+	// loaded lists the months whose executions are in data.Executions;
+	// the shards of the others are read when a query reaches them. dirty
+	// lists the months whose shards the next save rewrites.
+	loaded map[string]bool
+	dirty  map[string]bool
 }
 
 const maxBackupPathAttempts = 1000
@@ -31,13 +55,35 @@ func NewJSONStorage(config *core.Config) (Storage, error) {
 		return nil, fmt.Errorf("invalid storage path: %w", err)
 	}
 
+	redactor, err := redact.New(config.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction config: %w", err)
+	}
+
 	js := &JSONStorage{
 		config:   config,
+		redactor: redactor,
 		filepath: storagePath,
 	}
 	return js, js.Initialize(config)
 }
 
+// SetConfig replaces the config used for retention, backup limits, and
+// redaction, e.g. after the daemon reloads it. The storage file itself is
+// not moved. Invalid redaction patterns keep the previous redactor, since
+// the daemon validates the config before applying it.
+func (j *JSONStorage) SetConfig(config *core.Config) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.config = config
+	if redactor, err := redact.New(config.Redaction); err == nil {
+		j.redactor = redactor
+	}
+	if !j.buffered() {
+		_ = j.flush()
+	}
+}
+
 func (j *JSONStorage) Initialize(config *core.Config) error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
@@ -54,7 +100,7 @@ func (j *JSONStorage) Initialize(config *core.Config) error {
 		hostname, _ := os.Hostname()
 		user, _ := os.UserHomeDir()
 		j.data = &core.StorageData{
-			Version: "1.0.0",
+			Version: core.StorageVersion,
 			Metadata: core.StorageMetadata{
 				Created:     time.Now(),
 				LastUpdated: time.Now(),
@@ -71,14 +117,26 @@ func (j *JSONStorage) Initialize(config *core.Config) error {
 				ExecutionFrequency: make(map[string]int),
 			},
 		}
+		j.index = buildExecutionIndex(nil)
+		j.resetShards()
 		return j.save()
 	}
 
-	return j.load()
+	// Migrating an old file rewrites it, so it is loaded under the lock.
+	return j.withFileLock(j.load)
 }
 
+// Close saves any executions still waiting in the journal
 func (j *JSONStorage) Close() error {
-	return nil
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.flush()
+	if j.flushTimer != nil {
+		j.flushTimer.Stop()
+		j.flushTimer = nil
+	}
+	return err
 }
 
 func (j *JSONStorage) load() error {
@@ -87,66 +145,192 @@ func (j *JSONStorage) load() error {
 		return fmt.Errorf("failed to read storage file: %w", err)
 	}
 
-	var storage core.StorageData
-	if err := json.Unmarshal(data, &storage); err != nil {
-		return fmt.Errorf("failed to unmarshal storage data: %w", err)
+	storage, migrated, err := decodeStorage(data)
+	if err != nil {
+		var unsupported *UnsupportedVersionError
+		if !errors.As(err, &unsupported) {
+			err = &CorruptStorageError{Path: j.filepath, Err: err}
+		}
+		return fmt.Errorf("failed to load storage data: %w", err)
 	}
 
-	j.data = &storage
+	j.data = storage
+	j.resetShards()
+	stale, err := j.loadShards()
+	if err != nil {
+		return err
+	}
+	j.index = buildExecutionIndex(j.data.Executions)
+	// Files written before daily aggregates and the hour and weekday counts
+	// were kept get them on load.
+	missing := j.data.Statistics.Days == nil || j.data.Statistics.Hours == nil
+	if stale || missing && (len(j.data.Executions) > 0 || len(j.data.Metadata.Shards) > 0) {
+		if err := j.rebuildStatistics(); err != nil {
+			return err
+		}
+	}
+	// Shards to write now are not left for the journal's flush.
+	resave := len(j.dirty) > 0
+	j.pending = 0
+	if err := j.replayJournal(); err != nil {
+		return err
+	}
+	if resave && !migrated {
+		return j.save()
+	}
+	if migrated {
+		// Keep the file as it was before the upgrade, so it can be restored
+		// if the migration got anything wrong.
+		if _, err := j.writeBackup(data); err != nil {
+			return fmt.Errorf("failed to back up storage before migrating: %w", err)
+		}
+		return j.save()
+	}
+	j.stamps = j.currentStamps()
+	j.scheduleFlush()
 	return nil
 }
 
+// save writes the changed shards and then the storage file, which holds
+// everything but the executions
 func (j *JSONStorage) save() error {
+	if err := j.saveShards(); err != nil {
+		return err
+	}
 	j.data.Metadata.LastUpdated = time.Now()
+	j.data.Metadata.JournalSeq = j.journalSeq
 
-	data, err := json.MarshalIndent(j.data, "", "  ")
+	manifest := *j.data
+	manifest.Executions = []core.ExecutionRecord{}
+	data, err := json.MarshalIndent(&manifest, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal storage data: %w", err)
 	}
 
 	tempFile := j.filepath + ".tmp"
-	if err := os.WriteFile(tempFile, data, core.PrivateFileMode); err != nil {
+	if err := writeSynced(tempFile, data); err != nil {
 		return fmt.Errorf("failed to write storage file: %w", err)
 	}
 
 	if err := os.Rename(tempFile, j.filepath); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
+	if err := syncDir(filepath.Dir(j.filepath)); err != nil {
+		return fmt.Errorf("failed to sync storage directory: %w", err)
+	}
 
+	// Everything journaled is in the file now.
+	if err := j.removeJournal(); err != nil {
+		return err
+	}
+	j.pending = 0
+	if j.flushTimer != nil {
+		j.flushTimer.Stop()
+		j.flushTimer = nil
+	}
+	j.stamps = j.currentStamps()
 	return nil
 }
 
+// writeSynced writes data to path and syncs it, so renaming the file over
+// another never exposes contents still in flight after a crash
+func writeSynced(path string, data []byte) error {
+	file, err := safefs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, core.PrivateFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// AddExecution stores record, giving it an ID when it has none. It returns
+// ErrDuplicateExecution, and stores nothing, when the ID is already stored
+// or the same execution was stored within storage.dedupe_window of it.
 func (j *JSONStorage) AddExecution(record *core.ExecutionRecord) error {
+	stored, err := j.addExecutions([]*core.ExecutionRecord{record})
+	if err == nil && !stored[0] {
+		return ErrDuplicateExecution
+	}
+	return err
+}
+
+// AddExecutions stores records, skipping any whose ID is already stored or
+// repeats an earlier record in the batch, so a retried submission is not
+// recorded twice. Records with the same content as one stored within
+// storage.dedupe_window, such as a run reported by both a wrapper and a
+// shell hook, are skipped too. The result reports, for each record, whether
+// it was stored.
+func (j *JSONStorage) AddExecutions(records []*core.ExecutionRecord) ([]bool, error) {
+	return j.addExecutions(records)
+}
+
+func (j *JSONStorage) addExecutions(records []*core.ExecutionRecord) ([]bool, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	return j.withFileLock(func() error {
+	added := make([]bool, len(records))
+	err := j.withFileLock(func() error {
 		if err := j.reload(); err != nil {
 			return err
 		}
 
-		if record.ID == "" {
-			record.ID = fmt.Sprintf("exec_%s_%s", time.Now().Format("20060102_150405"), generateID())
-		}
-
-		storedRecord := copyExecutionValue(*record)
-		j.data.Executions = append(j.data.Executions, storedRecord)
-		j.data.Statistics.TotalExecutions++
-
-		if j.data.Statistics.ExecutionFrequency == nil {
-			j.data.Statistics.ExecutionFrequency = make(map[string]int)
+		seen := make(map[string]bool, len(j.data.Executions)+len(records))
+		for _, exec := range j.data.Executions {
+			seen[exec.ID] = true
 		}
-		if _, exists := j.data.Statistics.ExecutionFrequency[storedRecord.Tool]; !exists {
-			j.data.Statistics.ExecutionFrequency[storedRecord.Tool] = 0
-			j.data.Statistics.ToolsUsed = append(j.data.Statistics.ToolsUsed, storedRecord.Tool)
+		recent := newRecentExecutions(j.data.Executions, records, j.config.Storage.DedupeWindow)
+		var stored []*core.ExecutionRecord
+		for i, record := range records {
+			if record.ID != "" {
+				// The record's month may be a cold shard left on disk,
+				// whose IDs seen does not hold until it is read.
+				loaded := len(j.data.Executions)
+				if err := j.loadShard(shardMonth(record.Timestamp)); err != nil {
+					return err
+				}
+				for _, exec := range j.data.Executions[loaded:] {
+					seen[exec.ID] = true
+				}
+				if seen[record.ID] {
+					continue
+				}
+			}
+			j.prepareExecution(record)
+			if recent.duplicate(record) {
+				continue
+			}
+			if err := j.appendExecution(record); err != nil {
+				return err
+			}
+			seen[record.ID] = true
+			recent.add(record)
+			stored = append(stored, record)
+			added[i] = true
 		}
-		j.data.Statistics.ExecutionFrequency[storedRecord.Tool]++
 
-		for _, pkg := range storedRecord.PackagesAffected {
-			if err := j.updatePackageInternal(storedRecord.Tool, pkg, storedRecord.Timestamp); err != nil {
+		// Journal the executions before saving them, so one cut short
+		// by a crash is replayed rather than lost.
+		if len(stored) > 0 {
+			if err := j.appendJournal(stored); err != nil {
 				return err
 			}
 		}
+		if j.buffered() {
+			if len(stored) == 0 {
+				return nil
+			}
+			if flushCount := j.config.Storage.FlushCount; flushCount == 0 || j.pending < flushCount {
+				j.scheduleFlush()
+				return nil
+			}
+		}
 
 		if err := j.enforceRetentionPolicies(time.Time{}); err != nil {
 			return err
@@ -154,55 +338,188 @@ func (j *JSONStorage) AddExecution(record *core.ExecutionRecord) error {
 
 		return j.save()
 	})
+	return added, err
+}
+
+// stampOrigin records this machine on executions that do not name the
+// machine they came from
+func (j *JSONStorage) stampOrigin(record *core.ExecutionRecord) {
+	if record.Host == "" {
+		record.Host, _ = os.Hostname()
+	}
+	if record.MachineID == "" {
+		j.machineIDOnce.Do(func() {
+			dataDir := j.config.Daemon.DataDir
+			if dataDir == "" {
+				dataDir = filepath.Dir(j.filepath)
+			}
+			j.machineID = core.MachineID(dataDir)
+		})
+		record.MachineID = j.machineID
+	}
+}
+
+// prepareExecution gives record an ID, redacts it, and stamps its origin,
+// leaving it as it would be stored
+func (j *JSONStorage) prepareExecution(record *core.ExecutionRecord) {
+	if record.ID == "" {
+		record.ID = core.NewID()
+	}
+	// Redact the caller's record too so events published after storing
+	// never carry the secrets.
+	j.redactor.Record(record)
+	j.stampOrigin(record)
+	record.Output.Limit(core.MaxOutputLength)
+}
+
+func (j *JSONStorage) appendExecution(record *core.ExecutionRecord) error {
+	storedRecord := copyExecutionValue(*record)
+	if err := j.storeExecution(storedRecord); err != nil {
+		return err
+	}
+	j.data.Statistics.Count(&storedRecord)
+	return j.countPackages(&storedRecord)
+}
+
+// countPackages records a use of each package record affected and the
+// versions it changed them to
+func (j *JSONStorage) countPackages(record *core.ExecutionRecord) error {
+	for _, pkg := range record.PackagesAffected {
+		if err := j.updatePackageInternal(record.Tool, pkg, record.Timestamp); err != nil {
+			return err
+		}
+	}
+	for _, change := range record.VersionChanges() {
+		if pkg, exists := j.data.Packages[record.Tool][change.Package]; exists {
+			pkg.Version = change.To
+			j.data.Packages[record.Tool][change.Package] = pkg
+		}
+	}
+	return nil
 }
 
 func (j *JSONStorage) GetExecutions(opts QueryOptions) ([]*core.ExecutionRecord, error) {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
+	matches, err := j.matchExecutions(opts)
+	if err != nil {
+		return nil, err
+	}
 	var results []*core.ExecutionRecord
+	for _, exec := range matches {
+		copy := copyExecutionValue(*exec)
+		results = append(results, &copy)
+	}
+	return results, nil
+}
 
-	for i := range j.data.Executions {
-		exec := &j.data.Executions[i]
+// StreamExecutions calls fn with each execution GetExecutions would return,
+// in the same order, copying one record at a time instead of the whole
+// result. It stops at the first error fn returns.
+func (j *JSONStorage) StreamExecutions(opts QueryOptions, fn func(*core.ExecutionRecord) error) error {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
 
-		if opts.Tool != "" && exec.Tool != opts.Tool {
-			continue
+	matches, err := j.matchExecutions(opts)
+	if err != nil {
+		return err
+	}
+	for _, exec := range matches {
+		copy := copyExecutionValue(*exec)
+		if err := fn(&copy); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if opts.Package != "" {
-			found := false
-			for _, pkg := range exec.PackagesAffected {
-				if pkg == opts.Package {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
+// matchExecutions returns the stored executions matching opts, newest first,
+// reading the shards not in memory only when the query reaches back to them.
+// The records in memory are not copied; callers must hold j.mu.
+func (j *JSONStorage) matchExecutions(opts QueryOptions) ([]*core.ExecutionRecord, error) {
+	var matches []*core.ExecutionRecord
+	candidates := j.index.candidates(j.data.Executions, opts)
+	for k := len(candidates) - 1; k >= 0; k-- {
+		exec := &j.data.Executions[candidates[k]]
+		if !matchesQuery(exec, opts) {
+			continue
 		}
+		matches = append(matches, exec)
+		if opts.Limit > 0 && len(matches) == opts.Limit {
+			break
+		}
+	}
 
-		if opts.Since != nil && exec.Timestamp.Before(*opts.Since) {
-			continue
+	for _, shard := range j.coldShards(opts) {
+		if n := len(matches); opts.Limit > 0 && n == opts.Limit && matches[n-1].Timestamp.After(shard.Through) {
+			break
+		}
+		executions, err := readShard(j.filepath, shard.Month)
+		if err != nil {
+			return nil, err
 		}
+		matches = mergeNewestFirst(matches, newestMatching(executions, opts), opts.Limit)
+	}
+	return matches, nil
+}
 
-		if opts.Until != nil && exec.Timestamp.After(*opts.Until) {
-			continue
+// matchesQuery reports whether exec passes every filter in opts
+func matchesQuery(exec *core.ExecutionRecord, opts QueryOptions) bool {
+	if opts.Tool != "" && exec.Tool != opts.Tool {
+		return false
+	}
+
+	if opts.Package != "" {
+		found := false
+		for _, pkg := range exec.PackagesAffected {
+			if pkg == opts.Package {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
 		}
+	}
 
-		copy := copyExecutionValue(*exec)
-		results = append(results, &copy)
+	if opts.Since != nil && exec.Timestamp.Before(*opts.Since) {
+		return false
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Timestamp.After(results[j].Timestamp)
-	})
+	if opts.Until != nil && exec.Timestamp.After(*opts.Until) {
+		return false
+	}
 
-	if opts.Limit > 0 && len(results) > opts.Limit {
-		results = results[:opts.Limit]
+	if opts.ExitCode != nil && exec.ExitCode != *opts.ExitCode {
+		return false
 	}
 
-	return results, nil
+	if opts.FailedOnly && exec.ExitCode == 0 {
+		return false
+	}
+
+	if opts.WorkingDir != "" && !exec.InDir(opts.WorkingDir) {
+		return false
+	}
+
+	if opts.Project != "" && exec.Project() != opts.Project {
+		return false
+	}
+
+	if opts.SessionID != "" && exec.SessionID != opts.SessionID {
+		return false
+	}
+
+	if opts.Host != "" && !exec.FromHost(opts.Host) {
+		return false
+	}
+
+	if opts.CommandPattern != nil && !opts.CommandPattern.MatchString(exec.Command) {
+		return false
+	}
+
+	return true
 }
 
 func (j *JSONStorage) GetExecutionByID(id string) (*core.ExecutionRecord, error) {
@@ -216,6 +533,20 @@ func (j *JSONStorage) GetExecutionByID(id string) (*core.ExecutionRecord, error)
 		}
 	}
 
+	var found *core.ExecutionRecord
+	err := j.streamShards(func(record *core.ExecutionRecord) error {
+		if record.ID == id {
+			found = record
+			return errStopStream
+		}
+		return nil
+	})
+	if found != nil {
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	return nil, fmt.Errorf("execution not found: %s", id)
 }
 
@@ -260,7 +591,13 @@ func (j *JSONStorage) updatePackageInternal(tool, name string, timestamp time.Ti
 			UsageCount:  1,
 		}
 	} else {
-		pkg.LastUsed = timestamp
+		if timestamp.After(pkg.LastUsed) {
+			pkg.LastUsed = timestamp
+		}
+		// Imported executions can arrive in any order.
+		if timestamp.Before(pkg.InstallDate) {
+			pkg.InstallDate = timestamp
+		}
 		pkg.UsageCount++
 	}
 
@@ -353,52 +690,155 @@ func (j *JSONStorage) GetStatistics() (*core.StorageStatistics, error) {
 	return &stats, nil
 }
 
+// UpdateStatistics recounts the statistics and daily aggregates from the
+// stored executions, repairing counters that drifted from them
 func (j *JSONStorage) UpdateStatistics() error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	dayCount := make(map[string]int)
-	for _, exec := range j.data.Executions {
-		day := exec.Timestamp.Format("2006-01-02")
-		dayCount[day]++
+	return j.withFileLock(func() error {
+		if err := j.reload(); err != nil {
+			return err
+		}
+		if err := j.rebuildStatistics(); err != nil {
+			return err
+		}
+		return j.save()
+	})
+}
+
+// Backup writes a backup of the current data and returns its path.
+func (j *JSONStorage) Backup() (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// A backup holds every execution itself rather than in shards, so it
+	// restores on its own.
+	executions, err := j.allExecutions()
+	if err != nil {
+		return "", err
 	}
+	backup := *j.data
+	backup.Executions = executions
+	backup.Metadata.Shards = nil
 
-	maxCount := 0
-	mostActiveDay := ""
-	for day, count := range dayCount {
-		if count > maxCount {
-			maxCount = count
-			mostActiveDay = day
-		}
+	data, err := json.MarshalIndent(&backup, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal backup data: %w", err)
 	}
+	return j.writeBackup(data)
+}
 
-	j.data.Statistics.MostActiveDay = mostActiveDay
-	return j.save()
+// ImportBackup stores data, such as a backup downloaded from a remote
+// target, as a new local backup file and returns its path so it can be
+// passed to Restore.
+func (j *JSONStorage) ImportBackup(data []byte) (string, error) {
+	if _, _, err := decodeStorage(data); err != nil {
+		return "", fmt.Errorf("backup is not valid storage data: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.writeBackup(data)
 }
 
-func (j *JSONStorage) Backup() error {
+// ImportRecords merges records from another machine or an export.
+// Executions are redacted and stamped like added ones, and those whose ID
+// is already stored are skipped, as are packages that are already tracked. Imported executions only count toward usage of
+// packages that neither the store nor the import already describes, so
+// existing counts are not inflated.
+func (j *JSONStorage) ImportRecords(executions []*core.ExecutionRecord, packages []*core.PackageInfo) (*ImportResult, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	backupPath, err := j.nextBackupPath(time.Now())
+	result := &ImportResult{}
+	err := j.withFileLock(func() error {
+		if err := j.reload(); err != nil {
+			return err
+		}
+		if j.data.Packages == nil {
+			j.data.Packages = make(map[string]map[string]core.PackageInfo)
+		}
+
+		for _, pkg := range packages {
+			if _, exists := j.data.Packages[pkg.Tool][pkg.Name]; exists {
+				result.PackagesSkipped++
+				continue
+			}
+			if j.data.Packages[pkg.Tool] == nil {
+				j.data.Packages[pkg.Tool] = make(map[string]core.PackageInfo)
+			}
+			j.data.Packages[pkg.Tool][pkg.Name] = copyPackageValue(*pkg)
+			result.PackagesInserted++
+		}
+
+		derived := make(map[string]bool)
+		seen := make(map[string]bool, len(j.data.Executions))
+		for _, exec := range j.data.Executions {
+			seen[exec.ID] = true
+		}
+		err := j.streamShards(func(record *core.ExecutionRecord) error {
+			seen[record.ID] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range executions {
+			j.prepareExecution(record)
+			if seen[record.ID] {
+				result.ExecutionsSkipped++
+				continue
+			}
+			seen[record.ID] = true
+
+			stored := copyExecutionValue(*record)
+			if err := j.storeExecution(stored); err != nil {
+				return err
+			}
+			result.ExecutionsInserted++
+
+			for _, name := range stored.PackagesAffected {
+				key := stored.Tool + "/" + name
+				if _, exists := j.data.Packages[stored.Tool][name]; exists && !derived[key] {
+					continue
+				}
+				if err := j.updatePackageInternal(stored.Tool, name, stored.Timestamp); err != nil {
+					return err
+				}
+				derived[key] = true
+			}
+		}
+
+		if err := j.enforceRetentionPolicies(time.Time{}); err != nil {
+			return err
+		}
+		if err := j.rebuildStatistics(); err != nil {
+			return err
+		}
+		return j.save()
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return result, nil
+}
 
-	data, err := json.MarshalIndent(j.data, "", "  ")
+func (j *JSONStorage) writeBackup(data []byte) (string, error) {
+	backupPath, err := j.nextBackupPath(time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to marshal backup data: %w", err)
+		return "", err
 	}
 
 	if err := os.WriteFile(backupPath, data, core.PrivateFileMode); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+		return "", fmt.Errorf("failed to write backup file: %w", err)
 	}
 
 	if err := j.pruneBackups(); err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return backupPath, nil
 }
 
 func (j *JSONStorage) Restore(path string) error {
@@ -415,12 +855,18 @@ func (j *JSONStorage) Restore(path string) error {
 		return fmt.Errorf("failed to read restore file: %w", err)
 	}
 
-	var storage core.StorageData
-	if err := json.Unmarshal(data, &storage); err != nil {
-		return fmt.Errorf("failed to unmarshal restore data: %w", err)
+	storage, _, err := decodeStorage(data)
+	if err != nil {
+		return fmt.Errorf("failed to load restore data: %w", err)
 	}
 
-	j.data = &storage
+	if err := j.removeShards(); err != nil {
+		return err
+	}
+	j.data = storage
+	j.data.Metadata.Shards = nil
+	j.index = buildExecutionIndex(j.data.Executions)
+	j.resetShards()
 	return j.save()
 }
 
@@ -442,28 +888,35 @@ func (j *JSONStorage) Cleanup(before time.Time) error {
 }
 
 func (j *JSONStorage) enforceRetentionPolicies(before time.Time) error {
-	changed := false
+	now := time.Now()
+	cutoff := func(tool string) time.Time {
+		if !before.IsZero() {
+			return before
+		}
+		return j.config.RetentionCutoff(tool, now)
+	}
 
-	cutoff := before
-	if cutoff.IsZero() && j.config.Storage.RetentionDays > 0 {
-		cutoff = time.Now().AddDate(0, 0, -j.config.Storage.RetentionDays)
+	changed, err := j.expireColdShards(cutoff, !before.IsZero())
+	if err != nil {
+		return err
 	}
-	if !cutoff.IsZero() {
-		kept := make([]core.ExecutionRecord, 0, len(j.data.Executions))
-		for _, exec := range j.data.Executions {
-			if exec.Timestamp.After(cutoff) {
-				kept = append(kept, exec)
-			}
-		}
-		if len(kept) != len(j.data.Executions) {
-			j.data.Executions = kept
-			changed = true
+
+	kept := make([]core.ExecutionRecord, 0, len(j.data.Executions))
+	for _, exec := range j.data.Executions {
+		if toolCutoff := cutoff(exec.Tool); toolCutoff.IsZero() || exec.Timestamp.After(toolCutoff) {
+			kept = append(kept, exec)
 		}
 	}
+	if len(kept) != len(j.data.Executions) {
+		j.replaceExecutions(kept)
+		changed = true
+	}
 
+	// max_executions and max_storage_bytes bound the executions in memory;
+	// the shards of older months are bounded by retention alone.
 	if maxExecutions := j.config.Storage.MaxExecutions; maxExecutions > 0 && len(j.data.Executions) > maxExecutions {
 		sortExecutionsNewestFirst(j.data.Executions)
-		j.data.Executions = append([]core.ExecutionRecord(nil), j.data.Executions[:maxExecutions]...)
+		j.replaceExecutions(append([]core.ExecutionRecord(nil), j.data.Executions[:maxExecutions]...))
 		changed = true
 	}
 
@@ -476,7 +929,8 @@ func (j *JSONStorage) enforceRetentionPolicies(before time.Time) error {
 	}
 
 	if changed {
-		j.rebuildStatistics()
+		j.index = buildExecutionIndex(j.data.Executions)
+		return j.rebuildStatistics()
 	}
 
 	return nil
@@ -514,7 +968,8 @@ func (j *JSONStorage) enforceMaxStorageBytes(maxBytes int64) (bool, error) {
 		}
 	}
 
-	j.data.Executions = append([]core.ExecutionRecord(nil), executions[:bestKeep]...)
+	j.data.Executions = executions
+	j.replaceExecutions(append([]core.ExecutionRecord(nil), executions[:bestKeep]...))
 	return bestKeep != len(executions), nil
 }
 
@@ -532,86 +987,101 @@ func sortExecutionsNewestFirst(executions []core.ExecutionRecord) {
 	})
 }
 
-func (j *JSONStorage) rebuildStatistics() {
+// rebuildStatistics recounts the statistics from the executions in memory
+// and in the shards of older months
+func (j *JSONStorage) rebuildStatistics() error {
 	stats := core.StorageStatistics{
-		TotalExecutions:    len(j.data.Executions),
 		ToolsUsed:          []string{},
-		MostActiveDay:      "",
 		ExecutionFrequency: make(map[string]int),
+		Days:               make(map[string]core.DayAggregate),
+		Hours:              make([]int, 24),
+		Weekdays:           make([]int, 7),
 	}
+	err := j.streamShards(func(record *core.ExecutionRecord) error {
+		stats.Count(record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range j.data.Executions {
+		stats.Count(&j.data.Executions[i])
+	}
+	j.data.Statistics = stats
+	return nil
+}
 
-	seenTools := make(map[string]bool)
-	dayCount := make(map[string]int)
-	for _, exec := range j.data.Executions {
-		if exec.Tool != "" {
-			if !seenTools[exec.Tool] {
-				seenTools[exec.Tool] = true
-				stats.ToolsUsed = append(stats.ToolsUsed, exec.Tool)
+func (j *JSONStorage) pruneBackups() error {
+	maxBackups, maxAge, err := j.config.Storage.BackupRetention()
+	if err != nil {
+		return err
+	}
+	if maxBackups <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(j.filepath)
+	if err != nil {
+		return err
+	}
+
+	var expired []BackupInfo
+	switch {
+	case maxBackups > 0 && len(backups) > maxBackups:
+		expired = backups[maxBackups:]
+	case maxAge > 0 && len(backups) > 1:
+		cutoff := time.Now().Add(-maxAge)
+		// Always keep the newest backup, however old it is.
+		for _, backup := range backups[1:] {
+			if backup.CreatedAt.Before(cutoff) {
+				expired = append(expired, backup)
 			}
-			stats.ExecutionFrequency[exec.Tool]++
-		}
-		if !exec.Timestamp.IsZero() {
-			day := exec.Timestamp.Format("2006-01-02")
-			dayCount[day]++
 		}
 	}
 
-	maxCount := 0
-	for day, count := range dayCount {
-		if count > maxCount {
-			maxCount = count
-			stats.MostActiveDay = day
+	for _, backup := range expired {
+		if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old backup %s: %w", backup.Path, err)
 		}
 	}
 
-	j.data.Statistics = stats
+	return nil
 }
 
-func (j *JSONStorage) pruneBackups() error {
-	maxBackups := j.config.Storage.MaxBackups
-	if maxBackups <= 0 {
-		return nil
-	}
+// BackupInfo describes a storage backup file.
+type BackupInfo struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
 
-	paths, err := filepath.Glob(j.filepath + ".backup.*")
+// ListBackups returns the backups of the storage file at jsonFile, newest
+// first.
+func ListBackups(jsonFile string) ([]BackupInfo, error) {
+	paths, err := filepath.Glob(jsonFile + ".backup.*")
 	if err != nil {
-		return fmt.Errorf("failed to list backup files: %w", err)
+		return nil, fmt.Errorf("failed to list backup files: %w", err)
 	}
 
-	backups := make([]backupFile, 0, len(paths))
+	backups := make([]BackupInfo, 0, len(paths))
 	for _, path := range paths {
 		info, err := os.Stat(path)
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
-			return fmt.Errorf("failed to stat backup file %s: %w", path, err)
+			return nil, fmt.Errorf("failed to stat backup file %s: %w", path, err)
 		}
-		backups = append(backups, backupFile{path: path, modTime: info.ModTime()})
-	}
-	if len(backups) <= maxBackups {
-		return nil
+		backups = append(backups, BackupInfo{Path: path, Size: info.Size(), CreatedAt: info.ModTime()})
 	}
 
 	sort.Slice(backups, func(i, k int) bool {
-		if !backups[i].modTime.Equal(backups[k].modTime) {
-			return backups[i].modTime.Before(backups[k].modTime)
+		if !backups[i].CreatedAt.Equal(backups[k].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[k].CreatedAt)
 		}
-		return backups[i].path < backups[k].path
+		return backups[i].Path > backups[k].Path
 	})
-
-	for _, backup := range backups[:len(backups)-maxBackups] {
-		if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("failed to remove old backup %s: %w", backup.path, err)
-		}
-	}
-
-	return nil
-}
-
-type backupFile struct {
-	path    string
-	modTime time.Time
+	return backups, nil
 }
 
 func (j *JSONStorage) nextBackupPath(now time.Time) (string, error) {
@@ -637,11 +1107,28 @@ func (j *JSONStorage) reload() error {
 	if _, err := os.Stat(j.filepath); err != nil {
 		return err
 	}
+	if j.data != nil && j.currentStamps() == j.stamps {
+		return nil
+	}
 	return j.load()
 }
 
-func (j *JSONStorage) withFileLock(fn func() error) (err error) {
-	lockPath := j.filepath + ".lock"
+func (j *JSONStorage) withFileLock(fn func() error) error {
+	return lockStorageFile(j.filepath, func() error {
+		if err := fn(); err != nil {
+			// fn may have changed the data it failed to save; read the
+			// files again next time.
+			j.stamps = fileStamps{}
+			return err
+		}
+		return nil
+	})
+}
+
+// lockStorageFile runs fn holding the lock other processes take before
+// reading or writing the storage file at storagePath
+func lockStorageFile(storagePath string, fn func() error) (err error) {
+	lockPath := storagePath + ".lock"
 	lockFile, err := safefs.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, core.PrivateFileMode)
 	if err != nil {
 		return fmt.Errorf("failed to open storage lock: %w", err)
@@ -652,19 +1139,19 @@ func (j *JSONStorage) withFileLock(fn func() error) (err error) {
 		}
 	}()
 
-	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+	if err := acquireFileLock(lockFile); err != nil {
 		return fmt.Errorf("failed to lock storage: %w", err)
 	}
 
 	if err := fn(); err != nil {
-		unlockErr := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		unlockErr := releaseFileLock(lockFile)
 		if unlockErr != nil {
 			return fmt.Errorf("%w; additionally failed to unlock storage: %v", err, unlockErr)
 		}
 		return err
 	}
 
-	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN); err != nil {
+	if err := releaseFileLock(lockFile); err != nil {
 		return fmt.Errorf("failed to unlock storage: %w", err)
 	}
 
@@ -727,23 +1214,15 @@ func (j *JSONStorage) cleanRestorePath(path string) (string, error) {
 	return restorePath, nil
 }
 
-func generateID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, 6)
-	if _, err := rand.Read(b); err == nil {
-		for i, v := range b {
-			b[i] = charset[int(v)%len(charset)]
-		}
-		return string(b)
-	}
-	return fmt.Sprintf("%06x", time.Now().UnixNano()&0xFFFFFF)
-}
-
 func copyExecutionValue(record core.ExecutionRecord) core.ExecutionRecord {
 	record.Args = copyStringSlice(record.Args)
 	record.Environment = copyStringMap(record.Environment)
 	record.PackagesAffected = copyStringSlice(record.PackagesAffected)
 	record.Metadata = copyMetadataMap(record.Metadata)
+	if record.Output != nil {
+		output := *record.Output
+		record.Output = &output
+	}
 	return record
 }
 
@@ -755,6 +1234,17 @@ func copyPackageValue(pkg core.PackageInfo) core.PackageInfo {
 func copyStorageStatistics(stats core.StorageStatistics) core.StorageStatistics {
 	stats.ToolsUsed = copyStringSlice(stats.ToolsUsed)
 	stats.ExecutionFrequency = copyStringIntMap(stats.ExecutionFrequency)
+	if stats.Days != nil {
+		days := make(map[string]core.DayAggregate, len(stats.Days))
+		for key, day := range stats.Days {
+			day.Tools = copyStringIntMap(day.Tools)
+			day.Packages = copyStringIntMap(day.Packages)
+			days[key] = day
+		}
+		stats.Days = days
+	}
+	stats.Hours = copyIntSlice(stats.Hours)
+	stats.Weekdays = copyIntSlice(stats.Weekdays)
 	return stats
 }
 
@@ -765,6 +1255,13 @@ func copyStringSlice(values []string) []string {
 	return append([]string(nil), values...)
 }
 
+func copyIntSlice(values []int) []int {
+	if values == nil {
+		return nil
+	}
+	return append([]int(nil), values...)
+}
+
 func copyStringMap(values map[string]string) map[string]string {
 	if values == nil {
 		return nil
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CompileCommandMatch compiles a regular expression for
+// QueryOptions.CommandPattern. The expression may match anywhere in the
+// command text.
+func CompileCommandMatch(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(pattern)
+}
+
+// CompileCommandGlob translates a shell-style glob into a pattern for
+// QueryOptions.CommandPattern. The glob must match the whole command text:
+// * matches any run of characters and ? matches exactly one.
+func CompileCommandGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, char := range glob {
+		switch char {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
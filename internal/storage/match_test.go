@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestCompileCommandGlob(t *testing.T) {
+	tests := []struct {
+		glob    string
+		command string
+		want    bool
+	}{
+		{"npm install *", "npm install typescript", true},
+		{"npm install *", "sudo npm install typescript", false},
+		{"*typescript*", "npm install -D typescript@5", true},
+		{"go get golang.org/x/?ys", "go get golang.org/x/sys", true},
+		{"pip install a.b", "pip install aXb", false},
+	}
+	for _, tt := range tests {
+		pattern, err := CompileCommandGlob(tt.glob)
+		if err != nil {
+			t.Fatalf("CompileCommandGlob(%q) failed: %v", tt.glob, err)
+		}
+		if got := pattern.MatchString(tt.command); got != tt.want {
+			t.Errorf("glob %q on %q = %v, want %v", tt.glob, tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestQueryOptionsCommandPattern(t *testing.T) {
+	config := &core.Config{
+		Storage: core.StorageConfig{
+			JSONFile: filepath.Join(t.TempDir(), "test.json"),
+		},
+	}
+	storage, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer closeStorage(t, storage)
+
+	for _, command := range []string{"npm install typescript", "npm install -D @types/node", "npm uninstall typescript"} {
+		addExecution(t, storage, &core.ExecutionRecord{Tool: "npm", Command: command, Timestamp: time.Now()})
+	}
+
+	pattern, err := CompileCommandMatch(`install .*typescript`)
+	if err != nil {
+		t.Fatalf("CompileCommandMatch failed: %v", err)
+	}
+	results, _ := storage.GetExecutions(QueryOptions{CommandPattern: pattern})
+	if len(results) != 2 {
+		t.Errorf("Expected 2 executions matching regex, got %d", len(results))
+	}
+
+	pattern, _ = CompileCommandGlob("npm install -D *")
+	results, _ = storage.GetExecutions(QueryOptions{CommandPattern: pattern})
+	if len(results) != 1 || results[0].Command != "npm install -D @types/node" {
+		t.Errorf("Expected only the dev install to match glob, got %d results", len(results))
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// initialStorageVersion is the version of files written before migrations
+// existed, which is also assumed for files with no version at all
+const initialStorageVersion = "1.0.0"
+
+// migration upgrades storage data from one schema version to the next
+type migration struct {
+	from, to string
+	apply    func(*core.StorageData)
+}
+
+// migrations are applied in order, each one's to being the next one's from,
+// until data reaches core.StorageVersion. A change to the storage schema
+// that old files need upgrading for bumps core.StorageVersion and appends
+// a migration here.
+var migrations = []migration{
+	// 1.1.0 records the host on every execution. Older executions were all
+	// recorded on the machine the file was created on.
+	{from: "1.0.0", to: "1.1.0", apply: func(data *core.StorageData) {
+		for i := range data.Executions {
+			if data.Executions[i].Host == "" {
+				data.Executions[i].Host = data.Metadata.Hostname
+			}
+		}
+		if data.Packages == nil {
+			data.Packages = make(map[string]map[string]core.PackageInfo)
+		}
+		if data.Statistics.ExecutionFrequency == nil {
+			data.Statistics.ExecutionFrequency = make(map[string]int)
+		}
+	}},
+	// 1.2.0 keeps executions in per-month shard files beside the storage
+	// file. Executions still in the file are moved to shards by the save
+	// that follows loading it.
+	{from: "1.1.0", to: "1.2.0", apply: func(*core.StorageData) {}},
+}
+
+// UnsupportedVersionError is returned for storage data whose schema version
+// this build cannot upgrade from, usually because a newer diu wrote it.
+// Loading it anyway would drop the fields this build does not know about
+// on the next save.
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("storage version %q is not supported by diu %s (expected %s or older); upgrade diu to read it",
+		e.Version, core.Version, core.StorageVersion)
+}
+
+// decodeStorage parses storage data and migrates it to core.StorageVersion,
+// reporting whether it was migrated
+func decodeStorage(raw []byte) (*core.StorageData, bool, error) {
+	var data core.StorageData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, false, err
+	}
+	migrated, err := migrate(&data)
+	if err != nil {
+		return nil, false, err
+	}
+	return &data, migrated, nil
+}
+
+// migrate upgrades data in place to core.StorageVersion
+func migrate(data *core.StorageData) (bool, error) {
+	if data.Version == "" {
+		data.Version = initialStorageVersion
+	}
+	from := data.Version
+	for _, m := range migrations {
+		if data.Version == m.from {
+			m.apply(data)
+			data.Version = m.to
+		}
+	}
+	if data.Version != core.StorageVersion {
+		return false, &UnsupportedVersionError{Version: from}
+	}
+	return data.Version != from, nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// writeStorageFile writes a storage file with the given version and one
+// execution, as an older or newer diu would have
+func writeStorageFile(t *testing.T, path, version string) {
+	t.Helper()
+	raw := `{
+  "version": "` + version + `",
+  "metadata": {"hostname": "old-laptop"},
+  "executions": [{"id": "a", "tool": "npm", "command": "npm install", "timestamp": "2024-01-02T03:04:05Z"}]
+}`
+	if err := os.WriteFile(path, []byte(raw), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write storage file: %v", err)
+	}
+}
+
+func TestMigratesOldStorageFile(t *testing.T) {
+	for _, version := range []string{"", "1.0.0"} {
+		path := filepath.Join(t.TempDir(), "executions.json")
+		writeStorageFile(t, path, version)
+
+		store, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: path}})
+		if err != nil {
+			t.Fatalf("NewJSONStorage failed for version %q: %v", version, err)
+		}
+		executions, err := store.GetExecutions(QueryOptions{})
+		closeStorage(t, store)
+		if err != nil {
+			t.Fatalf("GetExecutions failed: %v", err)
+		}
+		if len(executions) != 1 || executions[0].Host != "old-laptop" {
+			t.Errorf("Expected the execution's host to come from the file metadata, got %+v", executions)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read storage file: %v", err)
+		}
+		var data core.StorageData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			t.Fatalf("Invalid storage file: %v", err)
+		}
+		if data.Version != core.StorageVersion || data.Packages == nil {
+			t.Errorf("Expected the file to be rewritten at version %s, got %q", core.StorageVersion, data.Version)
+		}
+		if len(data.Executions) != 0 || len(data.Metadata.Shards) != 1 {
+			t.Errorf("Expected the executions moved to a shard, got %d in the file and shards %+v", len(data.Executions), data.Metadata.Shards)
+		}
+
+		backups, _ := filepath.Glob(path + ".backup.*")
+		if len(backups) != 1 {
+			t.Errorf("Expected the original file to be backed up, got %v", backups)
+		}
+	}
+}
+
+func TestRefusesUnsupportedStorageVersion(t *testing.T) {
+	for _, version := range []string{"9.0.0", "1.0.0-beta"} {
+		path := filepath.Join(t.TempDir(), "executions.json")
+		writeStorageFile(t, path, version)
+		before, _ := os.ReadFile(path)
+
+		_, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: path}})
+		var versionErr *UnsupportedVersionError
+		if !errors.As(err, &versionErr) || versionErr.Version != version {
+			t.Errorf("Expected an unsupported version error for %q, got %v", version, err)
+		}
+		if after, _ := os.ReadFile(path); string(after) != string(before) {
+			t.Errorf("Expected the version %q file to be left untouched", version)
+		}
+	}
+}
+
+func TestRestoreMigratesAndRefuses(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: filepath.Join(dir, "executions.json")}})
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, store)
+
+	newer := filepath.Join(dir, "executions.json.backup.newer")
+	writeStorageFile(t, newer, "9.0.0")
+	var versionErr *UnsupportedVersionError
+	if err := store.Restore(newer); !errors.As(err, &versionErr) {
+		t.Errorf("Expected restoring a newer file to fail, got %v", err)
+	}
+	raw, _ := os.ReadFile(newer)
+	if _, err := store.ImportBackup(raw); !errors.As(err, &versionErr) {
+		t.Errorf("Expected importing a newer backup to fail, got %v", err)
+	}
+
+	older := filepath.Join(dir, "executions.json.backup.older")
+	writeStorageFile(t, older, "1.0.0")
+	if err := store.Restore(older); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	executions, err := store.GetExecutions(QueryOptions{})
+	if err != nil {
+		t.Fatalf("GetExecutions failed: %v", err)
+	}
+	if len(executions) != 1 || executions[0].Host != "old-laptop" {
+		t.Errorf("Expected the restored execution to be migrated, got %+v", executions)
+	}
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// corruptSuffix is added to a file that cannot be decoded when
+// RecoverStorage sets it aside
+const corruptSuffix = ".corrupt"
+
+// CorruptStorageError is returned when the storage file or one of its
+// shards is not valid JSON
+type CorruptStorageError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptStorageError) Error() string {
+	return fmt.Sprintf("%s is corrupt: %v", e.Path, e.Err)
+}
+
+func (e *CorruptStorageError) Unwrap() error {
+	return e.Err
+}
+
+// Recovery describes how RecoverStorage replaced a corrupt file
+type Recovery struct {
+	// Path is the file that could not be read and Err why.
+	Path string
+	Err  error
+	// CorruptPath is where the file was moved.
+	CorruptPath string
+	// Backup is the backup the storage file was restored from.
+	Backup string
+	// Recovered counts the executions kept from shards newer than the
+	// backup.
+	Recovered int
+}
+
+func (r *Recovery) String() string {
+	return fmt.Sprintf("%s could not be read (%v); moved it to %s and restored storage from %s with %d newer executions from shards",
+		r.Path, r.Err, r.CorruptPath, r.Backup, r.Recovered)
+}
+
+// RecoverStorage replaces storage whose file or shard is corrupt with its
+// newest backup that can be decoded, after moving the corrupt file aside
+// with a .corrupt suffix. Executions in the shards that are still readable
+// and missing from the backup are kept, and executions journaled since
+// are replayed when storage is next opened. It fails, changing nothing,
+// when there is no usable backup.
+func RecoverStorage(config *core.Config, corrupt *CorruptStorageError) (*Recovery, error) {
+	storagePath, err := cleanManagedPath(config.Storage.JSONFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage path: %w", err)
+	}
+
+	recovery := &Recovery{Path: corrupt.Path, Err: corrupt.Err, CorruptPath: corrupt.Path + corruptSuffix}
+	err = lockStorageFile(storagePath, func() error {
+		data, err := newestValidBackup(storagePath, recovery)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(corrupt.Path, recovery.CorruptPath); err != nil {
+			return fmt.Errorf("failed to set aside corrupt file: %w", err)
+		}
+
+		recovery.Recovered, err = mergeShardExecutions(storagePath, data)
+		if err != nil {
+			return err
+		}
+		raw, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal storage data: %w", err)
+		}
+		tempFile := storagePath + ".tmp"
+		if err := writeSynced(tempFile, raw); err != nil {
+			return fmt.Errorf("failed to write storage file: %w", err)
+		}
+		if err := os.Rename(tempFile, storagePath); err != nil {
+			return fmt.Errorf("failed to rename temp file: %w", err)
+		}
+		return syncDir(filepath.Dir(storagePath))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recovery, nil
+}
+
+// newestValidBackup decodes the newest backup of the storage file at
+// storagePath that is valid, recording its path in recovery
+func newestValidBackup(storagePath string, recovery *Recovery) (*core.StorageData, error) {
+	backups, err := ListBackups(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		raw, err := readManagedFile(backup.Path)
+		if err != nil {
+			continue
+		}
+		if data, _, err := decodeStorage(raw); err == nil {
+			recovery.Backup = backup.Path
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("no valid backup of %s to recover from", storagePath)
+}
+
+// mergeShardExecutions adds the executions in the readable shards beside
+// the storage file at storagePath that data lacks, holding every execution
+// in data itself, and recounts the statistics and package usage. It
+// returns how many executions it added.
+func mergeShardExecutions(storagePath string, data *core.StorageData) (int, error) {
+	paths, err := filepath.Glob(shardPath(storagePath, shardGlob))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list shards: %w", err)
+	}
+	seen := make(map[string]bool, len(data.Executions))
+	for _, exec := range data.Executions {
+		seen[exec.ID] = true
+	}
+	added := 0
+	for _, path := range paths {
+		raw, err := readManagedFile(path)
+		if err != nil {
+			continue
+		}
+		var shard shardFile
+		if err := json.Unmarshal(raw, &shard); err != nil {
+			continue
+		}
+		for _, exec := range shard.Executions {
+			if exec.ID != "" && seen[exec.ID] {
+				continue
+			}
+			seen[exec.ID] = true
+			data.Executions = append(data.Executions, exec)
+			added++
+		}
+	}
+	// The shards are rewritten from data.Executions when it is opened.
+	data.Metadata.Shards = nil
+
+	data.Statistics = core.StorageStatistics{
+		ToolsUsed:          []string{},
+		ExecutionFrequency: make(map[string]int),
+		Days:               make(map[string]core.DayAggregate),
+	}
+	for i := range data.Executions {
+		data.Statistics.Count(&data.Executions[i])
+	}
+	(&JSONStorage{data: data}).repairPackages()
+	return added, nil
+}
+
+// OpenWithRecovery opens the storage of config like NewJSONStorage and,
+// when the storage file or a shard is corrupt, recovers it with
+// RecoverStorage and opens the result. The returned Recovery is nil when
+// storage opened normally.
+func OpenWithRecovery(config *core.Config) (Storage, *Recovery, error) {
+	store, err := NewJSONStorage(config)
+	var corrupt *CorruptStorageError
+	if !errors.As(err, &corrupt) {
+		return store, nil, err
+	}
+	recovery, recoverErr := RecoverStorage(config, corrupt)
+	if recoverErr != nil {
+		return nil, nil, fmt.Errorf("%w; automatic recovery failed: %v", err, recoverErr)
+	}
+	store, err = NewJSONStorage(config)
+	if err != nil {
+		return nil, recovery, err
+	}
+	return store, recovery, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestOpenWithRecoveryRestoresNewestValidBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	config := &core.Config{Storage: core.StorageConfig{JSONFile: path}}
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	january := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	addExecution(t, store, &core.ExecutionRecord{ID: "jan", Tool: "npm", Command: "npm ci", Timestamp: january})
+	backupPath, err := store.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	addExecution(t, store, &core.ExecutionRecord{ID: "feb", Tool: "npm", Command: "npm install tsx", Timestamp: january.AddDate(0, 1, 0), PackagesAffected: []string{"tsx"}})
+	closeStorage(t, store)
+
+	// A newer backup that is itself corrupt is skipped.
+	if err := os.WriteFile(path+".backup.29990101_000000_000000000", []byte("{"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": "1.2.0", "executions": [`), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to corrupt storage file: %v", err)
+	}
+
+	var corrupt *CorruptStorageError
+	if _, err := NewJSONStorage(config); !errors.As(err, &corrupt) || corrupt.Path != path {
+		t.Fatalf("Expected a CorruptStorageError for the storage file, got %v", err)
+	}
+
+	recovered, recovery, err := OpenWithRecovery(config)
+	if err != nil {
+		t.Fatalf("OpenWithRecovery failed: %v", err)
+	}
+	defer closeStorage(t, recovered)
+	if recovery == nil || recovery.Backup != backupPath || recovery.Recovered != 1 {
+		t.Fatalf("Expected recovery from %s with one execution from shards, got %+v", backupPath, recovery)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("Expected the corrupt file kept: %v", err)
+	}
+
+	all, err := recovered.GetExecutions(QueryOptions{})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Expected both executions after recovery, got %d (%v)", len(all), err)
+	}
+	if pkg, err := recovered.GetPackage("npm", "tsx"); err != nil || pkg.UsageCount != 1 {
+		t.Errorf("Expected package usage recounted from the shards, got %+v (%v)", pkg, err)
+	}
+	if report, err := Check(path); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Expected recovered storage to pass a check, got %+v (%v)", report, err)
+	}
+}
+
+func TestOpenWithRecoveryWithoutBackupLeavesFileAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	corrupt := []byte(`{"executions": [`)
+	if err := os.WriteFile(path, corrupt, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write storage file: %v", err)
+	}
+
+	if _, _, err := OpenWithRecovery(&core.Config{Storage: core.StorageConfig{JSONFile: path}}); err == nil {
+		t.Fatal("Expected an error without a backup to recover from")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(corrupt) {
+		t.Errorf("Expected the storage file left as it was, got %q (%v)", data, err)
+	}
+}
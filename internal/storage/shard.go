@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// shardMonthLayout names the UTC month a shard covers
+const shardMonthLayout = "2006-01"
+
+// shardGlob matches the month in the name of any shard
+const shardGlob = "[0-9][0-9][0-9][0-9]-[0-9][0-9]"
+
+// shardPruneSlack is how far retention passes the oldest execution in a
+// shard that is not in memory before the shard is rewritten without the
+// expired ones, so the oldest shard is rewritten about once a day rather
+// than on every save
+const shardPruneSlack = 24 * time.Hour
+
+// errStopStream ends a streamShards early once fn has what it needs
+var errStopStream = errors.New("stop streaming")
+
+// shardFile is the content of one month's shard
+type shardFile struct {
+	Month      string                 `json:"month"`
+	Executions []core.ExecutionRecord `json:"executions"`
+}
+
+func shardMonth(t time.Time) string {
+	return t.UTC().Format(shardMonthLayout)
+}
+
+// shardPath names month's shard beside the storage file at storagePath:
+// executions-2025-01.json for executions.json
+func shardPath(storagePath, month string) string {
+	return strings.TrimSuffix(storagePath, filepath.Ext(storagePath)) + "-" + month + ".json"
+}
+
+// ReadShards returns the executions in shards, the shards of the storage
+// file at storagePath, for reading a storage file outside JSONStorage
+func ReadShards(storagePath string, shards []core.ShardInfo) ([]core.ExecutionRecord, error) {
+	var executions []core.ExecutionRecord
+	for _, shard := range shards {
+		records, err := readShard(storagePath, shard.Month)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, records...)
+	}
+	return executions, nil
+}
+
+// readShard returns the executions in month's shard. A missing shard is
+// empty: a save removed it and was cut short before the storage file
+// followed.
+func readShard(storagePath, month string) ([]core.ExecutionRecord, error) {
+	data, err := readManagedFile(shardPath(storagePath, month))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard %s: %w", month, err)
+	}
+	var shard shardFile
+	if err := json.Unmarshal(data, &shard); err != nil {
+		return nil, fmt.Errorf("invalid shard %s: %w", month, &CorruptStorageError{Path: shardPath(storagePath, month), Err: err})
+	}
+	return shard.Executions, nil
+}
+
+// resetShards forgets which months are in memory and changed, for data
+// just loaded whose executions are all in memory
+func (j *JSONStorage) resetShards() {
+	j.loaded = make(map[string]bool)
+	j.dirty = make(map[string]bool)
+	// Executions still in the storage file itself, from before shards or
+	// from a restored backup, move to their shards on the next save.
+	for _, exec := range j.data.Executions {
+		month := shardMonth(exec.Timestamp)
+		j.loaded[month] = true
+		j.dirty[month] = true
+	}
+}
+
+// memoryCutoff returns the time before which executions are left in their
+// shards rather than kept in memory, or zero when all are kept
+func (j *JSONStorage) memoryCutoff(now time.Time) time.Time {
+	if j.config.Storage.MemoryDays <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -j.config.Storage.MemoryDays)
+}
+
+// cold reports whether month ended before the memory cutoff, so its shard
+// is read only when a query reaches back to it
+func (j *JSONStorage) cold(month string, now time.Time) bool {
+	cutoff := j.memoryCutoff(now)
+	if cutoff.IsZero() {
+		return false
+	}
+	start, err := time.Parse(shardMonthLayout, month)
+	if err != nil {
+		return false
+	}
+	return !start.AddDate(0, 1, 0).After(cutoff)
+}
+
+// loadShards reads the shards of the months that are not cold into
+// memory. It reports whether any shard held a different number of
+// executions than the storage file says, as after a save cut short.
+func (j *JSONStorage) loadShards() (bool, error) {
+	stale := false
+	now := time.Now()
+	for _, shard := range j.data.Metadata.Shards {
+		if j.cold(shard.Month, now) && !j.loaded[shard.Month] {
+			continue
+		}
+		executions, err := readShard(j.filepath, shard.Month)
+		if err != nil {
+			return false, err
+		}
+		if len(executions) != shard.Executions {
+			stale = true
+			j.dirty[shard.Month] = true
+		}
+		j.data.Executions = append(j.data.Executions, executions...)
+		j.loaded[shard.Month] = true
+	}
+	return stale, nil
+}
+
+// loadShard reads month's shard into memory unless it is there already
+func (j *JSONStorage) loadShard(month string) error {
+	if j.loaded[month] {
+		return nil
+	}
+	j.loaded[month] = true
+	shard := j.shardInfo(month)
+	if shard == nil {
+		return nil
+	}
+	executions, err := readShard(j.filepath, month)
+	if err != nil {
+		return err
+	}
+	j.data.Executions = append(j.data.Executions, executions...)
+	j.index = buildExecutionIndex(j.data.Executions)
+	if len(executions) != shard.Executions {
+		j.dirty[month] = true
+		return j.rebuildStatistics()
+	}
+	return nil
+}
+
+func (j *JSONStorage) shardInfo(month string) *core.ShardInfo {
+	shards := j.data.Metadata.Shards
+	i := sort.Search(len(shards), func(k int) bool { return shards[k].Month >= month })
+	if i < len(shards) && shards[i].Month == month {
+		return &shards[i]
+	}
+	return nil
+}
+
+// storeExecution adds exec to memory, reading its month's shard first so
+// the shard is rewritten with everything already in it
+func (j *JSONStorage) storeExecution(exec core.ExecutionRecord) error {
+	month := shardMonth(exec.Timestamp)
+	if err := j.loadShard(month); err != nil {
+		return err
+	}
+	j.data.Executions = append(j.data.Executions, exec)
+	j.index.add(j.data.Executions, len(j.data.Executions)-1)
+	j.dirty[month] = true
+	return nil
+}
+
+// replaceExecutions swaps in executions, some of those in memory, marking
+// the months that lost any as changed
+func (j *JSONStorage) replaceExecutions(executions []core.ExecutionRecord) {
+	removed := make(map[string]int)
+	for _, exec := range j.data.Executions {
+		removed[shardMonth(exec.Timestamp)]++
+	}
+	for _, exec := range executions {
+		removed[shardMonth(exec.Timestamp)]--
+	}
+	for month, count := range removed {
+		if count != 0 {
+			j.dirty[month] = true
+		}
+	}
+	j.data.Executions = executions
+}
+
+// saveShards writes the shards of the months changed since the last save,
+// removing those left empty, then drops the months that went cold from
+// memory. The storage file saved next commits the shards' new counts.
+func (j *JSONStorage) saveShards() error {
+	byMonth := make(map[string][]core.ExecutionRecord, len(j.dirty))
+	for _, exec := range j.data.Executions {
+		if month := shardMonth(exec.Timestamp); j.dirty[month] {
+			byMonth[month] = append(byMonth[month], exec)
+		}
+	}
+	for month := range j.dirty {
+		if err := j.writeShard(month, byMonth[month]); err != nil {
+			return err
+		}
+		delete(j.dirty, month)
+	}
+
+	now := time.Now()
+	unloaded := false
+	for month := range j.loaded {
+		if j.cold(month, now) {
+			delete(j.loaded, month)
+			unloaded = true
+		}
+	}
+	if !unloaded {
+		return nil
+	}
+	kept := make([]core.ExecutionRecord, 0, len(j.data.Executions))
+	for _, exec := range j.data.Executions {
+		if j.loaded[shardMonth(exec.Timestamp)] {
+			kept = append(kept, exec)
+		}
+	}
+	j.data.Executions = kept
+	j.index = buildExecutionIndex(kept)
+	return nil
+}
+
+// writeShard replaces month's shard with executions, or removes it when
+// there are none, and records it in the storage metadata
+func (j *JSONStorage) writeShard(month string, executions []core.ExecutionRecord) error {
+	path := shardPath(j.filepath, month)
+	shards := j.data.Metadata.Shards
+	i := sort.Search(len(shards), func(k int) bool { return shards[k].Month >= month })
+	exists := i < len(shards) && shards[i].Month == month
+
+	if len(executions) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove shard %s: %w", month, err)
+		}
+		if exists {
+			j.data.Metadata.Shards = append(shards[:i], shards[i+1:]...)
+		}
+		return nil
+	}
+
+	// Shards of months that have ended are seldom read by hand and are
+	// written without indentation, which makes them about a third smaller.
+	shard := shardFile{Month: month, Executions: executions}
+	var data []byte
+	var err error
+	if month < shardMonth(time.Now()) {
+		data, err = json.Marshal(shard)
+	} else {
+		data, err = json.MarshalIndent(shard, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal shard %s: %w", month, err)
+	}
+	tempFile := path + ".tmp"
+	if err := writeSynced(tempFile, data); err != nil {
+		return fmt.Errorf("failed to write shard %s: %w", month, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename shard %s: %w", month, err)
+	}
+
+	info := core.ShardInfo{Month: month, Executions: len(executions), Oldest: make(map[string]time.Time)}
+	for _, exec := range executions {
+		if info.From.IsZero() || exec.Timestamp.Before(info.From) {
+			info.From = exec.Timestamp
+		}
+		if exec.Timestamp.After(info.Through) {
+			info.Through = exec.Timestamp
+		}
+		if oldest, ok := info.Oldest[exec.Tool]; !ok || exec.Timestamp.Before(oldest) {
+			info.Oldest[exec.Tool] = exec.Timestamp
+		}
+	}
+	if exists {
+		shards[i] = info
+	} else {
+		shards = append(shards, core.ShardInfo{})
+		copy(shards[i+1:], shards[i:])
+		shards[i] = info
+		j.data.Metadata.Shards = shards
+	}
+	return nil
+}
+
+// removeShards removes every shard, for data that holds its executions
+// itself, such as a restored backup
+func (j *JSONStorage) removeShards() error {
+	for _, shard := range j.data.Metadata.Shards {
+		if err := os.Remove(shardPath(j.filepath, shard.Month)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove shard %s: %w", shard.Month, err)
+		}
+	}
+	j.data.Metadata.Shards = nil
+	return nil
+}
+
+// coldShards returns the shards not in memory that can hold executions
+// matching opts, newest first
+func (j *JSONStorage) coldShards(opts QueryOptions) []core.ShardInfo {
+	var shards []core.ShardInfo
+	for i := len(j.data.Metadata.Shards) - 1; i >= 0; i-- {
+		shard := j.data.Metadata.Shards[i]
+		if j.loaded[shard.Month] {
+			continue
+		}
+		if opts.Since != nil && opts.Since.After(shard.Through) {
+			continue
+		}
+		if opts.Until != nil && opts.Until.Before(shard.From) {
+			continue
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// streamShards calls fn with each execution in the shards not in memory,
+// newest shard first
+func (j *JSONStorage) streamShards(fn func(*core.ExecutionRecord) error) error {
+	for _, shard := range j.coldShards(QueryOptions{}) {
+		executions, err := readShard(j.filepath, shard.Month)
+		if err != nil {
+			return err
+		}
+		for i := range executions {
+			if err := fn(&executions[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expireColdShards applies retention to the shards not in memory. A shard
+// whose executions have all expired is removed without reading it; one
+// holding some expired executions is loaded so they are pruned with the
+// rest. It reports whether any shard was removed.
+func (j *JSONStorage) expireColdShards(cutoff func(tool string) time.Time, exact bool) (bool, error) {
+	removed := false
+	for _, shard := range append([]core.ShardInfo(nil), j.data.Metadata.Shards...) {
+		if j.loaded[shard.Month] {
+			continue
+		}
+		all, some := len(shard.Oldest) > 0, false
+		for tool, oldest := range shard.Oldest {
+			toolCutoff := cutoff(tool)
+			if toolCutoff.IsZero() || shard.Through.After(toolCutoff) {
+				all = false
+			}
+			if !exact {
+				oldest = oldest.Add(shardPruneSlack)
+			}
+			if !toolCutoff.IsZero() && oldest.Before(toolCutoff) {
+				some = true
+			}
+		}
+
+		switch {
+		case all:
+			// An empty, changed month is removed by the next save.
+			j.loaded[shard.Month] = true
+			j.dirty[shard.Month] = true
+			removed = true
+		case some:
+			if err := j.loadShard(shard.Month); err != nil {
+				return false, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// allExecutions returns the executions in the shards not in memory
+// followed by those in memory, for writing every execution out at once
+func (j *JSONStorage) allExecutions() ([]core.ExecutionRecord, error) {
+	var executions []core.ExecutionRecord
+	err := j.streamShards(func(record *core.ExecutionRecord) error {
+		executions = append(executions, *record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(executions, j.data.Executions...), nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestShardedWritesTouchOnlyChangedMonths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	store, err := NewJSONStorage(&core.Config{Storage: core.StorageConfig{JSONFile: path}})
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, store)
+
+	january := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	february := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: january})
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: february})
+
+	januaryShard := filepath.Join(filepath.Dir(path), "executions-2026-01.json")
+	before, err := os.ReadFile(januaryShard)
+	if err != nil {
+		t.Fatalf("Expected a January shard: %v", err)
+	}
+	stat, _ := os.Stat(januaryShard)
+
+	addExecution(t, store, &core.ExecutionRecord{Tool: "go", Command: "go build", Timestamp: february.Add(time.Hour)})
+	after, _ := os.ReadFile(januaryShard)
+	if restat, _ := os.Stat(januaryShard); !bytes.Equal(before, after) || !restat.ModTime().Equal(stat.ModTime()) {
+		t.Error("Expected a February execution to leave the January shard alone")
+	}
+
+	raw, _ := os.ReadFile(path)
+	var manifest core.StorageData
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("Invalid storage file: %v", err)
+	}
+	if len(manifest.Executions) != 0 || len(manifest.Metadata.Shards) != 2 || manifest.Metadata.Shards[1].Executions != 2 {
+		t.Errorf("Expected the storage file to list two shards and hold no executions, got %+v", manifest.Metadata.Shards)
+	}
+
+	// Cleanup drops January whole.
+	if err := store.Cleanup(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(januaryShard); !os.IsNotExist(err) {
+		t.Errorf("Expected the January shard removed, got %v", err)
+	}
+	if all, _ := store.GetExecutions(QueryOptions{}); len(all) != 2 {
+		t.Errorf("Expected the February executions kept, got %d", len(all))
+	}
+}
+
+func TestAddExecutionSkipsIDsInColdShards(t *testing.T) {
+	config := &core.Config{Storage: core.StorageConfig{
+		JSONFile:   filepath.Join(t.TempDir(), "executions.json"),
+		MemoryDays: 30,
+	}}
+	opened, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	store := opened.(*JSONStorage)
+	defer closeStorage(t, store)
+
+	old := core.ExecutionRecord{ID: "old-1", Tool: "npm", Command: "npm install", Timestamp: time.Now().AddDate(0, 0, -100)}
+	first := old
+	addExecution(t, store, &first)
+	if len(store.data.Executions) != 0 {
+		t.Fatalf("Expected the old execution left on disk, got %d in memory", len(store.data.Executions))
+	}
+
+	replayed := old
+	if err := store.AddExecution(&replayed); !errors.Is(err, ErrDuplicateExecution) {
+		t.Fatalf("Expected ErrDuplicateExecution for an ID in a cold shard, got %v", err)
+	}
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 1 {
+		t.Errorf("Expected the execution stored once, got %d", got)
+	}
+}
+
+func TestMemoryDaysLeavesOldShardsOnDisk(t *testing.T) {
+	config := &core.Config{Storage: core.StorageConfig{
+		JSONFile:   filepath.Join(t.TempDir(), "executions.json"),
+		MemoryDays: 30,
+	}}
+	opened, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	store := opened.(*JSONStorage)
+	defer closeStorage(t, store)
+
+	now := time.Now()
+	old := &core.ExecutionRecord{Tool: "npm", Command: "npm install", Timestamp: now.AddDate(0, 0, -100), PackagesAffected: []string{"tsx"}}
+	older := &core.ExecutionRecord{Tool: "go", Command: "go install", Timestamp: now.AddDate(0, 0, -200)}
+	recent := &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: now.Add(-time.Hour)}
+	for _, record := range []*core.ExecutionRecord{old, older, recent} {
+		addExecution(t, store, record)
+	}
+
+	if len(store.data.Executions) != 1 {
+		t.Errorf("Expected only the recent execution in memory, got %d", len(store.data.Executions))
+	}
+	if got := storedExecutionCount(t, config.Storage.JSONFile); got != 3 {
+		t.Errorf("Expected every execution in a shard, got %d", got)
+	}
+
+	all, err := store.GetExecutions(QueryOptions{})
+	if err != nil {
+		t.Fatalf("GetExecutions failed: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != recent.ID || all[1].ID != old.ID || all[2].ID != older.ID {
+		t.Fatalf("Expected every execution newest first, got %+v", all)
+	}
+	limited, _ := store.GetExecutions(QueryOptions{Tool: "npm", Limit: 2})
+	if len(limited) != 2 || limited[1].ID != old.ID {
+		t.Errorf("Expected the limit filled from an old shard, got %+v", limited)
+	}
+	since := now.AddDate(0, 0, -7)
+	if recentOnly, _ := store.GetExecutions(QueryOptions{Since: &since}); len(recentOnly) != 1 {
+		t.Errorf("Expected only the recent execution since a week ago, got %d", len(recentOnly))
+	}
+
+	found, err := store.GetExecutionByID(older.ID)
+	if err != nil || found.Command != "go install" {
+		t.Errorf("Expected the old execution by ID, got %+v (%v)", found, err)
+	}
+
+	if err := store.UpdateStatistics(); err != nil {
+		t.Fatalf("UpdateStatistics failed: %v", err)
+	}
+	stats, _ := store.GetStatistics()
+	if stats.TotalExecutions != 3 || stats.ExecutionFrequency["go"] != 1 {
+		t.Errorf("Expected statistics to count executions on disk, got %+v", stats)
+	}
+	if len(store.data.Executions) != 1 {
+		t.Errorf("Expected saving to leave old shards on disk, got %d in memory", len(store.data.Executions))
+	}
+
+	path, err := store.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	var backup core.StorageData
+	if err := json.Unmarshal(raw, &backup); err != nil {
+		t.Fatalf("Invalid backup: %v", err)
+	}
+	if len(backup.Executions) != 3 || len(backup.Metadata.Shards) != 0 {
+		t.Errorf("Expected the backup to hold every execution itself, got %d (%d shards)", len(backup.Executions), len(backup.Metadata.Shards))
+	}
+	if err := store.Restore(path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if all, _ := store.GetExecutions(QueryOptions{}); len(all) != 3 || len(store.data.Executions) != 1 {
+		t.Errorf("Expected the restore sharded again, got %d executions, %d in memory", len(all), len(store.data.Executions))
+	}
+
+	if err := store.Cleanup(now.AddDate(0, 0, -150)); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if all, _ := store.GetExecutions(QueryOptions{}); len(all) != 2 {
+		t.Errorf("Expected cleanup to prune old shards, got %d executions", len(all))
+	}
+}
+
+func TestInterruptedShardSaveRecountsStatistics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	config := &core.Config{Storage: core.StorageConfig{JSONFile: path}}
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	addExecution(t, store, &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: at})
+	closeStorage(t, store)
+
+	// Simulate a crash after writing the shard but before the storage file.
+	shard := shardFile{Month: "2026-03", Executions: []core.ExecutionRecord{
+		{ID: "a", Tool: "npm", Command: "npm ci", Timestamp: at},
+		{ID: "b", Tool: "go", Command: "go test", Timestamp: at.Add(time.Hour)},
+	}}
+	raw, _ := json.Marshal(shard)
+	if err := os.WriteFile(shardPath(path, "2026-03"), raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write shard: %v", err)
+	}
+
+	recovered, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	defer closeStorage(t, recovered)
+	stats, _ := recovered.GetStatistics()
+	if stats.TotalExecutions != 2 || stats.ExecutionFrequency["go"] != 1 {
+		t.Errorf("Expected statistics recounted from the shard, got %+v", stats)
+	}
+	if got := storedExecutionCount(t, path); got != 2 {
+		t.Errorf("Expected the storage file to catch up with the shard, got %d", got)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package storage
+
+import "os"
+
+// syncDir makes the renames into dir durable
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return f.Sync()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package storage
+
+// syncDir is a no-op: Windows cannot sync a directory handle, and NTFS
+// journals the renames into it.
+func syncDir(string) error {
+	return nil
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// tenantFileName is the store kept for each tenant
+const tenantFileName = "executions.json"
+
+// Tenant names one user's machine in a TenantStorage
+type Tenant struct {
+	User    string `json:"user"`
+	Machine string `json:"machine"`
+}
+
+// ErrMachineLimit is returned by Open for a machine that would take a user
+// past server.max_machines_per_user
+var ErrMachineLimit = errors.New("machine limit reached")
+
+// TenantStorage keeps a separate store for each user and machine under one
+// directory, at <root>/<user>/<machine>/executions.json, so records from
+// different people never mix. Each store is an ordinary Storage, opened on
+// first use. At most maxOpen stay open; opening another closes the least
+// recently used, which is safe since a JSONStorage stays usable after Close
+// and rereads its file on each call.
+type TenantStorage struct {
+	root    string
+	config  *core.Config
+	maxOpen int
+
+	mu     sync.Mutex
+	stores map[Tenant]Storage
+	// used lists the tenants in stores from least to most recently used
+	used []Tenant
+}
+
+// NewTenantStorage keeps tenants under root. Retention and limits for every
+// tenant come from config.
+func NewTenantStorage(config *core.Config, root string) (*TenantStorage, error) {
+	root, err := cleanManagedPath(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server data path: %w", err)
+	}
+	if err := os.MkdirAll(root, core.OwnerDirectoryMode); err != nil {
+		return nil, fmt.Errorf("failed to create server data directory: %w", err)
+	}
+	return &TenantStorage{
+		root:    root,
+		config:  config,
+		maxOpen: core.DefaultServerOpenStores,
+		stores:  make(map[Tenant]Storage),
+	}, nil
+}
+
+// Open returns the store for tenant, creating it when it does not exist. It
+// returns ErrMachineLimit when creating it would give the user more than
+// server.max_machines_per_user machines.
+func (t *TenantStorage) Open(tenant Tenant) (Storage, error) {
+	if !core.ValidTenantName(tenant.User) || !core.ValidTenantName(tenant.Machine) {
+		return nil, fmt.Errorf("invalid tenant %s/%s", tenant.User, tenant.Machine)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stores == nil {
+		return nil, errors.New("tenant storage is closed")
+	}
+	if store, ok := t.stores[tenant]; ok {
+		t.touch(tenant)
+		return store, nil
+	}
+	if err := t.checkMachineLimit(tenant); err != nil {
+		return nil, err
+	}
+	if err := t.evict(t.maxOpen - 1); err != nil {
+		return nil, err
+	}
+
+	config := *t.config
+	config.Daemon.DataDir = filepath.Join(t.root, tenant.User, tenant.Machine)
+	config.Storage.JSONFile = filepath.Join(config.Daemon.DataDir, tenantFileName)
+	store, err := NewJSONStorage(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage for %s/%s: %w", tenant.User, tenant.Machine, err)
+	}
+	t.stores[tenant] = store
+	t.used = append(t.used, tenant)
+	return store, nil
+}
+
+// touch marks tenant's open store as the most recently used
+func (t *TenantStorage) touch(tenant Tenant) {
+	for i, used := range t.used {
+		if used == tenant {
+			t.used = append(append(t.used[:i:i], t.used[i+1:]...), tenant)
+			return
+		}
+	}
+}
+
+// evict closes the least recently used stores until at most keep are open.
+// An evicted store is dropped even when closing it fails, since its
+// executions are journaled and replayed when it is next opened.
+func (t *TenantStorage) evict(keep int) error {
+	var errs []error
+	for len(t.used) > 0 && len(t.used) > keep {
+		tenant := t.used[0]
+		t.used = t.used[1:]
+		if err := t.stores[tenant].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close storage for %s/%s: %w", tenant.User, tenant.Machine, err))
+		}
+		delete(t.stores, tenant)
+	}
+	return errors.Join(errs...)
+}
+
+// checkMachineLimit returns ErrMachineLimit when tenant is a machine the
+// user has no store for and they already have the most allowed
+func (t *TenantStorage) checkMachineLimit(tenant Tenant) error {
+	limit := t.config.Server.MaxMachinesPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	machines := make(map[string]bool)
+	for open := range t.stores {
+		if open.User == tenant.User {
+			machines[open.Machine] = true
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(t.root, tenant.User))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read machines of %s: %w", tenant.User, err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(t.root, tenant.User, entry.Name(), tenantFileName)); err == nil {
+			machines[entry.Name()] = true
+		}
+	}
+	if machines[tenant.Machine] || len(machines) < limit {
+		return nil
+	}
+	return fmt.Errorf("%w: %s already syncs from %d machines", ErrMachineLimit, tenant.User, limit)
+}
+
+// Tenants lists every tenant with a store, sorted by user and machine
+func (t *TenantStorage) Tenants() ([]Tenant, error) {
+	users, err := os.ReadDir(t.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server data directory: %w", err)
+	}
+
+	var tenants []Tenant
+	for _, user := range users {
+		if !user.IsDir() || !core.ValidTenantName(user.Name()) {
+			continue
+		}
+		machines, err := os.ReadDir(filepath.Join(t.root, user.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, machine := range machines {
+			if !machine.IsDir() || !core.ValidTenantName(machine.Name()) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(t.root, user.Name(), machine.Name(), tenantFileName)); err != nil {
+				continue
+			}
+			tenants = append(tenants, Tenant{User: user.Name(), Machine: machine.Name()})
+		}
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		if tenants[i].User != tenants[j].User {
+			return tenants[i].User < tenants[j].User
+		}
+		return tenants[i].Machine < tenants[j].Machine
+	})
+	return tenants, nil
+}
+
+// StreamExecutions calls fn with each execution matching opts in the stores
+// of the tenants keep accepts, or of every tenant when keep is nil
+func (t *TenantStorage) StreamExecutions(keep func(Tenant) bool, opts QueryOptions, fn func(Tenant, *core.ExecutionRecord) error) error {
+	tenants, err := t.Tenants()
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		if keep != nil && !keep(tenant) {
+			continue
+		}
+		store, err := t.Open(tenant)
+		if err != nil {
+			return err
+		}
+		if err := store.StreamExecutions(opts, func(record *core.ExecutionRecord) error {
+			return fn(tenant, record)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every open tenant store
+func (t *TenantStorage) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for _, store := range t.stores {
+		if err := store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.stores = nil
+	t.used = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestTenantStorageKeepsTenantsApart(t *testing.T) {
+	root := t.TempDir()
+	tenants, err := NewTenantStorage(&core.Config{}, root)
+	if err != nil {
+		t.Fatalf("NewTenantStorage failed: %v", err)
+	}
+
+	ana, err := tenants.Open(Tenant{User: "ana", Machine: "laptop"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := ana.AddExecution(&core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("AddExecution failed: %v", err)
+	}
+	if _, err := tenants.Open(Tenant{User: "ben", Machine: "laptop"}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if again, _ := tenants.Open(Tenant{User: "ana", Machine: "laptop"}); again != ana {
+		t.Error("Expected an open tenant's store to be reused")
+	}
+
+	counts := make(map[string]int)
+	if err := tenants.StreamExecutions(nil, QueryOptions{}, func(tenant Tenant, _ *core.ExecutionRecord) error {
+		counts[tenant.User]++
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamExecutions failed: %v", err)
+	}
+	if counts["ana"] != 1 || counts["ben"] != 0 {
+		t.Errorf("Expected ana's execution only in her store, got %v", counts)
+	}
+
+	for _, tenant := range []Tenant{{User: "..", Machine: "laptop"}, {User: "ana", Machine: "a/b"}, {User: "", Machine: "laptop"}} {
+		if _, err := tenants.Open(tenant); err == nil {
+			t.Errorf("Expected Open(%v) to fail", tenant)
+		}
+	}
+
+	if err := tenants.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := tenants.Open(Tenant{User: "ana", Machine: "laptop"}); err == nil {
+		t.Error("Expected Open after Close to fail")
+	}
+
+	reopened, err := NewTenantStorage(&core.Config{}, root)
+	if err != nil {
+		t.Fatalf("NewTenantStorage failed: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	listed, err := reopened.Tenants()
+	if err != nil {
+		t.Fatalf("Tenants failed: %v", err)
+	}
+	if len(listed) != 2 || listed[0] != (Tenant{User: "ana", Machine: "laptop"}) {
+		t.Errorf("Expected both tenants listed from %s, got %v", root, listed)
+	}
+}
+
+func TestTenantStorageLimitsMachinesPerUser(t *testing.T) {
+	config := &core.Config{Server: core.ServerConfig{MaxMachinesPerUser: 2}}
+	tenants, err := NewTenantStorage(config, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTenantStorage failed: %v", err)
+	}
+	defer func() { _ = tenants.Close() }()
+
+	for _, machine := range []string{"laptop", "devbox"} {
+		if _, err := tenants.Open(Tenant{User: "ana", Machine: machine}); err != nil {
+			t.Fatalf("Open(%s) failed: %v", machine, err)
+		}
+	}
+	if _, err := tenants.Open(Tenant{User: "ana", Machine: "ci"}); !errors.Is(err, ErrMachineLimit) {
+		t.Errorf("Expected ErrMachineLimit for a third machine, got %v", err)
+	}
+	if _, err := tenants.Open(Tenant{User: "ana", Machine: "laptop"}); err != nil {
+		t.Errorf("Expected a known machine to open at the limit, got %v", err)
+	}
+	if _, err := tenants.Open(Tenant{User: "ben", Machine: "ci"}); err != nil {
+		t.Errorf("Expected the limit to apply per user, got %v", err)
+	}
+}
+
+func TestTenantStorageClosesLeastRecentlyUsed(t *testing.T) {
+	tenants, err := NewTenantStorage(&core.Config{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTenantStorage failed: %v", err)
+	}
+	defer func() { _ = tenants.Close() }()
+	tenants.maxOpen = 2
+
+	laptop := Tenant{User: "ana", Machine: "laptop"}
+	store, err := tenants.Open(laptop)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := store.AddExecution(&core.ExecutionRecord{Tool: core.ToolNPM, Command: "npm ls", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("AddExecution failed: %v", err)
+	}
+	for _, tenant := range []Tenant{{User: "ana", Machine: "devbox"}, laptop, {User: "ben", Machine: "laptop"}} {
+		if _, err := tenants.Open(tenant); err != nil {
+			t.Fatalf("Open(%v) failed: %v", tenant, err)
+		}
+	}
+
+	if len(tenants.stores) != 2 {
+		t.Errorf("Expected 2 open stores, got %d", len(tenants.stores))
+	}
+	if _, ok := tenants.stores[Tenant{User: "ana", Machine: "devbox"}]; ok {
+		t.Error("Expected the least recently used store closed")
+	}
+	if _, ok := tenants.stores[laptop]; !ok {
+		t.Error("Expected the recently used store kept open")
+	}
+
+	tenants.maxOpen = 1
+	if _, err := tenants.Open(Tenant{User: "ben", Machine: "devbox"}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	reopened, err := tenants.Open(laptop)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	executions, err := reopened.GetExecutions(QueryOptions{})
+	if err != nil || len(executions) != 1 {
+		t.Errorf("Expected a reopened store to keep its executions, got %d, %v", len(executions), err)
+	}
+}
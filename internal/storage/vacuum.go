@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+// VacuumResult is what Vacuum did and the size of the storage files
+// before and after
+type VacuumResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+	// Expired counts the executions past retention that were still on disk.
+	Expired int `json:"expired"`
+	// Recovered counts the executions found in shards the storage file did
+	// not list.
+	Recovered int `json:"recovered"`
+	// RemovedFiles counts the temporary files and unlisted shards removed.
+	RemovedFiles int `json:"removed_files"`
+	Shards       int `json:"shards"`
+}
+
+// Vacuum rewrites the storage of config: it drops executions past
+// retention that a shard not in memory still holds, takes in executions
+// from shards the storage file does not list, rewrites every shard, past
+// months compactly, recounts the statistics, and removes temporary files
+// left by interrupted saves.
+func Vacuum(config *core.Config) (*VacuumResult, error) {
+	opened, err := NewJSONStorage(config)
+	if err != nil {
+		return nil, err
+	}
+	j := opened.(*JSONStorage)
+	result, vacuumErr := j.vacuum()
+	if err := j.Close(); vacuumErr == nil && err != nil {
+		return nil, err
+	}
+	return result, vacuumErr
+}
+
+func (j *JSONStorage) vacuum() (*VacuumResult, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := &VacuumResult{}
+	err := j.withFileLock(func() error {
+		if err := j.reload(); err != nil {
+			return err
+		}
+		files, err := j.storageFiles()
+		if err != nil {
+			return err
+		}
+		result.SizeBefore = filesSize(files)
+		// Listed before saving, which replaces the storage file's own.
+		leftovers := temporaryFiles(files)
+
+		listed := make(map[string]bool, len(j.data.Metadata.Shards))
+		for _, shard := range j.data.Metadata.Shards {
+			listed[shardPath(j.filepath, shard.Month)] = true
+			if err := j.loadShard(shard.Month); err != nil {
+				return err
+			}
+			j.dirty[shard.Month] = true
+		}
+		stale, err := j.adoptUnlistedShards(listed)
+		if err != nil {
+			return err
+		}
+		result.Recovered = stale.recovered
+
+		now := time.Now()
+		kept := make([]core.ExecutionRecord, 0, len(j.data.Executions))
+		for _, exec := range j.data.Executions {
+			if cutoff := j.config.RetentionCutoff(exec.Tool, now); !cutoff.IsZero() && !exec.Timestamp.After(cutoff) {
+				result.Expired++
+				continue
+			}
+			kept = append(kept, exec)
+		}
+		j.replaceExecutions(kept)
+		j.index = buildExecutionIndex(kept)
+		if err := j.rebuildStatistics(); err != nil {
+			return err
+		}
+		if err := j.save(); err != nil {
+			return err
+		}
+
+		// A month whose shard was unlisted is listed again once saved.
+		saved := make(map[string]bool, len(j.data.Metadata.Shards))
+		for _, shard := range j.data.Metadata.Shards {
+			saved[shardPath(j.filepath, shard.Month)] = true
+		}
+		var remove []string
+		for _, path := range stale.paths {
+			if !saved[path] {
+				remove = append(remove, path)
+			}
+		}
+		for _, path := range append(remove, leftovers...) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			result.RemovedFiles++
+		}
+		result.Shards = len(j.data.Metadata.Shards)
+		files, err = j.storageFiles()
+		if err != nil {
+			return err
+		}
+		result.SizeAfter = filesSize(files)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// unlistedShards are the shard files the storage file does not list
+type unlistedShards struct {
+	paths     []string
+	recovered int
+}
+
+// adoptUnlistedShards adds the executions in shards missing from listed,
+// as a save cut short can leave, to memory unless an execution with the
+// same ID is stored already, and returns the shards so they can be
+// removed once the executions are saved elsewhere
+func (j *JSONStorage) adoptUnlistedShards(listed map[string]bool) (unlistedShards, error) {
+	var stale unlistedShards
+	paths, err := filepath.Glob(shardPath(j.filepath, shardGlob))
+	if err != nil {
+		return stale, fmt.Errorf("failed to list shards: %w", err)
+	}
+	seen := make(map[string]bool, len(j.data.Executions))
+	for _, exec := range j.data.Executions {
+		seen[exec.ID] = true
+	}
+	for _, path := range paths {
+		if listed[path] {
+			continue
+		}
+		raw, err := readManagedFile(path)
+		if err != nil {
+			return stale, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var shard shardFile
+		if err := json.Unmarshal(raw, &shard); err != nil {
+			return stale, &CorruptStorageError{Path: path, Err: err}
+		}
+		for _, exec := range shard.Executions {
+			if seen[exec.ID] {
+				continue
+			}
+			seen[exec.ID] = true
+			if err := j.storeExecution(exec); err != nil {
+				return stale, err
+			}
+			if err := j.countPackages(&exec); err != nil {
+				return stale, err
+			}
+			stale.recovered++
+		}
+		stale.paths = append(stale.paths, path)
+	}
+	return stale, nil
+}
+
+// storageFiles lists the storage file, its shards, journal, and the
+// temporary files of saves cut short
+func (j *JSONStorage) storageFiles() ([]string, error) {
+	files := []string{j.filepath, j.journalPath(), j.filepath + ".tmp"}
+	for _, pattern := range []string{shardPath(j.filepath, shardGlob), shardPath(j.filepath, shardGlob) + ".tmp"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list storage files: %w", err)
+		}
+		files = append(files, paths...)
+	}
+	return files, nil
+}
+
+// temporaryFiles returns the temporary files in files that exist
+func temporaryFiles(files []string) []string {
+	var temporary []string
+	for _, path := range files {
+		if filepath.Ext(path) != ".tmp" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			temporary = append(temporary, path)
+		}
+	}
+	return temporary
+}
+
+func filesSize(files []string) int64 {
+	var size int64
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func TestVacuumRewritesStorageAndReportsSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executions.json")
+	config := &core.Config{Storage: core.StorageConfig{JSONFile: path}}
+	store, err := NewJSONStorage(config)
+	if err != nil {
+		t.Fatalf("NewJSONStorage failed: %v", err)
+	}
+	now := time.Now().UTC()
+	old := &core.ExecutionRecord{Tool: "npm", Command: "npm ci", Timestamp: now.AddDate(0, -3, 0)}
+	expired := &core.ExecutionRecord{Tool: "go", Command: "go build", Timestamp: now.AddDate(-2, 0, 0)}
+	recent := &core.ExecutionRecord{Tool: "npm", Command: "npm test", Timestamp: now}
+	for _, record := range []*core.ExecutionRecord{old, expired, recent} {
+		addExecution(t, store, record)
+	}
+	closeStorage(t, store)
+
+	// An indented shard from before past months were written compactly, a
+	// shard a save cut short never listed, and a temporary file.
+	oldMonth := shardMonth(old.Timestamp)
+	raw, _ := json.MarshalIndent(shardFile{Month: oldMonth, Executions: []core.ExecutionRecord{*old}}, "", "  ")
+	if err := os.WriteFile(shardPath(path, oldMonth), raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write shard: %v", err)
+	}
+	unlisted := core.ExecutionRecord{ID: "unlisted", Tool: "npm", Command: "npm install tsx", Timestamp: now.AddDate(-1, -1, 0), PackagesAffected: []string{"tsx"}}
+	unlistedMonth := shardMonth(unlisted.Timestamp)
+	raw, _ = json.Marshal(shardFile{Month: unlistedMonth, Executions: []core.ExecutionRecord{unlisted}})
+	if err := os.WriteFile(shardPath(path, unlistedMonth), raw, core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write shard: %v", err)
+	}
+	if err := os.WriteFile(path+".tmp", []byte("{"), core.PrivateFileMode); err != nil {
+		t.Fatalf("Failed to write temporary file: %v", err)
+	}
+
+	config.Storage.RetentionDays = 500
+	result, err := Vacuum(config)
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if result.Expired != 1 || result.Recovered != 1 || result.RemovedFiles != 1 || result.Shards != 3 {
+		t.Errorf("Expected one expired, one recovered, one file removed, and three shards, got %+v", result)
+	}
+	if result.SizeBefore == 0 || result.SizeAfter >= result.SizeBefore {
+		t.Errorf("Expected storage to shrink, got %d -> %d bytes", result.SizeBefore, result.SizeAfter)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file removed, got %v", err)
+	}
+	if raw, _ := os.ReadFile(shardPath(path, oldMonth)); bytes.Contains(raw, []byte("\n")) {
+		t.Error("Expected the past month's shard written without indentation")
+	}
+
+	report, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 0 || report.Executions != 3 {
+		t.Errorf("Expected a clean report of three executions, got %+v", report)
+	}
+}
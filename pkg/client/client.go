@@ -0,0 +1,611 @@
+// Package client is a typed Go client for the diu daemon's local HTTP API.
+// It lists and records executions, reads packages, statistics, and time
+// series, and follows the live execution stream, so Go tools need not
+// build requests against the API by hand.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+	"github.com/yowainwright/diu/pkg/diu"
+)
+
+// API paths, relative to the daemon's address
+const (
+	ExecutionsPath      = "/api/v1/executions"
+	ExecutionBatchPath  = "/api/v1/executions/batch"
+	ExecutionStreamPath = "/api/v1/executions/stream"
+	PackagesPath        = "/api/v1/packages"
+	StatsPath           = "/api/v1/stats"
+	SeriesPath          = "/api/v1/series"
+	MonitorsPath        = "/api/v1/monitors"
+	HealthPath          = "/api/v1/health"
+	SummaryPath         = "/api/v1/summary"
+	ReloadPath          = "/api/v1/reload"
+	PausePath           = "/api/v1/pause"
+	ResumePath          = "/api/v1/resume"
+)
+
+const (
+	// maxErrorBodyBytes bounds how much of an error response is kept as its
+	// message.
+	maxErrorBodyBytes = 1024
+	// maxStreamLineBytes bounds one streamed execution.
+	maxStreamLineBytes = 1 << 20
+)
+
+// ErrStreamClosed is returned by StreamExecutions when the daemon ends the
+// stream, as it does when it stops
+var ErrStreamClosed = errors.New("daemon closed the execution stream")
+
+// APIError is a response from the daemon with an unexpected status
+type APIError struct {
+	StatusCode int
+	Status     string
+	// Message is the start of the response body, which holds the reason.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("daemon returned %s: %s", e.Status, e.Message)
+}
+
+// Client calls one daemon's API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of
+// http.DefaultClient, for timeouts or custom transports. Requests that
+// stream, such as StreamExecutions, last as long as their context, so the
+// client should not set an overall timeout for them.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New returns a client for the daemon at baseURL, such as
+// http://127.0.0.1:8081
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid daemon URL %q: expected http://host:port", baseURL)
+	}
+	c := &Client{baseURL: parsed, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// NewFromConfig returns a client for the daemon config describes, reaching
+// a daemon that listens on every interface through the loopback address
+func NewFromConfig(config *diu.Config, opts ...Option) (*Client, error) {
+	if !config.API.Enabled {
+		return nil, fmt.Errorf("the daemon API is disabled; set api.enabled to true")
+	}
+	host := config.API.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = core.DefaultAPIHost
+	}
+	endpoint := url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", strings.Trim(host, "[]"), config.API.Port)}
+	return New(endpoint.String(), opts...)
+}
+
+// BaseURL returns the daemon's address
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// ExecutionQuery filters ListExecutions. Zero fields do not filter.
+type ExecutionQuery struct {
+	Tool    string
+	Package string
+	// Dir keeps executions run in this directory or below it.
+	Dir     string
+	Project string
+	Session string
+	// Host is a hostname or machine ID.
+	Host     string
+	ExitCode *int
+	Failed   bool
+	// Match is a regular expression and Glob a glob over the command; at
+	// most one may be set.
+	Match string
+	Glob  string
+	Limit int
+}
+
+func (q ExecutionQuery) values() url.Values {
+	values := url.Values{}
+	setValue(values, "tool", q.Tool)
+	setValue(values, "package", q.Package)
+	setValue(values, "dir", q.Dir)
+	setValue(values, "project", q.Project)
+	setValue(values, "session", q.Session)
+	setValue(values, "host", q.Host)
+	setValue(values, "match", q.Match)
+	setValue(values, "glob", q.Glob)
+	if q.ExitCode != nil {
+		values.Set("exit_code", strconv.Itoa(*q.ExitCode))
+	}
+	if q.Failed {
+		values.Set("failed", "true")
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// ListExecutions returns the stored executions matching query, newest
+// first
+func (c *Client) ListExecutions(ctx context.Context, query ExecutionQuery) ([]*diu.ExecutionRecord, error) {
+	var executions []*diu.ExecutionRecord
+	if err := c.getJSON(ctx, ExecutionsPath, query.values(), &executions); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// PostExecution queues record for the daemon to store
+func (c *Client) PostExecution(ctx context.Context, record *diu.ExecutionRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode execution: %w", err)
+	}
+	resp, err := c.do(ctx, http.MethodPost, ExecutionsPath, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return closeResponse(resp)
+}
+
+// BatchResult reports which executions PostExecutions stored
+type BatchResult struct {
+	Accepted   int                 `json:"accepted"`
+	Duplicates int                 `json:"duplicates"`
+	Rejected   int                 `json:"rejected"`
+	Skipped    int                 `json:"skipped"`
+	Results    []BatchRecordResult `json:"results"`
+}
+
+// BatchRecordResult is the outcome of the execution at Index in a batch
+type BatchRecordResult struct {
+	Index int `json:"index"`
+	// Status is "accepted", "duplicate" for an execution already stored
+	// or repeated within the dedupe window, "skipped" for one the daemon
+	// does not record, such as while recording is paused, or "rejected".
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PostExecutions stores records in one request, reporting each one's
+// outcome; invalid records are rejected without failing the rest
+func (c *Client) PostExecutions(ctx context.Context, records []*diu.ExecutionRecord) (*BatchResult, error) {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode executions: %w", err)
+	}
+	var result BatchResult
+	if err := c.sendJSON(ctx, http.MethodPost, ExecutionBatchPath, bytes.NewReader(body), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PackageQuery filters and orders ListPackages
+type PackageQuery struct {
+	Tool string
+	// UnusedFor keeps packages not used within this duration, such as
+	// "30d".
+	UnusedFor string
+	// Sort is "usage_count" or "last_used" and Order "asc" or "desc".
+	Sort  string
+	Order string
+	Limit int
+}
+
+// ListPackages returns the tracked packages matching query
+func (c *Client) ListPackages(ctx context.Context, query PackageQuery) ([]*diu.PackageInfo, error) {
+	values := url.Values{}
+	setValue(values, "tool", query.Tool)
+	setValue(values, "unused_for", query.UnusedFor)
+	setValue(values, "sort", query.Sort)
+	setValue(values, "order", query.Order)
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	var packages []*diu.PackageInfo
+	if err := c.getJSON(ctx, PackagesPath, values, &packages); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+// Statistics returns the all-time statistics storage keeps
+func (c *Client) Statistics(ctx context.Context) (*diu.StorageStatistics, error) {
+	var stats diu.StorageStatistics
+	if err := c.getJSON(ctx, StatsPath, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// StatsQuery selects the executions Stats counts. Since or Until at local
+// midnight count whole days, which the daemon answers without scanning
+// executions.
+type StatsQuery struct {
+	Since *time.Time
+	Until *time.Time
+	// GroupBy is "tool", "day", "package", "hour", or "weekday".
+	GroupBy string
+	Tool    string
+}
+
+// Stats counts executions, in total and per group
+type Stats struct {
+	Since           *time.Time   `json:"since,omitempty"`
+	Until           *time.Time   `json:"until,omitempty"`
+	GroupBy         string       `json:"group_by,omitempty"`
+	TotalExecutions int          `json:"total_executions"`
+	Groups          []StatsGroup `json:"groups,omitempty"`
+}
+
+// StatsGroup is the count of one group's executions
+type StatsGroup struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Stats counts the executions query selects. A query with neither a range
+// nor a grouping counts every execution.
+func (c *Client) Stats(ctx context.Context, query StatsQuery) (*Stats, error) {
+	values := url.Values{}
+	setTime(values, "since", query.Since)
+	setTime(values, "until", query.Until)
+	setValue(values, "group_by", query.GroupBy)
+	setValue(values, "tool", query.Tool)
+	ungrouped := len(values.Get("since")+values.Get("until")+query.GroupBy) == 0
+	if ungrouped {
+		// Without a range or grouping the daemon answers with its stored
+		// statistics, so the total is counted by tool instead.
+		values.Set("group_by", "tool")
+	}
+
+	var stats Stats
+	if err := c.getJSON(ctx, StatsPath, values, &stats); err != nil {
+		return nil, err
+	}
+	if ungrouped {
+		stats.GroupBy, stats.Groups = "", nil
+	}
+	return &stats, nil
+}
+
+// SeriesQuery selects a time series. Zero fields take the daemon's
+// defaults: executions per day over the last 30 days.
+type SeriesQuery struct {
+	// Metric is "executions", "failures", or "duration".
+	Metric string
+	// Interval is a duration such as "1h", "1d", or "1w".
+	Interval string
+	Since    *time.Time
+	Until    *time.Time
+	Tool     string
+}
+
+// Series is a metric in consecutive intervals, every interval listed
+type Series struct {
+	Metric string `json:"metric"`
+	// Unit is "count", or "ms" for durations.
+	Unit     string        `json:"unit"`
+	Interval string        `json:"interval"`
+	Tool     string        `json:"tool,omitempty"`
+	Since    time.Time     `json:"since"`
+	Until    time.Time     `json:"until"`
+	Points   []SeriesPoint `json:"points"`
+}
+
+// SeriesPoint is one interval's value
+type SeriesPoint struct {
+	Start time.Time `json:"start"`
+	Value int64     `json:"value"`
+}
+
+// Series returns the metric query selects in time buckets
+func (c *Client) Series(ctx context.Context, query SeriesQuery) (*Series, error) {
+	values := url.Values{}
+	setValue(values, "metric", query.Metric)
+	setValue(values, "interval", query.Interval)
+	setTime(values, "since", query.Since)
+	setTime(values, "until", query.Until)
+	setValue(values, "tool", query.Tool)
+	var series Series
+	if err := c.getJSON(ctx, SeriesPath, values, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// Monitors lists the tools the daemon can monitor, whether each is enabled,
+// and whether its monitor is running
+func (c *Client) Monitors(ctx context.Context) ([]diu.MonitorStatus, error) {
+	var statuses []diu.MonitorStatus
+	if err := c.getJSON(ctx, MonitorsPath, nil, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// EnableMonitor enables tool's monitor in the daemon's config file and
+// starts it, returning its status afterwards
+func (c *Client) EnableMonitor(ctx context.Context, tool string) (*diu.MonitorStatus, error) {
+	return c.setMonitor(ctx, tool, "enable")
+}
+
+// DisableMonitor disables tool's monitor in the daemon's config file and
+// stops it, returning its status afterwards
+func (c *Client) DisableMonitor(ctx context.Context, tool string) (*diu.MonitorStatus, error) {
+	return c.setMonitor(ctx, tool, "disable")
+}
+
+func (c *Client) setMonitor(ctx context.Context, tool, action string) (*diu.MonitorStatus, error) {
+	var status diu.MonitorStatus
+	path := MonitorsPath + "/" + url.PathEscape(tool) + "/" + action
+	if err := c.sendJSON(ctx, http.MethodPost, path, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Health returns the daemon's health. An unhealthy daemon's status is
+// returned without an error.
+func (c *Client) Health(ctx context.Context) (*diu.HealthStatus, error) {
+	resp, err := c.send(ctx, http.MethodGet, HealthPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, responseError(resp)
+	}
+	var health diu.HealthStatus
+	if err := decodeResponse(resp, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Summary is the daemon's compact status for menu-bar and tray apps
+type Summary struct {
+	// Status is the daemon's health status, such as "healthy".
+	Status          string            `json:"status"`
+	Paused          bool              `json:"paused"`
+	PausedUntil     *time.Time        `json:"paused_until,omitempty"`
+	Version         string            `json:"version"`
+	Uptime          string            `json:"uptime"`
+	TodayExecutions int               `json:"today_executions"`
+	TodayFailures   int               `json:"today_failures"`
+	TodayByTool     map[string]int    `json:"today_by_tool"`
+	LastExecution   *SummaryExecution `json:"last_execution,omitempty"`
+	TrackedPackages int               `json:"tracked_packages"`
+	UnusedPackages  int               `json:"unused_packages"`
+	UnusedDays      int               `json:"unused_days"`
+}
+
+// SummaryExecution is the most recent execution in a Summary
+type SummaryExecution struct {
+	Tool      string    `json:"tool"`
+	Command   string    `json:"command"`
+	Timestamp time.Time `json:"timestamp"`
+	ExitCode  int       `json:"exit_code"`
+}
+
+// Summary returns today's counts, the last execution, and how many
+// packages have gone unused for unusedFor, such as "30d", or for 90 days
+// when it is empty
+func (c *Client) Summary(ctx context.Context, unusedFor string) (*Summary, error) {
+	values := url.Values{}
+	setValue(values, "unused_for", unusedFor)
+	var summary Summary
+	if err := c.getJSON(ctx, SummaryPath, values, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Reload asks the daemon to reload its configuration and monitors
+func (c *Client) Reload(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodPost, ReloadPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	return closeResponse(resp)
+}
+
+// PauseState reports whether the daemon's recording is paused
+func (c *Client) PauseState(ctx context.Context) (*diu.PauseState, error) {
+	var state diu.PauseState
+	if err := c.getJSON(ctx, PausePath, nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Pause makes the daemon drop incoming executions for duration, or until
+// Resume when duration is 0
+func (c *Client) Pause(ctx context.Context, duration time.Duration) (*diu.PauseState, error) {
+	values := url.Values{}
+	if duration > 0 {
+		values.Set("duration", duration.String())
+	}
+	resp, err := c.do(ctx, http.MethodPost, PausePath, values, nil)
+	if err != nil {
+		return nil, err
+	}
+	var state diu.PauseState
+	if err := decodeResponse(resp, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Resume resumes recording after Pause
+func (c *Client) Resume(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodPost, ResumePath, nil, nil)
+	if err != nil {
+		return err
+	}
+	return closeResponse(resp)
+}
+
+// StreamExecutions calls handle with each execution the daemon stores,
+// for one tool when tool is set, until ctx is done, handle returns an
+// error, or the daemon ends the stream with ErrStreamClosed. It returns
+// ctx's error once ctx is done.
+func (c *Client) StreamExecutions(ctx context.Context, tool string, handle func(*diu.ExecutionRecord) error) error {
+	values := url.Values{}
+	setValue(values, "tool", tool)
+	resp, err := c.do(ctx, http.MethodGet, ExecutionStreamPath, values, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record diu.ExecutionRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("invalid execution from daemon: %w", err)
+		}
+		if err := handle(&record); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("execution stream interrupted: %w", err)
+	}
+	return ErrStreamClosed
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, values url.Values, out interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, values, nil)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	resp, err := c.do(ctx, method, path, nil, body)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+// do sends a request and returns the response when its status is 2xx,
+// otherwise an *APIError
+func (c *Client) do(ctx context.Context, method, path string, values url.Values, body io.Reader) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, values, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// send sends a request and returns the response whatever its status
+func (c *Client) send(ctx context.Context, method, path string, values url.Values, body io.Reader) (*http.Response, error) {
+	endpoint := *c.baseURL
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + path
+	if len(values) > 0 {
+		endpoint.RawQuery = values.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to connect to the daemon at %s (is it running?): %w", c.baseURL.Host, err)
+	}
+	return resp, nil
+}
+
+// responseError reads resp's body into an *APIError and closes it
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	_ = resp.Body.Close()
+	return &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(message))}
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from daemon: %w", err)
+	}
+	return nil
+}
+
+func closeResponse(resp *http.Response) error {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func setValue(values url.Values, key, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
+
+func setTime(values url.Values, key string, t *time.Time) {
+	if t != nil {
+		values.Set(key, t.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/internal/core"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL + "/")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return c
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, value interface{}) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		t.Errorf("Failed to encode response: %v", err)
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+	var posted []byte
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.Method + " " + r.URL.Path {
+		case "GET " + ExecutionsPath:
+			if query.Get("tool") != "npm" || query.Get("exit_code") != "1" || query.Get("failed") != "true" || query.Get("limit") != "5" {
+				t.Errorf("Unexpected execution query %q", r.URL.RawQuery)
+			}
+			writeJSON(t, w, []core.ExecutionRecord{{Tool: "npm", Command: "npm ci", ExitCode: 1}})
+		case "POST " + ExecutionsPath:
+			posted, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusAccepted)
+		case "POST " + ExecutionBatchPath:
+			writeJSON(t, w, BatchResult{Accepted: 1, Rejected: 1, Results: []BatchRecordResult{{Index: 0, Status: "accepted", ID: "a"}, {Index: 1, Status: "rejected", Error: "tool is required"}}})
+		case "GET " + PackagesPath:
+			if query.Get("unused_for") != "30d" || query.Get("sort") != "last_used" {
+				t.Errorf("Unexpected package query %q", r.URL.RawQuery)
+			}
+			writeJSON(t, w, []core.PackageInfo{{Name: "tsx", Tool: "npm", UsageCount: 3}})
+		case "GET " + StatsPath:
+			if query.Get("group_by") == "" {
+				writeJSON(t, w, core.StorageStatistics{TotalExecutions: 7})
+				return
+			}
+			writeJSON(t, w, Stats{GroupBy: query.Get("group_by"), TotalExecutions: 4, Groups: []StatsGroup{{Key: "npm", Count: 4}}})
+		case "GET " + SeriesPath:
+			if query.Get("metric") != "duration" || query.Get("since") != "2026-03-02T00:00:00Z" {
+				t.Errorf("Unexpected series query %q", r.URL.RawQuery)
+			}
+			writeJSON(t, w, Series{Metric: "duration", Unit: "ms", Points: []SeriesPoint{{Value: 3000}}})
+		case "GET " + MonitorsPath:
+			writeJSON(t, w, []core.MonitorStatus{{Tool: "npm", Enabled: true, Active: true}})
+		case "POST " + MonitorsPath + "/pip/disable":
+			writeJSON(t, w, core.MonitorStatus{Tool: "pip"})
+		case "POST " + PausePath:
+			if query.Get("duration") != "30m0s" {
+				t.Errorf("Unexpected pause query %q", r.URL.RawQuery)
+			}
+			writeJSON(t, w, core.PauseState{Paused: true, Until: time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)})
+		case "POST " + ResumePath:
+			writeJSON(t, w, core.PauseState{})
+		case "GET " + SummaryPath:
+			if query.Get("unused_for") != "30d" {
+				t.Errorf("Unexpected summary query %q", r.URL.RawQuery)
+			}
+			writeJSON(t, w, Summary{Status: "healthy", TodayExecutions: 3, LastExecution: &SummaryExecution{Tool: "npm", Command: "npm ci"}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	ctx := t.Context()
+
+	exitCode := 1
+	executions, err := c.ListExecutions(ctx, ExecutionQuery{Tool: "npm", ExitCode: &exitCode, Failed: true, Limit: 5})
+	if err != nil || len(executions) != 1 || executions[0].Command != "npm ci" {
+		t.Errorf("Expected one execution, got %+v, %v", executions, err)
+	}
+
+	if err := c.PostExecution(ctx, &core.ExecutionRecord{Tool: "npm", Command: "npm test"}); err != nil {
+		t.Errorf("PostExecution failed: %v", err)
+	}
+	if !strings.Contains(string(posted), `"command":"npm test"`) {
+		t.Errorf("Expected the execution posted, got %s", posted)
+	}
+
+	batch, err := c.PostExecutions(ctx, []*core.ExecutionRecord{{Tool: "npm"}, {}})
+	if err != nil || batch.Accepted != 1 || batch.Results[1].Error != "tool is required" {
+		t.Errorf("Expected one execution accepted, got %+v, %v", batch, err)
+	}
+
+	packages, err := c.ListPackages(ctx, PackageQuery{UnusedFor: "30d", Sort: "last_used"})
+	if err != nil || len(packages) != 1 || packages[0].UsageCount != 3 {
+		t.Errorf("Expected one package, got %+v, %v", packages, err)
+	}
+
+	stats, err := c.Statistics(ctx)
+	if err != nil || stats.TotalExecutions != 7 {
+		t.Errorf("Expected stored statistics, got %+v, %v", stats, err)
+	}
+	grouped, err := c.Stats(ctx, StatsQuery{GroupBy: "tool"})
+	if err != nil || grouped.TotalExecutions != 4 || len(grouped.Groups) != 1 {
+		t.Errorf("Expected counts by tool, got %+v, %v", grouped, err)
+	}
+	total, err := c.Stats(ctx, StatsQuery{Tool: "npm"})
+	if err != nil || total.TotalExecutions != 4 || total.GroupBy != "" || total.Groups != nil {
+		t.Errorf("Expected an ungrouped total, got %+v, %v", total, err)
+	}
+
+	since := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	series, err := c.Series(ctx, SeriesQuery{Metric: "duration", Since: &since})
+	if err != nil || series.Unit != "ms" || series.Points[0].Value != 3000 {
+		t.Errorf("Expected a duration series, got %+v, %v", series, err)
+	}
+
+	statuses, err := c.Monitors(ctx)
+	if err != nil || len(statuses) != 1 || !statuses[0].Active {
+		t.Errorf("Expected one running monitor, got %+v, %v", statuses, err)
+	}
+	status, err := c.DisableMonitor(ctx, "pip")
+	if err != nil || status.Tool != "pip" || status.Enabled {
+		t.Errorf("Expected pip disabled, got %+v, %v", status, err)
+	}
+
+	paused, err := c.Pause(ctx, 30*time.Minute)
+	if err != nil || !paused.Paused || paused.Until.IsZero() {
+		t.Errorf("Expected recording paused, got %+v, %v", paused, err)
+	}
+	if err := c.Resume(ctx); err != nil {
+		t.Errorf("Resume failed: %v", err)
+	}
+
+	summary, err := c.Summary(ctx, "30d")
+	if err != nil || summary.TodayExecutions != 3 || summary.LastExecution.Command != "npm ci" {
+		t.Errorf("Expected today's summary, got %+v, %v", summary, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case HealthPath:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(core.HealthStatus{Status: core.HealthStatusDegraded})
+		default:
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+		}
+	})
+
+	health, err := c.Health(t.Context())
+	if err != nil || health.Status != core.HealthStatusDegraded {
+		t.Errorf("Expected a degraded daemon's health, got %+v, %v", health, err)
+	}
+
+	_, err = c.ListExecutions(t.Context(), ExecutionQuery{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "invalid limit" {
+		t.Errorf("Expected an API error, got %v", err)
+	}
+
+	if _, err := New("127.0.0.1:8081"); err == nil {
+		t.Error("Expected a URL without a scheme rejected")
+	}
+	config := core.DefaultConfig()
+	config.API.Enabled = false
+	if _, err := NewFromConfig(config); err == nil || !strings.Contains(err.Error(), "api.enabled") {
+		t.Errorf("Expected the disabled API reported, got %v", err)
+	}
+	config.API.Enabled, config.API.Host, config.API.Port = true, "0.0.0.0", 9000
+	if c, err := NewFromConfig(config); err != nil || c.BaseURL() != "http://"+core.DefaultAPIHost+":9000" {
+		t.Errorf("Expected the loopback address, got %v, %v", c, err)
+	}
+}
+
+func TestClientStreamExecutions(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ExecutionStreamPath || r.URL.Query().Get("tool") != "npm" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, `{"tool":"npm","command":"npm ci"}`+"\n\n"+`{"tool":"npm","command":"npm test"}`+"\n")
+	})
+
+	var commands []string
+	err := c.StreamExecutions(t.Context(), "npm", func(record *core.ExecutionRecord) error {
+		commands = append(commands, record.Command)
+		return nil
+	})
+	if !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Expected the stream closed, got %v", err)
+	}
+	if strings.Join(commands, ",") != "npm ci,npm test" {
+		t.Errorf("Expected both executions, got %v", commands)
+	}
+
+	stop := errors.New("stop")
+	if err := c.StreamExecutions(t.Context(), "npm", func(*core.ExecutionRecord) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package diu exposes the record types diu stores and reports, so other
+// projects can read, write, and build on diu's data without importing its
+// internal packages. The types are aliases of diu's own, so values pass
+// between this package, pkg/storage, pkg/parse, and pkg/client unchanged.
+package diu
+
+import "github.com/yowainwright/diu/internal/core"
+
+// Records
+type (
+	// ExecutionRecord is one package manager command diu observed.
+	ExecutionRecord = core.ExecutionRecord
+	// Output is the captured tail of a command's stdout or stderr.
+	Output = core.Output
+	// VersionChange is a package version an execution changed.
+	VersionChange = core.VersionChange
+	// PackageInfo is a package installed by a tracked tool and its usage.
+	PackageInfo = core.PackageInfo
+	// StorageStatistics totals the stored executions.
+	StorageStatistics = core.StorageStatistics
+	// DayAggregate totals the executions of one or more days.
+	DayAggregate = core.DayAggregate
+	// HealthStatus is the daemon's health report.
+	HealthStatus = core.HealthStatus
+	// MonitorStatus is whether a tool's monitor is enabled and running.
+	MonitorStatus = core.MonitorStatus
+	// PauseState is whether recording is paused, and until when.
+	PauseState = core.PauseState
+)
+
+// Config is diu's configuration, as read from its config file; see
+// DefaultConfigPath.
+type Config = core.Config
+
+// Tool names as records store them
+const (
+	ToolHomebrew = core.ToolHomebrew
+	ToolNPM      = core.ToolNPM
+	ToolPNPM     = core.ToolPNPM
+	ToolBun      = core.ToolBun
+	ToolYarn     = core.ToolYarn
+	ToolGo       = core.ToolGo
+	ToolPip      = core.ToolPip
+	ToolUV       = core.ToolUV
+	ToolPoetry   = core.ToolPoetry
+	ToolGem      = core.ToolGem
+	ToolCargo    = core.ToolCargo
+	ToolGoBinary = core.ToolGoBinary
+	ToolMake     = core.ToolMake
+	ToolJust     = core.ToolJust
+	ToolTask     = core.ToolTask
+)
+
+// DefaultConfig returns the configuration diu uses when its config file
+// sets nothing.
+func DefaultConfig() *Config {
+	return core.DefaultConfig()
+}
+
+// DefaultConfigPath returns the first of config.json, config.yaml,
+// config.yml, and config.toml that exists in diu's config directory, or
+// config.json there when none does.
+func DefaultConfigPath() string {
+	return core.DefaultConfigPath()
+}
+
+// LoadConfig reads the configuration at path, or at DefaultConfigPath when
+// path is empty, returning the defaults when the file does not exist.
+func LoadConfig(path string) (*Config, error) {
+	return core.LoadConfig(path)
+}
+
+// NormalizeToolName maps a tool's aliases, such as brew or python3, to the
+// name records store.
+func NormalizeToolName(tool string) string {
+	return core.NormalizeToolName(tool)
+}
@@ -0,0 +1,42 @@
+// Package parse reads package manager commands the way diu's monitors do,
+// reporting the packages a command affects and metadata such as its
+// action, so other projects can classify commands without running diu.
+// Parsing only reads the command line; it neither runs the tool nor needs
+// it installed.
+package parse
+
+import (
+	"github.com/yowainwright/diu/internal/monitors"
+	"github.com/yowainwright/diu/pkg/diu"
+)
+
+// Tools lists the tools Command can parse, sorted.
+func Tools() []string {
+	return monitors.SupportedTools()
+}
+
+// Command parses a command of tool, given as the binary name and its
+// arguments such as "npm", []string{"install", "tsx"}. The record has the
+// tool, the affected packages, and metadata such as "action"; Command
+// returns an error for a tool that is not in Tools.
+func Command(tool, command string, args []string) (*diu.ExecutionRecord, error) {
+	monitor, err := monitors.New(tool)
+	if err != nil {
+		return nil, err
+	}
+	return monitor.ParseCommand(command, args)
+}
+
+// Enrich fills in record's affected packages and metadata from its command
+// and arguments, keeping any already set. It normalizes the record's tool
+// name and reports false, leaving the rest unchanged, for a tool that is
+// not in Tools.
+func Enrich(record *diu.ExecutionRecord) bool {
+	record.Tool = diu.NormalizeToolName(record.Tool)
+	monitor, err := monitors.New(record.Tool)
+	if err != nil {
+		return false
+	}
+	monitors.EnrichExecutionRecord(monitor, record)
+	return true
+}
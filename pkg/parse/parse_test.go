@@ -0,0 +1,49 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yowainwright/diu/pkg/diu"
+)
+
+func TestCommand(t *testing.T) {
+	record, err := Command("brew", "brew", []string{"uninstall", "jq"})
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if record.Tool != diu.ToolHomebrew || !reflect.DeepEqual(record.PackagesAffected, []string{"jq"}) || record.Metadata["action"] != "uninstall" {
+		t.Errorf("Expected jq's uninstall, got %+v", record)
+	}
+
+	if _, err := Command("apt", "apt", []string{"install", "jq"}); err == nil {
+		t.Error("Expected an unsupported tool rejected")
+	}
+	for _, tool := range Tools() {
+		if _, err := Command(tool, tool, []string{"install", "example"}); err != nil {
+			t.Errorf("Expected %s parsed, got %v", tool, err)
+		}
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	record := &diu.ExecutionRecord{
+		Tool:     "NPM",
+		Command:  "npm",
+		Args:     []string{"install", "-g", "tsx"},
+		Metadata: map[string]interface{}{"action": "bootstrap"},
+	}
+	if !Enrich(record) {
+		t.Fatal("Expected npm supported")
+	}
+	if record.Tool != diu.ToolNPM || !reflect.DeepEqual(record.PackagesAffected, []string{"tsx"}) {
+		t.Errorf("Expected tsx affected, got %+v", record)
+	}
+	if record.Metadata["action"] != "bootstrap" || record.Metadata["global"] != true {
+		t.Errorf("Expected the set action kept and global added, got %v", record.Metadata)
+	}
+
+	if Enrich(&diu.ExecutionRecord{Tool: "apt"}) {
+		t.Error("Expected apt unsupported")
+	}
+}
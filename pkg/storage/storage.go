@@ -0,0 +1,71 @@
+// Package storage opens diu's execution and package store, the same files
+// the daemon and CLI write, so other projects can query and record
+// executions without importing diu's internal packages.
+package storage
+
+import (
+	"regexp"
+
+	"github.com/yowainwright/diu/internal/storage"
+	"github.com/yowainwright/diu/pkg/diu"
+)
+
+// Storage reads and writes executions, packages, and statistics.
+type Storage = storage.Storage
+
+// Query and aggregation options and results
+type (
+	// QueryOptions selects and orders executions.
+	QueryOptions = storage.QueryOptions
+	// AggregateOptions selects executions to total and how to group them.
+	AggregateOptions = storage.AggregateOptions
+	// AggregateGroup totals the executions in one group.
+	AggregateGroup = storage.AggregateGroup
+	// AggregateResult holds the totals of an aggregation.
+	AggregateResult = storage.AggregateResult
+	// ImportResult counts the records ImportRecords inserted and skipped.
+	ImportResult = storage.ImportResult
+	// Recovery describes how OpenWithRecovery repaired corrupt storage.
+	Recovery = storage.Recovery
+	// CorruptStorageError is returned by Open for storage that cannot be
+	// parsed.
+	CorruptStorageError = storage.CorruptStorageError
+)
+
+// Groupings accepted by AggregateOptions.GroupBy
+const (
+	GroupByTool    = storage.GroupByTool
+	GroupByPackage = storage.GroupByPackage
+	GroupByDay     = storage.GroupByDay
+	GroupByHour    = storage.GroupByHour
+	GroupByWeekday = storage.GroupByWeekday
+)
+
+// ErrDuplicateExecution is returned by AddExecution for a record whose ID is
+// already stored.
+var ErrDuplicateExecution = storage.ErrDuplicateExecution
+
+// Open opens the storage file config names, creating it when it does not
+// exist. Close it when done.
+func Open(config *diu.Config) (Storage, error) {
+	return storage.NewJSONStorage(config)
+}
+
+// OpenWithRecovery opens storage like Open and, when it is corrupt,
+// restores the newest valid backup first. The Recovery is nil when storage
+// opened normally.
+func OpenWithRecovery(config *diu.Config) (Storage, *Recovery, error) {
+	return storage.OpenWithRecovery(config)
+}
+
+// CompileCommandGlob compiles a shell-style glob, such as "npm i*", for
+// QueryOptions.CommandPattern. The glob must match the whole command text.
+func CompileCommandGlob(glob string) (*regexp.Regexp, error) {
+	return storage.CompileCommandGlob(glob)
+}
+
+// CompileCommandMatch compiles a regular expression for
+// QueryOptions.CommandPattern. It may match anywhere in the command text.
+func CompileCommandMatch(pattern string) (*regexp.Regexp, error) {
+	return storage.CompileCommandMatch(pattern)
+}
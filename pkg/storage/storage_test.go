@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yowainwright/diu/pkg/diu"
+)
+
+func TestOpen(t *testing.T) {
+	config := diu.DefaultConfig()
+	config.Storage.JSONFile = filepath.Join(t.TempDir(), "executions.json")
+
+	store, err := Open(config)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	now := time.Now()
+	for _, record := range []*diu.ExecutionRecord{
+		{ID: "a", Tool: diu.ToolNPM, Command: "npm install tsx", Timestamp: now},
+		{ID: "b", Tool: diu.ToolNPM, Command: "npm test", Timestamp: now},
+		{ID: "c", Tool: diu.ToolGo, Command: "go build", Timestamp: now},
+	} {
+		if err := store.AddExecution(record); err != nil {
+			t.Fatalf("AddExecution failed: %v", err)
+		}
+	}
+	if err := store.AddExecution(&diu.ExecutionRecord{ID: "a", Tool: diu.ToolNPM, Timestamp: now}); !errors.Is(err, ErrDuplicateExecution) {
+		t.Errorf("Expected a duplicate rejected, got %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	store, recovery, err := OpenWithRecovery(config)
+	if err != nil || recovery != nil {
+		t.Fatalf("Expected storage reopened, got %v, %v", recovery, err)
+	}
+	defer store.Close()
+
+	pattern, err := CompileCommandGlob("npm i*")
+	if err != nil {
+		t.Fatalf("CompileCommandGlob failed: %v", err)
+	}
+	executions, err := store.GetExecutions(QueryOptions{CommandPattern: pattern})
+	if err != nil || len(executions) != 1 || executions[0].ID != "a" {
+		t.Errorf("Expected npm's install, got %+v, %v", executions, err)
+	}
+
+	result, err := store.Aggregate(AggregateOptions{GroupBy: GroupByTool})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if result.Total.Count != 3 || len(result.Groups) != 2 || result.Groups[0] != (AggregateGroup{Key: diu.ToolNPM, Count: 2}) {
+		t.Errorf("Expected counts by tool, got %+v", result)
+	}
+}